@@ -0,0 +1,187 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const (
+	// Data directory, also the package directory for the test.
+	testdata = "testdata"
+
+	// Binary to be built.
+	testcover = "./testcover.exe"
+)
+
+var (
+	// Files we use.
+	testMain     = filepath.Join(testdata, "main.go")
+	testTest     = filepath.Join(testdata, "test.go")
+	coverInput   = filepath.Join(testdata, "test_line.go")
+	coverOutput  = filepath.Join(testdata, "test_cover.go")
+	coverProfile = filepath.Join(testdata, "profile.cov")
+	funcOutput   = filepath.Join(testdata, "func.out")
+	htmlOutput   = filepath.Join(testdata, "cover.html")
+)
+
+var debug = false // Keeps the rewritten files around if set.
+
+// Run this shell script, but do it in Go so it can be run by "go test".
+//
+//	replace the word LINE with the line number < testdata/test.go > testdata/test_line.go
+// 	go build -o ./testcover
+// 	./testcover -mode=count -var=coverTest -o ./testdata/test_cover.go testdata/test_line.go
+//	go run ./testdata/main.go ./testdata/test.go
+//
+func TestCover(t *testing.T) {
+	switch runtime.GOOS {
+	case "nacl":
+		t.Skipf("skipping; %v/%v no support for forking", runtime.GOOS, runtime.GOARCH)
+	case "darwin", "android":
+		switch runtime.GOARCH {
+		case "arm", "arm64":
+			t.Skipf("skipping; %v/%v no support for forking", runtime.GOOS, runtime.GOARCH)
+		}
+	}
+	// Read in the test file (testTest) and write it, with LINEs specified, to coverInput.
+	file, err := ioutil.ReadFile(testTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(file, []byte("\n"))
+	for i, line := range lines {
+		lines[i] = bytes.Replace(line, []byte("LINE"), []byte(fmt.Sprint(i+1)), -1)
+	}
+	if err := ioutil.WriteFile(coverInput, bytes.Join(lines, []byte("\n")), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	// defer removal of test_line.go
+	if !debug {
+		defer os.Remove(coverInput)
+	}
+
+	// go build -o testcover
+	cmd := exec.Command("go", "build", "-o", testcover)
+	run(cmd, t)
+
+	// defer removal of testcover
+	defer os.Remove(testcover)
+
+	// ./testcover -mode=count -var=coverTest -o ./testdata/test_cover.go testdata/test_line.go
+	cmd = exec.Command(testcover, "-mode=count", "-var=coverTest", "-o", coverOutput, coverInput)
+	run(cmd, t)
+
+	// defer removal of ./testdata/test_cover.go
+	if !debug {
+		defer os.Remove(coverOutput)
+	}
+
+	// go run ./testdata/main.go ./testdata/test.go
+	cmd = exec.Command("go", "run", testMain, coverOutput)
+	run(cmd, t)
+}
+
+// TestFuncAndHTML checks the -func and -html reports for a profile
+// that covers some, but not all, of the statements in testdata/test.go.
+func TestFuncAndHTML(t *testing.T) {
+	switch runtime.GOOS {
+	case "nacl":
+		t.Skipf("skipping; %v/%v no support for forking", runtime.GOOS, runtime.GOARCH)
+	case "darwin", "android":
+		switch runtime.GOARCH {
+		case "arm", "arm64":
+			t.Skipf("skipping; %v/%v no support for forking", runtime.GOOS, runtime.GOARCH)
+		}
+	}
+	// The profile names the file by a relative path, as findFile
+	// does not look those up in GOROOT or GOPATH.
+	name := "./" + filepath.ToSlash(testTest)
+	profile := "mode: set\n" +
+		name + ":15.16,29.2 13 1\n" +
+		name + ":47.19,49.2 1 1\n" +
+		name + ":111.21,127.2 7 0\n"
+	if err := ioutil.WriteFile(coverProfile, []byte(profile), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if !debug {
+		defer os.Remove(coverProfile)
+	}
+
+	cmd := exec.Command("go", "build", "-o", testcover)
+	run(cmd, t)
+	defer os.Remove(testcover)
+
+	cmd = exec.Command(testcover, "-func", coverProfile, "-o", funcOutput)
+	run(cmd, t)
+	if !debug {
+		defer os.Remove(funcOutput)
+	}
+	out, err := ioutil.ReadFile(funcOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The columns are aligned by tabwriter, so compare the fields of each line.
+	var got []string
+	for _, line := range strings.Split(string(out), "\n") {
+		got = append(got, strings.Join(strings.Fields(line), " "))
+	}
+	for _, want := range []string{
+		name + ":15: testAll 100.0%",
+		name + ":47: testSimple 100.0%",
+		name + ":111: testBlockRun 0.0%",
+		"total: (statements) 66.7%",
+	} {
+		found := false
+		for _, line := range got {
+			if line == want {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("-func output does not contain %q:\n%s", want, out)
+		}
+	}
+
+	cmd = exec.Command(testcover, "-html", coverProfile, "-o", htmlOutput)
+	run(cmd, t)
+	if !debug {
+		defer os.Remove(htmlOutput)
+	}
+	out, err = ioutil.ReadFile(htmlOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<span class="cov8" title="1">{
+        check(LINE, 1)
+}</span>`,
+		`<span class="cov0" title="0">{
+        check(LINE, 1)
+        {`,
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("-html output does not contain %q", want)
+		}
+	}
+}
+
+func run(c *exec.Cmd, t *testing.T) {
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	err := c.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"sort"
+)
+
+// An editBuffer is a queue of insertions to apply to a file's content.
+// Cover annotates a file by inserting text rather than by rewriting
+// and printing the syntax tree, so the comments, formatting and line
+// numbers of the original source are preserved.
+type editBuffer struct {
+	old []byte
+	q   edits
+}
+
+// An edit inserts new text at the byte offset pos of the original content.
+type edit struct {
+	pos int
+	new string
+}
+
+type edits []edit
+
+func (x edits) Len() int           { return len(x) }
+func (x edits) Swap(i, j int)      { x[i], x[j] = x[j], x[i] }
+func (x edits) Less(i, j int) bool { return x[i].pos < x[j].pos }
+
+// newEditBuffer returns an editBuffer for the content data.
+func newEditBuffer(data []byte) *editBuffer {
+	return &editBuffer{old: data}
+}
+
+// Insert records the insertion of new at the byte offset pos.
+// Insertions at the same offset appear in the order they were made.
+func (b *editBuffer) Insert(pos int, new string) {
+	if pos < 0 || pos > len(b.old) {
+		panic("invalid edit position")
+	}
+	b.q = append(b.q, edit{pos, new})
+}
+
+// Bytes returns the content with all the insertions applied.
+func (b *editBuffer) Bytes() []byte {
+	sort.Stable(b.q)
+	var buf bytes.Buffer
+	offset := 0
+	for _, e := range b.q {
+		buf.Write(b.old[offset:e.pos])
+		buf.WriteString(e.new)
+		offset = e.pos
+	}
+	buf.Write(b.old[offset:])
+	return buf.Bytes()
+}
@@ -732,14 +732,21 @@ func install(dir string) {
 // matchfield reports whether the field matches this build.
 func matchfield(f string) bool {
 	for _, tag := range strings.Split(f, ",") {
-		if tag == goos || tag == goarch || tag == "cmd_go_bootstrap" || tag == "go1.1" || (goos == "android" && tag == "linux") {
-			continue
+		if !matchtag(tag) {
+			return false
 		}
-		return false
 	}
 	return true
 }
 
+// matchtag reports whether the tag (name or !name) matches.
+func matchtag(tag string) bool {
+	if strings.HasPrefix(tag, "!") {
+		return len(tag) > 1 && tag[1] != '!' && !matchtag(tag[1:])
+	}
+	return tag == goos || tag == goarch || tag == "cmd_go_bootstrap" || tag == "go1.1" || (goos == "android" && tag == "linux")
+}
+
 // shouldbuild reports whether we should build this file.
 // It applies the same rules that are used with context tags
 // in package go/build, except that the GOOS and GOARCH
@@ -809,7 +816,7 @@ func shouldbuild(file, dir string) bool {
 			continue
 		}
 		for _, p := range fields[2:] {
-			if (p[0] == '!' && !matchfield(p[1:])) || matchfield(p) {
+			if matchfield(p) {
 				goto fieldmatch
 			}
 		}
@@ -871,13 +878,19 @@ var buildorder = []string{
 	"bufio",
 	"sort",
 	"container/heap",
+	"hash",
+	"crypto",
+	"crypto/sha256",
+	"hash/crc32",
 	"encoding/base64",
 	"syscall",
 	"time",
 	"internal/syscall/windows",
+	"internal/testlog",
 	"os",
 	"reflect",
 	"fmt",
+	"compress/flate",
 	"encoding",
 	"encoding/binary",
 	"encoding/json",
@@ -885,6 +898,7 @@ var buildorder = []string{
 	"path/filepath",
 	"path",
 	"io/ioutil",
+	"archive/zip",
 	"log",
 	"regexp/syntax",
 	"regexp",
@@ -892,6 +906,7 @@ var buildorder = []string{
 	"go/scanner",
 	"go/ast",
 	"go/parser",
+	"context",
 	"os/exec",
 	"os/signal",
 	"net/url",
@@ -899,6 +914,8 @@ var buildorder = []string{
 	"text/template",
 	"go/doc",
 	"go/build",
+	"cmd/internal/cover",
+	"cmd/internal/test2json",
 	"cmd/go",
 }
 
@@ -924,9 +941,14 @@ var cleantab = []string{
 	"cmd/old9a",
 
 	// Go packages.
+	"archive/zip",
 	"bufio",
 	"bytes",
+	"compress/flate",
 	"container/heap",
+	"context",
+	"crypto",
+	"crypto/sha256",
 	"encoding",
 	"encoding/base64",
 	"encoding/json",
@@ -939,6 +961,8 @@ var cleantab = []string{
 	"go/parser",
 	"go/scanner",
 	"go/token",
+	"hash",
+	"hash/crc32",
 	"io",
 	"io/ioutil",
 	"log",
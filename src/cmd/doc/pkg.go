@@ -0,0 +1,487 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/doc"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	punchedCardWidth = 80 // These things just won't leave us alone.
+	indentedWidth    = punchedCardWidth - len(indent)
+	indent           = "    "
+)
+
+type Package struct {
+	writer   io.Writer // Destination for output.
+	name     string    // Package name, json for encoding/json.
+	userPath string    // String the user used to find this package.
+	doc      *doc.Package
+	build    *build.Package
+	fs       *token.FileSet // Needed for printing.
+	buf      bytes.Buffer
+}
+
+type PackageError string // type returned by pkg.Fatalf.
+
+func (p PackageError) Error() string {
+	return string(p)
+}
+
+// Fatalf is like log.Fatalf, but panics so it can be recovered in the
+// main do function, so it doesn't cause an exit. Allows testing to work
+// without running a subprocess. The log prefix will be added when
+// logged in main; it is not added here.
+func (pkg *Package) Fatalf(format string, args ...interface{}) {
+	panic(PackageError(fmt.Sprintf(format, args...)))
+}
+
+// parsePackage turns the build package we found into a parsed package
+// we can then use to generate documentation.
+func parsePackage(writer io.Writer, pkg *build.Package, userPath string) (*Package, error) {
+	fs := token.NewFileSet()
+	// include tells parser.ParseDir which files to include.
+	// That means the file must be in the build package's GoFiles or CgoFiles
+	// list only (no tag-ignored files, tests, swig or other non-Go files).
+	include := func(info os.FileInfo) bool {
+		for _, name := range pkg.GoFiles {
+			if name == info.Name() {
+				return true
+			}
+		}
+		for _, name := range pkg.CgoFiles {
+			if name == info.Name() {
+				return true
+			}
+		}
+		return false
+	}
+	pkgs, err := parser.ParseDir(fs, pkg.Dir, include, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	astPkg := pkgs[pkg.Name]
+	if astPkg == nil {
+		return nil, fmt.Errorf("no package %s in %s", pkg.Name, pkg.Dir)
+	}
+
+	// The builtin package declares lower-case types such as int;
+	// treat them as exported.
+	if pkg.ImportPath == "builtin" {
+		unexported = true
+	}
+	// By default, go/doc drops unexported declarations and the
+	// unexported fields and methods of structs and interfaces.
+	mode := doc.Mode(0)
+	if unexported {
+		mode |= doc.AllDecls
+	}
+	docPkg := doc.New(astPkg, pkg.ImportPath, mode)
+
+	return &Package{
+		writer:   writer,
+		name:     pkg.Name,
+		userPath: userPath,
+		doc:      docPkg,
+		build:    pkg,
+		fs:       fs,
+	}, nil
+}
+
+func (pkg *Package) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(&pkg.buf, format, args...)
+}
+
+func (pkg *Package) flush() {
+	_, err := pkg.writer.Write(pkg.buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	pkg.buf.Reset() // Not needed, but it's a flush.
+}
+
+var newlineBytes = []byte("\n\n") // We never ask for more than 2.
+
+// newlines guarantees there are n newlines at the end of the buffer.
+func (pkg *Package) newlines(n int) {
+	if pkg.buf.Len() == 0 {
+		return
+	}
+	for !bytes.HasSuffix(pkg.buf.Bytes(), newlineBytes[:n]) {
+		pkg.buf.WriteRune('\n')
+	}
+}
+
+// emit prints the node, followed by its documentation
+// indented by one level.
+func (pkg *Package) emit(comment string, node ast.Node) {
+	if node != nil {
+		err := format.Node(&pkg.buf, pkg.fs, node)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if comment != "" {
+			pkg.newlines(1)
+			doc.ToText(&pkg.buf, comment, indent, indent+"\t", indentedWidth)
+			pkg.newlines(2) // Blank line after comment to separate from next item.
+		} else {
+			pkg.newlines(1)
+		}
+	}
+}
+
+// formatNode returns the formatted text of the node.
+func (pkg *Package) formatNode(node ast.Node) string {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, pkg.fs, node); err != nil {
+		log.Fatal(err)
+	}
+	return buf.String()
+}
+
+// oneLineNode returns a one-line summary of the given input node.
+func (pkg *Package) oneLineNode(node ast.Node) string {
+	switch n := node.(type) {
+	case *ast.GenDecl:
+		// Summarize the first spec; "..." signals that there are more.
+		if len(n.Specs) == 0 {
+			return ""
+		}
+		trailer := ""
+		if len(n.Specs) > 1 {
+			trailer = " ..."
+		}
+		return n.Tok.String() + " " + pkg.oneLineNode(n.Specs[0]) + trailer
+
+	case *ast.FuncDecl:
+		// Formatting the function with its body cleared
+		// prints just the signature.
+		decl := *n
+		decl.Doc = nil
+		decl.Body = nil
+		return pkg.oneLine(pkg.formatNode(&decl))
+
+	case *ast.TypeSpec:
+		return n.Name.Name + " " + pkg.oneLineType(n.Type)
+
+	case *ast.ValueSpec:
+		var names []string
+		for _, name := range n.Names {
+			names = append(names, name.Name)
+		}
+		s := strings.Join(names, ", ")
+		if n.Type != nil {
+			s += " " + pkg.oneLineType(n.Type)
+		}
+		if len(n.Values) > 0 {
+			value := pkg.formatNode(n.Values[0])
+			if strings.Contains(value, "\n") || len(n.Values) > 1 {
+				value = "..."
+			}
+			s += " = " + value
+		}
+		return s
+	}
+	return ""
+}
+
+// oneLineType returns a one-line summary of the type expression,
+// eliding the fields of structs and the methods of interfaces.
+func (pkg *Package) oneLineType(typ ast.Expr) string {
+	switch t := typ.(type) {
+	case *ast.StructType:
+		if t.Fields == nil || len(t.Fields.List) == 0 && !t.Incomplete {
+			return "struct{}"
+		}
+		return "struct{ ... }"
+	case *ast.InterfaceType:
+		if t.Methods == nil || len(t.Methods.List) == 0 && !t.Incomplete {
+			return "interface{}"
+		}
+		return "interface{ ... }"
+	}
+	return pkg.oneLine(pkg.formatNode(typ))
+}
+
+// oneLine joins the lines of a formatted node into one.
+func (pkg *Package) oneLine(s string) string {
+	lines := strings.Split(s, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+	s = strings.Join(lines, " ")
+	s = strings.Replace(s, "( ", "(", -1)
+	return strings.Replace(s, ", )", ")", -1)
+}
+
+// packageDoc prints the docs for the package (package doc plus one-liners of the rest).
+func (pkg *Package) packageDoc() {
+	defer pkg.flush()
+	pkg.packageClause(false)
+
+	doc.ToText(&pkg.buf, pkg.doc.Doc, "", indent, indentedWidth)
+	pkg.newlines(1)
+
+	if pkg.name == "main" {
+		// For a command, the package doc is the documentation.
+		return
+	}
+
+	pkg.newlines(2) // Guarantee blank line before the components.
+	pkg.valueSummary(pkg.doc.Consts, "")
+	pkg.valueSummary(pkg.doc.Vars, "")
+	pkg.funcSummary(pkg.doc.Funcs, "")
+	pkg.typeSummary()
+	pkg.bugs()
+}
+
+// packageClause prints the package clause.
+// The argument boolean, if true, suppresses the output if the
+// user's argument is identical to the actual package path or
+// is empty, meaning it's the current directory.
+func (pkg *Package) packageClause(checkUserPath bool) {
+	if checkUserPath {
+		if pkg.userPath == "" || pkg.userPath == pkg.build.ImportPath {
+			return
+		}
+	}
+	importPath := pkg.build.ImportComment
+	if importPath == "" {
+		importPath = pkg.build.ImportPath
+	}
+	if importPath == "" || importPath == "." {
+		pkg.Printf("package %s\n\n", pkg.name)
+		return
+	}
+	pkg.Printf("package %s // import %q\n\n", pkg.name, importPath)
+}
+
+// valueSummary prints a one-line summary for each set of values and constants,
+// each line preceded by prefix.
+func (pkg *Package) valueSummary(values []*doc.Value, prefix string) {
+	for _, value := range values {
+		pkg.Printf("%s%s\n", prefix, pkg.oneLineNode(value.Decl))
+	}
+}
+
+// funcSummary prints a one-line summary for each function,
+// each line preceded by prefix.
+func (pkg *Package) funcSummary(funcs []*doc.Func, prefix string) {
+	for _, fun := range funcs {
+		// Exported functions only. The go/doc package does not include methods here.
+		if isExported(fun.Name) {
+			pkg.Printf("%s%s\n", prefix, pkg.oneLineNode(fun.Decl))
+		}
+	}
+}
+
+// typeSummary prints a one-line summary for each type,
+// followed by its associated constants, variables, and constructors.
+func (pkg *Package) typeSummary() {
+	for _, typ := range pkg.doc.Types {
+		for _, spec := range typ.Decl.Specs {
+			typeSpec := spec.(*ast.TypeSpec) // Must succeed.
+			if isExported(typeSpec.Name.Name) {
+				pkg.Printf("type %s\n", pkg.oneLineNode(typeSpec))
+				// Now print the consts, vars, and constructors.
+				pkg.valueSummary(typ.Consts, indent)
+				pkg.valueSummary(typ.Vars, indent)
+				pkg.funcSummary(typ.Funcs, indent)
+			}
+		}
+	}
+}
+
+// bugs prints the BUGS information for the package.
+func (pkg *Package) bugs() {
+	if pkg.doc.Notes["BUG"] == nil {
+		return
+	}
+	pkg.Printf("\n")
+	for _, note := range pkg.doc.Notes["BUG"] {
+		pkg.Printf("%s: %v\n", "BUG", note.Body)
+	}
+}
+
+// findValues finds the doc.Values that describe the symbol,
+// whether declared at package level or associated with a type.
+func (pkg *Package) findValues(symbol string, docValues []*doc.Value) (values []*doc.Value) {
+	for _, value := range docValues {
+		for _, name := range value.Names {
+			if match(symbol, name) {
+				values = append(values, value)
+				break
+			}
+		}
+	}
+	return
+}
+
+// findFuncs finds the doc.Funcs that describe the symbol,
+// including constructors associated with types.
+func (pkg *Package) findFuncs(symbol string) (funcs []*doc.Func) {
+	for _, fun := range pkg.doc.Funcs {
+		if match(symbol, fun.Name) {
+			funcs = append(funcs, fun)
+		}
+	}
+	for _, typ := range pkg.doc.Types {
+		for _, fun := range typ.Funcs {
+			if match(symbol, fun.Name) {
+				funcs = append(funcs, fun)
+			}
+		}
+	}
+	return
+}
+
+// findTypes finds the doc.Types that describe the symbol.
+func (pkg *Package) findTypes(symbol string) (types []*doc.Type) {
+	for _, typ := range pkg.doc.Types {
+		if match(symbol, typ.Name) {
+			types = append(types, typ)
+		}
+	}
+	return
+}
+
+// allValues returns the package-level constants and variables
+// together with those associated with types.
+func (pkg *Package) allValues() []*doc.Value {
+	values := append([]*doc.Value{}, pkg.doc.Consts...)
+	values = append(values, pkg.doc.Vars...)
+	for _, typ := range pkg.doc.Types {
+		values = append(values, typ.Consts...)
+		values = append(values, typ.Vars...)
+	}
+	return values
+}
+
+// emitFunc prints the declaration of the function or method,
+// without its body, followed by its documentation.
+func (pkg *Package) emitFunc(fun *doc.Func) {
+	decl := *fun.Decl
+	decl.Doc = nil
+	decl.Body = nil
+	pkg.emit(fun.Doc, &decl)
+}
+
+// symbolDoc prints the docs for symbol. There may be multiple matches.
+// If symbol matches a type, output includes its methods factories and associated constants.
+// If there is no top-level symbol, symbolDoc looks for methods that match.
+func (pkg *Package) symbolDoc(symbol string) {
+	defer pkg.flush()
+	found := false
+	// Functions.
+	for _, fun := range pkg.findFuncs(symbol) {
+		if !found {
+			pkg.packageClause(true)
+		}
+		pkg.emitFunc(fun)
+		found = true
+	}
+	// Constants and variables behave the same.
+	for _, value := range pkg.findValues(symbol, pkg.allValues()) {
+		if !found {
+			pkg.packageClause(true)
+		}
+		pkg.emit(value.Doc, value.Decl)
+		found = true
+	}
+	// Types.
+	for _, typ := range pkg.findTypes(symbol) {
+		if !found {
+			pkg.packageClause(true)
+		}
+		pkg.emit(typ.Doc, typ.Decl)
+		// Show associated methods, constants, etc.
+		if len(typ.Consts) > 0 || len(typ.Vars) > 0 || len(typ.Funcs) > 0 || len(typ.Methods) > 0 {
+			pkg.newlines(2)
+		}
+		pkg.valueSummary(typ.Consts, "")
+		pkg.valueSummary(typ.Vars, "")
+		pkg.funcSummary(typ.Funcs, "")
+		pkg.funcSummary(typ.Methods, "")
+		found = true
+	}
+	if !found {
+		// See if there are methods.
+		if !pkg.printMethodDoc("", symbol) {
+			pkg.Fatalf("no symbol %s in package %s", symbol, pkg.build.ImportPath)
+		}
+	}
+}
+
+// printMethodDoc prints the docs for matches of symbol.method.
+// If symbol is empty, it prints all methods that match the name.
+// It reports whether it found any methods.
+func (pkg *Package) printMethodDoc(symbol, method string) bool {
+	defer pkg.flush()
+	types := pkg.doc.Types
+	if symbol != "" {
+		types = pkg.findTypes(symbol)
+	}
+	found := false
+	for _, typ := range types {
+		for _, meth := range typ.Methods {
+			if match(method, meth.Name) {
+				if !found {
+					pkg.packageClause(true)
+				}
+				pkg.emitFunc(meth)
+				found = true
+			}
+		}
+	}
+	return found
+}
+
+// methodDoc prints the docs for matches of symbol.method.
+func (pkg *Package) methodDoc(symbol, method string) {
+	defer pkg.flush()
+	if len(pkg.findTypes(symbol)) == 0 {
+		pkg.Fatalf("symbol %s is not a type in package %s", symbol, pkg.build.ImportPath)
+	}
+	if !pkg.printMethodDoc(symbol, method) {
+		pkg.Fatalf("no method %s.%s in package %s", symbol, method, pkg.build.ImportPath)
+	}
+}
+
+// match reports whether the user's symbol matches the program's.
+// A lower-case character in the user's string matches either case in the program's.
+// The program string must be exported.
+func match(user, program string) bool {
+	if !isExported(program) {
+		return false
+	}
+	if matchCase {
+		return user == program
+	}
+	for _, u := range user {
+		p, w := utf8.DecodeRuneInString(program)
+		program = program[w:]
+		if u == p {
+			continue
+		}
+		if unicode.IsLower(u) && unicode.ToLower(p) == u {
+			continue
+		}
+		return false
+	}
+	return program == ""
+}
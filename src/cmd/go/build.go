@@ -478,6 +478,10 @@ func runInstall(cmd *Command, args []string) {
 	pkgs := pkgsFilter(packagesForBuild(args))
 
 	for _, p := range pkgs {
+		if p.Module != nil && p.Name != "main" {
+			// Packages in modules are kept in the build cache.
+			continue
+		}
 		if p.Target == "" && (!p.Standard || p.ImportPath != "unsafe") {
 			if p.cmdline {
 				errorf("go install: no install location for .go files listed on command line (GOBIN not set)")
@@ -610,6 +614,8 @@ type action struct {
 	objpkg string // the intermediate package .a file created during the action
 	target string // goal of the action: the created package or executable
 
+	outputID cacheID // hash of the target, once known (see actionOutputID)
+
 	// Execution state.
 	pending  int  // number of deps yet to complete
 	priority int  // relative execution priority
@@ -800,8 +806,8 @@ func (b *builder) action(mode buildMode, depMode buildMode, p *Package) *action
 		return a
 	}
 
-	if p.local && p.target == "" {
-		// Imported via local path.  No permanent target.
+	if (p.local || p.Module != nil) && p.target == "" {
+		// Imported via local path or from a module.  No permanent target.
 		mode = modeBuild
 	}
 	work := p.pkgdir
@@ -970,6 +976,10 @@ func (b *builder) do(root *action) {
 	}
 
 	wg.Wait()
+
+	if c := b.cache(); c != nil {
+		c.trim(maxCacheSize)
+	}
 }
 
 // hasString reports whether s appears in the list of strings.
@@ -1048,6 +1058,23 @@ func (b *builder) build(a *action) (err error) {
 		}
 	}
 
+	// Reuse the archive from an earlier build with the same inputs.
+	// With -a, the package is rebuilt but the result is still cached.
+	cache := b.cache()
+	var key cacheID
+	cacheable := false
+	if cache != nil {
+		key, cacheable = b.compileKey(a, pcCFLAGS, pcLDFLAGS)
+	}
+	if cacheable && !buildA {
+		if file, ok := cache.get(key); ok {
+			if err := b.copyFile(a, a.objpkg, file, 0666); err != nil {
+				return err
+			}
+			return b.finishBuild(a, nil)
+		}
+	}
+
 	// Run SWIG on each .swig and .swigcxx file.
 	// Each run will generate two files, a .go file and a .c or .cxx file.
 	// The .go file will use import "C" and is to be processed by cgo.
@@ -1223,8 +1250,44 @@ func (b *builder) build(a *action) (err error) {
 		}
 	}
 
-	// Link if needed.
-	if a.link {
+	if cacheable {
+		if err := cache.putFile(key, a.objpkg); err != nil {
+			fmt.Fprintf(os.Stderr, "go: caching %s: %v\n", a.p.ImportPath, err)
+		}
+	}
+
+	return b.finishBuild(a, objects)
+}
+
+// finishBuild records the output of the build action a,
+// whose package archive is complete, and links it if needed.
+// The objects are those added to the archive, which only
+// the gccgo linker uses.
+func (b *builder) finishBuild(a *action, objects []string) error {
+	if !a.link {
+		id, err := hashFile(a.objpkg)
+		if err != nil {
+			return err
+		}
+		a.outputID = id
+		return nil
+	}
+
+	cache := b.cache()
+	var key cacheID
+	cacheable := false
+	if cache != nil {
+		key, cacheable = b.linkKey(a)
+	}
+	file, hit := "", false
+	if cacheable && !buildA {
+		file, hit = cache.get(key)
+	}
+	if hit {
+		if err := b.copyFile(a, a.target, file, 0777); err != nil {
+			return err
+		}
+	} else {
 		// The compiler only cares about direct imports, but the
 		// linker needs the whole dependency tree.
 		all := actionList(a)
@@ -1232,8 +1295,17 @@ func (b *builder) build(a *action) (err error) {
 		if err := buildToolchain.ld(b, a.p, a.target, all, a.objpkg, objects); err != nil {
 			return err
 		}
+		if cacheable {
+			if err := cache.putFile(key, a.target); err != nil {
+				fmt.Fprintf(os.Stderr, "go: caching %s: %v\n", a.p.ImportPath, err)
+			}
+		}
 	}
-
+	id, err := hashFile(a.target)
+	if err != nil {
+		return err
+	}
+	a.outputID = id
 	return nil
 }
 
@@ -1324,7 +1396,11 @@ func (b *builder) install(a *action) (err error) {
 		defer os.Remove(a1.target)
 	}
 
-	return b.moveOrCopyFile(a, a.target, a1.target, perm)
+	if err := b.moveOrCopyFile(a, a.target, a1.target, perm); err != nil {
+		return err
+	}
+	a.outputID = a1.outputID
+	return nil
 }
 
 // includeArgs returns the -I or -L directory list for access
@@ -1877,6 +1953,13 @@ func (gcToolchain) gc(b *builder, p *Package, archive, obj string, asmhdr bool,
 	if buildContext.InstallSuffix != "" {
 		gcargs = append(gcargs, "-installsuffix", buildContext.InstallSuffix)
 	}
+	// Imports satisfied by vendor directories are written in the
+	// source using the path within the vendor directory.
+	for _, path := range p.Imports {
+		if i, ok := findVendor(path); ok {
+			gcargs = append(gcargs, "-importmap", path[i+len("vendor/"):]+"="+path)
+		}
+	}
 
 	args := []interface{}{buildToolExec, tool(archChar() + "g"), "-o", ofile, "-trimpath", b.work, buildGcflags, gcargs, "-D", p.localPrefix, importArgs}
 	if ofile == archive {
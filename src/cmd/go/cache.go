@@ -0,0 +1,523 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCacheSize is the size to which the cache is trimmed,
+// removing the least recently used entries first.
+const maxCacheSize = 10 << 30
+
+const (
+	// cacheMtimeInterval is how often the modification time of a
+	// cache entry is updated when it is used, for trimming.
+	cacheMtimeInterval = 1 * time.Hour
+
+	// cacheTrimInterval is how often the cache is trimmed.
+	cacheTrimInterval = 1 * time.Hour
+)
+
+// A buildCache is a directory holding build outputs and test results,
+// each stored in a file named after its cacheID. Entries are written
+// to temporary files that are renamed into place, so that concurrent
+// go commands sharing the cache never observe partial entries.
+type buildCache struct {
+	dir string
+}
+
+// A cacheID identifies a cache entry, or the contents of a file.
+type cacheID [sha256.Size]byte
+
+func (id cacheID) String() string {
+	return fmt.Sprintf("%x", id[:])
+}
+
+// cacheDir returns the directory holding the cache,
+// or "" if the cache is disabled.
+func cacheDir() string {
+	dir := os.Getenv("GOCACHE")
+	if dir == "off" {
+		return ""
+	}
+	if dir != "" {
+		return dir
+	}
+	switch runtime.GOOS {
+	case "windows":
+		dir = os.Getenv("LocalAppData")
+	case "darwin":
+		if dir = os.Getenv("HOME"); dir != "" {
+			dir = filepath.Join(dir, "Library", "Caches")
+		}
+	case "plan9":
+		if dir = os.Getenv("home"); dir != "" {
+			dir = filepath.Join(dir, "lib", "cache")
+		}
+	default:
+		if dir = os.Getenv("XDG_CACHE_HOME"); dir == "" {
+			if dir = os.Getenv("HOME"); dir != "" {
+				dir = filepath.Join(dir, ".cache")
+			}
+		}
+	}
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "go-build")
+}
+
+var (
+	cacheOnce    sync.Once
+	defaultCache *buildCache
+)
+
+// openCache returns the cache in cacheDir,
+// or nil if the cache is disabled or cannot be created.
+func openCache() *buildCache {
+	cacheOnce.Do(func() {
+		dir := cacheDir()
+		if dir == "" {
+			return
+		}
+		dir, err := filepath.Abs(dir)
+		if err == nil {
+			err = os.MkdirAll(dir, 0777)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "go: disabling cache (%s): %v\n", dir, err)
+			return
+		}
+		readme := filepath.Join(dir, "README")
+		if _, err := os.Stat(readme); err != nil {
+			ioutil.WriteFile(readme, []byte(cacheREADME), 0666)
+		}
+		defaultCache = &buildCache{dir: dir}
+	})
+	return defaultCache
+}
+
+const cacheREADME = `This directory holds cached build artifacts from the Go build system.
+Run "go clean -cache" if the directory is getting too large.
+See "go help cache" for more information.
+`
+
+// file returns the name of the file holding the entry for id.
+func (c *buildCache) file(id cacheID) string {
+	s := id.String()
+	return filepath.Join(c.dir, s[:2], s)
+}
+
+// get returns the name of the file holding the entry for id,
+// and whether the entry exists.
+func (c *buildCache) get(id cacheID) (string, bool) {
+	file := c.file(id)
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", false
+	}
+	// Record the use of the entry, so that trim keeps it.
+	// Updating the time only once in a while saves most of
+	// the writes for entries used over and over.
+	if now := time.Now(); now.Sub(info.ModTime()) >= cacheMtimeInterval {
+		os.Chtimes(file, now, now)
+	}
+	return file, true
+}
+
+// getBytes returns the contents of the entry for id.
+func (c *buildCache) getBytes(id cacheID) ([]byte, bool) {
+	file, ok := c.get(id)
+	if !ok {
+		return nil, false
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// put stores the contents of r as the entry for id.
+func (c *buildCache) put(id cacheID, r io.Reader) error {
+	file := c.file(id)
+	if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(file), "tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), file)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// putFile stores the contents of the named file as the entry for id.
+func (c *buildCache) putFile(id cacheID, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.put(id, f)
+}
+
+// putBytes stores data as the entry for id.
+func (c *buildCache) putBytes(id cacheID, data []byte) error {
+	return c.put(id, bytes.NewReader(data))
+}
+
+// trim removes the least recently used entries until the cache holds
+// at most limit bytes. It does nothing if the cache was trimmed within
+// the last cacheTrimInterval, so that it is cheap to call after every build.
+func (c *buildCache) trim(limit int64) {
+	now := time.Now()
+	stamp := filepath.Join(c.dir, "trim.txt")
+	if data, err := ioutil.ReadFile(stamp); err == nil {
+		if t, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil && now.Sub(time.Unix(t, 0)) < cacheTrimInterval {
+			return
+		}
+	}
+	ioutil.WriteFile(stamp, []byte(fmt.Sprintf("%d\n", now.Unix())), 0666)
+
+	var entries []os.FileInfo
+	var dirs []string
+	var size int64
+	subdirs, _ := ioutil.ReadDir(c.dir)
+	for _, subdir := range subdirs {
+		if !subdir.IsDir() || len(subdir.Name()) != 2 {
+			continue
+		}
+		dir := filepath.Join(c.dir, subdir.Name())
+		infos, _ := ioutil.ReadDir(dir)
+		for _, info := range infos {
+			if strings.HasPrefix(info.Name(), "tmp-") {
+				// Left behind by a go command that was killed
+				// while writing an entry.
+				if now.Sub(info.ModTime()) > cacheTrimInterval {
+					os.Remove(filepath.Join(dir, info.Name()))
+				}
+				continue
+			}
+			entries = append(entries, info)
+			dirs = append(dirs, dir)
+			size += info.Size()
+		}
+	}
+	if size <= limit {
+		return
+	}
+	index := make([]int, len(entries))
+	for i := range index {
+		index[i] = i
+	}
+	sort.Sort(byModTime{index, entries})
+	for _, i := range index {
+		if size <= limit {
+			break
+		}
+		if os.Remove(filepath.Join(dirs[i], entries[i].Name())) == nil {
+			size -= entries[i].Size()
+		}
+	}
+}
+
+type byModTime struct {
+	index   []int
+	entries []os.FileInfo
+}
+
+func (x byModTime) Len() int      { return len(x.index) }
+func (x byModTime) Swap(i, j int) { x.index[i], x.index[j] = x.index[j], x.index[i] }
+func (x byModTime) Less(i, j int) bool {
+	return x.entries[x.index[i]].ModTime().Before(x.entries[x.index[j]].ModTime())
+}
+
+// clean removes all entries from the cache.
+func (c *buildCache) clean() error {
+	subdirs, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	for _, subdir := range subdirs {
+		if subdir.IsDir() && len(subdir.Name()) == 2 {
+			if err := os.RemoveAll(filepath.Join(c.dir, subdir.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	os.Remove(filepath.Join(c.dir, "trim.txt"))
+	return nil
+}
+
+// A cacheHash accumulates the inputs of an action into the cacheID
+// under which its output is stored.
+type cacheHash struct {
+	h hash.Hash
+}
+
+func newCacheHash(kind string) *cacheHash {
+	k := &cacheHash{sha256.New()}
+	k.add("go cache key v1 %s", kind)
+	k.add("toolchain %s", toolchainID())
+	k.add("goos %s goarch %s", goos, goarch)
+	return k
+}
+
+// add adds a line formatted as with fmt.Sprintf to the key.
+func (k *cacheHash) add(format string, args ...interface{}) {
+	fmt.Fprintf(k.h, format, args...)
+	k.h.Write([]byte{'\n'})
+}
+
+// addFile adds the contents of the named file to the key, labeled by label.
+func (k *cacheHash) addFile(label, name string) error {
+	id, err := hashFile(name)
+	if err != nil {
+		return err
+	}
+	k.add("file %q %s", label, id)
+	return nil
+}
+
+// addDir adds the names and contents of the regular files in dir to the
+// key, including those in subdirectories if recursive is set. A missing
+// directory adds nothing.
+func (k *cacheHash) addDir(dir string, recursive bool) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if path == dir && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			if path != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		return k.addFile(filepath.ToSlash(rel), path)
+	})
+}
+
+func (k *cacheHash) sum() cacheID {
+	var id cacheID
+	copy(id[:], k.h.Sum(nil))
+	return id
+}
+
+var fileHashCache struct {
+	sync.Mutex
+	m map[string]cacheID
+}
+
+// hashFile returns the hash of the contents of the named file.
+// The hash is computed once per file, so hashFile must not be
+// used on files that the go command modifies after hashing them.
+func hashFile(name string) (cacheID, error) {
+	fileHashCache.Lock()
+	id, ok := fileHashCache.m[name]
+	fileHashCache.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return cacheID{}, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return cacheID{}, err
+	}
+	copy(id[:], h.Sum(nil))
+
+	fileHashCache.Lock()
+	if fileHashCache.m == nil {
+		fileHashCache.m = make(map[string]cacheID)
+	}
+	fileHashCache.m[name] = id
+	fileHashCache.Unlock()
+	return id, nil
+}
+
+var (
+	toolchainOnce sync.Once
+	toolchainVal  string
+)
+
+// toolchainID returns a string identifying the toolchain in use: the
+// version of the go command and the size and modification time of each
+// tool it runs. Reinstalling a tool changes its modification time, so
+// the tools need not be hashed on every run.
+func toolchainID() string {
+	toolchainOnce.Do(func() {
+		id := []string{runtime.Version(), goroot}
+		infos, _ := ioutil.ReadDir(toolDir)
+		for _, info := range infos {
+			id = append(id, fmt.Sprintf("%s:%d:%d", info.Name(), info.Size(), info.ModTime().UnixNano()))
+		}
+		if exe, err := os.Stat(os.Args[0]); err == nil {
+			id = append(id, fmt.Sprintf("go:%d:%d", exe.Size(), exe.ModTime().UnixNano()))
+		}
+		toolchainVal = strings.Join(id, " ")
+	})
+	return toolchainVal
+}
+
+// cache returns the cache to use for build outputs,
+// or nil if the build must not use one.
+func (b *builder) cache() *buildCache {
+	if buildN || buildLinkshared || ldBuildmode != "exe" {
+		return nil
+	}
+	if _, ok := buildToolchain.(gcToolchain); !ok {
+		return nil
+	}
+	return openCache()
+}
+
+// trimWork replaces the work directory in s with $WORK,
+// so that keys do not depend on the name of the temporary directory.
+func (b *builder) trimWork(s string) string {
+	return strings.Replace(s, strings.TrimSuffix(b.work, string(filepath.Separator)), "$WORK", -1)
+}
+
+// actionOutputID returns the cacheID of the output of a,
+// which must have completed successfully.
+func actionOutputID(a *action) (cacheID, error) {
+	if a.outputID != (cacheID{}) {
+		return a.outputID, nil
+	}
+	return hashFile(a.target)
+}
+
+// addDeps adds the outputs of the actions in deps to the key.
+// The order of deps does not matter: the action graph is not always
+// constructed in the same order.
+func (k *cacheHash) addDeps(deps []*action) error {
+	var lines []string
+	for _, a1 := range deps {
+		if a1.p == nil || a1.target == "" {
+			continue
+		}
+		id, err := actionOutputID(a1)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, fmt.Sprintf("dep %s %s", a1.p.ImportPath, id))
+	}
+	sort.Strings(lines)
+	for _, line := range lines {
+		k.add("%s", line)
+	}
+	return nil
+}
+
+// compileKey returns the key under which the package archive
+// built by a is stored, or false if the action cannot be cached.
+// The key covers everything the archive is derived from: the flags,
+// the contents of the package's source files, and the archives of
+// the packages it imports.
+func (b *builder) compileKey(a *action, pcCFLAGS, pcLDFLAGS []string) (cacheID, bool) {
+	p := a.p
+	k := newCacheHash("compile")
+	k.add("package %s %s", p.ImportPath, p.Name)
+	k.add("dir %s", b.trimWork(p.Dir))
+	k.add("localPrefix %s", p.localPrefix)
+	k.add("standard %v", p.Standard)
+	k.add("gcflags %q", buildGcflags)
+	k.add("asmflags %q", buildAsmflags)
+	k.add("installsuffix %s", buildContext.InstallSuffix)
+	k.add("toolexec %q", buildToolExec)
+	k.add("GOARM=%s GO386=%s", os.Getenv("GOARM"), os.Getenv("GO386"))
+	if p.coverMode != "" {
+		k.add("covermode %s", p.coverMode)
+		var files []string
+		for file := range p.coverVars {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			k.add("cover %s %s", file, p.coverVars[file].Var)
+		}
+	}
+	if p.usesCgo() || p.usesSwig() {
+		for _, name := range []string{"CC", "CXX", "CGO_CFLAGS", "CGO_CPPFLAGS", "CGO_CXXFLAGS", "CGO_LDFLAGS"} {
+			k.add("%s=%s", name, os.Getenv(name))
+		}
+		k.add("pkg-config %q %q", pcCFLAGS, pcLDFLAGS)
+		if a.cgo != nil && a.cgo.target != "" {
+			if err := k.addDeps([]*action{a.cgo}); err != nil {
+				return cacheID{}, false
+			}
+		}
+	}
+	for _, list := range [][]string{
+		p.GoFiles, p.CgoFiles, p.CFiles, p.CXXFiles, p.MFiles, p.HFiles,
+		p.SFiles, p.SysoFiles, p.SwigFiles, p.SwigCXXFiles,
+	} {
+		for _, file := range list {
+			if err := k.addFile(file, filepath.Join(p.Dir, file)); err != nil {
+				return cacheID{}, false
+			}
+		}
+	}
+	if err := k.addDeps(a.deps); err != nil {
+		return cacheID{}, false
+	}
+	return k.sum(), true
+}
+
+// linkKey returns the key under which the executable linked by a
+// is stored, or false if the action cannot be cached.
+func (b *builder) linkKey(a *action) (cacheID, bool) {
+	p := a.p
+	k := newCacheHash("link")
+	k.add("package %s", p.ImportPath)
+	k.add("buildmode %s", ldBuildmode)
+	k.add("ldflags %q", buildLdflags)
+	k.add("installsuffix %s", buildContext.InstallSuffix)
+	k.add("omitDWARF %v", p.omitDWARF)
+	k.add("toolexec %q", buildToolExec)
+	k.add("CC=%s CXX=%s", os.Getenv("CC"), os.Getenv("CXX"))
+	if err := k.addFile("main", a.objpkg); err != nil {
+		return cacheID{}, false
+	}
+	all := actionList(a)
+	if err := k.addDeps(all[:len(all)-1]); err != nil {
+		return cacheID{}, false
+	}
+	return k.sum(), true
+}
@@ -0,0 +1,167 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testCache(t *testing.T) (*buildCache, func()) {
+	dir, err := ioutil.TempDir("", "gocache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &buildCache{dir: dir}, func() { os.RemoveAll(dir) }
+}
+
+func cacheIDFor(s string) cacheID {
+	k := newCacheHash("test")
+	k.add("%s", s)
+	return k.sum()
+}
+
+func TestCachePutGet(t *testing.T) {
+	c, cleanup := testCache(t)
+	defer cleanup()
+
+	id := cacheIDFor("a")
+	if _, ok := c.get(id); ok {
+		t.Fatalf("get of missing entry succeeded")
+	}
+	if err := c.putBytes(id, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	data, ok := c.getBytes(id)
+	if !ok || string(data) != "hello" {
+		t.Fatalf("getBytes = %q, %v; want %q, true", data, ok, "hello")
+	}
+	if _, ok := c.get(cacheIDFor("b")); ok {
+		t.Fatalf("get of other entry succeeded")
+	}
+
+	// A new entry for the same key replaces the old one.
+	if err := c.putBytes(id, []byte("world")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := c.getBytes(id); string(data) != "world" {
+		t.Fatalf("getBytes after second put = %q, want %q", data, "world")
+	}
+
+	if err := c.clean(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.get(id); ok {
+		t.Fatalf("get after clean succeeded")
+	}
+}
+
+func TestCacheTrim(t *testing.T) {
+	c, cleanup := testCache(t)
+	defer cleanup()
+
+	// Entries a, b, c were last used two, three and four hours ago.
+	now := time.Now()
+	data := bytes.Repeat([]byte("x"), 100)
+	for i, s := range []string{"a", "b", "c"} {
+		id := cacheIDFor(s)
+		if err := c.putBytes(id, data); err != nil {
+			t.Fatal(err)
+		}
+		mtime := now.Add(-time.Duration(i+2) * time.Hour)
+		if err := os.Chtimes(c.file(id), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Using a marks it as recently used, so that trimming to
+	// two entries removes c, the least recently used.
+	if _, ok := c.get(cacheIDFor("a")); !ok {
+		t.Fatal("get a failed")
+	}
+
+	c.trim(250)
+	for s, want := range map[string]bool{"a": true, "b": true, "c": false} {
+		if _, ok := c.get(cacheIDFor(s)); ok != want {
+			t.Errorf("after trim, entry %s present = %v, want %v", s, ok, want)
+		}
+	}
+
+	// A second trim within the trim interval does nothing.
+	c.trim(0)
+	if _, ok := c.get(cacheIDFor("a")); !ok {
+		t.Errorf("second trim removed entries")
+	}
+}
+
+func TestTestInputsID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testinputs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	input := filepath.Join(dir, "input.txt")
+	old := time.Now().Add(-time.Hour)
+	write := func(data string) {
+		if err := ioutil.WriteFile(input, []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(input, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a")
+	defer os.Unsetenv("GOTESTINPUT")
+	os.Setenv("GOTESTINPUT", "x")
+
+	a := &action{p: &Package{Dir: dir}}
+	inputs := func(log string) (cacheID, bool) {
+		return testInputsID(a, []byte("# test log\n"+log))
+	}
+	log := `getenv "GOTESTINPUT"` + "\n" +
+		`open "input.txt"` + "\n" +
+		`stat "missing.txt"` + "\n"
+	id, ok := inputs(log)
+	if !ok {
+		t.Fatal("testInputsID failed")
+	}
+	if id2, _ := inputs(log); id2 != id {
+		t.Errorf("testInputsID changed without a change in the inputs")
+	}
+
+	os.Setenv("GOTESTINPUT", "y")
+	id2, ok := inputs(log)
+	if !ok || id2 == id {
+		t.Errorf("testInputsID did not change with the environment")
+	}
+	id = id2
+
+	write("b")
+	if id2, ok := inputs(log); !ok || id2 == id {
+		t.Errorf("testInputsID did not change with the contents of an opened file")
+	}
+
+	// A file examined with stat is hashed by its modification time,
+	// which must not be so recent that it could change unnoticed.
+	if _, ok := inputs(`stat "input.txt"` + "\n"); !ok {
+		t.Errorf("testInputsID failed for an old file")
+	}
+	if err := os.Chtimes(input, time.Now(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := inputs(`stat "input.txt"` + "\n"); ok {
+		t.Errorf("testInputsID succeeded for a file modified just now")
+	}
+
+	if _, ok := testInputsID(a, []byte(`open "input.txt"`+"\n")); ok {
+		t.Errorf("testInputsID succeeded for a log without a header")
+	}
+	if _, ok := inputs(`exec "input.txt"` + "\n"); ok {
+		t.Errorf("testInputsID succeeded for a log with an unknown operation")
+	}
+}
@@ -13,7 +13,7 @@ import (
 )
 
 var cmdClean = &Command{
-	UsageLine: "clean [-i] [-r] [-n] [-x] [build flags] [packages]",
+	UsageLine: "clean [-i] [-r] [-n] [-x] [-cache] [build flags] [packages]",
 	Short:     "remove object files",
 	Long: `
 Clean removes object files from package source directories.
@@ -52,14 +52,19 @@ dependencies of the packages named by the import paths.
 
 The -x flag causes clean to print remove commands as it executes them.
 
+The -cache flag causes clean to remove the entire build and test cache
+(see 'go help cache'). If no packages are named, go clean -cache
+removes only the cache.
+
 For more about build flags, see 'go help build'.
 
 For more about specifying packages, see 'go help packages'.
 	`,
 }
 
-var cleanI bool // clean -i flag
-var cleanR bool // clean -r flag
+var cleanI bool     // clean -i flag
+var cleanR bool     // clean -r flag
+var cleanCache bool // clean -cache flag
 
 func init() {
 	// break init cycle
@@ -67,6 +72,7 @@ func init() {
 
 	cmdClean.Flag.BoolVar(&cleanI, "i", false, "")
 	cmdClean.Flag.BoolVar(&cleanR, "r", false, "")
+	cmdClean.Flag.BoolVar(&cleanCache, "cache", false, "")
 	// -n and -x are important enough to be
 	// mentioned explicitly in the docs but they
 	// are part of the build flags.
@@ -75,8 +81,28 @@ func init() {
 }
 
 func runClean(cmd *Command, args []string) {
-	for _, pkg := range packagesAndErrors(args) {
-		clean(pkg)
+	if len(args) > 0 || !cleanCache {
+		for _, pkg := range packagesAndErrors(args) {
+			clean(pkg)
+		}
+	}
+
+	if cleanCache {
+		dir := cacheDir()
+		if dir == "" {
+			return
+		}
+		var b builder
+		b.print = fmt.Print
+		if buildN || buildX {
+			b.showcmd("", "rm -r %s", filepath.Join(dir, "??"))
+		}
+		if !buildN {
+			c := &buildCache{dir: dir}
+			if err := c.clean(); err != nil && !os.IsNotExist(err) {
+				errorf("go clean -cache: %v", err)
+			}
+		}
 	}
 }
 
@@ -16,6 +16,7 @@ The commands are:
 
 	build       compile packages and dependencies
 	clean       remove object files
+	doc         show documentation for package or symbol
 	env         print Go environment information
 	fix         run go tool fix on packages
 	fmt         run gofmt on package sources
@@ -23,6 +24,7 @@ The commands are:
 	get         download and install packages and dependencies
 	install     compile and install packages and dependencies
 	list        list packages
+	mod         module maintenance
 	run         compile and run Go program
 	test        test packages
 	tool        run specified go tool
@@ -35,9 +37,11 @@ Additional help topics:
 
 	c           calling between Go and C
 	buildmode   description of build modes
+	cache       build and test caching
 	filetype    file types
 	gopath      GOPATH environment variable
 	importpath  import path syntax
+	modules     modules, module versions, and more
 	packages    description of package lists
 	testflag    description of testing flags
 	testfunc    description of testing functions
@@ -140,7 +144,7 @@ Remove object files
 
 Usage:
 
-	go clean [-i] [-r] [-n] [-x] [build flags] [packages]
+	go clean [-i] [-r] [-n] [-x] [-cache] [build flags] [packages]
 
 Clean removes object files from package source directories.
 The go command builds most objects in a temporary directory,
@@ -178,11 +182,84 @@ dependencies of the packages named by the import paths.
 
 The -x flag causes clean to print remove commands as it executes them.
 
+The -cache flag causes clean to remove the entire build and test cache
+(see 'go help cache'). If no packages are named, go clean -cache
+removes only the cache.
+
 For more about build flags, see 'go help build'.
 
 For more about specifying packages, see 'go help packages'.
 
 
+Show documentation for package or symbol
+
+Usage:
+
+	go doc [-u] [-c] [package|[package.]symbol[.method]]
+
+Doc prints the documentation comments associated with the item identified by its
+arguments (a package, a symbol within a package, or a method of a symbol)
+followed by a one-line summary of each of the first-level items "under"
+that item (package-level declarations for a package, methods for a type,
+etc.).
+
+Given no arguments or one argument, Doc accepts one of these forms:
+
+	go doc
+	go doc <pkg>
+	go doc <sym>[.<method>]
+	go doc [<pkg>.]<sym>[.<method>]
+
+Doc interprets the argument to see what it represents, determined by its syntax
+and which packages and symbols are present in the source directories of GOROOT and
+GOPATH.
+
+The first item in this list that succeeds is the one whose documentation
+is printed. If there is no argument, the package in the current directory is
+chosen. If there is a symbol but no package, the package in the current
+directory is chosen.
+
+A package may be given by its full import path, as in encoding/json,
+or by a trailing sequence of its path elements, as in json. The
+import path is resolved the same way 'go build' and 'go list' resolve
+it. A partial path is matched against the directories of GOROOT and
+then GOPATH, in lexical order, and the first match is used.
+
+When matching symbols, lower-case letters match either case but upper-case
+letters match exactly. This means that there may be multiple matches in a
+package if different symbols have different cases. If this occurs, documentation
+for all matches is printed.
+
+Examples:
+	go doc
+		Show documentation for current package.
+	go doc Foo
+		Show documentation for Foo in the current package.
+		(Foo starts with a capital letter so it cannot match a package path.)
+	go doc json
+		Show documentation for the encoding/json package.
+	go doc encoding/json
+		Same.
+	go doc json.Number
+		Show documentation and method summary for json.Number.
+	go doc json.Number.Int64 (or go doc json.number.int64)
+		Show documentation for json.Number's Int64 method.
+
+Given two arguments, the first must be a full package path (not just a
+suffix), and the second is a symbol or symbol and method:
+
+	go doc <pkg> <sym>[.<method>]
+
+For commands (package main), only the package documentation is shown.
+
+Flags:
+	-c
+		Respect case when matching symbols.
+	-u
+		Show documentation for unexported as well as exported
+		symbols and methods.
+
+
 Print Go environment information
 
 Usage:
@@ -378,6 +455,9 @@ For more about specifying packages, see 'go help packages'.
 For more about how 'go get' finds source code to
 download, see 'go help importpath'.
 
+In module mode, get adds and upgrades the requirements of the main
+module instead of downloading and installing packages. See 'go help modules'.
+
 See also: go build, go install, go clean.
 
 
@@ -400,7 +480,7 @@ List packages
 
 Usage:
 
-	go list [-e] [-f format] [-json] [build flags] [packages]
+	go list [-e] [-f format] [-json] [-m] [build flags] [packages]
 
 List lists the packages named by the import paths, one per line.
 
@@ -426,6 +506,9 @@ syntax of package template.  The default output is equivalent to -f
         Stale         bool   // would 'go install' do anything for this package?
         Root          string // Go root or Go path dir containing this package
 
+        // Module information
+        Module *Module // info about package's containing module, if any
+
         // Source files
         GoFiles        []string // .go source files (excluding CgoFiles, TestGoFiles, XTestGoFiles)
         CgoFiles       []string // .go sources files that import "C"
@@ -494,11 +577,49 @@ printing.  Erroneous packages will have a non-empty ImportPath and
 a non-nil Error field; other information may or may not be missing
 (zeroed).
 
+The -m flag causes list to list modules instead of packages.
+It requires module mode (see 'go help modules'). With no arguments,
+list -m lists the main module; the argument "all" lists the build list,
+and other arguments name modules in the build list. The default output
+shows the module path and version; the -f and -json flags apply
+to this struct:
+
+    type Module struct {
+        Path    string // module path
+        Version string // module version
+        Main    bool   // is this the main module?
+        Dir     string // directory holding files for this module, if any
+        GoMod   string // path to go.mod file describing this module, if any
+    }
+
 For more about build flags, see 'go help build'.
 
 For more about specifying packages, see 'go help packages'.
 
 
+Module maintenance
+
+Usage:
+
+	go mod init [path] | download | verify
+
+Mod performs maintenance operations on the main module,
+the module containing the current directory.
+
+'go mod init' creates a go.mod file in the current directory,
+making it the root of a new module. The optional argument gives
+the module path. If it is omitted, init uses the directory's position
+in GOPATH or the import comment of the package in the directory.
+
+'go mod download' downloads every module in the build list
+into the module cache, recording any checksums missing from go.sum.
+
+'go mod verify' checks that the downloaded copies of the modules
+in the build list have not been modified since they were downloaded.
+
+For more about modules, see 'go help modules'.
+
+
 Compile and run Go program
 
 Usage:
@@ -554,6 +675,18 @@ with source in the current directory, including tests, and runs the tests.
 The package is built in a temporary directory so it does not interfere with the
 non-test installation.
 
+When given a list of packages, go test caches the results of tests that
+pass and reuses them as long as the test and its inputs are unchanged
+(see 'go help cache'). The inputs are the environment variables and
+files the test reads through package os: the values of the variables,
+the contents of the files and directories it opens, and the size and
+modification time of the files it examines with os.Stat. The go command
+cannot see inputs read in other ways: files read by subprocesses or
+through package syscall, network services, and anything read by init
+functions or by TestMain before it calls m.Run. A change to such an
+input does not invalidate a cached result, so tests that depend on them
+should be run with -count=1.
+
 In addition to the build flags, the flags handled by 'go test' itself are:
 
 	-c
@@ -569,6 +702,14 @@ In addition to the build flags, the flags handled by 'go test' itself are:
 	    Install packages that are dependencies of the test.
 	    Do not run the test.
 
+	-json
+	    Convert test output to JSON suitable for automated processing.
+	    Each line of output is a JSON object describing a test event,
+	    such as a test starting, pausing, continuing, passing, failing
+	    or being skipped, a benchmark result, or a line of test output.
+	    The -json flag implies -v.
+	    See 'go doc cmd/test2json' for the encoding details.
+
 	-o file
 		Compile the test binary to the named file.
 		The test still runs (unless -c or -i is specified).
@@ -620,7 +761,7 @@ Usage:
 
 Vet runs the Go vet command on the packages named by the import paths.
 
-For more about vet, see 'godoc golang.org/x/tools/cmd/vet'.
+For more about vet and its flags, see 'go doc cmd/vet'.
 For more about specifying packages, see 'go help packages'.
 
 To run the vet tool with specific options, run 'go tool vet'.
@@ -687,6 +828,44 @@ are:
 		executables. Packages not named main are ignored.
 
 
+Build and test caching
+
+The go command caches build outputs for reuse in future builds.
+The cache is content-addressed: each compiled package and linked
+binary is stored under a hash of everything that went into it,
+namely the Go toolchain, the build flags, the contents of the
+source files, and the outputs of the packages it depends on.
+As a result, a package whose inputs have not changed is not
+compiled again, even if it was never installed.
+
+The go command also caches the results of tests that pass, when
+'go test' is given a list of packages. The test binary records the
+environment variables and files it reads through package os. If the
+test binary, its flags, the GOxxx environment variables, and the
+recorded environment variables and files are unchanged, 'go test'
+prints the recorded output instead of running the test again,
+marking the elapsed time as "(cached)". Inputs read in other ways,
+such as by subprocesses, through package syscall, over the network,
+or before TestMain calls m.Run, are not recorded; see 'go help test'.
+Only runs using the flags
+-cover, -covermode, -coverpkg, -cpu, -failfast, -parallel, -run,
+-shard, -shards, -short, -timeout, and -v are cached; any other flag,
+including those interpreted by the test itself, causes the test to run.
+In particular, the idiomatic way to run tests again regardless of
+the cache is to use -count=1.
+
+The cache is stored in the directory named by the GOCACHE environment
+variable, by default a subdirectory go-build of the standard user cache
+directory for the current operating system ($XDG_CACHE_HOME or
+$HOME/.cache on Unix systems). Setting GOCACHE=off disables the cache.
+When the cache grows beyond 10 GB, the go command removes the entries
+that were least recently used. The 'go clean -cache' command removes
+all cached data.
+
+The cache is not used with -n, with the gccgo toolchain, or with
+build modes other than the default ones.
+
+
 File types
 
 The go command examines the contents of a restricted set of files
@@ -783,6 +962,37 @@ Go searches each directory listed in GOPATH to find source code,
 but new packages are always downloaded into the first directory
 in the list.
 
+Vendor Directories
+
+Code below a directory containing a subdirectory named "vendor" can
+import packages from that vendor directory using import paths that
+omit the prefix up to and including the vendor element.
+
+Here's the example from the previous section, but with the "quux"
+command vendoring a copy of a package from elsewhere:
+
+    /home/user/gocode/
+        src/
+            foo/
+                quux/              (go code in package main)
+                    y.go
+                    vendor/
+                        github.com/x/y/
+                            z.go   (go code in package y)
+
+Code in quux/y.go imports the vendored package as "github.com/x/y",
+the same path by which it would import the original. The import is
+resolved by looking for vendor/github.com/x/y in the directory of
+the importing package, then in each enclosing directory up to the
+src directory of its GOPATH tree, and only then in GOROOT and GOPATH
+as usual. The nearest vendor directory wins. A vendor directory only
+satisfies an import if the package directory in it contains Go files.
+
+Vendored packages are identified by their full path in the tree: the
+package above is listed by 'go list' and built and installed as
+"foo/quux/vendor/github.com/x/y". Code may not import a vendored
+package using that full path.
+
 
 Import path syntax
 
@@ -952,6 +1162,99 @@ direct path to the underlying code hosting site.
 See https://golang.org/s/go14customimport for details.
 
 
+Modules, module versions, and more
+
+A module is a collection of related Go packages that are versioned
+together. Modules record precise dependency requirements, so that
+everyone building the same version of a module builds it from the
+same versions of its dependencies.
+
+The go command runs in module mode when the current directory,
+or one of its parents, contains a file named go.mod. That directory
+is the root of the main module. In module mode, import paths are
+resolved using the build list described below instead of GOPATH,
+and vendor directories are ignored.
+
+The go.mod file
+
+The go.mod file declares the module path, which is the import path
+prefix for the packages in the module, and lists the modules it requires,
+each at a minimum semantic version (see http://semver.org/):
+
+	module example.com/hello
+
+	require (
+		example.com/greet v1.2.0
+		example.com/util v0.3.1
+	)
+
+The 'go mod init' command creates a new go.mod file, and 'go get'
+adds and updates requirements. Comments begin with //.
+
+Minimal version selection
+
+The build list is the set of module versions used in a build.
+It contains the main module and, for every other module required
+by the main module or, recursively, by the go.mod files of its
+requirements, the highest version any of them requires. Because every
+version in the build list is listed in some go.mod file, the build list
+changes only when a go.mod file changes, never because a new version
+of a dependency is published. The 'go list -m all' command prints
+the build list.
+
+An imported package is provided by the module in the build list
+whose path is the longest prefix of the import path. Imports not
+provided by any module must be standard library packages.
+
+Adding and upgrading dependencies
+
+In module mode, 'go get' updates the requirements of the main module
+instead of installing packages:
+
+	go get example.com/greet          # require the latest version
+	go get example.com/greet@v1.1.0   # require a specific version
+	go get -u                         # upgrade all requirements to their latest versions
+
+The latest version of a module is its highest release version or,
+if it has none, its highest prerelease version. The arguments to get
+may also name packages in a module. When -u is given with arguments,
+get also upgrades the modules directly required by the named modules.
+The build list may still select a version higher than the one requested
+if another module requires it. After updating go.mod, get downloads
+the modules in the new build list.
+
+Module proxies
+
+Modules are fetched from the module proxy named by the GOPROXY environment
+variable, a local directory (or file:// URL) that stands in for the
+version control repositories holding the modules. Setting GOPROXY=off
+disallows fetching. The versions of module path are found in the
+directory GOPROXY/path/@v, in any of these forms:
+
+	list          a list of versions, one per line
+	v1.2.0.mod    the go.mod file for version v1.2.0
+	v1.2.0.zip    a zip archive of the module's files for version v1.2.0,
+	              each stored under the prefix path@v1.2.0/
+	v1.2.0/       a directory holding the module's files for version v1.2.0
+
+Downloaded modules are unpacked into the module cache, the directory
+GOPATH/pkg/mod, where each version lives in its own directory,
+path@version, which is never modified. Subsequent builds use the module
+cache and do not consult the proxy, so a build whose modules have all
+been downloaded works offline.
+The 'go mod download' command fills the module cache in advance.
+
+Checksums
+
+The go.sum file next to go.mod records the expected cryptographic
+hash of each module version in the build list and of its go.mod file.
+The go command adds the hash of every newly downloaded module to go.sum
+and refuses to use a module whose content does not match the recorded
+hash. Both go.mod and go.sum should be checked into version control.
+The 'go mod verify' command checks that the module cache has not been
+modified since the modules were downloaded.
+
+
 Description of package lists
 
 Many commands apply to a set of packages:
@@ -1052,6 +1355,13 @@ control the execution of any test:
 	    if -test.blockprofile is set without this flag, all blocking events
 	    are recorded, equivalent to -test.blockprofilerate=1.
 
+	-count n
+	    Run each test and benchmark n times (default 1).
+	    If -cpu is set, run n times for each GOMAXPROCS value.
+	    Examples are always run once.
+	    The results of a run using -count are not cached,
+	    so -count=1 forces tests to run again (see 'go help cache').
+
 	-cover
 	    Enable coverage analysis.
 
@@ -1074,6 +1384,10 @@ control the execution of any test:
 
 	-coverprofile cover.out
 	    Write a coverage profile to the file after all tests have passed.
+	    When testing several packages, the profiles of their tests are
+	    merged into the one file; the counts for a package instrumented
+	    by more than one test, as with -coverpkg, are combined.
+	    Use 'go tool cover' to display the profile.
 	    Sets -cover.
 
 	-cpu 1,2,4
@@ -1085,6 +1399,28 @@ control the execution of any test:
 	    Write a CPU profile to the specified file before exiting.
 	    Writes test binary as -c would.
 
+	-failfast
+	    Do not start new tests after the first test failure.
+	    Tests that are already running, such as parallel tests,
+	    are allowed to finish.
+
+	-fuzz regexp
+	    Run the fuzzing engine on the fuzz target matching the regular
+	    expression, after the tests have passed. Only a single package
+	    may be fuzzed, and the regular expression must match exactly one
+	    of its fuzz targets. The package is instrumented for coverage,
+	    as with -covermode=count, to guide the engine, so -fuzz cannot be
+	    combined with the coverage flags. A failing input is minimized
+	    and written to the testdata/fuzz directory of the package.
+
+	-fuzzminimizetime t
+	    Spend at most t minimizing a failing input found by -fuzz.
+	    The default is 60 seconds (60s).
+
+	-fuzztime t
+	    Stop fuzzing after t, specified as a time.Duration. By default,
+	    fuzzing runs until a failing input is found.
+
 	-memprofile mem.out
 	    Write a memory profile to the file after all tests have passed.
 	    Writes test binary as -c would.
@@ -1098,6 +1434,7 @@ control the execution of any test:
 	-outputdir directory
 	    Place output files from profiling in the specified directory,
 	    by default the directory in which "go test" is running.
+	    A relative directory is interpreted relative to that directory.
 
 	-parallel n
 	    Allow parallel execution of test functions that call t.Parallel.
@@ -1108,6 +1445,19 @@ control the execution of any test:
 	    Run only those tests and examples matching the regular
 	    expression.
 
+	-shard k
+	    Run only the tests in shard k of those selected by -run,
+	    where 0 <= k < n for the n of -shards. The default is 0.
+
+	-shards n
+	    Split the tests selected by -run into n shards, so that
+	    their runs with -shard=0 through -shard=n-1 together run
+	    each test exactly once, for example on n different machines.
+	    The tests, fuzz targets and examples of a package are dealt
+	    out to the shards in turn, in the order in which they appear
+	    in the package; subtests run in the shard of their top-level
+	    test, and benchmarks are not sharded. The default is 1.
+
 	-short
 	    Tell long-running tests to shorten their run time.
 	    It is off by default but set during all.bash so that installing
@@ -1142,14 +1492,24 @@ will compile the test binary and then run it as
 
 The test flags that generate profiles (other than for coverage) also
 leave the test binary in pkg.test for use when analyzing the profiles.
+When testing several packages, each test writes its profiles to files
+of its own, named by prefixing the profile name with the package's
+import path, with slashes replaced by underscores, and the binary is
+left in a file named the same way. For instance,
+
+	go test -cpuprofile=cpu.out encoding/json net/url
+
+writes encoding_json.cpu.out and net_url.cpu.out and leaves the binaries
+in encoding_json.test and net_url.test.
 
 Flags not recognized by 'go test' must be placed after any specified packages.
 
 
 Description of testing functions
 
-The 'go test' command expects to find test, benchmark, and example functions
-in the "*_test.go" files corresponding to the package under test.
+The 'go test' command expects to find test, benchmark, fuzz target, and
+example functions in the "*_test.go" files corresponding to the package
+under test.
 
 A test function is one named TestXXX (where XXX is any alphanumeric string
 not starting with a lower case letter) and should have the signature,
@@ -1160,6 +1520,10 @@ A benchmark function is one named BenchmarkXXX and should have the signature,
 
 	func BenchmarkXXX(b *testing.B) { ... }
 
+A fuzz target is one named FuzzXXX and should have the signature,
+
+	func FuzzXXX(f *testing.F) { ... }
+
 An example function is similar to a test function but, instead of using
 *testing.T to report success or failure, prints output to os.Stdout.
 That output is compared against the function's "Output:" comment, which
@@ -1183,7 +1547,7 @@ Here is an example of an example:
 
 The entire test file is presented as the example when it contains a single
 example function, at least one other function, type, variable, or constant
-declaration, and no test or benchmark functions.
+declaration, and no test, benchmark, or fuzz target functions.
 
 See the documentation of the testing package for more information.
 
@@ -0,0 +1,79 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+var cmdDoc = &Command{
+	Run:         runDoc,
+	UsageLine:   "doc [-u] [-c] [package|[package.]symbol[.method]]",
+	CustomFlags: true,
+	Short:       "show documentation for package or symbol",
+	Long: `
+Doc prints the documentation comments associated with the item identified by its
+arguments (a package, a symbol within a package, or a method of a symbol)
+followed by a one-line summary of each of the first-level items "under"
+that item (package-level declarations for a package, methods for a type,
+etc.).
+
+Given no arguments or one argument, Doc accepts one of these forms:
+
+	go doc
+	go doc <pkg>
+	go doc <sym>[.<method>]
+	go doc [<pkg>.]<sym>[.<method>]
+
+Doc interprets the argument to see what it represents, determined by its syntax
+and which packages and symbols are present in the source directories of GOROOT and
+GOPATH.
+
+The first item in this list that succeeds is the one whose documentation
+is printed. If there is no argument, the package in the current directory is
+chosen. If there is a symbol but no package, the package in the current
+directory is chosen.
+
+A package may be given by its full import path, as in encoding/json,
+or by a trailing sequence of its path elements, as in json. The
+import path is resolved the same way 'go build' and 'go list' resolve
+it. A partial path is matched against the directories of GOROOT and
+then GOPATH, in lexical order, and the first match is used.
+
+When matching symbols, lower-case letters match either case but upper-case
+letters match exactly. This means that there may be multiple matches in a
+package if different symbols have different cases. If this occurs, documentation
+for all matches is printed.
+
+Examples:
+	go doc
+		Show documentation for current package.
+	go doc Foo
+		Show documentation for Foo in the current package.
+		(Foo starts with a capital letter so it cannot match a package path.)
+	go doc json
+		Show documentation for the encoding/json package.
+	go doc encoding/json
+		Same.
+	go doc json.Number
+		Show documentation and method summary for json.Number.
+	go doc json.Number.Int64 (or go doc json.number.int64)
+		Show documentation for json.Number's Int64 method.
+
+Given two arguments, the first must be a full package path (not just a
+suffix), and the second is a symbol or symbol and method:
+
+	go doc <pkg> <sym>[.<method>]
+
+For commands (package main), only the package documentation is shown.
+
+Flags:
+	-c
+		Respect case when matching symbols.
+	-u
+		Show documentation for unexported as well as exported
+		symbols and methods.
+`,
+}
+
+func runDoc(cmd *Command, args []string) {
+	run(buildToolExec, tool("doc"), args)
+}
@@ -53,6 +53,9 @@ For more about specifying packages, see 'go help packages'.
 For more about how 'go get' finds source code to
 download, see 'go help importpath'.
 
+In module mode, get adds and upgrades the requirements of the main
+module instead of downloading and installing packages. See 'go help modules'.
+
 See also: go build, go install, go clean.
 	`,
 }
@@ -69,6 +72,10 @@ func init() {
 }
 
 func runGet(cmd *Command, args []string) {
+	if modEnabled() {
+		runModGet(args)
+		return
+	}
 	if *getF && !*getU {
 		fatalf("go get: cannot use -f flag without -u")
 	}
@@ -321,6 +321,37 @@ Here's an example directory layout:
 Go searches each directory listed in GOPATH to find source code,
 but new packages are always downloaded into the first directory
 in the list.
+
+Vendor Directories
+
+Code below a directory containing a subdirectory named "vendor" can
+import packages from that vendor directory using import paths that
+omit the prefix up to and including the vendor element.
+
+Here's the example from the previous section, but with the "quux"
+command vendoring a copy of a package from elsewhere:
+
+    /home/user/gocode/
+        src/
+            foo/
+                quux/              (go code in package main)
+                    y.go
+                    vendor/
+                        github.com/x/y/
+                            z.go   (go code in package y)
+
+Code in quux/y.go imports the vendored package as "github.com/x/y",
+the same path by which it would import the original. The import is
+resolved by looking for vendor/github.com/x/y in the directory of
+the importing package, then in each enclosing directory up to the
+src directory of its GOPATH tree, and only then in GOROOT and GOPATH
+as usual. The nearest vendor directory wins. A vendor directory only
+satisfies an import if the package directory in it contains Go files.
+
+Vendored packages are identified by their full path in the tree: the
+package above is listed by 'go list' and built and installed as
+"foo/quux/vendor/github.com/x/y". Code may not import a vendored
+package using that full path.
 	`,
 }
 
@@ -403,3 +434,44 @@ are:
 		executables. Packages not named main are ignored.
 `,
 }
+
+var helpCache = &Command{
+	UsageLine: "cache",
+	Short:     "build and test caching",
+	Long: `
+The go command caches build outputs for reuse in future builds.
+The cache is content-addressed: each compiled package and linked
+binary is stored under a hash of everything that went into it,
+namely the Go toolchain, the build flags, the contents of the
+source files, and the outputs of the packages it depends on.
+As a result, a package whose inputs have not changed is not
+compiled again, even if it was never installed.
+
+The go command also caches the results of tests that pass, when
+'go test' is given a list of packages. The test binary records the
+environment variables and files it reads through package os. If the
+test binary, its flags, the GOxxx environment variables, and the
+recorded environment variables and files are unchanged, 'go test'
+prints the recorded output instead of running the test again,
+marking the elapsed time as "(cached)". Inputs read in other ways,
+such as by subprocesses, through package syscall, over the network,
+or before TestMain calls m.Run, are not recorded; see 'go help test'.
+Only runs using the flags
+-cover, -covermode, -coverpkg, -cpu, -failfast, -parallel, -run,
+-shard, -shards, -short, -timeout, and -v are cached; any other flag,
+including those interpreted by the test itself, causes the test to run.
+In particular, the idiomatic way to run tests again regardless of
+the cache is to use -count=1.
+
+The cache is stored in the directory named by the GOCACHE environment
+variable, by default a subdirectory go-build of the standard user cache
+directory for the current operating system ($XDG_CACHE_HOME or
+$HOME/.cache on Unix systems). Setting GOCACHE=off disables the cache.
+When the cache grows beyond 10 GB, the go command removes the entries
+that were least recently used. The 'go clean -cache' command removes
+all cached data.
+
+The cache is not used with -n, with the gccgo toolchain, or with
+build modes other than the default ones.
+	`,
+}
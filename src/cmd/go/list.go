@@ -14,7 +14,7 @@ import (
 )
 
 var cmdList = &Command{
-	UsageLine: "list [-e] [-f format] [-json] [build flags] [packages]",
+	UsageLine: "list [-e] [-f format] [-json] [-m] [build flags] [packages]",
 	Short:     "list packages",
 	Long: `
 List lists the packages named by the import paths, one per line.
@@ -41,6 +41,9 @@ syntax of package template.  The default output is equivalent to -f
         Stale         bool   // would 'go install' do anything for this package?
         Root          string // Go root or Go path dir containing this package
 
+        // Module information
+        Module *Module // info about package's containing module, if any
+
         // Source files
         GoFiles        []string // .go source files (excluding CgoFiles, TestGoFiles, XTestGoFiles)
         CgoFiles       []string // .go sources files that import "C"
@@ -109,6 +112,21 @@ printing.  Erroneous packages will have a non-empty ImportPath and
 a non-nil Error field; other information may or may not be missing
 (zeroed).
 
+The -m flag causes list to list modules instead of packages.
+It requires module mode (see 'go help modules'). With no arguments,
+list -m lists the main module; the argument "all" lists the build list,
+and other arguments name modules in the build list. The default output
+shows the module path and version; the -f and -json flags apply
+to this struct:
+
+    type Module struct {
+        Path    string // module path
+        Version string // module version
+        Main    bool   // is this the main module?
+        Dir     string // directory holding files for this module, if any
+        GoMod   string // path to go.mod file describing this module, if any
+    }
+
 For more about build flags, see 'go help build'.
 
 For more about specifying packages, see 'go help packages'.
@@ -121,8 +139,9 @@ func init() {
 }
 
 var listE = cmdList.Flag.Bool("e", false, "")
-var listFmt = cmdList.Flag.String("f", "{{.ImportPath}}", "")
+var listFmt = cmdList.Flag.String("f", "", "")
 var listJson = cmdList.Flag.Bool("json", false, "")
+var listM = cmdList.Flag.Bool("m", false, "")
 var nl = []byte{'\n'}
 
 func runList(cmd *Command, args []string) {
@@ -130,9 +149,16 @@ func runList(cmd *Command, args []string) {
 	out := newTrackingWriter(os.Stdout)
 	defer out.w.Flush()
 
-	var do func(*Package)
+	if *listFmt == "" {
+		*listFmt = "{{.ImportPath}}"
+		if *listM {
+			*listFmt = "{{.Path}}{{if .Version}} {{.Version}}{{end}}"
+		}
+	}
+
+	var do func(interface{})
 	if *listJson {
-		do = func(p *Package) {
+		do = func(p interface{}) {
 			b, err := json.MarshalIndent(p, "", "\t")
 			if err != nil {
 				out.Flush()
@@ -157,7 +183,7 @@ func runList(cmd *Command, args []string) {
 		if err != nil {
 			fatalf("%s", err)
 		}
-		do = func(p *Package) {
+		do = func(p interface{}) {
 			if err := tmpl.Execute(out, p); err != nil {
 				out.Flush()
 				fatalf("%s", err)
@@ -168,6 +194,13 @@ func runList(cmd *Command, args []string) {
 		}
 	}
 
+	if *listM {
+		for _, m := range listModules(args) {
+			do(m)
+		}
+		return
+	}
+
 	load := packages
 	if *listE {
 		load = packagesAndErrors
@@ -208,3 +241,35 @@ func (t *TrackingWriter) Flush() {
 func (t *TrackingWriter) NeedNL() bool {
 	return t.last != '\n'
 }
+
+// listModules returns the modules named by the 'go list -m' arguments.
+func listModules(args []string) []*Module {
+	if !modEnabled() {
+		fatalf("go list -m: cannot find main module (go.mod); see 'go help modules'")
+	}
+	list := modBuildList()
+	if len(args) == 0 {
+		return []*Module{modInfo(list[0])}
+	}
+	var mods []*Module
+	for _, arg := range args {
+		if arg == "all" {
+			for _, m := range list {
+				mods = append(mods, modInfo(m))
+			}
+			continue
+		}
+		found := false
+		for _, m := range list {
+			if m.Path == arg {
+				mods = append(mods, modInfo(m))
+				found = true
+				break
+			}
+		}
+		if !found {
+			errorf("go list -m: module %s is not in the build list", arg)
+		}
+	}
+	return mods
+}
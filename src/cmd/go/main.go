@@ -77,6 +77,7 @@ func (c *Command) Runnable() bool {
 var commands = []*Command{
 	cmdBuild,
 	cmdClean,
+	cmdDoc,
 	cmdEnv,
 	cmdFix,
 	cmdFmt,
@@ -84,6 +85,7 @@ var commands = []*Command{
 	cmdGet,
 	cmdInstall,
 	cmdList,
+	cmdMod,
 	cmdRun,
 	cmdTest,
 	cmdTool,
@@ -92,9 +94,11 @@ var commands = []*Command{
 
 	helpC,
 	helpBuildmode,
+	helpCache,
 	helpFileType,
 	helpGopath,
 	helpImportPath,
+	helpModules,
 	helpPackages,
 	helpTestflag,
 	helpTestfunc,
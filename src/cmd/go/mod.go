@@ -0,0 +1,208 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"os"
+	"path/filepath"
+)
+
+var cmdMod = &Command{
+	UsageLine: "mod init [path] | download | verify",
+	Short:     "module maintenance",
+	Long: `
+Mod performs maintenance operations on the main module,
+the module containing the current directory.
+
+'go mod init' creates a go.mod file in the current directory,
+making it the root of a new module. The optional argument gives
+the module path. If it is omitted, init uses the directory's position
+in GOPATH or the import comment of the package in the directory.
+
+'go mod download' downloads every module in the build list
+into the module cache, recording any checksums missing from go.sum.
+
+'go mod verify' checks that the downloaded copies of the modules
+in the build list have not been modified since they were downloaded.
+
+For more about modules, see 'go help modules'.
+	`,
+}
+
+func init() {
+	cmdMod.Run = runMod // break init loop
+}
+
+func runMod(cmd *Command, args []string) {
+	if len(args) == 0 {
+		cmd.Usage()
+	}
+	switch args[0] {
+	case "init":
+		if len(args) > 2 {
+			fatalf("go mod init: too many arguments")
+		}
+		path := ""
+		if len(args) == 2 {
+			path = args[1]
+		}
+		modInitModule(path)
+	case "download", "verify":
+		if len(args) > 1 {
+			fatalf("go mod %s: too many arguments", args[0])
+		}
+		if !modEnabled() {
+			fatalf("go mod %s: cannot find main module (go.mod); see 'go help modules'", args[0])
+		}
+		list := modBuildList()[1:]
+		for _, m := range list {
+			var err error
+			if args[0] == "download" {
+				_, err = modDir(m)
+			} else {
+				err = modVerify(m)
+			}
+			if err != nil {
+				errorf("go mod %s: %v", args[0], err)
+			}
+		}
+		if args[0] == "verify" && exitStatus == 0 {
+			fmt.Println("all modules verified")
+		}
+	default:
+		fatalf("go mod: unknown command %q\nRun 'go help mod' for usage.", args[0])
+	}
+}
+
+// modInitModule creates a go.mod file in the current directory
+// declaring the module path.
+func modInitModule(path string) {
+	file := filepath.Join(cwd, "go.mod")
+	if _, err := os.Stat(file); err == nil {
+		fatalf("go mod init: go.mod already exists")
+	}
+	if path == "" {
+		for _, root := range filepath.SplitList(buildContext.GOPATH) {
+			if rel, ok := hasSubdir(filepath.Join(root, "src"), cwd); ok {
+				path = rel
+				break
+			}
+		}
+	}
+	if path == "" {
+		if bp, err := buildContext.ImportDir(cwd, build.ImportComment); err == nil {
+			path = bp.ImportComment
+		}
+	}
+	if path == "" {
+		fatalf("go mod init: cannot determine module path for source directory %s\n\tuse 'go mod init path'", cwd)
+	}
+	if build.IsLocalImport(path) || filepath.IsAbs(path) {
+		fatalf("go mod init: invalid module path %q", path)
+	}
+	fmt.Fprintf(os.Stderr, "go: creating new go.mod: module %s\n", path)
+	f := &modFile{Module: path}
+	if err := writeFileAtomic(file, f.format()); err != nil {
+		fatalf("go mod init: %v", err)
+	}
+}
+
+var helpModules = &Command{
+	UsageLine: "modules",
+	Short:     "modules, module versions, and more",
+	Long: `
+A module is a collection of related Go packages that are versioned
+together. Modules record precise dependency requirements, so that
+everyone building the same version of a module builds it from the
+same versions of its dependencies.
+
+The go command runs in module mode when the current directory,
+or one of its parents, contains a file named go.mod. That directory
+is the root of the main module. In module mode, import paths are
+resolved using the build list described below instead of GOPATH,
+and vendor directories are ignored.
+
+The go.mod file
+
+The go.mod file declares the module path, which is the import path
+prefix for the packages in the module, and lists the modules it requires,
+each at a minimum semantic version (see http://semver.org/):
+
+	module example.com/hello
+
+	require (
+		example.com/greet v1.2.0
+		example.com/util v0.3.1
+	)
+
+The 'go mod init' command creates a new go.mod file, and 'go get'
+adds and updates requirements. Comments begin with //.
+
+Minimal version selection
+
+The build list is the set of module versions used in a build.
+It contains the main module and, for every other module required
+by the main module or, recursively, by the go.mod files of its
+requirements, the highest version any of them requires. Because every
+version in the build list is listed in some go.mod file, the build list
+changes only when a go.mod file changes, never because a new version
+of a dependency is published. The 'go list -m all' command prints
+the build list.
+
+An imported package is provided by the module in the build list
+whose path is the longest prefix of the import path. Imports not
+provided by any module must be standard library packages.
+
+Adding and upgrading dependencies
+
+In module mode, 'go get' updates the requirements of the main module
+instead of installing packages:
+
+	go get example.com/greet          # require the latest version
+	go get example.com/greet@v1.1.0   # require a specific version
+	go get -u                         # upgrade all requirements to their latest versions
+
+The latest version of a module is its highest release version or,
+if it has none, its highest prerelease version. The arguments to get
+may also name packages in a module. When -u is given with arguments,
+get also upgrades the modules directly required by the named modules.
+The build list may still select a version higher than the one requested
+if another module requires it. After updating go.mod, get downloads
+the modules in the new build list.
+
+Module proxies
+
+Modules are fetched from the module proxy named by the GOPROXY environment
+variable, a local directory (or file:// URL) that stands in for the
+version control repositories holding the modules. Setting GOPROXY=off
+disallows fetching. The versions of module path are found in the
+directory GOPROXY/path/@v, in any of these forms:
+
+	list          a list of versions, one per line
+	v1.2.0.mod    the go.mod file for version v1.2.0
+	v1.2.0.zip    a zip archive of the module's files for version v1.2.0,
+	              each stored under the prefix path@v1.2.0/
+	v1.2.0/       a directory holding the module's files for version v1.2.0
+
+Downloaded modules are unpacked into the module cache, the directory
+GOPATH/pkg/mod, where each version lives in its own directory,
+path@version, which is never modified. Subsequent builds use the module
+cache and do not consult the proxy, so a build whose modules have all
+been downloaded works offline.
+The 'go mod download' command fills the module cache in advance.
+
+Checksums
+
+The go.sum file next to go.mod records the expected cryptographic
+hash of each module version in the build list and of its go.mod file.
+The go command adds the hash of every newly downloaded module to go.sum
+and refuses to use a module whose content does not match the recorded
+hash. Both go.mod and go.sum should be checked into version control.
+The 'go mod verify' command checks that the module cache has not been
+modified since the modules were downloaded.
+	`,
+}
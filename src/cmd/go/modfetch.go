@@ -0,0 +1,442 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Modules are fetched from a module proxy: a directory tree, named by
+// $GOPROXY, that serves as a read-only stand-in for the version control
+// repositories holding the modules. See 'go help modules' for its layout.
+//
+// Downloaded modules are kept in the module cache, $GOPATH/pkg/mod.
+// Each module version is unpacked into its own directory, path@version,
+// and never changes once written. The cache/download subdirectory
+// holds each version's go.mod file and the hash of its file tree,
+// so that builds need not consult the proxy again.
+
+// modProxy returns the module proxy directory named by $GOPROXY.
+func modProxy() (string, error) {
+	proxy := os.Getenv("GOPROXY")
+	switch {
+	case proxy == "":
+		return "", fmt.Errorf("GOPROXY is not set")
+	case proxy == "off":
+		return "", fmt.Errorf("module lookup disabled by GOPROXY=off")
+	case strings.HasPrefix(proxy, "file://"):
+		proxy = filepath.FromSlash(strings.TrimPrefix(proxy, "file://"))
+	}
+	if !filepath.IsAbs(proxy) {
+		return "", fmt.Errorf("GOPROXY must be an absolute directory path or file:// URL, not %q", proxy)
+	}
+	return proxy, nil
+}
+
+// modProxyDir returns the @v directory of module path in the proxy.
+func modProxyDir(path string) (string, error) {
+	proxy, err := modProxy()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(proxy, filepath.FromSlash(path), "@v"), nil
+}
+
+// modCacheRoot returns the root of the module cache.
+func modCacheRoot() (string, error) {
+	list := filepath.SplitList(buildContext.GOPATH)
+	if len(list) == 0 || list[0] == "" {
+		return "", fmt.Errorf("GOPATH is not set; it is needed for the module cache")
+	}
+	return filepath.Join(list[0], "pkg", "mod"), nil
+}
+
+// modDownloadDir returns the directory in the module cache
+// holding the downloaded metadata for version v of module path,
+// creating it if necessary.
+func modDownloadDir(path string) (string, error) {
+	root, err := modCacheRoot()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, "cache", "download", filepath.FromSlash(path), "@v")
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// modVersions returns the versions of module path available
+// from the proxy, in increasing order.
+func modVersions(path string) ([]string, error) {
+	dir, err := modProxyDir(path)
+	if err != nil {
+		return nil, err
+	}
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("module %s: not found in module proxy", path)
+		}
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var list []string
+	add := func(v string) {
+		if isSemver(v) && !seen[v] {
+			seen[v] = true
+			list = append(list, v)
+		}
+	}
+	for _, fi := range fis {
+		switch name := fi.Name(); {
+		case fi.IsDir():
+			add(name)
+		case strings.HasSuffix(name, ".zip"):
+			add(strings.TrimSuffix(name, ".zip"))
+		case name == "list":
+			data, err := ioutil.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				return nil, err
+			}
+			for _, v := range strings.Fields(string(data)) {
+				add(v)
+			}
+		}
+	}
+	sort.Sort(bySemver(list))
+	return list, nil
+}
+
+// modLatest returns the latest available version of module path:
+// the highest release version or, if there are none, the highest prerelease.
+func modLatest(path string) (string, error) {
+	list, err := modVersions(path)
+	if err != nil {
+		return "", err
+	}
+	latest := ""
+	for _, v := range list {
+		if p, _ := parseSemver(v); p.prerelease == "" || latest == "" {
+			latest = v
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("module %s: no versions available", path)
+	}
+	return latest, nil
+}
+
+// modGoMod returns the go.mod file of module version m,
+// fetching it from the proxy if it is not yet in the module cache.
+// A module without a go.mod file is treated as having one
+// that declares its path and nothing else.
+func modGoMod(m modVersion) ([]byte, error) {
+	ddir, err := modDownloadDir(m.Path)
+	if err != nil {
+		return nil, err
+	}
+	cached := filepath.Join(ddir, m.Version+".mod")
+	if data, err := ioutil.ReadFile(cached); err == nil {
+		if err := checkModSum(m, true, modHashGoMod(data)); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+
+	pdir, err := modProxyDir(m.Path)
+	if err != nil {
+		return nil, fmt.Errorf("module %s: %v", m, err)
+	}
+	var data []byte
+	if data, err = ioutil.ReadFile(filepath.Join(pdir, m.Version+".mod")); err != nil {
+		if data, err = ioutil.ReadFile(filepath.Join(pdir, m.Version, "go.mod")); err != nil {
+			if data, err = modZipGoMod(m, filepath.Join(pdir, m.Version+".zip")); err != nil {
+				if !os.IsNotExist(err) {
+					return nil, err
+				}
+				if fi, err := os.Stat(filepath.Join(pdir, m.Version)); err != nil || !fi.IsDir() {
+					return nil, fmt.Errorf("module %s: not found in module proxy", m)
+				}
+				data = []byte(fmt.Sprintf("module %s\n", m.Path))
+			}
+		}
+	}
+	if err := checkModSum(m, true, modHashGoMod(data)); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(cached, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// modZipGoMod returns the go.mod file stored in the module zip file.
+// If the zip file holds no go.mod, modZipGoMod returns an empty module file.
+func modZipGoMod(m modVersion, file string) ([]byte, error) {
+	z, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+	for _, f := range z.File {
+		if f.Name == m.String()+"/go.mod" {
+			r, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer r.Close()
+			return ioutil.ReadAll(r)
+		}
+	}
+	return []byte(fmt.Sprintf("module %s\n", m.Path)), nil
+}
+
+// modDir returns the module cache directory holding the files of
+// module version m, downloading the module if necessary.
+func modDir(m modVersion) (string, error) {
+	root, err := modCacheRoot()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, filepath.FromSlash(m.String()))
+	ddir, err := modDownloadDir(m.Path)
+	if err != nil {
+		return "", err
+	}
+	hashFile := filepath.Join(ddir, m.Version+".ziphash")
+	if data, err := ioutil.ReadFile(hashFile); err == nil {
+		if _, err := os.Stat(dir); err == nil {
+			if err := checkModSum(m, false, strings.TrimSpace(string(data))); err != nil {
+				return "", err
+			}
+			return dir, nil
+		}
+	}
+
+	pdir, err := modProxyDir(m.Path)
+	if err != nil {
+		return "", fmt.Errorf("module %s: %v", m, err)
+	}
+	if buildX {
+		fmt.Fprintf(os.Stderr, "# get %s\n", m)
+	}
+	tmp := dir + ".tmp"
+	os.RemoveAll(tmp)
+	if err := os.MkdirAll(tmp, 0777); err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	if err = modUnzip(m, tmp, filepath.Join(pdir, m.Version+".zip")); os.IsNotExist(err) {
+		err = modCopyDir(tmp, filepath.Join(pdir, m.Version))
+		if os.IsNotExist(err) {
+			err = fmt.Errorf("module %s: not found in module proxy", m)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	h, err := modHashDir(tmp, m.String())
+	if err != nil {
+		return "", err
+	}
+	if err := checkModSum(m, false, h); err != nil {
+		return "", err
+	}
+	os.RemoveAll(dir)
+	if err := os.Rename(tmp, dir); err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(hashFile, []byte(h+"\n")); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// modUnzip unpacks the module zip file into dir.
+// Every file in the zip must be under the prefix path@version/.
+func modUnzip(m modVersion, dir, file string) error {
+	z, err := zip.OpenReader(file)
+	if err != nil {
+		return err
+	}
+	defer z.Close()
+	prefix := m.String() + "/"
+	for _, f := range z.File {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		name := strings.TrimPrefix(f.Name, prefix)
+		if name == f.Name || !modValidFileName(name) {
+			return fmt.Errorf("module %s: invalid file name %s in %s", m, f.Name, file)
+		}
+		if err := modUnzipFile(f, filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func modUnzipFile(f *zip.File, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0777); err != nil {
+		return err
+	}
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// modValidFileName reports whether name is a valid slash-separated
+// relative file name within a module.
+func modValidFileName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "/") || strings.Contains(name, `\`) {
+		return false
+	}
+	for _, elem := range strings.Split(name, "/") {
+		if elem == "" || elem == "." || elem == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// modCopyDir copies the regular files in the tree rooted at src into dst.
+func modCopyDir(dst, src string) error {
+	if _, err := os.Stat(src); err != nil {
+		return err
+	}
+	return filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0777); err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, data, 0666)
+	})
+}
+
+// modHashDir returns the hash of the file tree rooted at dir,
+// naming each file as if it were below prefix.
+// The hash is the SHA-256 of a summary listing each file's own SHA-256
+// and name, one per line in name order, the same summary
+// printed by 'sha256sum' for the tree.
+func modHashDir(dir, prefix string) (string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+	h := sha256.New()
+	for _, name := range files {
+		data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%x  %s\n", sha256.Sum256(data), prefix+"/"+name)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// modHashGoMod returns the hash of a go.mod file with the given content,
+// computed as modHashDir would for a tree holding only that file.
+func modHashGoMod(data []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%x  %s\n", sha256.Sum256(data), "go.mod")
+	return "h1:" + base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// modVerify checks that the unpacked files of module version m
+// in the module cache have not been modified since they were downloaded.
+func modVerify(m modVersion) error {
+	root, err := modCacheRoot()
+	if err != nil {
+		return err
+	}
+	ddir, err := modDownloadDir(m.Path)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(filepath.Join(ddir, m.Version+".ziphash"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // not downloaded
+		}
+		return err
+	}
+	dir := filepath.Join(root, filepath.FromSlash(m.String()))
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	h, err := modHashDir(dir, m.String())
+	if err != nil {
+		return err
+	}
+	if want := strings.TrimSpace(string(data)); h != want {
+		return fmt.Errorf("%s: dir has been modified (%s)", m, dir)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to file by way of a temporary file,
+// so that concurrent readers never see a partially written file.
+func writeFileAtomic(file string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(file), "tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, bytes.NewReader(data))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), file)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
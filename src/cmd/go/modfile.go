@@ -0,0 +1,244 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A modVersion identifies a single version of a module.
+// The main module has an empty version.
+type modVersion struct {
+	Path    string
+	Version string
+}
+
+func (m modVersion) String() string {
+	if m.Version == "" {
+		return m.Path
+	}
+	return m.Path + "@" + m.Version
+}
+
+// A modFile is the parsed form of a go.mod file:
+//
+//	module example.com/hello
+//
+//	require (
+//		example.com/greet v1.2.0
+//		example.com/util v0.3.1
+//	)
+//
+type modFile struct {
+	Module  string       // module path
+	Require []modVersion // required module versions
+}
+
+// parseModFile parses the go.mod file data read from file.
+func parseModFile(file string, data []byte) (*modFile, error) {
+	f := new(modFile)
+	inRequire := false
+	for i, line := range strings.Split(string(data), "\n") {
+		if j := strings.Index(line, "//"); j >= 0 {
+			line = line[:j]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		lineErr := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%s:%d: %s", file, i+1, fmt.Sprintf(format, args...))
+		}
+		if inRequire {
+			if len(fields) == 1 && fields[0] == ")" {
+				inRequire = false
+				continue
+			}
+			if err := f.addRequire(fields); err != nil {
+				return nil, lineErr("%v", err)
+			}
+			continue
+		}
+		switch fields[0] {
+		default:
+			return nil, lineErr("unknown directive: %s", fields[0])
+		case "module":
+			if f.Module != "" {
+				return nil, lineErr("repeated module statement")
+			}
+			if len(fields) != 2 {
+				return nil, lineErr("usage: module path")
+			}
+			path, err := modUnquote(fields[1])
+			if err != nil {
+				return nil, lineErr("invalid module path %s", fields[1])
+			}
+			f.Module = path
+		case "require":
+			if len(fields) == 2 && fields[1] == "(" {
+				inRequire = true
+				continue
+			}
+			if err := f.addRequire(fields[1:]); err != nil {
+				return nil, lineErr("%v", err)
+			}
+		}
+	}
+	if inRequire {
+		return nil, fmt.Errorf("%s: unterminated require block", file)
+	}
+	if f.Module == "" {
+		return nil, fmt.Errorf("%s: missing module statement", file)
+	}
+	return f, nil
+}
+
+// addRequire adds the requirement "path version" given by fields.
+func (f *modFile) addRequire(fields []string) error {
+	if len(fields) != 2 {
+		return fmt.Errorf("usage: require module/path v1.2.3")
+	}
+	path, err := modUnquote(fields[0])
+	if err != nil {
+		return fmt.Errorf("invalid module path %s", fields[0])
+	}
+	if !isSemver(fields[1]) {
+		return fmt.Errorf("invalid version %s for module %s", fields[1], path)
+	}
+	for _, r := range f.Require {
+		if r.Path == path {
+			return fmt.Errorf("repeated requirement for module %s", path)
+		}
+	}
+	f.Require = append(f.Require, modVersion{path, fields[1]})
+	return nil
+}
+
+// modUnquote returns s, unquoting it first if it is a quoted string.
+func modUnquote(s string) (string, error) {
+	if strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "`") {
+		return strconv.Unquote(s)
+	}
+	return s, nil
+}
+
+// setRequire records that f requires version v of module path,
+// replacing any existing requirement for that module.
+func (f *modFile) setRequire(path, v string) {
+	for i := range f.Require {
+		if f.Require[i].Path == path {
+			f.Require[i].Version = v
+			return
+		}
+	}
+	f.Require = append(f.Require, modVersion{path, v})
+}
+
+// format returns the go.mod file text for f.
+// Requirements are sorted by module path.
+func (f *modFile) format() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "module %s\n", f.Module)
+	req := append([]modVersion(nil), f.Require...)
+	sort.Sort(byModPath(req))
+	switch len(req) {
+	case 0:
+	case 1:
+		fmt.Fprintf(&buf, "\nrequire %s %s\n", req[0].Path, req[0].Version)
+	default:
+		fmt.Fprintf(&buf, "\nrequire (\n")
+		for _, r := range req {
+			fmt.Fprintf(&buf, "\t%s %s\n", r.Path, r.Version)
+		}
+		fmt.Fprintf(&buf, ")\n")
+	}
+	return buf.Bytes()
+}
+
+type byModPath []modVersion
+
+func (x byModPath) Len() int      { return len(x) }
+func (x byModPath) Swap(i, j int) { x[i], x[j] = x[j], x[i] }
+func (x byModPath) Less(i, j int) bool {
+	if x[i].Path != x[j].Path {
+		return x[i].Path < x[j].Path
+	}
+	return compareSemver(x[i].Version, x[j].Version) < 0
+}
+
+// A goSum holds the contents of a go.sum file, which records the
+// expected cryptographic checksum of each module version used in a build.
+// Each line has the form
+//
+//	path version hash
+//
+// where version is either a module version, for the hash of the
+// module's file tree, or a module version followed by /go.mod,
+// for the hash of just its go.mod file.
+type goSum map[string]string
+
+// goSumKey returns the go.sum key for version v of module path.
+// If goMod is set, the key is for the module's go.mod file alone.
+func goSumKey(m modVersion, goMod bool) string {
+	if goMod {
+		return m.Path + " " + m.Version + "/go.mod"
+	}
+	return m.Path + " " + m.Version
+}
+
+// parseGoSum parses the go.sum file data read from file.
+func parseGoSum(file string, data []byte) (goSum, error) {
+	sum := make(goSum)
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s:%d: malformed line", file, i+1)
+		}
+		sum[fields[0]+" "+fields[1]] = fields[2]
+	}
+	return sum, nil
+}
+
+// format returns the go.sum file text for sum, sorted by module path and version.
+func (sum goSum) format() []byte {
+	keys := make([]string, 0, len(sum))
+	for k := range sum {
+		keys = append(keys, k)
+	}
+	sort.Sort(byGoSumKey(keys))
+	var buf bytes.Buffer
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "%s %s\n", k, sum[k])
+	}
+	return buf.Bytes()
+}
+
+type byGoSumKey []string
+
+func (x byGoSumKey) Len() int      { return len(x) }
+func (x byGoSumKey) Swap(i, j int) { x[i], x[j] = x[j], x[i] }
+func (x byGoSumKey) Less(i, j int) bool {
+	pi, vi := splitGoSumKey(x[i])
+	pj, vj := splitGoSumKey(x[j])
+	if pi != pj {
+		return pi < pj
+	}
+	if c := compareSemver(strings.TrimSuffix(vi, "/go.mod"), strings.TrimSuffix(vj, "/go.mod")); c != 0 {
+		return c < 0
+	}
+	return vi < vj
+}
+
+func splitGoSumKey(k string) (path, version string) {
+	i := strings.Index(k, " ")
+	return k[:i], k[i+1:]
+}
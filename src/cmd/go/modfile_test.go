@@ -0,0 +1,141 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseModFile(t *testing.T) {
+	data := `// The hello module.
+module "example.com/hello"
+
+require example.com/b v1.2.0
+require (
+	example.com/a v0.1.0-pre // comment
+)
+`
+	f, err := parseModFile("go.mod", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &modFile{
+		Module: "example.com/hello",
+		Require: []modVersion{
+			{"example.com/b", "v1.2.0"},
+			{"example.com/a", "v0.1.0-pre"},
+		},
+	}
+	if !reflect.DeepEqual(f, want) {
+		t.Fatalf("parseModFile = %+v, want %+v", f, want)
+	}
+
+	f.setRequire("example.com/b", "v1.3.0")
+	f.setRequire("example.com/c", "v2.0.0")
+	const formatted = `module example.com/hello
+
+require (
+	example.com/a v0.1.0-pre
+	example.com/b v1.3.0
+	example.com/c v2.0.0
+)
+`
+	if out := string(f.format()); out != formatted {
+		t.Fatalf("format:\n%s\nwant:\n%s", out, formatted)
+	}
+	f2, err := parseModFile("go.mod", f.format())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(f2.format()) != formatted {
+		t.Fatalf("format did not round trip:\n%s", f2.format())
+	}
+}
+
+var badModFiles = []struct {
+	data string
+	err  string
+}{
+	{"require x v1.0.0\n", "go.mod: missing module statement"},
+	{"module x\nmodule y\n", "go.mod:2: repeated module statement"},
+	{"module x\nreplace y\n", "go.mod:2: unknown directive: replace"},
+	{"module x\nrequire y 1.0\n", "go.mod:2: invalid version 1.0 for module y"},
+	{"module x\nrequire y\n", "go.mod:2: usage: require module/path v1.2.3"},
+	{"module x\nrequire (\ny v1.0.0\ny v1.1.0\n)\n", "go.mod:4: repeated requirement for module y"},
+	{"module x\nrequire (\n", "go.mod: unterminated require block"},
+}
+
+func TestParseModFileErrors(t *testing.T) {
+	for _, tt := range badModFiles {
+		_, err := parseModFile("go.mod", []byte(tt.data))
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("parseModFile(%q): error %v, want %q", tt.data, err, tt.err)
+		}
+	}
+}
+
+func TestGoSum(t *testing.T) {
+	const data = `example.com/b v1.10.0 h1:b10
+example.com/a v1.0.0/go.mod h1:amod
+example.com/b v1.2.0 h1:b2
+example.com/a v1.0.0 h1:a
+`
+	sum, err := parseGoSum("go.sum", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := sum[goSumKey(modVersion{"example.com/a", "v1.0.0"}, true)]; h != "h1:amod" {
+		t.Errorf("go.mod hash of example.com/a = %q, want %q", h, "h1:amod")
+	}
+	const formatted = `example.com/a v1.0.0 h1:a
+example.com/a v1.0.0/go.mod h1:amod
+example.com/b v1.2.0 h1:b2
+example.com/b v1.10.0 h1:b10
+`
+	if out := string(sum.format()); out != formatted {
+		t.Errorf("format:\n%s\nwant:\n%s", out, formatted)
+	}
+	if _, err := parseGoSum("go.sum", []byte("example.com/a v1.0.0\n")); err == nil || !strings.Contains(err.Error(), "go.sum:1: malformed line") {
+		t.Errorf("parseGoSum of malformed line: error %v", err)
+	}
+}
+
+func TestMVSBuildList(t *testing.T) {
+	// A requires B 1.1 and C 1.0. B 1.1 requires D 1.1;
+	// C 1.0 requires D 1.2, and D 1.2 requires E 1.0.
+	// D 1.3 and E 1.1 exist but are not required by anything,
+	// so they must not be selected.
+	reqs := map[modVersion][]modVersion{
+		modVersion{"a", ""}:       {{"b", "v1.1.0"}, {"c", "v1.0.0"}},
+		modVersion{"b", "v1.1.0"}: {{"d", "v1.1.0"}},
+		modVersion{"c", "v1.0.0"}: {{"d", "v1.2.0"}, {"a", "v1.0.0"}},
+		modVersion{"d", "v1.1.0"}: nil,
+		modVersion{"d", "v1.2.0"}: {{"e", "v1.0.0"}},
+		modVersion{"d", "v1.3.0"}: {{"e", "v1.1.0"}},
+		modVersion{"e", "v1.0.0"}: nil,
+	}
+	list, err := mvsBuildList(modVersion{"a", ""}, func(m modVersion) ([]modVersion, error) {
+		r, ok := reqs[m]
+		if !ok {
+			t.Errorf("unexpected request for requirements of %v", m)
+		}
+		return r, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []modVersion{
+		{"a", ""},
+		{"b", "v1.1.0"},
+		{"c", "v1.0.0"},
+		{"d", "v1.2.0"},
+		{"e", "v1.0.0"},
+	}
+	if !reflect.DeepEqual(list, want) {
+		t.Errorf("mvsBuildList = %v, want %v", list, want)
+	}
+}
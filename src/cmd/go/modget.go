@@ -0,0 +1,108 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	pathpkg "path"
+	"strings"
+)
+
+// runModGet implements 'go get' in module mode: it adds or updates
+// requirements of the main module and downloads the resulting
+// build list. See 'go help modules'.
+func runModGet(args []string) {
+	if *getF || *getFix || *getT {
+		fatalf("go get: -f, -fix, and -t flags are not supported in module mode")
+	}
+	if len(args) == 0 && !*getU {
+		fatalf("go get: no modules named; use -u to upgrade all requirements")
+	}
+
+	if len(args) == 0 {
+		for _, r := range modMain.Require {
+			v, err := modLatest(r.Path)
+			if err != nil {
+				errorf("go get: %v", err)
+				continue
+			}
+			modMain.setRequire(r.Path, maxSemver(r.Version, v))
+		}
+	}
+	for _, arg := range args {
+		m, err := modQuery(arg)
+		if err != nil {
+			errorf("go get %s: %v", arg, err)
+			continue
+		}
+		modMain.setRequire(m.Path, m.Version)
+		if *getU {
+			reqs, err := modReqs(m)
+			if err != nil {
+				errorf("go get %s: %v", arg, err)
+				continue
+			}
+			for _, r := range reqs {
+				v, err := modLatest(r.Path)
+				if err != nil {
+					errorf("go get %s: %v", arg, err)
+					continue
+				}
+				modMain.setRequire(r.Path, maxSemver(r.Version, v))
+			}
+		}
+	}
+	exitIfErrors()
+
+	// Compute and download the new build list
+	// before committing to the new requirements.
+	modList = nil
+	for _, m := range modBuildList()[1:] {
+		if _, err := modDir(m); err != nil {
+			errorf("go get: %v", err)
+		}
+	}
+	exitIfErrors()
+	writeGoMod()
+}
+
+// modQuery resolves the 'go get' argument path[@version] to a module version.
+// The path may name a module or a package in a module; the module
+// is the one with the longest path prefix known to the module proxy.
+// If the version is omitted or is "latest", modQuery uses the latest version.
+func modQuery(arg string) (modVersion, error) {
+	path, vers := arg, "latest"
+	if i := strings.Index(arg, "@"); i >= 0 {
+		path, vers = arg[:i], arg[i+1:]
+	}
+	if vers != "latest" && !isSemver(vers) {
+		return modVersion{}, fmt.Errorf("invalid version %q", vers)
+	}
+	if path == modMain.Module || strings.HasPrefix(path, modMain.Module+"/") {
+		return modVersion{}, fmt.Errorf("cannot get packages in the main module")
+	}
+
+	var firstErr error
+	for prefix := path; prefix != "."; prefix = pathpkg.Dir(prefix) {
+		list, err := modVersions(prefix)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if vers == "latest" {
+			v, err := modLatest(prefix)
+			return modVersion{prefix, v}, err
+		}
+		for _, v := range list {
+			if v == vers {
+				return modVersion{prefix, v}, nil
+			}
+		}
+		return modVersion{}, fmt.Errorf("module %s: no version %s", prefix, vers)
+	}
+	return modVersion{}, firstErr
+}
@@ -0,0 +1,298 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A Module describes a module in the build list.
+// It is the data reported by 'go list -m'.
+type Module struct {
+	Path    string // module path
+	Version string // module version
+	Main    bool   // is this the main module?
+	Dir     string `json:",omitempty"` // directory holding files for this module, if any
+	GoMod   string `json:",omitempty"` // path to go.mod file describing this module, if any
+}
+
+var (
+	modInitDone bool
+	modRoot     string       // directory containing the main module's go.mod, or "" if not in module mode
+	modMain     *modFile     // the main module's go.mod
+	modSum      goSum        // the main module's go.sum
+	modSumDirty bool         // whether modSum has entries not yet written to go.sum
+	modList     []modVersion // build list; modList[0] is the main module
+)
+
+// modInit determines whether the go command is running in module mode:
+// it is if the current directory or one of its parents contains a go.mod
+// file, and that directory is not in $GOROOT/src. See 'go help modules'.
+func modInit() {
+	if modInitDone {
+		return
+	}
+	modInitDone = true
+	if _, ok := hasSubdir(gorootSrc, cwd); ok {
+		return
+	}
+	dir := filepath.Clean(cwd)
+	for {
+		if fi, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil && !fi.IsDir() {
+			modRoot = dir
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return
+		}
+		dir = parent
+	}
+
+	file := filepath.Join(modRoot, "go.mod")
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		fatalf("go: %v", err)
+	}
+	if modMain, err = parseModFile(shortPath(file), data); err != nil {
+		fatalf("go: %v", err)
+	}
+	file = filepath.Join(modRoot, "go.sum")
+	modSum = make(goSum)
+	if data, err := ioutil.ReadFile(file); err == nil {
+		if modSum, err = parseGoSum(shortPath(file), data); err != nil {
+			fatalf("go: %v", err)
+		}
+	} else if !os.IsNotExist(err) {
+		fatalf("go: %v", err)
+	}
+	atexit(writeGoSum)
+}
+
+// modEnabled reports whether the go command is running in module mode.
+func modEnabled() bool {
+	modInit()
+	return modRoot != ""
+}
+
+// checkModSum checks the hash h of module version m, or of its go.mod
+// file if goMod is set, against the go.sum file of the main module.
+// A hash not yet listed in go.sum is added to it.
+func checkModSum(m modVersion, goMod bool, h string) error {
+	if modSum == nil {
+		return nil
+	}
+	key := goSumKey(m, goMod)
+	if want, ok := modSum[key]; ok {
+		if h != want {
+			return fmt.Errorf("verifying %s%s: checksum mismatch\n\tdownloaded: %v\n\tgo.sum:     %v",
+				m, strings.TrimPrefix(key, goSumKey(m, false)), h, want)
+		}
+		return nil
+	}
+	modSum[key] = h
+	modSumDirty = true
+	return nil
+}
+
+// writeGoSum writes any new checksums to the main module's go.sum file.
+func writeGoSum() {
+	if !modSumDirty {
+		return
+	}
+	modSumDirty = false
+	if err := writeFileAtomic(filepath.Join(modRoot, "go.sum"), modSum.format()); err != nil {
+		errorf("go: updating go.sum: %v", err)
+	}
+}
+
+// writeGoMod writes the main module's go.mod file.
+func writeGoMod() {
+	if err := writeFileAtomic(filepath.Join(modRoot, "go.mod"), modMain.format()); err != nil {
+		fatalf("go: updating go.mod: %v", err)
+	}
+}
+
+// modBuildList returns the build list for the main module,
+// computing it if necessary.
+func modBuildList() []modVersion {
+	if modList == nil {
+		list, err := mvsBuildList(modVersion{Path: modMain.Module}, modReqs)
+		if err != nil {
+			fatalf("go: %v", err)
+		}
+		modList = list
+	}
+	return modList
+}
+
+// modReqs returns the requirements listed in the go.mod file of module version m.
+func modReqs(m modVersion) ([]modVersion, error) {
+	if m.Version == "" {
+		return modMain.Require, nil
+	}
+	data, err := modGoMod(m)
+	if err != nil {
+		return nil, err
+	}
+	f, err := parseModFile(m.String()+"/go.mod", data)
+	if err != nil {
+		return nil, err
+	}
+	if f.Module != m.Path {
+		return nil, fmt.Errorf("%s: go.mod has non-matching module path %q", m, f.Module)
+	}
+	return f.Require, nil
+}
+
+// mvsBuildList computes the build list for target by minimal version
+// selection: starting at target, it follows the requirements returned by
+// reqs, and selects for each module the highest version any visited
+// module requires. It returns target followed by the selected version
+// of every other module, sorted by module path.
+func mvsBuildList(target modVersion, reqs func(modVersion) ([]modVersion, error)) ([]modVersion, error) {
+	selected := map[string]string{target.Path: target.Version}
+	seen := map[modVersion]bool{target: true}
+	queue := []modVersion{target}
+	for len(queue) > 0 {
+		m := queue[0]
+		queue = queue[1:]
+		required, err := reqs(m)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range required {
+			if r.Path == target.Path {
+				continue
+			}
+			if v, ok := selected[r.Path]; !ok || compareSemver(r.Version, v) > 0 {
+				selected[r.Path] = r.Version
+			}
+			if !seen[r] {
+				seen[r] = true
+				queue = append(queue, r)
+			}
+		}
+	}
+
+	list := []modVersion{target}
+	for path, v := range selected {
+		if path != target.Path {
+			list = append(list, modVersion{path, v})
+		}
+	}
+	sort.Sort(byModPath(list[1:]))
+	return list, nil
+}
+
+// modInfo returns the Module describing module version m of the build list.
+// The download directory is filled in only if the module has been downloaded.
+func modInfo(m modVersion) *Module {
+	if m.Version == "" {
+		return &Module{
+			Path:  m.Path,
+			Main:  true,
+			Dir:   modRoot,
+			GoMod: filepath.Join(modRoot, "go.mod"),
+		}
+	}
+	info := &Module{Path: m.Path, Version: m.Version}
+	if root, err := modCacheRoot(); err == nil {
+		if dir := filepath.Join(root, filepath.FromSlash(m.String())); isDir(dir) {
+			info.Dir = dir
+		}
+		if file := filepath.Join(root, "cache", "download", filepath.FromSlash(m.Path), "@v", m.Version+".mod"); isFile(file) {
+			info.GoMod = file
+		}
+	}
+	return info
+}
+
+func isDir(name string) bool {
+	fi, err := os.Stat(name)
+	return err == nil && fi.IsDir()
+}
+
+func isFile(name string) bool {
+	fi, err := os.Stat(name)
+	return err == nil && !fi.IsDir()
+}
+
+// modImport locates the package with the given import path in module mode.
+// A path belongs to the module in the build list with the longest
+// matching module path; paths in no module are looked up in the standard
+// library. Vendor directories and GOPATH are not consulted.
+func modImport(path string) (*build.Package, *Module, error) {
+	var mod modVersion
+	for _, m := range modBuildList() {
+		if (path == m.Path || strings.HasPrefix(path, m.Path+"/")) && len(m.Path) > len(mod.Path) {
+			mod = m
+		}
+	}
+	if mod.Path == "" {
+		elem := path
+		if i := strings.Index(path, "/"); i >= 0 {
+			elem = path[:i]
+		}
+		if strings.Contains(elem, ".") {
+			err := fmt.Errorf("cannot find module providing package %s; to add it:\n\tgo get %s", path, path)
+			return &build.Package{ImportPath: path}, nil, err
+		}
+		bp, err := buildContext.Import(path, "", build.ImportComment|build.IgnoreVendor)
+		if err == nil && !bp.Goroot {
+			err = fmt.Errorf("cannot find package %q in the standard library", path)
+		}
+		return bp, nil, err
+	}
+
+	dir := modRoot
+	if mod.Version != "" {
+		var err error
+		if dir, err = modDir(mod); err != nil {
+			return &build.Package{ImportPath: path}, nil, err
+		}
+	}
+	info := modInfo(mod)
+	dir = filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(path[len(mod.Path):], "/")))
+	bp, err := buildContext.ImportDir(dir, build.ImportComment)
+	if _, ok := err.(*build.NoGoError); ok || !isDir(dir) {
+		err = fmt.Errorf("cannot find package %q in module %s", path, mod)
+	}
+	// Packages in modules are found by module path, not by GOPATH position.
+	bp.ImportPath = path
+	bp.Root = ""
+	bp.SrcRoot = ""
+	bp.PkgRoot = ""
+	bp.PkgTargetRoot = ""
+	bp.PkgObj = ""
+	bp.BinDir = gobin
+	if bp.BinDir == "" {
+		if list := filepath.SplitList(buildContext.GOPATH); len(list) > 0 && list[0] != "" {
+			bp.BinDir = filepath.Join(list[0], "bin")
+		}
+	}
+	return bp, info, err
+}
+
+// modLocalImportPath returns the import path of the package in dir
+// when running in module mode and dir is in the main module.
+func modLocalImportPath(dir string) (string, bool) {
+	if !modEnabled() {
+		return "", false
+	}
+	if dir == modRoot {
+		return modMain.Module, true
+	}
+	if rel, ok := hasSubdir(modRoot, dir); ok {
+		return modMain.Module + "/" + rel, true
+	}
+	return "", false
+}
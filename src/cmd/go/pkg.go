@@ -38,6 +38,9 @@ type Package struct {
 	Root          string `json:",omitempty"` // Go root or Go path dir containing this package
 	ConflictDir   string `json:",omitempty"` // Dir is hidden by this other directory
 
+	// Module information
+	Module *Module `json:",omitempty"` // info about package's containing module, if any
+
 	// Source files
 	GoFiles        []string `json:",omitempty"` // .go source files (excluding CgoFiles, TestGoFiles, XTestGoFiles)
 	CgoFiles       []string `json:",omitempty"` // .go sources files that import "C"
@@ -233,24 +236,34 @@ func makeImportValid(r rune) rune {
 // loadImport scans the directory named by path, which must be an import path,
 // but possibly a local import path (an absolute file system path or one beginning
 // with ./ or ../).  A local relative path is interpreted relative to srcDir.
+// The parent is the package whose source contains the import, or nil for
+// a path named on the command line; only imports from a parent are
+// resolved using vendor directories.
 // It returns a *Package describing the package found in that directory.
-func loadImport(path string, srcDir string, stk *importStack, importPos []token.Position) *Package {
+func loadImport(path string, srcDir string, parent *Package, stk *importStack, importPos []token.Position) *Package {
 	stk.push(path)
 	defer stk.pop()
 
 	// Determine canonical identifier for this package.
 	// For a local import the identifier is the pseudo-import path
 	// we create from the full directory to the package.
+	// For an import satisfied by a vendor directory it is the
+	// path of the package in that directory.
 	// Otherwise it is the usual import path.
 	importPath := path
 	isLocal := build.IsLocalImport(path)
 	if isLocal {
 		importPath = dirToImportPath(filepath.Join(srcDir, path))
+	} else if parent != nil && !modEnabled() {
+		importPath = vendoredImportPath(path, srcDir)
 	}
 	if p := packageCache[importPath]; p != nil {
 		if perr := disallowInternal(srcDir, p, stk); perr != p {
 			return perr
 		}
+		if perr := disallowVendor(path, parent, p, stk); perr != p {
+			return perr
+		}
 		return reusePackage(p, stk)
 	}
 
@@ -265,12 +278,23 @@ func loadImport(path string, srcDir string, stk *importStack, importPos []token.
 	//
 	// TODO: After Go 1, decide when to pass build.AllowBinary here.
 	// See issue 3268 for mistakes to avoid.
-	bp, err := buildContext.Import(path, srcDir, build.ImportComment)
+	var bp *build.Package
+	var err error
+	if !isLocal && modEnabled() {
+		bp, p.Module, err = modImport(path)
+	} else {
+		buildPath, buildMode := path, build.ImportComment
+		if !isLocal {
+			// importPath already accounts for vendor directories.
+			buildPath, buildMode = importPath, buildMode|build.IgnoreVendor
+		}
+		bp, err = buildContext.Import(buildPath, srcDir, buildMode)
+	}
 	bp.ImportPath = importPath
 	if gobin != "" {
 		bp.BinDir = gobin
 	}
-	if err == nil && !isLocal && bp.ImportComment != "" && bp.ImportComment != path {
+	if _, vendored := findVendor(importPath); err == nil && !isLocal && !vendored && bp.ImportComment != "" && bp.ImportComment != path {
 		err = fmt.Errorf("code in directory %s expects import %q", bp.Dir, bp.ImportComment)
 	}
 	p.load(stk, bp, err)
@@ -283,10 +307,63 @@ func loadImport(path string, srcDir string, stk *importStack, importPos []token.
 	if perr := disallowInternal(srcDir, p, stk); perr != p {
 		return perr
 	}
+	if perr := disallowVendor(path, parent, p, stk); perr != p {
+		return perr
+	}
 
 	return p
 }
 
+// vendoredImportPath returns the path of the package that an import
+// of path from code in srcDir refers to, taking vendor directories into
+// account (see 'go help gopath'). It returns path if no vendor
+// directory provides the package.
+func vendoredImportPath(path, srcDir string) string {
+	if srcDir == "" {
+		return path
+	}
+	bp, err := buildContext.Import(path, srcDir, build.FindOnly)
+	if err != nil || bp.ImportPath == "" {
+		return path
+	}
+	return bp.ImportPath
+}
+
+// disallowVendor checks that an import of path by parent is allowed to
+// refer to package p. A vendored package must be imported by the
+// path it has inside the vendor directory, not by its full path.
+// If the import is allowed, disallowVendor returns the original package p.
+// If not, it returns a new package containing just an appropriate error.
+func disallowVendor(path string, parent, p *Package, stk *importStack) *Package {
+	// Anything listed on the command line is fine.
+	if parent == nil {
+		return p
+	}
+	if i, ok := findVendor(path); ok {
+		perr := *p
+		perr.Error = &PackageError{
+			ImportStack: stk.copy(),
+			Err:         "must be imported as " + path[i+len("vendor/"):],
+		}
+		perr.Incomplete = true
+		return &perr
+	}
+	return p
+}
+
+// findVendor looks for the last non-terminating "vendor" path element
+// in the given import path. If there isn't one, findVendor returns ok=false.
+// Otherwise, findVendor returns ok=true and the index of the "vendor".
+func findVendor(path string) (index int, ok bool) {
+	switch {
+	case strings.Contains(path, "/vendor/"):
+		return strings.LastIndex(path, "/vendor/") + 1, true
+	case strings.HasPrefix(path, "vendor/"):
+		return 0, true
+	}
+	return 0, false
+}
+
 // reusePackage reuses package p to satisfy the import at the top
 // of the import stack stk.  If this use causes an import loop,
 // reusePackage updates p's error information to record the loop.
@@ -406,7 +483,9 @@ var goTools = map[string]targetDir{
 	"cmd/api":                              toTool,
 	"cmd/asm":                              toTool,
 	"cmd/cgo":                              toTool,
+	"cmd/cover":                            toTool,
 	"cmd/dist":                             toTool,
+	"cmd/doc":                              toTool,
 	"cmd/fix":                              toTool,
 	"cmd/link":                             toTool,
 	"cmd/nm":                               toTool,
@@ -417,11 +496,11 @@ var goTools = map[string]targetDir{
 	"cmd/old9a":                            toTool,
 	"cmd/pack":                             toTool,
 	"cmd/pprof":                            toTool,
+	"cmd/test2json":                        toTool,
 	"cmd/trace":                            toTool,
+	"cmd/vet":                              toTool,
 	"cmd/yacc":                             toTool,
-	"golang.org/x/tools/cmd/cover":         toTool,
 	"golang.org/x/tools/cmd/godoc":         toBin,
-	"golang.org/x/tools/cmd/vet":           toTool,
 	"code.google.com/p/go.tools/cmd/cover": stalePath,
 	"code.google.com/p/go.tools/cmd/godoc": stalePath,
 	"code.google.com/p/go.tools/cmd/vet":   stalePath,
@@ -493,6 +572,10 @@ func (p *Package) load(stk *importStack, bp *build.Package, err error) *Package
 			return p
 		}
 		_, elem := filepath.Split(p.Dir)
+		if p.Module != nil {
+			// Module directories may carry a version suffix.
+			elem = pathpkg.Base(p.ImportPath)
+		}
 		full := buildContext.GOOS + "_" + buildContext.GOARCH + "/" + elem
 		if buildContext.GOOS != toolGOOS || buildContext.GOARCH != toolGOARCH {
 			// Install cross-compiled binaries to subdirectories of bin.
@@ -515,8 +598,9 @@ func (p *Package) load(stk *importStack, bp *build.Package, err error) *Package
 		if p.target != "" && buildContext.GOOS == "windows" {
 			p.target += ".exe"
 		}
-	} else if p.local {
-		// Local import turned into absolute path.
+	} else if p.local || p.Module != nil {
+		// Local import turned into absolute path,
+		// or a package in a module.
 		// No permanent install target.
 		p.target = ""
 	} else {
@@ -603,7 +687,7 @@ func (p *Package) load(stk *importStack, bp *build.Package, err error) *Package
 		if path == "C" {
 			continue
 		}
-		p1 := loadImport(path, p.Dir, stk, p.build.ImportPos[path])
+		p1 := loadImport(path, p.Dir, p, stk, p.build.ImportPos[path])
 		if p1.local {
 			if !p.local && p.Error == nil {
 				p.Error = &PackageError{
@@ -615,8 +699,15 @@ func (p *Package) load(stk *importStack, bp *build.Package, err error) *Package
 					p.Error.Pos = pos[0].String()
 				}
 			}
+		}
+		// Record the path of the package actually imported,
+		// which differs from path for local and vendored imports.
+		if p1.ImportPath != path {
 			path = p1.ImportPath
 			importPaths[i] = path
+			if i < len(p.Imports) {
+				p.Imports[i] = path
+			}
 		}
 		deps[path] = p1
 		imports = append(imports, p1)
@@ -878,14 +969,20 @@ func loadPackage(arg string, stk *importStack) *Package {
 	// This lets you run go test ./ioutil in package io and be
 	// referring to io/ioutil rather than a hypothetical import of
 	// "./ioutil".
+	// In module mode, a directory in the main module
+	// is named by its path in the module.
 	if build.IsLocalImport(arg) {
-		bp, _ := buildContext.ImportDir(filepath.Join(cwd, arg), build.FindOnly)
-		if bp.ImportPath != "" && bp.ImportPath != "." {
-			arg = bp.ImportPath
+		if path, ok := modLocalImportPath(filepath.Join(cwd, arg)); ok {
+			arg = path
+		} else {
+			bp, _ := buildContext.ImportDir(filepath.Join(cwd, arg), build.FindOnly)
+			if bp.ImportPath != "" && bp.ImportPath != "." {
+				arg = bp.ImportPath
+			}
 		}
 	}
 
-	return loadImport(arg, cwd, stk, nil)
+	return loadImport(arg, cwd, nil, stk, nil)
 }
 
 // packages returns the packages named by the
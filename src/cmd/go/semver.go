@@ -0,0 +1,220 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "strings"
+
+// Module versions are semantic versions (http://semver.org/) with a
+// leading v, as in v1.2.3, v1.2.3-pre.1, or v1.2.3+meta.
+// The shorthands v1 and v1.2 used by semver tools are not accepted:
+// a module version always names a single release.
+
+// A semver is a parsed semantic version.
+type semver struct {
+	major, minor, patch string
+	prerelease          string // including leading "-", or ""
+	build               string // including leading "+", or ""
+}
+
+// parseSemver parses v, reporting whether it is a valid module version.
+func parseSemver(v string) (p semver, ok bool) {
+	if !strings.HasPrefix(v, "v") {
+		return
+	}
+	v = v[1:]
+	if p.major, v, ok = semverNum(v); !ok || v == "" || v[0] != '.' {
+		return p, false
+	}
+	if p.minor, v, ok = semverNum(v[1:]); !ok || v == "" || v[0] != '.' {
+		return p, false
+	}
+	if p.patch, v, ok = semverNum(v[1:]); !ok {
+		return p, false
+	}
+	if strings.HasPrefix(v, "-") {
+		i := strings.Index(v, "+")
+		if i < 0 {
+			i = len(v)
+		}
+		p.prerelease, v = v[:i], v[i:]
+		if !semverIdents(p.prerelease[1:], true) {
+			return p, false
+		}
+	}
+	if strings.HasPrefix(v, "+") {
+		p.build, v = v, ""
+		if !semverIdents(p.build[1:], false) {
+			return p, false
+		}
+	}
+	return p, v == ""
+}
+
+// semverNum parses the decimal number at the start of v,
+// which must not have leading zeros.
+func semverNum(v string) (num, rest string, ok bool) {
+	i := 0
+	for i < len(v) && '0' <= v[i] && v[i] <= '9' {
+		i++
+	}
+	if i == 0 || i > 1 && v[0] == '0' {
+		return "", v, false
+	}
+	return v[:i], v[i:], true
+}
+
+// semverIdents reports whether s is a valid dot-separated list of
+// prerelease or build identifiers. Numeric prerelease identifiers
+// must not have leading zeros.
+func semverIdents(s string, prerelease bool) bool {
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return false
+		}
+		numeric := true
+		for i := 0; i < len(id); i++ {
+			c := id[i]
+			switch {
+			case '0' <= c && c <= '9':
+			case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', c == '-':
+				numeric = false
+			default:
+				return false
+			}
+		}
+		if prerelease && numeric && len(id) > 1 && id[0] == '0' {
+			return false
+		}
+	}
+	return true
+}
+
+// isSemver reports whether v is a valid module version.
+func isSemver(v string) bool {
+	_, ok := parseSemver(v)
+	return ok
+}
+
+// semverMajor returns the major version prefix of v, as in "v2",
+// or "" if v is not a valid version.
+func semverMajor(v string) string {
+	p, ok := parseSemver(v)
+	if !ok {
+		return ""
+	}
+	return "v" + p.major
+}
+
+// compareSemver returns -1, 0, or +1 according to whether v < w, v == w,
+// or v > w in semantic version precedence. Build metadata is ignored.
+// An invalid version is considered less than all valid ones,
+// and equal to other invalid ones.
+func compareSemver(v, w string) int {
+	pv, okv := parseSemver(v)
+	pw, okw := parseSemver(w)
+	switch {
+	case !okv && !okw:
+		return 0
+	case !okv:
+		return -1
+	case !okw:
+		return +1
+	}
+	if c := compareNum(pv.major, pw.major); c != 0 {
+		return c
+	}
+	if c := compareNum(pv.minor, pw.minor); c != 0 {
+		return c
+	}
+	if c := compareNum(pv.patch, pw.patch); c != 0 {
+		return c
+	}
+	return comparePrerelease(pv.prerelease, pw.prerelease)
+}
+
+// compareNum compares two decimal numbers without leading zeros.
+func compareNum(x, y string) int {
+	switch {
+	case len(x) != len(y):
+		if len(x) < len(y) {
+			return -1
+		}
+		return +1
+	case x < y:
+		return -1
+	case x > y:
+		return +1
+	}
+	return 0
+}
+
+// comparePrerelease compares prerelease suffixes, including their leading "-".
+// A version without a prerelease suffix sorts after any with one.
+func comparePrerelease(x, y string) int {
+	if x == y {
+		return 0
+	}
+	if x == "" {
+		return +1
+	}
+	if y == "" {
+		return -1
+	}
+	xs := strings.Split(x[1:], ".")
+	ys := strings.Split(y[1:], ".")
+	for i := 0; i < len(xs) && i < len(ys); i++ {
+		if xs[i] == ys[i] {
+			continue
+		}
+		xnum, ynum := isNum(xs[i]), isNum(ys[i])
+		switch {
+		case xnum && ynum:
+			return compareNum(xs[i], ys[i])
+		case xnum:
+			return -1
+		case ynum:
+			return +1
+		case xs[i] < ys[i]:
+			return -1
+		default:
+			return +1
+		}
+	}
+	switch {
+	case len(xs) < len(ys):
+		return -1
+	case len(xs) > len(ys):
+		return +1
+	}
+	return 0
+}
+
+func isNum(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// maxSemver returns the greater of v and w.
+func maxSemver(v, w string) string {
+	if compareSemver(v, w) < 0 {
+		return w
+	}
+	return v
+}
+
+type bySemver []string
+
+func (x bySemver) Len() int      { return len(x) }
+func (x bySemver) Swap(i, j int) { x[i], x[j] = x[j], x[i] }
+func (x bySemver) Less(i, j int) bool {
+	if c := compareSemver(x[i], x[j]); c != 0 {
+		return c < 0
+	}
+	return x[i] < x[j]
+}
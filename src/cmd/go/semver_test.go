@@ -0,0 +1,76 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+var semverTests = []struct {
+	v  string
+	ok bool
+}{
+	{"v1.2.3", true},
+	{"v0.0.0", true},
+	{"v1.2.3-pre", true},
+	{"v1.2.3-pre.1.x-y", true},
+	{"v1.2.3+meta", true},
+	{"v1.2.3-pre+meta.1", true},
+	{"v10.20.30", true},
+	{"1.2.3", false},
+	{"v1", false},
+	{"v1.2", false},
+	{"v1.2.3.4", false},
+	{"v01.2.3", false},
+	{"v1.02.3", false},
+	{"v1.2.3-", false},
+	{"v1.2.3-01", false},
+	{"v1.2.3-a..b", false},
+	{"v1.2.3+", false},
+	{"v1.2.3+a_b", false},
+}
+
+func TestIsSemver(t *testing.T) {
+	for _, tt := range semverTests {
+		if ok := isSemver(tt.v); ok != tt.ok {
+			t.Errorf("isSemver(%q) = %v, want %v", tt.v, ok, tt.ok)
+		}
+	}
+}
+
+// Versions in increasing order, from the semver specification.
+var semverOrder = []string{
+	"bad",
+	"v1.0.0-alpha",
+	"v1.0.0-alpha.1",
+	"v1.0.0-alpha.beta",
+	"v1.0.0-beta",
+	"v1.0.0-beta.2",
+	"v1.0.0-beta.11",
+	"v1.0.0-rc.1",
+	"v1.0.0",
+	"v1.0.1",
+	"v1.2.0",
+	"v1.10.0",
+	"v2.0.0",
+}
+
+func TestCompareSemver(t *testing.T) {
+	for i, v := range semverOrder {
+		for j, w := range semverOrder {
+			want := 0
+			switch {
+			case i < j:
+				want = -1
+			case i > j:
+				want = +1
+			}
+			if c := compareSemver(v, w); c != want {
+				t.Errorf("compareSemver(%q, %q) = %d, want %d", v, w, c, want)
+			}
+		}
+	}
+	if c := compareSemver("v1.0.0+a", "v1.0.0+b"); c != 0 {
+		t.Errorf("compareSemver with different build metadata = %d, want 0", c)
+	}
+}
@@ -605,6 +605,19 @@ if ! grep -q '^hello world' hello.out; then
 fi
 rm -rf $d hello.out
 
+TEST go test -json
+./testgo test -json -run 'TestIndex$' strings >json.out 2>&1 || ok=false
+if ! grep -q '"Action":"run","Package":"strings","Test":"TestIndex"' json.out; then
+	echo "go test -json did not report TestIndex running"
+	cat json.out
+	ok=false
+elif ! grep -q '"Action":"pass","Package":"strings","Elapsed"' json.out; then
+	echo "go test -json did not report strings passing"
+	cat json.out
+	ok=false
+fi
+rm -f json.out
+
 TEST go test -cpuprofile leaves binary behind
 ./testgo test -cpuprofile strings.prof strings || ok=false
 if [ ! -x strings.test ]; then
@@ -613,6 +626,20 @@ if [ ! -x strings.test ]; then
 fi
 rm -f strings.prof strings.test
 
+TEST go test -cpuprofile with multiple packages names files after packages
+./testgo test -cpuprofile cpu.prof -memprofile mem.prof strings unicode/utf8 || ok=false
+for f in strings.cpu.prof strings.mem.prof unicode_utf8.cpu.prof unicode_utf8.mem.prof; do
+	if [ ! -f $f ]; then
+		echo "go test -cpuprofile -memprofile strings unicode/utf8 did not create $f"
+		ok=false
+	fi
+done
+if [ ! -x strings.test -o ! -x unicode_utf8.test ]; then
+	echo "go test -cpuprofile strings unicode/utf8 did not create strings.test and unicode_utf8.test"
+	ok=false
+fi
+rm -f *.cpu.prof *.mem.prof strings.test unicode_utf8.test
+
 TEST go test -cpuprofile -o controls binary location
 ./testgo test -cpuprofile strings.prof -o mystrings.test strings || ok=false
 if [ ! -x mystrings.test ]; then
@@ -814,6 +841,26 @@ TEST coverage runs
 ./testgo test -short -cover strings math regexp >>testdata/cover.txt 2>&1 || ok=false
 checkcoverage
 
+TEST coverage profiles of multiple packages are merged
+if ./testgo test -short -coverpkg=strings -covermode=count -coverprofile=testdata/cover.out strings regexp >testdata/cover.txt 2>&1; then
+	if [ "$(grep -c '^mode:' testdata/cover.out)" != 1 ]; then
+		echo 'merged coverage profile does not have exactly one mode line'
+		ok=false
+	fi
+	if ! grep -q '^strings/strings.go:' testdata/cover.out; then
+		echo 'merged coverage profile does not cover strings'
+		ok=false
+	fi
+	if [ -n "$(cut -d' ' -f1 testdata/cover.out | sort | uniq -d)" ]; then
+		echo 'merged coverage profile repeats blocks'
+		ok=false
+	fi
+	checkcoverage
+else
+	ok=false
+fi
+rm -f testdata/cover.out testdata/cover.txt
+
 # Check that coverage analysis uses set mode.
 TEST coverage uses set mode
 if ./testgo test -short -cover encoding/binary -coverprofile=testdata/cover.out >testdata/cover.txt 2>&1; then
@@ -1058,6 +1105,191 @@ if ! ./testgo test xtestonly >/dev/null; then
 fi
 unset GOPATH
 
+TEST 'go test runs fuzz targets on their corpus'
+export GOPATH=$(pwd)/testdata
+if ! ./testgo test -v fuzztarget > testdata/std.out; then
+	echo "go test fuzztarget failed"
+	ok=false
+elif ! grep -q -- '--- PASS: FuzzDouble/seed#0' testdata/std.out; then
+	echo "go test fuzztarget did not run the seed corpus"
+	cat testdata/std.out
+	ok=false
+elif ! grep -q -- '--- PASS: FuzzDouble/regression' testdata/std.out; then
+	echo "go test fuzztarget did not run testdata/fuzz/FuzzDouble/regression"
+	cat testdata/std.out
+	ok=false
+fi
+if ./testgo test -fuzz FuzzDouble fuzztarget xtestonly 2>testdata/err.out; then
+	echo "go test -fuzz with multiple packages succeeded"
+	ok=false
+elif ! grep -q 'cannot use -fuzz flag with multiple packages' testdata/err.out; then
+	echo "go test -fuzz with multiple packages failed with wrong error"
+	cat testdata/err.out
+	ok=false
+fi
+rm -f testdata/std.out testdata/err.out
+unset GOPATH
+
+TEST 'vendor directories satisfy imports'
+export GOPATH=$(pwd)/testdata
+if ! ./testgo list -f '{{.ImportPath}} {{.Imports}}' vend/hello > testdata/std.out; then
+	echo "go list vend/hello failed"
+	ok=false
+elif ! grep -q '^vend/hello \[fmt vend/vendor/strings\]$' testdata/std.out; then
+	echo "go list vend/hello did not report the vendored import"
+	cat testdata/std.out
+	ok=false
+elif ! ./testgo run testdata/src/vend/hello/hello.go > testdata/std.out; then
+	echo "go run vend/hello failed"
+	ok=false
+elif ! grep -q 'hello, world' testdata/std.out; then
+	echo "go run vend/hello did not use the vendored strings"
+	cat testdata/std.out
+	ok=false
+elif ! ./testgo test vend/hello > testdata/std.out; then
+	echo "go test vend/hello failed"
+	cat testdata/std.out
+	ok=false
+fi
+if ./testgo build vend/bad 2>testdata/err.out; then
+	echo "go build vend/bad succeeded"
+	ok=false
+elif ! grep -q 'must be imported as strings' testdata/err.out; then
+	echo "go build vend/bad failed with wrong error"
+	cat testdata/err.out
+	ok=false
+fi
+rm -f testdata/std.out testdata/err.out
+unset GOPATH
+
+TEST 'go test replays cached results of passing tests'
+d=$(mktemp -d -t testgoXXX)
+export GOCACHE=$d/cache
+if ! ./testgo test errors > testdata/std.out; then
+	echo "go test errors failed"
+	ok=false
+elif grep -q '(cached)' testdata/std.out; then
+	echo "first go test errors used a cached result"
+	cat testdata/std.out
+	ok=false
+elif ! ./testgo test errors > testdata/std.out; then
+	echo "second go test errors failed"
+	ok=false
+elif ! grep -q '^ok.*errors.*(cached)' testdata/std.out; then
+	echo "second go test errors did not use the cached result"
+	cat testdata/std.out
+	ok=false
+elif ! ./testgo test -run=New errors > testdata/std.out; then
+	echo "go test -run=New errors failed"
+	ok=false
+elif grep -q '(cached)' testdata/std.out; then
+	echo "go test -run=New errors used the result of a run with different flags"
+	cat testdata/std.out
+	ok=false
+elif ! ./testgo test -count=1 errors > testdata/std.out; then
+	echo "go test -count=1 errors failed"
+	ok=false
+elif grep -q '(cached)' testdata/std.out; then
+	echo "go test -count=1 errors used a cached result"
+	cat testdata/std.out
+	ok=false
+elif ! ./testgo clean -cache; then
+	echo "go clean -cache failed"
+	ok=false
+elif [ -n "$(ls $GOCACHE | grep -v -e README -e trim.txt)" ]; then
+	echo "go clean -cache did not empty the cache"
+	ls $GOCACHE
+	ok=false
+fi
+unset GOCACHE
+rm -rf $d testdata/std.out
+
+TEST 'go test does not replay a cached result when an environment variable the test read changes'
+d=$(mktemp -d -t testgoXXX)
+export GOCACHE=$d/cache
+export GOPATH=$(pwd)/testdata
+if ! TESTCACHE_VAR=a ./testgo test -v testcache > testdata/std.out; then
+	echo "first go test testcache failed"
+	ok=false
+elif ! TESTCACHE_VAR=a ./testgo test -v testcache > testdata/std.out; then
+	echo "second go test testcache failed"
+	ok=false
+elif ! grep -q '^ok.*testcache.*(cached)' testdata/std.out; then
+	echo "go test testcache with the same environment did not use the cached result"
+	cat testdata/std.out
+	ok=false
+elif ! TESTCACHE_VAR=b ./testgo test -v testcache > testdata/std.out; then
+	echo "third go test testcache failed"
+	ok=false
+elif grep -q '(cached)' testdata/std.out || ! grep -q 'TESTCACHE_VAR=b' testdata/std.out; then
+	echo "go test testcache with a changed environment used the cached result"
+	cat testdata/std.out
+	ok=false
+fi
+unset GOCACHE GOPATH
+rm -rf $d testdata/std.out
+
+TEST 'go get and go build use module requirements'
+d=$(mktemp -d -t testgoXXX)
+testgo=$(pwd)/testgo
+mkdir $d/hello
+cp testdata/mod/hello.go $d/hello
+export GOPATH=$d/gopath GOCACHE=$d/cache GOPROXY=$(pwd)/testdata/modproxy
+if ! (cd $d/hello && $testgo mod init example.com/hello 2>/dev/null); then
+	echo "go mod init failed"
+	ok=false
+elif (cd $d/hello && $testgo build 2>$d/err.out); then
+	echo "go build succeeded without a requirement for example.com/lib"
+	ok=false
+elif ! grep -q 'cannot find module providing package example.com/lib' $d/err.out; then
+	echo "go build failed with wrong error"
+	cat $d/err.out
+	ok=false
+elif ! (cd $d/hello && $testgo get example.com/lib@v1.0.0 && $testgo build -o hello.exe && ./hello.exe > $d/std.out); then
+	echo "go get example.com/lib@v1.0.0 and build failed"
+	ok=false
+elif ! grep -q '^lib v1.0.0$' $d/std.out; then
+	echo "go build did not use example.com/lib v1.0.0"
+	cat $d/std.out
+	ok=false
+elif ! (cd $d/hello && $testgo get example.com/lib && $testgo list -m all > $d/std.out); then
+	echo "go get example.com/lib and go list -m all failed"
+	ok=false
+elif [ "$(cat $d/std.out)" != "$(printf 'example.com/hello\nexample.com/dep v1.2.0\nexample.com/lib v1.1.0')" ]; then
+	echo "go list -m all printed the wrong build list"
+	cat $d/std.out
+	ok=false
+elif ! grep -q '^example.com/dep v1.2.0 h1:' $d/hello/go.sum; then
+	echo "go get did not record the checksum of example.com/dep in go.sum"
+	cat $d/hello/go.sum
+	ok=false
+elif ! (cd $d/hello && GOPROXY=off $testgo run hello.go > $d/std.out); then
+	echo "go run with GOPROXY=off failed"
+	ok=false
+elif ! grep -q '^lib v1.1.0, dep v1.2.0$' $d/std.out; then
+	echo "go run did not use the upgraded build list"
+	cat $d/std.out
+	ok=false
+elif ! (cd $d/hello && $testgo mod verify > /dev/null); then
+	echo "go mod verify failed"
+	ok=false
+fi
+if [ -f $d/hello/go.sum ]; then
+	sed 's/^\(example.com\/lib v1.1.0 h1:\).*/\1AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=/' $d/hello/go.sum > $d/go.sum
+	mv $d/go.sum $d/hello/go.sum
+	rm -rf $d/gopath/pkg/mod
+	if (cd $d/hello && $testgo build 2>$d/err.out); then
+		echo "go build succeeded with a bad checksum in go.sum"
+		ok=false
+	elif ! grep -q 'checksum mismatch' $d/err.out; then
+		echo "go build with bad checksum failed with wrong error"
+		cat $d/err.out
+		ok=false
+	fi
+fi
+unset GOPATH GOCACHE GOPROXY
+rm -rf $d
+
 TEST 'go test builds an xtest containing only non-runnable examples'
 if ! ./testgo test -v ./testdata/norunexample > testdata/std.out; then
 	echo "go test ./testdata/norunexample failed"
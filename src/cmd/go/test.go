@@ -6,6 +6,8 @@ package main
 
 import (
 	"bytes"
+	"cmd/internal/test2json"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"go/ast"
@@ -13,6 +15,8 @@ import (
 	"go/doc"
 	"go/parser"
 	"go/token"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
@@ -21,7 +25,9 @@ import (
 	"regexp"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 	"unicode"
@@ -64,6 +70,18 @@ with source in the current directory, including tests, and runs the tests.
 The package is built in a temporary directory so it does not interfere with the
 non-test installation.
 
+When given a list of packages, go test caches the results of tests that
+pass and reuses them as long as the test and its inputs are unchanged
+(see 'go help cache'). The inputs are the environment variables and
+files the test reads through package os: the values of the variables,
+the contents of the files and directories it opens, and the size and
+modification time of the files it examines with os.Stat. The go command
+cannot see inputs read in other ways: files read by subprocesses or
+through package syscall, network services, and anything read by init
+functions or by TestMain before it calls m.Run. A change to such an
+input does not invalidate a cached result, so tests that depend on them
+should be run with -count=1.
+
 In addition to the build flags, the flags handled by 'go test' itself are:
 
 	-c
@@ -79,6 +97,14 @@ In addition to the build flags, the flags handled by 'go test' itself are:
 	    Install packages that are dependencies of the test.
 	    Do not run the test.
 
+	-json
+	    Convert test output to JSON suitable for automated processing.
+	    Each line of output is a JSON object describing a test event,
+	    such as a test starting, pausing, continuing, passing, failing
+	    or being skipped, a benchmark result, or a line of test output.
+	    The -json flag implies -v.
+	    See 'go doc cmd/test2json' for the encoding details.
+
 	-o file
 		Compile the test binary to the named file.
 		The test still runs (unless -c or -i is specified).
@@ -141,6 +167,13 @@ control the execution of any test:
 	    if -test.blockprofile is set without this flag, all blocking events
 	    are recorded, equivalent to -test.blockprofilerate=1.
 
+	-count n
+	    Run each test and benchmark n times (default 1).
+	    If -cpu is set, run n times for each GOMAXPROCS value.
+	    Examples are always run once.
+	    The results of a run using -count are not cached,
+	    so -count=1 forces tests to run again (see 'go help cache').
+
 	-cover
 	    Enable coverage analysis.
 
@@ -163,6 +196,10 @@ control the execution of any test:
 
 	-coverprofile cover.out
 	    Write a coverage profile to the file after all tests have passed.
+	    When testing several packages, the profiles of their tests are
+	    merged into the one file; the counts for a package instrumented
+	    by more than one test, as with -coverpkg, are combined.
+	    Use 'go tool cover' to display the profile.
 	    Sets -cover.
 
 	-cpu 1,2,4
@@ -174,6 +211,28 @@ control the execution of any test:
 	    Write a CPU profile to the specified file before exiting.
 	    Writes test binary as -c would.
 
+	-failfast
+	    Do not start new tests after the first test failure.
+	    Tests that are already running, such as parallel tests,
+	    are allowed to finish.
+
+	-fuzz regexp
+	    Run the fuzzing engine on the fuzz target matching the regular
+	    expression, after the tests have passed. Only a single package
+	    may be fuzzed, and the regular expression must match exactly one
+	    of its fuzz targets. The package is instrumented for coverage,
+	    as with -covermode=count, to guide the engine, so -fuzz cannot be
+	    combined with the coverage flags. A failing input is minimized
+	    and written to the testdata/fuzz directory of the package.
+
+	-fuzzminimizetime t
+	    Spend at most t minimizing a failing input found by -fuzz.
+	    The default is 60 seconds (60s).
+
+	-fuzztime t
+	    Stop fuzzing after t, specified as a time.Duration. By default,
+	    fuzzing runs until a failing input is found.
+
 	-memprofile mem.out
 	    Write a memory profile to the file after all tests have passed.
 	    Writes test binary as -c would.
@@ -187,6 +246,7 @@ control the execution of any test:
 	-outputdir directory
 	    Place output files from profiling in the specified directory,
 	    by default the directory in which "go test" is running.
+	    A relative directory is interpreted relative to that directory.
 
 	-parallel n
 	    Allow parallel execution of test functions that call t.Parallel.
@@ -197,6 +257,19 @@ control the execution of any test:
 	    Run only those tests and examples matching the regular
 	    expression.
 
+	-shard k
+	    Run only the tests in shard k of those selected by -run,
+	    where 0 <= k < n for the n of -shards. The default is 0.
+
+	-shards n
+	    Split the tests selected by -run into n shards, so that
+	    their runs with -shard=0 through -shard=n-1 together run
+	    each test exactly once, for example on n different machines.
+	    The tests, fuzz targets and examples of a package are dealt
+	    out to the shards in turn, in the order in which they appear
+	    in the package; subtests run in the shard of their top-level
+	    test, and benchmarks are not sharded. The default is 1.
+
 	-short
 	    Tell long-running tests to shorten their run time.
 	    It is off by default but set during all.bash so that installing
@@ -231,6 +304,15 @@ will compile the test binary and then run it as
 
 The test flags that generate profiles (other than for coverage) also
 leave the test binary in pkg.test for use when analyzing the profiles.
+When testing several packages, each test writes its profiles to files
+of its own, named by prefixing the profile name with the package's
+import path, with slashes replaced by underscores, and the binary is
+left in a file named the same way. For instance,
+
+	go test -cpuprofile=cpu.out encoding/json net/url
+
+writes encoding_json.cpu.out and net_url.cpu.out and leaves the binaries
+in encoding_json.test and net_url.test.
 
 Flags not recognized by 'go test' must be placed after any specified packages.
 `,
@@ -240,8 +322,9 @@ var helpTestfunc = &Command{
 	UsageLine: "testfunc",
 	Short:     "description of testing functions",
 	Long: `
-The 'go test' command expects to find test, benchmark, and example functions
-in the "*_test.go" files corresponding to the package under test.
+The 'go test' command expects to find test, benchmark, fuzz target, and
+example functions in the "*_test.go" files corresponding to the package
+under test.
 
 A test function is one named TestXXX (where XXX is any alphanumeric string
 not starting with a lower case letter) and should have the signature,
@@ -252,6 +335,10 @@ A benchmark function is one named BenchmarkXXX and should have the signature,
 
 	func BenchmarkXXX(b *testing.B) { ... }
 
+A fuzz target is one named FuzzXXX and should have the signature,
+
+	func FuzzXXX(f *testing.F) { ... }
+
 An example function is similar to a test function but, instead of using
 *testing.T to report success or failure, prints output to os.Stdout.
 That output is compared against the function's "Output:" comment, which
@@ -275,7 +362,7 @@ Here is an example of an example:
 
 The entire test file is presented as the example when it contains a single
 example function, at least one other function, type, variable, or constant
-declaration, and no test or benchmark functions.
+declaration, and no test, benchmark, or fuzz target functions.
 
 See the documentation of the testing package for more information.
 `,
@@ -287,15 +374,21 @@ var (
 	testCoverMode    string     // -covermode flag
 	testCoverPaths   []string   // -coverpkg flag
 	testCoverPkgs    []*Package // -coverpkg flag
+	testCoverProfile string     // -coverprofile flag
 	testO            string     // -o flag
+	testOutputDir    string     // -outputdir flag
 	testProfile      bool       // some profiling flag
+	testProfileEach  bool       // profiles are written for each package separately
 	testNeedBinary   bool       // profile needs to keep binary around
 	testV            bool       // -v flag
+	testJSON         bool       // -json flag
+	testFuzz         string     // -fuzz flag
 	testTimeout      string     // -timeout flag
 	testArgs         []string
 	testBench        bool
 	testStreamOutput bool // show output as it is generated
 	testShowPass     bool // show passing output
+	testCacheOK      bool // results of passing tests may be cached
 
 	testKillTimeout = 10 * time.Minute
 )
@@ -326,10 +419,15 @@ func runTest(cmd *Command, args []string) {
 	if testO != "" && len(pkgs) != 1 {
 		fatalf("cannot use -o flag with multiple packages")
 	}
-	if testProfile && len(pkgs) != 1 {
-		fatalf("cannot use test profile flag with multiple packages")
+	if testFuzz != "" && len(pkgs) != 1 {
+		fatalf("cannot use -fuzz flag with multiple packages")
 	}
 
+	// The tests of several packages cannot all write their profiles
+	// to the same file, so each gets a name of its own.
+	// See testProfileName.
+	testProfileEach = len(pkgs) > 1
+
 	// If a test timeout was given and is parseable, set our kill timeout
 	// to that timeout plus one minute.  This is a backup alarm in case
 	// the test wedges with a goroutine spinning and its background
@@ -337,22 +435,37 @@ func runTest(cmd *Command, args []string) {
 	if dt, err := time.ParseDuration(testTimeout); err == nil && dt > 0 {
 		testKillTimeout = dt + 1*time.Minute
 	}
+	// The fuzzing engine runs until it finds a failing input or -fuzztime
+	// has elapsed, so there is no sensible deadline to enforce.
+	if testFuzz != "" {
+		testKillTimeout = 100 * 365 * 24 * time.Hour
+	}
 
 	// show passing test output (after buffering) with -v flag.
 	// must buffer because tests are running in parallel, and
 	// otherwise the output will get mixed.
-	testShowPass = testV
+	testShowPass = testV || testJSON
 
 	// stream test output (no buffering) when no package has
 	// been given on the command line (implicit current directory)
-	// or when benchmarking.
+	// or when benchmarking or fuzzing.
 	// Also stream if we're showing output anyway with a
 	// single package under test or if parallelism is set to 1.
 	// In these cases, streaming the output produces the same result
 	// as not streaming, just more immediately.
-	testStreamOutput = len(pkgArgs) == 0 || testBench ||
+	testStreamOutput = len(pkgArgs) == 0 || testBench || testFuzz != "" ||
 		(testShowPass && (len(pkgs) == 1 || buildP == 1))
 
+	// cache the results of passing tests only when testing a list of
+	// packages, and only when the flags do not ask the test for anything
+	// but its pass or fail result (see 'go help cache').
+	testCacheOK = len(pkgArgs) > 0 && !testC && cacheableTestArgs(testArgs)
+
+	// The counters of atomic coverage mode are updated using sync/atomic.
+	if testCover && testCoverMode == "atomic" {
+		testMainDeps["sync/atomic"] = true
+	}
+
 	var b builder
 	b.init()
 
@@ -370,10 +483,10 @@ func runTest(cmd *Command, args []string) {
 				deps[path] = true
 			}
 			for _, path := range p.TestImports {
-				deps[path] = true
+				deps[vendoredImportPath(path, p.Dir)] = true
 			}
 			for _, path := range p.XTestImports {
-				deps[path] = true
+				deps[vendoredImportPath(path, p.Dir)] = true
 			}
 		}
 
@@ -533,6 +646,10 @@ func runTest(cmd *Command, args []string) {
 	}
 
 	b.do(root)
+
+	if testCoverProfile != "" && !testC && !buildN {
+		writeCoverProfile()
+	}
 }
 
 func contains(x []string, s string) bool {
@@ -568,11 +685,12 @@ func (b *builder) test(p *Package) (buildAction, runAction, printAction *action,
 	var imports, ximports []*Package
 	var stk importStack
 	stk.push(p.ImportPath + " (test)")
-	for _, path := range p.TestImports {
-		p1 := loadImport(path, p.Dir, &stk, p.build.TestImportPos[path])
+	for i, path := range p.TestImports {
+		p1 := loadImport(path, p.Dir, p, &stk, p.build.TestImportPos[path])
 		if p1.Error != nil {
 			return nil, nil, nil, p1.Error
 		}
+		p.TestImports[i] = p1.ImportPath
 		if contains(p1.Deps, p.ImportPath) {
 			// Same error that loadPackage returns (via reusePackage) in pkg.go.
 			// Can't change that code, because that code is only for loading the
@@ -589,15 +707,16 @@ func (b *builder) test(p *Package) (buildAction, runAction, printAction *action,
 	stk.pop()
 	stk.push(p.ImportPath + "_test")
 	pxtestNeedsPtest := false
-	for _, path := range p.XTestImports {
-		if path == p.ImportPath {
-			pxtestNeedsPtest = true
-			continue
-		}
-		p1 := loadImport(path, p.Dir, &stk, p.build.XTestImportPos[path])
+	for i, path := range p.XTestImports {
+		p1 := loadImport(path, p.Dir, p, &stk, p.build.XTestImportPos[path])
 		if p1.Error != nil {
 			return nil, nil, nil, p1.Error
 		}
+		p.XTestImports[i] = p1.ImportPath
+		if p1 == p {
+			pxtestNeedsPtest = true
+			continue
+		}
 		ximports = append(ximports, p1)
 	}
 	stk.pop()
@@ -723,7 +842,7 @@ func (b *builder) test(p *Package) (buildAction, runAction, printAction *action,
 		if dep == ptest.ImportPath {
 			pmain.imports = append(pmain.imports, ptest)
 		} else {
-			p1 := loadImport(dep, "", &stk, nil)
+			p1 := loadImport(dep, "", nil, &stk, nil)
 			if p1.Error != nil {
 				return nil, nil, nil, p1.Error
 			}
@@ -850,6 +969,9 @@ func (b *builder) test(p *Package) (buildAction, runAction, printAction *action,
 	if testC || testNeedBinary {
 		// -c or profiling flag: create action to copy binary to ./test.out.
 		target := filepath.Join(cwd, testBinary+exeSuffix)
+		if testProfileEach {
+			target = filepath.Join(cwd, testProfileName(p, "test")+exeSuffix)
+		}
 		if testO != "" {
 			target = testO
 			if !filepath.IsAbs(target) {
@@ -976,11 +1098,75 @@ func declareCoverVars(importPath string, files ...string) map[string]*CoverVar {
 	return coverVars
 }
 
+// testProfileFlags are the test flags that name a profile to write.
+var testProfileFlags = map[string]bool{
+	"blockprofile": true,
+	"cpuprofile":   true,
+	"memprofile":   true,
+	"trace":        true,
+}
+
+// testProfileName returns the name under which the test of p writes
+// the file with the given name: the profile named by a profiling flag
+// or, for the name "test", the test binary kept for analyzing the
+// profiles. When testing a single package, that is the name itself.
+// When testing several, it is prefixed with the import path of p,
+// with slashes replaced by underscores, so that profiling
+// encoding/json with -cpuprofile=cpu.out writes encoding_json.cpu.out
+// and keeps the binary encoding_json.test.
+func testProfileName(p *Package, name string) string {
+	if !testProfileEach {
+		return name
+	}
+	dir, file := filepath.Split(name)
+	return dir + strings.Replace(p.ImportPath, "/", "_", -1) + "." + file
+}
+
+// testBinaryArgs returns the arguments for the test binary run by a:
+// testArgs, with the profile names rewritten for the package.
+// The coverage profile is written to the package's test directory
+// in the work directory, to be merged by mergeCoverProfile.
+func (b *builder) testBinaryArgs(a *action) []string {
+	if testCoverProfile == "" && !testProfileEach {
+		return testArgs
+	}
+	args := make([]string, 0, len(testArgs))
+	for _, arg := range testArgs {
+		if strings.HasPrefix(arg, "-test.") {
+			if i := strings.Index(arg, "="); i >= 0 {
+				name, value := arg[len("-test."):i], arg[i+1:]
+				switch {
+				case name == "coverprofile":
+					arg = "-test.coverprofile=" + b.testCoverFile(a.p)
+				case testProfileFlags[name]:
+					arg = "-test." + name + "=" + testProfileName(a.p, value)
+				}
+			}
+		}
+		args = append(args, arg)
+	}
+	return args
+}
+
 // runTest is the action for running a test binary.
 func (b *builder) runTest(a *action) error {
-	args := stringList(findExecCmd(), a.deps[0].target, testArgs)
+	args := stringList(findExecCmd(), a.deps[0].target, b.testBinaryArgs(a))
 	a.testOutput = new(bytes.Buffer)
 
+	// testOut receives the test's output and result summary.
+	// With -json, it converts them to JSON events, which go straight
+	// to standard output when streaming and are buffered otherwise.
+	var testOut io.Writer = a.testOutput
+	if testJSON {
+		var w io.Writer = a.testOutput
+		if testStreamOutput {
+			w = lockedStdout{}
+		}
+		json := test2json.NewConverter(w, a.p.ImportPath, test2json.Timestamp)
+		defer json.Close()
+		testOut = json
+	}
+
 	if buildN || buildX {
 		b.showcmd("", "%s", strings.Join(args, " "))
 		if buildN {
@@ -991,16 +1177,37 @@ func (b *builder) runTest(a *action) error {
 	if a.failed {
 		// We were unable to build the binary.
 		a.failed = false
-		fmt.Fprintf(a.testOutput, "FAIL\t%s [build failed]\n", a.p.ImportPath)
+		fmt.Fprintf(testOut, "FAIL\t%s [build failed]\n", a.p.ImportPath)
 		setExitStatus(1)
 		return nil
 	}
 
+	cache := b.cache()
+	var testID cacheID
+	cacheable := false
+	if cache != nil && testCacheOK {
+		testID, cacheable = b.testID(a)
+	}
+	testlogFile := filepath.Join(filepath.Dir(a.deps[0].target), "testlog.txt")
+	if cacheable {
+		if out, ok := cachedTestOutput(cache, a, testID); ok {
+			if testShowPass {
+				testOut.Write(out)
+			}
+			fmt.Fprintf(testOut, "ok  \t%s\t(cached)%s\n", a.p.ImportPath, coveragePercentage(out))
+			return nil
+		}
+		args = append(args, "-test.testlogfile="+testlogFile)
+	}
+
 	cmd := exec.Command(args[0], args[1:]...)
 	cmd.Dir = a.p.Dir
-	cmd.Env = envForDir(cmd.Dir)
+	cmd.Env = testEnv(a.p)
 	var buf bytes.Buffer
-	if testStreamOutput {
+	if testStreamOutput && testJSON {
+		cmd.Stdout = testOut
+		cmd.Stderr = testOut
+	} else if testStreamOutput {
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
 	} else {
@@ -1008,23 +1215,14 @@ func (b *builder) runTest(a *action) error {
 		cmd.Stderr = &buf
 	}
 
-	// If there are any local SWIG dependencies, we want to load
-	// the shared library from the build directory.
-	if a.p.usesSwig() {
-		env := cmd.Env
-		found := false
-		prefix := "LD_LIBRARY_PATH="
-		for i, v := range env {
-			if strings.HasPrefix(v, prefix) {
-				env[i] = v + ":."
-				found = true
-				break
-			}
-		}
-		if !found {
-			env = append(env, "LD_LIBRARY_PATH=.")
-		}
-		cmd.Env = env
+	// When streaming, keep a copy of the output for the cache.
+	// Sharing one writer between standard output and standard error
+	// keeps the copy in the order the test wrote it.
+	var record bytes.Buffer
+	if cacheable && testStreamOutput {
+		w := io.MultiWriter(cmd.Stdout, &record)
+		cmd.Stdout = w
+		cmd.Stderr = w
 	}
 
 	t0 := time.Now()
@@ -1065,26 +1263,288 @@ func (b *builder) runTest(a *action) error {
 	}
 	out := buf.Bytes()
 	t := fmt.Sprintf("%.3fs", time.Since(t0).Seconds())
+	if testCoverProfile != "" {
+		if err := mergeCoverProfile(b.testCoverFile(a.p)); err != nil {
+			fmt.Fprintf(os.Stderr, "go: merging coverage profile for %s: %v\n", a.p.ImportPath, err)
+			setExitStatus(1)
+		}
+	}
 	if err == nil {
+		if cacheable {
+			result := out
+			if testStreamOutput {
+				result = record.Bytes()
+			}
+			if err := saveTestOutput(cache, a, testID, testlogFile, result); err != nil {
+				fmt.Fprintf(os.Stderr, "go: caching test result for %s: %v\n", a.p.ImportPath, err)
+			}
+		}
 		if testShowPass {
-			a.testOutput.Write(out)
+			testOut.Write(out)
 		}
-		fmt.Fprintf(a.testOutput, "ok  \t%s\t%s%s\n", a.p.ImportPath, t, coveragePercentage(out))
+		fmt.Fprintf(testOut, "ok  \t%s\t%s%s\n", a.p.ImportPath, t, coveragePercentage(out))
 		return nil
 	}
 
 	setExitStatus(1)
 	if len(out) > 0 {
-		a.testOutput.Write(out)
+		testOut.Write(out)
 		// assume printing the test binary's exit status is superfluous
 	} else {
-		fmt.Fprintf(a.testOutput, "%s\n", err)
+		fmt.Fprintf(testOut, "%s\n", err)
 	}
-	fmt.Fprintf(a.testOutput, "FAIL\t%s\t%s\n", a.p.ImportPath, t)
+	fmt.Fprintf(testOut, "FAIL\t%s\t%s\n", a.p.ImportPath, t)
 
 	return nil
 }
 
+// cacheableTestFlags are the test flags that leave the result of a
+// test run cacheable.
+// A run with -count is never cached, so that -count=1 is the
+// idiomatic way to run tests again.
+var cacheableTestFlags = map[string]bool{
+	"cpu":      true,
+	"failfast": true,
+	"parallel": true,
+	"run":      true,
+	"shard":    true,
+	"shards":   true,
+	"short":    true,
+	"timeout":  true,
+	"v":        true,
+}
+
+// cacheableTestArgs reports whether the test binary arguments
+// args leave the result of a test run cacheable.
+func cacheableTestArgs(args []string) bool {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-test.") {
+			return false
+		}
+		name := strings.TrimPrefix(arg, "-test.")
+		if i := strings.Index(name, "="); i >= 0 {
+			name = name[:i]
+		}
+		if !cacheableTestFlags[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// testEnv returns the environment in which to run the test of p.
+func testEnv(p *Package) []string {
+	env := envForDir(p.Dir)
+	// If there are any local SWIG dependencies, we want to load
+	// the shared library from the build directory.
+	if p.usesSwig() {
+		found := false
+		prefix := "LD_LIBRARY_PATH="
+		for i, v := range env {
+			if strings.HasPrefix(v, prefix) {
+				env[i] = v + ":."
+				found = true
+				break
+			}
+		}
+		if !found {
+			env = append(env, "LD_LIBRARY_PATH=.")
+		}
+	}
+	return env
+}
+
+// testID returns the key identifying the test run by a, or false if
+// the run cannot be cached. It covers the test binary, its arguments
+// and the GOxxx environment variables, but not the other environment
+// variables and files the test reads: those are recorded in the test
+// log stored under testID, and their state is hashed by testInputsID.
+func (b *builder) testID(a *action) (cacheID, bool) {
+	id, err := actionOutputID(a.deps[0])
+	if err != nil {
+		return cacheID{}, false
+	}
+	k := newCacheHash("test")
+	k.add("binary %s", id)
+	k.add("args %q", testArgs)
+	k.add("exec %q", findExecCmd())
+	k.add("dir %s", a.p.Dir)
+	env := os.Environ()
+	sort.Strings(env)
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "GO") {
+			k.add("env %s", kv)
+		}
+	}
+	return k.sum(), true
+}
+
+// testOutputKey returns the key under which the output of the test
+// identified by testID is stored, given the hash of its inputs.
+func testOutputKey(testID, inputsID cacheID) cacheID {
+	k := newCacheHash("testoutput")
+	k.add("test %s", testID)
+	k.add("inputs %s", inputsID)
+	return k.sum()
+}
+
+// cachedTestOutput returns the recorded output of a passing run of the
+// test identified by testID, if the inputs listed in the test log from
+// that run are unchanged.
+func cachedTestOutput(cache *buildCache, a *action, testID cacheID) ([]byte, bool) {
+	log, ok := cache.getBytes(testID)
+	if !ok {
+		return nil, false
+	}
+	inputsID, ok := testInputsID(a, log)
+	if !ok {
+		return nil, false
+	}
+	return cache.getBytes(testOutputKey(testID, inputsID))
+}
+
+// saveTestOutput stores the output of a passing run of the test
+// identified by testID, along with the log of the inputs the test read,
+// which it found in testlogFile. A run whose log is missing or lists
+// inputs that cannot be hashed reliably is not cached.
+func saveTestOutput(cache *buildCache, a *action, testID cacheID, testlogFile string, out []byte) error {
+	log, err := ioutil.ReadFile(testlogFile)
+	if err != nil {
+		// The test did not call m.Run, or ran on another system.
+		return nil
+	}
+	inputsID, ok := testInputsID(a, log)
+	if !ok {
+		return nil
+	}
+	if err := cache.putBytes(testID, log); err != nil {
+		return err
+	}
+	return cache.putBytes(testOutputKey(testID, inputsID), out)
+}
+
+// testModTimeCutoff is how old a file must be for a test that examines
+// it with os.Stat to be cached. A file modified more recently could be
+// modified again without its modification time changing.
+const testModTimeCutoff = 2 * time.Second
+
+// testInputsID returns a hash of the current state of the inputs
+// listed in the test log written by the testing package: the values
+// of the environment variables the test looked up, the contents of the
+// files and directories it opened, and the size and modification time
+// of the files it examined. It returns false if the log is not valid
+// or an input changed too recently to be hashed reliably.
+func testInputsID(a *action, log []byte) (cacheID, bool) {
+	lines := strings.Split(string(log), "\n")
+	if lines[0] != "# test log" {
+		return cacheID{}, false
+	}
+	env := testEnv(a.p)
+	pwd := a.p.Dir
+	k := newCacheHash("testinputs")
+	for _, line := range lines[1:] {
+		if line == "" {
+			continue
+		}
+		i := strings.Index(line, " ")
+		if i < 0 {
+			return cacheID{}, false
+		}
+		op := line[:i]
+		name, err := strconv.Unquote(line[i+1:])
+		if err != nil {
+			return cacheID{}, false
+		}
+		if op != "getenv" && !filepath.IsAbs(name) {
+			name = filepath.Join(pwd, name)
+		}
+		switch op {
+		default:
+			return cacheID{}, false
+		case "getenv":
+			k.add("getenv %q %s", name, lookupEnv(env, name))
+		case "chdir":
+			pwd = name
+			k.add("chdir %q", name)
+		case "stat":
+			info, err := os.Stat(name)
+			if err != nil {
+				k.add("stat %q missing", name)
+				break
+			}
+			if time.Since(info.ModTime()) < testModTimeCutoff {
+				return cacheID{}, false
+			}
+			k.add("stat %q %v %d %d", name, info.Mode(), info.Size(), info.ModTime().UnixNano())
+		case "open":
+			id, err := hashTestInput(name)
+			if err != nil {
+				return cacheID{}, false
+			}
+			k.add("open %q %s", name, id)
+		}
+	}
+	return k.sum(), true
+}
+
+// lookupEnv returns the value of the variable key in env, quoted,
+// or "unset" if env does not set it.
+func lookupEnv(env []string, key string) string {
+	for i := len(env) - 1; i >= 0; i-- {
+		if strings.HasPrefix(env[i], key+"=") {
+			return strconv.Quote(env[i][len(key)+1:])
+		}
+	}
+	return "unset"
+}
+
+// hashTestInput returns a hash of the state of the file name opened by
+// a test: the contents of a regular file, the names in a directory, or
+// else the file's mode, or that it does not exist. Unlike hashFile, it
+// does not remember hashes, since tests may write the files they open.
+func hashTestInput(name string) (string, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "missing", nil
+		}
+		return "", err
+	}
+	h := sha256.New()
+	switch {
+	case info.IsDir():
+		names, err := readDirNames(name)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "dir %q\n", names)
+	case info.Mode().IsRegular():
+		f, err := os.Open(name)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		if _, err := io.Copy(h, f); err != nil {
+			return "", err
+		}
+	default:
+		return fmt.Sprintf("mode %v", info.Mode()), nil
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// readDirNames returns the sorted names of the entries in dir.
+func readDirNames(dir string) ([]string, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	names, err := f.Readdirnames(-1)
+	sort.Strings(names)
+	return names, err
+}
+
 // coveragePercentage returns the coverage results (if enabled) for the
 // test. It uncovers the data by scanning the output from the test run.
 func coveragePercentage(out []byte) string {
@@ -1126,10 +1586,31 @@ func (b *builder) printTest(a *action) error {
 
 // notest is the action for testing a package with no test files.
 func (b *builder) notest(a *action) error {
+	if testJSON {
+		json := test2json.NewConverter(lockedStdout{}, a.p.ImportPath, test2json.Timestamp)
+		defer json.Close()
+		fmt.Fprintf(json, "?   \t%s\t[no test files]\n", a.p.ImportPath)
+		return nil
+	}
 	fmt.Printf("?   \t%s\t[no test files]\n", a.p.ImportPath)
 	return nil
 }
 
+// stdoutMu serializes writes to standard output by concurrently
+// running test actions.
+var stdoutMu sync.Mutex
+
+// lockedStdout is an io.Writer that writes to standard output
+// while holding stdoutMu, so that concurrent writes of whole
+// JSON events are not interleaved.
+type lockedStdout struct{}
+
+func (lockedStdout) Write(b []byte) (int, error) {
+	stdoutMu.Lock()
+	defer stdoutMu.Unlock()
+	return os.Stdout.Write(b)
+}
+
 // isTestMain tells whether fn is a TestMain(m *testing.M) function.
 func isTestMain(fn *ast.FuncDecl) bool {
 	if fn.Name.String() != "TestMain" ||
@@ -1210,6 +1691,7 @@ func writeTestmain(out string, t *testFuncs) error {
 type testFuncs struct {
 	Tests       []testFunc
 	Benchmarks  []testFunc
+	FuzzTargets []testFunc
 	Examples    []testFunc
 	TestMain    *testFunc
 	Package     *Package
@@ -1280,6 +1762,9 @@ func (t *testFuncs) load(filename, pkg string, doImport, seen *bool) error {
 		case isTest(name, "Benchmark"):
 			t.Benchmarks = append(t.Benchmarks, testFunc{pkg, name, ""})
 			*doImport, *seen = true, true
+		case isTest(name, "Fuzz"):
+			t.FuzzTargets = append(t.FuzzTargets, testFunc{pkg, name, ""})
+			*doImport, *seen = true, true
 		}
 	}
 	ex := doc.Examples(f)
@@ -1339,6 +1824,12 @@ var benchmarks = []testing.InternalBenchmark{
 {{end}}
 }
 
+var fuzzTargets = []testing.InternalFuzzTarget{
+{{range .FuzzTargets}}
+	{"{{.Name}}", {{.Package}}.{{.Name}}},
+{{end}}
+}
+
 var examples = []testing.InternalExample{
 {{range .Examples}}
 	{"{{.Name}}", {{.Package}}.{{.Name}}, {{.Output | printf "%q"}}},
@@ -1407,7 +1898,7 @@ func main() {
 		CoveredPackages: {{printf "%q" .Covered}},
 	})
 {{end}}
-	m := testing.MainStart(matchString, tests, benchmarks, examples)
+	m := testing.MainStart(matchString, tests, benchmarks, fuzzTargets, examples)
 {{with .TestMain}}
 	{{.Package}}.{{.Name}}(m)
 {{else}}
@@ -0,0 +1,78 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"cmd/internal/cover"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// The coverage profile written by 'go test -coverprofile' is merged
+// from the profiles of the test binaries. Each binary writes its
+// profile to a file in its test directory, and runTest adds the blocks
+// in that file to coverMerge when the binary exits. When -coverpkg
+// makes several binaries instrument the same package, its blocks
+// appear more than once; cover.ParseProfilesFromReader combines their
+// counts as the coverage mode requires.
+var coverMerge struct {
+	sync.Mutex
+	blocks bytes.Buffer // profile lines, without mode lines
+}
+
+// testCoverFile returns the name of the file to which the test binary
+// for p writes its coverage profile.
+func (b *builder) testCoverFile(p *Package) string {
+	return filepath.Join(b.work, filepath.FromSlash(p.ImportPath+"/_test"), "_cover_.out")
+}
+
+// mergeCoverProfile adds the coverage profile in the named file to coverMerge.
+// A missing file is not an error: a test binary that failed to start
+// or exited early writes no profile.
+func mergeCoverProfile(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	mode := []byte("mode: " + testCoverMode + "\n")
+	if !bytes.HasPrefix(data, mode) {
+		return fmt.Errorf("%s: profile does not begin with %q", file, bytes.TrimSpace(mode))
+	}
+	coverMerge.Lock()
+	coverMerge.blocks.Write(data[len(mode):])
+	coverMerge.Unlock()
+	return nil
+}
+
+// writeCoverProfile writes the merged coverage profile to the file
+// named by the -coverprofile flag.
+func writeCoverProfile() {
+	r := bytes.NewBufferString("mode: " + testCoverMode + "\n")
+	r.Write(coverMerge.blocks.Bytes())
+	profiles, err := cover.ParseProfilesFromReader(r)
+	if err != nil {
+		errorf("go test: merging coverage profiles: %v", err)
+		return
+	}
+	f, err := os.Create(testCoverProfile)
+	if err != nil {
+		errorf("go test: %v", err)
+		return
+	}
+	err = cover.WriteProfiles(f, testCoverMode, profiles)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		errorf("go test: writing coverage profile: %v", err)
+	}
+}
@@ -0,0 +1,3 @@
+package dep
+
+const Msg = "dep v1.0.0"
@@ -0,0 +1,3 @@
+package lib
+
+func Msg() string { return "lib v1.0.0" }
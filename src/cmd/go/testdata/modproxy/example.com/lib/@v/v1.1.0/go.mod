@@ -0,0 +1,3 @@
+module example.com/lib
+
+require example.com/dep v1.2.0
@@ -0,0 +1,5 @@
+package lib
+
+import "example.com/dep"
+
+func Msg() string { return "lib v1.1.0, " + dep.Msg }
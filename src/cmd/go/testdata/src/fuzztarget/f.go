@@ -0,0 +1,3 @@
+package fuzztarget
+
+func Double(n int) int { return 2 * n }
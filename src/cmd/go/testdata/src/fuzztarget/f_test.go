@@ -0,0 +1,12 @@
+package fuzztarget
+
+import "testing"
+
+func FuzzDouble(f *testing.F) {
+	f.Add(21)
+	f.Fuzz(func(t *testing.T, n int) {
+		if d := Double(n); d/2 != n {
+			t.Errorf("Double(%d) = %d", n, d)
+		}
+	})
+}
@@ -0,0 +1,10 @@
+package testcache
+
+import (
+	"os"
+	"testing"
+)
+
+func TestEnv(t *testing.T) {
+	t.Logf("TESTCACHE_VAR=%s", os.Getenv("TESTCACHE_VAR"))
+}
@@ -0,0 +1,3 @@
+package bad
+
+import _ "vend/vendor/strings"
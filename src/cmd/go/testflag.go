@@ -7,6 +7,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
@@ -32,6 +33,7 @@ var testFlagDefn = []*testFlagSpec{
 	{name: "cover", boolVar: &testCover},
 	{name: "covermode"},
 	{name: "coverpkg"},
+	{name: "json", boolVar: &testJSON},
 	{name: "o"},
 
 	// build flags.
@@ -55,9 +57,14 @@ var testFlagDefn = []*testFlagSpec{
 	{name: "bench", passToTest: true},
 	{name: "benchmem", boolVar: new(bool), passToTest: true},
 	{name: "benchtime", passToTest: true},
+	{name: "count", passToTest: true},
 	{name: "coverprofile", passToTest: true},
 	{name: "cpu", passToTest: true},
 	{name: "cpuprofile", passToTest: true},
+	{name: "failfast", boolVar: new(bool), passToTest: true},
+	{name: "fuzz", passToTest: true},
+	{name: "fuzzminimizetime", passToTest: true},
+	{name: "fuzztime", passToTest: true},
 	{name: "memprofile", passToTest: true},
 	{name: "memprofilerate", passToTest: true},
 	{name: "blockprofile", passToTest: true},
@@ -65,6 +72,8 @@ var testFlagDefn = []*testFlagSpec{
 	{name: "outputdir", passToTest: true},
 	{name: "parallel", passToTest: true},
 	{name: "run", passToTest: true},
+	{name: "shard", passToTest: true},
+	{name: "shards", passToTest: true},
 	{name: "short", boolVar: new(bool), passToTest: true},
 	{name: "timeout", passToTest: true},
 	{name: "trace", passToTest: true},
@@ -82,7 +91,6 @@ var testFlagDefn = []*testFlagSpec{
 //	go test -x math
 func testFlags(args []string) (packageNames, passToTest []string) {
 	inPkg := false
-	outputDir := ""
 	for i := 0; i < len(args); i++ {
 		if !strings.HasPrefix(args[i], "-") {
 			if !inPkg && packageNames == nil {
@@ -116,7 +124,7 @@ func testFlags(args []string) (packageNames, passToTest []string) {
 		var err error
 		switch f.name {
 		// bool flags.
-		case "a", "c", "i", "n", "x", "v", "race", "cover", "work", "linkshared":
+		case "a", "c", "i", "n", "x", "v", "race", "cover", "work", "linkshared", "json":
 			setBoolFlag(f.boolVar, value)
 		case "o":
 			testO = value
@@ -150,6 +158,8 @@ func testFlags(args []string) (packageNames, passToTest []string) {
 		case "bench":
 			// record that we saw the flag; don't care about the value
 			testBench = true
+		case "fuzz":
+			testFuzz = value
 		case "timeout":
 			testTimeout = value
 		case "blockprofile", "cpuprofile", "memprofile", "trace":
@@ -165,6 +175,7 @@ func testFlags(args []string) (packageNames, passToTest []string) {
 		case "coverprofile":
 			testCover = true
 			testProfile = true
+			testCoverProfile = value
 		case "covermode":
 			switch value {
 			case "set", "count", "atomic":
@@ -174,7 +185,13 @@ func testFlags(args []string) (packageNames, passToTest []string) {
 			}
 			testCover = true
 		case "outputdir":
-			outputDir = value
+			// The test binaries run in their package directories,
+			// so a relative directory is made absolute here.
+			value, err = filepath.Abs(value)
+			if err != nil {
+				fatalf("invalid flag argument for -%s: %v", f.name, err)
+			}
+			testOutputDir = value
 		}
 		if extraWord {
 			i++
@@ -184,6 +201,24 @@ func testFlags(args []string) (packageNames, passToTest []string) {
 		}
 	}
 
+	// The JSON converter needs the verbose output to see every test.
+	if testJSON {
+		passToTest = append(passToTest, "-test.v=true")
+	}
+
+	// The fuzzing engine is guided by the coverage counters
+	// of the package under test.
+	if testFuzz != "" {
+		if testCover {
+			fatalf("cannot use -fuzz flag with coverage flags")
+		}
+		testCover = true
+		testCoverMode = "count"
+		if buildRace {
+			testCoverMode = "atomic"
+		}
+	}
+
 	if testCoverMode == "" {
 		testCoverMode = "set"
 		if buildRace {
@@ -193,13 +228,20 @@ func testFlags(args []string) (packageNames, passToTest []string) {
 	}
 
 	// Tell the test what directory we're running in, so it can write the profiles there.
-	if testProfile && outputDir == "" {
+	if testProfile && testOutputDir == "" {
 		dir, err := os.Getwd()
 		if err != nil {
 			fatalf("error from os.Getwd: %s", err)
 		}
+		testOutputDir = dir
 		passToTest = append(passToTest, "-test.outputdir", dir)
 	}
+
+	// The go command itself writes the coverage profile,
+	// merged from those of the test binaries.
+	if testCoverProfile != "" && !filepath.IsAbs(testCoverProfile) {
+		testCoverProfile = filepath.Join(testOutputDir, testCoverProfile)
+	}
 	return
 }
 
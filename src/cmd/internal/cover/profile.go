@@ -0,0 +1,270 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package cover reads and writes the coverage profiles generated by
+// "go test -coverprofile=cover.out".
+// It is used by cmd/cover and cmd/go.
+package cover
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A profile consists of a line giving the coverage mode,
+//	mode: set
+// where the mode is "set", "count", or "atomic", followed by one
+// line for each basic block, in the format
+//	encoding/base64/base64.go:34.44,37.40 3 1
+// where the fields are name.go:line.column,line.column numberOfStatements count.
+
+// Profile represents the profiling data for a specific file.
+type Profile struct {
+	FileName string
+	Mode     string
+	Blocks   []ProfileBlock
+}
+
+// ProfileBlock represents a single block of profiling data.
+type ProfileBlock struct {
+	StartLine, StartCol int
+	EndLine, EndCol     int
+	NumStmt, Count      int
+}
+
+type byFileName []*Profile
+
+func (p byFileName) Len() int           { return len(p) }
+func (p byFileName) Less(i, j int) bool { return p[i].FileName < p[j].FileName }
+func (p byFileName) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// ParseProfiles parses profile data in the named file and returns a
+// Profile for each source file described therein.
+func ParseProfiles(fileName string) ([]*Profile, error) {
+	pf, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer pf.Close()
+	profiles, err := ParseProfilesFromReader(pf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+	return profiles, nil
+}
+
+// ParseProfilesFromReader parses profile data from r and returns a
+// Profile for each source file described therein, sorted by file name.
+//
+// A block may appear more than once, as it does when the profiles of
+// several test binaries instrumenting the same package are concatenated.
+// The counts of such a block are merged: in "set" mode the block is
+// covered if any of them is nonzero; otherwise they are added.
+func ParseProfilesFromReader(r io.Reader) ([]*Profile, error) {
+	files := make(map[string]*Profile)
+	s := bufio.NewScanner(r)
+	mode := ""
+	for s.Scan() {
+		line := s.Text()
+		if mode == "" {
+			const p = "mode: "
+			if !strings.HasPrefix(line, p) || line == p {
+				return nil, fmt.Errorf("bad mode line: %v", line)
+			}
+			mode = line[len(p):]
+			continue
+		}
+		fn, b, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %q doesn't match expected format: %v", line, err)
+		}
+		p := files[fn]
+		if p == nil {
+			p = &Profile{
+				FileName: fn,
+				Mode:     mode,
+			}
+			files[fn] = p
+		}
+		p.Blocks = append(p.Blocks, b)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	for _, p := range files {
+		sort.Sort(blocksByStart(p.Blocks))
+		// Merge samples from the same location.
+		j := 1
+		for i := 1; i < len(p.Blocks); i++ {
+			b := p.Blocks[i]
+			last := &p.Blocks[j-1]
+			if b.StartLine == last.StartLine &&
+				b.StartCol == last.StartCol &&
+				b.EndLine == last.EndLine &&
+				b.EndCol == last.EndCol {
+				if b.NumStmt != last.NumStmt {
+					return nil, fmt.Errorf("inconsistent NumStmt for %s:%d.%d: changed from %d to %d",
+						p.FileName, b.StartLine, b.StartCol, last.NumStmt, b.NumStmt)
+				}
+				if mode == "set" {
+					last.Count |= b.Count
+				} else {
+					last.Count += b.Count
+				}
+				continue
+			}
+			p.Blocks[j] = b
+			j++
+		}
+		p.Blocks = p.Blocks[:j]
+	}
+	profiles := make([]*Profile, 0, len(files))
+	for _, p := range files {
+		profiles = append(profiles, p)
+	}
+	sort.Sort(byFileName(profiles))
+	return profiles, nil
+}
+
+// parseLine parses a block line from a profile.
+func parseLine(l string) (fileName string, b ProfileBlock, err error) {
+	end := len(l)
+	if b.Count, end, err = seekBack(l, ' ', end, "Count"); err != nil {
+		return
+	}
+	if b.NumStmt, end, err = seekBack(l, ' ', end, "NumStmt"); err != nil {
+		return
+	}
+	if b.EndCol, end, err = seekBack(l, '.', end, "EndCol"); err != nil {
+		return
+	}
+	if b.EndLine, end, err = seekBack(l, ',', end, "EndLine"); err != nil {
+		return
+	}
+	if b.StartCol, end, err = seekBack(l, '.', end, "StartCol"); err != nil {
+		return
+	}
+	if b.StartLine, end, err = seekBack(l, ':', end, "StartLine"); err != nil {
+		return
+	}
+	fileName = l[:end]
+	if fileName == "" {
+		err = fmt.Errorf("missing file name")
+	}
+	return
+}
+
+// seekBack searches backward from end for sep in l and returns the
+// non-negative integer between sep and end, along with the position of sep.
+// Errors refer to the value as what.
+func seekBack(l string, sep byte, end int, what string) (value, nextSep int, err error) {
+	for start := end - 1; start >= 0; start-- {
+		if l[start] == sep {
+			i, err := strconv.Atoi(l[start+1 : end])
+			if err != nil {
+				return 0, 0, fmt.Errorf("couldn't parse %s: %v", what, err)
+			}
+			if i < 0 {
+				return 0, 0, fmt.Errorf("negative value for %s: %d", what, i)
+			}
+			return i, start, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("couldn't find a %q before %s", sep, what)
+}
+
+type blocksByStart []ProfileBlock
+
+func (b blocksByStart) Len() int      { return len(b) }
+func (b blocksByStart) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b blocksByStart) Less(i, j int) bool {
+	bi, bj := b[i], b[j]
+	return bi.StartLine < bj.StartLine || bi.StartLine == bj.StartLine && bi.StartCol < bj.StartCol
+}
+
+// WriteProfiles writes the profiles to w in the format read by
+// ParseProfiles, preceded by a line giving the mode.
+func WriteProfiles(w io.Writer, mode string, profiles []*Profile) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "mode: %s\n", mode)
+	for _, p := range profiles {
+		for _, b := range p.Blocks {
+			fmt.Fprintf(bw, "%s:%d.%d,%d.%d %d %d\n", p.FileName,
+				b.StartLine, b.StartCol, b.EndLine, b.EndCol, b.NumStmt, b.Count)
+		}
+	}
+	return bw.Flush()
+}
+
+// Boundary represents the position in a source file of the beginning or end of a
+// block as reported by the coverage profile. In HTML mode, it will correspond to
+// the opening or closing of a <span> tag and will be used to colorize the source.
+type Boundary struct {
+	Offset int     // Location as a byte offset in the source file.
+	Start  bool    // Is this the start of a block?
+	Count  int     // Event count from the cover profile.
+	Norm   float64 // Count normalized to [0..1].
+}
+
+// Boundaries returns a Profile as a set of Boundary objects within the provided src.
+func (p *Profile) Boundaries(src []byte) (boundaries []Boundary) {
+	// Find maximum count.
+	max := 0
+	for _, b := range p.Blocks {
+		if b.Count > max {
+			max = b.Count
+		}
+	}
+	// Divisor for normalization.
+	divisor := math.Log(float64(max))
+
+	// boundary returns a Boundary, populating the Norm field with a normalized Count.
+	boundary := func(offset int, start bool, count int) Boundary {
+		b := Boundary{Offset: offset, Start: start, Count: count}
+		if !start || count == 0 {
+			return b
+		}
+		if max <= 1 {
+			b.Norm = 0.8 // Profile is in "set" mode; we want a heat map. Use cov8 in the CSS.
+		} else if count > 0 {
+			b.Norm = math.Log(float64(count)) / divisor
+		}
+		return b
+	}
+
+	// Columns in the profile count bytes from 1.
+	line, col := 1, 1
+	for si, bi := 0, 0; si < len(src) && bi < len(p.Blocks); {
+		b := p.Blocks[bi]
+		if b.StartLine == line && b.StartCol == col {
+			boundaries = append(boundaries, boundary(si, true, b.Count))
+		}
+		if b.EndLine == line && b.EndCol == col || line > b.EndLine {
+			boundaries = append(boundaries, boundary(si, false, 0))
+			bi++
+			continue // Don't advance through src; maybe the next block starts here.
+		}
+		if src[si] == '\n' {
+			line++
+			col = 0
+		}
+		col++
+		si++
+	}
+	// Boundaries at the same offset keep the order in which they were found.
+	sort.Stable(boundariesByPos(boundaries))
+	return
+}
+
+type boundariesByPos []Boundary
+
+func (b boundariesByPos) Len() int           { return len(b) }
+func (b boundariesByPos) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b boundariesByPos) Less(i, j int) bool { return b[i].Offset < b[j].Offset }
@@ -0,0 +1,103 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cover
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// The merge tests concatenate the profiles of two test binaries
+// that both instrument package p, as 'go test -coverpkg' sees them.
+var mergeTests = []struct {
+	mode string
+	in   string
+	out  string
+}{
+	{
+		"set",
+		`p/b.go:1.10,2.2 1 0
+p/a.go:5.20,7.3 2 1
+p/a.go:1.10,3.4 3 1
+p/b.go:1.10,2.2 1 0
+p/a.go:1.10,3.4 3 0
+p/a.go:5.20,7.3 2 1
+`,
+		`mode: set
+p/a.go:1.10,3.4 3 1
+p/a.go:5.20,7.3 2 1
+p/b.go:1.10,2.2 1 0
+`,
+	},
+	{
+		"count",
+		`p/b.go:1.10,2.2 1 0
+p/a.go:5.20,7.3 2 4
+p/a.go:1.10,3.4 3 1
+p/b.go:1.10,2.2 1 0
+p/a.go:1.10,3.4 3 0
+p/a.go:5.20,7.3 2 3
+`,
+		`mode: count
+p/a.go:1.10,3.4 3 1
+p/a.go:5.20,7.3 2 7
+p/b.go:1.10,2.2 1 0
+`,
+	},
+}
+
+func TestMerge(t *testing.T) {
+	for _, tt := range mergeTests {
+		profiles, err := ParseProfilesFromReader(strings.NewReader("mode: " + tt.mode + "\n" + tt.in))
+		if err != nil {
+			t.Errorf("%s: %v", tt.mode, err)
+			continue
+		}
+		var buf bytes.Buffer
+		if err := WriteProfiles(&buf, tt.mode, profiles); err != nil {
+			t.Errorf("%s: %v", tt.mode, err)
+			continue
+		}
+		if buf.String() != tt.out {
+			t.Errorf("%s: merged profile:\n%s\nwant:\n%s", tt.mode, buf.String(), tt.out)
+		}
+	}
+}
+
+var badProfileTests = []string{
+	"p/a.go:1.10,3.4 3 1\n",
+	"mode: set\np/a.go:1.10,3.4 3\n",
+	"mode: set\np/a.go:1.10-3.4 3 1\n",
+	"mode: set\n:1.10,3.4 3 1\n",
+	"mode: set\np/a.go:1.10,3.4 3 1\np/a.go:1.10,3.4 2 1\n",
+}
+
+func TestBadProfile(t *testing.T) {
+	for _, in := range badProfileTests {
+		if _, err := ParseProfilesFromReader(strings.NewReader(in)); err == nil {
+			t.Errorf("ParseProfilesFromReader(%q) succeeded, want error", in)
+		}
+	}
+}
+
+func TestBoundaries(t *testing.T) {
+	src := []byte("package p\n\nfunc f() {\n\tx()\n}\n")
+	p := &Profile{
+		FileName: "p/p.go",
+		Mode:     "set",
+		Blocks:   []ProfileBlock{{StartLine: 3, StartCol: 10, EndLine: 5, EndCol: 2, NumStmt: 1, Count: 1}},
+	}
+	b := p.Boundaries(src)
+	if len(b) != 2 {
+		t.Fatalf("Boundaries returned %d boundaries, want 2", len(b))
+	}
+	if start := string(src[b[0].Offset:]); !b[0].Start || !strings.HasPrefix(start, "{\n\tx()") {
+		t.Errorf("block starts at %q, want %q", start, "{")
+	}
+	if b[1].Start || b[1].Offset != len(src)-1 {
+		t.Errorf("block ends at offset %d, want %d", b[1].Offset, len(src)-1)
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test2json
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/*.json files")
+
+func TestGolden(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".test")
+		orig, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+
+		// Test one line written to c at a time.
+		// Assume that's the most likely to be handled correctly.
+		var buf bytes.Buffer
+		c := NewConverter(&buf, "", 0)
+		in := append([]byte{}, orig...)
+		for _, line := range bytes.SplitAfter(in, []byte("\n")) {
+			writeAndKill(c, line)
+		}
+		c.Close()
+
+		if *update {
+			js := strings.TrimSuffix(file, ".test") + ".json"
+			t.Logf("rewriting %s", js)
+			if err := ioutil.WriteFile(js, buf.Bytes(), 0666); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		want, err := ioutil.ReadFile(strings.TrimSuffix(file, ".test") + ".json")
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		diffJSON(t, name, "line-by-line", buf.Bytes(), want)
+		if t.Failed() {
+			// If the line-at-a-time conversion fails, no point testing boundary conditions.
+			continue
+		}
+
+		// Write entire input in bulk.
+		buf.Reset()
+		c = NewConverter(&buf, "", 0)
+		in = append([]byte{}, orig...)
+		writeAndKill(c, in)
+		c.Close()
+		diffJSON(t, name, "bulk", buf.Bytes(), want)
+
+		// Write 2 bytes at a time on even boundaries.
+		buf.Reset()
+		c = NewConverter(&buf, "", 0)
+		in = append([]byte{}, orig...)
+		for i := 0; i < len(in); i += 2 {
+			if i+2 <= len(in) {
+				writeAndKill(c, in[i:i+2])
+			} else {
+				writeAndKill(c, in[i:])
+			}
+		}
+		c.Close()
+		diffJSON(t, name, "2 bytes", buf.Bytes(), want)
+
+		// Test with very small output buffers, to check that
+		// long lines are split and UTF-8 sequences are kept intact.
+		for b := 5; b <= 8; b++ {
+			buf.Reset()
+			c = NewConverter(&buf, "", 0)
+			c.(*converter).output.b = make([]byte, 0, b)
+			in = append([]byte{}, orig...)
+			writeAndKill(c, in)
+			c.Close()
+			diffJSON(t, name, fmt.Sprintf("%d-byte output buffer", b), buf.Bytes(), want)
+		}
+	}
+}
+
+// writeAndKill writes b to w and then fills b with Zs.
+// The filling makes sure that if w is holding onto b for
+// future use, that future use will have obviously wrong data.
+func writeAndKill(w io.Writer, b []byte) {
+	w.Write(b)
+	for i := range b {
+		b[i] = 'Z'
+	}
+}
+
+// diffJSON diffs the stream we have against the stream we want
+// and fails the test with a useful message if they don't match.
+// Output events are coalesced, so that different splits of the
+// same output compare equal.
+func diffJSON(t *testing.T, name, how string, have, want []byte) {
+	h := parseEvents(t, name, how, have)
+	w := parseEvents(t, name, how, want)
+	if !reflect.DeepEqual(h, w) {
+		t.Errorf("%s (%s): events differ\nhave:\n%s\nwant:\n%s", name, how, formatEvents(h), formatEvents(w))
+	}
+}
+
+type testEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed *float64
+	Output  string
+}
+
+func parseEvents(t *testing.T, name, how string, data []byte) []testEvent {
+	var events []testEvent
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var e testEvent
+		if err := json.Unmarshal(line, &e); err != nil {
+			t.Errorf("%s (%s): invalid JSON %q: %v", name, how, line, err)
+			continue
+		}
+		if !utf8.ValidString(e.Output) {
+			t.Errorf("%s (%s): output %q is not valid UTF-8", name, how, e.Output)
+		}
+		if n := len(events); n > 0 && e.Action == "output" &&
+			events[n-1].Action == "output" && events[n-1].Test == e.Test &&
+			!strings.HasSuffix(events[n-1].Output, "\n") {
+			events[n-1].Output += e.Output
+			continue
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func formatEvents(events []testEvent) string {
+	var buf bytes.Buffer
+	for _, e := range events {
+		js, _ := json.Marshal(e)
+		buf.Write(js)
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+func TestTrimUTF8(t *testing.T) {
+	s := "hello α ☺ 😂 world" // α is 2-byte, ☺ is 3-byte, 😂 is 4-byte
+	b := []byte(s)
+	for i := 0; i < len(s); i++ {
+		j := trimUTF8(b[:i])
+		u := string([]rune(s[:j])) + string([]rune(s[j:]))
+		if u != s {
+			t.Errorf("trimUTF8(%q) = %d (-%d), not at boundary (split: %q %q)", s[:i], j, i-j, s[:j], s[j:])
+		}
+		if utf8.FullRune(b[j:i]) {
+			t.Errorf("trimUTF8(%q) = %d (-%d), too early (missed: %q)", s[:j], j, i-j, s[j:i])
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+
+Vet examines Go source code and reports suspicious constructs, such as Printf
+calls whose arguments do not align with the format string. Vet uses heuristics
+that do not guarantee all reports are genuine problems, but it can find errors
+not caught by the compilers.
+
+It can be invoked three ways:
+
+By package, from the go tool:
+	go vet package/path/name
+vets the package whose path is provided.
+
+By files:
+	go tool vet source/directory/*.go
+vets the files named, all of which must be in the same package.
+
+By directory:
+	go tool vet source/directory
+vets the package in the directory, together with its test files.
+
+Vet's exit code is 2 for erroneous invocation of the tool, 1 if a
+problem was reported, and 0 otherwise. Note that the tool does not
+check every possible problem and depends on unreliable heuristics
+so it should be used as guidance only, not as a firm indicator of
+program correctness.
+
+Each check is an analyzer built on the framework in
+cmd/vet/internal/analysis. Vet type-checks the package, loading its
+dependencies from source, and runs the analyzers over the typed syntax
+trees. Analyzers may record facts about the functions of a package for
+use when checking the packages that import it; for example, the printf
+check learns which functions are wrappers of fmt.Printf.
+
+By default all checks are performed except the experimental ones.
+If any flags are explicitly set to true, only those tests are run.
+Conversely, if any flag is explicitly set to false, only those tests
+are disabled. Thus -printf=true runs the printf check, -printf=false
+runs all checks except the printf check.
+
+Available checks:
+
+Printf family
+
+Flag: -printf
+
+Suspicious calls to functions in the Printf family, including any
+functions, in the package or in the packages it imports, that pass
+their format string and arguments on to one of them. Additional
+function names may be given with -printfuncs:
+	-printfuncs=Warn,Warnf
+Names ending in f are assumed to take a format string; the others
+print their operands. Vet reports format strings that do not match
+their arguments, calls of the non-formatting functions that appear to
+contain formatting directives, function values passed as arguments,
+and calls that would recursively invoke the String method being
+defined.
+
+Copying locks
+
+Flag: -copylocks
+
+Locks that are erroneously passed by value.
+
+Unreachable code
+
+Flag: -unreachable
+
+Unreachable code.
+
+Shadowed variables
+
+Flag: -shadow=false (experimental; must be set explicitly)
+
+Variables that may have been unintentionally shadowed.
+
+Other flags
+
+These flags configure the behavior of vet:
+
+	-v
+		Verbose mode; also report type-checking errors.
+	-printfuncs
+		A comma-separated list of print-like functions to supplement
+		the standard list.
+	-shadowstrict
+		Whether to be strict about shadowing; can be noisy.
+*/
+package main
@@ -0,0 +1,167 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package analysis defines the interface between a modular static
+// analysis and the driver program that applies it.
+//
+// An Analyzer describes an analysis: its name, its documentation, and
+// the function that applies it to a package. The Driver loads and
+// type-checks packages and calls each Analyzer's Run function with a
+// Pass, which provides the syntax trees and type information of one
+// package and the means to report diagnostics.
+//
+// Analyzers may also record facts about the objects of a package for
+// use by analyses of the packages that import it: the printf check, for
+// example, records which functions are wrappers of fmt.Printf, so that
+// calls to those wrappers in other packages can be checked too.
+// The Driver applies analyzers that use facts to the dependencies of the
+// packages being analyzed, in dependency order, discarding their
+// diagnostics.
+package analysis
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"reflect"
+)
+
+// An Analyzer describes an analysis function and its options.
+type Analyzer struct {
+	// Name is the name of the analyzer. It must be a valid Go
+	// identifier, as it is used as the name of a command-line flag.
+	Name string
+
+	// Doc is the documentation for the analyzer.
+	// Its first line is a one-sentence summary.
+	Doc string
+
+	// Run applies the analyzer to a package.
+	// It reports diagnostics by calling pass.Report or pass.Reportf.
+	// A non-nil error indicates a failure of the analysis itself,
+	// not a problem in the code being analyzed.
+	Run func(pass *Pass) error
+
+	// FactTypes lists the types of facts the analyzer imports and
+	// exports, each represented by a pointer to its zero value.
+	// An analyzer with facts is also applied to the dependencies
+	// of the packages being analyzed.
+	FactTypes []Fact
+}
+
+func (a *Analyzer) String() string { return a.Name }
+
+// A Pass provides information to the Run function that applies
+// a specific analyzer to a single package.
+type Pass struct {
+	Analyzer *Analyzer // the analyzer being applied
+
+	Fset      *token.FileSet // file position information
+	Files     []*ast.File    // the syntax trees of the package
+	Pkg       *types.Package // type information about the package
+	TypesInfo *types.Info    // type information about the syntax trees
+
+	// Report reports a diagnostic about the package.
+	Report func(Diagnostic)
+
+	facts *factTable
+}
+
+// Reportf reports a diagnostic at pos, formatting the message
+// in the manner of fmt.Sprintf.
+func (pass *Pass) Reportf(pos token.Pos, format string, args ...interface{}) {
+	pass.Report(Diagnostic{Pos: pos, Message: fmt.Sprintf(format, args...)})
+}
+
+// A Diagnostic is a message associated with a source location.
+type Diagnostic struct {
+	Pos      token.Pos
+	Category string // name of the analyzer that reported it; set by the Driver
+	Message  string
+}
+
+// A Fact is an intermediate fact produced during analysis.
+//
+// Each fact is associated with a named object (such as a function) or
+// with a package, and is available to later analyses of the packages
+// that import it. A fact type must be a pointer type; its AFact method
+// does nothing and only marks the type as a fact.
+type Fact interface {
+	AFact()
+}
+
+// A factKey identifies a fact: its type and the object or
+// package it describes.
+type factKey struct {
+	obj types.Object   // nil for package facts
+	pkg *types.Package // nil for object facts
+	t   reflect.Type
+}
+
+// A factTable holds the facts exported by all passes of a Driver.
+type factTable struct {
+	m map[factKey]Fact
+}
+
+func newFactTable() *factTable {
+	return &factTable{m: make(map[factKey]Fact)}
+}
+
+// ImportObjectFact retrieves the fact of the same type as fact
+// associated with obj, copying it into *fact.
+// It reports whether such a fact exists.
+func (pass *Pass) ImportObjectFact(obj types.Object, fact Fact) bool {
+	if obj == nil {
+		panic("nil object")
+	}
+	return pass.importFact(factKey{obj: obj, t: pass.factType(fact)}, fact)
+}
+
+// ExportObjectFact associates fact with obj, which must be declared
+// at package level (or be a method) in the package being analyzed.
+func (pass *Pass) ExportObjectFact(obj types.Object, fact Fact) {
+	if obj.Pkg() != pass.Pkg {
+		panic(fmt.Sprintf("%s: exporting fact about %s, which is not in package %s", pass.Analyzer, obj, pass.Pkg.Path()))
+	}
+	pass.facts.m[factKey{obj: obj, t: pass.factType(fact)}] = fact
+}
+
+// ImportPackageFact retrieves the fact of the same type as fact
+// associated with pkg, copying it into *fact.
+// It reports whether such a fact exists.
+func (pass *Pass) ImportPackageFact(pkg *types.Package, fact Fact) bool {
+	if pkg == nil {
+		panic("nil package")
+	}
+	return pass.importFact(factKey{pkg: pkg, t: pass.factType(fact)}, fact)
+}
+
+// ExportPackageFact associates fact with the package being analyzed.
+func (pass *Pass) ExportPackageFact(fact Fact) {
+	pass.facts.m[factKey{pkg: pass.Pkg, t: pass.factType(fact)}] = fact
+}
+
+func (pass *Pass) importFact(key factKey, fact Fact) bool {
+	v, ok := pass.facts.m[key]
+	if ok {
+		reflect.ValueOf(fact).Elem().Set(reflect.ValueOf(v).Elem())
+	}
+	return ok
+}
+
+// factType returns the type of fact, which must be a pointer type
+// listed in the FactTypes of the pass's analyzer.
+func (pass *Pass) factType(fact Fact) reflect.Type {
+	t := reflect.TypeOf(fact)
+	if t == nil || t.Kind() != reflect.Ptr {
+		panic(fmt.Sprintf("%s: invalid fact type %T", pass.Analyzer, fact))
+	}
+	for _, f := range pass.Analyzer.FactTypes {
+		if reflect.TypeOf(f) == t {
+			return t
+		}
+	}
+	panic(fmt.Sprintf("%s: fact type %s not declared in FactTypes", pass.Analyzer, t))
+}
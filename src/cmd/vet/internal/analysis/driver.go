@@ -0,0 +1,233 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A Driver loads packages from source, type-checks them, and applies
+// a set of analyzers to them.
+//
+// Dependencies are loaded through go/build, the same way the go command
+// finds them, and are type-checked from source. Analyzers that use facts
+// are applied to each dependency as soon as it has been type-checked,
+// so the facts about a package are always available to the analysis
+// of the packages that import it.
+type Driver struct {
+	// Analyzers lists the analyzers to apply.
+	Analyzers []*Analyzer
+
+	// Context is the build context used to find packages.
+	// If nil, build.Default is used.
+	Context *build.Context
+
+	// Fset records the positions of all files loaded by the driver.
+	// If nil, Analyze allocates a new file set.
+	Fset *token.FileSet
+
+	// If Error != nil, it is called with each type-checking error
+	// found in the packages being analyzed. Errors in their
+	// dependencies are ignored, as they are by the analyzers:
+	// analysis proceeds with whatever type information is available.
+	Error func(err error)
+
+	facts    *factTable
+	loaded   map[string]*loadedPackage // by import path
+	testPath string                    // import path of package loaded with its test files
+}
+
+// A loadedPackage is a dependency loaded by the driver.
+type loadedPackage struct {
+	pkg *types.Package // nil while the package is being loaded
+	err error
+}
+
+// Analyze applies the driver's analyzers to the package made up of
+// the named files in dir, which must all belong to the same package,
+// and returns the resulting diagnostics sorted by position.
+//
+// If the package is an external test package (its name ends in _test),
+// the package it tests is loaded together with its internal test files.
+func (d *Driver) Analyze(dir string, filenames []string) ([]Diagnostic, error) {
+	if d.Fset == nil {
+		d.Fset = token.NewFileSet()
+	}
+	if d.facts == nil {
+		d.facts = newFactTable()
+	}
+
+	var files []*ast.File
+	for _, name := range filenames {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		f, err := parser.ParseFile(d.Fset, name, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) > 0 && f.Name.Name != files[0].Name.Name {
+			return nil, fmt.Errorf("%s: package %s; expected %s", name, f.Name.Name, files[0].Name.Name)
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+
+	path := files[0].Name.Name
+	testPath := ""
+	if bp, err := d.context().ImportDir(dir, 0); err == nil && bp.ImportPath != "." {
+		path = bp.ImportPath
+		if strings.HasSuffix(files[0].Name.Name, "_test") {
+			testPath = path
+			path += "_test"
+		}
+	}
+	if d.loaded == nil || testPath != d.testPath {
+		// Packages loaded for a different test variant may
+		// refer to the wrong version of the package under test.
+		d.loaded = make(map[string]*loadedPackage)
+		d.testPath = testPath
+	}
+
+	conf := types.Config{
+		FakeImportC: true,
+		Importer:    &importer{d, dir},
+		Error: func(err error) {
+			if d.Error != nil {
+				d.Error(err)
+			}
+		},
+	}
+	info := newInfo()
+	pkg, _ := conf.Check(path, d.Fset, files, info)
+	return d.apply(pkg, files, info, true)
+}
+
+func (d *Driver) context() *build.Context {
+	if d.Context != nil {
+		return d.Context
+	}
+	return &build.Default
+}
+
+func newInfo() *types.Info {
+	return &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:     make(map[ast.Node]*types.Scope),
+	}
+}
+
+// apply applies the analyzers to a type-checked package.
+// If report is false, the package is a dependency: only the
+// analyzers that use facts are applied and their diagnostics
+// are discarded.
+func (d *Driver) apply(pkg *types.Package, files []*ast.File, info *types.Info, report bool) ([]Diagnostic, error) {
+	var diags []Diagnostic
+	for _, a := range d.Analyzers {
+		if !report && len(a.FactTypes) == 0 {
+			continue
+		}
+		a := a
+		pass := &Pass{
+			Analyzer:  a,
+			Fset:      d.Fset,
+			Files:     files,
+			Pkg:       pkg,
+			TypesInfo: info,
+			Report: func(diag Diagnostic) {
+				if report {
+					diag.Category = a.Name
+					diags = append(diags, diag)
+				}
+			},
+			facts: d.facts,
+		}
+		if err := a.Run(pass); err != nil {
+			return nil, fmt.Errorf("analyzing %s: %s: %v", pkg.Path(), a.Name, err)
+		}
+	}
+	sort.Sort(byPos(diags))
+	return diags, nil
+}
+
+type byPos []Diagnostic
+
+func (x byPos) Len() int      { return len(x) }
+func (x byPos) Swap(i, j int) { x[i], x[j] = x[j], x[i] }
+func (x byPos) Less(i, j int) bool {
+	if x[i].Pos != x[j].Pos {
+		return x[i].Pos < x[j].Pos
+	}
+	return x[i].Message < x[j].Message
+}
+
+// An importer loads the imports of the package in dir.
+type importer struct {
+	d   *Driver
+	dir string
+}
+
+func (imp *importer) Import(path string) (*types.Package, error) {
+	return imp.d.load(path, imp.dir)
+}
+
+// load loads, type-checks and analyzes the package imported
+// as path by a package in srcDir.
+func (d *Driver) load(path, srcDir string) (*types.Package, error) {
+	bp, err := d.context().Import(path, srcDir, 0)
+	if err != nil {
+		return nil, err
+	}
+	if lp := d.loaded[bp.ImportPath]; lp != nil {
+		if lp.pkg == nil && lp.err == nil {
+			return nil, fmt.Errorf("import cycle through package %s", bp.ImportPath)
+		}
+		return lp.pkg, lp.err
+	}
+	lp := new(loadedPackage)
+	d.loaded[bp.ImportPath] = lp
+
+	filenames := append(append([]string{}, bp.GoFiles...), bp.CgoFiles...)
+	if bp.ImportPath == d.testPath {
+		filenames = append(filenames, bp.TestGoFiles...)
+	}
+	var files []*ast.File
+	for _, name := range filenames {
+		f, err := parser.ParseFile(d.Fset, filepath.Join(bp.Dir, name), nil, parser.ParseComments)
+		if err != nil {
+			lp.err = err
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	conf := types.Config{
+		FakeImportC: true,
+		Importer:    &importer{d, bp.Dir},
+		Error:       func(error) {},
+	}
+	info := newInfo()
+	pkg, _ := conf.Check(bp.ImportPath, d.Fset, files, info)
+	if _, err := d.apply(pkg, files, info, false); err != nil {
+		lp.err = err
+		return nil, err
+	}
+	lp.pkg = pkg
+	return pkg, nil
+}
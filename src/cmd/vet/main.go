@@ -0,0 +1,251 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/build"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"cmd/vet/internal/analysis"
+)
+
+var verbose = flag.Bool("v", false, "verbose")
+
+var exitCode = 0
+
+// analyzers lists the checks run by vet.
+var analyzers = []*analysis.Analyzer{
+	copylocksAnalyzer,
+	printfAnalyzer,
+	shadowAnalyzer,
+	unreachableAnalyzer,
+}
+
+// experimental records the checks that are run only when
+// requested by their flag.
+var experimental = map[string]bool{
+	"shadow": true,
+}
+
+// report records the state of each check's flag.
+var report = map[string]*triState{}
+
+// triState is a boolean that knows whether it has been set to either true or false.
+// It is used to identify if a flag appears; the standard boolean flag cannot
+// distinguish missing from unset. It also satisfies flag.Value.
+type triState int
+
+const (
+	unset triState = iota
+	setTrue
+	setFalse
+)
+
+func triStateFlag(name string, value triState, usage string) *triState {
+	flag.Var(&value, name, usage)
+	return &value
+}
+
+func (ts *triState) Get() interface{} {
+	return *ts == setTrue
+}
+
+func (ts *triState) Set(value string) error {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	if b {
+		*ts = setTrue
+	} else {
+		*ts = setFalse
+	}
+	return nil
+}
+
+func (ts *triState) String() string {
+	switch *ts {
+	case unset:
+		return "unset"
+	case setTrue:
+		return "true"
+	case setFalse:
+		return "false"
+	}
+	panic("not reached")
+}
+
+func (ts triState) IsBoolFlag() bool {
+	return true
+}
+
+// enabledAnalyzers returns the analyzers selected by the flags.
+// If any check is explicitly enabled, only the enabled checks run;
+// otherwise all checks run except the experimental ones and those
+// explicitly disabled.
+func enabledAnalyzers() []*analysis.Analyzer {
+	anySet := false
+	for _, ts := range report {
+		if *ts == setTrue {
+			anySet = true
+		}
+	}
+	var list []*analysis.Analyzer
+	for _, a := range analyzers {
+		switch *report[a.Name] {
+		case setTrue:
+			list = append(list, a)
+		case unset:
+			if !anySet && !experimental[a.Name] {
+				list = append(list, a)
+			}
+		}
+	}
+	return list
+}
+
+// Usage is a replacement usage function for the flags package.
+func Usage() {
+	fmt.Fprintf(os.Stderr, "Usage of vet:\n")
+	fmt.Fprintf(os.Stderr, "\tvet [flags] directory...\n")
+	fmt.Fprintf(os.Stderr, "\tvet [flags] files... # Must be a single package\n")
+	fmt.Fprintf(os.Stderr, "For more information run\n")
+	fmt.Fprintf(os.Stderr, "\tgo doc cmd/vet\n\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	for _, a := range analyzers {
+		summary := a.Doc
+		if i := strings.Index(summary, "\n"); i >= 0 {
+			summary = summary[:i]
+		}
+		report[a.Name] = triStateFlag(a.Name, unset, summary)
+	}
+	flag.Usage = Usage
+	flag.Parse()
+	initPrintFlags()
+
+	if flag.NArg() == 0 {
+		Usage()
+	}
+
+	d := &analysis.Driver{
+		Analyzers: enabledAnalyzers(),
+		Error: func(err error) {
+			// Type-checking errors are reported only in verbose mode;
+			// the checks use whatever type information is available.
+			if *verbose {
+				warnf("%v", err)
+			}
+		},
+	}
+
+	dirs := false
+	files := false
+	for _, name := range flag.Args() {
+		// Is it a directory?
+		fi, err := os.Stat(name)
+		if err != nil {
+			warnf("error walking tree: %s", err)
+			continue
+		}
+		if fi.IsDir() {
+			dirs = true
+		} else {
+			files = true
+		}
+	}
+	if dirs && files {
+		Usage()
+	}
+	if dirs {
+		for _, name := range flag.Args() {
+			doPackageDir(d, name)
+		}
+		os.Exit(exitCode)
+	}
+	dir := filepath.Dir(flag.Arg(0))
+	var names []string
+	for _, name := range flag.Args() {
+		if filepath.Dir(name) != dir {
+			errorf("%s and %s are in different directories", flag.Arg(0), name)
+			os.Exit(exitCode)
+		}
+		if !strings.HasSuffix(name, ".go") {
+			// Vet checks only Go source files.
+			continue
+		}
+		names = append(names, filepath.Base(name))
+	}
+	doPackage(d, dir, names)
+	os.Exit(exitCode)
+}
+
+// doPackageDir analyzes the package in the directory, first with
+// its internal test files, then its external test package, if any.
+func doPackageDir(d *analysis.Driver, dir string) {
+	pkg, err := build.ImportDir(dir, 0)
+	if err != nil {
+		// If it's just that there are no go source files, that's fine.
+		if _, nogo := err.(*build.NoGoError); nogo {
+			return
+		}
+		// Non-fatal: there may be other directories.
+		warnf("cannot process directory %s: %s", dir, err)
+		return
+	}
+	var names []string
+	names = append(names, pkg.GoFiles...)
+	names = append(names, pkg.CgoFiles...)
+	names = append(names, pkg.TestGoFiles...) // These are also in the "foo" package.
+	if len(names) > 0 {
+		doPackage(d, dir, names)
+	}
+	if len(pkg.XTestGoFiles) > 0 {
+		doPackage(d, dir, pkg.XTestGoFiles)
+	}
+}
+
+// doPackage analyzes the single package constructed from the named files
+// and prints the diagnostics.
+func doPackage(d *analysis.Driver, dir string, names []string) {
+	diags, err := d.Analyze(dir, names)
+	if err != nil {
+		errorf("%v", err)
+		return
+	}
+	for _, diag := range diags {
+		posn := d.Fset.Position(diag.Pos)
+		fmt.Fprintf(os.Stderr, "%s:%d: %s\n", posn.Filename, posn.Line, diag.Message)
+		setExit(1)
+	}
+}
+
+func setExit(err int) {
+	if err > exitCode {
+		exitCode = err
+	}
+}
+
+// errorf formats the error to standard error, adding program
+// identification and a newline, and sets the exit status.
+func errorf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "vet: "+format+"\n", args...)
+	setExit(1)
+}
+
+// warnf formats the error to standard error, adding program
+// identification and a newline, but does not exit.
+func warnf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "vet: "+format+"\n", args...)
+}
@@ -0,0 +1,844 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains the printf-checker.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"go/ast"
+	"go/exact"
+	"go/token"
+	"go/types"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"cmd/vet/internal/analysis"
+)
+
+var printfuncs = flag.String("printfuncs", "", "comma-separated list of print function names to check")
+
+var printfAnalyzer = &analysis.Analyzer{
+	Name: "printf",
+	Doc: `check consistency of Printf format strings and arguments
+
+The check applies to calls of the formatting functions such as
+fmt.Printf and fmt.Sprintf, and to any function found to be a wrapper
+of one of them, in this package or in a package it imports. A
+function is a wrapper if its final parameters are format string and
+args ...interface{} and it passes them on to a formatting function.
+Additional functions may be named with the -printfuncs flag.`,
+	Run:       checkPrintf,
+	FactTypes: []analysis.Fact{new(isWrapper)},
+}
+
+// A funcKind describes how a function formats its arguments.
+type funcKind int
+
+const (
+	kindNone   funcKind = iota
+	kindPrintf          // like fmt.Printf: format string and arguments
+	kindPrint           // like fmt.Print: operands only
+)
+
+// isWrapper is a fact recording that a function is a wrapper
+// of a print or printf function.
+type isWrapper struct {
+	Kind funcKind
+}
+
+func (*isWrapper) AFact() {}
+
+// isPrint records the formatting functions of package fmt.
+// Their wrappers are found by analysis.
+var isPrint = map[string]funcKind{
+	"Errorf":   kindPrintf,
+	"Fprintf":  kindPrintf,
+	"Printf":   kindPrintf,
+	"Sprintf":  kindPrintf,
+	"Fprint":   kindPrint,
+	"Fprintln": kindPrint,
+	"Print":    kindPrint,
+	"Println":  kindPrint,
+	"Sprint":   kindPrint,
+	"Sprintln": kindPrint,
+}
+
+// extraPrint records the functions named by the -printfuncs flag,
+// by lower-case name. Names ending in f are printf-like.
+var extraPrint map[string]funcKind
+
+func initPrintFlags() {
+	extraPrint = make(map[string]funcKind)
+	if *printfuncs == "" {
+		return
+	}
+	for _, name := range strings.Split(*printfuncs, ",") {
+		if len(name) == 0 {
+			flag.Usage()
+		}
+		name = strings.ToLower(name)
+		if strings.HasSuffix(name, "f") {
+			extraPrint[name] = kindPrintf
+		} else {
+			extraPrint[name] = kindPrint
+		}
+	}
+}
+
+// A printfChecker holds the state of the printf check of one package.
+type printfChecker struct {
+	pass *analysis.Pass
+	recv types.Object // receiver of the enclosing String or Error method, if any
+}
+
+func checkPrintf(pass *analysis.Pass) error {
+	findPrintfWrappers(pass)
+	c := &printfChecker{pass: pass}
+	for _, file := range pass.Files {
+		for _, decl := range file.Decls {
+			c.recv = nil
+			if fdecl, ok := decl.(*ast.FuncDecl); ok {
+				c.recv = stringerReceiver(pass, fdecl)
+			}
+			ast.Inspect(decl, func(n ast.Node) bool {
+				if call, ok := n.(*ast.CallExpr); ok {
+					c.checkCall(call)
+				}
+				return true
+			})
+		}
+	}
+	return nil
+}
+
+// stringerReceiver returns the receiver of fdecl if it is
+// a String or Error method, and nil otherwise.
+func stringerReceiver(pass *analysis.Pass, fdecl *ast.FuncDecl) types.Object {
+	if fdecl.Recv == nil || len(fdecl.Recv.List) == 0 || len(fdecl.Recv.List[0].Names) == 0 {
+		return nil
+	}
+	if name := fdecl.Name.Name; name != "String" && name != "Error" {
+		return nil
+	}
+	return pass.TypesInfo.Defs[fdecl.Recv.List[0].Names[0]]
+}
+
+// findPrintfWrappers records a fact for each function in the package
+// that passes its format and arguments on to a print or printf function.
+// It iterates to a fixed point, since a wrapper may call another wrapper
+// declared later in the package.
+func findPrintfWrappers(pass *analysis.Pass) {
+	for changed := true; changed; {
+		changed = false
+		for _, file := range pass.Files {
+			for _, decl := range file.Decls {
+				fdecl, ok := decl.(*ast.FuncDecl)
+				if !ok || fdecl.Body == nil {
+					continue
+				}
+				fn, ok := pass.TypesInfo.Defs[fdecl.Name].(*types.Func)
+				if !ok {
+					continue
+				}
+				var fact isWrapper
+				if pass.ImportObjectFact(fn, &fact) {
+					continue
+				}
+				if kind := wrapperKind(pass, fn, fdecl.Body); kind != kindNone {
+					pass.ExportObjectFact(fn, &isWrapper{kind})
+					changed = true
+				}
+			}
+		}
+	}
+}
+
+// wrapperKind reports whether fn, with the given body, forwards its
+// final parameters format string and args ...interface{} (or just args)
+// to a printf (or print) function.
+func wrapperKind(pass *analysis.Pass, fn *types.Func, body *ast.BlockStmt) funcKind {
+	sig := fn.Type().(*types.Signature)
+	if !sig.Variadic() {
+		return kindNone
+	}
+	params := sig.Params()
+	args := params.At(params.Len() - 1)
+	if iface, ok := args.Type().(*types.Slice).Elem().Underlying().(*types.Interface); !ok || iface.NumMethods() != 0 {
+		return kindNone
+	}
+	var format *types.Var
+	if params.Len() >= 2 {
+		if p := params.At(params.Len() - 2); types.Identical(p.Type(), types.Typ[types.String]) {
+			format = p
+		}
+	}
+
+	kind := kindNone
+	modified := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		if modified {
+			return false
+		}
+		if as, ok := n.(*ast.AssignStmt); ok {
+			// A function that changes its format or arguments,
+			// or an element of its arguments, is not a simple wrapper.
+			for _, lhs := range as.Lhs {
+				if index, ok := lhs.(*ast.IndexExpr); ok {
+					lhs = index.X
+				}
+				if id, ok := lhs.(*ast.Ident); ok {
+					if obj := pass.TypesInfo.Uses[id]; obj != nil && (obj == args || obj == format) {
+						modified = true
+					}
+				}
+			}
+			return true
+		}
+		call, ok := n.(*ast.CallExpr)
+		if !ok || kind != kindNone || !call.Ellipsis.IsValid() {
+			return true
+		}
+		if id, ok := call.Args[len(call.Args)-1].(*ast.Ident); !ok || pass.TypesInfo.Uses[id] != args {
+			return true
+		}
+		switch printKind(pass, calleeFunc(pass, call)) {
+		case kindPrint:
+			kind = kindPrint
+		case kindPrintf:
+			if format == nil || len(call.Args) < 2 {
+				break
+			}
+			if id, ok := call.Args[len(call.Args)-2].(*ast.Ident); ok && pass.TypesInfo.Uses[id] == format {
+				kind = kindPrintf
+			}
+		}
+		return true
+	})
+	if modified {
+		return kindNone
+	}
+	return kind
+}
+
+// calleeFunc returns the function or method called by call,
+// or nil if it is not a statically known function.
+func calleeFunc(pass *analysis.Pass, call *ast.CallExpr) *types.Func {
+	var id *ast.Ident
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	default:
+		return nil
+	}
+	fn, _ := pass.TypesInfo.Uses[id].(*types.Func)
+	return fn
+}
+
+// printKind reports how fn formats its arguments.
+func printKind(pass *analysis.Pass, fn *types.Func) funcKind {
+	if fn == nil {
+		return kindNone
+	}
+	sig := fn.Type().(*types.Signature)
+	if fn.Pkg() != nil && fn.Pkg().Path() == "fmt" && sig.Recv() == nil {
+		if kind, ok := isPrint[fn.Name()]; ok {
+			return kind
+		}
+	}
+	var fact isWrapper
+	if pass.ImportObjectFact(fn, &fact) {
+		return fact.Kind
+	}
+	if kind, ok := extraPrint[strings.ToLower(fn.Name())]; ok && sig.Variadic() {
+		return kind
+	}
+	return kindNone
+}
+
+// checkCall checks a call to a print or printf function.
+func (c *printfChecker) checkCall(call *ast.CallExpr) {
+	fn := calleeFunc(c.pass, call)
+	switch printKind(c.pass, fn) {
+	case kindPrintf:
+		c.checkPrintfCall(call, fn)
+	case kindPrint:
+		c.checkPrintCall(call, fn)
+	}
+}
+
+// formatState holds the parsed representation of a printf directive such as "%3.*[4]d".
+// It is constructed by parsePrintfVerb.
+type formatState struct {
+	verb     rune   // the format verb: 'd' for "%d"
+	format   string // the full format directive from % through verb, "%.3d".
+	name     string // Printf, Sprintf etc.
+	flags    []byte // the list of # + etc.
+	argNums  []int  // the successive argument numbers that are consumed, adjusted to refer to actual arg in call
+	indexed  bool   // whether an indexing expression appears: %[1]d.
+	firstArg int    // Index of first argument after the format in the Printf call.
+	// Used only during parse.
+	c      *printfChecker
+	call   *ast.CallExpr
+	argNum int // Which argument we're expecting to format now.
+	nbytes int // number of bytes of the format string consumed.
+}
+
+// checkPrintfCall checks a call to a printf-like function.
+func (c *printfChecker) checkPrintfCall(call *ast.CallExpr, fn *types.Func) {
+	name := fn.Name()
+	sig := fn.Type().(*types.Signature)
+	formatIndex := sig.Params().Len() - 2
+	if formatIndex < 0 || formatIndex >= len(call.Args) {
+		return
+	}
+	tv := c.pass.TypesInfo.Types[call.Args[formatIndex]]
+	if tv.Value == nil || tv.Value.Kind() != exact.String {
+		// Format string is not a constant; we can't check it.
+		return
+	}
+	format := exact.StringVal(tv.Value)
+	firstArg := formatIndex + 1 // Arguments are immediately after format string.
+	if !strings.Contains(format, "%") {
+		if len(call.Args) > firstArg {
+			c.pass.Reportf(call.Lparen, "%s call has arguments but no formatting directives", name)
+		}
+		return
+	}
+	// Hard part: check formats against args.
+	argNum := firstArg
+	indexed := false
+	for i, w := 0, 0; i < len(format); i += w {
+		w = 1
+		if format[i] != '%' {
+			continue
+		}
+		state := c.parsePrintfVerb(call, name, format[i:], firstArg, argNum)
+		if state == nil {
+			return
+		}
+		w = len(state.format)
+		if state.indexed {
+			indexed = true
+		}
+		if !c.okPrintfArg(call, state) { // One error per format is enough.
+			return
+		}
+		if len(state.argNums) > 0 {
+			// Continue with the next sequential argument.
+			argNum = state.argNums[len(state.argNums)-1] + 1
+		}
+	}
+	// Dotdotdot is hard.
+	if call.Ellipsis.IsValid() && argNum >= len(call.Args)-1 {
+		return
+	}
+	// If any formats are indexed, extra arguments are ignored.
+	if indexed {
+		return
+	}
+	// There should be no leftover arguments.
+	if argNum != len(call.Args) {
+		expect := argNum - firstArg
+		numArgs := len(call.Args) - firstArg
+		c.pass.Reportf(call.Pos(), "wrong number of args for format in %s call: %d needed but %d args", name, expect, numArgs)
+	}
+}
+
+// parseFlags accepts any printf flags.
+func (s *formatState) parseFlags() {
+	for s.nbytes < len(s.format) {
+		switch c := s.format[s.nbytes]; c {
+		case '#', '0', '+', '-', ' ':
+			s.flags = append(s.flags, c)
+			s.nbytes++
+		default:
+			return
+		}
+	}
+}
+
+// scanNum advances through a decimal number if present.
+func (s *formatState) scanNum() {
+	for ; s.nbytes < len(s.format); s.nbytes++ {
+		c := s.format[s.nbytes]
+		if c < '0' || '9' < c {
+			return
+		}
+	}
+}
+
+// parseIndex scans an index expression. It returns false if there is a syntax error.
+func (s *formatState) parseIndex() bool {
+	if s.nbytes == len(s.format) || s.format[s.nbytes] != '[' {
+		return true
+	}
+	// Argument index present.
+	s.indexed = true
+	s.nbytes++ // skip '['
+	start := s.nbytes
+	s.scanNum()
+	if s.nbytes == len(s.format) || s.nbytes == start || s.format[s.nbytes] != ']' {
+		s.c.pass.Reportf(s.call.Pos(), "illegal syntax for printf argument index")
+		return false
+	}
+	arg32, err := strconv.ParseInt(s.format[start:s.nbytes], 10, 32)
+	if err != nil {
+		s.c.pass.Reportf(s.call.Pos(), "illegal syntax for printf argument index: %v", err)
+		return false
+	}
+	if arg32 < 1 {
+		s.c.pass.Reportf(s.call.Pos(), "index value [%d] for %s; indexes start at 1", arg32, s.name)
+		return false
+	}
+	s.nbytes++                             // skip ']'
+	s.argNum = int(arg32) + s.firstArg - 1 // We want to zero-index the actual arguments.
+	return true
+}
+
+// parseNum scans a width or precision (or *).
+func (s *formatState) parseNum() {
+	if s.nbytes < len(s.format) && s.format[s.nbytes] == '*' {
+		s.nbytes++
+		s.argNums = append(s.argNums, s.argNum)
+		s.argNum++
+	} else {
+		s.scanNum()
+	}
+}
+
+// parsePrecision scans for a precision. It returns false if there's a bad index expression.
+func (s *formatState) parsePrecision() bool {
+	// If there's a period, there may be a precision.
+	if s.nbytes < len(s.format) && s.format[s.nbytes] == '.' {
+		s.flags = append(s.flags, '.') // Treat precision as a flag.
+		s.nbytes++
+		if !s.parseIndex() {
+			return false
+		}
+		s.parseNum()
+	}
+	return true
+}
+
+// parsePrintfVerb looks the formatting directive that begins the format string
+// and returns a formatState that encodes what the directive wants, without looking
+// at the actual arguments present in the call. The result is nil if there is an error.
+func (c *printfChecker) parsePrintfVerb(call *ast.CallExpr, name, format string, firstArg, argNum int) *formatState {
+	state := &formatState{
+		format:   format,
+		name:     name,
+		flags:    make([]byte, 0, 5),
+		argNum:   argNum,
+		argNums:  make([]int, 0, 1),
+		nbytes:   1, // There's guaranteed to be a percent sign.
+		firstArg: firstArg,
+		c:        c,
+		call:     call,
+	}
+	// There may be flags.
+	state.parseFlags()
+	// There may be an index.
+	if !state.parseIndex() {
+		return nil
+	}
+	// There may be a width.
+	state.parseNum()
+	// There may be a precision.
+	if !state.parsePrecision() {
+		return nil
+	}
+	// Now a verb, possibly prefixed by an index.
+	if !state.parseIndex() {
+		return nil
+	}
+	if state.nbytes == len(state.format) {
+		c.pass.Reportf(call.Pos(), "missing verb at end of format string in %s call", name)
+		return nil
+	}
+	verb, w := utf8.DecodeRuneInString(state.format[state.nbytes:])
+	state.verb = verb
+	state.nbytes += w
+	if verb != '%' {
+		state.argNums = append(state.argNums, state.argNum)
+	}
+	state.format = state.format[:state.nbytes]
+	return state
+}
+
+// printfArgType encodes the types of expressions a printf verb accepts. It is a bitmask.
+type printfArgType int
+
+const (
+	argBool printfArgType = 1 << iota
+	argInt
+	argRune
+	argString
+	argFloat
+	argComplex
+	argPointer
+	anyType printfArgType = ^0
+)
+
+type printVerb struct {
+	verb  rune   // User may provide verb through Formatter; could be a rune.
+	flags string // known flags are all ASCII
+	typ   printfArgType
+}
+
+// Common flag sets for printf verbs.
+const (
+	noFlag       = ""
+	numFlag      = " -+.0"
+	sharpNumFlag = " -+.0#"
+	allFlags     = " -+.0#"
+)
+
+// printVerbs identifies which flags are known to printf for each verb.
+// TODO: A type that implements Formatter may do what it wants, and vet
+// will complain incorrectly.
+var printVerbs = []printVerb{
+	// '-' is a width modifier, always valid.
+	// '.' is a precision for float, max width for strings.
+	// '+' is required sign for numbers, Go format for %v.
+	// '#' is alternate format for several verbs.
+	// ' ' is spacer for numbers
+	{'%', noFlag, 0},
+	{'b', numFlag, argInt | argFloat | argComplex},
+	{'c', "-", argRune | argInt},
+	{'d', numFlag, argInt},
+	{'e', numFlag, argFloat | argComplex},
+	{'E', numFlag, argFloat | argComplex},
+	{'f', numFlag, argFloat | argComplex},
+	{'F', numFlag, argFloat | argComplex},
+	{'g', numFlag, argFloat | argComplex},
+	{'G', numFlag, argFloat | argComplex},
+	{'o', sharpNumFlag, argInt},
+	{'p', "-#", argPointer},
+	{'q', " -+.0#", argRune | argInt | argString},
+	{'s', " -+.0", argString},
+	{'t', "-", argBool},
+	{'T', "-", anyType},
+	{'U', "-#", argRune | argInt},
+	{'v', allFlags, anyType},
+	{'x', sharpNumFlag, argRune | argInt | argString},
+	{'X', sharpNumFlag, argRune | argInt | argString},
+}
+
+// okPrintfArg compares the formatState to the arguments actually present,
+// reporting any discrepancies it can discern. If the final argument is ellipsissed,
+// there's little it can do for that.
+func (c *printfChecker) okPrintfArg(call *ast.CallExpr, state *formatState) bool {
+	var v printVerb
+	found := false
+	// Linear scan is fast enough for a small list.
+	for _, v = range printVerbs {
+		if v.verb == state.verb {
+			found = true
+			break
+		}
+	}
+	if !found {
+		c.pass.Reportf(call.Pos(), "unrecognized printf verb %q", state.verb)
+		return false
+	}
+	for _, flag := range state.flags {
+		if !strings.ContainsRune(v.flags, rune(flag)) {
+			c.pass.Reportf(call.Pos(), "unrecognized printf flag for verb %q: %q", state.verb, flag)
+			return false
+		}
+	}
+	// Verb is good. If len(state.argNums)>trueArgs, we have something like %.*s and all
+	// but the final arg must be an integer.
+	trueArgs := 1
+	if state.verb == '%' {
+		trueArgs = 0
+	}
+	nargs := len(state.argNums)
+	for i := 0; i < nargs-trueArgs; i++ {
+		argNum := state.argNums[i]
+		if !c.argCanBeChecked(call, i, state) {
+			return false
+		}
+		arg := call.Args[argNum]
+		if !c.matchArgType(argInt, arg) {
+			c.pass.Reportf(call.Pos(), "arg %s for * in printf format not of type int", types.ExprString(arg))
+			return false
+		}
+	}
+	if state.verb == '%' {
+		return true
+	}
+	argNum := state.argNums[len(state.argNums)-1]
+	if !c.argCanBeChecked(call, len(state.argNums)-1, state) {
+		return false
+	}
+	arg := call.Args[argNum]
+	if c.isFunctionValue(arg) && state.verb != 'p' && state.verb != 'T' {
+		c.pass.Reportf(call.Pos(), "arg %s in printf call is a function value, not a function call", types.ExprString(arg))
+		return false
+	}
+	if !c.matchArgType(v.typ, arg) {
+		typeString := ""
+		if typ := c.pass.TypesInfo.TypeOf(arg); typ != nil {
+			typeString = typ.String()
+		}
+		c.pass.Reportf(call.Pos(), "arg %s for printf verb %%%c of wrong type: %s", types.ExprString(arg), state.verb, typeString)
+		return false
+	}
+	if v.typ&argString != 0 && v.verb != 'T' && !bytes.Contains(state.flags, []byte{'#'}) && c.recursiveStringer(arg) {
+		c.pass.Reportf(call.Pos(), "arg %s for printf causes recursive call to String method", types.ExprString(arg))
+		return false
+	}
+	return true
+}
+
+// argCanBeChecked reports whether the specified argument is statically present;
+// it may be beyond the list of arguments or in a terminal slice... argument, which
+// means we can't see it.
+func (c *printfChecker) argCanBeChecked(call *ast.CallExpr, formatArg int, state *formatState) bool {
+	argNum := state.argNums[formatArg]
+	if argNum < len(call.Args)-1 {
+		return true // Always OK.
+	}
+	if call.Ellipsis.IsValid() {
+		return false // We just can't tell; there could be many more arguments.
+	}
+	if argNum < len(call.Args) {
+		return true
+	}
+	// There are bad indexes in the format or there are fewer arguments than the format needs.
+	// This is the argument number relative to the format: Printf("%s", "hi") will give 1 for the "hi".
+	arg := argNum - state.firstArg + 1 // People think of arguments as 1-indexed.
+	c.pass.Reportf(call.Pos(), `missing argument for %s("%s"): format reads arg %d, have only %d args`, state.name, state.format, arg, len(call.Args)-state.firstArg)
+	return false
+}
+
+// printFormatRE is the regexp we match and report as a possible format string
+// in the first argument to unformatted prints like fmt.Print.
+// We exclude the space flag, so that printing a string like "x % y" is not reported as a format.
+var printFormatRE = regexp.MustCompile(`%` + flagsRE + numOptRE + `\.?` + numOptRE + indexOptRE + verbRE)
+
+const (
+	flagsRE    = `[+\-#]*`
+	indexOptRE = `(\[[0-9]+\])?`
+	numOptRE   = `([0-9]+|` + indexOptRE + `\*)?`
+	verbRE     = `[bcdefgopqstvxEFGUX]`
+)
+
+// checkPrintCall checks a call to an unformatted print routine such as Println.
+func (c *printfChecker) checkPrintCall(call *ast.CallExpr, fn *types.Func) {
+	name := fn.Name()
+	firstArg := fn.Type().(*types.Signature).Params().Len() - 1
+	if firstArg < 0 || firstArg >= len(call.Args) || call.Ellipsis.IsValid() {
+		return
+	}
+	args := call.Args[firstArg:]
+	if lit, ok := args[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+		// Ignore trailing % character in lit.Value.
+		// The % in "abc 0.0%" couldn't be a formatting directive.
+		s := strings.TrimSuffix(lit.Value, `%"`)
+		if strings.Contains(s, "%") && printFormatRE.MatchString(s) {
+			c.pass.Reportf(call.Pos(), "possible formatting directive in %s call", name)
+		}
+	}
+	if strings.HasSuffix(name, "ln") {
+		// The last item, if a string, should not have a newline.
+		if lit, ok := args[len(args)-1].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			str, _ := strconv.Unquote(lit.Value)
+			if strings.HasSuffix(str, "\n") {
+				c.pass.Reportf(call.Pos(), "%s arg list ends with redundant newline", name)
+			}
+		}
+	}
+	for _, arg := range args {
+		if c.isFunctionValue(arg) {
+			c.pass.Reportf(call.Pos(), "arg %s in %s call is a function value, not a function call", types.ExprString(arg), name)
+		}
+		if c.recursiveStringer(arg) {
+			c.pass.Reportf(call.Pos(), "arg %s in %s call causes recursive call to String method", types.ExprString(arg), name)
+		}
+	}
+}
+
+// isFunctionValue reports whether the expression is a function as opposed to a function call.
+// It is almost always a mistake to print a function value.
+func (c *printfChecker) isFunctionValue(e ast.Expr) bool {
+	if typ := c.pass.TypesInfo.TypeOf(e); typ != nil {
+		_, ok := typ.(*types.Signature)
+		return ok
+	}
+	return false
+}
+
+// recursiveStringer reports whether the argument e is the receiver of
+// the enclosing String or Error method, whose printing calls that
+// method again.
+func (c *printfChecker) recursiveStringer(e ast.Expr) bool {
+	if c.recv == nil {
+		return false
+	}
+	id, ok := e.(*ast.Ident)
+	return ok && c.pass.TypesInfo.Uses[id] == c.recv
+}
+
+// matchArgType reports an error if printf verb t is not appropriate
+// for operand arg.
+func (c *printfChecker) matchArgType(t printfArgType, arg ast.Expr) bool {
+	typ := c.pass.TypesInfo.TypeOf(arg)
+	if typ == nil {
+		return true // probably a type check problem
+	}
+	return matchArgTypeInternal(t, typ, make(map[types.Type]bool))
+}
+
+// matchArgTypeInternal is the internal version of matchArgType. It carries a map
+// remembering what types are in progress so we don't recur when faced with recursive
+// types or mutually recursive types.
+func matchArgTypeInternal(t printfArgType, typ types.Type, inProgress map[types.Type]bool) bool {
+	// %v, %T accept any argument type.
+	if t == anyType {
+		return true
+	}
+	// If the type implements fmt.Formatter, we have nothing to check.
+	if hasMethod(typ, "Format") {
+		return true
+	}
+	// If we can use a string, might arg (dynamically) implement the Stringer or Error interface?
+	if t&argString != 0 && (hasMethod(typ, "String") || hasMethod(typ, "Error")) {
+		return true
+	}
+
+	typ = typ.Underlying()
+	if inProgress[typ] {
+		// We're already looking at this type. The call that started it will take care of it.
+		return true
+	}
+	inProgress[typ] = true
+
+	switch typ := typ.(type) {
+	case *types.Signature:
+		return t&argPointer != 0
+
+	case *types.Map:
+		// Recur: map[int]int matches %d.
+		return t&argPointer != 0 ||
+			(matchArgTypeInternal(t, typ.Key(), inProgress) && matchArgTypeInternal(t, typ.Elem(), inProgress))
+
+	case *types.Chan:
+		return t&argPointer != 0
+
+	case *types.Array:
+		// Same as slice.
+		if types.Identical(typ.Elem().Underlying(), types.Typ[types.Byte]) && t&argString != 0 {
+			return true // %s matches []byte
+		}
+		// Recur: []int matches %d.
+		return t&argPointer != 0 || matchArgTypeInternal(t, typ.Elem(), inProgress)
+
+	case *types.Slice:
+		// Same as array.
+		if types.Identical(typ.Elem().Underlying(), types.Typ[types.Byte]) && t&argString != 0 {
+			return true // %s matches []byte
+		}
+		// Recur: []int matches %d. But watch out for
+		//	type T []T
+		// If the element is a pointer type (type T[]*T), it's handled fine by the Pointer case below.
+		return t&argPointer != 0 || matchArgTypeInternal(t, typ.Elem(), inProgress)
+
+	case *types.Pointer:
+		// If it's actually a pointer with %p, it prints as one.
+		if t == argPointer {
+			return true
+		}
+		// If it's pointer to struct, that's equivalent in our analysis to whether we can print the struct.
+		if str, ok := typ.Elem().Underlying().(*types.Struct); ok {
+			return matchStructArgType(t, str, inProgress)
+		}
+		// The rest can print with %p as pointers, or as integers with %x etc.
+		return t&(argInt|argPointer) != 0
+
+	case *types.Struct:
+		return matchStructArgType(t, typ, inProgress)
+
+	case *types.Interface:
+		// There's little we can do.
+		// Whether any particular verb is valid depends on the argument.
+		// The user may have reasonable prior knowledge of the contents of the interface.
+		return true
+
+	case *types.Basic:
+		switch typ.Kind() {
+		case types.UntypedBool,
+			types.Bool:
+			return t&argBool != 0
+
+		case types.UntypedInt,
+			types.Int,
+			types.Int8,
+			types.Int16,
+			types.Int32,
+			types.Int64,
+			types.Uint,
+			types.Uint8,
+			types.Uint16,
+			types.Uint32,
+			types.Uint64,
+			types.Uintptr:
+			return t&argInt != 0
+
+		case types.UntypedFloat,
+			types.Float32,
+			types.Float64:
+			return t&argFloat != 0
+
+		case types.UntypedComplex,
+			types.Complex64,
+			types.Complex128:
+			return t&argComplex != 0
+
+		case types.UntypedString,
+			types.String:
+			return t&argString != 0
+
+		case types.UnsafePointer:
+			return t&(argPointer|argInt) != 0
+
+		case types.UntypedRune:
+			return t&(argInt|argRune) != 0
+
+		case types.UntypedNil:
+			return t&argPointer != 0 // TODO?
+
+		case types.Invalid:
+			return true // Probably a type check problem.
+		}
+		panic("unreachable")
+	}
+
+	return false
+}
+
+// matchStructArgType reports whether all the elements of the struct match the expected
+// type. For instance, with "%d" all the elements must be printable with the "%d" format.
+func matchStructArgType(t printfArgType, typ *types.Struct, inProgress map[types.Type]bool) bool {
+	for i := 0; i < typ.NumFields(); i++ {
+		if !matchArgTypeInternal(t, typ.Field(i).Type(), inProgress) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasMethod reports whether the method set of typ includes a method
+// with the given name. The method sets of interfaces are included.
+func hasMethod(typ types.Type, name string) bool {
+	return types.NewMethodSet(typ).Lookup(nil, name) != nil
+}
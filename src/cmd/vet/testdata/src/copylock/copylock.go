@@ -0,0 +1,159 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains tests for the copylock checker's
+// function declaration analysis.
+
+package copylock
+
+import "sync"
+
+func OkFunc(*sync.Mutex) {}
+func BadFunc(sync.Mutex) {} // ERROR "BadFunc passes lock by value: sync.Mutex"
+func OkRet() *sync.Mutex {}
+func BadRet() sync.Mutex {} // Don't warn about results
+
+var (
+	OkClosure  = func(*sync.Mutex) {}
+	BadClosure = func(sync.Mutex) {} // ERROR "func passes lock by value: sync.Mutex"
+)
+
+type EmbeddedRWMutex struct {
+	sync.RWMutex
+}
+
+func (*EmbeddedRWMutex) OkMeth() {}
+func (EmbeddedRWMutex) BadMeth() {} // ERROR "BadMeth passes lock by value: .*copylock.EmbeddedRWMutex"
+func OkFunc2(e *EmbeddedRWMutex) {}
+func BadFunc2(e EmbeddedRWMutex) {} // ERROR "BadFunc2 passes lock by value: .*copylock.EmbeddedRWMutex"
+func OkRet2() *EmbeddedRWMutex   {}
+func BadRet2() EmbeddedRWMutex   {} // Don't warn about results
+
+type FieldMutex struct {
+	s sync.Mutex
+}
+
+func (*FieldMutex) OkMeth()    {}
+func (FieldMutex) BadMeth()    {} // ERROR "BadMeth passes lock by value: .*copylock.FieldMutex contains sync.Mutex"
+func OkFunc3(*FieldMutex)      {}
+func BadFunc3(FieldMutex, int) {} // ERROR "BadFunc3 passes lock by value: .*copylock.FieldMutex contains sync.Mutex"
+
+type L0 struct {
+	L1
+}
+
+type L1 struct {
+	l L2
+}
+
+type L2 struct {
+	sync.Mutex
+}
+
+func (*L0) Ok() {}
+func (L0) Bad() {} // ERROR "Bad passes lock by value: .*copylock.L0 contains .*copylock.L1 contains .*copylock.L2"
+
+type EmbeddedMutexPointer struct {
+	s *sync.Mutex // safe to copy this pointer
+}
+
+func (*EmbeddedMutexPointer) Ok()      {}
+func (EmbeddedMutexPointer) AlsoOk()   {}
+func StillOk(EmbeddedMutexPointer)     {}
+func LookinGood() EmbeddedMutexPointer {}
+
+type EmbeddedLocker struct {
+	sync.Locker // safe to copy interface values
+}
+
+func (*EmbeddedLocker) Ok()    {}
+func (EmbeddedLocker) AlsoOk() {}
+
+type CustomLock struct{}
+
+func (*CustomLock) Lock()   {}
+func (*CustomLock) Unlock() {}
+
+func Ok(*CustomLock) {}
+func Bad(CustomLock) {} // ERROR "Bad passes lock by value: .*copylock.CustomLock"
+
+// Passing lock values into interface function arguments
+func FuncCallInterfaceArg(f func(a int, b interface{})) {
+	var m sync.Mutex
+	var t struct{ lock sync.Mutex }
+
+	f(1, "foo")
+	f(2, &t)
+	f(3, &sync.Mutex{})
+	f(4, m) // ERROR "call of f copies lock value: sync.Mutex"
+	f(5, t) // ERROR "call of f copies lock value: struct.lock sync.Mutex. contains sync.Mutex"
+}
+
+// Returning lock via interface value
+func ReturnViaInterface(x int) (int, interface{}) {
+	var m sync.Mutex
+	var t struct{ lock sync.Mutex }
+
+	switch x % 4 {
+	case 0:
+		return 0, "qwe"
+	case 1:
+		return 1, &sync.Mutex{}
+	case 2:
+		return 2, m // ERROR "return copies lock value: sync.Mutex"
+	default:
+		return 3, t // ERROR "return copies lock value: struct.lock sync.Mutex. contains sync.Mutex"
+	}
+}
+
+func OkAssignments() {
+	var x sync.Mutex
+	p := &x
+	var y sync.Mutex
+	p = &y
+	var z = sync.Mutex{}
+	w := sync.Mutex{}
+	w = sync.Mutex{}
+	q := struct{ L sync.Mutex }{
+		L: sync.Mutex{},
+	}
+	_, _, _, _, _ = x, p, y, z, w
+	_ = q
+	_ = new(sync.Mutex)
+}
+
+func BadAssignments() {
+	var x sync.Mutex
+	y := x    // ERROR "assignment copies lock value to y: sync.Mutex"
+	var z = x // ERROR "variable declaration copies lock value to z: sync.Mutex"
+	z = y     // ERROR "assignment copies lock value to z: sync.Mutex"
+	t := struct{ L sync.Mutex }{
+		L: x, // ERROR "literal copies lock value from x: sync.Mutex"
+	}
+	a := [3]sync.Mutex{x} // ERROR "literal copies lock value from x: sync.Mutex"
+	b := a                // ERROR "assignment copies lock value to b: sync.Mutex"
+	_, _, _ = t, z, b
+}
+
+func Range() {
+	var s []sync.Mutex
+	for range s {
+	}
+	for i := range s {
+		_ = i
+	}
+	for i, _ := range s {
+		_ = i
+	}
+	for _, m := range s { // ERROR "range var m copies lock: sync.Mutex"
+		_ = m
+	}
+	var mut sync.Mutex
+	for _, mut = range s { // ERROR "range var mut copies lock: sync.Mutex"
+	}
+	var ptrs []*sync.Mutex
+	for _, p := range ptrs {
+		_ = p
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains tests for the dead code checker.
+
+package deadcode
+
+func _() int {
+	print(1)
+	return 2
+	println() // ERROR "unreachable code"
+	return 3
+}
+
+func _() int {
+L:
+	print(1)
+	goto L
+	println() // ERROR "unreachable code"
+}
+
+func _() int {
+	print(1)
+	panic(2)
+	println() // ERROR "unreachable code"
+}
+
+// but only builtin panic
+func _() int {
+	var panic = func(int) {}
+	print(1)
+	panic(2)
+	println() // ok
+	return 0
+}
+
+func _() int {
+	{
+		print(1)
+		return 2
+		println() // ERROR "unreachable code"
+	}
+	println() // ok
+}
+
+func _() int {
+	for {
+	}
+	println() // ERROR "unreachable code"
+}
+
+func _() int {
+	for {
+		break
+	}
+	println() // ok
+	return 0
+}
+
+func _() int {
+L:
+	for {
+		for {
+			break L
+		}
+	}
+	println() // ok
+	return 0
+}
+
+func _() int {
+	for x := 0; x < 10; x++ {
+		continue
+		println() // ERROR "unreachable code"
+	}
+	return 0
+}
+
+func _() int {
+	if x := 1; x > 0 {
+		return 1
+	} else {
+		panic(2)
+	}
+	println() // ERROR "unreachable code"
+}
+
+func _() int {
+	if x := 1; x > 0 {
+		return 1
+	}
+	println() // ok
+	return 0
+}
+
+func _(x int) int {
+	switch x {
+	case 1:
+		return 1
+	default:
+		return 2
+	}
+	println() // ERROR "unreachable code"
+}
+
+func _(x int) int {
+	switch x {
+	case 1:
+		return 1
+	}
+	println() // ok
+	return 0
+}
+
+func _(x interface{}) int {
+	switch x.(type) {
+	case int:
+		break
+	default:
+		return 2
+	}
+	println() // ok
+	return 0
+}
+
+func _(c chan int) int {
+	select {
+	case <-c:
+		return 1
+	}
+	println() // ERROR "unreachable code"
+}
+
+func _() int {
+	select {}
+	println() // ERROR "unreachable code"
+}
+
+var _ = func() int {
+	return 1
+	println() // ERROR "unreachable code"
+}
@@ -0,0 +1,33 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package wrapper declares print wrappers for use by the print test.
+package wrapper
+
+import "fmt"
+
+// Logf is a printf wrapper.
+func Logf(format string, args ...interface{}) {
+	fmt.Printf(format, args...)
+}
+
+// Errorf is a wrapper of a wrapper.
+func Errorf(format string, args ...interface{}) {
+	Logf(format, args...)
+}
+
+// Log is a print wrapper.
+func Log(args ...interface{}) {
+	fmt.Println(args...)
+}
+
+// Convert modifies its arguments, so it is not a wrapper.
+func Convert(format string, args ...interface{}) string {
+	for i, arg := range args {
+		if s, ok := arg.(fmt.Stringer); ok {
+			args[i] = s.String()
+		}
+	}
+	return fmt.Sprintf(format, args...)
+}
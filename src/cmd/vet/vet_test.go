@@ -0,0 +1,120 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"cmd/vet/internal/analysis"
+)
+
+var vetTests = []struct {
+	dir       string
+	analyzers []*analysis.Analyzer
+}{
+	{"print", []*analysis.Analyzer{printfAnalyzer}},
+	{"copylock", []*analysis.Analyzer{copylocksAnalyzer}},
+	{"shadow", []*analysis.Analyzer{shadowAnalyzer}},
+	{"deadcode", []*analysis.Analyzer{unreachableAnalyzer}},
+}
+
+// errorRE matches the expectations in the test files:
+// a comment of the form // ERROR "regexp" on the line of the diagnostic.
+var errorRE = regexp.MustCompile(`// ERROR "(.*)"`)
+
+// TestVet applies each analyzer to the packages in testdata/src and
+// compares the diagnostics with the expectations in the source.
+// The packages import one another through a GOPATH rooted at testdata,
+// so the printf test also covers the facts passed between packages.
+func TestVet(t *testing.T) {
+	gopath, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctxt := build.Default
+	ctxt.GOPATH = gopath
+
+	*printfuncs = "Warn,Warnf"
+	initPrintFlags()
+	defer func() {
+		*printfuncs = ""
+		initPrintFlags()
+	}()
+
+	for _, tt := range vetTests {
+		dir := filepath.Join(gopath, "src", tt.dir)
+		pkg, err := ctxt.ImportDir(dir, 0)
+		if err != nil {
+			t.Errorf("%s: %v", tt.dir, err)
+			continue
+		}
+		d := &analysis.Driver{
+			Analyzers: tt.analyzers,
+			Context:   &ctxt,
+		}
+		diags, err := d.Analyze(dir, pkg.GoFiles)
+		if err != nil {
+			t.Errorf("%s: %v", tt.dir, err)
+			continue
+		}
+		got := make(map[string][]string) // file:line -> messages
+		for _, diag := range diags {
+			posn := d.Fset.Position(diag.Pos)
+			key := fmt.Sprintf("%s:%d", filepath.Base(posn.Filename), posn.Line)
+			got[key] = append(got[key], diag.Message)
+		}
+		for _, name := range pkg.GoFiles {
+			checkExpectations(t, filepath.Join(dir, name), got)
+		}
+		for key, msgs := range got {
+			for _, msg := range msgs {
+				t.Errorf("%s: %s: unexpected diagnostic: %s", tt.dir, key, msg)
+			}
+		}
+	}
+}
+
+// checkExpectations reports each expectation in the named file that
+// is not matched by a diagnostic, removing matched diagnostics from got.
+func checkExpectations(t *testing.T, filename string, got map[string][]string) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		m := errorRE.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		key := fmt.Sprintf("%s:%d", filepath.Base(filename), i+1)
+		re, err := regexp.Compile(m[1])
+		if err != nil {
+			t.Errorf("%s: %v", key, err)
+			continue
+		}
+		msgs := got[key]
+		found := false
+		for j, msg := range msgs {
+			if re.MatchString(msg) {
+				got[key] = append(msgs[:j], msgs[j+1:]...)
+				found = true
+				break
+			}
+		}
+		if len(got[key]) == 0 {
+			delete(got, key)
+		}
+		if !found {
+			t.Errorf("%s: no diagnostic matching %q", key, m[1])
+		}
+	}
+}
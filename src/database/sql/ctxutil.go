@@ -0,0 +1,168 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+)
+
+// The ctxDriver functions call into the driver on behalf of the
+// Context methods. A driver implementing the matching Context
+// interface of package driver is passed ctx and trusted to return
+// once ctx is done. Calls into other drivers can't be interrupted, so
+// they are made with dc.run, which abandons dc if ctx is done first.
+//
+// Each function locks dc around the driver call; the caller must not
+// hold dc's lock.
+
+func ctxDriverPrepare(ctx context.Context, dc *driverConn, query string) (driver.Stmt, error) {
+	if ciCtx, ok := dc.ci.(driver.ConnPrepareContext); ok {
+		dc.Lock()
+		defer dc.Unlock()
+		return ciCtx.PrepareContext(ctx, query)
+	}
+	var si driver.Stmt
+	var err error
+	if cerr := dc.run(ctx, func() { si, err = dc.ci.Prepare(query) }); cerr != nil {
+		return nil, cerr
+	}
+	return si, err
+}
+
+// ctxDriverExec executes query with dc's ExecerContext or Execer.
+// It returns driver.ErrSkip if dc implements neither.
+func ctxDriverExec(ctx context.Context, dc *driverConn, query string, args []interface{}) (driver.Result, error) {
+	execerCtx, isExecerCtx := dc.ci.(driver.ExecerContext)
+	execer, isExecer := dc.ci.(driver.Execer)
+	if !isExecerCtx && !isExecer {
+		return nil, driver.ErrSkip
+	}
+	dargs, err := driverArgs(nil, args)
+	if err != nil {
+		return nil, err
+	}
+	if isExecerCtx {
+		dc.Lock()
+		defer dc.Unlock()
+		return execerCtx.ExecContext(ctx, query, dargs)
+	}
+	var resi driver.Result
+	if cerr := dc.run(ctx, func() { resi, err = execer.Exec(query, dargs) }); cerr != nil {
+		return nil, cerr
+	}
+	return resi, err
+}
+
+// ctxDriverQuery runs query with dc's QueryerContext or Queryer.
+// It returns driver.ErrSkip if dc implements neither.
+func ctxDriverQuery(ctx context.Context, dc *driverConn, query string, args []interface{}) (driver.Rows, error) {
+	queryerCtx, isQueryerCtx := dc.ci.(driver.QueryerContext)
+	queryer, isQueryer := dc.ci.(driver.Queryer)
+	if !isQueryerCtx && !isQueryer {
+		return nil, driver.ErrSkip
+	}
+	dargs, err := driverArgs(nil, args)
+	if err != nil {
+		return nil, err
+	}
+	if isQueryerCtx {
+		dc.Lock()
+		defer dc.Unlock()
+		return queryerCtx.QueryContext(ctx, query, dargs)
+	}
+	var rowsi driver.Rows
+	if cerr := dc.run(ctx, func() { rowsi, err = queryer.Query(query, dargs) }); cerr != nil {
+		return nil, cerr
+	}
+	return rowsi, err
+}
+
+func ctxDriverStmtExec(ctx context.Context, dc *driverConn, si driver.Stmt, dargs []driver.Value) (driver.Result, error) {
+	if siCtx, ok := si.(driver.StmtExecContext); ok {
+		dc.Lock()
+		defer dc.Unlock()
+		return siCtx.ExecContext(ctx, dargs)
+	}
+	var resi driver.Result
+	var err error
+	if cerr := dc.run(ctx, func() { resi, err = si.Exec(dargs) }); cerr != nil {
+		return nil, cerr
+	}
+	return resi, err
+}
+
+func ctxDriverStmtQuery(ctx context.Context, dc *driverConn, si driver.Stmt, dargs []driver.Value) (driver.Rows, error) {
+	if siCtx, ok := si.(driver.StmtQueryContext); ok {
+		dc.Lock()
+		defer dc.Unlock()
+		return siCtx.QueryContext(ctx, dargs)
+	}
+	var rowsi driver.Rows
+	var err error
+	if cerr := dc.run(ctx, func() { rowsi, err = si.Query(dargs) }); cerr != nil {
+		return nil, cerr
+	}
+	return rowsi, err
+}
+
+func ctxDriverBegin(ctx context.Context, dc *driverConn) (driver.Tx, error) {
+	if ciCtx, ok := dc.ci.(driver.ConnBeginContext); ok {
+		dc.Lock()
+		defer dc.Unlock()
+		return ciCtx.BeginContext(ctx)
+	}
+	var txi driver.Tx
+	var err error
+	if cerr := dc.run(ctx, func() { txi, err = dc.ci.Begin() }); cerr != nil {
+		return nil, cerr
+	}
+	return txi, err
+}
+
+// run calls fn, a call into the driver, with dc locked, and returns
+// nil once fn has returned. If ctx is done first, run returns
+// ctx.Err() without waiting and abandons dc: fn keeps running in the
+// background, and releasing dc closes it once fn returns rather than
+// reusing it. Callers must not look at fn's results unless run
+// returns nil.
+func (dc *driverConn) run(ctx context.Context, fn func()) error {
+	done := ctx.Done()
+	if done == nil {
+		// ctx can't be canceled; avoid the goroutine.
+		dc.Lock()
+		fn()
+		dc.Unlock()
+		return nil
+	}
+	select {
+	case <-done:
+		return ctx.Err()
+	default:
+	}
+
+	returned := make(chan struct{})
+	go func() {
+		dc.Lock()
+		fn()
+		dc.Unlock()
+		close(returned)
+	}()
+	select {
+	case <-returned:
+		return nil
+	case <-done:
+	}
+	select {
+	case <-returned:
+		// fn finished too; prefer its results.
+		return nil
+	default:
+	}
+	dc.db.mu.Lock()
+	dc.abandoned = returned
+	dc.db.mu.Unlock()
+	return ctx.Err()
+}
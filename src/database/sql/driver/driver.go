@@ -8,7 +8,11 @@
 // Most code should use package sql.
 package driver
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"reflect"
+)
 
 // Value is a value that drivers must be able to handle.
 // It is either nil or an instance of one of these types:
@@ -76,6 +80,68 @@ type Queryer interface {
 	Query(query string, args []Value) (Rows, error)
 }
 
+// The Context interfaces below are optional interfaces that may be
+// implemented by a Conn or Stmt. Each is the counterpart of a method
+// that takes no Context; the sql package uses it instead of that
+// method for calls such as DB.QueryContext.
+//
+// The driver should stop the operation and return promptly once ctx
+// is done, returning ctx.Err() or an error of its own. Calls on a Conn
+// or Stmt that implements none of these interfaces cannot be
+// interrupted: if ctx is done first, the sql package returns to the
+// caller without waiting and discards the connection once the call
+// returns.
+
+// Pinger is an optional interface that may be implemented by a Conn.
+//
+// If a Conn does not implement Pinger, the sql package's DB.Ping
+// only checks that a connection is available.
+//
+// Ping should return ErrBadConn if the connection is no longer usable.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// ExecerContext is like Execer but also receives a Context.
+//
+// ExecContext may return ErrSkip.
+type ExecerContext interface {
+	ExecContext(ctx context.Context, query string, args []Value) (Result, error)
+}
+
+// QueryerContext is like Queryer but also receives a Context.
+//
+// QueryContext may return ErrSkip.
+type QueryerContext interface {
+	QueryContext(ctx context.Context, query string, args []Value) (Rows, error)
+}
+
+// ConnPrepareContext is like the Prepare method of Conn but also
+// receives a Context. The Context bounds only the preparation, not
+// the lifetime of the returned Stmt.
+type ConnPrepareContext interface {
+	PrepareContext(ctx context.Context, query string) (Stmt, error)
+}
+
+// ConnBeginContext is like the Begin method of Conn but also
+// receives a Context. The Context bounds only starting the
+// transaction, not the lifetime of the returned Tx.
+type ConnBeginContext interface {
+	BeginContext(ctx context.Context) (Tx, error)
+}
+
+// StmtExecContext is like the Exec method of Stmt but also
+// receives a Context.
+type StmtExecContext interface {
+	ExecContext(ctx context.Context, args []Value) (Result, error)
+}
+
+// StmtQueryContext is like the Query method of Stmt but also
+// receives a Context.
+type StmtQueryContext interface {
+	QueryContext(ctx context.Context, args []Value) (Rows, error)
+}
+
 // Conn is a connection to a database. It is not used concurrently
 // by multiple goroutines.
 //
@@ -173,6 +239,70 @@ type Rows interface {
 	Next(dest []Value) error
 }
 
+// RowsNextResultSet may be implemented by Rows that can return
+// more than one result set, such as the results of a stored
+// procedure or a batch of queries.
+type RowsNextResultSet interface {
+	Rows
+
+	// HasNextResultSet is called at the end of the current result
+	// set and reports whether there is another result set after
+	// the current one.
+	HasNextResultSet() bool
+
+	// NextResultSet advances the driver to the next result set even
+	// if there are remaining rows in the current result set.
+	//
+	// NextResultSet should return io.EOF when there are no more
+	// result sets.
+	NextResultSet() error
+}
+
+// The RowsColumnType interfaces may be implemented by Rows to describe
+// the columns of the current result set. Each method is passed the
+// index of a column, as in the slice returned by Columns. Methods
+// returning an ok result report false if the property doesn't apply
+// to the column's type or isn't known.
+
+// RowsColumnTypeScanType may be implemented by Rows. It returns the
+// Go type of the values the driver returns in Next for the column,
+// such as reflect.TypeOf(int64(0)), or the type of a Scanner such as
+// NullInt64 for a nullable column.
+type RowsColumnTypeScanType interface {
+	Rows
+	ColumnTypeScanType(index int) reflect.Type
+}
+
+// RowsColumnTypeDatabaseTypeName may be implemented by Rows. It
+// returns the database system's name for the column's type, without
+// its length, in upper case, such as "VARCHAR", "NUMERIC" or "INT".
+type RowsColumnTypeDatabaseTypeName interface {
+	Rows
+	ColumnTypeDatabaseTypeName(index int) string
+}
+
+// RowsColumnTypeLength may be implemented by Rows. It returns the
+// length of a variable length column type, such as text or binary
+// types. If the length is unlimited, length is math.MaxInt64.
+type RowsColumnTypeLength interface {
+	Rows
+	ColumnTypeLength(index int) (length int64, ok bool)
+}
+
+// RowsColumnTypeNullable may be implemented by Rows. It reports
+// whether the column may be NULL.
+type RowsColumnTypeNullable interface {
+	Rows
+	ColumnTypeNullable(index int) (nullable, ok bool)
+}
+
+// RowsColumnTypePrecisionScale may be implemented by Rows. It returns
+// the precision and scale of a decimal column type.
+type RowsColumnTypePrecisionScale interface {
+	Rows
+	ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool)
+}
+
 // Tx is a transaction.
 type Tx interface {
 	Commit() error
@@ -5,11 +5,14 @@
 package sql
 
 import (
+	"context"
 	"database/sql/driver"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
@@ -32,6 +35,11 @@ var _ = log.Printf
 //     where types are: "string", [u]int{8,16,32,64}, "bool"
 //   INSERT|<tablename>|col=val,col2=val2,col3=?
 //   SELECT|<tablename>|projectcol1,projectcol2|filtercol=?,filtercol2=?
+//   <select>;<select>;...
+//     returns a result set for each SELECT, in order
+//   WAIT|<duration>|<query>
+//     waits for the duration (or until the context is done, with a
+//     Context method) before executing or querying the query
 //
 // When opening a fakeDriver's database, it starts empty with no
 // tables.  All tables and data are stored in memory only.
@@ -113,6 +121,7 @@ type fakeStmt struct {
 	table string
 
 	closed bool
+	wait   time.Duration // used by WAIT
 
 	colName      []string      // used by CREATE, INSERT, SELECT (selected columns)
 	colType      []string      // used by CREATE
@@ -122,6 +131,8 @@ type fakeStmt struct {
 	whereCol []string // used by SELECT (all placeholders)
 
 	placeholderConverter []driver.ValueConverter // used by INSERT
+
+	next *fakeStmt // the next SELECT of a query joined with semicolons
 }
 
 var fdriver driver.Driver = &fakeDriver{}
@@ -155,9 +166,10 @@ func TestDrivers(t *testing.T) {
 
 // Supports dsn forms:
 //    <dbname>
-//    <dbname>;<opts>  (only currently supported option is `badConn`,
-//                      which causes driver.ErrBadConn to be returned on
-//                      every other conn.Begin())
+//    <dbname>;<opts>  (supported options are `badConn`, which causes
+//                      driver.ErrBadConn to be returned on every other
+//                      conn.Begin(), and `ctx`, which opens a
+//                      fakeCtxConn)
 func (d *fakeDriver) Open(dsn string) (driver.Conn, error) {
 	parts := strings.Split(dsn, ";")
 	if len(parts) < 1 {
@@ -181,6 +193,9 @@ func (d *fakeDriver) Open(dsn string) (driver.Conn, error) {
 		d.waitCh = nil
 		d.waitingCh = nil
 	}
+	if len(parts) >= 2 && parts[1] == "ctx" {
+		return &fakeCtxConn{conn}, nil
+	}
 	return conn, nil
 }
 
@@ -475,13 +490,53 @@ func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
 		return nil, driver.ErrBadConn
 	}
 
+	var firstStmt, prev *fakeStmt
+	for _, query := range strings.Split(query, ";") {
+		si, err := c.prepareOne(query)
+		if err != nil {
+			if firstStmt != nil {
+				firstStmt.Close()
+			}
+			return nil, err
+		}
+		stmt := si.(*fakeStmt)
+		if firstStmt == nil {
+			firstStmt = stmt
+			prev = stmt
+			continue
+		}
+		prev.next = stmt
+		prev = stmt
+		if firstStmt.cmd != "SELECT" || stmt.cmd != "SELECT" {
+			firstStmt.Close()
+			return nil, errf("only SELECT queries may be joined with a semicolon")
+		}
+	}
+	return firstStmt, nil
+}
+
+// prepareOne prepares a single query, not joined with semicolons.
+func (c *fakeConn) prepareOne(query string) (driver.Stmt, error) {
 	parts := strings.Split(query, "|")
 	if len(parts) < 1 {
 		return nil, errf("empty query")
 	}
 	cmd := parts[0]
 	parts = parts[1:]
-	stmt := &fakeStmt{q: query, c: c, cmd: cmd}
+	var wait time.Duration
+	if cmd == "WAIT" {
+		if len(parts) < 2 {
+			return nil, errf("invalid WAIT query %q", query)
+		}
+		var err error
+		wait, err = time.ParseDuration(parts[0])
+		if err != nil {
+			return nil, errf("invalid WAIT duration %q: %v", parts[0], err)
+		}
+		cmd = parts[1]
+		parts = parts[2:]
+	}
+	stmt := &fakeStmt{q: query, c: c, cmd: cmd, wait: wait}
 	c.incrStat(&c.stmtsMade)
 	switch cmd {
 	case "WIPE":
@@ -521,6 +576,9 @@ func (s *fakeStmt) Close() error {
 		s.c.incrStat(&s.c.stmtsClosed)
 		s.closed = true
 	}
+	if s.next != nil {
+		s.next.Close()
+	}
 	return nil
 }
 
@@ -529,10 +587,33 @@ var errClosed = errors.New("fakedb: statement has been closed")
 // hook to simulate broken connections
 var hookExecBadConn func() bool
 
+// waitContext waits for the statement's WAIT duration, if any,
+// or until ctx is done.
+func (s *fakeStmt) waitContext(ctx context.Context) error {
+	if s.wait <= 0 {
+		return nil
+	}
+	t := time.NewTimer(s.wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.execContext(context.Background(), args)
+}
+
+func (s *fakeStmt) execContext(ctx context.Context, args []driver.Value) (driver.Result, error) {
 	if s.closed {
 		return nil, errClosed
 	}
+	if err := s.waitContext(ctx); err != nil {
+		return nil, err
+	}
 
 	if s.c.stickyBad || (hookExecBadConn != nil && hookExecBadConn()) {
 		return nil, driver.ErrBadConn
@@ -614,9 +695,16 @@ func (s *fakeStmt) execInsert(args []driver.Value, doInsert bool) (driver.Result
 var hookQueryBadConn func() bool
 
 func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.queryContext(context.Background(), args)
+}
+
+func (s *fakeStmt) queryContext(ctx context.Context, args []driver.Value) (driver.Rows, error) {
 	if s.closed {
 		return nil, errClosed
 	}
+	if err := s.waitContext(ctx); err != nil {
+		return nil, err
+	}
 
 	if s.c.stickyBad || (hookQueryBadConn != nil && hookQueryBadConn()) {
 		return nil, driver.ErrBadConn
@@ -627,16 +715,36 @@ func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
 		return nil, err
 	}
 
-	db := s.c.db
-	if len(args) != s.placeholders {
+	if len(args) != s.NumInput() {
 		panic("error in pkg db; should only get here if size is correct")
 	}
 
+	cursor := &rowsCursor{
+		pos:    -1,
+		errPos: -1,
+	}
+	for st := s; st != nil; st = st.next {
+		rows, colType, err := st.selectRows(args[:st.placeholders])
+		if err != nil {
+			return nil, err
+		}
+		args = args[st.placeholders:]
+		cursor.cols = append(cursor.cols, st.colName)
+		cursor.colType = append(cursor.colType, colType)
+		cursor.rows = append(cursor.rows, rows)
+	}
+	return cursor, nil
+}
+
+// selectRows returns the rows of a single SELECT and the types of the
+// selected columns.
+func (s *fakeStmt) selectRows(args []driver.Value) ([]*row, []string, error) {
+	db := s.c.db
 	db.mu.Lock()
 	t, ok := db.table(s.table)
 	db.mu.Unlock()
 	if !ok {
-		return nil, fmt.Errorf("fakedb: table %q doesn't exist", s.table)
+		return nil, nil, fmt.Errorf("fakedb: table %q doesn't exist", s.table)
 	}
 
 	if s.table == "magicquery" {
@@ -651,12 +759,14 @@ func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
 	defer t.mu.Unlock()
 
 	colIdx := make(map[string]int) // select column name -> column index in table
-	for _, name := range s.colName {
+	colType := make([]string, len(s.colName))
+	for i, name := range s.colName {
 		idx := t.columnIndex(name)
 		if idx == -1 {
-			return nil, fmt.Errorf("fakedb: unknown column name %q", name)
+			return nil, nil, fmt.Errorf("fakedb: unknown column name %q", name)
 		}
 		colIdx[name] = idx
+		colType[i] = t.coltype[idx]
 	}
 
 	mrows := []*row{}
@@ -668,7 +778,7 @@ rows:
 		for widx, wcol := range s.whereCol {
 			idx := t.columnIndex(wcol)
 			if idx == -1 {
-				return nil, fmt.Errorf("db: invalid where clause column %q", wcol)
+				return nil, nil, fmt.Errorf("db: invalid where clause column %q", wcol)
 			}
 			tcol := trow.cols[idx]
 			if bs, ok := tcol.([]byte); ok {
@@ -686,17 +796,83 @@ rows:
 		mrows = append(mrows, mrow)
 	}
 
-	cursor := &rowsCursor{
-		pos:    -1,
-		rows:   mrows,
-		cols:   s.colName,
-		errPos: -1,
-	}
-	return cursor, nil
+	return mrows, colType, nil
 }
 
 func (s *fakeStmt) NumInput() int {
-	return s.placeholders
+	n := s.placeholders
+	if s.next != nil {
+		n += s.next.NumInput()
+	}
+	return n
+}
+
+// fakeCtxConn is a fakeConn that implements the driver package's
+// Context interfaces, so that its calls can be canceled.
+type fakeCtxConn struct {
+	*fakeConn
+}
+
+// fakeCtxStmt is a fakeStmt prepared on a fakeCtxConn.
+type fakeCtxStmt struct {
+	*fakeStmt
+}
+
+var (
+	_ driver.Pinger             = (*fakeCtxConn)(nil)
+	_ driver.ExecerContext      = (*fakeCtxConn)(nil)
+	_ driver.QueryerContext     = (*fakeCtxConn)(nil)
+	_ driver.ConnPrepareContext = (*fakeCtxConn)(nil)
+	_ driver.ConnBeginContext   = (*fakeCtxConn)(nil)
+	_ driver.StmtExecContext    = (*fakeCtxStmt)(nil)
+	_ driver.StmtQueryContext   = (*fakeCtxStmt)(nil)
+)
+
+func (c *fakeCtxConn) Ping(ctx context.Context) error {
+	if c.stickyBad {
+		return driver.ErrBadConn
+	}
+	return ctx.Err()
+}
+
+func (c *fakeCtxConn) ExecContext(ctx context.Context, query string, args []driver.Value) (driver.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Exec(query, args)
+}
+
+func (c *fakeCtxConn) QueryContext(ctx context.Context, query string, args []driver.Value) (driver.Rows, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Query(query, args)
+}
+
+func (c *fakeCtxConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	si, err := c.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeCtxStmt{si.(*fakeStmt)}, nil
+}
+
+func (c *fakeCtxConn) BeginContext(ctx context.Context) (driver.Tx, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Begin()
+}
+
+func (s *fakeCtxStmt) ExecContext(ctx context.Context, args []driver.Value) (driver.Result, error) {
+	return s.execContext(ctx, args)
+}
+
+func (s *fakeCtxStmt) QueryContext(ctx context.Context, args []driver.Value) (driver.Rows, error) {
+	return s.queryContext(ctx, args)
 }
 
 func (tx *fakeTx) Commit() error {
@@ -709,11 +885,23 @@ func (tx *fakeTx) Rollback() error {
 	return nil
 }
 
+var (
+	_ driver.RowsNextResultSet              = (*rowsCursor)(nil)
+	_ driver.RowsColumnTypeScanType         = (*rowsCursor)(nil)
+	_ driver.RowsColumnTypeDatabaseTypeName = (*rowsCursor)(nil)
+	_ driver.RowsColumnTypeLength           = (*rowsCursor)(nil)
+	_ driver.RowsColumnTypeNullable         = (*rowsCursor)(nil)
+	_ driver.RowsColumnTypePrecisionScale   = (*rowsCursor)(nil)
+)
+
 type rowsCursor struct {
-	cols   []string
-	pos    int
-	rows   []*row
-	closed bool
+	// cols, colType and rows hold one entry per result set.
+	cols    [][]string
+	colType [][]string
+	rows    [][]*row
+	set     int // index of the current result set
+	pos     int // index of the current row in the result set
+	closed  bool
 
 	// errPos and err are for making Next return early with error.
 	errPos int
@@ -736,7 +924,47 @@ func (rc *rowsCursor) Close() error {
 }
 
 func (rc *rowsCursor) Columns() []string {
-	return rc.cols
+	return rc.cols[rc.set]
+}
+
+func (rc *rowsCursor) HasNextResultSet() bool {
+	return rc.set+1 < len(rc.rows)
+}
+
+func (rc *rowsCursor) NextResultSet() error {
+	if !rc.HasNextResultSet() {
+		return io.EOF
+	}
+	rc.set++
+	rc.pos = -1
+	return nil
+}
+
+func (rc *rowsCursor) ColumnTypeScanType(index int) reflect.Type {
+	return colTypeToReflectType(rc.colType[rc.set][index])
+}
+
+func (rc *rowsCursor) ColumnTypeDatabaseTypeName(index int) string {
+	return strings.ToUpper(strings.TrimPrefix(rc.colType[rc.set][index], "null"))
+}
+
+func (rc *rowsCursor) ColumnTypeLength(index int) (length int64, ok bool) {
+	switch rc.colType[rc.set][index] {
+	case "string", "nullstring", "blob":
+		return math.MaxInt64, true
+	}
+	return 0, false
+}
+
+func (rc *rowsCursor) ColumnTypeNullable(index int) (nullable, ok bool) {
+	return strings.HasPrefix(rc.colType[rc.set][index], "null"), true
+}
+
+// ColumnTypePrecisionScale reports nothing, as fakedb has no decimal
+// type; it exists so that the sql package's handling of an unknown
+// answer is tested.
+func (rc *rowsCursor) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	return 0, 0, false
 }
 
 var rowsCursorNextHook func(dest []driver.Value) error
@@ -753,10 +981,10 @@ func (rc *rowsCursor) Next(dest []driver.Value) error {
 	if rc.pos == rc.errPos {
 		return rc.err
 	}
-	if rc.pos >= len(rc.rows) {
+	if rc.pos >= len(rc.rows[rc.set]) {
 		return io.EOF // per interface spec
 	}
-	for i, v := range rc.rows[rc.pos].cols {
+	for i, v := range rc.rows[rc.set][rc.pos].cols {
 		// TODO(bradfitz): convert to subset types? naah, I
 		// think the subset types should only be input to
 		// driver, but the sql package should be able to handle
@@ -832,3 +1060,31 @@ func converterForType(typ string) driver.ValueConverter {
 	}
 	panic("invalid fakedb column type of " + typ)
 }
+
+func colTypeToReflectType(typ string) reflect.Type {
+	switch typ {
+	case "bool":
+		return reflect.TypeOf(false)
+	case "nullbool":
+		return reflect.TypeOf(NullBool{})
+	case "int32":
+		return reflect.TypeOf(int32(0))
+	case "string":
+		return reflect.TypeOf("")
+	case "nullstring":
+		return reflect.TypeOf(NullString{})
+	case "int64":
+		return reflect.TypeOf(int64(0))
+	case "nullint64":
+		return reflect.TypeOf(NullInt64{})
+	case "float64":
+		return reflect.TypeOf(float64(0))
+	case "nullfloat64":
+		return reflect.TypeOf(NullFloat64{})
+	case "datetime":
+		return reflect.TypeOf(time.Time{})
+	case "blob":
+		return reflect.TypeOf([]byte(nil))
+	}
+	panic("invalid fakedb column type of " + typ)
+}
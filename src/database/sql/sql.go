@@ -13,10 +13,12 @@
 package sql
 
 import (
+	"context"
 	"database/sql/driver"
 	"errors"
 	"fmt"
 	"io"
+	"reflect"
 	"runtime"
 	"sort"
 	"sync"
@@ -262,8 +264,9 @@ type driverConn struct {
 
 	// guarded by db.mu
 	inUse      bool
-	onPut      []func() // code (with db.mu held) run when conn is next returned
-	dbmuClosed bool     // same as closed, but guarded by db.mu, for removeClosedStmtLocked
+	onPut      []func()      // code (with db.mu held) run when conn is next returned
+	dbmuClosed bool          // same as closed, but guarded by db.mu, for removeClosedStmtLocked
+	abandoned  chan struct{} // if non-nil, closed when the call dc was abandoned in returns; see run
 }
 
 func (dc *driverConn) releaseConn(err error) {
@@ -276,8 +279,10 @@ func (dc *driverConn) removeOpenStmt(si driver.Stmt) {
 	delete(dc.openStmt, si)
 }
 
-func (dc *driverConn) prepareLocked(query string) (driver.Stmt, error) {
-	si, err := dc.ci.Prepare(query)
+// prepareContext prepares query on dc, honoring ctx as the
+// ctxDriver functions do. dc must not be locked.
+func (dc *driverConn) prepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	si, err := ctxDriverPrepare(ctx, dc, query)
 	if err == nil {
 		// Track each driverConn's open statements, so we can close them
 		// before closing the conn.
@@ -292,14 +297,41 @@ func (dc *driverConn) prepareLocked(query string) (driver.Stmt, error) {
 		// *Stmt.connStmt, instead of returning a
 		// driver.Stmt), using driverStmt as a pointer
 		// everywhere, and making it a finalCloser.
+		dc.Lock()
 		if dc.openStmt == nil {
 			dc.openStmt = make(map[driver.Stmt]bool)
 		}
 		dc.openStmt[si] = true
+		dc.Unlock()
 	}
 	return si, err
 }
 
+// closeStmt closes si, a statement prepared on dc. If dc was
+// abandoned, the driver may still be using it, so si is instead
+// closed once the abandoned call returns, before dc is closed.
+func (dc *driverConn) closeStmt(si driver.Stmt) error {
+	dc.db.mu.Lock()
+	if dc.abandoned != nil {
+		dc.onPut = append(dc.onPut, func() {
+			si.Close()
+		})
+		dc.db.mu.Unlock()
+		return nil
+	}
+	dc.db.mu.Unlock()
+	dc.Lock()
+	defer dc.Unlock()
+	return si.Close()
+}
+
+// isAbandoned reports whether a call on dc was abandoned; see run.
+func (dc *driverConn) isAbandoned() bool {
+	dc.db.mu.Lock()
+	defer dc.db.mu.Unlock()
+	return dc.abandoned != nil
+}
+
 // the dc.db's Mutex is held.
 func (dc *driverConn) closeDBLocked() func() error {
 	dc.Lock()
@@ -359,9 +391,7 @@ type driverStmt struct {
 }
 
 func (ds *driverStmt) Close() error {
-	ds.Lock()
-	defer ds.Unlock()
-	return ds.si.Close()
+	return ds.Locker.(*driverConn).closeStmt(ds.si)
 }
 
 // depSet is a finalCloser's outstanding dependencies
@@ -474,15 +504,38 @@ func Open(driverName, dataSourceName string) (*DB, error) {
 // Ping verifies a connection to the database is still alive,
 // establishing a connection if necessary.
 func (db *DB) Ping() error {
-	// TODO(bradfitz): give drivers an optional hook to implement
-	// this in a more efficient or more reliable way, if they
-	// have one.
-	dc, err := db.conn(cachedOrNewConn)
+	return db.PingContext(context.Background())
+}
+
+// PingContext is like Ping but gives up, returning ctx.Err(), once
+// ctx is done. Drivers implementing driver.Pinger are asked to check
+// the connection.
+func (db *DB) PingContext(ctx context.Context) error {
+	var err error
+	for i := 0; i < maxBadConnRetries; i++ {
+		err = db.ping(ctx, cachedOrNewConn)
+		if err != driver.ErrBadConn {
+			break
+		}
+	}
+	if err == driver.ErrBadConn {
+		return db.ping(ctx, alwaysNewConn)
+	}
+	return err
+}
+
+func (db *DB) ping(ctx context.Context, strategy connReuseStrategy) error {
+	dc, err := db.conn(ctx, strategy)
 	if err != nil {
 		return err
 	}
-	db.putConn(dc, nil)
-	return nil
+	if pinger, ok := dc.ci.(driver.Pinger); ok {
+		dc.Lock()
+		err = pinger.Ping(ctx)
+		dc.Unlock()
+	}
+	db.putConn(dc, err)
+	return err
 }
 
 // Close closes the database, releasing any open resources.
@@ -663,13 +716,21 @@ type connRequest struct {
 
 var errDBClosed = errors.New("sql: database is closed")
 
-// conn returns a newly-opened or cached *driverConn.
-func (db *DB) conn(strategy connReuseStrategy) (*driverConn, error) {
+// conn returns a newly-opened or cached *driverConn. It gives up
+// waiting for a connection once ctx is done.
+func (db *DB) conn(ctx context.Context, strategy connReuseStrategy) (*driverConn, error) {
 	db.mu.Lock()
 	if db.closed {
 		db.mu.Unlock()
 		return nil, errDBClosed
 	}
+	// Check if the context is done before doing any work.
+	select {
+	case <-ctx.Done():
+		db.mu.Unlock()
+		return nil, ctx.Err()
+	default:
+	}
 
 	// Prefer a free connection, if possible.
 	numFree := len(db.freeConn)
@@ -690,8 +751,24 @@ func (db *DB) conn(strategy connReuseStrategy) (*driverConn, error) {
 		req := make(chan connRequest, 1)
 		db.connRequests = append(db.connRequests, req)
 		db.mu.Unlock()
-		ret := <-req
-		return ret.conn, ret.err
+		select {
+		case <-ctx.Done():
+			db.mu.Lock()
+			db.removeConnRequestLocked(req)
+			db.mu.Unlock()
+			// A connection may have been handed over before
+			// the request was removed; give it back.
+			select {
+			case ret := <-req:
+				if ret.conn != nil {
+					db.putConn(ret.conn, ret.err)
+				}
+			default:
+			}
+			return nil, ctx.Err()
+		case ret := <-req:
+			return ret.conn, ret.err
+		}
 	}
 
 	db.numOpen++ // optimistically
@@ -714,6 +791,19 @@ func (db *DB) conn(strategy connReuseStrategy) (*driverConn, error) {
 	return dc, nil
 }
 
+// removeConnRequestLocked removes req from the queue of callers
+// waiting for a connection, if it's still there.
+func (db *DB) removeConnRequestLocked(req chan connRequest) {
+	for i, r := range db.connRequests {
+		if r == req {
+			copy(db.connRequests[i:], db.connRequests[i+1:])
+			db.connRequests[len(db.connRequests)-1] = nil
+			db.connRequests = db.connRequests[:len(db.connRequests)-1]
+			return
+		}
+	}
+}
+
 var (
 	errConnClosed = errors.New("database/sql: internal sentinel error: conn is closed")
 	errConnBusy   = errors.New("database/sql: internal sentinel error: conn is busy")
@@ -758,6 +848,11 @@ func (db *DB) putConn(dc *driverConn, err error) {
 	if debugGetPut {
 		db.lastPut[dc] = stack()
 	}
+	if dc.abandoned != nil {
+		db.mu.Unlock()
+		go db.closeAbandoned(dc)
+		return
+	}
 	dc.inUse = false
 
 	for _, fn := range dc.onPut {
@@ -786,6 +881,22 @@ func (db *DB) putConn(dc *driverConn, err error) {
 	}
 }
 
+// closeAbandoned closes dc, which was abandoned in a driver call,
+// once that call returns. The driver may still be using dc until
+// then, so dc stays in use until it is closed.
+func (db *DB) closeAbandoned(dc *driverConn) {
+	<-dc.abandoned
+	db.mu.Lock()
+	dc.inUse = false
+	for _, fn := range dc.onPut {
+		fn()
+	}
+	dc.onPut = nil
+	db.maybeOpenNewConnections()
+	db.mu.Unlock()
+	dc.Close()
+}
+
 // Satisfy a connRequest or put the driverConn in the idle pool and return true
 // or return false.
 // putConnDBLocked will satisfy a connRequest if there is one, or it will
@@ -830,34 +941,39 @@ const maxBadConnRetries = 2
 // Multiple queries or executions may be run concurrently from the
 // returned statement.
 func (db *DB) Prepare(query string) (*Stmt, error) {
+	return db.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext is like Prepare but gives up, returning ctx.Err(),
+// once ctx is done. ctx bounds only the preparation, not the use of
+// the returned statement.
+func (db *DB) PrepareContext(ctx context.Context, query string) (*Stmt, error) {
 	var stmt *Stmt
 	var err error
 	for i := 0; i < maxBadConnRetries; i++ {
-		stmt, err = db.prepare(query, cachedOrNewConn)
+		stmt, err = db.prepare(ctx, query, cachedOrNewConn)
 		if err != driver.ErrBadConn {
 			break
 		}
 	}
 	if err == driver.ErrBadConn {
-		return db.prepare(query, alwaysNewConn)
+		return db.prepare(ctx, query, alwaysNewConn)
 	}
 	return stmt, err
 }
 
-func (db *DB) prepare(query string, strategy connReuseStrategy) (*Stmt, error) {
+func (db *DB) prepare(ctx context.Context, query string, strategy connReuseStrategy) (*Stmt, error) {
 	// TODO: check if db.driver supports an optional
 	// driver.Preparer interface and call that instead, if so,
 	// otherwise we make a prepared statement that's bound
 	// to a connection, and to execute this prepared statement
 	// we either need to use this connection (if it's free), else
 	// get a new connection + re-prepare + execute on that one.
-	dc, err := db.conn(strategy)
+	dc, err := db.conn(ctx, strategy)
 	if err != nil {
 		return nil, err
 	}
-	dc.Lock()
-	si, err := dc.prepareLocked(query)
-	dc.Unlock()
+	si, err := dc.prepareContext(ctx, query)
 	if err != nil {
 		db.putConn(dc, err)
 		return nil, err
@@ -876,123 +992,124 @@ func (db *DB) prepare(query string, strategy connReuseStrategy) (*Stmt, error) {
 // Exec executes a query without returning any rows.
 // The args are for any placeholder parameters in the query.
 func (db *DB) Exec(query string, args ...interface{}) (Result, error) {
+	return db.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext is like Exec but gives up, returning ctx.Err(), once
+// ctx is done. Drivers implementing the driver package's Context
+// interfaces are passed ctx. With other drivers, ExecContext returns
+// without waiting for the driver, and the connection it was using
+// is closed once the driver returns.
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (Result, error) {
 	var res Result
 	var err error
 	for i := 0; i < maxBadConnRetries; i++ {
-		res, err = db.exec(query, args, cachedOrNewConn)
+		res, err = db.exec(ctx, query, args, cachedOrNewConn)
 		if err != driver.ErrBadConn {
 			break
 		}
 	}
 	if err == driver.ErrBadConn {
-		return db.exec(query, args, alwaysNewConn)
+		return db.exec(ctx, query, args, alwaysNewConn)
 	}
 	return res, err
 }
 
-func (db *DB) exec(query string, args []interface{}, strategy connReuseStrategy) (res Result, err error) {
-	dc, err := db.conn(strategy)
+func (db *DB) exec(ctx context.Context, query string, args []interface{}, strategy connReuseStrategy) (Result, error) {
+	dc, err := db.conn(ctx, strategy)
 	if err != nil {
 		return nil, err
 	}
+	return db.execConn(ctx, dc, dc.releaseConn, query, args)
+}
+
+// execConn executes a query on the given connection.
+// The connection gets released by the releaseConn function.
+func (db *DB) execConn(ctx context.Context, dc *driverConn, releaseConn func(error), query string, args []interface{}) (res Result, err error) {
 	defer func() {
-		db.putConn(dc, err)
+		releaseConn(err)
 	}()
 
-	if execer, ok := dc.ci.(driver.Execer); ok {
-		dargs, err := driverArgs(nil, args)
+	resi, err := ctxDriverExec(ctx, dc, query, args)
+	if err != driver.ErrSkip {
 		if err != nil {
 			return nil, err
 		}
-		dc.Lock()
-		resi, err := execer.Exec(query, dargs)
-		dc.Unlock()
-		if err != driver.ErrSkip {
-			if err != nil {
-				return nil, err
-			}
-			return driverResult{dc, resi}, nil
-		}
+		return driverResult{dc, resi}, nil
 	}
 
-	dc.Lock()
-	si, err := dc.ci.Prepare(query)
-	dc.Unlock()
+	si, err := ctxDriverPrepare(ctx, dc, query)
 	if err != nil {
 		return nil, err
 	}
-	defer withLock(dc, func() { si.Close() })
-	return resultFromStatement(driverStmt{dc, si}, args...)
+	defer dc.closeStmt(si)
+	return resultFromStatement(ctx, dc, si, args...)
 }
 
 // Query executes a query that returns rows, typically a SELECT.
 // The args are for any placeholder parameters in the query.
 func (db *DB) Query(query string, args ...interface{}) (*Rows, error) {
+	return db.QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext is like Query but gives up, returning ctx.Err(), once
+// ctx is done, as ExecContext does. Once ctx is done, the returned
+// Rows also stop iterating: Next returns false and Err reports
+// ctx.Err().
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
 	var rows *Rows
 	var err error
 	for i := 0; i < maxBadConnRetries; i++ {
-		rows, err = db.query(query, args, cachedOrNewConn)
+		rows, err = db.query(ctx, query, args, cachedOrNewConn)
 		if err != driver.ErrBadConn {
 			break
 		}
 	}
 	if err == driver.ErrBadConn {
-		return db.query(query, args, alwaysNewConn)
+		return db.query(ctx, query, args, alwaysNewConn)
 	}
 	return rows, err
 }
 
-func (db *DB) query(query string, args []interface{}, strategy connReuseStrategy) (*Rows, error) {
-	ci, err := db.conn(strategy)
+func (db *DB) query(ctx context.Context, query string, args []interface{}, strategy connReuseStrategy) (*Rows, error) {
+	ci, err := db.conn(ctx, strategy)
 	if err != nil {
 		return nil, err
 	}
 
-	return db.queryConn(ci, ci.releaseConn, query, args)
+	return db.queryConn(ctx, ci, ci.releaseConn, query, args)
 }
 
 // queryConn executes a query on the given connection.
 // The connection gets released by the releaseConn function.
-func (db *DB) queryConn(dc *driverConn, releaseConn func(error), query string, args []interface{}) (*Rows, error) {
-	if queryer, ok := dc.ci.(driver.Queryer); ok {
-		dargs, err := driverArgs(nil, args)
+// The returned Rows stop iterating once ctx is done.
+func (db *DB) queryConn(ctx context.Context, dc *driverConn, releaseConn func(error), query string, args []interface{}) (*Rows, error) {
+	rowsi, err := ctxDriverQuery(ctx, dc, query, args)
+	if err != driver.ErrSkip {
 		if err != nil {
 			releaseConn(err)
 			return nil, err
 		}
-		dc.Lock()
-		rowsi, err := queryer.Query(query, dargs)
-		dc.Unlock()
-		if err != driver.ErrSkip {
-			if err != nil {
-				releaseConn(err)
-				return nil, err
-			}
-			// Note: ownership of dc passes to the *Rows, to be freed
-			// with releaseConn.
-			rows := &Rows{
-				dc:          dc,
-				releaseConn: releaseConn,
-				rowsi:       rowsi,
-			}
-			return rows, nil
+		// Note: ownership of dc passes to the *Rows, to be freed
+		// with releaseConn.
+		rows := &Rows{
+			ctx:         ctx,
+			dc:          dc,
+			releaseConn: releaseConn,
+			rowsi:       rowsi,
 		}
+		return rows, nil
 	}
 
-	dc.Lock()
-	si, err := dc.ci.Prepare(query)
-	dc.Unlock()
+	si, err := ctxDriverPrepare(ctx, dc, query)
 	if err != nil {
 		releaseConn(err)
 		return nil, err
 	}
 
-	ds := driverStmt{dc, si}
-	rowsi, err := rowsiFromStatement(ds, args...)
+	rowsi, err = rowsiFromStatement(ctx, dc, si, args...)
 	if err != nil {
-		dc.Lock()
-		si.Close()
-		dc.Unlock()
+		dc.closeStmt(si)
 		releaseConn(err)
 		return nil, err
 	}
@@ -1000,6 +1117,7 @@ func (db *DB) queryConn(dc *driverConn, releaseConn func(error), query string, a
 	// Note: ownership of ci passes to the *Rows, to be freed
 	// with releaseConn.
 	rows := &Rows{
+		ctx:         ctx,
 		dc:          dc,
 		releaseConn: releaseConn,
 		rowsi:       rowsi,
@@ -1012,35 +1130,46 @@ func (db *DB) queryConn(dc *driverConn, releaseConn func(error), query string, a
 // QueryRow always return a non-nil value. Errors are deferred until
 // Row's Scan method is called.
 func (db *DB) QueryRow(query string, args ...interface{}) *Row {
-	rows, err := db.Query(query, args...)
+	return db.QueryRowContext(context.Background(), query, args...)
+}
+
+// QueryRowContext is like QueryRow but honors ctx as QueryContext
+// does.
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *Row {
+	rows, err := db.QueryContext(ctx, query, args...)
 	return &Row{rows: rows, err: err}
 }
 
 // Begin starts a transaction. The isolation level is dependent on
 // the driver.
 func (db *DB) Begin() (*Tx, error) {
+	return db.BeginContext(context.Background())
+}
+
+// BeginContext is like Begin but gives up, returning ctx.Err(), once
+// ctx is done. ctx bounds only starting the transaction; the Context
+// methods of Tx bound the statements run in it.
+func (db *DB) BeginContext(ctx context.Context) (*Tx, error) {
 	var tx *Tx
 	var err error
 	for i := 0; i < maxBadConnRetries; i++ {
-		tx, err = db.begin(cachedOrNewConn)
+		tx, err = db.begin(ctx, cachedOrNewConn)
 		if err != driver.ErrBadConn {
 			break
 		}
 	}
 	if err == driver.ErrBadConn {
-		return db.begin(alwaysNewConn)
+		return db.begin(ctx, alwaysNewConn)
 	}
 	return tx, err
 }
 
-func (db *DB) begin(strategy connReuseStrategy) (tx *Tx, err error) {
-	dc, err := db.conn(strategy)
+func (db *DB) begin(ctx context.Context, strategy connReuseStrategy) (tx *Tx, err error) {
+	dc, err := db.conn(ctx, strategy)
 	if err != nil {
 		return nil, err
 	}
-	dc.Lock()
-	txi, err := dc.ci.Begin()
-	dc.Unlock()
+	txi, err := ctxDriverBegin(ctx, dc)
 	if err != nil {
 		db.putConn(dc, err)
 		return nil, err
@@ -1062,7 +1191,9 @@ func (db *DB) Driver() driver.Driver {
 // A transaction must end with a call to Commit or Rollback.
 //
 // After a call to Commit or Rollback, all operations on the
-// transaction fail with ErrTxDone.
+// transaction fail with ErrTxDone. So do they once a Context method
+// gives up on a driver that can't be interrupted: the connection is
+// then closed, which rolls the transaction back.
 type Tx struct {
 	db *DB
 
@@ -1103,6 +1234,30 @@ func (tx *Tx) grabConn() (*driverConn, error) {
 	return tx.dc, nil
 }
 
+// releaseConn is the releaseConn function for statements run on
+// tx's connection. The connection stays with tx unless a Context
+// method abandoned it, which ends the transaction.
+func (tx *Tx) releaseConn(error) {
+	if tx.done || !tx.dc.isAbandoned() {
+		return
+	}
+	// Close the prepared statements here rather than with
+	// closePrepared: the caller may be running one of them, holding
+	// its closemu. Their driver statements are closed once the
+	// abandoned call returns.
+	tx.stmts.Lock()
+	for _, stmt := range tx.stmts.v {
+		stmt.mu.Lock()
+		if !stmt.closed && stmt.stickyErr == nil {
+			stmt.closed = true
+			tx.dc.closeStmt(stmt.txsi.si)
+		}
+		stmt.mu.Unlock()
+	}
+	tx.stmts.Unlock()
+	tx.close()
+}
+
 // Closes all Stmts prepared for this transaction.
 func (tx *Tx) closePrepared() {
 	tx.stmts.Lock()
@@ -1149,6 +1304,13 @@ func (tx *Tx) Rollback() error {
 //
 // To use an existing prepared statement on this transaction, see Tx.Stmt.
 func (tx *Tx) Prepare(query string) (*Stmt, error) {
+	return tx.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext is like Prepare but gives up, returning ctx.Err(),
+// once ctx is done, as DB.ExecContext does. ctx bounds only the
+// preparation, not the use of the returned statement.
+func (tx *Tx) PrepareContext(ctx context.Context, query string) (*Stmt, error) {
 	// TODO(bradfitz): We could be more efficient here and either
 	// provide a method to take an existing Stmt (created on
 	// perhaps a different Conn), and re-create it on this Conn if
@@ -1167,10 +1329,9 @@ func (tx *Tx) Prepare(query string) (*Stmt, error) {
 		return nil, err
 	}
 
-	dc.Lock()
-	si, err := dc.ci.Prepare(query)
-	dc.Unlock()
+	si, err := ctxDriverPrepare(ctx, dc, query)
 	if err != nil {
+		tx.releaseConn(err)
 		return nil, err
 	}
 
@@ -1199,6 +1360,13 @@ func (tx *Tx) Prepare(query string) (*Stmt, error) {
 //  ...
 //  res, err := tx.Stmt(updateMoney).Exec(123.45, 98293203)
 func (tx *Tx) Stmt(stmt *Stmt) *Stmt {
+	return tx.StmtContext(context.Background(), stmt)
+}
+
+// StmtContext is like Stmt but gives up preparing the statement once
+// ctx is done, as PrepareContext does; the returned statement then
+// fails with ctx.Err().
+func (tx *Tx) StmtContext(ctx context.Context, stmt *Stmt) *Stmt {
 	// TODO(bradfitz): optimize this. Currently this re-prepares
 	// each time.  This is fine for now to illustrate the API but
 	// we should really cache already-prepared statements
@@ -1211,9 +1379,10 @@ func (tx *Tx) Stmt(stmt *Stmt) *Stmt {
 	if err != nil {
 		return &Stmt{stickyErr: err}
 	}
-	dc.Lock()
-	si, err := dc.ci.Prepare(stmt.query)
-	dc.Unlock()
+	si, err := ctxDriverPrepare(ctx, dc, stmt.query)
+	if err != nil {
+		tx.releaseConn(err)
+	}
 	txs := &Stmt{
 		db: tx.db,
 		tx: tx,
@@ -1233,53 +1402,44 @@ func (tx *Tx) Stmt(stmt *Stmt) *Stmt {
 // Exec executes a query that doesn't return rows.
 // For example: an INSERT and UPDATE.
 func (tx *Tx) Exec(query string, args ...interface{}) (Result, error) {
-	dc, err := tx.grabConn()
-	if err != nil {
-		return nil, err
-	}
-
-	if execer, ok := dc.ci.(driver.Execer); ok {
-		dargs, err := driverArgs(nil, args)
-		if err != nil {
-			return nil, err
-		}
-		dc.Lock()
-		resi, err := execer.Exec(query, dargs)
-		dc.Unlock()
-		if err == nil {
-			return driverResult{dc, resi}, nil
-		}
-		if err != driver.ErrSkip {
-			return nil, err
-		}
-	}
+	return tx.ExecContext(context.Background(), query, args...)
+}
 
-	dc.Lock()
-	si, err := dc.ci.Prepare(query)
-	dc.Unlock()
+// ExecContext is like Exec but gives up, returning ctx.Err(), once
+// ctx is done, as DB.ExecContext does.
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (Result, error) {
+	dc, err := tx.grabConn()
 	if err != nil {
 		return nil, err
 	}
-	defer withLock(dc, func() { si.Close() })
-
-	return resultFromStatement(driverStmt{dc, si}, args...)
+	return tx.db.execConn(ctx, dc, tx.releaseConn, query, args)
 }
 
 // Query executes a query that returns rows, typically a SELECT.
 func (tx *Tx) Query(query string, args ...interface{}) (*Rows, error) {
+	return tx.QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext is like Query but honors ctx as DB.QueryContext does.
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
 	dc, err := tx.grabConn()
 	if err != nil {
 		return nil, err
 	}
-	releaseConn := func(error) {}
-	return tx.db.queryConn(dc, releaseConn, query, args)
+	return tx.db.queryConn(ctx, dc, tx.releaseConn, query, args)
 }
 
 // QueryRow executes a query that is expected to return at most one row.
 // QueryRow always return a non-nil value. Errors are deferred until
 // Row's Scan method is called.
 func (tx *Tx) QueryRow(query string, args ...interface{}) *Row {
-	rows, err := tx.Query(query, args...)
+	return tx.QueryRowContext(context.Background(), query, args...)
+}
+
+// QueryRowContext is like QueryRow but honors ctx as DB.QueryContext
+// does.
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *Row {
+	rows, err := tx.QueryContext(ctx, query, args...)
 	return &Row{rows: rows, err: err}
 }
 
@@ -1319,12 +1479,18 @@ type Stmt struct {
 // Exec executes a prepared statement with the given arguments and
 // returns a Result summarizing the effect of the statement.
 func (s *Stmt) Exec(args ...interface{}) (Result, error) {
+	return s.ExecContext(context.Background(), args...)
+}
+
+// ExecContext is like Exec but gives up, returning ctx.Err(), once
+// ctx is done, as DB.ExecContext does.
+func (s *Stmt) ExecContext(ctx context.Context, args ...interface{}) (Result, error) {
 	s.closemu.RLock()
 	defer s.closemu.RUnlock()
 
 	var res Result
 	for i := 0; i < maxBadConnRetries; i++ {
-		dc, releaseConn, si, err := s.connStmt()
+		dc, releaseConn, si, err := s.connStmt(ctx)
 		if err != nil {
 			if err == driver.ErrBadConn {
 				continue
@@ -1332,7 +1498,7 @@ func (s *Stmt) Exec(args ...interface{}) (Result, error) {
 			return nil, err
 		}
 
-		res, err = resultFromStatement(driverStmt{dc, si}, args...)
+		res, err = resultFromStatement(ctx, dc, si, args...)
 		releaseConn(err)
 		if err != driver.ErrBadConn {
 			return res, err
@@ -1341,7 +1507,8 @@ func (s *Stmt) Exec(args ...interface{}) (Result, error) {
 	return nil, driver.ErrBadConn
 }
 
-func resultFromStatement(ds driverStmt, args ...interface{}) (Result, error) {
+func resultFromStatement(ctx context.Context, dc *driverConn, si driver.Stmt, args ...interface{}) (Result, error) {
+	ds := driverStmt{dc, si}
 	ds.Lock()
 	want := ds.si.NumInput()
 	ds.Unlock()
@@ -1358,13 +1525,11 @@ func resultFromStatement(ds driverStmt, args ...interface{}) (Result, error) {
 		return nil, err
 	}
 
-	ds.Lock()
-	resi, err := ds.si.Exec(dargs)
-	ds.Unlock()
+	resi, err := ctxDriverStmtExec(ctx, dc, si, dargs)
 	if err != nil {
 		return nil, err
 	}
-	return driverResult{ds.Locker, resi}, nil
+	return driverResult{dc, resi}, nil
 }
 
 // removeClosedStmtLocked removes closed conns in s.css.
@@ -1396,7 +1561,7 @@ func (s *Stmt) removeClosedStmtLocked() {
 // connStmt returns a free driver connection on which to execute the
 // statement, a function to call to release the connection, and a
 // statement bound to that connection.
-func (s *Stmt) connStmt() (ci *driverConn, releaseConn func(error), si driver.Stmt, err error) {
+func (s *Stmt) connStmt(ctx context.Context) (ci *driverConn, releaseConn func(error), si driver.Stmt, err error) {
 	if err = s.stickyErr; err != nil {
 		return
 	}
@@ -1415,15 +1580,14 @@ func (s *Stmt) connStmt() (ci *driverConn, releaseConn func(error), si driver.St
 		if err != nil {
 			return
 		}
-		releaseConn = func(error) {}
-		return ci, releaseConn, s.txsi.si, nil
+		return ci, s.tx.releaseConn, s.txsi.si, nil
 	}
 
 	s.removeClosedStmtLocked()
 	s.mu.Unlock()
 
 	// TODO(bradfitz): or always wait for one? make configurable later?
-	dc, err := s.db.conn(cachedOrNewConn)
+	dc, err := s.db.conn(ctx, cachedOrNewConn)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -1438,9 +1602,7 @@ func (s *Stmt) connStmt() (ci *driverConn, releaseConn func(error), si driver.St
 	s.mu.Unlock()
 
 	// No luck; we need to prepare the statement on this connection
-	dc.Lock()
-	si, err = dc.prepareLocked(s.query)
-	dc.Unlock()
+	si, err = dc.prepareContext(ctx, s.query)
 	if err != nil {
 		s.db.putConn(dc, err)
 		return nil, nil, nil, err
@@ -1456,12 +1618,17 @@ func (s *Stmt) connStmt() (ci *driverConn, releaseConn func(error), si driver.St
 // Query executes a prepared query statement with the given arguments
 // and returns the query results as a *Rows.
 func (s *Stmt) Query(args ...interface{}) (*Rows, error) {
+	return s.QueryContext(context.Background(), args...)
+}
+
+// QueryContext is like Query but honors ctx as DB.QueryContext does.
+func (s *Stmt) QueryContext(ctx context.Context, args ...interface{}) (*Rows, error) {
 	s.closemu.RLock()
 	defer s.closemu.RUnlock()
 
 	var rowsi driver.Rows
 	for i := 0; i < maxBadConnRetries; i++ {
-		dc, releaseConn, si, err := s.connStmt()
+		dc, releaseConn, si, err := s.connStmt(ctx)
 		if err != nil {
 			if err == driver.ErrBadConn {
 				continue
@@ -1469,11 +1636,12 @@ func (s *Stmt) Query(args ...interface{}) (*Rows, error) {
 			return nil, err
 		}
 
-		rowsi, err = rowsiFromStatement(driverStmt{dc, si}, args...)
+		rowsi, err = rowsiFromStatement(ctx, dc, si, args...)
 		if err == nil {
 			// Note: ownership of ci passes to the *Rows, to be freed
 			// with releaseConn.
 			rows := &Rows{
+				ctx:   ctx,
 				dc:    dc,
 				rowsi: rowsi,
 				// releaseConn set below
@@ -1494,7 +1662,8 @@ func (s *Stmt) Query(args ...interface{}) (*Rows, error) {
 	return nil, driver.ErrBadConn
 }
 
-func rowsiFromStatement(ds driverStmt, args ...interface{}) (driver.Rows, error) {
+func rowsiFromStatement(ctx context.Context, dc *driverConn, si driver.Stmt, args ...interface{}) (driver.Rows, error) {
+	ds := driverStmt{dc, si}
 	ds.Lock()
 	want := ds.si.NumInput()
 	ds.Unlock()
@@ -1511,9 +1680,7 @@ func rowsiFromStatement(ds driverStmt, args ...interface{}) (driver.Rows, error)
 		return nil, err
 	}
 
-	ds.Lock()
-	rowsi, err := ds.si.Query(dargs)
-	ds.Unlock()
+	rowsi, err := ctxDriverStmtQuery(ctx, dc, si, dargs)
 	if err != nil {
 		return nil, err
 	}
@@ -1532,7 +1699,13 @@ func rowsiFromStatement(ds driverStmt, args ...interface{}) (driver.Rows, error)
 //  var name string
 //  err := nameByUseridStmt.QueryRow(id).Scan(&name)
 func (s *Stmt) QueryRow(args ...interface{}) *Row {
-	rows, err := s.Query(args...)
+	return s.QueryRowContext(context.Background(), args...)
+}
+
+// QueryRowContext is like QueryRow but honors ctx as DB.QueryContext
+// does.
+func (s *Stmt) QueryRowContext(ctx context.Context, args ...interface{}) *Row {
+	rows, err := s.QueryContext(ctx, args...)
 	if err != nil {
 		return &Row{err: err}
 	}
@@ -1592,13 +1765,14 @@ func (s *Stmt) finalClose() error {
 //     err = rows.Err() // get any error encountered during iteration
 //     ...
 type Rows struct {
-	dc          *driverConn // owned; must call releaseConn when closed to release
+	ctx         context.Context // nil means none
+	dc          *driverConn     // owned; must call releaseConn when closed to release
 	releaseConn func(error)
 	rowsi       driver.Rows
 
 	closed    bool
 	lastcols  []driver.Value
-	lasterr   error       // non-nil only if closed is true
+	lasterr   error       // non-nil if closed is true, or at the end of a result set followed by another
 	closeStmt driver.Stmt // if non-nil, statement to Close on close
 }
 
@@ -1608,14 +1782,63 @@ type Rows struct {
 // the two cases.
 //
 // Every call to Scan, even the first one, must be preceded by a call to Next.
+//
+// When Next returns false at the end of a result set that is followed by
+// another, the Rows stay open so that NextResultSet can advance to it, and
+// its connection is not released until the Rows are closed.
 func (rs *Rows) Next() bool {
 	if rs.closed {
 		return false
 	}
+	if rs.ctx != nil {
+		if err := rs.ctx.Err(); err != nil {
+			rs.lasterr = err
+			rs.Close()
+			return false
+		}
+	}
+	if rs.lasterr != nil {
+		// At the end of a result set that is followed by another.
+		return false
+	}
 	if rs.lastcols == nil {
 		rs.lastcols = make([]driver.Value, len(rs.rowsi.Columns()))
 	}
 	rs.lasterr = rs.rowsi.Next(rs.lastcols)
+	if rs.lasterr != nil {
+		// Stay open at the end of a result set if another
+		// follows, so that NextResultSet can advance to it.
+		if rs.lasterr == io.EOF {
+			if nextResultSet, ok := rs.rowsi.(driver.RowsNextResultSet); ok && nextResultSet.HasNextResultSet() {
+				return false
+			}
+		}
+		rs.Close()
+		return false
+	}
+	return true
+}
+
+// NextResultSet prepares the next result set for reading. It returns
+// true if there is a further result set, or false if there is none or
+// an error happened while advancing to it. Err should be consulted to
+// distinguish between the two cases. Any rows remaining in the current
+// result set are skipped.
+//
+// After calling NextResultSet, Next must be called before the first
+// call to Scan. The columns and their types may differ from those of
+// the previous result set.
+func (rs *Rows) NextResultSet() bool {
+	if rs.closed {
+		return false
+	}
+	rs.lastcols = nil
+	nextResultSet, ok := rs.rowsi.(driver.RowsNextResultSet)
+	if !ok {
+		rs.Close()
+		return false
+	}
+	rs.lasterr = nextResultSet.NextResultSet()
 	if rs.lasterr != nil {
 		rs.Close()
 		return false
@@ -1645,6 +1868,110 @@ func (rs *Rows) Columns() ([]string, error) {
 	return rs.rowsi.Columns(), nil
 }
 
+// ColumnTypes returns column information such as column type, length,
+// and nullable for the current result set. Some information may not
+// be available from some drivers.
+// ColumnTypes returns an error if the rows are closed, or if the rows
+// are from QueryRow and there was a deferred error.
+func (rs *Rows) ColumnTypes() ([]*ColumnType, error) {
+	if rs.closed {
+		return nil, errors.New("sql: Rows are closed")
+	}
+	if rs.rowsi == nil {
+		return nil, errors.New("sql: no Rows available")
+	}
+	return rowsColumnInfoSetup(rs.rowsi), nil
+}
+
+// ColumnType contains the name and type of a column.
+type ColumnType struct {
+	name string
+
+	hasNullable       bool
+	hasLength         bool
+	hasPrecisionScale bool
+
+	nullable     bool
+	length       int64
+	databaseType string
+	precision    int64
+	scale        int64
+	scanType     reflect.Type
+}
+
+// Name returns the name or alias of the column.
+func (ci *ColumnType) Name() string {
+	return ci.name
+}
+
+// Length returns the column type length for variable length column
+// types such as text and binary field types. If the type length is
+// unbounded the value will be math.MaxInt64 (any database limits will
+// still apply). If the column type is not variable length, such as an
+// int, or if not supported by the driver, ok is false.
+func (ci *ColumnType) Length() (length int64, ok bool) {
+	return ci.length, ci.hasLength
+}
+
+// DecimalSize returns the scale and precision of a decimal type.
+// If not applicable or if not supported, ok is false.
+func (ci *ColumnType) DecimalSize() (precision, scale int64, ok bool) {
+	return ci.precision, ci.scale, ci.hasPrecisionScale
+}
+
+// ScanType returns a Go type suitable for scanning into using
+// Rows.Scan. If a driver does not support this property, ScanType
+// returns the type of an empty interface.
+func (ci *ColumnType) ScanType() reflect.Type {
+	return ci.scanType
+}
+
+// Nullable reports whether the column may be null.
+// If a driver does not support this property, ok is false.
+func (ci *ColumnType) Nullable() (nullable, ok bool) {
+	return ci.nullable, ci.hasNullable
+}
+
+// DatabaseTypeName returns the database system name of the column
+// type, such as "VARCHAR", "TEXT", "NVARCHAR", "DECIMAL", "BOOL",
+// "INT" or "BIGINT". If an empty string is returned, the driver type
+// name is not supported. Consult your driver documentation for a list
+// of driver data types. Length specifiers are not included.
+func (ci *ColumnType) DatabaseTypeName() string {
+	return ci.databaseType
+}
+
+func rowsColumnInfoSetup(rowsi driver.Rows) []*ColumnType {
+	names := rowsi.Columns()
+
+	list := make([]*ColumnType, len(names))
+	for i := range list {
+		ci := &ColumnType{
+			name: names[i],
+		}
+		list[i] = ci
+
+		if prop, ok := rowsi.(driver.RowsColumnTypeScanType); ok {
+			ci.scanType = prop.ColumnTypeScanType(i)
+		} else {
+			ci.scanType = reflect.TypeOf(new(interface{})).Elem()
+		}
+		if prop, ok := rowsi.(driver.RowsColumnTypeDatabaseTypeName); ok {
+			ci.databaseType = prop.ColumnTypeDatabaseTypeName(i)
+		}
+		if prop, ok := rowsi.(driver.RowsColumnTypeLength); ok {
+			ci.length, ci.hasLength = prop.ColumnTypeLength(i)
+		}
+		if prop, ok := rowsi.(driver.RowsColumnTypeNullable); ok {
+			ci.nullable, ci.hasNullable = prop.ColumnTypeNullable(i)
+		}
+		if prop, ok := rowsi.(driver.RowsColumnTypePrecisionScale); ok {
+			ci.precision, ci.scale, ci.hasPrecisionScale = prop.ColumnTypePrecisionScale(i)
+		}
+	}
+	return list
+}
+
 // Scan copies the columns in the current row into the values pointed
 // at by dest.
 //
@@ -1661,7 +1988,9 @@ func (rs *Rows) Scan(dest ...interface{}) error {
 	if rs.closed {
 		return errors.New("sql: Rows are closed")
 	}
-	if rs.lastcols == nil {
+	// At the end of a result set followed by another, lastcols
+	// still holds the last row, which Next did not return.
+	if rs.lastcols == nil || rs.lasterr != nil {
 		return errors.New("sql: Scan called without calling Next")
 	}
 	if len(dest) != len(rs.lastcols) {
@@ -1679,8 +2008,10 @@ func (rs *Rows) Scan(dest ...interface{}) error {
 var rowsCloseHook func(*Rows, *error)
 
 // Close closes the Rows, preventing further enumeration. If Next returns
-// false, the Rows are closed automatically and it will suffice to check the
-// result of Err. Close is idempotent and does not affect the result of Err.
+// false and there is no further result set, the Rows are closed
+// automatically and it will suffice to check the result of Err. Otherwise,
+// Close must be called unless NextResultSet is used to read the remaining
+// result sets. Close is idempotent and does not affect the result of Err.
 func (rs *Rows) Close() error {
 	if rs.closed {
 		return nil
@@ -1787,10 +2118,3 @@ func stack() string {
 	var buf [2 << 10]byte
 	return string(buf[:runtime.Stack(buf[:], false)])
 }
-
-// withLock runs while holding lk.
-func withLock(lk sync.Locker, fn func()) {
-	lk.Lock()
-	fn()
-	lk.Unlock()
-}
@@ -5,9 +5,11 @@
 package sql
 
 import (
+	"context"
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"math"
 	"math/rand"
 	"reflect"
 	"runtime"
@@ -136,6 +138,22 @@ func (db *DB) numDepsPollUntil(want int, d time.Duration) int {
 	}
 }
 
+// Abandoned connections are closed via a goroutine, so this polls
+// waiting for the number of open connections to fall to want,
+// waiting up to d.
+func (db *DB) numOpenPollUntil(want int, d time.Duration) int {
+	deadline := time.Now().Add(d)
+	for {
+		db.mu.Lock()
+		n := db.numOpen
+		db.mu.Unlock()
+		if n <= want || time.Now().After(deadline) {
+			return n
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 func (db *DB) numFreeConns() int {
 	db.mu.Lock()
 	defer db.mu.Unlock()
@@ -206,6 +224,456 @@ func TestQuery(t *testing.T) {
 	}
 }
 
+func TestMultiResultSetQuery(t *testing.T) {
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+	rows, err := db.Query("SELECT|people|age,name|;SELECT|people|name|age=?", 3)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	type row1 struct {
+		age  int
+		name string
+	}
+	got1 := []row1{}
+	for rows.Next() {
+		var r row1
+		err = rows.Scan(&r.age, &r.name)
+		if err != nil {
+			t.Fatalf("Scan: %v", err)
+		}
+		got1 = append(got1, r)
+	}
+	err = rows.Err()
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	want1 := []row1{
+		{age: 1, name: "Alice"},
+		{age: 2, name: "Bob"},
+		{age: 3, name: "Chris"},
+	}
+	if !reflect.DeepEqual(got1, want1) {
+		t.Errorf("mismatch.\n got1: %#v\nwant: %#v", got1, want1)
+	}
+
+	if !rows.NextResultSet() {
+		t.Fatalf("NextResultSet = false; want true (Err = %v)", rows.Err())
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		t.Fatalf("Columns: %v", err)
+	}
+	if want := []string{"name"}; !reflect.DeepEqual(cols, want) {
+		t.Errorf("Columns = %q; want %q", cols, want)
+	}
+	var got2 []string
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			t.Fatalf("Scan: %v", err)
+		}
+		got2 = append(got2, name)
+	}
+	err = rows.Err()
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if want2 := []string{"Chris"}; !reflect.DeepEqual(got2, want2) {
+		t.Errorf("mismatch.\n got2: %#v\nwant: %#v", got2, want2)
+	}
+
+	if rows.NextResultSet() {
+		t.Error("NextResultSet after last result set = true; want false")
+	}
+	if err := rows.Err(); err != nil {
+		t.Errorf("Err after last result set: %v", err)
+	}
+	if n := db.numFreeConns(); n != 1 {
+		t.Fatalf("free conns after last result set = %d; want 1", n)
+	}
+}
+
+func TestNextResultSetSkipsRows(t *testing.T) {
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+	rows, err := db.Query("SELECT|people|name|;SELECT|people|age|")
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		t.Fatalf("Next = false; want true (Err = %v)", rows.Err())
+	}
+	if !rows.NextResultSet() {
+		t.Fatalf("NextResultSet = false; want true (Err = %v)", rows.Err())
+	}
+	var age int
+	if err := rows.Scan(&age); err == nil {
+		t.Error("Scan before Next after NextResultSet succeeded; want error")
+	}
+	var ages []int
+	for rows.Next() {
+		if err := rows.Scan(&age); err != nil {
+			t.Fatalf("Scan: %v", err)
+		}
+		ages = append(ages, age)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(ages, want) {
+		t.Errorf("ages = %v; want %v", ages, want)
+	}
+}
+
+func TestScanAtEndOfResultSet(t *testing.T) {
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+	rows, err := db.Query("SELECT|people|name|;SELECT|people|age|")
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	var name string
+	if err := rows.Scan(&name); err == nil {
+		t.Errorf("Scan after Next = false succeeded with %q; want error", name)
+	}
+	if !rows.NextResultSet() {
+		t.Fatalf("NextResultSet = false; want true (Err = %v)", rows.Err())
+	}
+}
+
+func TestRowsHoldConnBeforeNextResultSet(t *testing.T) {
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+	rows, err := db.Query("SELECT|people|name|;SELECT|people|age|")
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	for rows.Next() {
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	// Another result set follows, so the Rows stay open and keep
+	// their connection until they are closed.
+	if n := db.numFreeConns(); n != 0 {
+		t.Errorf("free conns after first result set = %d; want 0", n)
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if n := db.numFreeConns(); n != 1 {
+		t.Errorf("free conns after Close = %d; want 1", n)
+	}
+}
+
+func TestRowsColumnTypes(t *testing.T) {
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+	exec(t, db, "CREATE|t|id=int32,name=nullstring")
+	rows, err := db.Query("SELECT|people|name,age,photo,dead,bdate|;SELECT|t|id,name|")
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	defer rows.Close()
+
+	type colType struct {
+		name             string
+		scanType         reflect.Type
+		databaseTypeName string
+		length           int64
+		hasLength        bool
+		nullable         bool
+		hasNullable      bool
+	}
+	check := func(want []colType) {
+		tt, err := rows.ColumnTypes()
+		if err != nil {
+			t.Fatalf("ColumnTypes: %v", err)
+		}
+		if len(tt) != len(want) {
+			t.Fatalf("got %d column types; want %d", len(tt), len(want))
+		}
+		for i, ct := range tt {
+			var got colType
+			got.name = ct.Name()
+			got.scanType = ct.ScanType()
+			got.databaseTypeName = ct.DatabaseTypeName()
+			got.length, got.hasLength = ct.Length()
+			got.nullable, got.hasNullable = ct.Nullable()
+			if got != want[i] {
+				t.Errorf("column %d: got %+v; want %+v", i, got, want[i])
+			}
+			if _, _, ok := ct.DecimalSize(); ok {
+				t.Errorf("column %d: DecimalSize ok = true; want false", i)
+			}
+		}
+	}
+	check([]colType{
+		{"name", reflect.TypeOf(""), "STRING", math.MaxInt64, true, false, true},
+		{"age", reflect.TypeOf(int32(0)), "INT32", 0, false, false, true},
+		{"photo", reflect.TypeOf([]byte(nil)), "BLOB", math.MaxInt64, true, false, true},
+		{"dead", reflect.TypeOf(false), "BOOL", 0, false, false, true},
+		{"bdate", reflect.TypeOf(time.Time{}), "DATETIME", 0, false, false, true},
+	})
+	if !rows.NextResultSet() {
+		t.Fatalf("NextResultSet = false; want true (Err = %v)", rows.Err())
+	}
+	check([]colType{
+		{"id", reflect.TypeOf(int32(0)), "INT32", 0, false, false, true},
+		{"name", reflect.TypeOf(NullString{}), "STRING", math.MaxInt64, true, true, true},
+	})
+
+	rows.Close()
+	if _, err := rows.ColumnTypes(); err == nil {
+		t.Error("ColumnTypes on closed Rows succeeded; want error")
+	}
+}
+
+func TestQueryContext(t *testing.T) {
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rows, err := db.QueryContext(ctx, "SELECT|people|age,name|")
+	if err != nil {
+		t.Fatalf("QueryContext: %v", err)
+	}
+	n := 0
+	for rows.Next() {
+		n++
+		if n == 2 {
+			cancel()
+		}
+	}
+	if n != 2 {
+		t.Errorf("read %d rows; want 2 before cancelation", n)
+	}
+	if err := rows.Err(); err != context.Canceled {
+		t.Errorf("Err = %v; want %v", err, context.Canceled)
+	}
+	// The canceled Rows must have released their connection.
+	if n := db.numFreeConns(); n != 1 {
+		t.Errorf("free conns after canceled query = %d; want 1", n)
+	}
+
+	if _, err := db.QueryContext(ctx, "SELECT|people|age,name|"); err != context.Canceled {
+		t.Errorf("QueryContext with canceled context = %v; want %v", err, context.Canceled)
+	}
+	if _, err := db.ExecContext(ctx, "INSERT|people|name=Dave,age=?", 4); err != context.Canceled {
+		t.Errorf("ExecContext with canceled context = %v; want %v", err, context.Canceled)
+	}
+	var name string
+	if err := db.QueryRowContext(ctx, "SELECT|people|name|age=?", 1).Scan(&name); err != context.Canceled {
+		t.Errorf("QueryRowContext with canceled context = %v; want %v", err, context.Canceled)
+	}
+}
+
+// Tests that a caller waiting for a connection at the MaxOpenConns
+// limit gives up when its context is done.
+func TestConnWaitContext(t *testing.T) {
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+	db.SetMaxOpenConns(1)
+
+	rows, err := db.Query("SELECT|people|name|")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := db.ExecContext(ctx, "INSERT|people|name=Dave,age=?", 4); err != context.DeadlineExceeded {
+		t.Fatalf("ExecContext = %v; want %v", err, context.DeadlineExceeded)
+	}
+	db.mu.Lock()
+	pending := len(db.connRequests)
+	db.mu.Unlock()
+	if pending != 0 {
+		t.Errorf("%d connection requests left queued; want 0", pending)
+	}
+	rows.Close()
+
+	// The connection is still usable once released.
+	if _, err := db.Exec("INSERT|people|name=Dave,age=?", 4); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Tests that a Context method returns once its context is done, even
+// though the driver can't be interrupted, and that the connection it
+// abandons is closed, not reused.
+func TestQueryContextAbandon(t *testing.T) {
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+
+	drv := db.driver.(*fakeDriver)
+	drv.mu.Lock()
+	closes0 := drv.closeCount
+	drv.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := db.QueryContext(ctx, "WAIT|500ms|SELECT|people|name|")
+	if err != context.DeadlineExceeded {
+		t.Fatalf("QueryContext = %v; want %v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > 400*time.Millisecond {
+		t.Errorf("QueryContext returned after %v; want soon after the deadline", d)
+	}
+	if n := db.numFreeConns(); n != 0 {
+		t.Errorf("free conns after abandoned query = %d; want 0", n)
+	}
+
+	if n := db.numOpenPollUntil(0, 5*time.Second); n != 0 {
+		t.Fatalf("%d connections open after abandoned query returned; want 0", n)
+	}
+	drv.mu.Lock()
+	closes := drv.closeCount - closes0
+	drv.mu.Unlock()
+	if closes != 1 {
+		t.Errorf("closed %d connections; want 1", closes)
+	}
+
+	// The DB is still usable.
+	var name string
+	if err := db.QueryRow("SELECT|people|name|age=?", 1).Scan(&name); err != nil || name != "Alice" {
+		t.Errorf("QueryRow = %q, %v; want %q, nil", name, err, "Alice")
+	}
+}
+
+// Tests that a statement in a transaction that gives up on its context
+// ends the transaction.
+func TestTxStmtExecContextAbandon(t *testing.T) {
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt, err := tx.Prepare("WAIT|500ms|INSERT|people|name=Eve,age=?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := stmt.ExecContext(ctx, 5); err != context.DeadlineExceeded {
+		t.Fatalf("ExecContext = %v; want %v", err, context.DeadlineExceeded)
+	}
+	if _, err := stmt.Exec(5); err == nil {
+		t.Error("Exec on statement of ended transaction succeeded")
+	}
+	if err := stmt.Close(); err != nil {
+		t.Errorf("Close = %v", err)
+	}
+	if err := tx.Commit(); err != ErrTxDone {
+		t.Errorf("Commit = %v; want %v", err, ErrTxDone)
+	}
+	if n := db.numOpenPollUntil(0, 5*time.Second); n != 0 {
+		t.Fatalf("%d connections open after abandoned transaction; want 0", n)
+	}
+}
+
+// Tests that drivers implementing the driver package's Context
+// interfaces get the context, and keep their connections.
+func TestContextDriver(t *testing.T) {
+	newTestDB(t, "people").Close()
+	db, err := Open("test", fakeDBName+";ctx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDB(t, db)
+
+	if err := db.PingContext(context.Background()); err != nil {
+		t.Fatalf("PingContext: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := db.ExecContext(ctx, "WAIT|5s|INSERT|people|name=Eve,age=?", 5); err != context.DeadlineExceeded {
+		t.Fatalf("ExecContext = %v; want %v", err, context.DeadlineExceeded)
+	}
+	// The driver returned, so the connection was not abandoned.
+	if n := db.numFreeConns(); n != 1 {
+		t.Errorf("free conns = %d; want 1", n)
+	}
+	if err := db.PingContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("PingContext = %v; want %v", err, context.DeadlineExceeded)
+	}
+}
+
+// Tests the Context methods with contexts that are not done.
+func TestContextMethods(t *testing.T) {
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		t.Fatalf("PingContext: %v", err)
+	}
+	stmt, err := db.PrepareContext(ctx, "SELECT|people|name|age=?")
+	if err != nil {
+		t.Fatalf("PrepareContext: %v", err)
+	}
+	defer stmt.Close()
+	var name string
+	if err := stmt.QueryRowContext(ctx, 2).Scan(&name); err != nil || name != "Bob" {
+		t.Errorf("Stmt.QueryRowContext = %q, %v; want Bob", name, err)
+	}
+
+	tx, err := db.BeginContext(ctx)
+	if err != nil {
+		t.Fatalf("BeginContext: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT|people|name=Dave,age=?", 4); err != nil {
+		t.Errorf("Tx.ExecContext: %v", err)
+	}
+	if err := tx.StmtContext(ctx, stmt).QueryRowContext(ctx, 4).Scan(&name); err != nil || name != "Dave" {
+		t.Errorf("Tx.StmtContext QueryRowContext = %q, %v; want Dave", name, err)
+	}
+	ins, err := tx.PrepareContext(ctx, "INSERT|people|name=Eve,age=?")
+	if err != nil {
+		t.Fatalf("Tx.PrepareContext: %v", err)
+	}
+	if _, err := ins.ExecContext(ctx, 5); err != nil {
+		t.Errorf("Stmt.ExecContext: %v", err)
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT|people|name|")
+	if err != nil {
+		t.Fatalf("Tx.QueryContext: %v", err)
+	}
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	if err := rows.Err(); err != nil || n != 5 {
+		t.Errorf("Tx.QueryContext read %d rows, err %v; want 5 rows", n, err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	if err := db.PingContext(ctx); err != context.Canceled {
+		t.Errorf("PingContext with canceled context = %v; want %v", err, context.Canceled)
+	}
+	if _, err := db.BeginContext(ctx); err != context.Canceled {
+		t.Errorf("BeginContext with canceled context = %v; want %v", err, context.Canceled)
+	}
+	if _, err := stmt.QueryContext(ctx, 1); err != context.Canceled {
+		t.Errorf("Stmt.QueryContext with canceled context = %v; want %v", err, context.Canceled)
+	}
+}
+
 func TestByteOwnership(t *testing.T) {
 	db := newTestDB(t, "people")
 	defer closeDB(t, db)
@@ -1085,17 +1553,17 @@ func TestMaxOpenConnsOnBusy(t *testing.T) {
 
 	db.SetMaxOpenConns(3)
 
-	conn0, err := db.conn(cachedOrNewConn)
+	conn0, err := db.conn(context.Background(), cachedOrNewConn)
 	if err != nil {
 		t.Fatalf("db open conn fail: %v", err)
 	}
 
-	conn1, err := db.conn(cachedOrNewConn)
+	conn1, err := db.conn(context.Background(), cachedOrNewConn)
 	if err != nil {
 		t.Fatalf("db open conn fail: %v", err)
 	}
 
-	conn2, err := db.conn(cachedOrNewConn)
+	conn2, err := db.conn(context.Background(), cachedOrNewConn)
 	if err != nil {
 		t.Fatalf("db open conn fail: %v", err)
 	}
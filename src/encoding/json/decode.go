@@ -16,6 +16,7 @@ import (
 	"reflect"
 	"runtime"
 	"strconv"
+	"sync"
 	"unicode"
 	"unicode/utf16"
 	"unicode/utf8"
@@ -37,6 +38,9 @@ import (
 // To unmarshal JSON into a struct, Unmarshal matches incoming object
 // keys to the keys used by Marshal (either the struct field name or its tag),
 // preferring an exact match but also accepting a case-insensitive match.
+// Keys that match no field are ignored, and a key that appears more than
+// once sets the field to its last value. The strict decoding options of
+// Decoder turn these cases into errors.
 //
 // To unmarshal JSON into an interface value,
 // Unmarshal stores one of these in the interface value:
@@ -99,6 +103,24 @@ func (e *UnmarshalTypeError) Error() string {
 	return "json: cannot unmarshal " + e.Value + " into Go value of type " + e.Type.String()
 }
 
+// A StrictError describes JSON input rejected by one of the strict
+// decoding options of a Decoder, such as an unknown or duplicate
+// object key. Path and Offset are relative to the value passed to
+// Decode, so when Decode reads an element in the middle of a stream
+// consumed with Token, they do not include the enclosing elements.
+type StrictError struct {
+	Path   string // JSON path of the offending element, such as ".servers[2].port"
+	Msg    string // description of the error
+	Offset int64  // error occurred after reading Offset bytes
+}
+
+func (e *StrictError) Error() string {
+	if e.Path == "" {
+		return "json: " + e.Msg
+	}
+	return "json: " + e.Msg + " at " + e.Path
+}
+
 // An UnmarshalFieldError describes a JSON object key that
 // led to an unexported (and therefore unwritable) struct field.
 // (No longer used; kept for compatibility.)
@@ -175,6 +197,22 @@ type decodeState struct {
 	nextscan   scanner // for calls to nextValue
 	savedError error
 	useNumber  bool
+
+	// The strict decoding options of a Decoder.
+	disallowUnknownFields bool
+	exactCase             bool
+	disallowDuplicateKeys bool
+
+	// path is the JSON path of the value being decoded,
+	// maintained only while a strict decoding option is set.
+	path []pathElem
+}
+
+// A pathElem is an element of the JSON path of a value:
+// an object key, or an array index if index >= 0.
+type pathElem struct {
+	key   string
+	index int
 }
 
 // errPhase is used for errors that should not happen unless
@@ -186,9 +224,71 @@ func (d *decodeState) init(data []byte) *decodeState {
 	d.data = data
 	d.off = 0
 	d.savedError = nil
+	d.path = d.path[:0]
 	return d
 }
 
+// strict reports whether any of the strict decoding options is set.
+func (d *decodeState) strict() bool {
+	return d.disallowUnknownFields || d.exactCase || d.disallowDuplicateKeys
+}
+
+// pushKey and pushIndex add the object key or array index of the
+// next value to d.path; popPath removes it again. The key is only
+// converted to a string, which allocates, when decoding strictly.
+func (d *decodeState) pushKey(key []byte) {
+	if d.strict() {
+		d.path = append(d.path, pathElem{key: string(key), index: -1})
+	}
+}
+
+func (d *decodeState) pushIndex(i int) {
+	if d.strict() {
+		d.path = append(d.path, pathElem{index: i})
+	}
+}
+
+func (d *decodeState) popPath() {
+	if d.strict() {
+		d.path = d.path[:len(d.path)-1]
+	}
+}
+
+// pathString formats d.path as in ".servers[2].port". Keys that are
+// not identifiers are quoted, as in `["content-type"]`.
+func (d *decodeState) pathString() string {
+	var buf bytes.Buffer
+	for _, e := range d.path {
+		switch {
+		case e.index >= 0:
+			fmt.Fprintf(&buf, "[%d]", e.index)
+		case isIdentifier(e.key):
+			buf.WriteByte('.')
+			buf.WriteString(e.key)
+		default:
+			fmt.Fprintf(&buf, "[%s]", strconv.Quote(e.key))
+		}
+	}
+	return buf.String()
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		if !unicode.IsLetter(c) && c != '_' && (i == 0 || !unicode.IsDigit(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// strictError returns a StrictError with message msg for the value at d.path.
+func (d *decodeState) strictError(msg string) *StrictError {
+	return &StrictError{Path: d.pathString(), Msg: msg, Offset: int64(d.off)}
+}
+
 // error aborts the decoding by panicking with err.
 func (d *decodeState) error(err error) {
 	panic(err)
@@ -278,7 +378,115 @@ func (d *decodeState) value(v reflect.Value) {
 		return
 	}
 
-	switch op := d.scanWhile(scanSkipSpace); op {
+	typeDecoder(v.Type())(d, d.scanWhile(scanSkipSpace), v)
+}
+
+// A decoderFunc decodes a JSON value from d.data[d.off-1:] into v.
+// op is the scan code of the first byte of the value, which the caller
+// has read already.
+type decoderFunc func(d *decodeState, op int, v reflect.Value)
+
+var decoderCache struct {
+	sync.RWMutex
+	m map[reflect.Type]decoderFunc
+}
+
+// typeDecoder returns the decoderFunc for values of type t.
+// Decoding through it walks the structure of t only once per type,
+// when the decoder is built, rather than for every value.
+func typeDecoder(t reflect.Type) decoderFunc {
+	decoderCache.RLock()
+	f := decoderCache.m[t]
+	decoderCache.RUnlock()
+	if f != nil {
+		return f
+	}
+	// Building the decoder is a separate function so that the
+	// variables captured below are not allocated on every call.
+	return buildTypeDecoder(t)
+}
+
+func buildTypeDecoder(t reflect.Type) (f decoderFunc) {
+	// To deal with recursive types, populate the map with an
+	// indirect func before we build it. This type waits on the
+	// real func (f) to be ready and then calls it.  This indirect
+	// func is only used for recursive types.
+	decoderCache.Lock()
+	if decoderCache.m == nil {
+		decoderCache.m = make(map[reflect.Type]decoderFunc)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	decoderCache.m[t] = func(d *decodeState, op int, v reflect.Value) {
+		wg.Wait()
+		f(d, op, v)
+	}
+	decoderCache.Unlock()
+
+	// Build the decoder without lock.
+	// Might duplicate effort but won't hold other computations back.
+	f = newTypeDecoder(t)
+	wg.Done()
+	decoderCache.Lock()
+	decoderCache.m[t] = f
+	decoderCache.Unlock()
+	return f
+}
+
+var (
+	unmarshalerType     = reflect.TypeOf(new(Unmarshaler)).Elem()
+	textUnmarshalerType = reflect.TypeOf(new(encoding.TextUnmarshaler)).Elem()
+)
+
+// newTypeDecoder constructs a decoderFunc for a type.
+// Interfaces, and types whose values indirect would find an
+// Unmarshaler or encoding.TextUnmarshaler for, use valueDecoder.
+func newTypeDecoder(t reflect.Type) decoderFunc {
+	switch {
+	case t.Kind() == reflect.Interface:
+		return valueDecoder
+	case t.Kind() == reflect.Ptr:
+		if t.Implements(unmarshalerType) || t.Implements(textUnmarshalerType) {
+			return valueDecoder
+		}
+	case t.Name() != "":
+		pt := reflect.PtrTo(t)
+		if pt.Implements(unmarshalerType) || pt.Implements(textUnmarshalerType) {
+			return valueDecoder
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return boolDecoder
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return intDecoder
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return uintDecoder
+	case reflect.Float32, reflect.Float64:
+		return floatDecoder
+	case reflect.String:
+		return stringDecoder
+	case reflect.Struct:
+		return newStructDecoder(t)
+	case reflect.Map:
+		if t.Key().Kind() == reflect.String {
+			return newMapDecoder(t)
+		}
+	case reflect.Slice, reflect.Array:
+		return newArrayDecoder(t)
+	case reflect.Ptr:
+		return newPtrDecoder(t)
+	}
+	return valueDecoder
+}
+
+// valueDecoder decodes into v by examining v as it goes, allocating
+// pointers and calling Unmarshalers. The specialized decoders use it
+// for the values they do not handle themselves, such as null or a
+// value of the wrong kind, so that they report the same errors.
+func valueDecoder(d *decodeState, op int, v reflect.Value) {
+	switch op {
 	default:
 		d.error(errPhase)
 
@@ -293,6 +501,309 @@ func (d *decodeState) value(v reflect.Value) {
 	}
 }
 
+func boolDecoder(d *decodeState, op int, v reflect.Value) {
+	if op != scanBeginLiteral {
+		valueDecoder(d, op, v)
+		return
+	}
+	item := d.literalItem()
+	switch item[0] {
+	case 't', 'f':
+		v.SetBool(item[0] == 't')
+	default:
+		d.literalStore(item, v, false)
+	}
+}
+
+func intDecoder(d *decodeState, op int, v reflect.Value) {
+	if op != scanBeginLiteral {
+		valueDecoder(d, op, v)
+		return
+	}
+	item := d.literalItem()
+	if n, ok := parseInt(item); ok && !v.OverflowInt(n) {
+		v.SetInt(n)
+		return
+	}
+	d.literalStore(item, v, false)
+}
+
+func uintDecoder(d *decodeState, op int, v reflect.Value) {
+	if op != scanBeginLiteral {
+		valueDecoder(d, op, v)
+		return
+	}
+	item := d.literalItem()
+	if n, ok := parseUint(item); ok && !v.OverflowUint(n) {
+		v.SetUint(n)
+		return
+	}
+	d.literalStore(item, v, false)
+}
+
+func floatDecoder(d *decodeState, op int, v reflect.Value) {
+	if op != scanBeginLiteral {
+		valueDecoder(d, op, v)
+		return
+	}
+	item := d.literalItem()
+	if c := item[0]; c == '-' || '0' <= c && c <= '9' {
+		if n, err := parseFloat(item, v.Type().Bits()); err == nil && !v.OverflowFloat(n) {
+			v.SetFloat(n)
+			return
+		}
+	}
+	d.literalStore(item, v, false)
+}
+
+func stringDecoder(d *decodeState, op int, v reflect.Value) {
+	if op != scanBeginLiteral {
+		valueDecoder(d, op, v)
+		return
+	}
+	item := d.literalItem()
+	if item[0] == '"' {
+		if s, ok := unquoteBytes(item); ok {
+			v.SetString(string(s))
+			return
+		}
+	}
+	d.literalStore(item, v, false)
+}
+
+type structDecoder struct {
+	fields   *structFields
+	decoders []decoderFunc // decoders[i] decodes fields.list[i]
+}
+
+func (sd *structDecoder) decode(d *decodeState, op int, v reflect.Value) {
+	if op != scanBeginObject {
+		valueDecoder(d, op, v)
+		return
+	}
+
+	var seen map[string]bool // field names seen, for disallowDuplicateKeys
+	for {
+		key, ok := d.objectKey()
+		if !ok {
+			break
+		}
+
+		// Figure out field corresponding to key.
+		i, folded := -1, false // folded: whether the field matches key only case-insensitively
+		if j, ok := sd.fields.nameIndex[string(key)]; ok {
+			i = j
+		} else {
+			for j := range sd.fields.list {
+				ff := &sd.fields.list[j]
+				if ff.equalFold(ff.nameBytes, key) {
+					i, folded = j, true
+					break
+				}
+			}
+		}
+		if i >= 0 && folded && d.exactCase {
+			d.saveError(d.strictError(fmt.Sprintf("object key %q does not match the case of field %q", key, sd.fields.list[i].name)))
+			i = -1
+		} else if i < 0 && d.disallowUnknownFields {
+			d.saveError(d.strictError(fmt.Sprintf("unknown field %q", key)))
+		}
+
+		if i < 0 {
+			d.value(reflect.Value{})
+		} else {
+			f := &sd.fields.list[i]
+			if d.disallowDuplicateKeys {
+				// Compare field names, not keys, so that keys
+				// differing only in case count as duplicates.
+				seen = d.checkDuplicate(seen, f.name)
+			}
+			subv := v
+			for _, i := range f.index {
+				if subv.Kind() == reflect.Ptr {
+					if subv.IsNil() {
+						subv.Set(reflect.New(subv.Type().Elem()))
+					}
+					subv = subv.Elem()
+				}
+				subv = subv.Field(i)
+			}
+			if f.quoted {
+				d.valueDestring(subv)
+			} else {
+				sd.decoders[i](d, d.scanWhile(scanSkipSpace), subv)
+			}
+		}
+		d.popPath()
+
+		if !d.objectNext() {
+			break
+		}
+	}
+}
+
+func newStructDecoder(t reflect.Type) decoderFunc {
+	fields := cachedTypeFields(t)
+	sd := &structDecoder{
+		fields:   fields,
+		decoders: make([]decoderFunc, len(fields.list)),
+	}
+	for i, f := range fields.list {
+		// f.typ is the type of the field with any pointer
+		// removed, so look up the declared type.
+		sd.decoders[i] = typeDecoder(t.FieldByIndex(f.index).Type)
+	}
+	return sd.decode
+}
+
+type mapDecoder struct {
+	elemDec decoderFunc
+}
+
+func (md *mapDecoder) decode(d *decodeState, op int, v reflect.Value) {
+	if op != scanBeginObject {
+		valueDecoder(d, op, v)
+		return
+	}
+	t := v.Type()
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(t))
+	}
+
+	var mapElem reflect.Value
+	var seen map[string]bool // keys seen, for disallowDuplicateKeys
+	for {
+		key, ok := d.objectKey()
+		if !ok {
+			break
+		}
+		if !mapElem.IsValid() {
+			mapElem = reflect.New(t.Elem()).Elem()
+		} else {
+			mapElem.Set(reflect.Zero(t.Elem()))
+		}
+		if d.disallowDuplicateKeys {
+			seen = d.checkDuplicate(seen, string(key))
+		}
+		md.elemDec(d, d.scanWhile(scanSkipSpace), mapElem)
+		d.popPath()
+
+		kv := reflect.ValueOf(key).Convert(t.Key())
+		v.SetMapIndex(kv, mapElem)
+
+		if !d.objectNext() {
+			break
+		}
+	}
+}
+
+func newMapDecoder(t reflect.Type) decoderFunc {
+	md := &mapDecoder{typeDecoder(t.Elem())}
+	return md.decode
+}
+
+// arrayDecoder decodes into arrays and slices.
+type arrayDecoder struct {
+	elemDec decoderFunc
+}
+
+func (ad *arrayDecoder) decode(d *decodeState, op int, v reflect.Value) {
+	if op != scanBeginArray {
+		valueDecoder(d, op, v)
+		return
+	}
+
+	i := 0
+	for {
+		// Look ahead for ] - can only happen on first iteration.
+		op := d.scanWhile(scanSkipSpace)
+		if op == scanEndArray {
+			break
+		}
+
+		// Get element of array, growing if necessary.
+		if v.Kind() == reflect.Slice {
+			// Grow slice if necessary
+			if i >= v.Cap() {
+				newcap := v.Cap() + v.Cap()/2
+				if newcap < 4 {
+					newcap = 4
+				}
+				newv := reflect.MakeSlice(v.Type(), v.Len(), newcap)
+				reflect.Copy(newv, v)
+				v.Set(newv)
+			}
+			if i >= v.Len() {
+				v.SetLen(i + 1)
+			}
+		}
+
+		d.pushIndex(i)
+		if i < v.Len() {
+			// Decode into element.
+			ad.elemDec(d, op, v.Index(i))
+		} else {
+			// Ran out of fixed array: skip.
+			// Back up so d.value can have the byte we just read.
+			d.off--
+			d.scan.undo(op)
+			d.value(reflect.Value{})
+		}
+		d.popPath()
+		i++
+
+		// Next token must be , or ].
+		op = d.scanWhile(scanSkipSpace)
+		if op == scanEndArray {
+			break
+		}
+		if op != scanArrayValue {
+			d.error(errPhase)
+		}
+	}
+
+	if i < v.Len() {
+		if v.Kind() == reflect.Array {
+			// Array.  Zero the rest.
+			z := reflect.Zero(v.Type().Elem())
+			for ; i < v.Len(); i++ {
+				v.Index(i).Set(z)
+			}
+		} else {
+			v.SetLen(i)
+		}
+	}
+	if i == 0 && v.Kind() == reflect.Slice {
+		v.Set(emptySlice(v.Type()))
+	}
+}
+
+func newArrayDecoder(t reflect.Type) decoderFunc {
+	ad := &arrayDecoder{typeDecoder(t.Elem())}
+	return ad.decode
+}
+
+type ptrDecoder struct {
+	elemDec decoderFunc
+}
+
+func (pd *ptrDecoder) decode(d *decodeState, op int, v reflect.Value) {
+	if op == scanBeginLiteral && d.data[d.off-1] == 'n' || v.IsNil() && !v.CanSet() {
+		// Null sets the pointer to nil.
+		valueDecoder(d, op, v)
+		return
+	}
+	if v.IsNil() {
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+	pd.elemDec(d, op, v.Elem())
+}
+
+func newPtrDecoder(t reflect.Type) decoderFunc {
+	pd := &ptrDecoder{typeDecoder(t.Elem())}
+	return pd.decode
+}
+
 type unquotedValue struct{}
 
 // valueQuoted is like value but decodes a
@@ -406,68 +917,35 @@ func (d *decodeState) array(v reflect.Value) {
 		break
 	}
 
-	i := 0
-	for {
-		// Look ahead for ] - can only happen on first iteration.
-		op := d.scanWhile(scanSkipSpace)
-		if op == scanEndArray {
-			break
-		}
-
-		// Back up so d.value can have the byte we just read.
-		d.off--
-		d.scan.undo(op)
-
-		// Get element of array, growing if necessary.
-		if v.Kind() == reflect.Slice {
-			// Grow slice if necessary
-			if i >= v.Cap() {
-				newcap := v.Cap() + v.Cap()/2
-				if newcap < 4 {
-					newcap = 4
-				}
-				newv := reflect.MakeSlice(v.Type(), v.Len(), newcap)
-				reflect.Copy(newv, v)
-				v.Set(newv)
-			}
-			if i >= v.Len() {
-				v.SetLen(i + 1)
-			}
-		}
+	// indirect found no Unmarshaler, so the decoder for v's type
+	// is a specialized one, which does not call back into d.array.
+	typeDecoder(v.Type())(d, scanBeginArray, v)
+}
 
-		if i < v.Len() {
-			// Decode into element.
-			d.value(v.Index(i))
-		} else {
-			// Ran out of fixed array: skip.
-			d.value(reflect.Value{})
-		}
-		i++
+var emptySliceCache struct {
+	sync.RWMutex
+	m map[reflect.Type]reflect.Value
+}
 
-		// Next token must be , or ].
-		op = d.scanWhile(scanSkipSpace)
-		if op == scanEndArray {
-			break
-		}
-		if op != scanArrayValue {
-			d.error(errPhase)
-		}
+// emptySlice returns an empty, non-nil slice of type t.
+// The slices are cached by type, so that decoding the many
+// empty arrays in typical JSON does not allocate.
+func emptySlice(t reflect.Type) reflect.Value {
+	emptySliceCache.RLock()
+	s, ok := emptySliceCache.m[t]
+	emptySliceCache.RUnlock()
+	if ok {
+		return s
 	}
 
-	if i < v.Len() {
-		if v.Kind() == reflect.Array {
-			// Array.  Zero the rest.
-			z := reflect.Zero(v.Type().Elem())
-			for ; i < v.Len(); i++ {
-				v.Index(i).Set(z)
-			}
-		} else {
-			v.SetLen(i)
-		}
-	}
-	if i == 0 && v.Kind() == reflect.Slice {
-		v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+	s = reflect.MakeSlice(t, 0, 0)
+	emptySliceCache.Lock()
+	if emptySliceCache.m == nil {
+		emptySliceCache.m = map[reflect.Type]reflect.Value{}
 	}
+	emptySliceCache.m[t] = s
+	emptySliceCache.Unlock()
+	return s
 }
 
 var nullLiteral = []byte("null")
@@ -503,16 +981,12 @@ func (d *decodeState) object(v reflect.Value) {
 	switch v.Kind() {
 	case reflect.Map:
 		// map must have string kind
-		t := v.Type()
-		if t.Key().Kind() != reflect.String {
+		if v.Type().Key().Kind() != reflect.String {
 			d.saveError(&UnmarshalTypeError{"object", v.Type(), int64(d.off)})
 			d.off--
 			d.next() // skip over { } in input
 			return
 		}
-		if v.IsNil() {
-			v.Set(reflect.MakeMap(t))
-		}
 	case reflect.Struct:
 
 	default:
@@ -521,113 +995,95 @@ func (d *decodeState) object(v reflect.Value) {
 		d.next() // skip over { } in input
 		return
 	}
+	// indirect found no Unmarshaler, so the decoder for v's type
+	// is a specialized one, which does not call back into d.object.
+	typeDecoder(v.Type())(d, scanBeginObject, v)
+}
 
-	var mapElem reflect.Value
-
-	for {
-		// Read opening " of string key or closing }.
-		op := d.scanWhile(scanSkipSpace)
-		if op == scanEndObject {
-			// closing } - can only happen on first iteration.
-			break
-		}
-		if op != scanBeginLiteral {
-			d.error(errPhase)
-		}
-
-		// Read key.
-		start := d.off - 1
-		op = d.scanWhile(scanContinue)
-		item := d.data[start : d.off-1]
-		key, ok := unquoteBytes(item)
-		if !ok {
-			d.error(errPhase)
-		}
-
-		// Figure out field corresponding to key.
-		var subv reflect.Value
-		destring := false // whether the value is wrapped in a string to be decoded first
+// objectKey reads the key of the next member of an object and the ':'
+// after it, and adds the key to d.path. At the end of the object it
+// reads the closing '}' instead and returns false.
+func (d *decodeState) objectKey() ([]byte, bool) {
+	// Read opening " of string key or closing }.
+	op := d.scanWhile(scanSkipSpace)
+	if op == scanEndObject {
+		// closing } - can only happen on first iteration.
+		return nil, false
+	}
+	if op != scanBeginLiteral {
+		d.error(errPhase)
+	}
 
-		if v.Kind() == reflect.Map {
-			elemType := v.Type().Elem()
-			if !mapElem.IsValid() {
-				mapElem = reflect.New(elemType).Elem()
-			} else {
-				mapElem.Set(reflect.Zero(elemType))
-			}
-			subv = mapElem
-		} else {
-			var f *field
-			fields := cachedTypeFields(v.Type())
-			for i := range fields {
-				ff := &fields[i]
-				if bytes.Equal(ff.nameBytes, key) {
-					f = ff
-					break
-				}
-				if f == nil && ff.equalFold(ff.nameBytes, key) {
-					f = ff
-				}
-			}
-			if f != nil {
-				subv = v
-				destring = f.quoted
-				for _, i := range f.index {
-					if subv.Kind() == reflect.Ptr {
-						if subv.IsNil() {
-							subv.Set(reflect.New(subv.Type().Elem()))
-						}
-						subv = subv.Elem()
-					}
-					subv = subv.Field(i)
-				}
-			}
-		}
+	// Read key.
+	start := d.off - 1
+	op = d.scanWhile(scanContinue)
+	item := d.data[start : d.off-1]
+	key, ok := unquoteBytes(item)
+	if !ok {
+		d.error(errPhase)
+	}
+	d.pushKey(key)
 
-		// Read : before value.
-		if op == scanSkipSpace {
-			op = d.scanWhile(scanSkipSpace)
-		}
-		if op != scanObjectKey {
-			d.error(errPhase)
-		}
+	// Read : before value.
+	if op == scanSkipSpace {
+		op = d.scanWhile(scanSkipSpace)
+	}
+	if op != scanObjectKey {
+		d.error(errPhase)
+	}
+	return key, true
+}
 
-		// Read value.
-		if destring {
-			switch qv := d.valueQuoted().(type) {
-			case nil:
-				d.literalStore(nullLiteral, subv, false)
-			case string:
-				d.literalStore([]byte(qv), subv, true)
-			default:
-				d.saveError(fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal unquoted value into %v", subv.Type()))
-			}
-		} else {
-			d.value(subv)
-		}
+// objectNext reads the ',' or '}' after a member of an object
+// and reports whether another member follows.
+func (d *decodeState) objectNext() bool {
+	// Next token must be , or }.
+	op := d.scanWhile(scanSkipSpace)
+	if op == scanEndObject {
+		return false
+	}
+	if op != scanObjectValue {
+		d.error(errPhase)
+	}
+	return true
+}
 
-		// Write value back to map;
-		// if using struct, subv points into struct already.
-		if v.Kind() == reflect.Map {
-			kv := reflect.ValueOf(key).Convert(v.Type().Key())
-			v.SetMapIndex(kv, subv)
-		}
+// valueDestring decodes into v a value wrapped in a JSON string,
+// as for a field with the ",string" tag option.
+func (d *decodeState) valueDestring(v reflect.Value) {
+	switch qv := d.valueQuoted().(type) {
+	case nil:
+		d.literalStore(nullLiteral, v, false)
+	case string:
+		d.literalStore([]byte(qv), v, true)
+	default:
+		d.saveError(fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal unquoted value into %v", v.Type()))
+	}
+}
 
-		// Next token must be , or }.
-		op = d.scanWhile(scanSkipSpace)
-		if op == scanEndObject {
-			break
-		}
-		if op != scanObjectValue {
-			d.error(errPhase)
-		}
+// checkDuplicate records name in seen, allocating seen if needed,
+// and saves an error if name was already in it.
+func (d *decodeState) checkDuplicate(seen map[string]bool, name string) map[string]bool {
+	if seen == nil {
+		seen = make(map[string]bool)
+	}
+	if seen[name] {
+		d.saveError(d.strictError("duplicate object key"))
 	}
+	seen[name] = true
+	return seen
 }
 
 // literal consumes a literal from d.data[d.off-1:], decoding into the value v.
 // The first byte of the literal has been read already
 // (that's how the caller knows it's a literal).
 func (d *decodeState) literal(v reflect.Value) {
+	d.literalStore(d.literalItem(), v, false)
+}
+
+// literalItem consumes a literal from d.data[d.off-1:] and returns it.
+// The first byte of the literal has been read already.
+func (d *decodeState) literalItem() []byte {
 	// All bytes inside literal return scanContinue op code.
 	start := d.off - 1
 	op := d.scanWhile(scanContinue)
@@ -636,22 +1092,167 @@ func (d *decodeState) literal(v reflect.Value) {
 	d.off--
 	d.scan.undo(op)
 
-	d.literalStore(d.data[start:d.off], v, false)
+	return d.data[start:d.off]
 }
 
 // convertNumber converts the number literal s to a float64 or a Number
 // depending on the setting of d.useNumber.
-func (d *decodeState) convertNumber(s string) (interface{}, error) {
+func (d *decodeState) convertNumber(s []byte) (interface{}, error) {
 	if d.useNumber {
 		return Number(s), nil
 	}
-	f, err := strconv.ParseFloat(s, 64)
+	f, err := parseFloat(s, 64)
 	if err != nil {
-		return nil, &UnmarshalTypeError{"number " + s, reflect.TypeOf(0.0), int64(d.off)}
+		return nil, &UnmarshalTypeError{"number " + string(s), reflect.TypeOf(0.0), int64(d.off)}
 	}
 	return f, nil
 }
 
+// parseInt parses the number literal s as a decimal integer.
+// It is like strconv.ParseInt(string(s), 10, 64) without the conversion
+// to string. The result is false if s is not an integer or overflows int64.
+func parseInt(s []byte) (int64, bool) {
+	neg := len(s) > 0 && s[0] == '-'
+	if neg {
+		s = s[1:]
+	}
+	u, ok := parseUint(s)
+	if !ok {
+		return 0, false
+	}
+	if neg {
+		if u > 1<<63 {
+			return 0, false
+		}
+		return -int64(u), true
+	}
+	if u >= 1<<63 {
+		return 0, false
+	}
+	return int64(u), true
+}
+
+// parseUint parses the number literal s as an unsigned decimal integer.
+// It is like strconv.ParseUint(string(s), 10, 64) without the conversion
+// to string. The result is false if s is not an integer or overflows uint64.
+func parseUint(s []byte) (uint64, bool) {
+	if len(s) == 0 {
+		return 0, false
+	}
+	var n uint64
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		if n > (1<<64-1)/10 {
+			return 0, false
+		}
+		n1 := n*10 + uint64(c-'0')
+		if n1 < n {
+			return 0, false
+		}
+		n = n1
+	}
+	return n, true
+}
+
+// float64pow10 holds the powers of ten that are exact in a float64.
+var float64pow10 = [...]float64{
+	1e0, 1e1, 1e2, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8, 1e9,
+	1e10, 1e11, 1e12, 1e13, 1e14, 1e15, 1e16, 1e17, 1e18, 1e19,
+	1e20, 1e21, 1e22,
+}
+
+// parseFloat parses the number literal s like strconv.ParseFloat.
+// Most numbers found in JSON have few digits and a small exponent;
+// for those, parseFloat64Exact computes the correctly rounded result
+// without converting s to a string.
+func parseFloat(s []byte, bitSize int) (float64, error) {
+	if bitSize == 64 {
+		if f, ok := parseFloat64Exact(s); ok {
+			return f, nil
+		}
+	}
+	return strconv.ParseFloat(string(s), bitSize)
+}
+
+// parseFloat64Exact parses the number literal s when its value is
+// the product or quotient of two integers that are exact in a float64:
+// a mantissa of at most 15 significant digits and a power of ten no
+// larger than 1e22. A single floating-point multiplication or division
+// then rounds correctly. The result is false for any other s.
+func parseFloat64Exact(s []byte) (float64, bool) {
+	i := 0
+	neg := i < len(s) && s[i] == '-'
+	if neg {
+		i++
+	}
+
+	var mant uint64
+	digits, exp := 0, 0
+	sawDigit := false
+	for ; i < len(s) && '0' <= s[i] && s[i] <= '9'; i++ {
+		mant = mant*10 + uint64(s[i]-'0')
+		if mant != 0 {
+			digits++
+		}
+		sawDigit = true
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		for ; i < len(s) && '0' <= s[i] && s[i] <= '9'; i++ {
+			mant = mant*10 + uint64(s[i]-'0')
+			if mant != 0 {
+				digits++
+			}
+			exp--
+			sawDigit = true
+		}
+	}
+	if !sawDigit || digits > 15 {
+		return 0, false
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		esign := 1
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			if s[i] == '-' {
+				esign = -1
+			}
+			i++
+		}
+		if i == len(s) {
+			return 0, false
+		}
+		e := 0
+		for ; i < len(s) && '0' <= s[i] && s[i] <= '9'; i++ {
+			if e >= 1000 {
+				return 0, false
+			}
+			e = e*10 + int(s[i]-'0')
+		}
+		exp += esign * e
+	}
+	if i != len(s) {
+		return 0, false
+	}
+
+	f := float64(mant)
+	switch {
+	case mant == 0:
+	case exp >= 0 && exp < len(float64pow10):
+		f *= float64pow10[exp]
+	case exp < 0 && -exp < len(float64pow10):
+		f /= float64pow10[-exp]
+	default:
+		return 0, false
+	}
+	if neg {
+		f = -f
+	}
+	return f, true
+}
+
 var numberType = reflect.TypeOf(Number(""))
 
 // literalStore decodes a literal stored in item into v.
@@ -768,11 +1369,10 @@ func (d *decodeState) literalStore(item []byte, v reflect.Value, fromQuoted bool
 				d.error(errPhase)
 			}
 		}
-		s := string(item)
 		switch v.Kind() {
 		default:
 			if v.Kind() == reflect.String && v.Type() == numberType {
-				v.SetString(s)
+				v.SetString(string(item))
 				break
 			}
 			if fromQuoted {
@@ -781,7 +1381,7 @@ func (d *decodeState) literalStore(item []byte, v reflect.Value, fromQuoted bool
 				d.error(&UnmarshalTypeError{"number", v.Type(), int64(d.off)})
 			}
 		case reflect.Interface:
-			n, err := d.convertNumber(s)
+			n, err := d.convertNumber(item)
 			if err != nil {
 				d.saveError(err)
 				break
@@ -793,25 +1393,25 @@ func (d *decodeState) literalStore(item []byte, v reflect.Value, fromQuoted bool
 			v.Set(reflect.ValueOf(n))
 
 		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			n, err := strconv.ParseInt(s, 10, 64)
-			if err != nil || v.OverflowInt(n) {
-				d.saveError(&UnmarshalTypeError{"number " + s, v.Type(), int64(d.off)})
+			n, ok := parseInt(item)
+			if !ok || v.OverflowInt(n) {
+				d.saveError(&UnmarshalTypeError{"number " + string(item), v.Type(), int64(d.off)})
 				break
 			}
 			v.SetInt(n)
 
 		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-			n, err := strconv.ParseUint(s, 10, 64)
-			if err != nil || v.OverflowUint(n) {
-				d.saveError(&UnmarshalTypeError{"number " + s, v.Type(), int64(d.off)})
+			n, ok := parseUint(item)
+			if !ok || v.OverflowUint(n) {
+				d.saveError(&UnmarshalTypeError{"number " + string(item), v.Type(), int64(d.off)})
 				break
 			}
 			v.SetUint(n)
 
 		case reflect.Float32, reflect.Float64:
-			n, err := strconv.ParseFloat(s, v.Type().Bits())
+			n, err := parseFloat(item, v.Type().Bits())
 			if err != nil || v.OverflowFloat(n) {
-				d.saveError(&UnmarshalTypeError{"number " + s, v.Type(), int64(d.off)})
+				d.saveError(&UnmarshalTypeError{"number " + string(item), v.Type(), int64(d.off)})
 				break
 			}
 			v.SetFloat(n)
@@ -852,7 +1452,9 @@ func (d *decodeState) arrayInterface() []interface{} {
 		d.off--
 		d.scan.undo(op)
 
+		d.pushIndex(len(v))
 		v = append(v, d.valueInterface())
+		d.popPath()
 
 		// Next token must be , or ].
 		op = d.scanWhile(scanSkipSpace)
@@ -884,10 +1486,11 @@ func (d *decodeState) objectInterface() map[string]interface{} {
 		start := d.off - 1
 		op = d.scanWhile(scanContinue)
 		item := d.data[start : d.off-1]
-		key, ok := unquote(item)
+		kb, ok := unquoteBytes(item)
 		if !ok {
 			d.error(errPhase)
 		}
+		key := string(kb)
 
 		// Read : before value.
 		if op == scanSkipSpace {
@@ -897,8 +1500,14 @@ func (d *decodeState) objectInterface() map[string]interface{} {
 			d.error(errPhase)
 		}
 
+		d.pushKey(kb)
+		if _, dup := m[key]; dup && d.disallowDuplicateKeys {
+			d.saveError(d.strictError("duplicate object key"))
+		}
+
 		// Read value.
 		m[key] = d.valueInterface()
+		d.popPath()
 
 		// Next token must be , or }.
 		op = d.scanWhile(scanSkipSpace)
@@ -941,7 +1550,7 @@ func (d *decodeState) literalInterface() interface{} {
 		if c != '-' && (c < '0' || c > '9') {
 			d.error(errPhase)
 		}
-		n, err := d.convertNumber(string(item))
+		n, err := d.convertNumber(item)
 		if err != nil {
 			d.saveError(err)
 		}
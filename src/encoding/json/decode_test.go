@@ -9,7 +9,10 @@ import (
 	"encoding"
 	"fmt"
 	"image"
+	"io"
+	"math"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -618,6 +621,103 @@ func TestNumberAccessors(t *testing.T) {
 	}
 }
 
+var parseNumberTests = []string{
+	"0", "-0", "1", "-1", "12", "0.1", "-0.5", "1e3", "1E+3", "1e-3",
+	"1.5e-10", "123456789012345", "1234567890123456", "0.000001",
+	"9007199254740993", "1316289444", "0.9999999999999999",
+	"3.8622766122766117", "1e22", "1e23", "1e-22", "1e-23", "123e20",
+	"9223372036854775807", "9223372036854775808", "-9223372036854775808",
+	"-9223372036854775809", "18446744073709551615", "18446744073709551616",
+	"1e308", "1e309", "-1e309", "1e-400", "0e999", "5e-324",
+}
+
+// Test that the number parsers used by Unmarshal agree with strconv.
+func TestParseNumbers(t *testing.T) {
+	for _, s := range parseNumberTests {
+		wantI, err := strconv.ParseInt(s, 10, 64)
+		if i, ok := parseInt([]byte(s)); ok != (err == nil) || ok && i != wantI {
+			t.Errorf("parseInt(%q) = %d, %v, want %d, %v", s, i, ok, wantI, err)
+		}
+		wantU, err := strconv.ParseUint(s, 10, 64)
+		if u, ok := parseUint([]byte(s)); ok != (err == nil) || ok && u != wantU {
+			t.Errorf("parseUint(%q) = %d, %v, want %d, %v", s, u, ok, wantU, err)
+		}
+		for _, bits := range []int{32, 64} {
+			wantF, wantErr := strconv.ParseFloat(s, bits)
+			f, err := parseFloat([]byte(s), bits)
+			if f != wantF || math.Signbit(f) != math.Signbit(wantF) || (err == nil) != (wantErr == nil) {
+				t.Errorf("parseFloat(%q, %d) = %g, %v, want %g, %v", s, bits, f, err, wantF, wantErr)
+			}
+		}
+	}
+}
+
+func TestUnmarshalNumberAllocs(t *testing.T) {
+	var v struct {
+		I int
+		U uint16
+		F float64
+	}
+	data := []byte(`{"I": -12, "U": 300, "F": 1.5e3}`)
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := Unmarshal(data, &v); err != nil {
+			t.Fatal(err)
+		}
+	})
+	// Only the decodeState and its scanner stack are allocated.
+	if allocs > 2 {
+		t.Errorf("Unmarshal allocated %v times, want at most 2", allocs)
+	}
+}
+
+type recursiveList []recursiveList
+
+type recursiveNode struct {
+	Name string
+	Kids []*recursiveNode
+	Next *recursiveNode
+}
+
+func TestUnmarshalRecursiveTypes(t *testing.T) {
+	var l recursiveList
+	if err := Unmarshal([]byte(`[[], [[]], null]`), &l); err != nil {
+		t.Fatal(err)
+	}
+	if want := (recursiveList{{}, {{}}, nil}); !reflect.DeepEqual(l, want) {
+		t.Errorf("list = %#v, want %#v", l, want)
+	}
+
+	var n recursiveNode
+	data := []byte(`{"Name": "a", "Kids": [{"Name": "b"}, null], "Next": {"Name": "c", "Next": null}}`)
+	if err := Unmarshal(data, &n); err != nil {
+		t.Fatal(err)
+	}
+	want := recursiveNode{
+		Name: "a",
+		Kids: []*recursiveNode{{Name: "b"}, nil},
+		Next: &recursiveNode{Name: "c"},
+	}
+	if !reflect.DeepEqual(n, want) {
+		t.Errorf("node = %#v, want %#v", n, want)
+	}
+}
+
+func TestUnmarshalEmptySlice(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		s := []int{1, 2}
+		if err := Unmarshal([]byte(`[]`), &s); err != nil {
+			t.Fatal(err)
+		}
+		if s == nil || len(s) != 0 {
+			t.Fatalf("Unmarshal([]) = %#v, want empty non-nil slice", s)
+		}
+		s = append(s, 3)
+		if !reflect.DeepEqual(s, []int{3}) {
+			t.Fatalf("append to decoded empty slice = %v, want [3]", s)
+		}
+	}
+}
+
 func TestLargeByteSlice(t *testing.T) {
 	s0 := make([]byte, 2000)
 	for i := range s0 {
@@ -1372,3 +1472,144 @@ func TestInvalidUnmarshal(t *testing.T) {
 		}
 	}
 }
+
+type strictServer struct {
+	Host string
+	Port int `json:"port"`
+}
+
+type strictConfig struct {
+	Name    string
+	Servers []strictServer `json:"servers"`
+	Labels  map[string]string
+	Extra   interface{}
+}
+
+var strictTests = []struct {
+	in      string
+	options string // the strict options, as a list of letters
+	err     *StrictError
+}{
+	// Without the options, mistakes go unnoticed.
+	{in: `{"Name": "a", "servers": [{"prot": 1}], "name": "b"}`},
+	{in: `{"NAME": "a"}`, options: "u"},
+
+	{
+		in:      `{"servers": [{"port": 1}, {"port": 2}, {"prot": 3}]}`,
+		options: "u",
+		err:     &StrictError{Path: ".servers[2].prot", Msg: `unknown field "prot"`},
+	},
+	{
+		in:      `{"Labels": {"x-y": "z"}, "Extra": {"a b": [1]}, "Nmae": 1}`,
+		options: "u",
+		err:     &StrictError{Path: ".Nmae", Msg: `unknown field "Nmae"`},
+	},
+	{
+		in:      `{"servers": [{"Port": 1}]}`,
+		options: "c",
+		err:     &StrictError{Path: ".servers[0].Port", Msg: `object key "Port" does not match the case of field "port"`},
+	},
+	{
+		in:      `{"servers": [{"host": "h"}]}`,
+		options: "cu",
+		err:     &StrictError{Path: ".servers[0].host", Msg: `object key "host" does not match the case of field "Host"`},
+	},
+	{in: `{"servers": [{"Host": "h", "port": 1}]}`, options: "cud"},
+	{
+		in:      `{"servers": [{"port": 1, "Port": 2}]}`,
+		options: "d",
+		err:     &StrictError{Path: ".servers[0].Port", Msg: "duplicate object key"},
+	},
+	{
+		in:      `{"Labels": {"a": "1", "content-type": "x", "content-type": "y"}}`,
+		options: "d",
+		err:     &StrictError{Path: `.Labels["content-type"]`, Msg: "duplicate object key"},
+	},
+	{
+		in:      `{"Extra": [{"a": 1}, {"b": {"c": 1, "c": 2}}]}`,
+		options: "d",
+		err:     &StrictError{Path: ".Extra[1].b.c", Msg: "duplicate object key"},
+	},
+	{
+		in:      `{"Name": "a"} {"Name": "b"}`,
+		options: "t",
+		err:     &StrictError{Msg: "trailing data after top-level value"},
+	},
+	{in: "{\"Name\": \"a\"} \n\t", options: "t"},
+}
+
+func TestStrictDecoding(t *testing.T) {
+	for _, tt := range strictTests {
+		dec := NewDecoder(strings.NewReader(tt.in))
+		for _, o := range tt.options {
+			switch o {
+			case 'u':
+				dec.DisallowUnknownFields()
+			case 'c':
+				dec.RequireExactCase()
+			case 'd':
+				dec.DisallowDuplicateKeys()
+			case 't':
+				dec.DisallowTrailingData()
+			}
+		}
+		var c strictConfig
+		err := dec.Decode(&c)
+		if tt.err == nil {
+			if err != nil {
+				t.Errorf("%s with %q: unexpected error: %v", tt.in, tt.options, err)
+			}
+			continue
+		}
+		se, ok := err.(*StrictError)
+		if !ok {
+			t.Errorf("%s with %q: error = %v, want %v", tt.in, tt.options, err, tt.err)
+			continue
+		}
+		if se.Path != tt.err.Path || se.Msg != tt.err.Msg {
+			t.Errorf("%s with %q: error = %v, want %v", tt.in, tt.options, err, tt.err)
+		}
+	}
+
+	// Token reads keys and scalars like top-level values, but only
+	// Decode checks for trailing data, and the paths Decode reports
+	// are relative to the value it decodes.
+	in := `{"Name": "a", "servers": [{"prot": 1}]}`
+	dec := NewDecoder(strings.NewReader(in))
+	dec.DisallowUnknownFields()
+	dec.DisallowTrailingData()
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%s with \"ut\": Token: unexpected error: %v", in, err)
+		}
+		if tok != Delim('[') {
+			continue
+		}
+		var s strictServer
+		err = dec.Decode(&s)
+		if se, ok := err.(*StrictError); !ok || se.Path != ".prot" {
+			t.Errorf("%s with \"ut\": Decode: error = %v, want unknown field at .prot", in, err)
+		}
+	}
+}
+
+func TestStrictDecodingContinues(t *testing.T) {
+	// A strict error, like a type error, leaves the rest of the value decoded.
+	dec := NewDecoder(strings.NewReader(`{"servers": [{"prot": 1, "port": 2}], "Name": "n"}`))
+	dec.DisallowUnknownFields()
+	var c strictConfig
+	err := dec.Decode(&c)
+	if _, ok := err.(*StrictError); !ok {
+		t.Fatalf("Decode: error = %v, want *StrictError", err)
+	}
+	if want := "json: unknown field \"prot\" at .servers[0].prot"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+	if c.Name != "n" || len(c.Servers) != 1 || c.Servers[0].Port != 2 {
+		t.Errorf("decoded %+v", c)
+	}
+}
@@ -131,12 +131,15 @@ import (
 // an infinite recursion.
 //
 func Marshal(v interface{}) ([]byte, error) {
-	e := &encodeState{}
+	e := newEncodeState()
 	err := e.marshal(v)
 	if err != nil {
 		return nil, err
 	}
-	return e.Bytes(), nil
+	// Copy the result so that e's buffer can be reused.
+	b := append([]byte(nil), e.Bytes()...)
+	encodeStatePool.Put(e)
+	return b, nil
 }
 
 // MarshalIndent is like Marshal but applies Indent to format the output.
@@ -569,7 +572,8 @@ type structEncoder struct {
 func (se *structEncoder) encode(e *encodeState, v reflect.Value, quoted bool) {
 	e.WriteByte('{')
 	first := true
-	for i, f := range se.fields {
+	for i := range se.fields {
+		f := &se.fields[i]
 		fv := fieldByIndex(v, f.index)
 		if !fv.IsValid() || f.omitEmpty && isEmptyValue(fv) {
 			continue
@@ -579,15 +583,14 @@ func (se *structEncoder) encode(e *encodeState, v reflect.Value, quoted bool) {
 		} else {
 			e.WriteByte(',')
 		}
-		e.string(f.name)
-		e.WriteByte(':')
+		e.Write(f.nameKey)
 		se.fieldEncs[i](e, fv, f.quoted)
 	}
 	e.WriteByte('}')
 }
 
 func newStructEncoder(t reflect.Type) encoderFunc {
-	fields := cachedTypeFields(t)
+	fields := cachedTypeFields(t).list
 	se := &structEncoder{
 		fields:    fields,
 		fieldEncs: make([]encoderFunc, len(fields)),
@@ -938,6 +941,7 @@ type field struct {
 	name      string
 	nameBytes []byte                 // []byte(name)
 	equalFold func(s, t []byte) bool // bytes.EqualFold or equivalent
+	nameKey   []byte                 // name encoded as an object key, with ':'
 
 	tag       bool
 	index     []int
@@ -949,6 +953,10 @@ type field struct {
 func fillField(f field) field {
 	f.nameBytes = []byte(f.name)
 	f.equalFold = foldFunc(f.nameBytes)
+	var e encodeState
+	e.string(f.name)
+	e.WriteByte(':')
+	f.nameKey = e.Bytes()
 	return f
 }
 
@@ -1152,13 +1160,20 @@ func dominantField(fields []field) (field, bool) {
 	return fields[0], true
 }
 
+// structFields holds the fields of a struct type, as computed by typeFields,
+// together with an index of the exact field names for decoding.
+type structFields struct {
+	list      []field
+	nameIndex map[string]int // field name to index in list
+}
+
 var fieldCache struct {
 	sync.RWMutex
-	m map[reflect.Type][]field
+	m map[reflect.Type]*structFields
 }
 
 // cachedTypeFields is like typeFields but uses a cache to avoid repeated work.
-func cachedTypeFields(t reflect.Type) []field {
+func cachedTypeFields(t reflect.Type) *structFields {
 	fieldCache.RLock()
 	f := fieldCache.m[t]
 	fieldCache.RUnlock()
@@ -1168,14 +1183,18 @@ func cachedTypeFields(t reflect.Type) []field {
 
 	// Compute fields without lock.
 	// Might duplicate effort but won't hold other computations back.
-	f = typeFields(t)
-	if f == nil {
-		f = []field{}
+	list := typeFields(t)
+	f = &structFields{
+		list:      list,
+		nameIndex: make(map[string]int, len(list)),
+	}
+	for i, field := range list {
+		f.nameIndex[field.name] = i
 	}
 
 	fieldCache.Lock()
 	if fieldCache.m == nil {
-		fieldCache.m = map[reflect.Type][]field{}
+		fieldCache.m = map[reflect.Type]*structFields{}
 	}
 	fieldCache.m[t] = f
 	fieldCache.Unlock()
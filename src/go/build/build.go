@@ -154,6 +154,19 @@ func (ctxt *Context) hasSubdir(root, dir string) (rel string, ok bool) {
 	return hasSubdir(rootSym, dirSym)
 }
 
+// hasGoFiles reports whether dir contains any files with names ending in .go.
+// A vendor directory only satisfies an import if it has Go files,
+// so that vendoring just a/b/c does not hide the non-vendored a/b.
+func hasGoFiles(ctxt *Context, dir string) bool {
+	ents, _ := ctxt.readDir(dir)
+	for _, ent := range ents {
+		if !ent.IsDir() && strings.HasSuffix(ent.Name(), ".go") {
+			return true
+		}
+	}
+	return false
+}
+
 func hasSubdir(root, dir string) (rel string, ok bool) {
 	const sep = string(filepath.Separator)
 	root = filepath.Clean(root)
@@ -342,6 +355,18 @@ const (
 	// or finds conflicting comments in multiple source files.
 	// See golang.org/s/go14customimport for more information.
 	ImportComment
+
+	// If IgnoreVendor is set, Import does not look for the package
+	// in vendor directories.
+	//
+	// By default, an import of path from a package in directory
+	// srcDir is first satisfied by the package in directory
+	// vendor/path of srcDir or of the nearest enclosing directory
+	// within the same GOROOT or GOPATH source tree that has one.
+	// The Package for a vendored package records the path by which
+	// the package is identified, which ends in vendor/path;
+	// its source files are still written to import path.
+	IgnoreVendor
 )
 
 // A Package describes the Go package found in a directory.
@@ -445,6 +470,11 @@ func nameExt(name string) string {
 // using a standard import path, the returned package will set p.ImportPath
 // to that path.
 //
+// Unless mode includes IgnoreVendor, a non-local import path is first
+// looked up in the vendor directories enclosing srcDir, as described
+// in the documentation for IgnoreVendor; the returned package's
+// ImportPath is then the vendored path.
+//
 // In the directory containing the package, .go, .c, .h, and .s files are
 // considered part of the package except for:
 //
@@ -470,18 +500,23 @@ func (ctxt *Context) Import(path string, srcDir string, mode ImportMode) (*Packa
 	if ctxt.InstallSuffix != "" {
 		suffix = "_" + ctxt.InstallSuffix
 	}
-	switch ctxt.Compiler {
-	case "gccgo":
-		pkgtargetroot = "pkg/gccgo_" + ctxt.GOOS + "_" + ctxt.GOARCH + suffix
-		dir, elem := pathpkg.Split(p.ImportPath)
-		pkga = pkgtargetroot + "/" + dir + "lib" + elem + ".a"
-	case "gc":
-		pkgtargetroot = "pkg/" + ctxt.GOOS + "_" + ctxt.GOARCH + suffix
-		pkga = pkgtargetroot + "/" + p.ImportPath + ".a"
-	default:
-		// Save error for end of function.
-		pkgerr = fmt.Errorf("import %q: unknown compiler %q", path, ctxt.Compiler)
+	// setPkga sets pkga, the installed package object for p.ImportPath
+	// relative to its root, and must be called again if p.ImportPath changes.
+	setPkga := func() {
+		switch ctxt.Compiler {
+		case "gccgo":
+			pkgtargetroot = "pkg/gccgo_" + ctxt.GOOS + "_" + ctxt.GOARCH + suffix
+			dir, elem := pathpkg.Split(p.ImportPath)
+			pkga = pkgtargetroot + "/" + dir + "lib" + elem + ".a"
+		case "gc":
+			pkgtargetroot = "pkg/" + ctxt.GOOS + "_" + ctxt.GOARCH + suffix
+			pkga = pkgtargetroot + "/" + p.ImportPath + ".a"
+		default:
+			// Save error for end of function.
+			pkgerr = fmt.Errorf("import %q: unknown compiler %q", path, ctxt.Compiler)
+		}
 	}
+	setPkga()
 
 	binaryOnly := false
 	if IsLocalImport(path) {
@@ -538,10 +573,50 @@ func (ctxt *Context) Import(path string, srcDir string, mode ImportMode) (*Packa
 
 		// tried records the location of unsuccessful package lookups
 		var tried struct {
+			vendor []string
 			goroot string
 			gopath []string
 		}
 
+		// Vendor directories get the first chance to satisfy the import.
+		if mode&IgnoreVendor == 0 && srcDir != "" {
+			searchVendor := func(root string, isGoroot bool) bool {
+				sub, ok := ctxt.hasSubdir(root, srcDir)
+				if !ok || (sub != "src" && !strings.HasPrefix(sub, "src/")) || strings.Contains(sub, "/testdata/") {
+					return false
+				}
+				for {
+					vendor := ctxt.joinPath(root, sub, "vendor")
+					if ctxt.isDir(vendor) {
+						dir := ctxt.joinPath(vendor, path)
+						if ctxt.isDir(dir) && hasGoFiles(ctxt, dir) {
+							p.Dir = dir
+							p.ImportPath = strings.TrimPrefix(pathpkg.Join(sub, "vendor", path), "src/")
+							p.Goroot = isGoroot
+							p.Root = root
+							setPkga() // p.ImportPath changed
+							return true
+						}
+						tried.vendor = append(tried.vendor, dir)
+					}
+					i := strings.LastIndex(sub, "/")
+					if i < 0 {
+						break
+					}
+					sub = sub[:i]
+				}
+				return false
+			}
+			if ctxt.GOROOT != "" && searchVendor(ctxt.GOROOT, true) {
+				goto Found
+			}
+			for _, root := range ctxt.gopath() {
+				if searchVendor(root, false) {
+					goto Found
+				}
+			}
+		}
+
 		// Determine directory from import path.
 		if ctxt.GOROOT != "" {
 			dir := ctxt.joinPath(ctxt.GOROOT, "src", path)
@@ -569,13 +644,18 @@ func (ctxt *Context) Import(path string, srcDir string, mode ImportMode) (*Packa
 
 		// package was not found
 		var paths []string
+		format := "\t%s (vendor tree)"
+		for _, dir := range tried.vendor {
+			paths = append(paths, fmt.Sprintf(format, dir))
+			format = "\t%s"
+		}
 		if tried.goroot != "" {
 			paths = append(paths, fmt.Sprintf("\t%s (from $GOROOT)", tried.goroot))
 		} else {
 			paths = append(paths, "\t($GOROOT not set)")
 		}
 		var i int
-		var format = "\t%s (from $GOPATH)"
+		format = "\t%s (from $GOPATH)"
 		for ; i < len(tried.gopath); i++ {
 			if i > 0 {
 				format = "\t%s"
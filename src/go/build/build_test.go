@@ -275,3 +275,47 @@ func TestExpandSrcDir(t *testing.T) {
 		}
 	}
 }
+
+func TestImportVendor(t *testing.T) {
+	gopath, err := filepath.Abs("testdata/vendor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctxt := Default
+	ctxt.GOPATH = gopath
+	srcDir := filepath.Join(gopath, "src", "x", "y")
+
+	for _, tt := range []struct {
+		path, srcDir string
+		mode         ImportMode
+		importPath   string
+	}{
+		{"z", srcDir, 0, "x/vendor/z"},
+		{"z", srcDir, IgnoreVendor, "z"},
+		{"z", filepath.Join(gopath, "src", "w"), 0, "z"},
+		{"z", "", 0, "z"},
+		// x/vendor/w has no Go files, so it does not hide w.
+		{"w", srcDir, 0, "w"},
+	} {
+		p, err := ctxt.Import(tt.path, tt.srcDir, tt.mode)
+		if err != nil {
+			t.Errorf("Import(%q, %q, %v): %v", tt.path, tt.srcDir, tt.mode, err)
+			continue
+		}
+		if p.ImportPath != tt.importPath {
+			t.Errorf("Import(%q, %q, %v).ImportPath = %q, want %q", tt.path, tt.srcDir, tt.mode, p.ImportPath, tt.importPath)
+		}
+		wantDir := filepath.Join(gopath, "src", filepath.FromSlash(tt.importPath))
+		if p.Dir != wantDir {
+			t.Errorf("Import(%q, %q, %v).Dir = %q, want %q", tt.path, tt.srcDir, tt.mode, p.Dir, wantDir)
+		}
+		if wantObj := filepath.Join(gopath, "pkg", ctxt.GOOS+"_"+ctxt.GOARCH, filepath.FromSlash(tt.importPath)+".a"); ctxt.Compiler == "gc" && p.PkgObj != wantObj {
+			t.Errorf("Import(%q, %q, %v).PkgObj = %q, want %q", tt.path, tt.srcDir, tt.mode, p.PkgObj, wantObj)
+		}
+	}
+
+	_, err = ctxt.Import("missing", srcDir, 0)
+	if err == nil || !strings.Contains(err.Error(), filepath.Join(gopath, "src", "x", "vendor", "missing")+" (vendor tree)") {
+		t.Errorf("Import of missing package: error %v does not mention vendor directory", err)
+	}
+}
@@ -126,10 +126,10 @@ var pkgDeps = map[string][]string{
 	// Operating system access.
 	"syscall":       {"L0", "unicode/utf16"},
 	"time":          {"L0", "syscall"},
-	"os":            {"L1", "os", "syscall", "time", "internal/syscall/windows"},
+	"os":            {"L1", "os", "syscall", "time", "internal/syscall/windows", "internal/testlog"},
 	"path/filepath": {"L2", "os", "syscall"},
 	"io/ioutil":     {"L2", "os", "path/filepath", "time"},
-	"os/exec":       {"L2", "os", "path/filepath", "syscall"},
+	"os/exec":       {"L2", "context", "os", "path/filepath", "syscall"},
 	"os/signal":     {"L2", "os", "syscall"},
 
 	// OS enables basic operating system functionality,
@@ -146,6 +146,9 @@ var pkgDeps = map[string][]string{
 	"fmt": {"L1", "os", "reflect"},
 	"log": {"L1", "os", "fmt", "time"},
 
+	// Request-scoped cancelation and deadlines.
+	"context": {"errors", "fmt", "reflect", "sync", "time"},
+
 	// Packages used by testing must be low-level (L2+fmt).
 	"regexp":         {"L2", "regexp/syntax"},
 	"regexp/syntax":  {"L2"},
@@ -153,7 +156,7 @@ var pkgDeps = map[string][]string{
 	"runtime/pprof":  {"L2", "fmt", "text/tabwriter"},
 	"text/tabwriter": {"L2"},
 
-	"testing":        {"L2", "flag", "fmt", "os", "runtime/pprof", "time"},
+	"testing":        {"L2", "flag", "fmt", "internal/testlog", "os", "path/filepath", "reflect", "runtime/pprof", "time"},
 	"testing/iotest": {"L2", "log"},
 	"testing/quick":  {"L2", "flag", "fmt", "reflect"},
 
@@ -191,8 +194,8 @@ var pkgDeps = map[string][]string{
 	"compress/gzip":       {"L4", "compress/flate"},
 	"compress/lzw":        {"L4"},
 	"compress/zlib":       {"L4", "compress/flate"},
-	"database/sql":        {"L4", "container/list", "database/sql/driver"},
-	"database/sql/driver": {"L4", "time"},
+	"database/sql":        {"L4", "container/list", "context", "database/sql/driver"},
+	"database/sql/driver": {"L4", "context", "time"},
 	"debug/dwarf":         {"L4"},
 	"debug/elf":           {"L4", "OS", "debug/dwarf"},
 	"debug/gosym":         {"L4"},
@@ -243,7 +246,7 @@ var pkgDeps = map[string][]string{
 	// Basic networking.
 	// Because net must be used by any package that wants to
 	// do networking portably, it must have a small dependency set: just L1+basic os.
-	"net": {"L1", "CGO", "os", "syscall", "time", "internal/syscall/windows", "internal/singleflight"},
+	"net": {"L1", "CGO", "context", "os", "syscall", "time", "internal/nettrace", "internal/syscall/windows", "internal/singleflight"},
 
 	// NET enables use of basic network-related packages.
 	"NET": {
@@ -320,16 +323,18 @@ var pkgDeps = map[string][]string{
 	// HTTP, kingpin of dependencies.
 	"net/http": {
 		"L4", "NET", "OS",
-		"compress/gzip", "crypto/tls", "mime/multipart", "runtime/debug",
-		"net/http/internal",
+		"compress/gzip", "compress/zlib", "container/list", "context", "crypto/tls", "mime/multipart", "runtime/debug",
+		"internal/nettrace", "net/http/httptrace",
+		"net/http/internal", "net/http/internal/hpack", "net/http/internal/http2",
 	},
+	"net/http/httptrace": {"context", "crypto/tls", "internal/nettrace", "net", "reflect", "time"},
 
 	// HTTP-using packages.
 	"expvar":            {"L4", "OS", "encoding/json", "net/http"},
 	"net/http/cgi":      {"L4", "NET", "OS", "crypto/tls", "net/http", "regexp"},
 	"net/http/fcgi":     {"L4", "NET", "OS", "net/http", "net/http/cgi"},
 	"net/http/httptest": {"L4", "NET", "OS", "crypto/tls", "flag", "net/http"},
-	"net/http/httputil": {"L4", "NET", "OS", "net/http", "net/http/internal"},
+	"net/http/httputil": {"L4", "NET", "OS", "context", "net/http", "net/http/httptrace", "net/http/internal"},
 	"net/http/pprof":    {"L4", "OS", "html/template", "net/http", "runtime/pprof"},
 	"net/rpc":           {"L4", "NET", "encoding/gob", "html/template", "net/http"},
 	"net/rpc/jsonrpc":   {"L4", "NET", "encoding/json", "net/rpc"},
@@ -348,13 +353,17 @@ var pkgDeps = map[string][]string{
 	"image/internal/imageutil": {"image"},
 	"internal/format":          {"bytes", "go/ast", "go/parser", "go/printer", "go/token", "strings"},
 	"internal/mime":            {"bytes", "encoding/base64", "errors", "fmt", "io", "io/ioutil", "strconv", "strings", "unicode"},
+	"internal/nettrace":        {},
 	"internal/singleflight":    {"sync"},
 	"internal/syscall/unix":    {"runtime", "sync/atomic", "syscall", "unsafe"},
 	"internal/syscall/windows": {"syscall", "unsafe"},
+	"internal/testlog":         {"sync/atomic"},
 	"internal/trace":           {"bufio", "bytes", "fmt", "io", "os", "os/exec", "sort", "strconv", "strings"},
 	"mime/quotedprintable":     {"bufio", "bytes", "fmt", "io"},
-	"net/http/cookiejar":       {"errors", "fmt", "net", "net/http", "net/url", "sort", "strings", "sync", "time", "unicode/utf8"},
+	"net/http/cookiejar":       {"encoding/json", "errors", "fmt", "io/ioutil", "net", "net/http", "net/url", "os", "path/filepath", "sort", "strings", "sync", "time", "unicode/utf8"},
 	"net/http/internal":        {"bufio", "bytes", "errors", "fmt", "io"},
+	"net/http/internal/hpack":  {"bytes", "errors", "fmt", "io", "sync"},
+	"net/http/internal/http2":  {"encoding/binary", "errors", "fmt", "io"},
 	"net/internal/socktest":    {"fmt", "sync", "syscall"},
 }
 
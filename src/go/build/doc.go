@@ -55,6 +55,19 @@
 //	            foo/
 //	                bar.a          (installed package object)
 //
+// Vendor Directories
+//
+// Code below a directory containing a subdirectory named "vendor"
+// can import packages from that vendor directory as if they were
+// at the top of the Go path. For example, code in
+// DIR/src/foo/quux/y.go that imports "github.com/x/y" gets the
+// package in DIR/src/foo/vendor/github.com/x/y if that directory
+// contains Go files, in preference to one elsewhere in the Go path.
+// The nearest enclosing vendor directory wins, and vendored packages
+// are identified by their full paths (here "foo/vendor/github.com/x/y")
+// while the code importing them keeps using the original import path.
+// Import's IgnoreVendor mode disables this lookup.
+//
 // Build Constraints
 //
 // A build constraint, also known as a build tag, is a line comment that begins
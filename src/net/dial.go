@@ -5,7 +5,9 @@
 package net
 
 import (
+	"context"
 	"errors"
+	"internal/nettrace"
 	"time"
 )
 
@@ -52,18 +54,34 @@ type Dialer struct {
 	KeepAlive time.Duration
 }
 
-// Return either now+Timeout or Deadline, whichever comes first.
-// Or zero, if neither is set.
-func (d *Dialer) deadline() time.Time {
-	if d.Timeout == 0 {
-		return d.Deadline
+// Return the earliest of now+Timeout, Deadline and the deadline of
+// ctx. Or zero, if none is set.
+func (d *Dialer) deadline(ctx context.Context) time.Time {
+	deadline := d.Deadline
+	if d.Timeout != 0 {
+		timeoutDeadline := time.Now().Add(d.Timeout)
+		if deadline.IsZero() || timeoutDeadline.Before(deadline) {
+			deadline = timeoutDeadline
+		}
+	}
+	if ctxDeadline, ok := ctx.Deadline(); ok {
+		if deadline.IsZero() || ctxDeadline.Before(deadline) {
+			deadline = ctxDeadline
+		}
 	}
-	timeoutDeadline := time.Now().Add(d.Timeout)
-	if d.Deadline.IsZero() || timeoutDeadline.Before(d.Deadline) {
-		return timeoutDeadline
-	} else {
-		return d.Deadline
+	return deadline
+}
+
+// mapContextErr converts the error of a finished context into the
+// error this package reports for the same condition.
+func mapContextErr(err error) error {
+	switch err {
+	case context.Canceled:
+		return errCanceled
+	case context.DeadlineExceeded:
+		return errTimeout
 	}
+	return err
 }
 
 func parseNetwork(net string) (afnet string, proto int, err error) {
@@ -95,7 +113,7 @@ func parseNetwork(net string) (afnet string, proto int, err error) {
 	return "", 0, UnknownNetworkError(net)
 }
 
-func resolveAddrList(op, net, addr string, deadline time.Time) (addrList, error) {
+func resolveAddrList(op, net, addr string, deadline time.Time, trace *nettrace.Trace) (addrList, error) {
 	afnet, _, err := parseNetwork(net)
 	if err != nil {
 		return nil, err
@@ -111,7 +129,7 @@ func resolveAddrList(op, net, addr string, deadline time.Time) (addrList, error)
 		}
 		return addrList{addr}, nil
 	}
-	return internetAddrList(afnet, addr, deadline)
+	return internetAddrList(afnet, addr, deadline, trace)
 }
 
 // Dial connects to the address on the named network.
@@ -159,7 +177,55 @@ func DialTimeout(network, address string, timeout time.Duration) (Conn, error) {
 // See func Dial for a description of the network and address
 // parameters.
 func (d *Dialer) Dial(network, address string) (Conn, error) {
-	addrs, err := resolveAddrList("dial", network, address, d.deadline())
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialContext connects to the address on the named network using
+// the provided context.
+//
+// The provided Context must be non-nil. If the context is canceled
+// or its deadline passes before the connection is complete, an
+// error is returned; a connection completed after that point is
+// closed. Once successfully connected, the context has no effect on
+// the connection.
+//
+// See func Dial for a description of the network and address
+// parameters.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (Conn, error) {
+	if ctx == nil {
+		panic("nil context")
+	}
+	deadline := d.deadline(ctx)
+	trace, _ := ctx.Value(nettrace.TraceKey{}).(*nettrace.Trace)
+	if ctx.Done() == nil {
+		return d.dial(network, address, deadline, trace)
+	}
+	type racer struct {
+		Conn
+		error
+	}
+	ch := make(chan racer, 1)
+	go func() {
+		c, err := d.dial(network, address, deadline, trace)
+		ch <- racer{c, err}
+	}()
+	select {
+	case <-ctx.Done():
+		go func() {
+			// Release a connection that completes after
+			// the caller has given up on it.
+			if racer := <-ch; racer.error == nil {
+				racer.Conn.Close()
+			}
+		}()
+		return nil, &OpError{Op: "dial", Net: network, Addr: nil, Err: mapContextErr(ctx.Err())}
+	case racer := <-ch:
+		return racer.Conn, racer.error
+	}
+}
+
+func (d *Dialer) dial(network, address string, deadline time.Time, trace *nettrace.Trace) (Conn, error) {
+	addrs, err := resolveAddrList("dial", network, address, deadline, trace)
 	if err != nil {
 		return nil, &OpError{Op: "dial", Net: network, Addr: nil, Err: err}
 	}
@@ -168,16 +234,16 @@ func (d *Dialer) Dial(network, address string) (Conn, error) {
 		primaries, fallbacks := addrs.partition(isIPv4)
 		if len(fallbacks) > 0 {
 			dialer = func(deadline time.Time) (Conn, error) {
-				return dialMulti(network, address, d.LocalAddr, addrList{primaries[0], fallbacks[0]}, deadline)
+				return dialMulti(network, address, d.LocalAddr, addrList{primaries[0], fallbacks[0]}, deadline, trace)
 			}
 		}
 	}
 	if dialer == nil {
 		dialer = func(deadline time.Time) (Conn, error) {
-			return dialSingle(network, address, d.LocalAddr, addrs.first(isIPv4), deadline)
+			return dialSingle(network, address, d.LocalAddr, addrs.first(isIPv4), deadline, trace)
 		}
 	}
-	c, err := dial(network, addrs.first(isIPv4), dialer, d.deadline())
+	c, err := dial(network, addrs.first(isIPv4), dialer, deadline)
 	if d.KeepAlive > 0 && err == nil {
 		if tc, ok := c.(*TCPConn); ok {
 			tc.SetKeepAlive(true)
@@ -192,7 +258,7 @@ func (d *Dialer) Dial(network, address string) (Conn, error) {
 // the list of addresses. It will return the first established
 // connection and close the other connections. Otherwise it returns
 // error on the last attempt.
-func dialMulti(net, addr string, la Addr, ras addrList, deadline time.Time) (Conn, error) {
+func dialMulti(net, addr string, la Addr, ras addrList, deadline time.Time, trace *nettrace.Trace) (Conn, error) {
 	type racer struct {
 		Conn
 		error
@@ -204,7 +270,7 @@ func dialMulti(net, addr string, la Addr, ras addrList, deadline time.Time) (Con
 	lane := make(chan racer, 1)
 	for _, ra := range ras {
 		go func(ra Addr) {
-			c, err := dialSingle(net, addr, la, ra, deadline)
+			c, err := dialSingle(net, addr, la, ra, deadline, trace)
 			if _, ok := <-sig; ok {
 				lane <- racer{c, err}
 			} else if err == nil {
@@ -233,7 +299,16 @@ func dialMulti(net, addr string, la Addr, ras addrList, deadline time.Time) (Con
 
 // dialSingle attempts to establish and returns a single connection to
 // the destination address.
-func dialSingle(net, addr string, la, ra Addr, deadline time.Time) (c Conn, err error) {
+func dialSingle(net, addr string, la, ra Addr, deadline time.Time, trace *nettrace.Trace) (c Conn, err error) {
+	if trace != nil {
+		raStr := ra.String()
+		if trace.ConnectStart != nil {
+			trace.ConnectStart(net, raStr)
+		}
+		if trace.ConnectDone != nil {
+			defer func() { trace.ConnectDone(net, raStr, err) }()
+		}
+	}
 	if la != nil && la.Network() != ra.Network() {
 		return nil, &OpError{Op: "dial", Net: net, Addr: ra, Err: errors.New("mismatched local address type " + la.Network())}
 	}
@@ -264,7 +339,7 @@ func dialSingle(net, addr string, la, ra Addr, deadline time.Time) (c Conn, err
 // "tcp6", "unix" or "unixpacket".
 // See Dial for the syntax of laddr.
 func Listen(net, laddr string) (Listener, error) {
-	addrs, err := resolveAddrList("listen", net, laddr, noDeadline)
+	addrs, err := resolveAddrList("listen", net, laddr, noDeadline, nil)
 	if err != nil {
 		return nil, &OpError{Op: "listen", Net: net, Addr: nil, Err: err}
 	}
@@ -288,7 +363,7 @@ func Listen(net, laddr string) (Listener, error) {
 // "udp6", "ip", "ip4", "ip6" or "unixgram".
 // See Dial for the syntax of laddr.
 func ListenPacket(net, laddr string) (PacketConn, error) {
-	addrs, err := resolveAddrList("listen", net, laddr, noDeadline)
+	addrs, err := resolveAddrList("listen", net, laddr, noDeadline, nil)
 	if err != nil {
 		return nil, &OpError{Op: "listen", Net: net, Addr: nil, Err: err}
 	}
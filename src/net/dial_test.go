@@ -5,6 +5,7 @@
 package net
 
 import (
+	"context"
 	"fmt"
 	"net/internal/socktest"
 	"runtime"
@@ -293,3 +294,55 @@ func TestDialerKeepAlive(t *testing.T) {
 		}
 	}
 }
+
+func TestDialerDialContext(t *testing.T) {
+	origTestHookLookupIP := testHookLookupIP
+	defer func() { testHookLookupIP = origTestHookLookupIP }()
+	release := make(chan bool)
+	testHookLookupIP = func(fn func(string) ([]IPAddr, error), host string) ([]IPAddr, error) {
+		<-release
+		return lookupLocalhost(fn, host)
+	}
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		var d Dialer
+		c, err := d.DialContext(ctx, "tcp", "localhost:0")
+		if err == nil {
+			c.Close()
+		}
+		errc <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-errc:
+		if perr := parseDialError(err); perr != nil {
+			t.Error(perr)
+		}
+		if oe, ok := err.(*OpError); !ok || oe.Err != errCanceled {
+			t.Errorf("got %v; want %v", err, errCanceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("DialContext didn't return after cancelation")
+	}
+
+	// A context whose deadline has already passed fails the dial
+	// with a timeout.
+	ctx, cancel = context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	var d Dialer
+	c, err := d.DialContext(ctx, "tcp", "localhost:0")
+	if err == nil {
+		c.Close()
+		t.Fatal("DialContext with expired context succeeded")
+	}
+	if perr := parseDialError(err); perr != nil {
+		t.Error(perr)
+	}
+	if nerr, ok := err.(Error); !ok || !nerr.Timeout() {
+		t.Errorf("got %v; want timeout error", err)
+	}
+}
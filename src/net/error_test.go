@@ -96,7 +96,7 @@ second:
 		goto third
 	}
 	switch nestedErr {
-	case errClosing, errMissingAddress:
+	case errClosing, errMissingAddress, errCanceled:
 		return nil
 	}
 	return fmt.Errorf("unexpected type on 2nd nested level: %T", nestedErr)
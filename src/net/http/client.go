@@ -348,6 +348,7 @@ func (c *Client) doFollowingRedirects(ireq *Request, shouldRedirect func(int) bo
 				nreq.Method = "GET"
 			}
 			nreq.Header = make(Header)
+			nreq.ctx = ireq.ctx
 			nreq.URL, err = base.Parse(urlStr)
 			if err != nil {
 				break
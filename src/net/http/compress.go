@@ -0,0 +1,304 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Response compression.
+
+package http
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CompressHandler returns a handler that runs h and compresses its
+// responses with gzip or deflate, as negotiated by the request's
+// Accept-Encoding header.
+//
+// A response is sent uncompressed if it has no body, is a 206 Partial
+// Content response, already has a Content-Encoding, or has a
+// Content-Type that is already compressed, such as most images.
+// Responses without a Content-Type get one from DetectContentType
+// before compression. Compressed responses have their Content-Length
+// removed, and all responses get "Vary: Accept-Encoding".
+//
+// The ResponseWriter passed to h implements Flusher, CloseNotifier,
+// Hijacker and Pusher, delegating to the underlying ResponseWriter.
+// Flush flushes any compressed data written so far.
+func CompressHandler(h Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		enc := negotiateEncoding(r.Header["Accept-Encoding"])
+		addVary(w.Header(), "Accept-Encoding")
+		if enc == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{rw: w, encoding: enc}
+		defer cw.close()
+		h.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding returns the content-coding, "gzip" or "deflate",
+// to use for a request with the given Accept-Encoding header values,
+// or "" if neither is acceptable. gzip wins ties.
+func negotiateEncoding(accept []string) string {
+	gzipQ, deflateQ, starQ := -1.0, -1.0, -1.0
+	for _, v := range accept {
+		for _, part := range strings.Split(v, ",") {
+			coding, q := part, 1.0
+			if i := strings.Index(part, ";"); i >= 0 {
+				coding = part[:i]
+				q = parseQValue(part[i+1:])
+			}
+			switch strings.ToLower(strings.TrimSpace(coding)) {
+			case "gzip", "x-gzip":
+				gzipQ = q
+			case "deflate":
+				deflateQ = q
+			case "*":
+				starQ = q
+			}
+		}
+	}
+	if gzipQ < 0 {
+		gzipQ = starQ
+	}
+	if deflateQ < 0 {
+		deflateQ = starQ
+	}
+	switch {
+	case gzipQ > 0 && gzipQ >= deflateQ:
+		return "gzip"
+	case deflateQ > 0:
+		return "deflate"
+	}
+	return ""
+}
+
+// parseQValue returns the q parameter among the ";"-separated
+// parameters params, or 1 if there is none. A malformed q is 0.
+func parseQValue(params string) float64 {
+	for _, p := range strings.Split(params, ";") {
+		p = strings.TrimSpace(p)
+		if len(p) < 2 || (p[0] != 'q' && p[0] != 'Q') || p[1] != '=' {
+			continue
+		}
+		q, err := strconv.ParseFloat(p[2:], 64)
+		if err != nil || q < 0 || q > 1 {
+			return 0
+		}
+		return q
+	}
+	return 1
+}
+
+// addVary adds token to h's Vary header, unless it's already there.
+func addVary(h Header, token string) {
+	for _, v := range h["Vary"] {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t == "*" || strings.EqualFold(t, token) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", token)
+}
+
+// isCompressedType reports whether content of the given Content-Type
+// is already compressed, so that compressing it again is a waste.
+func isCompressedType(contentType string) bool {
+	mt := strings.ToLower(contentType)
+	if i := strings.Index(mt, ";"); i >= 0 {
+		mt = mt[:i]
+	}
+	mt = strings.TrimSpace(mt)
+	switch {
+	case mt == "image/svg+xml":
+		return false
+	case strings.HasPrefix(mt, "image/"),
+		strings.HasPrefix(mt, "audio/"),
+		strings.HasPrefix(mt, "video/"):
+		return true
+	}
+	switch mt {
+	case "application/x-gzip", "application/gzip", "application/zip",
+		"application/x-rar-compressed", "application/x-bzip2",
+		"application/x-7z-compressed", "application/pdf":
+		return true
+	}
+	return false
+}
+
+var (
+	gzipWriterPool sync.Pool
+	zlibWriterPool sync.Pool
+)
+
+// compressWriter is the ResponseWriter given to the handler wrapped
+// by CompressHandler. Whether to compress is decided when the header
+// is written, which is deferred until the first non-empty Write, a
+// Flush or the handler's return, so that a response turning out to
+// have no body is not compressed.
+type compressWriter struct {
+	rw       ResponseWriter
+	encoding string // "gzip" or "deflate"
+
+	code     int  // status code passed to WriteHeader, or 0
+	decided  bool // whether rw's header has been written
+	hijacked bool // whether the connection was hijacked
+
+	gz *gzip.Writer // non-nil if compressing with gzip
+	zw *zlib.Writer // non-nil if compressing with deflate
+}
+
+func (cw *compressWriter) Header() Header { return cw.rw.Header() }
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.decided || cw.code != 0 {
+		return
+	}
+	cw.code = code
+	if !bodyAllowedForStatus(code) {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		if cw.Header().get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", DetectContentType(p))
+		}
+		cw.decide(true)
+	}
+	switch {
+	case cw.gz != nil:
+		return cw.gz.Write(p)
+	case cw.zw != nil:
+		return cw.zw.Write(p)
+	}
+	return cw.rw.Write(p)
+}
+
+func (cw *compressWriter) status() int {
+	if cw.code == 0 {
+		return StatusOK
+	}
+	return cw.code
+}
+
+// decide writes the header to the underlying ResponseWriter,
+// choosing whether to compress. hasBody reports whether a body may
+// follow.
+func (cw *compressWriter) decide(hasBody bool) {
+	cw.decided = true
+	h := cw.Header()
+	code := cw.status()
+	if hasBody &&
+		bodyAllowedForStatus(code) &&
+		code != StatusPartialContent &&
+		h.get("Content-Encoding") == "" &&
+		h.get("Content-Range") == "" &&
+		!isCompressedType(h.get("Content-Type")) {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		// Byte ranges of the uncompressed content don't apply.
+		h.Del("Accept-Ranges")
+		switch cw.encoding {
+		case "gzip":
+			if gz, ok := gzipWriterPool.Get().(*gzip.Writer); ok {
+				gz.Reset(cw.rw)
+				cw.gz = gz
+			} else {
+				cw.gz = gzip.NewWriter(cw.rw)
+			}
+		case "deflate":
+			// The "deflate" content-coding is the zlib format
+			// (RFC 2616 section 3.5), not raw DEFLATE.
+			if zw, ok := zlibWriterPool.Get().(*zlib.Writer); ok {
+				zw.Reset(cw.rw)
+				cw.zw = zw
+			} else {
+				cw.zw = zlib.NewWriter(cw.rw)
+			}
+		}
+	}
+	cw.rw.WriteHeader(code)
+}
+
+// close finishes the response after the handler returns.
+func (cw *compressWriter) close() {
+	if cw.hijacked {
+		return
+	}
+	if !cw.decided {
+		cw.decide(false)
+	}
+	if cw.gz != nil {
+		cw.gz.Close()
+		gzipWriterPool.Put(cw.gz)
+		cw.gz = nil
+	}
+	if cw.zw != nil {
+		cw.zw.Close()
+		zlibWriterPool.Put(cw.zw)
+		cw.zw = nil
+	}
+}
+
+// Flush implements the Flusher interface. A Flush before the
+// Content-Type is known sends the response uncompressed.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.decide(cw.Header().get("Content-Type") != "")
+	}
+	switch {
+	case cw.gz != nil:
+		cw.gz.Flush()
+	case cw.zw != nil:
+		cw.zw.Flush()
+	}
+	if f, ok := cw.rw.(Flusher); ok {
+		f.Flush()
+	}
+}
+
+// CloseNotify implements the CloseNotifier interface. If the
+// underlying ResponseWriter is not a CloseNotifier, the channel
+// never receives a value.
+func (cw *compressWriter) CloseNotify() <-chan bool {
+	if cn, ok := cw.rw.(CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+	return make(chan bool)
+}
+
+// Hijack implements the Hijacker interface. It fails once any of
+// the response has been written.
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := cw.rw.(Hijacker)
+	if !ok || cw.decided {
+		return nil, nil, ErrNotSupported
+	}
+	c, brw, err := hj.Hijack()
+	if err == nil {
+		cw.hijacked = true
+	}
+	return c, brw, err
+}
+
+// Push implements the Pusher interface.
+func (cw *compressWriter) Push(target string, opts *PushOptions) error {
+	if p, ok := cw.rw.(Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return ErrNotSupported
+}
@@ -0,0 +1,231 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	. "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var compressBody = strings.Repeat("Hello, compressed world! ", 100)
+
+var compressNegotiationTests = []struct {
+	accept string
+	want   string // Content-Encoding of the response
+}{
+	{"", ""},
+	{"gzip", "gzip"},
+	{"deflate", "deflate"},
+	{"gzip, deflate", "gzip"},
+	{"deflate, gzip", "gzip"},
+	{"gzip;q=0.5, deflate", "deflate"},
+	{"gzip;q=0, deflate;q=0", ""},
+	{"*", "gzip"},
+	{"*;q=0.1, gzip;q=0", "deflate"},
+	{"identity", ""},
+	{"br, GZIP;Q=1", "gzip"},
+}
+
+func TestCompressHandlerNegotiation(t *testing.T) {
+	defer afterTest(t)
+	ts := httptest.NewServer(CompressHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, compressBody)
+	})))
+	defer ts.Close()
+
+	tr := &Transport{DisableCompression: true}
+	defer tr.CloseIdleConnections()
+	for _, tt := range compressNegotiationTests {
+		req, _ := NewRequest("GET", ts.URL, nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept-Encoding", tt.accept)
+		}
+		res, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := res.Header.Get("Content-Encoding"); got != tt.want {
+			t.Errorf("Accept-Encoding %q: Content-Encoding = %q; want %q", tt.accept, got, tt.want)
+		}
+		if got := res.Header.Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("Accept-Encoding %q: Vary = %q; want Accept-Encoding", tt.accept, got)
+		}
+		var body io.Reader = res.Body
+		switch tt.want {
+		case "gzip":
+			body, err = gzip.NewReader(res.Body)
+		case "deflate":
+			body, err = zlib.NewReader(res.Body)
+		}
+		if err != nil {
+			t.Fatalf("Accept-Encoding %q: %v", tt.accept, err)
+		}
+		got, err := ioutil.ReadAll(body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatalf("Accept-Encoding %q: reading body: %v", tt.accept, err)
+		}
+		if string(got) != compressBody {
+			t.Errorf("Accept-Encoding %q: body mismatch", tt.accept)
+		}
+	}
+}
+
+func TestCompressHandlerSkips(t *testing.T) {
+	defer afterTest(t)
+	png := "\x89PNG\x0D\x0A\x1A\x0A" + compressBody
+	ts := httptest.NewServer(CompressHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+		switch r.URL.Path {
+		case "/png":
+			io.WriteString(w, png)
+		case "/encoded":
+			w.Header().Set("Content-Encoding", "br")
+			io.WriteString(w, compressBody)
+		case "/empty":
+		case "/emptytyped":
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(StatusOK)
+			w.Write(nil)
+		case "/nocontent":
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(StatusNoContent)
+		case "/notmodified":
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(StatusNotModified)
+		case "/file":
+			ServeContent(w, r, "file.txt", time.Time{}, strings.NewReader(compressBody))
+		}
+	})))
+	defer ts.Close()
+
+	tr := &Transport{DisableCompression: true}
+	defer tr.CloseIdleConnections()
+	get := func(path, rangeHdr string) *Response {
+		req, _ := NewRequest("GET", ts.URL+path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		if rangeHdr != "" {
+			req.Header.Set("Range", rangeHdr)
+		}
+		res, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	tests := []struct {
+		path, rangeHdr string
+		status         int
+		encoding       string
+		body           string
+	}{
+		{"/png", "", 200, "", png},
+		{"/encoded", "", 200, "br", compressBody},
+		{"/empty", "", 200, "", ""},
+		{"/emptytyped", "", 200, "", ""},
+		{"/nocontent", "", 204, "", ""},
+		{"/notmodified", "", 304, "", ""},
+		{"/file", "bytes=0-4", 206, "", compressBody[:5]},
+	}
+	for _, tt := range tests {
+		res := get(tt.path, tt.rangeHdr)
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != tt.status {
+			t.Errorf("%s: status = %d; want %d", tt.path, res.StatusCode, tt.status)
+		}
+		if got := res.Header.Get("Content-Encoding"); got != tt.encoding {
+			t.Errorf("%s: Content-Encoding = %q; want %q", tt.path, got, tt.encoding)
+		}
+		if string(body) != tt.body {
+			t.Errorf("%s: body = %q; want %q", tt.path, body, tt.body)
+		}
+	}
+
+	// A full ServeContent response is compressed and loses its
+	// Content-Length, which was for the uncompressed content.
+	res := get("/file", "")
+	defer res.Body.Close()
+	if res.Header.Get("Content-Encoding") != "gzip" || res.ContentLength == int64(len(compressBody)) {
+		t.Errorf("ServeContent: Content-Encoding = %q, ContentLength = %d; want gzip and the compressed length",
+			res.Header.Get("Content-Encoding"), res.ContentLength)
+	}
+	if ct := res.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("ServeContent: Content-Type = %q; want text/plain", ct)
+	}
+}
+
+func TestCompressHandlerFlush(t *testing.T) {
+	defer afterTest(t)
+	flushed := make(chan bool)
+	ts := httptest.NewServer(CompressHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, "first\n")
+		w.(Flusher).Flush()
+		<-flushed
+		io.WriteString(w, "second\n")
+	})))
+	defer ts.Close()
+
+	tr := &Transport{}
+	defer tr.CloseIdleConnections()
+	req, _ := NewRequest("GET", ts.URL, nil)
+	res, err := tr.RoundTrip(req) // asks for and decodes gzip
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	br := bufio.NewReader(res.Body)
+	line, err := br.ReadString('\n')
+	if err != nil || line != "first\n" {
+		t.Fatalf("first line = %q, %v; want it flushed before the handler finished", line, err)
+	}
+	close(flushed)
+	rest, err := ioutil.ReadAll(br)
+	if err != nil || string(rest) != "second\n" {
+		t.Errorf("rest = %q, %v", rest, err)
+	}
+}
+
+func TestCompressHandlerHijack(t *testing.T) {
+	defer afterTest(t)
+	ts := httptest.NewServer(CompressHandler(HandlerFunc(func(w ResponseWriter, r *Request) {
+		if _, ok := w.(CloseNotifier); !ok {
+			t.Error("ResponseWriter is not a CloseNotifier")
+		}
+		conn, brw, err := w.(Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		io.WriteString(brw, "HTTP/1.0 200 OK\r\n\r\nhijacked")
+		brw.Flush()
+	})))
+	defer ts.Close()
+
+	req, _ := NewRequest("GET", ts.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	tr := &Transport{DisableCompression: true}
+	defer tr.CloseIdleConnections()
+	res, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if !bytes.Equal(body, []byte("hijacked")) {
+		t.Errorf("body = %q; want hijacked", body)
+	}
+}
@@ -3,6 +3,10 @@
 // license that can be found in the LICENSE file.
 
 // Package cookiejar implements an in-memory RFC 6265-compliant http.CookieJar.
+//
+// A Jar's contents can be exported and imported with Entries and
+// SetEntries, or kept in a Storage such as FileStorage so that they
+// survive a restart.
 package cookiejar
 
 import (
@@ -54,11 +58,16 @@ type Options struct {
 	// secure: it means that the HTTP server for foo.co.uk can set a cookie
 	// for bar.co.uk.
 	PublicSuffixList PublicSuffixList
+
+	// Storage, if non-nil, is where Save and Load keep the jar's
+	// entries. New loads any entries it already holds.
+	Storage Storage
 }
 
 // Jar implements the http.CookieJar interface from the net/http package.
 type Jar struct {
-	psList PublicSuffixList
+	psList  PublicSuffixList
+	storage Storage
 
 	// mu locks the remaining fields.
 	mu sync.Mutex
@@ -80,6 +89,12 @@ func New(o *Options) (*Jar, error) {
 	}
 	if o != nil {
 		jar.psList = o.PublicSuffixList
+		jar.storage = o.Storage
+	}
+	if jar.storage != nil {
+		if err := jar.Load(); err != nil {
+			return nil, err
+		}
 	}
 	return jar, nil
 }
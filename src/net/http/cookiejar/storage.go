@@ -0,0 +1,258 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cookiejar
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Entry is the exported form of a cookie stored in a Jar, as returned
+// by Jar.Entries and accepted by Jar.SetEntries. Its fields are those
+// of RFC 6265 section 5.3.
+type Entry struct {
+	Name       string
+	Value      string
+	Domain     string // canonical host for host-only cookies, else the domain attribute
+	Path       string
+	Secure     bool
+	HttpOnly   bool
+	Persistent bool // false for session cookies
+	HostOnly   bool
+	Expires    time.Time // zero for session cookies
+	Creation   time.Time
+	LastAccess time.Time
+}
+
+// Storage is a backend that persists the entries of a Jar.
+//
+// Implementations of Storage must be safe for concurrent use by
+// multiple goroutines.
+type Storage interface {
+	// Load returns the previously saved entries. A backend that
+	// has never been saved to returns no entries and a nil error.
+	Load() ([]Entry, error)
+
+	// Save replaces the saved state with entries.
+	Save(entries []Entry) error
+}
+
+var errNoStorage = errors.New("cookiejar: jar has no Storage")
+
+// Entries returns all cookies in the jar that have not expired,
+// including session cookies, ordered by creation time.
+func (j *Jar) Entries() []Entry {
+	return j.exportEntries(time.Now())
+}
+
+// exportEntries is like Entries but takes the current time as a parameter.
+func (j *Jar) exportEntries(now time.Time) []Entry {
+	j.mu.Lock()
+	var all []entry
+	for _, submap := range j.entries {
+		for _, e := range submap {
+			if e.Persistent && !e.Expires.After(now) {
+				continue
+			}
+			all = append(all, e)
+		}
+	}
+	j.mu.Unlock()
+
+	sort.Sort(byCreation(all))
+	entries := make([]Entry, len(all))
+	for i, e := range all {
+		entries[i] = Entry{
+			Name:       e.Name,
+			Value:      e.Value,
+			Domain:     e.Domain,
+			Path:       e.Path,
+			Secure:     e.Secure,
+			HttpOnly:   e.HttpOnly,
+			Persistent: e.Persistent,
+			HostOnly:   e.HostOnly,
+			Creation:   e.Creation,
+			LastAccess: e.LastAccess,
+		}
+		if e.Persistent {
+			entries[i].Expires = e.Expires
+		}
+	}
+	return entries
+}
+
+// SetEntries adds entries, typically obtained from Entries, to the
+// jar, replacing any cookie with the same name, domain and path.
+// Entries that have expired are dropped, as are entries whose domain
+// or path could not have been set by a server, such as a domain
+// cookie for a public suffix.
+func (j *Jar) SetEntries(entries []Entry) {
+	j.importEntries(entries, time.Now())
+}
+
+// importEntries is like SetEntries but takes the current time as a parameter.
+func (j *Jar) importEntries(entries []Entry, now time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, x := range entries {
+		e, ok := j.entryFromExport(x, now)
+		if !ok {
+			continue
+		}
+		key := jarKey(e.Domain, j.psList)
+		submap := j.entries[key]
+		if submap == nil {
+			submap = make(map[string]entry)
+			j.entries[key] = submap
+		}
+		id := e.id()
+		if old, ok := submap[id]; ok {
+			e.seqNum = old.seqNum
+		} else {
+			e.seqNum = j.nextSeqNum
+			j.nextSeqNum++
+		}
+		submap[id] = e
+	}
+}
+
+// entryFromExport validates x and converts it to an entry. It reports
+// false if x has expired with respect to now or is malformed.
+func (j *Jar) entryFromExport(x Entry, now time.Time) (e entry, ok bool) {
+	if x.Persistent && !x.Expires.After(now) {
+		return e, false
+	}
+	if x.Path == "" || x.Path[0] != '/' {
+		return e, false
+	}
+	domain, err := toASCII(strings.ToLower(x.Domain))
+	if err != nil || domain == "" || domain[0] == '.' || domain[len(domain)-1] == '.' {
+		return e, false
+	}
+	if !x.HostOnly {
+		// A domain cookie must satisfy the same rules as one
+		// received from a server at that domain.
+		d, hostOnly, err := j.domainAndType(domain, domain)
+		if err != nil || hostOnly || d != domain {
+			return e, false
+		}
+	}
+
+	e = entry{
+		Name:       x.Name,
+		Value:      x.Value,
+		Domain:     domain,
+		Path:       x.Path,
+		Secure:     x.Secure,
+		HttpOnly:   x.HttpOnly,
+		Persistent: x.Persistent,
+		HostOnly:   x.HostOnly,
+		Expires:    x.Expires,
+		Creation:   x.Creation,
+		LastAccess: x.LastAccess,
+	}
+	if !e.Persistent {
+		e.Expires = endOfTime
+	}
+	if e.Creation.IsZero() {
+		e.Creation = now
+	}
+	if e.LastAccess.IsZero() {
+		e.LastAccess = e.Creation
+	}
+	return e, true
+}
+
+// byCreation is a []entry sort.Interface that sorts by creation time
+// and then by sequence number.
+type byCreation []entry
+
+func (s byCreation) Len() int { return len(s) }
+
+func (s byCreation) Less(i, j int) bool {
+	if !s[i].Creation.Equal(s[j].Creation) {
+		return s[i].Creation.Before(s[j].Creation)
+	}
+	return s[i].seqNum < s[j].seqNum
+}
+
+func (s byCreation) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+// Load adds the entries saved in the jar's Storage to the jar, as
+// SetEntries does. It returns an error if the jar has no Storage.
+func (j *Jar) Load() error {
+	if j.storage == nil {
+		return errNoStorage
+	}
+	entries, err := j.storage.Load()
+	if err != nil {
+		return err
+	}
+	j.SetEntries(entries)
+	return nil
+}
+
+// Save writes the jar's unexpired entries to its Storage. It returns
+// an error if the jar has no Storage.
+func (j *Jar) Save() error {
+	if j.storage == nil {
+		return errNoStorage
+	}
+	return j.storage.Save(j.Entries())
+}
+
+// FileStorage returns a Storage that keeps entries as JSON in the
+// named file. A missing file holds no entries. Save writes a new file,
+// readable only by its owner, and renames it over the old one.
+func FileStorage(filename string) Storage {
+	return fileStorage(filename)
+}
+
+type fileStorage string
+
+func (f fileStorage) Load() ([]Entry, error) {
+	data, err := ioutil.ReadFile(string(f))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (f fileStorage) Save(entries []Entry) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	name := string(f)
+	tmp, err := ioutil.TempFile(filepath.Dir(name), filepath.Base(name)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
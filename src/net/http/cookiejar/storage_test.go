@@ -0,0 +1,144 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cookiejar
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cookieString returns the cookies jar sends to rawurl at now in the
+// form "name1=val1 name2=val2".
+func cookieString(jar *Jar, rawurl string, now time.Time) string {
+	var s []string
+	for _, c := range jar.cookies(mustParseURL(rawurl), now) {
+		s = append(s, c.Name+"="+c.Value)
+	}
+	return strings.Join(s, " ")
+}
+
+func parseSetCookies(lines ...string) []*http.Cookie {
+	return (&http.Response{Header: http.Header{"Set-Cookie": lines}}).Cookies()
+}
+
+func TestExportImport(t *testing.T) {
+	src := newTestJar()
+	src.setCookies(mustParseURL("http://www.host.test/dir/page"), parseSetCookies(
+		"host=1",
+		"domain=2; domain=host.test; path=/",
+		"secure=3; secure; httponly; max-age=3600",
+		"short=4; max-age=10",
+		"gone=5; max-age=-1",
+	), tNow)
+
+	entries := src.exportEntries(tNow)
+	if len(entries) != 4 {
+		t.Fatalf("got %d entries; want 4: %+v", len(entries), entries)
+	}
+	for _, e := range entries {
+		switch e.Name {
+		case "host":
+			if !e.HostOnly || e.Domain != "www.host.test" || e.Path != "/dir" || e.Persistent || !e.Expires.IsZero() {
+				t.Errorf("host-only session cookie exported as %+v", e)
+			}
+		case "domain":
+			if e.HostOnly || e.Domain != "host.test" || e.Path != "/" {
+				t.Errorf("domain cookie exported as %+v", e)
+			}
+		case "secure":
+			if !e.Secure || !e.HttpOnly || !e.Persistent || !e.Expires.Equal(tNow.Add(time.Hour)) {
+				t.Errorf("secure cookie exported as %+v", e)
+			}
+		}
+	}
+
+	// Load into a fresh jar a minute later: the short-lived cookie
+	// has expired and must be pruned.
+	now := tNow.Add(time.Minute)
+	dst := newTestJar()
+	dst.importEntries(entries, now)
+	if got := len(dst.exportEntries(now)); got != 3 {
+		t.Errorf("imported jar has %d entries; want 3", got)
+	}
+	tests := []struct {
+		url, want string
+	}{
+		{"http://www.host.test/dir/x", "host=1 domain=2"},
+		{"https://www.host.test/dir/x", "host=1 secure=3 domain=2"},
+		{"http://www.host.test/other", "domain=2"},
+		{"http://sub.host.test/dir/x", "domain=2"},
+		{"http://other.test/", ""},
+	}
+	for _, tt := range tests {
+		if got := cookieString(dst, tt.url, now); got != tt.want {
+			t.Errorf("Cookies(%q) = %q; want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestImportRejectsBadEntries(t *testing.T) {
+	jar := newTestJar()
+	jar.importEntries([]Entry{
+		{Name: "a", Value: "1", Domain: "co.uk", Path: "/"},           // public suffix
+		{Name: "b", Value: "2", Domain: "192.168.0.1", Path: "/"},     // domain cookie for an IP
+		{Name: "c", Value: "3", Domain: "www.host.test", Path: "rel"}, // bad path
+		{Name: "d", Value: "4", Domain: ".host.test", Path: "/"},      // malformed domain
+		{Name: "e", Value: "5", Domain: "www.host.test", Path: "/", HostOnly: true},
+	}, tNow)
+	entries := jar.exportEntries(tNow)
+	if len(entries) != 1 || entries[0].Name != "e" {
+		t.Errorf("imported entries = %+v; want only e", entries)
+	}
+}
+
+func TestFileStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cookiejar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cookies.json")
+
+	jar, err := New(&Options{PublicSuffixList: testPSL{}, Storage: FileStorage(file)})
+	if err != nil {
+		t.Fatalf("New with missing file: %v", err)
+	}
+	jar.SetCookies(mustParseURL("http://www.host.test/"), parseSetCookies(
+		"session=1",
+		"persistent=2; max-age=3600",
+	))
+	if err := jar.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(file); err != nil {
+		t.Fatal(err)
+	} else if perm := fi.Mode().Perm(); perm&077 != 0 {
+		t.Errorf("cookie file mode = %v; want it private", perm)
+	}
+
+	jar2, err := New(&Options{PublicSuffixList: testPSL{}, Storage: FileStorage(file)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cookieString(jar2, "http://www.host.test/", time.Now()), "session=1 persistent=2"; got != want {
+		t.Errorf("reloaded cookies = %q; want %q", got, want)
+	}
+
+	if err := ioutil.WriteFile(file, []byte("not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(&Options{Storage: FileStorage(file)}); err == nil {
+		t.Error("New with corrupt file succeeded")
+	}
+
+	if err := newTestJar().Save(); err == nil {
+		t.Error("Save without Storage succeeded")
+	}
+}
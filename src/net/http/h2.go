@@ -0,0 +1,258 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Code shared by the HTTP/2 server (h2_server.go) and client
+// (h2_transport.go).
+
+package http
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+
+	"net/http/internal/hpack"
+	"net/http/internal/http2"
+)
+
+var (
+	errH2StreamClosed = errors.New("http2: stream closed")
+	errH2ConnClosed   = errors.New("http2: connection closed")
+)
+
+// h2ConnectionHeaders are the connection-specific header fields that
+// HTTP/2 forbids (RFC 7540 section 8.1.2.2). They are dropped when
+// sending and make a received message malformed.
+var h2ConnectionHeaders = map[string]bool{
+	"connection":        true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+}
+
+// validH2HeaderName reports whether name is a valid HTTP/2 header
+// field name: a non-empty token with no upper case letters.
+func validH2HeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !isToken(rune(c)) || 'A' <= c && c <= 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// h2HeaderFields appends to fields the lower-cased fields of h,
+// omitting connection-specific headers and any key for which skip
+// reports true.
+func h2HeaderFields(fields []hpack.HeaderField, h Header, skip func(key string) bool) []hpack.HeaderField {
+	for k, vv := range h {
+		lk := strings.ToLower(k)
+		if h2ConnectionHeaders[lk] || skip != nil && skip(k) {
+			continue
+		}
+		for _, v := range vv {
+			fields = append(fields, hpack.HeaderField{Name: lk, Value: v})
+		}
+	}
+	return fields
+}
+
+// h2TrailerKeys returns the canonical trailer names declared in the
+// "Trailer" values of h, skipping those not permitted in trailers.
+func h2TrailerKeys(h Header) []string {
+	var keys []string
+	for _, v := range h["Trailer"] {
+		for _, key := range strings.Split(v, ",") {
+			key = CanonicalHeaderKey(strings.TrimSpace(key))
+			switch key {
+			case "", "Transfer-Encoding", "Trailer", "Content-Length":
+				continue
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// h2encoder encodes header blocks for one connection. Header blocks
+// must be written to the wire in the order they are encoded, so the
+// caller holds the connection's write lock while using it.
+type h2encoder struct {
+	buf bytes.Buffer
+	enc *hpack.Encoder
+}
+
+func newH2Encoder() *h2encoder {
+	e := new(h2encoder)
+	e.enc = hpack.NewEncoder(&e.buf)
+	return e
+}
+
+// writeHeaders encodes fields and writes them as a HEADERS frame
+// followed by as many CONTINUATION frames as needed to respect
+// maxFrameSize.
+func (e *h2encoder) writeHeaders(fr *http2.Framer, streamID uint32, endStream bool, maxFrameSize uint32, fields []hpack.HeaderField) error {
+	block := e.encode(fields)
+	first, rest := h2splitBlock(block, maxFrameSize)
+	err := fr.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      streamID,
+		BlockFragment: first,
+		EndStream:     endStream,
+		EndHeaders:    len(rest) == 0,
+	})
+	if err != nil {
+		return err
+	}
+	return h2writeContinuations(fr, streamID, rest, maxFrameSize)
+}
+
+// writePushPromise is like writeHeaders for a PUSH_PROMISE frame
+// reserving promiseID.
+func (e *h2encoder) writePushPromise(fr *http2.Framer, streamID, promiseID uint32, maxFrameSize uint32, fields []hpack.HeaderField) error {
+	block := e.encode(fields)
+	// The promised stream ID takes four bytes of the first frame.
+	first, rest := h2splitBlock(block, maxFrameSize-4)
+	err := fr.WritePushPromise(http2.PushPromiseParam{
+		StreamID:      streamID,
+		PromiseID:     promiseID,
+		BlockFragment: first,
+		EndHeaders:    len(rest) == 0,
+	})
+	if err != nil {
+		return err
+	}
+	return h2writeContinuations(fr, streamID, rest, maxFrameSize)
+}
+
+func (e *h2encoder) encode(fields []hpack.HeaderField) []byte {
+	e.buf.Reset()
+	for _, f := range fields {
+		e.enc.WriteField(f)
+	}
+	return e.buf.Bytes()
+}
+
+func h2splitBlock(block []byte, max uint32) (first, rest []byte) {
+	if uint32(len(block)) <= max {
+		return block, nil
+	}
+	return block[:max], block[max:]
+}
+
+func h2writeContinuations(fr *http2.Framer, streamID uint32, rest []byte, maxFrameSize uint32) error {
+	for len(rest) > 0 {
+		var frag []byte
+		frag, rest = h2splitBlock(rest, maxFrameSize)
+		if err := fr.WriteContinuation(streamID, len(rest) == 0, frag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// h2pipe is a buffer connecting the goroutine reading frames off a
+// connection, which writes DATA payloads into it, with the goroutine
+// reading a request or response body. Writes never block.
+type h2pipe struct {
+	mu     sync.Mutex
+	c      sync.Cond // c.L == &mu
+	b      bytes.Buffer
+	err    error // returned by Read once b is drained
+	brkErr error // returned by Read immediately, discarding b
+
+	// onRead, if non-nil, is called after each successful Read
+	// with the number of bytes read, without mu held.
+	onRead func(n int)
+}
+
+func newH2Pipe(onRead func(int)) *h2pipe {
+	p := &h2pipe{onRead: onRead}
+	p.c.L = &p.mu
+	return p
+}
+
+func (p *h2pipe) Read(d []byte) (n int, err error) {
+	p.mu.Lock()
+	for {
+		if p.brkErr != nil {
+			p.mu.Unlock()
+			return 0, p.brkErr
+		}
+		if p.b.Len() > 0 {
+			n, _ = p.b.Read(d)
+			break
+		}
+		if p.err != nil {
+			p.mu.Unlock()
+			return 0, p.err
+		}
+		p.c.Wait()
+	}
+	p.mu.Unlock()
+	if p.onRead != nil {
+		p.onRead(n)
+	}
+	return n, nil
+}
+
+// Write buffers d. It fails once the pipe has been closed or broken.
+func (p *h2pipe) Write(d []byte) (n int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.brkErr != nil {
+		return 0, p.brkErr
+	}
+	if p.err != nil {
+		return 0, errH2StreamClosed
+	}
+	defer p.c.Signal()
+	return p.b.Write(d)
+}
+
+// closeWithError makes Read return err once the buffered data has
+// been consumed. Only the first call has any effect.
+func (p *h2pipe) closeWithError(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		p.err = err
+		p.c.Broadcast()
+	}
+}
+
+// breakWithError makes Read return err immediately, discarding any
+// buffered data. It returns the number of bytes discarded, so the
+// caller can give back their flow-control credit.
+func (p *h2pipe) breakWithError(err error) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.brkErr != nil {
+		return 0
+	}
+	p.brkErr = err
+	n := p.b.Len()
+	p.b.Reset()
+	p.c.Broadcast()
+	return n
+}
+
+// h2flow is a flow-control window for sending.
+type h2flow int32
+
+// add adds n to the window, reporting false if the result would
+// exceed the largest legal window.
+func (f *h2flow) add(n int32) bool {
+	sum := int64(*f) + int64(n)
+	if sum > http2.MaxWindowSize {
+		return false
+	}
+	*f = h2flow(sum)
+	return true
+}
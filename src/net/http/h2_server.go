@@ -0,0 +1,1369 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// HTTP/2 server. See RFC 7540.
+
+package http
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"net/http/internal/hpack"
+	"net/http/internal/http2"
+)
+
+const (
+	h2ServerMaxConcurrentStreams = 250
+	h2ServerInitialWindowSize    = 1 << 20 // per-stream receive window
+	h2ServerConnWindowSize       = 1 << 20 // connection receive window
+	h2ServerMaxReadFrameSize     = 1 << 20
+	h2PrefaceTimeout             = 10 * time.Second
+
+	// h2ResponseBufferSize is how much response body is buffered
+	// before the first DATA frame is sent. It is at least 512
+	// bytes, for DetectContentType.
+	h2ResponseBufferSize = 4 << 10
+)
+
+var (
+	errH2ClientReset   = errors.New("http2: client reset stream")
+	errH2RecursivePush = errors.New("http2: recursive push not allowed")
+	errH2PushLimit     = errors.New("http2: too many concurrent pushes")
+)
+
+// Pusher is the interface implemented by ResponseWriters that support
+// HTTP/2 server push. For more background, see
+// https://tools.ietf.org/html/rfc7540#section-8.2.
+type Pusher interface {
+	// Push initiates an HTTP/2 server push. This constructs a
+	// synthetic request using the given target and options,
+	// serializes that request into a PUSH_PROMISE frame, then
+	// dispatches that request using the server's request handler.
+	// If opts is nil, default options are used.
+	//
+	// The target must either be an absolute path (like "/path") or
+	// an absolute URL that contains a valid host and the same
+	// scheme as the parent request. If the target is a path, it
+	// will inherit the scheme and host of the parent request.
+	//
+	// Push must be called before the handler returns, and before
+	// writing any response body that refers to the pushed
+	// resource, so that the client does not request it itself.
+	//
+	// Push returns ErrNotSupported if the client has disabled push
+	// or if push is not supported on the underlying connection.
+	Push(target string, opts *PushOptions) error
+}
+
+// PushOptions describes options for Pusher.Push.
+type PushOptions struct {
+	// Method specifies the HTTP method for the promised request.
+	// If set, it must be "GET" or "HEAD". Empty means "GET".
+	Method string
+
+	// Header specifies additional promised request headers. This
+	// cannot include HTTP/2 pseudo header fields like ":path" and
+	// ":scheme", which will be added automatically.
+	Header Header
+}
+
+// setupHTTP2 enables HTTP/2 for TLS connections to srv that
+// negotiate "h2", unless the user has set srv.TLSNextProto.
+func (srv *Server) setupHTTP2() {
+	srv.nextProtoOnce.Do(srv.onceSetNextProtoDefaults)
+}
+
+func (srv *Server) onceSetNextProtoDefaults() {
+	if srv.TLSNextProto != nil {
+		return
+	}
+	s := &h2Server{conns: make(map[*h2serverConn]bool)}
+	srv.TLSNextProto = map[string]func(*Server, *tls.Conn, Handler){
+		http2.NextProtoTLS: s.serveConn,
+	}
+	srv.mu.Lock()
+	srv.onShutdown = append(srv.onShutdown, s.shutdown)
+	srv.mu.Unlock()
+}
+
+// h2Server tracks the HTTP/2 connections of one Server, so that
+// Shutdown can ask them to go away.
+type h2Server struct {
+	mu           sync.Mutex
+	conns        map[*h2serverConn]bool
+	shuttingDown bool
+}
+
+func (s *h2Server) shutdown() {
+	s.mu.Lock()
+	s.shuttingDown = true
+	conns := make([]*h2serverConn, 0, len(s.conns))
+	for sc := range s.conns {
+		conns = append(conns, sc)
+	}
+	s.mu.Unlock()
+	for _, sc := range conns {
+		sc.goAway()
+	}
+}
+
+// track adds or removes sc from the set of live connections. It
+// reports whether the server is shutting down.
+func (s *h2Server) track(sc *h2serverConn, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if add {
+		s.conns[sc] = true
+	} else {
+		delete(s.conns, sc)
+	}
+	return s.shuttingDown
+}
+
+// serveConn serves HTTP/2 on c. It is installed as the "h2" entry
+// of Server.TLSNextProto.
+func (s *h2Server) serveConn(srv *Server, c *tls.Conn, h Handler) {
+	sc := &h2serverConn{
+		srv:               srv,
+		hs:                s,
+		conn:              c,
+		handler:           h,
+		remoteAddr:        c.RemoteAddr().String(),
+		bw:                bufio.NewWriter(c),
+		henc:              newH2Encoder(),
+		hdec:              hpack.NewDecoder(http2.InitialHeaderTableSize),
+		streams:           make(map[uint32]*h2serverStream),
+		nextPushID:        2,
+		sendWindow:        http2.InitialWindowSize,
+		initialSendWindow: http2.InitialWindowSize,
+		peerMaxFrameSize:  http2.DefaultMaxFrameSize,
+		peerMaxStreams:    h2ServerMaxConcurrentStreams,
+		pushEnabled:       true,
+		recvWindow:        h2ServerConnWindowSize,
+	}
+	sc.cond.L = &sc.mu
+	if npn, ok := h.(initNPNRequest); ok {
+		sc.hc = npn.hc
+	}
+	state := c.ConnectionState()
+	sc.tlsState = &state
+	sc.br = bufio.NewReader(c)
+	sc.framer = http2.NewFramer(sc.bw, sc.br)
+	sc.framer.SetMaxReadFrameSize(h2ServerMaxReadFrameSize)
+	sc.hdec.SetMaxStringLength(srv.maxHeaderBytes())
+	sc.serve()
+}
+
+// h2serverConn is the server side of an HTTP/2 connection.
+//
+// A single goroutine (serve) reads frames; each stream's handler
+// runs in its own goroutine. Frames are written by whichever
+// goroutine needs to, holding wmu. Lock ordering: wmu before mu.
+type h2serverConn struct {
+	srv        *Server
+	hs         *h2Server
+	hc         *conn // HTTP/1 conn record, for ConnState; may be nil
+	conn       *tls.Conn
+	handler    Handler
+	tlsState   *tls.ConnectionState
+	remoteAddr string
+	br         *bufio.Reader
+	framer     *http2.Framer
+	hdec       *hpack.Decoder
+
+	// Used only by the serve goroutine, to assemble header blocks
+	// split over CONTINUATION frames.
+	sawSettings  bool
+	hdrStreamID  uint32
+	hdrEndStream bool
+	hdrBlock     []byte
+
+	wmu  sync.Mutex // guards writing frames; see writeFrame
+	bw   *bufio.Writer
+	henc *h2encoder
+	werr error // sticky write error
+
+	mu                sync.Mutex
+	cond              sync.Cond // c.L == &mu; signaled on window and stream state changes
+	streams           map[uint32]*h2serverStream
+	maxClientStreamID uint32
+	nextPushID        uint32
+	curClientStreams  int
+	curPushStreams    int
+	sendWindow        h2flow // connection send window
+	initialSendWindow int32  // peer's SETTINGS_INITIAL_WINDOW_SIZE
+	peerMaxFrameSize  uint32
+	peerMaxStreams    uint32 // limits our pushes
+	pushEnabled       bool
+	recvWindow        int32 // connection receive window left for the peer
+	recvUnacked       int32 // connection credit not yet returned by WINDOW_UPDATE
+	goAwaySent        bool
+	closed            bool
+
+	stateMu     sync.Mutex // guards active and stateClosed, and orders ConnState changes
+	active      int        // number of open streams
+	stateClosed bool
+}
+
+// h2serverStream is one stream of an h2serverConn. Unless noted, its
+// fields are guarded by sc.mu.
+type h2serverStream struct {
+	sc     *h2serverConn
+	id     uint32
+	pushed bool // a server push stream; immutable
+
+	body          *h2pipe // request body; nil if none (immutable)
+	trailer       Header  // request trailer to fill in; immutable map, values set by serve goroutine
+	declBodyBytes int64   // Content-Length of request, or -1 (immutable)
+	bodyBytes     int64   // request body bytes received
+	sendWindow    h2flow
+	recvWindow    int32
+	recvUnacked   int32
+	sentHeaders   bool  // final response HEADERS sent
+	endRecv       bool  // client sent END_STREAM
+	endSent       bool  // server sent END_STREAM
+	resetErr      error // non-nil once the stream is reset or the conn closed
+
+	closeNotify chan bool // buffered; signaled on reset
+
+	ctx       context.Context // the request's context
+	cancelCtx context.CancelFunc
+}
+
+func (sc *h2serverConn) newStream(id uint32, endRecv bool) *h2serverStream {
+	st := &h2serverStream{
+		sc:            sc,
+		id:            id,
+		declBodyBytes: -1,
+		sendWindow:    h2flow(sc.initialSendWindow),
+		recvWindow:    h2ServerInitialWindowSize,
+		endRecv:       endRecv,
+		closeNotify:   make(chan bool, 1),
+	}
+	st.ctx, st.cancelCtx = context.WithCancel(context.Background())
+	return st
+}
+
+func (sc *h2serverConn) serve() {
+	defer sc.close()
+
+	// The server connection preface is a SETTINGS frame, followed
+	// by enlarging the connection's receive window.
+	err := sc.writeFrame(func(fr *http2.Framer) error {
+		err := fr.WriteSettings(
+			http2.Setting{http2.SettingMaxFrameSize, h2ServerMaxReadFrameSize},
+			http2.Setting{http2.SettingMaxConcurrentStreams, h2ServerMaxConcurrentStreams},
+			http2.Setting{http2.SettingInitialWindowSize, h2ServerInitialWindowSize},
+			http2.Setting{http2.SettingMaxHeaderListSize, uint32(sc.srv.maxHeaderBytes())},
+		)
+		if err != nil {
+			return err
+		}
+		return fr.WriteWindowUpdate(0, h2ServerConnWindowSize-http2.InitialWindowSize)
+	})
+	if err != nil {
+		return
+	}
+	if sc.hs.track(sc, true) {
+		sc.goAway()
+	}
+	defer sc.hs.track(sc, false)
+
+	if err := sc.readPreface(); err != nil {
+		if err != io.EOF {
+			sc.srv.logf("http2: error reading preface from client %s: %v", sc.remoteAddr, err)
+		}
+		return
+	}
+	// The deadlines set before the TLS handshake were for
+	// reading one HTTP/1 request; an HTTP/2 connection is
+	// long-lived.
+	sc.conn.SetReadDeadline(time.Time{})
+	sc.conn.SetWriteDeadline(time.Time{})
+
+	for {
+		f, err := sc.framer.ReadFrame()
+		if err == nil {
+			err = sc.processFrame(f)
+		}
+		if err == nil {
+			continue
+		}
+		if err == http2.ErrFrameTooLarge {
+			err = http2.ConnectionError(http2.ErrCodeFrameSize)
+		}
+		switch ev := err.(type) {
+		case http2.StreamError:
+			sc.resetStream(ev.StreamID, ev.Code)
+			continue
+		case http2.ConnectionError:
+			sc.srv.logf("http2: closing connection from %s: %v", sc.remoteAddr, ev)
+			sc.writeFrame(func(fr *http2.Framer) error {
+				sc.mu.Lock()
+				last := sc.maxClientStreamID
+				sc.mu.Unlock()
+				return fr.WriteGoAway(last, http2.ErrCode(ev), nil)
+			})
+		}
+		return
+	}
+}
+
+func (sc *h2serverConn) readPreface() error {
+	sc.conn.SetReadDeadline(time.Now().Add(h2PrefaceTimeout))
+	buf := make([]byte, len(http2.ClientPreface))
+	if _, err := io.ReadFull(sc.br, buf); err != nil {
+		return err
+	}
+	if string(buf) != http2.ClientPreface {
+		return fmt.Errorf("bogus greeting %q", buf)
+	}
+	return nil
+}
+
+// close tears down the connection once the serve loop has exited.
+// Running handlers see their streams reset.
+func (sc *h2serverConn) close() {
+	sc.mu.Lock()
+	sc.closed = true
+	for _, st := range sc.streams {
+		sc.resetLocked(st, errH2ConnClosed)
+	}
+	sc.cond.Broadcast()
+	sc.mu.Unlock()
+
+	sc.stateMu.Lock()
+	sc.stateClosed = true
+	sc.stateMu.Unlock()
+	sc.conn.Close()
+}
+
+// goAway starts a graceful shutdown of the connection: the client is
+// told not to open new streams, and the connection is closed once
+// the streams already open have finished.
+func (sc *h2serverConn) goAway() {
+	sc.mu.Lock()
+	if sc.goAwaySent || sc.closed {
+		sc.mu.Unlock()
+		return
+	}
+	sc.goAwaySent = true
+	last := sc.maxClientStreamID
+	idle := len(sc.streams) == 0
+	sc.mu.Unlock()
+	sc.writeFrame(func(fr *http2.Framer) error {
+		return fr.WriteGoAway(last, http2.ErrCodeNo, nil)
+	})
+	if idle {
+		sc.conn.Close()
+	}
+}
+
+// writeFrame calls fn to write frames and flushes them to the
+// connection. A write error closes the connection.
+func (sc *h2serverConn) writeFrame(fn func(*http2.Framer) error) error {
+	sc.wmu.Lock()
+	defer sc.wmu.Unlock()
+	return sc.writeFrameLocked(fn)
+}
+
+func (sc *h2serverConn) writeFrameLocked(fn func(*http2.Framer) error) error {
+	if sc.werr != nil {
+		return sc.werr
+	}
+	err := fn(sc.framer)
+	if err == nil {
+		err = sc.bw.Flush()
+	}
+	if err != nil {
+		sc.werr = err
+		sc.conn.Close()
+	}
+	return err
+}
+
+// isIdle reports whether id names a stream that has not been opened
+// yet. sc.mu must be held.
+func (sc *h2serverConn) isIdleLocked(id uint32) bool {
+	if id%2 == 1 {
+		return id > sc.maxClientStreamID
+	}
+	return id >= sc.nextPushID
+}
+
+func (sc *h2serverConn) processFrame(f http2.Frame) error {
+	// The first frame from the client must be SETTINGS.
+	if !sc.sawSettings {
+		if _, ok := f.(*http2.SettingsFrame); !ok {
+			return http2.ConnectionError(http2.ErrCodeProtocol)
+		}
+		sc.sawSettings = true
+	}
+	switch f := f.(type) {
+	case *http2.SettingsFrame:
+		return sc.processSettings(f)
+	case *http2.HeadersFrame:
+		sc.hdrStreamID = f.StreamID
+		sc.hdrEndStream = f.StreamEnded()
+		sc.hdrBlock = append(sc.hdrBlock[:0], f.HeaderBlockFragment()...)
+		if f.HeadersEnded() {
+			return sc.processHeaderBlock()
+		}
+	case *http2.ContinuationFrame:
+		sc.hdrBlock = append(sc.hdrBlock, f.HeaderBlockFragment()...)
+		if len(sc.hdrBlock) > 2*sc.srv.maxHeaderBytes() {
+			return http2.ConnectionError(http2.ErrCodeEnhanceYourCalm)
+		}
+		if f.HeadersEnded() {
+			return sc.processHeaderBlock()
+		}
+	case *http2.DataFrame:
+		return sc.processData(f)
+	case *http2.WindowUpdateFrame:
+		return sc.processWindowUpdate(f)
+	case *http2.RSTStreamFrame:
+		sc.mu.Lock()
+		defer sc.mu.Unlock()
+		st := sc.streams[f.StreamID]
+		if st == nil {
+			if sc.isIdleLocked(f.StreamID) {
+				return http2.ConnectionError(http2.ErrCodeProtocol)
+			}
+			return nil
+		}
+		sc.resetLocked(st, errH2ClientReset)
+	case *http2.PingFrame:
+		if f.IsAck() {
+			return nil
+		}
+		data := f.Data
+		return sc.writeFrame(func(fr *http2.Framer) error {
+			return fr.WritePing(true, data)
+		})
+	case *http2.GoAwayFrame:
+		// The client is going away; finish what it asked for
+		// and stop.
+		sc.goAway()
+	case *http2.PushPromiseFrame:
+		// Clients must not push.
+		return http2.ConnectionError(http2.ErrCodeProtocol)
+	}
+	// PRIORITY and unknown frames are ignored.
+	return nil
+}
+
+func (sc *h2serverConn) processSettings(f *http2.SettingsFrame) error {
+	if f.IsAck() {
+		return nil
+	}
+	var tableSize uint32
+	haveTableSize := false
+	sc.mu.Lock()
+	err := f.ForeachSetting(func(s http2.Setting) error {
+		switch s.ID {
+		case http2.SettingHeaderTableSize:
+			tableSize, haveTableSize = s.Val, true
+		case http2.SettingEnablePush:
+			sc.pushEnabled = s.Val != 0
+		case http2.SettingMaxConcurrentStreams:
+			sc.peerMaxStreams = s.Val
+		case http2.SettingInitialWindowSize:
+			// Section 6.9.2: the change applies to the
+			// windows of all open streams.
+			delta := int32(s.Val) - sc.initialSendWindow
+			sc.initialSendWindow = int32(s.Val)
+			for _, st := range sc.streams {
+				if !st.sendWindow.add(delta) {
+					return http2.ConnectionError(http2.ErrCodeFlowControl)
+				}
+			}
+		case http2.SettingMaxFrameSize:
+			sc.peerMaxFrameSize = s.Val
+		}
+		return nil
+	})
+	sc.cond.Broadcast()
+	sc.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return sc.writeFrame(func(fr *http2.Framer) error {
+		if haveTableSize {
+			sc.henc.enc.SetMaxDynamicTableSizeLimit(tableSize)
+		}
+		return fr.WriteSettingsAck()
+	})
+}
+
+func (sc *h2serverConn) processWindowUpdate(f *http2.WindowUpdateFrame) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	defer sc.cond.Broadcast()
+	if f.StreamID == 0 {
+		if !sc.sendWindow.add(int32(f.Increment)) {
+			return http2.ConnectionError(http2.ErrCodeFlowControl)
+		}
+		return nil
+	}
+	st := sc.streams[f.StreamID]
+	if st == nil {
+		if sc.isIdleLocked(f.StreamID) {
+			return http2.ConnectionError(http2.ErrCodeProtocol)
+		}
+		return nil
+	}
+	if !st.sendWindow.add(int32(f.Increment)) {
+		return http2.StreamError{f.StreamID, http2.ErrCodeFlowControl}
+	}
+	return nil
+}
+
+func (sc *h2serverConn) processHeaderBlock() error {
+	id, endStream := sc.hdrStreamID, sc.hdrEndStream
+	// The block must be decoded even if the stream is then
+	// refused, to keep the decoder's table in sync with the
+	// client's.
+	fields, err := sc.hdec.DecodeFull(sc.hdrBlock)
+	if err != nil {
+		return http2.ConnectionError(http2.ErrCodeCompression)
+	}
+	if id%2 != 1 {
+		return http2.ConnectionError(http2.ErrCodeProtocol)
+	}
+
+	sc.mu.Lock()
+	st := sc.streams[id]
+	if st != nil {
+		sc.mu.Unlock()
+		return sc.processTrailers(st, fields, endStream)
+	}
+	if id <= sc.maxClientStreamID {
+		// A stream we already closed or refused.
+		sc.mu.Unlock()
+		return nil
+	}
+	sc.maxClientStreamID = id
+	refuse := sc.goAwaySent || sc.curClientStreams >= h2ServerMaxConcurrentStreams
+	st = sc.newStream(id, endStream)
+	sc.mu.Unlock()
+	if refuse {
+		return http2.StreamError{id, http2.ErrCodeRefusedStream}
+	}
+
+	var size uint32
+	for _, f := range fields {
+		size += f.Size()
+	}
+	if size > uint32(sc.srv.maxHeaderBytes()) {
+		return sc.writeErrorResponse(id, endStream, statusRequestHeaderFieldsTooLarge)
+	}
+	req, err := sc.newRequest(st, fields)
+	if err != nil {
+		return http2.StreamError{id, http2.ErrCodeProtocol}
+	}
+
+	sc.mu.Lock()
+	sc.streams[id] = st
+	sc.curClientStreams++
+	sc.mu.Unlock()
+	sc.streamOpened()
+	go sc.runHandler(newH2ResponseWriter(st, req), req)
+	return nil
+}
+
+// writeErrorResponse replies to a request that never reaches a
+// handler with a bodiless response carrying status code.
+func (sc *h2serverConn) writeErrorResponse(id uint32, endStream bool, code int) error {
+	fields := []hpack.HeaderField{{Name: ":status", Value: strconv.Itoa(code)}}
+	return sc.writeFrame(func(fr *http2.Framer) error {
+		sc.mu.Lock()
+		maxFrameSize := sc.peerMaxFrameSize
+		sc.mu.Unlock()
+		if err := sc.henc.writeHeaders(fr, id, true, maxFrameSize, fields); err != nil {
+			return err
+		}
+		if !endStream {
+			// Tell the client to stop sending its body.
+			return fr.WriteRSTStream(id, http2.ErrCodeNo)
+		}
+		return nil
+	})
+}
+
+// newRequest builds the Request for a new stream from its decoded
+// header fields, validating them per section 8.1.2.
+func (sc *h2serverConn) newRequest(st *h2serverStream, fields []hpack.HeaderField) (*Request, error) {
+	errMalformed := errors.New("malformed request")
+	var method, scheme, authority, path string
+	header := make(Header)
+	var cookies []string
+	sawRegular := false
+	for _, f := range fields {
+		if strings.HasPrefix(f.Name, ":") {
+			// Pseudo-header fields must precede regular
+			// ones, and may appear only once.
+			if sawRegular {
+				return nil, errMalformed
+			}
+			var p *string
+			switch f.Name {
+			case ":method":
+				p = &method
+			case ":scheme":
+				p = &scheme
+			case ":authority":
+				p = &authority
+			case ":path":
+				p = &path
+			default:
+				return nil, errMalformed
+			}
+			if *p != "" {
+				return nil, errMalformed
+			}
+			*p = f.Value
+			continue
+		}
+		sawRegular = true
+		if !validH2HeaderName(f.Name) || h2ConnectionHeaders[f.Name] {
+			return nil, errMalformed
+		}
+		if f.Name == "te" && f.Value != "trailers" {
+			return nil, errMalformed
+		}
+		if f.Name == "cookie" {
+			// Section 8.1.2.5: cookies may be split into
+			// several fields, and are rejoined for HTTP/1.
+			cookies = append(cookies, f.Value)
+			continue
+		}
+		key := CanonicalHeaderKey(f.Name)
+		header[key] = append(header[key], f.Value)
+	}
+	if len(cookies) > 0 {
+		header.Set("Cookie", strings.Join(cookies, "; "))
+	}
+
+	var u *url.URL
+	if method == "CONNECT" {
+		if scheme != "" || path != "" || authority == "" {
+			return nil, errMalformed
+		}
+		u = &url.URL{Host: authority}
+	} else {
+		if method == "" || path == "" || (scheme != "https" && scheme != "http") {
+			return nil, errMalformed
+		}
+		var err error
+		if u, err = url.ParseRequestURI(path); err != nil {
+			return nil, errMalformed
+		}
+	}
+	if authority == "" {
+		authority = header.get("Host")
+	}
+	header.Del("Host")
+
+	req := &Request{
+		Method:        method,
+		URL:           u,
+		Proto:         "HTTP/2.0",
+		ProtoMajor:    2,
+		ProtoMinor:    0,
+		Header:        header,
+		Host:          authority,
+		RemoteAddr:    sc.remoteAddr,
+		RequestURI:    path,
+		TLS:           sc.tlsState,
+		ContentLength: -1,
+		ctx:           st.ctx,
+	}
+	if method == "CONNECT" {
+		req.RequestURI = authority
+	}
+
+	if cl := header.get("Content-Length"); cl != "" {
+		n, err := strconv.ParseUint(cl, 10, 63)
+		if err != nil {
+			return nil, errMalformed
+		}
+		req.ContentLength = int64(n)
+	}
+	if st.endRecv {
+		if req.ContentLength > 0 {
+			return nil, errMalformed
+		}
+		req.ContentLength = 0
+		req.Body = eofReader
+		return req, nil
+	}
+
+	if keys := h2TrailerKeys(header); len(keys) > 0 {
+		req.Trailer = make(Header)
+		for _, k := range keys {
+			req.Trailer[k] = nil
+		}
+		st.trailer = req.Trailer
+	}
+	header.Del("Trailer")
+
+	st.declBodyBytes = req.ContentLength
+	st.body = newH2Pipe(func(n int) { sc.returnCredit(st, int32(n)) })
+	req.Body = &h2requestBody{
+		st:            st,
+		needsContinue: hasToken(header.get("Expect"), "100-continue"),
+	}
+	return req, nil
+}
+
+func (sc *h2serverConn) processTrailers(st *h2serverStream, fields []hpack.HeaderField, endStream bool) error {
+	if !endStream {
+		return http2.StreamError{st.id, http2.ErrCodeProtocol}
+	}
+	sc.mu.Lock()
+	done := st.endRecv || st.resetErr != nil
+	sc.mu.Unlock()
+	if done {
+		return nil
+	}
+	for _, f := range fields {
+		if strings.HasPrefix(f.Name, ":") || !validH2HeaderName(f.Name) {
+			return http2.StreamError{st.id, http2.ErrCodeProtocol}
+		}
+		key := CanonicalHeaderKey(f.Name)
+		if _, ok := st.trailer[key]; ok {
+			st.trailer[key] = append(st.trailer[key], f.Value)
+		}
+	}
+	return sc.endRecv(st)
+}
+
+// endRecv records that the client has finished sending st's
+// request body.
+func (sc *h2serverConn) endRecv(st *h2serverStream) error {
+	sc.mu.Lock()
+	st.endRecv = true
+	short := st.declBodyBytes != -1 && st.bodyBytes != st.declBodyBytes
+	sc.mu.Unlock()
+	if short {
+		return http2.StreamError{st.id, http2.ErrCodeProtocol}
+	}
+	if st.body != nil {
+		st.body.closeWithError(io.EOF)
+	}
+	return nil
+}
+
+func (sc *h2serverConn) processData(f *http2.DataFrame) error {
+	id := f.StreamID
+	data := f.Data()
+	n := int32(f.Length) // padding counts against flow control too
+
+	sc.mu.Lock()
+	if n > sc.recvWindow {
+		sc.mu.Unlock()
+		return http2.ConnectionError(http2.ErrCodeFlowControl)
+	}
+	sc.recvWindow -= n
+	st := sc.streams[id]
+	if st == nil || st.body == nil || st.endRecv || st.resetErr != nil {
+		idle := sc.isIdleLocked(id)
+		sc.mu.Unlock()
+		if idle {
+			return http2.ConnectionError(http2.ErrCodeProtocol)
+		}
+		// Nobody will read this; give the credit back.
+		sc.returnCredit(nil, n)
+		if st != nil && st.resetErr == nil {
+			return http2.StreamError{id, http2.ErrCodeStreamClosed}
+		}
+		return nil
+	}
+	if n > st.recvWindow {
+		sc.mu.Unlock()
+		sc.returnCredit(nil, n)
+		return http2.StreamError{id, http2.ErrCodeFlowControl}
+	}
+	st.recvWindow -= n
+	st.bodyBytes += int64(len(data))
+	tooLong := st.declBodyBytes != -1 && st.bodyBytes > st.declBodyBytes
+	sc.mu.Unlock()
+	if tooLong {
+		sc.returnCredit(nil, n)
+		return http2.StreamError{id, http2.ErrCodeProtocol}
+	}
+
+	if pad := n - int32(len(data)); pad > 0 {
+		sc.returnCredit(st, pad)
+	}
+	if len(data) > 0 {
+		if _, err := st.body.Write(data); err != nil {
+			// The handler closed the body.
+			sc.returnCredit(nil, int32(len(data)))
+		}
+	}
+	if f.StreamEnded() {
+		return sc.endRecv(st)
+	}
+	return nil
+}
+
+// returnCredit records that n bytes of received DATA have been
+// consumed, sending WINDOW_UPDATE frames for the connection and, if
+// st is non-nil, the stream once enough credit has accumulated.
+func (sc *h2serverConn) returnCredit(st *h2serverStream, n int32) {
+	if n <= 0 {
+		return
+	}
+	var connIncr, streamIncr int32
+	sc.mu.Lock()
+	sc.recvUnacked += n
+	if sc.recvUnacked >= h2ServerConnWindowSize/2 {
+		connIncr = sc.recvUnacked
+		sc.recvWindow += connIncr
+		sc.recvUnacked = 0
+	}
+	if st != nil && !st.endRecv && st.resetErr == nil {
+		st.recvUnacked += n
+		if st.recvUnacked >= h2ServerInitialWindowSize/2 {
+			streamIncr = st.recvUnacked
+			st.recvWindow += streamIncr
+			st.recvUnacked = 0
+		}
+	}
+	sc.mu.Unlock()
+	if connIncr == 0 && streamIncr == 0 {
+		return
+	}
+	sc.writeFrame(func(fr *http2.Framer) error {
+		if connIncr > 0 {
+			if err := fr.WriteWindowUpdate(0, uint32(connIncr)); err != nil {
+				return err
+			}
+		}
+		if streamIncr > 0 {
+			return fr.WriteWindowUpdate(st.id, uint32(streamIncr))
+		}
+		return nil
+	})
+}
+
+// resetStream sends RST_STREAM for the stream id, which the server
+// found in error.
+func (sc *h2serverConn) resetStream(id uint32, code http2.ErrCode) {
+	sc.mu.Lock()
+	if st := sc.streams[id]; st != nil {
+		sc.resetLocked(st, http2.StreamError{id, code})
+	}
+	sc.mu.Unlock()
+	sc.writeFrame(func(fr *http2.Framer) error {
+		return fr.WriteRSTStream(id, code)
+	})
+}
+
+// resetLocked marks st as reset with err, waking anybody blocked on
+// it. sc.mu must be held.
+func (sc *h2serverConn) resetLocked(st *h2serverStream, err error) {
+	if st.resetErr != nil {
+		return
+	}
+	st.resetErr = err
+	if st.body != nil {
+		// Discarded body bytes are returned to the connection
+		// window by the next WINDOW_UPDATE.
+		sc.recvUnacked += int32(st.body.breakWithError(err))
+	}
+	select {
+	case st.closeNotify <- true:
+	default:
+	}
+	st.cancelCtx()
+	sc.cond.Broadcast()
+}
+
+func (sc *h2serverConn) runHandler(rw *h2responseWriter, req *Request) {
+	defer sc.streamDone(rw.st)
+	defer func() {
+		if err := recover(); err != nil {
+			const size = 64 << 10
+			buf := make([]byte, size)
+			buf = buf[:runtime.Stack(buf, false)]
+			sc.srv.logf("http: panic serving %v: %v\n%s", sc.remoteAddr, err, buf)
+			sc.resetStream(rw.st.id, http2.ErrCodeInternal)
+		}
+	}()
+	sc.handler.ServeHTTP(rw, req)
+	rw.finish()
+}
+
+// streamDone is called when st's handler has returned.
+func (sc *h2serverConn) streamDone(st *h2serverStream) {
+	sc.mu.Lock()
+	delete(sc.streams, st.id)
+	if st.pushed {
+		sc.curPushStreams--
+	} else {
+		sc.curClientStreams--
+	}
+	// If the client is still sending a body nobody will read,
+	// ask it to stop (section 8.1).
+	sendRST := !st.endRecv && st.resetErr == nil
+	sc.resetLocked(st, errH2StreamClosed)
+	closeConn := sc.goAwaySent && len(sc.streams) == 0
+	sc.mu.Unlock()
+
+	if sendRST {
+		sc.writeFrame(func(fr *http2.Framer) error {
+			return fr.WriteRSTStream(st.id, http2.ErrCodeNo)
+		})
+	}
+	sc.streamClosed()
+	if closeConn {
+		sc.conn.Close()
+	}
+}
+
+// streamOpened and streamClosed maintain the connection's ConnState:
+// it is active while any stream is open and idle otherwise.
+func (sc *h2serverConn) streamOpened() {
+	sc.stateMu.Lock()
+	defer sc.stateMu.Unlock()
+	sc.active++
+	if sc.active == 1 && !sc.stateClosed {
+		sc.setConnState(StateActive)
+	}
+}
+
+func (sc *h2serverConn) streamClosed() {
+	sc.stateMu.Lock()
+	defer sc.stateMu.Unlock()
+	sc.active--
+	if sc.active == 0 && !sc.stateClosed {
+		sc.setConnState(StateIdle)
+	}
+}
+
+func (sc *h2serverConn) setConnState(state ConnState) {
+	if sc.hc != nil {
+		sc.hc.setState(sc.conn, state)
+	} else if hook := sc.srv.ConnState; hook != nil {
+		hook(sc.conn, state)
+	}
+}
+
+// writeHeaders writes a header block for st.
+func (sc *h2serverConn) writeHeaders(st *h2serverStream, endStream bool, fields []hpack.HeaderField) error {
+	return sc.writeFrame(func(fr *http2.Framer) error {
+		sc.mu.Lock()
+		err := st.resetErr
+		maxFrameSize := sc.peerMaxFrameSize
+		if err == nil {
+			st.sentHeaders = true
+			st.endSent = endStream
+		}
+		sc.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		return sc.henc.writeHeaders(fr, st.id, endStream, maxFrameSize, fields)
+	})
+}
+
+// writeData writes p as DATA frames for st, waiting for flow-control
+// window as needed.
+func (sc *h2serverConn) writeData(st *h2serverStream, p []byte, endStream bool) error {
+	for {
+		n, err := sc.awaitSendWindow(st, len(p))
+		if err != nil {
+			return err
+		}
+		chunk := p[:n]
+		p = p[n:]
+		end := endStream && len(p) == 0
+		err = sc.writeFrame(func(fr *http2.Framer) error {
+			return fr.WriteData(st.id, end, chunk)
+		})
+		if err != nil {
+			return err
+		}
+		if len(p) == 0 {
+			if end {
+				sc.mu.Lock()
+				st.endSent = true
+				sc.mu.Unlock()
+			}
+			return nil
+		}
+	}
+}
+
+// awaitSendWindow waits until st may send some of want bytes and
+// takes up to that many from the connection and stream windows.
+func (sc *h2serverConn) awaitSendWindow(st *h2serverStream, want int) (int, error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for {
+		if st.resetErr != nil {
+			return 0, st.resetErr
+		}
+		if want == 0 {
+			return 0, nil
+		}
+		n := int32(sc.peerMaxFrameSize)
+		if int(n) > want {
+			n = int32(want)
+		}
+		if w := int32(st.sendWindow); w < n {
+			n = w
+		}
+		if w := int32(sc.sendWindow); w < n {
+			n = w
+		}
+		if n > 0 {
+			st.sendWindow -= h2flow(n)
+			sc.sendWindow -= h2flow(n)
+			return int(n), nil
+		}
+		sc.cond.Wait()
+	}
+}
+
+// writeContinue sends a "100 Continue" response on st, unless the
+// final response headers have already gone out.
+func (sc *h2serverConn) writeContinue(st *h2serverStream) {
+	fields := []hpack.HeaderField{{Name: ":status", Value: "100"}}
+	sc.writeFrame(func(fr *http2.Framer) error {
+		sc.mu.Lock()
+		skip := st.sentHeaders || st.resetErr != nil
+		maxFrameSize := sc.peerMaxFrameSize
+		sc.mu.Unlock()
+		if skip {
+			return nil
+		}
+		return sc.henc.writeHeaders(fr, st.id, false, maxFrameSize, fields)
+	})
+}
+
+// h2requestBody is the Request.Body of an HTTP/2 request.
+type h2requestBody struct {
+	st            *h2serverStream
+	needsContinue bool
+	closed        bool
+}
+
+func (b *h2requestBody) Read(p []byte) (int, error) {
+	if b.closed {
+		return 0, ErrBodyReadAfterClose
+	}
+	if b.needsContinue {
+		b.needsContinue = false
+		b.st.sc.writeContinue(b.st)
+	}
+	return b.st.body.Read(p)
+}
+
+func (b *h2requestBody) Close() error {
+	if !b.closed {
+		b.closed = true
+		n := b.st.body.breakWithError(ErrBodyReadAfterClose)
+		b.st.sc.returnCredit(nil, int32(n))
+	}
+	return nil
+}
+
+// h2responseWriter is the ResponseWriter of an HTTP/2 stream.
+type h2responseWriter struct {
+	st  *h2serverStream
+	req *Request
+
+	handlerHeader Header
+	header        Header // handlerHeader as of WriteHeader
+	status        int
+	wroteHeader   bool  // WriteHeader called
+	sentHeader    bool  // response HEADERS written
+	contentLength int64 // from the Content-Length header, or -1
+	written       int64 // body bytes written by the handler
+	trailers      []string
+	buf           []byte // body not yet sent
+}
+
+func newH2ResponseWriter(st *h2serverStream, req *Request) *h2responseWriter {
+	return &h2responseWriter{
+		st:            st,
+		req:           req,
+		handlerHeader: make(Header),
+		contentLength: -1,
+		buf:           make([]byte, 0, h2ResponseBufferSize),
+	}
+}
+
+func (rw *h2responseWriter) Header() Header {
+	return rw.handlerHeader
+}
+
+func (rw *h2responseWriter) WriteHeader(code int) {
+	if rw.wroteHeader {
+		rw.st.sc.srv.logf("http: multiple response.WriteHeader calls")
+		return
+	}
+	rw.wroteHeader = true
+	rw.status = code
+	rw.header = rw.handlerHeader.clone()
+	if cl := rw.header.get("Content-Length"); cl != "" {
+		v, err := strconv.ParseInt(cl, 10, 64)
+		if err == nil && v >= 0 {
+			rw.contentLength = v
+		} else {
+			rw.st.sc.srv.logf("http: invalid Content-Length of %q", cl)
+			rw.header.Del("Content-Length")
+		}
+	}
+	rw.trailers = h2TrailerKeys(rw.header)
+}
+
+func (rw *h2responseWriter) Write(p []byte) (n int, err error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(StatusOK)
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if !bodyAllowedForStatus(rw.status) {
+		return 0, ErrBodyNotAllowed
+	}
+	rw.written += int64(len(p))
+	if rw.contentLength != -1 && rw.written > rw.contentLength {
+		return 0, ErrContentLength
+	}
+	if len(rw.buf)+len(p) > cap(rw.buf) {
+		if len(rw.buf) > 0 {
+			if err := rw.writeChunk(rw.buf, false); err != nil {
+				return 0, err
+			}
+			rw.buf = rw.buf[:0]
+		}
+		if len(p) >= cap(rw.buf) {
+			if err := rw.writeChunk(p, false); err != nil {
+				return 0, err
+			}
+			return len(p), nil
+		}
+	}
+	rw.buf = append(rw.buf, p...)
+	return len(p), nil
+}
+
+func (rw *h2responseWriter) Flush() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(StatusOK)
+	}
+	if len(rw.buf) > 0 || !rw.sentHeader {
+		rw.writeChunk(rw.buf, false)
+		rw.buf = rw.buf[:0]
+	}
+}
+
+func (rw *h2responseWriter) CloseNotify() <-chan bool {
+	return rw.st.closeNotify
+}
+
+// finish completes the response after the handler has returned.
+func (rw *h2responseWriter) finish() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(StatusOK)
+	}
+	rw.writeChunk(rw.buf, true)
+	rw.buf = nil
+}
+
+// writeChunk sends p as the next part of the response body, first
+// sending the response headers if they have not gone out yet. If
+// final is set, p is the last of the body and the stream is ended.
+func (rw *h2responseWriter) writeChunk(p []byte, final bool) error {
+	sc := rw.st.sc
+	first := p
+	if rw.req.Method == "HEAD" || !bodyAllowedForStatus(rw.status) {
+		p = nil
+	}
+	endStream := final && len(rw.trailers) == 0
+	if !rw.sentHeader {
+		rw.sentHeader = true
+		fields := rw.headerFields(first, final)
+		if err := sc.writeHeaders(rw.st, endStream && len(p) == 0, fields); err != nil {
+			return err
+		}
+		if endStream && len(p) == 0 {
+			return nil
+		}
+	}
+	if len(p) > 0 || endStream {
+		if err := sc.writeData(rw.st, p, endStream); err != nil {
+			return err
+		}
+	}
+	if final && !endStream {
+		return rw.writeTrailers()
+	}
+	return nil
+}
+
+// headerFields returns the response header block, given the first
+// chunk of the body (sniffed for a Content-Type) and whether the
+// handler has finished.
+func (rw *h2responseWriter) headerFields(p []byte, final bool) []hpack.HeaderField {
+	h := rw.header
+	fields := []hpack.HeaderField{{Name: ":status", Value: strconv.Itoa(rw.status)}}
+	if bodyAllowedForStatus(rw.status) {
+		if _, ok := h["Content-Type"]; !ok {
+			fields = append(fields, hpack.HeaderField{Name: "content-type", Value: DetectContentType(p)})
+		}
+		// If the handler is done and wrote the whole body in
+		// one go, declare its length.
+		if final && rw.contentLength == -1 && len(rw.trailers) == 0 && (rw.req.Method != "HEAD" || rw.written > 0) {
+			fields = append(fields, hpack.HeaderField{Name: "content-length", Value: strconv.FormatInt(rw.written, 10)})
+		}
+	} else {
+		for _, k := range suppressedHeaders(rw.status) {
+			h.Del(k)
+		}
+	}
+	if _, ok := h["Date"]; !ok {
+		fields = append(fields, hpack.HeaderField{Name: "date", Value: string(appendTime(nil, time.Now()))})
+	}
+	return h2HeaderFields(fields, h, nil)
+}
+
+// writeTrailers ends the stream with the declared trailers, taking
+// their values from the handler's Header.
+func (rw *h2responseWriter) writeTrailers() error {
+	var fields []hpack.HeaderField
+	for _, k := range rw.trailers {
+		for _, v := range rw.handlerHeader[k] {
+			fields = append(fields, hpack.HeaderField{Name: strings.ToLower(k), Value: v})
+		}
+	}
+	if len(fields) == 0 {
+		return rw.st.sc.writeData(rw.st, nil, true)
+	}
+	return rw.st.sc.writeHeaders(rw.st, true, fields)
+}
+
+func (rw *h2responseWriter) Push(target string, opts *PushOptions) error {
+	st, sc := rw.st, rw.st.sc
+	if st.pushed {
+		return errH2RecursivePush
+	}
+	if opts == nil {
+		opts = new(PushOptions)
+	}
+	method := opts.Method
+	if method == "" {
+		method = "GET"
+	}
+	if method != "GET" && method != "HEAD" {
+		return fmt.Errorf("http2: method %q must be GET or HEAD", method)
+	}
+	var u *url.URL
+	var err error
+	if strings.HasPrefix(target, "/") {
+		if u, err = url.ParseRequestURI(target); err != nil {
+			return err
+		}
+		u.Scheme = "https"
+		u.Host = rw.req.Host
+	} else {
+		if u, err = url.Parse(target); err != nil {
+			return err
+		}
+		if u.Scheme != "https" {
+			return fmt.Errorf("http2: target %q must have scheme https", target)
+		}
+		if u.Host == "" {
+			return fmt.Errorf("http2: target %q must have a host", target)
+		}
+	}
+	header := make(Header)
+	for k, vv := range opts.Header {
+		if strings.HasPrefix(k, ":") {
+			return fmt.Errorf("http2: promised request headers cannot include pseudo header %q", k)
+		}
+		switch k = CanonicalHeaderKey(k); k {
+		case "Content-Length", "Content-Encoding", "Trailer", "Te", "Expect", "Host":
+			return fmt.Errorf("http2: promised request headers cannot include %q", k)
+		}
+		header[k] = append(header[k], vv...)
+	}
+	fields := []hpack.HeaderField{
+		{Name: ":method", Value: method},
+		{Name: ":scheme", Value: "https"},
+		{Name: ":authority", Value: u.Host},
+		{Name: ":path", Value: u.RequestURI()},
+	}
+	fields = h2HeaderFields(fields, header, nil)
+
+	// Promised stream IDs must be sent in increasing order, so
+	// allocate one with the write lock held.
+	sc.wmu.Lock()
+	sc.mu.Lock()
+	switch {
+	case sc.closed:
+		err = errH2ConnClosed
+	case st.resetErr != nil:
+		err = st.resetErr
+	case st.endSent:
+		err = errH2StreamClosed
+	case !sc.pushEnabled || sc.goAwaySent:
+		err = ErrNotSupported
+	case uint32(sc.curPushStreams) >= sc.peerMaxStreams:
+		err = errH2PushLimit
+	}
+	if err != nil {
+		sc.mu.Unlock()
+		sc.wmu.Unlock()
+		return err
+	}
+	pst := sc.newStream(sc.nextPushID, true)
+	pst.pushed = true
+	sc.nextPushID += 2
+	sc.streams[pst.id] = pst
+	sc.curPushStreams++
+	maxFrameSize := sc.peerMaxFrameSize
+	sc.mu.Unlock()
+	err = sc.writeFrameLocked(func(fr *http2.Framer) error {
+		return sc.henc.writePushPromise(fr, st.id, pst.id, maxFrameSize, fields)
+	})
+	sc.wmu.Unlock()
+	sc.streamOpened()
+	if err != nil {
+		sc.streamDone(pst)
+		return err
+	}
+
+	req := &Request{
+		Method:     method,
+		URL:        u,
+		Proto:      "HTTP/2.0",
+		ProtoMajor: 2,
+		Header:     header,
+		Body:       eofReader,
+		Host:       u.Host,
+		RemoteAddr: sc.remoteAddr,
+		RequestURI: u.RequestURI(),
+		TLS:        sc.tlsState,
+		ctx:        pst.ctx,
+	}
+	go sc.runHandler(newH2ResponseWriter(pst, req), req)
+	return nil
+}
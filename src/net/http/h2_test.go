@@ -0,0 +1,571 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Tests of the HTTP/2 server and Transport.
+
+package http_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	. "net/http"
+	"net/http/httptest"
+	"net/http/internal/hpack"
+	"net/http/internal/http2"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newH2Server returns a started TLS test server that offers HTTP/2.
+func newH2Server(h Handler) *httptest.Server {
+	ts := httptest.NewUnstartedServer(h)
+	ts.TLS = &tls.Config{NextProtos: []string{"h2", "http/1.1"}}
+	ts.StartTLS()
+	return ts
+}
+
+func TestH2Basic(t *testing.T) {
+	defer afterTest(t)
+	ts := newH2Server(HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.ProtoMajor != 2 || r.Proto != "HTTP/2.0" {
+			t.Errorf("request Proto = %q (%d)", r.Proto, r.ProtoMajor)
+		}
+		if r.TLS == nil || r.TLS.NegotiatedProtocol != "h2" {
+			t.Errorf("request TLS = %+v", r.TLS)
+		}
+		if r.RemoteAddr == "" {
+			t.Error("request with no RemoteAddr")
+		}
+		w.Header().Set("X-Foo", r.Header.Get("X-Foo"))
+		fmt.Fprintf(w, "<html>%s %s %s", r.Method, r.Host, r.URL.RequestURI())
+	}))
+	defer ts.Close()
+	tr := newTLSTransport(t, ts)
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+
+	for i := 0; i < 3; i++ {
+		req, _ := NewRequest("GET", ts.URL+"/path?q=1", nil)
+		req.Header.Set("X-Foo", "bar")
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.ProtoMajor != 2 {
+			t.Errorf("response Proto = %q", res.Proto)
+		}
+		want := fmt.Sprintf("<html>GET %s /path?q=1", ts.Listener.Addr())
+		if string(body) != want {
+			t.Errorf("body = %q; want %q", body, want)
+		}
+		if res.ContentLength != int64(len(want)) {
+			t.Errorf("ContentLength = %d; want %d", res.ContentLength, len(want))
+		}
+		if got := res.Header.Get("X-Foo"); got != "bar" {
+			t.Errorf("X-Foo = %q; want bar", got)
+		}
+		if got := res.Header.Get("Content-Type"); got != "text/html; charset=utf-8" {
+			t.Errorf("Content-Type = %q; want sniffed text/html", got)
+		}
+		if res.Header.Get("Date") == "" {
+			t.Error("no Date header")
+		}
+	}
+}
+
+func TestH2Disabled(t *testing.T) {
+	defer afterTest(t)
+	ts := httptest.NewUnstartedServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	ts.TLS = &tls.Config{NextProtos: []string{"h2", "http/1.1"}}
+	ts.StartTLS()
+	defer ts.Close()
+
+	// An empty, non-nil TLSNextProto turns HTTP/2 off in the
+	// Transport.
+	tr := newTLSTransport(t, ts)
+	tr.TLSNextProto = map[string]func(string, *tls.Conn) RoundTripper{}
+	defer tr.CloseIdleConnections()
+	res, err := (&Client{Transport: tr}).Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if string(body) != "HTTP/1.1" {
+		t.Errorf("got %q; want HTTP/1.1", body)
+	}
+}
+
+// Tests that concurrent requests share one connection.
+func TestH2Multiplexing(t *testing.T) {
+	defer afterTest(t)
+	const n = 10
+	var wg sync.WaitGroup
+	wg.Add(n)
+	ts := httptest.NewUnstartedServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.URL.Path != "/warmup" {
+			// Don't reply until all requests are in flight.
+			wg.Done()
+			wg.Wait()
+		}
+		io.WriteString(w, r.URL.Path)
+	}))
+	var mu sync.Mutex
+	conns := 0
+	ts.Config.ConnState = func(c net.Conn, state ConnState) {
+		if state == StateNew {
+			mu.Lock()
+			conns++
+			mu.Unlock()
+		}
+	}
+	ts.TLS = &tls.Config{NextProtos: []string{"h2"}}
+	ts.StartTLS()
+	defer ts.Close()
+	tr := newTLSTransport(t, ts)
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+
+	// Establish the connection first, so later requests share it.
+	res, err := c.Get(ts.URL + "/warmup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	errc := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			path := fmt.Sprintf("/%d", i)
+			res, err := c.Get(ts.URL + path)
+			if err != nil {
+				errc <- err
+				return
+			}
+			defer res.Body.Close()
+			body, err := ioutil.ReadAll(res.Body)
+			if err == nil && string(body) != path {
+				err = fmt.Errorf("got %q; want %q", body, path)
+			}
+			errc <- err
+		}(i)
+	}
+	for i := 0; i < n; i++ {
+		if err := <-errc; err != nil {
+			t.Error(err)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if conns != 1 {
+		t.Errorf("server saw %d connections; want 1", conns)
+	}
+}
+
+// Tests that bodies larger than the flow-control windows are
+// transferred in both directions.
+func TestH2LargeBodies(t *testing.T) {
+	defer afterTest(t)
+	ts := newH2Server(HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.ContentLength != int64(len(largeBody)) {
+			t.Errorf("request ContentLength = %d", r.ContentLength)
+		}
+		io.Copy(w, r.Body)
+	}))
+	defer ts.Close()
+	tr := newTLSTransport(t, ts)
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+
+	res, err := c.Post(ts.URL, "application/octet-stream", bytes.NewReader(largeBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(body, largeBody) {
+		t.Errorf("echoed %d bytes, differing from the %d sent", len(body), len(largeBody))
+	}
+}
+
+var largeBody = bytes.Repeat([]byte("0123456789abcdef"), 5<<20/16)
+
+func TestH2Trailers(t *testing.T) {
+	defer afterTest(t)
+	ts := newH2Server(HandlerFunc(func(w ResponseWriter, r *Request) {
+		slurp, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("reading request body: %v", err)
+		}
+		if string(slurp) != "some body" {
+			t.Errorf("request body = %q", slurp)
+		}
+		if got := r.Trailer.Get("Client-Trailer"); got != "ct" {
+			t.Errorf("request trailer = %q; want ct", got)
+		}
+		w.Header().Set("Trailer", "Server-Trailer")
+		io.WriteString(w, "response body")
+		w.Header().Set("Server-Trailer", "st")
+	}))
+	defer ts.Close()
+	tr := newTLSTransport(t, ts)
+	defer tr.CloseIdleConnections()
+
+	req, _ := NewRequest("POST", ts.URL, ioutil.NopCloser(strings.NewReader("some body")))
+	req.ContentLength = -1
+	req.Trailer = Header{"Client-Trailer": {"ct"}}
+	res, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if _, ok := res.Trailer["Server-Trailer"]; !ok {
+		t.Errorf("Response.Trailer = %v; want Server-Trailer key", res.Trailer)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "response body" {
+		t.Errorf("body = %q", body)
+	}
+	if got := res.Trailer.Get("Server-Trailer"); got != "st" {
+		t.Errorf("response trailer = %q; want st", got)
+	}
+}
+
+// Tests that closing a response body early resets the stream, which
+// the handler sees through CloseNotify.
+func TestH2CloseBodyResetsStream(t *testing.T) {
+	defer afterTest(t)
+	gone := make(chan bool, 1)
+	ts := newH2Server(HandlerFunc(func(w ResponseWriter, r *Request) {
+		cn := w.(CloseNotifier).CloseNotify()
+		buf := make([]byte, 32<<10)
+		for {
+			if _, err := w.Write(buf); err != nil {
+				break
+			}
+			select {
+			case <-cn:
+				gone <- true
+				return
+			default:
+			}
+		}
+		gone <- true
+	}))
+	defer ts.Close()
+	tr := newTLSTransport(t, ts)
+	defer tr.CloseIdleConnections()
+
+	res, err := (&Client{Transport: tr}).Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(res.Body, make([]byte, 1000)); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	select {
+	case <-gone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler didn't notice the stream was reset")
+	}
+}
+
+// Tests that canceling a request's context resets its stream, which
+// in turn cancels the handler's context.
+func TestH2ContextCancel(t *testing.T) {
+	defer afterTest(t)
+	inHandler := make(chan bool, 1)
+	handlerErr := make(chan error, 1)
+	ts := newH2Server(HandlerFunc(func(w ResponseWriter, r *Request) {
+		inHandler <- true
+		select {
+		case <-r.Context().Done():
+			handlerErr <- r.Context().Err()
+		case <-time.After(5 * time.Second):
+			handlerErr <- errors.New("handler's context not canceled")
+		}
+	}))
+	defer ts.Close()
+	tr := newTLSTransport(t, ts)
+	defer tr.CloseIdleConnections()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := NewRequest("GET", ts.URL, nil)
+	go func() {
+		<-inHandler
+		cancel()
+	}()
+	res, err := tr.RoundTrip(req.WithContext(ctx))
+	if err != context.Canceled {
+		if err == nil {
+			res.Body.Close()
+		}
+		t.Fatalf("RoundTrip = %v; want %v", err, context.Canceled)
+	}
+	if err := <-handlerErr; err != context.Canceled {
+		t.Errorf("handler's ctx.Err() = %v; want %v", err, context.Canceled)
+	}
+}
+
+func TestH2Shutdown(t *testing.T) {
+	defer afterTest(t)
+	inHandler := make(chan bool)
+	unblock := make(chan bool)
+	ts := newH2Server(HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.URL.Path == "/slow" {
+			inHandler <- true
+			<-unblock
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer ts.Close()
+	tr := newTLSTransport(t, ts)
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+
+	resc := make(chan error, 1)
+	go func() {
+		res, err := c.Get(ts.URL + "/slow")
+		if err == nil {
+			var body []byte
+			body, err = ioutil.ReadAll(res.Body)
+			res.Body.Close()
+			if err == nil && string(body) != "ok" {
+				err = fmt.Errorf("body = %q", body)
+			}
+		}
+		resc <- err
+	}()
+	<-inHandler
+
+	shutdownc := make(chan error, 1)
+	go func() { shutdownc <- ts.Config.Shutdown(5 * time.Second) }()
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case err := <-shutdownc:
+		t.Fatalf("Shutdown returned %v with a request in flight", err)
+	default:
+	}
+	close(unblock)
+
+	if err := <-resc; err != nil {
+		t.Errorf("in-flight request: %v", err)
+	}
+	if err := <-shutdownc; err != nil {
+		t.Errorf("Shutdown = %v", err)
+	}
+}
+
+// h2RawConn is a client connection speaking raw HTTP/2 frames, for
+// testing server behavior the Transport doesn't exercise.
+type h2RawConn struct {
+	t    *testing.T
+	conn *tls.Conn
+	fr   *http2.Framer
+	henc *hpack.Encoder
+	hbuf bytes.Buffer
+	hdec *hpack.Decoder
+}
+
+func newH2RawConn(t *testing.T, ts *httptest.Server, settings ...http2.Setting) *h2RawConn {
+	conn, err := tls.Dial("tcp", ts.Listener.Addr().String(), &tls.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         []string{"h2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := conn.ConnectionState().NegotiatedProtocol; p != "h2" {
+		t.Fatalf("negotiated %q; want h2", p)
+	}
+	rc := &h2RawConn{
+		t:    t,
+		conn: conn,
+		fr:   http2.NewFramer(conn, conn),
+		hdec: hpack.NewDecoder(4096),
+	}
+	rc.henc = hpack.NewEncoder(&rc.hbuf)
+	io.WriteString(conn, http2.ClientPreface)
+	rc.fr.WriteSettings(settings...)
+	return rc
+}
+
+func (rc *h2RawConn) writeHeaders(streamID uint32, endStream bool, fields ...string) {
+	rc.hbuf.Reset()
+	for i := 0; i < len(fields); i += 2 {
+		rc.henc.WriteField(hpack.HeaderField{Name: fields[i], Value: fields[i+1]})
+	}
+	err := rc.fr.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      streamID,
+		BlockFragment: rc.hbuf.Bytes(),
+		EndStream:     endStream,
+		EndHeaders:    true,
+	})
+	if err != nil {
+		rc.t.Fatal(err)
+	}
+}
+
+// readFrame reads the next frame other than SETTINGS and
+// WINDOW_UPDATE.
+func (rc *h2RawConn) readFrame() http2.Frame {
+	rc.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		f, err := rc.fr.ReadFrame()
+		if err != nil {
+			rc.t.Fatalf("ReadFrame: %v", err)
+		}
+		switch f := f.(type) {
+		case *http2.SettingsFrame:
+			if !f.IsAck() {
+				rc.fr.WriteSettingsAck()
+			}
+			continue
+		case *http2.WindowUpdateFrame:
+			continue
+		}
+		return f
+	}
+}
+
+func (rc *h2RawConn) decode(block []byte) map[string]string {
+	fields, err := rc.hdec.DecodeFull(block)
+	if err != nil {
+		rc.t.Fatal(err)
+	}
+	m := make(map[string]string)
+	for _, f := range fields {
+		m[f.Name] = f.Value
+	}
+	return m
+}
+
+func TestH2ServerPush(t *testing.T) {
+	defer afterTest(t)
+	ts := newH2Server(HandlerFunc(func(w ResponseWriter, r *Request) {
+		switch r.URL.Path {
+		case "/":
+			p, ok := w.(Pusher)
+			if !ok {
+				t.Error("ResponseWriter is not a Pusher")
+				return
+			}
+			err := p.Push("/style.css", &PushOptions{Header: Header{"X-Pushed": {"1"}}})
+			if err != nil {
+				t.Errorf("Push: %v", err)
+			}
+			if err := p.Push("/x", &PushOptions{Method: "POST"}); err == nil {
+				t.Error("Push with POST succeeded")
+			}
+			io.WriteString(w, "index")
+		case "/style.css":
+			if r.Header.Get("X-Pushed") != "1" {
+				t.Errorf("pushed request header = %v", r.Header)
+			}
+			if err := w.(Pusher).Push("/y", nil); err == nil {
+				t.Error("recursive Push succeeded")
+			}
+			io.WriteString(w, "css")
+		}
+	}))
+	defer ts.Close()
+
+	rc := newH2RawConn(t, ts)
+	defer rc.conn.Close()
+	rc.writeHeaders(1, true, ":method", "GET", ":scheme", "https", ":authority", "example.com", ":path", "/")
+
+	var promised uint32
+	bodies := map[uint32]string{}
+	ended := map[uint32]bool{}
+	for !ended[1] || promised == 0 || !ended[promised] {
+		switch f := rc.readFrame().(type) {
+		case *http2.PushPromiseFrame:
+			if f.StreamID != 1 {
+				t.Fatalf("PUSH_PROMISE on stream %d", f.StreamID)
+			}
+			promised = f.PromiseID
+			h := rc.decode(f.HeaderBlockFragment())
+			if h[":path"] != "/style.css" || h[":authority"] != "example.com" || h[":method"] != "GET" {
+				t.Errorf("promised request = %v", h)
+			}
+		case *http2.HeadersFrame:
+			h := rc.decode(f.HeaderBlockFragment())
+			if h[":status"] != "200" {
+				t.Errorf("stream %d: status %q", f.StreamID, h[":status"])
+			}
+			ended[f.StreamID] = ended[f.StreamID] || f.StreamEnded()
+		case *http2.DataFrame:
+			bodies[f.StreamID] += string(f.Data())
+			ended[f.StreamID] = ended[f.StreamID] || f.StreamEnded()
+		default:
+			t.Fatalf("unexpected frame %v", f.Header())
+		}
+	}
+	if promised%2 != 0 {
+		t.Errorf("promised stream %d is odd", promised)
+	}
+	if bodies[1] != "index" || bodies[promised] != "css" {
+		t.Errorf("bodies = %q", bodies)
+	}
+}
+
+func TestH2PushDisabled(t *testing.T) {
+	defer afterTest(t)
+	errc := make(chan error, 1)
+	ts := newH2Server(HandlerFunc(func(w ResponseWriter, r *Request) {
+		errc <- w.(Pusher).Push("/style.css", nil)
+	}))
+	defer ts.Close()
+	// The Transport sends SETTINGS_ENABLE_PUSH=0.
+	tr := newTLSTransport(t, ts)
+	defer tr.CloseIdleConnections()
+	res, err := (&Client{Transport: tr}).Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if err := <-errc; err != ErrNotSupported {
+		t.Errorf("Push = %v; want ErrNotSupported", err)
+	}
+}
+
+func TestH2ServerRejectsMalformedRequest(t *testing.T) {
+	defer afterTest(t)
+	ts := newH2Server(HandlerFunc(func(w ResponseWriter, r *Request) {
+		t.Errorf("handler called for %v", r.URL)
+	}))
+	defer ts.Close()
+
+	rc := newH2RawConn(t, ts)
+	defer rc.conn.Close()
+	// Connection-specific header fields are forbidden.
+	rc.writeHeaders(1, true, ":method", "GET", ":scheme", "https", ":authority", "example.com", ":path", "/",
+		"connection", "close")
+	f, ok := rc.readFrame().(*http2.RSTStreamFrame)
+	if !ok || f.StreamID != 1 || f.ErrCode != http2.ErrCodeProtocol {
+		t.Errorf("got %v; want RST_STREAM PROTOCOL_ERROR on stream 1", f)
+	}
+}
@@ -0,0 +1,1144 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// HTTP/2 client. See RFC 7540.
+
+package http
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"net/http/httptrace"
+	"net/http/internal/hpack"
+	"net/http/internal/http2"
+)
+
+const (
+	h2ClientInitialWindowSize = 4 << 20 // per-stream receive window
+	h2ClientConnWindowSize    = 1 << 30 // connection receive window
+	h2ClientMaxReadFrameSize  = 1 << 20
+
+	// h2DefaultMaxConcurrentStreams limits streams until the
+	// server's SETTINGS say otherwise.
+	h2DefaultMaxConcurrentStreams = 100
+)
+
+var (
+	// errH2ClientConnUnusable is returned by h2ClientConn.RoundTrip
+	// when the connection can't take a new request; the Transport
+	// then retries on another connection.
+	errH2ClientConnUnusable = errors.New("http2: client connection not usable")
+
+	errH2RequestCanceled = errors.New("net/http: request canceled")
+)
+
+// onceSetNextProtoDefaults enables HTTP/2 for TLS connections made
+// by t, unless the user has set t.TLSNextProto.
+func (t *Transport) onceSetNextProtoDefaults() {
+	if t.TLSNextProto != nil {
+		return
+	}
+	t.TLSNextProto = map[string]func(string, *tls.Conn) RoundTripper{
+		http2.NextProtoTLS: func(authority string, c *tls.Conn) RoundTripper {
+			cc, err := t.newH2ClientConn(c)
+			if err != nil {
+				c.Close()
+				return h2erringRoundTripper{err}
+			}
+			return cc
+		},
+	}
+}
+
+// nextProtos returns the protocols to offer in the TLS handshake.
+func (t *Transport) nextProtos() []string {
+	if len(t.TLSNextProto) == 0 {
+		return nil
+	}
+	protos := make([]string, 0, len(t.TLSNextProto)+1)
+	for p := range t.TLSNextProto {
+		protos = append(protos, p)
+	}
+	// Offer "h2" (which sorts first among the usual names) in
+	// preference to HTTP/1.1.
+	sort.Strings(protos)
+	return append(protos, "http/1.1")
+}
+
+// getH2Conn returns a pooled HTTP/2 connection for key, if any.
+func (t *Transport) getH2Conn(key connectMethodKey) *h2ClientConn {
+	t.h2mu.Lock()
+	defer t.h2mu.Unlock()
+	conns := t.h2conns[key]
+	for len(conns) > 0 {
+		cc := conns[0]
+		if cc.canTakeNewRequest() {
+			return cc
+		}
+		conns = conns[1:]
+		t.h2conns[key] = conns
+	}
+	delete(t.h2conns, key)
+	return nil
+}
+
+// putH2Conn adds cc to the pool, to be shared by later requests
+// for key.
+func (t *Transport) putH2Conn(key connectMethodKey, cc *h2ClientConn) {
+	t.h2mu.Lock()
+	defer t.h2mu.Unlock()
+	if t.h2conns == nil {
+		t.h2conns = make(map[connectMethodKey][]*h2ClientConn)
+	}
+	cc.key = key
+	t.h2conns[key] = append(t.h2conns[key], cc)
+}
+
+func (t *Transport) removeH2Conn(cc *h2ClientConn) {
+	t.h2mu.Lock()
+	defer t.h2mu.Unlock()
+	conns := t.h2conns[cc.key]
+	for i, v := range conns {
+		if v == cc {
+			conns = append(conns[:i:i], conns[i+1:]...)
+			break
+		}
+	}
+	if len(conns) == 0 {
+		delete(t.h2conns, cc.key)
+	} else {
+		t.h2conns[cc.key] = conns
+	}
+}
+
+// closeIdleH2Conns closes the pooled HTTP/2 connections that have
+// no requests in flight.
+func (t *Transport) closeIdleH2Conns() {
+	t.h2mu.Lock()
+	var conns []*h2ClientConn
+	for _, v := range t.h2conns {
+		conns = append(conns, v...)
+	}
+	t.h2mu.Unlock()
+	for _, cc := range conns {
+		cc.closeIfIdle()
+	}
+}
+
+// h2erringRoundTripper is the alternate-protocol RoundTripper for a
+// connection whose HTTP/2 setup failed.
+type h2erringRoundTripper struct{ err error }
+
+func (rt h2erringRoundTripper) RoundTrip(*Request) (*Response, error) { return nil, rt.err }
+
+// h2ClientConn is the client side of an HTTP/2 connection, carrying
+// any number of concurrent requests.
+//
+// A single goroutine (readLoop) reads frames. Frames are written by
+// whichever goroutine needs to, holding wmu. Lock ordering: wmu
+// before mu.
+type h2ClientConn struct {
+	t        *Transport
+	key      connectMethodKey // pool key; guarded by t.h2mu
+	conn     *tls.Conn
+	tlsState *tls.ConnectionState
+	fr       *http2.Framer
+	hdec     *hpack.Decoder // used by readLoop only
+
+	// Used only by readLoop, to assemble header blocks split
+	// over CONTINUATION frames.
+	hdrStreamID  uint32
+	hdrEndStream bool
+	hdrBlock     []byte
+
+	wmu  sync.Mutex // guards writing frames; see writeFrame
+	bw   *bufio.Writer
+	henc *h2encoder
+	werr error // sticky write error
+
+	mu                   sync.Mutex
+	cond                 sync.Cond // c.L == &mu; signaled on window and stream state changes
+	streams              map[uint32]*h2clientStream
+	nextStreamID         uint32
+	reserved             int // streams RoundTrip is about to open
+	maxConcurrentStreams uint32
+	sendWindow           h2flow // connection send window
+	initialSendWindow    int32  // server's SETTINGS_INITIAL_WINDOW_SIZE
+	peerMaxFrameSize     uint32
+	recvUnacked          int32 // connection credit not yet returned by WINDOW_UPDATE
+	recvWindow           int32 // connection receive window left for the server
+	goAway               *http2.GoAwayError
+	closed               bool
+	closeErr             error // error for streams still open at close
+}
+
+// h2clientStream is one request on an h2ClientConn. Unless noted,
+// its fields are guarded by cc.mu.
+type h2clientStream struct {
+	cc            *h2ClientConn
+	id            uint32
+	req           *Request
+	requestedGzip bool                   // immutable
+	trace         *httptrace.ClientTrace // immutable; or nil
+	resc          chan responseAndError  // buffered; the response or an error, once
+	body          *h2pipe                // response body
+	trailer       Header                 // response trailer to fill in; values set by readLoop
+
+	sendWindow   h2flow
+	recvWindow   int32
+	recvUnacked  int32
+	gotHeaders   bool  // final response headers received
+	gotFirstByte bool  // some response headers received; owned by readLoop
+	endSent      bool  // request fully sent
+	endRecv      bool  // response fully received
+	stopSend     bool  // the server asked us to stop sending the body
+	resetErr     error // non-nil once the stream is reset or the conn closed
+
+	done chan struct{} // closed once the stream is forgotten
+}
+
+func (t *Transport) newH2ClientConn(c *tls.Conn) (*h2ClientConn, error) {
+	cc := &h2ClientConn{
+		t:                    t,
+		conn:                 c,
+		bw:                   bufio.NewWriter(c),
+		henc:                 newH2Encoder(),
+		hdec:                 hpack.NewDecoder(http2.InitialHeaderTableSize),
+		streams:              make(map[uint32]*h2clientStream),
+		nextStreamID:         1,
+		maxConcurrentStreams: h2DefaultMaxConcurrentStreams,
+		sendWindow:           http2.InitialWindowSize,
+		initialSendWindow:    http2.InitialWindowSize,
+		peerMaxFrameSize:     http2.DefaultMaxFrameSize,
+		recvWindow:           h2ClientConnWindowSize,
+	}
+	cc.cond.L = &cc.mu
+	state := c.ConnectionState()
+	cc.tlsState = &state
+	cc.fr = http2.NewFramer(cc.bw, bufio.NewReader(c))
+	cc.fr.SetMaxReadFrameSize(h2ClientMaxReadFrameSize)
+
+	err := cc.writeFrame(func(fr *http2.Framer) error {
+		if _, err := cc.bw.WriteString(http2.ClientPreface); err != nil {
+			return err
+		}
+		err := fr.WriteSettings(
+			http2.Setting{http2.SettingEnablePush, 0},
+			http2.Setting{http2.SettingInitialWindowSize, h2ClientInitialWindowSize},
+			http2.Setting{http2.SettingMaxFrameSize, h2ClientMaxReadFrameSize},
+		)
+		if err != nil {
+			return err
+		}
+		return fr.WriteWindowUpdate(0, h2ClientConnWindowSize-http2.InitialWindowSize)
+	})
+	if err != nil {
+		return nil, err
+	}
+	go cc.readLoop()
+	return cc, nil
+}
+
+func (cc *h2ClientConn) canTakeNewRequest() bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.canTakeNewRequestLocked()
+}
+
+func (cc *h2ClientConn) canTakeNewRequestLocked() bool {
+	return !cc.closed && cc.goAway == nil && cc.nextStreamID < 1<<31
+}
+
+func (cc *h2ClientConn) closeIfIdle() {
+	cc.mu.Lock()
+	idle := len(cc.streams) == 0 && cc.reserved == 0
+	if idle {
+		cc.closed = true
+	}
+	cc.mu.Unlock()
+	if idle {
+		cc.conn.Close()
+	}
+}
+
+// writeFrame calls fn to write frames and flushes them to the
+// connection. A write error closes the connection.
+func (cc *h2ClientConn) writeFrame(fn func(*http2.Framer) error) error {
+	cc.wmu.Lock()
+	defer cc.wmu.Unlock()
+	return cc.writeFrameLocked(fn)
+}
+
+func (cc *h2ClientConn) writeFrameLocked(fn func(*http2.Framer) error) error {
+	if cc.werr != nil {
+		return cc.werr
+	}
+	err := fn(cc.fr)
+	if err == nil {
+		err = cc.bw.Flush()
+	}
+	if err != nil {
+		cc.werr = err
+		cc.conn.Close()
+	}
+	return err
+}
+
+func (cc *h2ClientConn) RoundTrip(req *Request) (*Response, error) {
+	requestedGzip := !cc.t.DisableCompression &&
+		req.Header.Get("Accept-Encoding") == "" &&
+		req.Header.Get("Range") == "" &&
+		req.Method != "HEAD"
+	hasBody := req.Body != nil
+	fields := cc.requestHeaderFields(req, requestedGzip)
+
+	// Wait for a free stream slot, then open the stream. Stream
+	// IDs must be used in increasing order, so the ID is
+	// allocated with the write lock held.
+	cc.mu.Lock()
+	for {
+		if !cc.canTakeNewRequestLocked() {
+			cc.mu.Unlock()
+			return nil, errH2ClientConnUnusable
+		}
+		if uint32(len(cc.streams)+cc.reserved) < cc.maxConcurrentStreams {
+			break
+		}
+		cc.cond.Wait()
+	}
+	cc.reserved++
+	cc.mu.Unlock()
+
+	cc.wmu.Lock()
+	cc.mu.Lock()
+	cc.reserved--
+	if !cc.canTakeNewRequestLocked() {
+		cc.cond.Broadcast()
+		cc.mu.Unlock()
+		cc.wmu.Unlock()
+		return nil, errH2ClientConnUnusable
+	}
+	reused := cc.nextStreamID > 1
+	wasIdle := reused && len(cc.streams) == 0
+	trace := httptrace.ContextClientTrace(req.Context())
+	cs := &h2clientStream{
+		cc:            cc,
+		id:            cc.nextStreamID,
+		req:           req,
+		requestedGzip: requestedGzip,
+		trace:         trace,
+		resc:          make(chan responseAndError, 1),
+		sendWindow:    h2flow(cc.initialSendWindow),
+		recvWindow:    h2ClientInitialWindowSize,
+		endSent:       !hasBody,
+		done:          make(chan struct{}),
+	}
+	cs.body = newH2Pipe(func(n int) { cc.returnCredit(cs, int32(n)) })
+	cc.nextStreamID += 2
+	cc.streams[cs.id] = cs
+	maxFrameSize := cc.peerMaxFrameSize
+	cc.mu.Unlock()
+	err := cc.writeFrameLocked(func(fr *http2.Framer) error {
+		return cc.henc.writeHeaders(fr, cs.id, !hasBody, maxFrameSize, fields)
+	})
+	cc.wmu.Unlock()
+	if trace != nil && trace.GotConn != nil {
+		trace.GotConn(httptrace.GotConnInfo{Conn: cc.conn, Reused: reused, WasIdle: wasIdle})
+	}
+	if err != nil {
+		if trace != nil && trace.WroteRequest != nil {
+			trace.WroteRequest(httptrace.WroteRequestInfo{Err: err})
+		}
+		cc.abortStream(cs, err)
+		req.closeBody()
+		return nil, err
+	}
+	if trace != nil && trace.WroteHeaders != nil {
+		trace.WroteHeaders()
+	}
+	if !hasBody && trace != nil && trace.WroteRequest != nil {
+		trace.WroteRequest(httptrace.WroteRequestInfo{})
+	}
+
+	cc.t.setReqCanceler(req, func() {
+		cc.resetStream(cs, http2.ErrCodeCancel, errH2RequestCanceled)
+	})
+	if ctx := req.Context(); ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				cc.resetStream(cs, http2.ErrCodeCancel, ctx.Err())
+			case <-cs.done:
+			}
+		}()
+	}
+
+	var bodyDone chan struct{}
+	if hasBody {
+		bodyDone = make(chan struct{})
+		go cs.writeBody(bodyDone)
+	}
+
+	// As for HTTP/1, ResponseHeaderTimeout runs from when the
+	// request has been fully written.
+	timeout := cc.t.ResponseHeaderTimeout
+	var respHeaderTimer <-chan time.Time
+	if timeout > 0 && !hasBody {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop() // prevent leaks
+		respHeaderTimer = timer.C
+	}
+	for {
+		select {
+		case re := <-cs.resc:
+			if re.err != nil {
+				cc.t.setReqCanceler(req, nil)
+			}
+			return re.res, re.err
+		case <-bodyDone:
+			bodyDone = nil
+			if timeout > 0 {
+				timer := time.NewTimer(timeout)
+				defer timer.Stop() // prevent leaks
+				respHeaderTimer = timer.C
+			}
+		case <-respHeaderTimer:
+			cc.resetStream(cs, http2.ErrCodeCancel, errTimeout)
+			cc.t.setReqCanceler(req, nil)
+			return nil, errTimeout
+		}
+	}
+}
+
+// requestHeaderFields returns the header block for req.
+func (cc *h2ClientConn) requestHeaderFields(req *Request, requestedGzip bool) []hpack.HeaderField {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	method := req.Method
+	if method == "" {
+		method = "GET"
+	}
+	fields := []hpack.HeaderField{
+		{Name: ":authority", Value: host},
+		{Name: ":method", Value: method},
+	}
+	if method != "CONNECT" {
+		fields = append(fields,
+			hpack.HeaderField{Name: ":path", Value: req.URL.RequestURI()},
+			hpack.HeaderField{Name: ":scheme", Value: "https"},
+		)
+	}
+	fields = h2HeaderFields(fields, req.Header, func(k string) bool {
+		switch CanonicalHeaderKey(k) {
+		case "Host", "Content-Length", "Trailer":
+			return true
+		case "Te":
+			return req.Header.get(k) != "trailers"
+		}
+		return false
+	})
+	if len(req.Trailer) > 0 {
+		keys := make([]string, 0, len(req.Trailer))
+		for k := range req.Trailer {
+			keys = append(keys, CanonicalHeaderKey(k))
+		}
+		sort.Strings(keys)
+		fields = append(fields, hpack.HeaderField{Name: "trailer", Value: strings.Join(keys, ",")})
+	}
+	if req.Body != nil && req.ContentLength > 0 {
+		fields = append(fields, hpack.HeaderField{Name: "content-length", Value: strconv.FormatInt(req.ContentLength, 10)})
+	}
+	if requestedGzip {
+		fields = append(fields, hpack.HeaderField{Name: "accept-encoding", Value: "gzip"})
+	}
+	if _, ok := req.Header["User-Agent"]; !ok {
+		fields = append(fields, hpack.HeaderField{Name: "user-agent", Value: defaultUserAgent})
+	}
+	return fields
+}
+
+// writeBody sends the request body and any trailers, closing done
+// when it is finished.
+func (cs *h2clientStream) writeBody(done chan struct{}) {
+	cc := cs.cc
+	var err error
+	if trace := cs.trace; trace != nil && trace.WroteRequest != nil {
+		defer func() { trace.WroteRequest(httptrace.WroteRequestInfo{Err: err}) }()
+	}
+	defer close(done)
+	defer cs.req.Body.Close()
+	buf := make([]byte, h2ClientMaxReadFrameSize)
+	for {
+		n, rerr := cs.req.Body.Read(buf)
+		if rerr != nil && rerr != io.EOF {
+			err = rerr
+			cc.resetStream(cs, http2.ErrCodeCancel, rerr)
+			return
+		}
+		end := rerr == io.EOF && len(cs.req.Trailer) == 0
+		if err = cc.writeData(cs, buf[:n], end); err != nil {
+			return
+		}
+		if rerr == io.EOF {
+			break
+		}
+	}
+	if len(cs.req.Trailer) == 0 {
+		return
+	}
+	var fields []hpack.HeaderField
+	for k, vv := range cs.req.Trailer {
+		for _, v := range vv {
+			fields = append(fields, hpack.HeaderField{Name: strings.ToLower(k), Value: v})
+		}
+	}
+	if len(fields) == 0 {
+		err = cc.writeData(cs, nil, true)
+		return
+	}
+	err = cc.writeFrame(func(fr *http2.Framer) error {
+		cc.mu.Lock()
+		err := cs.sendErrLocked()
+		maxFrameSize := cc.peerMaxFrameSize
+		cc.mu.Unlock()
+		if err != nil {
+			return nil
+		}
+		if err := cc.henc.writeHeaders(fr, cs.id, true, maxFrameSize, fields); err != nil {
+			return err
+		}
+		cc.mu.Lock()
+		cc.endSendLocked(cs)
+		cc.mu.Unlock()
+		return nil
+	})
+}
+
+// sendErrLocked returns the error, if any, that stops more of the
+// request from being sent. cc.mu must be held.
+func (cs *h2clientStream) sendErrLocked() error {
+	if cs.resetErr != nil {
+		return cs.resetErr
+	}
+	if cs.stopSend {
+		return errH2StreamClosed
+	}
+	return nil
+}
+
+// writeData writes p as DATA frames for cs, waiting for flow-control
+// window as needed.
+func (cc *h2ClientConn) writeData(cs *h2clientStream, p []byte, endStream bool) error {
+	for {
+		n, err := cc.awaitSendWindow(cs, len(p))
+		if err != nil {
+			return err
+		}
+		chunk := p[:n]
+		p = p[n:]
+		end := endStream && len(p) == 0
+		err = cc.writeFrame(func(fr *http2.Framer) error {
+			return fr.WriteData(cs.id, end, chunk)
+		})
+		if err != nil {
+			return err
+		}
+		if len(p) == 0 {
+			if end {
+				cc.mu.Lock()
+				cc.endSendLocked(cs)
+				cc.mu.Unlock()
+			}
+			return nil
+		}
+	}
+}
+
+// awaitSendWindow waits until cs may send some of want bytes and
+// takes up to that many from the connection and stream windows.
+func (cc *h2ClientConn) awaitSendWindow(cs *h2clientStream, want int) (int, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	for {
+		if err := cs.sendErrLocked(); err != nil {
+			return 0, err
+		}
+		if want == 0 {
+			return 0, nil
+		}
+		n := int32(cc.peerMaxFrameSize)
+		if int(n) > want {
+			n = int32(want)
+		}
+		if w := int32(cs.sendWindow); w < n {
+			n = w
+		}
+		if w := int32(cc.sendWindow); w < n {
+			n = w
+		}
+		if n > 0 {
+			cs.sendWindow -= h2flow(n)
+			cc.sendWindow -= h2flow(n)
+			return int(n), nil
+		}
+		cc.cond.Wait()
+	}
+}
+
+// endSendLocked records that the request has been fully sent.
+// cc.mu must be held.
+func (cc *h2ClientConn) endSendLocked(cs *h2clientStream) {
+	cs.endSent = true
+	if cs.endRecv {
+		cc.forgetStreamLocked(cs)
+	}
+}
+
+// forgetStreamLocked removes a finished or reset stream from cc.
+// cc.mu must be held.
+func (cc *h2ClientConn) forgetStreamLocked(cs *h2clientStream) {
+	if cc.streams[cs.id] != cs {
+		return
+	}
+	delete(cc.streams, cs.id)
+	close(cs.done)
+	cc.cond.Broadcast()
+	if cc.goAway != nil && len(cc.streams) == 0 {
+		cc.closed = true
+		cc.conn.Close()
+	}
+}
+
+// resetStream sends RST_STREAM for cs and fails it with err.
+func (cc *h2ClientConn) resetStream(cs *h2clientStream, code http2.ErrCode, err error) {
+	cc.mu.Lock()
+	done := cs.resetErr != nil || cs.endRecv && cs.endSent
+	cc.mu.Unlock()
+	if done {
+		return
+	}
+	cc.writeFrame(func(fr *http2.Framer) error {
+		return fr.WriteRSTStream(cs.id, code)
+	})
+	cc.abortStream(cs, err)
+}
+
+// abortStream fails cs with err, without telling the server.
+func (cc *h2ClientConn) abortStream(cs *h2clientStream, err error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.abortStreamLocked(cs, err)
+}
+
+func (cc *h2ClientConn) abortStreamLocked(cs *h2clientStream, err error) {
+	if cs.resetErr != nil {
+		return
+	}
+	cs.resetErr = err
+	if !cs.gotHeaders {
+		cs.resc <- responseAndError{err: err}
+	}
+	// Discarded body bytes are returned to the connection window
+	// by the next WINDOW_UPDATE.
+	cc.recvUnacked += int32(cs.body.breakWithError(err))
+	cc.forgetStreamLocked(cs)
+}
+
+func (cc *h2ClientConn) readLoop() {
+	var err error
+	defer func() { cc.close(err) }()
+	for {
+		var f http2.Frame
+		f, err = cc.fr.ReadFrame()
+		if err == nil {
+			err = cc.processFrame(f)
+		}
+		if err == nil {
+			continue
+		}
+		if err == http2.ErrFrameTooLarge {
+			err = http2.ConnectionError(http2.ErrCodeFrameSize)
+		}
+		switch ev := err.(type) {
+		case http2.StreamError:
+			cc.mu.Lock()
+			cs := cc.streams[ev.StreamID]
+			cc.mu.Unlock()
+			if cs != nil {
+				cc.resetStream(cs, ev.Code, ev)
+			} else {
+				cc.writeFrame(func(fr *http2.Framer) error {
+					return fr.WriteRSTStream(ev.StreamID, ev.Code)
+				})
+			}
+			continue
+		case http2.ConnectionError:
+			cc.writeFrame(func(fr *http2.Framer) error {
+				return fr.WriteGoAway(0, http2.ErrCode(ev), nil)
+			})
+		}
+		return
+	}
+}
+
+// close shuts down the connection after readLoop has exited, failing
+// any requests still in flight.
+func (cc *h2ClientConn) close(err error) {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	cc.mu.Lock()
+	cc.closed = true
+	for _, cs := range cc.streams {
+		cerr := err
+		if cc.goAway != nil {
+			cerr = *cc.goAway
+		}
+		if cs.gotHeaders {
+			// Let the body reader see the data that
+			// arrived before the error.
+			cs.body.closeWithError(cerr)
+			cs.resetErr = cerr
+			cc.forgetStreamLocked(cs)
+		} else {
+			cc.abortStreamLocked(cs, cerr)
+		}
+	}
+	cc.cond.Broadcast()
+	cc.mu.Unlock()
+	cc.conn.Close()
+	cc.t.removeH2Conn(cc)
+}
+
+func (cc *h2ClientConn) processFrame(f http2.Frame) error {
+	switch f := f.(type) {
+	case *http2.SettingsFrame:
+		return cc.processSettings(f)
+	case *http2.HeadersFrame:
+		cc.hdrStreamID = f.StreamID
+		cc.hdrEndStream = f.StreamEnded()
+		cc.hdrBlock = append(cc.hdrBlock[:0], f.HeaderBlockFragment()...)
+		if f.HeadersEnded() {
+			return cc.processHeaderBlock()
+		}
+	case *http2.ContinuationFrame:
+		cc.hdrBlock = append(cc.hdrBlock, f.HeaderBlockFragment()...)
+		if f.HeadersEnded() {
+			return cc.processHeaderBlock()
+		}
+	case *http2.DataFrame:
+		return cc.processData(f)
+	case *http2.WindowUpdateFrame:
+		cc.mu.Lock()
+		defer cc.mu.Unlock()
+		defer cc.cond.Broadcast()
+		if f.StreamID == 0 {
+			if !cc.sendWindow.add(int32(f.Increment)) {
+				return http2.ConnectionError(http2.ErrCodeFlowControl)
+			}
+			return nil
+		}
+		if cs := cc.streams[f.StreamID]; cs != nil && !cs.sendWindow.add(int32(f.Increment)) {
+			return http2.StreamError{f.StreamID, http2.ErrCodeFlowControl}
+		}
+	case *http2.RSTStreamFrame:
+		cc.mu.Lock()
+		defer cc.mu.Unlock()
+		cs := cc.streams[f.StreamID]
+		if cs == nil {
+			if f.StreamID >= cc.nextStreamID {
+				return http2.ConnectionError(http2.ErrCodeProtocol)
+			}
+			return nil
+		}
+		if f.ErrCode == http2.ErrCodeNo && cs.endRecv {
+			// The server has sent its full response and
+			// doesn't want the rest of the request body.
+			cs.stopSend = true
+			cc.forgetStreamLocked(cs)
+			cc.cond.Broadcast()
+			return nil
+		}
+		err := error(http2.StreamError{f.StreamID, f.ErrCode})
+		if f.ErrCode == http2.ErrCodeRefusedStream && !cs.gotHeaders && cs.req.Body == nil {
+			// Nothing was processed; the request may be
+			// retried elsewhere.
+			err = errH2ClientConnUnusable
+		}
+		if cs.gotHeaders {
+			cs.body.closeWithError(err)
+		}
+		cc.abortStreamLocked(cs, err)
+	case *http2.PingFrame:
+		if f.IsAck() {
+			return nil
+		}
+		data := f.Data
+		return cc.writeFrame(func(fr *http2.Framer) error {
+			return fr.WritePing(true, data)
+		})
+	case *http2.GoAwayFrame:
+		cc.processGoAway(f)
+	case *http2.PushPromiseFrame:
+		// We sent SETTINGS_ENABLE_PUSH=0.
+		return http2.ConnectionError(http2.ErrCodeProtocol)
+	}
+	// PRIORITY and unknown frames are ignored.
+	return nil
+}
+
+func (cc *h2ClientConn) processSettings(f *http2.SettingsFrame) error {
+	if f.IsAck() {
+		return nil
+	}
+	var tableSize uint32
+	haveTableSize := false
+	cc.mu.Lock()
+	err := f.ForeachSetting(func(s http2.Setting) error {
+		switch s.ID {
+		case http2.SettingHeaderTableSize:
+			tableSize, haveTableSize = s.Val, true
+		case http2.SettingMaxConcurrentStreams:
+			cc.maxConcurrentStreams = s.Val
+		case http2.SettingInitialWindowSize:
+			delta := int32(s.Val) - cc.initialSendWindow
+			cc.initialSendWindow = int32(s.Val)
+			for _, cs := range cc.streams {
+				if !cs.sendWindow.add(delta) {
+					return http2.ConnectionError(http2.ErrCodeFlowControl)
+				}
+			}
+		case http2.SettingMaxFrameSize:
+			cc.peerMaxFrameSize = s.Val
+		}
+		return nil
+	})
+	cc.cond.Broadcast()
+	cc.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return cc.writeFrame(func(fr *http2.Framer) error {
+		if haveTableSize {
+			cc.henc.enc.SetMaxDynamicTableSizeLimit(tableSize)
+		}
+		return fr.WriteSettingsAck()
+	})
+}
+
+func (cc *h2ClientConn) processGoAway(f *http2.GoAwayFrame) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.goAway = &http2.GoAwayError{
+		LastStreamID: f.LastStreamID,
+		ErrCode:      f.ErrCode,
+		DebugData:    string(f.DebugData()),
+	}
+	// Streams after LastStreamID were not processed by the
+	// server. Those without a body to replay can be retried on
+	// another connection.
+	for id, cs := range cc.streams {
+		if id <= f.LastStreamID {
+			continue
+		}
+		var err error = *cc.goAway
+		if cs.req.Body == nil {
+			err = errH2ClientConnUnusable
+		}
+		cc.abortStreamLocked(cs, err)
+	}
+	if len(cc.streams) == 0 {
+		cc.closed = true
+		cc.conn.Close()
+	}
+	cc.cond.Broadcast()
+}
+
+func (cc *h2ClientConn) processHeaderBlock() error {
+	id, endStream := cc.hdrStreamID, cc.hdrEndStream
+	fields, err := cc.hdec.DecodeFull(cc.hdrBlock)
+	if err != nil {
+		return http2.ConnectionError(http2.ErrCodeCompression)
+	}
+	cc.mu.Lock()
+	cs := cc.streams[id]
+	idle := id >= cc.nextStreamID
+	cc.mu.Unlock()
+	if cs == nil {
+		if idle {
+			return http2.ConnectionError(http2.ErrCodeProtocol)
+		}
+		// A stream we reset; the block was decoded to keep
+		// the table in sync.
+		return nil
+	}
+	if !cs.gotFirstByte {
+		cs.gotFirstByte = true
+		if cs.trace != nil && cs.trace.GotFirstResponseByte != nil {
+			cs.trace.GotFirstResponseByte()
+		}
+	}
+	cc.mu.Lock()
+	gotHeaders := cs.gotHeaders
+	cc.mu.Unlock()
+	if gotHeaders {
+		return cc.processTrailers(cs, fields, endStream)
+	}
+
+	res, err := cc.newResponse(cs, fields, endStream)
+	if err != nil {
+		return http2.StreamError{id, http2.ErrCodeProtocol}
+	}
+	if res == nil {
+		// 1xx informational response.
+		return nil
+	}
+	cc.mu.Lock()
+	if cs.resetErr == nil {
+		cs.gotHeaders = true
+		cs.resc <- responseAndError{res: res}
+	}
+	cc.mu.Unlock()
+	if endStream {
+		cc.endRecv(cs)
+	}
+	return nil
+}
+
+// newResponse builds the Response for cs from its decoded header
+// fields. It returns a nil Response for informational (1xx)
+// responses.
+func (cc *h2ClientConn) newResponse(cs *h2clientStream, fields []hpack.HeaderField, endStream bool) (*Response, error) {
+	errMalformed := errors.New("malformed response")
+	status := ""
+	header := make(Header)
+	for _, f := range fields {
+		if f.Name == ":status" {
+			if status != "" || len(header) > 0 {
+				return nil, errMalformed
+			}
+			status = f.Value
+			continue
+		}
+		if strings.HasPrefix(f.Name, ":") || !validH2HeaderName(f.Name) {
+			return nil, errMalformed
+		}
+		key := CanonicalHeaderKey(f.Name)
+		header[key] = append(header[key], f.Value)
+	}
+	code, err := strconv.Atoi(status)
+	if err != nil || code < 100 || code > 999 {
+		return nil, errMalformed
+	}
+	if code < 200 {
+		if endStream {
+			return nil, errMalformed
+		}
+		if code == 100 && cs.trace != nil && cs.trace.Got100Continue != nil {
+			cs.trace.Got100Continue()
+		}
+		return nil, nil
+	}
+
+	res := &Response{
+		Status:        status + " " + StatusText(code),
+		StatusCode:    code,
+		Proto:         "HTTP/2.0",
+		ProtoMajor:    2,
+		Header:        header,
+		ContentLength: -1,
+		Request:       cs.req,
+		TLS:           cc.tlsState,
+	}
+	if cl := header.get("Content-Length"); cl != "" {
+		if n, err := strconv.ParseInt(cl, 10, 64); err == nil && n >= 0 {
+			res.ContentLength = n
+		}
+	}
+	if keys := h2TrailerKeys(header); len(keys) > 0 {
+		res.Trailer = make(Header)
+		for _, k := range keys {
+			res.Trailer[k] = nil
+		}
+		cs.trailer = res.Trailer
+	}
+	header.Del("Trailer")
+
+	if endStream || cs.req.Method == "HEAD" {
+		if cs.req.Method != "HEAD" {
+			res.ContentLength = 0
+		}
+		res.Body = eofReader
+		cc.t.setReqCanceler(cs.req, nil)
+		return res, nil
+	}
+	res.Body = &h2responseBody{cs: cs}
+	if cs.requestedGzip && header.get("Content-Encoding") == "gzip" {
+		header.Del("Content-Encoding")
+		header.Del("Content-Length")
+		res.ContentLength = -1
+		res.Body = &gzipReader{body: res.Body}
+	}
+	return res, nil
+}
+
+func (cc *h2ClientConn) processTrailers(cs *h2clientStream, fields []hpack.HeaderField, endStream bool) error {
+	if !endStream {
+		return http2.StreamError{cs.id, http2.ErrCodeProtocol}
+	}
+	for _, f := range fields {
+		if strings.HasPrefix(f.Name, ":") || !validH2HeaderName(f.Name) {
+			return http2.StreamError{cs.id, http2.ErrCodeProtocol}
+		}
+		key := CanonicalHeaderKey(f.Name)
+		if _, ok := cs.trailer[key]; ok {
+			cs.trailer[key] = append(cs.trailer[key], f.Value)
+		}
+	}
+	cc.endRecv(cs)
+	return nil
+}
+
+// endRecv records that the response to cs has been fully received.
+func (cc *h2ClientConn) endRecv(cs *h2clientStream) {
+	cs.body.closeWithError(io.EOF)
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cs.endRecv = true
+	if cs.endSent {
+		cc.forgetStreamLocked(cs)
+	}
+}
+
+func (cc *h2ClientConn) processData(f *http2.DataFrame) error {
+	data := f.Data()
+	n := int32(f.Length)
+
+	cc.mu.Lock()
+	if n > cc.recvWindow {
+		cc.mu.Unlock()
+		return http2.ConnectionError(http2.ErrCodeFlowControl)
+	}
+	cc.recvWindow -= n
+	cs := cc.streams[f.StreamID]
+	if cs == nil || !cs.gotHeaders || cs.endRecv || cs.resetErr != nil {
+		idle := f.StreamID >= cc.nextStreamID
+		early := cs != nil && !cs.gotHeaders && cs.resetErr == nil
+		cc.mu.Unlock()
+		if idle {
+			return http2.ConnectionError(http2.ErrCodeProtocol)
+		}
+		cc.returnCredit(nil, n)
+		if early {
+			return http2.StreamError{f.StreamID, http2.ErrCodeProtocol}
+		}
+		return nil
+	}
+	if n > cs.recvWindow {
+		cc.mu.Unlock()
+		cc.returnCredit(nil, n)
+		return http2.StreamError{f.StreamID, http2.ErrCodeFlowControl}
+	}
+	cs.recvWindow -= n
+	cc.mu.Unlock()
+
+	if pad := n - int32(len(data)); pad > 0 {
+		cc.returnCredit(cs, pad)
+	}
+	if len(data) > 0 {
+		if _, err := cs.body.Write(data); err != nil {
+			cc.returnCredit(nil, int32(len(data)))
+		}
+	}
+	if f.StreamEnded() {
+		cc.endRecv(cs)
+	}
+	return nil
+}
+
+// returnCredit records that n bytes of received DATA have been
+// consumed, sending WINDOW_UPDATE frames for the connection and, if
+// cs is non-nil, the stream once enough credit has accumulated.
+func (cc *h2ClientConn) returnCredit(cs *h2clientStream, n int32) {
+	if n <= 0 {
+		return
+	}
+	var connIncr, streamIncr int32
+	cc.mu.Lock()
+	cc.recvUnacked += n
+	if cc.recvUnacked >= h2ClientConnWindowSize/2 {
+		connIncr = cc.recvUnacked
+		cc.recvWindow += connIncr
+		cc.recvUnacked = 0
+	}
+	if cs != nil && !cs.endRecv && cs.resetErr == nil {
+		cs.recvUnacked += n
+		if cs.recvUnacked >= h2ClientInitialWindowSize/2 {
+			streamIncr = cs.recvUnacked
+			cs.recvWindow += streamIncr
+			cs.recvUnacked = 0
+		}
+	}
+	cc.mu.Unlock()
+	if connIncr == 0 && streamIncr == 0 {
+		return
+	}
+	cc.writeFrame(func(fr *http2.Framer) error {
+		if connIncr > 0 {
+			if err := fr.WriteWindowUpdate(0, uint32(connIncr)); err != nil {
+				return err
+			}
+		}
+		if streamIncr > 0 {
+			return fr.WriteWindowUpdate(cs.id, uint32(streamIncr))
+		}
+		return nil
+	})
+}
+
+// h2responseBody is the Response.Body of an HTTP/2 response.
+type h2responseBody struct {
+	cs     *h2clientStream
+	closed bool
+}
+
+func (b *h2responseBody) Read(p []byte) (n int, err error) {
+	if b.closed {
+		return 0, ErrBodyReadAfterClose
+	}
+	n, err = b.cs.body.Read(p)
+	if err != nil {
+		b.cs.cc.t.setReqCanceler(b.cs.req, nil)
+	}
+	return n, err
+}
+
+func (b *h2responseBody) Close() error {
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	cs, cc := b.cs, b.cs.cc
+	cc.t.setReqCanceler(cs.req, nil)
+	cc.mu.Lock()
+	done := cs.endRecv
+	cc.mu.Unlock()
+	if done {
+		cc.returnCredit(nil, int32(cs.body.breakWithError(ErrBodyReadAfterClose)))
+		return nil
+	}
+	// Tell the server we don't want the rest.
+	cc.resetStream(cs, http2.ErrCodeCancel, ErrBodyReadAfterClose)
+	return nil
+}
@@ -0,0 +1,388 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Load balancing over a pool of backends for ReverseProxy
+
+package httputil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// A BalancePolicy selects which backend of a BackendPool receives
+// a request.
+type BalancePolicy int
+
+const (
+	// RoundRobin sends requests to each healthy backend in turn.
+	RoundRobin BalancePolicy = iota
+
+	// LeastConn sends each request to the healthy backend with
+	// the fewest requests in flight.
+	LeastConn
+)
+
+var errNoBackend = errors.New("httputil: no healthy backend")
+
+// A BackendPool is an http.RoundTripper that spreads requests over a
+// set of backend servers. It rewrites each request's URL to the
+// chosen backend in the manner of NewSingleHostReverseProxy.
+//
+// A backend is ejected from the pool after MaxFails consecutive
+// failures, either connection failures of proxied requests or failed
+// health checks. With HealthCheckPath set, an ejected backend is
+// reinstated by its next successful health check; otherwise it is
+// reinstated after FailTimeout.
+//
+// Requests with idempotent methods that fail are retried on other
+// backends, provided that they have no body: the Transport closes a
+// request's body when the request fails, so it can't be sent again.
+//
+// The fields of a BackendPool must not be modified after its first
+// use. Close stops its health checks.
+type BackendPool struct {
+	// Policy selects the backend for each request.
+	Policy BalancePolicy
+
+	// Transport is used to reach the backends.
+	// If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// MaxAttempts is the maximum number of backends a request is
+	// tried on. If zero, up to 3 backends are tried.
+	MaxAttempts int
+
+	// MaxFails is the number of consecutive failures that eject
+	// a backend. If zero, a single failure ejects it.
+	MaxFails int
+
+	// FailTimeout is how long a backend ejected by failed requests
+	// stays out of the pool when there are no health checks.
+	// If zero, 10 seconds is used.
+	FailTimeout time.Duration
+
+	// HealthCheckPath, if non-empty, enables active health checks:
+	// a GET of this path, relative to the backend URL, must
+	// return a 2xx or 3xx status.
+	HealthCheckPath string
+
+	// HealthCheckInterval is the time between health checks.
+	// If zero, 10 seconds is used.
+	HealthCheckInterval time.Duration
+
+	// HealthCheckTimeout limits each health check.
+	// If zero, 5 seconds is used.
+	HealthCheckTimeout time.Duration
+
+	startOnce sync.Once
+	closeOnce sync.Once
+	closec    chan struct{}
+	wg        sync.WaitGroup
+
+	mu       sync.Mutex // guards the following and the backend states
+	backends []*backend
+	next     int // index of the next backend for RoundRobin
+}
+
+// backend is one server of a BackendPool. Its fields other than
+// target are guarded by the pool's mu.
+type backend struct {
+	target    *url.URL
+	active    int       // requests in flight
+	fails     int       // consecutive failures
+	ejected   bool      // out of the pool
+	ejectedAt time.Time // when ejected
+}
+
+// NewBackendPool returns a BackendPool over targets, using the
+// RoundRobin policy.
+func NewBackendPool(targets ...*url.URL) *BackendPool {
+	p := &BackendPool{
+		closec: make(chan struct{}),
+	}
+	for _, t := range targets {
+		p.backends = append(p.backends, &backend{target: t})
+	}
+	return p
+}
+
+// NewBalancingReverseProxy returns a new ReverseProxy that sends
+// requests to the backends of pool.
+func NewBalancingReverseProxy(pool *BackendPool) *ReverseProxy {
+	return &ReverseProxy{
+		Director:  func(*http.Request) {},
+		Transport: pool,
+	}
+}
+
+// Healthy returns the URLs of the backends currently in the pool.
+func (p *BackendPool) Healthy() []*url.URL {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	var urls []*url.URL
+	for _, b := range p.backends {
+		if p.usableLocked(b, now) {
+			urls = append(urls, b.target)
+		}
+	}
+	return urls
+}
+
+// Close stops the pool's health checks and waits for any in
+// progress to finish. Requests may still be sent through the pool.
+func (p *BackendPool) Close() error {
+	p.closeOnce.Do(func() { close(p.closec) })
+	p.wg.Wait()
+	return nil
+}
+
+func (p *BackendPool) transport() http.RoundTripper {
+	if p.Transport != nil {
+		return p.Transport
+	}
+	return http.DefaultTransport
+}
+
+func (p *BackendPool) maxAttempts() int {
+	if p.MaxAttempts > 0 {
+		return p.MaxAttempts
+	}
+	return 3
+}
+
+func (p *BackendPool) maxFails() int {
+	if p.MaxFails > 0 {
+		return p.MaxFails
+	}
+	return 1
+}
+
+func (p *BackendPool) failTimeout() time.Duration {
+	if p.FailTimeout > 0 {
+		return p.FailTimeout
+	}
+	return 10 * time.Second
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (p *BackendPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	if p.HealthCheckPath != "" {
+		p.startOnce.Do(p.startHealthChecks)
+	}
+	ctx := req.Context()
+	tried := make(map[*backend]bool)
+	err := errNoBackend
+	for len(tried) < p.maxAttempts() {
+		b := p.pick(tried)
+		if b == nil {
+			break
+		}
+		tried[b] = true
+
+		outreq := new(http.Request)
+		*outreq = *req
+		u := *req.URL
+		outreq.URL = &u
+		rewriteURL(outreq.URL, b.target)
+
+		var res *http.Response
+		res, err = p.transport().RoundTrip(outreq)
+		if err == nil {
+			p.succeeded(b)
+			body := &backendBody{ReadCloser: res.Body, pool: p, b: b}
+			if rw, ok := res.Body.(io.ReadWriteCloser); ok {
+				// Keep the connection of a protocol
+				// switch writable.
+				res.Body = backendRWBody{body, rw}
+			} else {
+				res.Body = body
+			}
+			return res, nil
+		}
+		p.done(b)
+		if ctx.Err() != nil {
+			// The client went away; that's not the backend's fault.
+			return nil, err
+		}
+		p.failed(b)
+		if !canRetry(req) {
+			break
+		}
+	}
+	return nil, err
+}
+
+// canRetry reports whether req may be sent again after a failed
+// attempt. Only requests with idempotent methods and no body are
+// retried, as a failed attempt closes the body.
+func canRetry(req *http.Request) bool {
+	switch req.Method {
+	case "", "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+	default:
+		return false
+	}
+	return req.Body == nil || req.ContentLength == 0 && len(req.TransferEncoding) == 0
+}
+
+// pick chooses a usable backend not in tried and counts a request
+// in flight on it. It returns nil if there is none.
+func (p *BackendPool) pick(tried map[*backend]bool) *backend {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	var best *backend
+	n := len(p.backends)
+	for i := 0; i < n; i++ {
+		idx := (p.next + i) % n
+		b := p.backends[idx]
+		if tried[b] || !p.usableLocked(b, now) {
+			continue
+		}
+		if p.Policy == RoundRobin {
+			p.next = (idx + 1) % n
+			best = b
+			break
+		}
+		if best == nil || b.active < best.active {
+			best = b
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	if p.Policy == LeastConn {
+		p.next = (p.next + 1) % n
+	}
+	best.active++
+	return best
+}
+
+// usableLocked reports whether b may receive requests at now,
+// reinstating it if its passive ejection has timed out.
+func (p *BackendPool) usableLocked(b *backend, now time.Time) bool {
+	if !b.ejected {
+		return true
+	}
+	if p.HealthCheckPath == "" && now.Sub(b.ejectedAt) >= p.failTimeout() {
+		b.ejected = false
+		b.fails = 0
+		return true
+	}
+	return false
+}
+
+// done records the end of a request in flight on b.
+func (p *BackendPool) done(b *backend) {
+	p.mu.Lock()
+	b.active--
+	p.mu.Unlock()
+}
+
+func (p *BackendPool) succeeded(b *backend) {
+	p.mu.Lock()
+	b.fails = 0
+	p.mu.Unlock()
+}
+
+func (p *BackendPool) failed(b *backend) {
+	p.mu.Lock()
+	b.fails++
+	if !b.ejected && b.fails >= p.maxFails() {
+		b.ejected = true
+		b.ejectedAt = time.Now()
+	}
+	p.mu.Unlock()
+}
+
+// backendBody is a response body that ends its request's time in
+// flight on Close.
+type backendBody struct {
+	io.ReadCloser
+	pool *BackendPool
+	b    *backend
+	once sync.Once
+}
+
+func (bb *backendBody) Close() error {
+	err := bb.ReadCloser.Close()
+	bb.once.Do(func() { bb.pool.done(bb.b) })
+	return err
+}
+
+// backendRWBody is a backendBody that is also writable.
+type backendRWBody struct {
+	*backendBody
+	w io.Writer
+}
+
+func (b backendRWBody) Write(p []byte) (int, error) { return b.w.Write(p) }
+
+func (p *BackendPool) startHealthChecks() {
+	for _, b := range p.backends {
+		p.wg.Add(1)
+		go p.healthLoop(b)
+	}
+}
+
+func (p *BackendPool) healthLoop(b *backend) {
+	defer p.wg.Done()
+	interval := p.HealthCheckInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-p.closec:
+			return
+		case <-t.C:
+		}
+		if p.checkHealth(b) {
+			p.mu.Lock()
+			b.ejected = false
+			b.fails = 0
+			p.mu.Unlock()
+		} else {
+			p.failed(b)
+		}
+	}
+}
+
+// checkHealth reports whether b answers its health check.
+func (p *BackendPool) checkHealth(b *backend) bool {
+	timeout := p.HealthCheckTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-p.closec:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	u := &url.URL{Path: p.HealthCheckPath}
+	rewriteURL(u, b.target)
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return false
+	}
+	res, err := p.transport().RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		return false
+	}
+	res.Body.Close()
+	return res.StatusCode >= 200 && res.StatusCode < 400
+}
@@ -0,0 +1,269 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Load balancing tests.
+
+package httputil
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// namedBackend starts a server that replies with its name and
+// whether its health check passes.
+func namedBackend(name string, healthy *bool, mu *sync.Mutex) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			mu.Lock()
+			ok := *healthy
+			mu.Unlock()
+			if !ok {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			return
+		}
+		io.WriteString(w, name)
+	}))
+}
+
+func mustURL(t *testing.T, s string) *url.URL {
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func getBody(t *testing.T, url string) string {
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return res.Status
+	}
+	return string(body)
+}
+
+func TestBackendPoolRoundRobin(t *testing.T) {
+	var mu sync.Mutex
+	healthy := true
+	a := namedBackend("a", &healthy, &mu)
+	defer a.Close()
+	b := namedBackend("b", &healthy, &mu)
+	defer b.Close()
+
+	pool := NewBackendPool(mustURL(t, a.URL), mustURL(t, b.URL))
+	defer pool.Close()
+	frontend := httptest.NewServer(NewBalancingReverseProxy(pool))
+	defer frontend.Close()
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		got = append(got, getBody(t, frontend.URL))
+	}
+	if g, w := strings.Join(got, ","), "a,b,a,b"; g != w {
+		t.Errorf("backends = %s; want %s", g, w)
+	}
+}
+
+func TestBackendPoolLeastConn(t *testing.T) {
+	unblock := make(chan bool)
+	started := make(chan bool)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- true
+		<-unblock
+		io.WriteString(w, "slow")
+	}))
+	defer slow.Close()
+	var mu sync.Mutex
+	healthy := true
+	fast := namedBackend("fast", &healthy, &mu)
+	defer fast.Close()
+
+	pool := NewBackendPool(mustURL(t, slow.URL), mustURL(t, fast.URL))
+	pool.Policy = LeastConn
+	defer pool.Close()
+	frontend := httptest.NewServer(NewBalancingReverseProxy(pool))
+	defer frontend.Close()
+
+	done := make(chan string)
+	go func() { done <- getBody(t, frontend.URL) }()
+	<-started
+
+	// With a request in flight on the slow backend, every new
+	// request goes to the fast one.
+	for i := 0; i < 3; i++ {
+		if got := getBody(t, frontend.URL); got != "fast" {
+			t.Errorf("request %d went to %q; want fast", i, got)
+		}
+	}
+	close(unblock)
+	if got := <-done; got != "slow" {
+		t.Errorf("first request went to %q; want slow", got)
+	}
+}
+
+func TestBackendPoolRetry(t *testing.T) {
+	var mu sync.Mutex
+	healthy := true
+	live := namedBackend("live", &healthy, &mu)
+	defer live.Close()
+	dead := httptest.NewServer(http.NotFoundHandler())
+	deadURL := mustURL(t, dead.URL)
+	dead.Close()
+
+	pool := NewBackendPool(deadURL, mustURL(t, live.URL))
+	pool.FailTimeout = time.Hour
+	defer pool.Close()
+	frontend := httptest.NewServer(NewBalancingReverseProxy(pool))
+	defer frontend.Close()
+
+	if got := getBody(t, frontend.URL); got != "live" {
+		t.Errorf("GET = %q; want it retried on live", got)
+	}
+	if h := pool.Healthy(); len(h) != 1 || h[0].String() != live.URL {
+		t.Errorf("healthy backends = %v; want only %s", h, live.URL)
+	}
+
+	// The dead backend is ejected, so a POST goes to live.
+	res, err := http.Post(frontend.URL, "text/plain", strings.NewReader("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("POST status = %d; want 200", res.StatusCode)
+	}
+
+	if canRetry(&http.Request{Method: "POST"}) {
+		t.Error("canRetry allowed a POST")
+	}
+	if !canRetry(&http.Request{Method: "PUT"}) {
+		t.Error("canRetry refused a PUT without a body")
+	}
+	body := ioutil.NopCloser(strings.NewReader("x"))
+	if canRetry(&http.Request{Method: "PUT", Body: body, ContentLength: 1}) {
+		t.Error("canRetry allowed a PUT with a body")
+	}
+	if canRetry(&http.Request{Method: "PUT", Body: body, ContentLength: -1, TransferEncoding: []string{"chunked"}}) {
+		t.Error("canRetry allowed a PUT with a chunked body")
+	}
+
+	// A failed attempt closes the body of a PUT, so it is not
+	// retried on live.
+	pool = NewBackendPool(deadURL, mustURL(t, live.URL))
+	pool.FailTimeout = time.Hour
+	defer pool.Close()
+	frontend = httptest.NewServer(NewBalancingReverseProxy(pool))
+	defer frontend.Close()
+	req, err := http.NewRequest("PUT", frontend.URL, strings.NewReader("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadGateway {
+		t.Errorf("PUT with body status = %d; want %d", res.StatusCode, http.StatusBadGateway)
+	}
+}
+
+func TestBackendPoolHealthCheck(t *testing.T) {
+	var mu sync.Mutex
+	aHealthy, bHealthy := true, true
+	a := namedBackend("a", &aHealthy, &mu)
+	defer a.Close()
+	b := namedBackend("b", &bHealthy, &mu)
+	defer b.Close()
+
+	pool := NewBackendPool(mustURL(t, a.URL), mustURL(t, b.URL))
+	pool.HealthCheckPath = "/healthz"
+	pool.HealthCheckInterval = 10 * time.Millisecond
+	defer pool.Close()
+	frontend := httptest.NewServer(NewBalancingReverseProxy(pool))
+	defer frontend.Close()
+
+	getBody(t, frontend.URL) // starts the health checks
+
+	waitHealthy := func(want int) {
+		deadline := time.Now().Add(5 * time.Second)
+		for len(pool.Healthy()) != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("timeout waiting for %d healthy backends; have %v", want, pool.Healthy())
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	mu.Lock()
+	bHealthy = false
+	mu.Unlock()
+	waitHealthy(1)
+	for i := 0; i < 3; i++ {
+		if got := getBody(t, frontend.URL); got != "a" {
+			t.Errorf("request went to %q with b ejected", got)
+		}
+	}
+
+	mu.Lock()
+	bHealthy = true
+	mu.Unlock()
+	waitHealthy(2)
+}
+
+func TestBackendPoolNoBackend(t *testing.T) {
+	dead := httptest.NewServer(http.NotFoundHandler())
+	deadURL := mustURL(t, dead.URL)
+	dead.Close()
+
+	pool := NewBackendPool(deadURL)
+	pool.FailTimeout = time.Hour
+	defer pool.Close()
+	rpxy := NewBalancingReverseProxy(pool)
+	errc := make(chan error, 2)
+	rpxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		errc <- err
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	frontend := httptest.NewServer(rpxy)
+	defer frontend.Close()
+
+	for i := 0; i < 2; i++ {
+		if got := getBody(t, frontend.URL); !strings.HasPrefix(got, "502") {
+			t.Errorf("request %d: got %q; want 502", i, got)
+		}
+	}
+	<-errc
+	if err := <-errc; err != errNoBackend {
+		t.Errorf("error with all backends ejected = %v; want %v", err, errNoBackend)
+	}
+}
+
+func TestBackendPoolUpgrade(t *testing.T) {
+	backend := httptest.NewServer(upgradeEchoHandler)
+	defer backend.Close()
+
+	pool := NewBackendPool(mustURL(t, backend.URL))
+	defer pool.Close()
+	frontend := httptest.NewServer(NewBalancingReverseProxy(pool))
+	defer frontend.Close()
+
+	checkEchoUpgrade(t, frontend.Listener.Addr().String())
+}
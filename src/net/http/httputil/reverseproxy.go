@@ -7,6 +7,8 @@
 package httputil
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net"
@@ -46,6 +48,18 @@ type ReverseProxy struct {
 	// If nil, logging goes to os.Stderr via the log package's
 	// standard logger.
 	ErrorLog *log.Logger
+
+	// ModifyResponse is an optional function that modifies the
+	// response from the backend before it is copied to the client.
+	// If it returns an error, the response body is closed and
+	// ErrorHandler is called with the error.
+	ModifyResponse func(*http.Response) error
+
+	// ErrorHandler is an optional function that handles errors
+	// reaching the backend or errors from ModifyResponse.
+	// If nil, the error is logged and the client gets a
+	// 502 Bad Gateway response.
+	ErrorHandler func(http.ResponseWriter, *http.Request, error)
 }
 
 func singleJoiningSlash(a, b string) string {
@@ -65,20 +79,24 @@ func singleJoiningSlash(a, b string) string {
 // target's path is "/base" and the incoming request was for "/dir",
 // the target request will be for /base/dir.
 func NewSingleHostReverseProxy(target *url.URL) *ReverseProxy {
-	targetQuery := target.RawQuery
 	director := func(req *http.Request) {
-		req.URL.Scheme = target.Scheme
-		req.URL.Host = target.Host
-		req.URL.Path = singleJoiningSlash(target.Path, req.URL.Path)
-		if targetQuery == "" || req.URL.RawQuery == "" {
-			req.URL.RawQuery = targetQuery + req.URL.RawQuery
-		} else {
-			req.URL.RawQuery = targetQuery + "&" + req.URL.RawQuery
-		}
+		rewriteURL(req.URL, target)
 	}
 	return &ReverseProxy{Director: director}
 }
 
+// rewriteURL points u at target, joining their paths and queries.
+func rewriteURL(u, target *url.URL) {
+	u.Scheme = target.Scheme
+	u.Host = target.Host
+	u.Path = singleJoiningSlash(target.Path, u.Path)
+	if target.RawQuery == "" || u.RawQuery == "" {
+		u.RawQuery = target.RawQuery + u.RawQuery
+	} else {
+		u.RawQuery = target.RawQuery + "&" + u.RawQuery
+	}
+}
+
 func copyHeader(dst, src http.Header) {
 	for k, vv := range src {
 		for _, v := range vv {
@@ -127,7 +145,11 @@ func (p *ReverseProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	outreq := new(http.Request)
 	*outreq = *req // includes shallow copies of maps, but okay
 
-	if closeNotifier, ok := rw.(http.CloseNotifier); ok {
+	// Watching for the client going away through CloseNotifier
+	// would rule out hijacking its connection after a protocol
+	// switch. An upgrade is canceled through the request's context.
+	reqUpType := upgradeType(req.Header)
+	if closeNotifier, ok := rw.(http.CloseNotifier); ok && reqUpType == "" {
 		if requestCanceler, ok := transport.(requestCanceler); ok {
 			reqDone := make(chan struct{})
 			defer close(reqDone)
@@ -178,6 +200,14 @@ func (p *ReverseProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		}
 	}
 
+	// An upgrade request is passed on as one, so that the backend
+	// can switch protocols with the client. The headers were
+	// copied above, since Upgrade is a hop-by-hop header.
+	if reqUpType != "" {
+		outreq.Header.Set("Connection", "Upgrade")
+		outreq.Header.Set("Upgrade", reqUpType)
+	}
+
 	if clientIP, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
 		// If we aren't the first proxy retain prior
 		// X-Forwarded-For information as a comma+space
@@ -190,8 +220,12 @@ func (p *ReverseProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 
 	res, err := transport.RoundTrip(outreq)
 	if err != nil {
-		p.logf("http: proxy error: %v", err)
-		rw.WriteHeader(http.StatusInternalServerError)
+		p.handleError(rw, outreq, err)
+		return
+	}
+
+	if res.StatusCode == http.StatusSwitchingProtocols {
+		p.handleUpgradeResponse(rw, outreq, reqUpType, res)
 		return
 	}
 	defer res.Body.Close()
@@ -200,12 +234,107 @@ func (p *ReverseProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		res.Header.Del(h)
 	}
 
+	if !p.modifyResponse(rw, outreq, res) {
+		return
+	}
+
 	copyHeader(rw.Header(), res.Header)
 
 	rw.WriteHeader(res.StatusCode)
 	p.copyResponse(rw, res.Body)
 }
 
+// modifyResponse runs ModifyResponse, if any, on res. If that fails,
+// it handles the error and reports false.
+func (p *ReverseProxy) modifyResponse(rw http.ResponseWriter, req *http.Request, res *http.Response) bool {
+	if p.ModifyResponse == nil {
+		return true
+	}
+	if err := p.ModifyResponse(res); err != nil {
+		p.handleError(rw, req, err)
+		return false
+	}
+	return true
+}
+
+// handleUpgradeResponse completes a protocol switch: it forwards the
+// backend's 101 response to the client and then copies data between
+// the client's connection and the backend's until either side is done
+// or req's context is.
+func (p *ReverseProxy) handleUpgradeResponse(rw http.ResponseWriter, req *http.Request, reqUpType string, res *http.Response) {
+	defer res.Body.Close()
+	resUpType := upgradeType(res.Header)
+	if reqUpType == "" || !strings.EqualFold(reqUpType, resUpType) {
+		p.handleError(rw, req, fmt.Errorf("backend tried to switch protocol %q when %q was requested", resUpType, reqUpType))
+		return
+	}
+	backConn, ok := res.Body.(io.ReadWriteCloser)
+	if !ok {
+		p.handleError(rw, req, errors.New("httputil: 101 Switching Protocols response with non-writable body"))
+		return
+	}
+	hj, ok := rw.(http.Hijacker)
+	if !ok {
+		p.handleError(rw, req, fmt.Errorf("httputil: can't switch protocols using non-Hijacker ResponseWriter type %T", rw))
+		return
+	}
+	if !p.modifyResponse(rw, req, res) {
+		return
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		p.handleError(rw, req, fmt.Errorf("httputil: Hijack failed on protocol switch: %v", err))
+		return
+	}
+	defer conn.Close()
+
+	res.Body = nil // only the status line and headers are written
+	if err := res.Write(brw); err != nil {
+		p.logf("httputil: response write: %v", err)
+		return
+	}
+	if err := brw.Flush(); err != nil {
+		p.logf("httputil: response flush: %v", err)
+		return
+	}
+
+	errc := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(backConn, brw)
+		errc <- err
+	}()
+	go func() {
+		_, err := io.Copy(conn, backConn)
+		errc <- err
+	}()
+	select {
+	case <-errc:
+	case <-req.Context().Done():
+	}
+}
+
+// upgradeType returns the protocol named by the Upgrade header of h
+// if its Connection header asks for an upgrade, or "" otherwise.
+func upgradeType(h http.Header) string {
+	for _, v := range h["Connection"] {
+		for _, tok := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(tok), "upgrade") {
+				return h.Get("Upgrade")
+			}
+		}
+	}
+	return ""
+}
+
+func (p *ReverseProxy) handleError(rw http.ResponseWriter, req *http.Request, err error) {
+	if p.ErrorHandler != nil {
+		p.ErrorHandler(rw, req, err)
+		return
+	}
+	p.logf("http: proxy error: %v", err)
+	rw.WriteHeader(http.StatusBadGateway)
+}
+
 func (p *ReverseProxy) copyResponse(dst io.Writer, src io.Reader) {
 	if p.FlushInterval != 0 {
 		if wf, ok := dst.(writeFlusher); ok {
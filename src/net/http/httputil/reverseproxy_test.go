@@ -7,8 +7,14 @@
 package httputil
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -271,3 +277,193 @@ func TestReverseProxyCancellation(t *testing.T) {
 		t.Fatal("DefaultClient.Do() returned nil error")
 	}
 }
+
+func TestReverseProxyModifyResponse(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Hit-Mod", fmt.Sprint(r.URL.Path == "/mod"))
+		w.Write([]byte("hi"))
+	}))
+	defer backend.Close()
+
+	backendURL, _ := url.Parse(backend.URL)
+	rpxy := NewSingleHostReverseProxy(backendURL)
+	rpxy.ErrorLog = log.New(ioutil.Discard, "", 0)
+	rpxy.ModifyResponse = func(res *http.Response) error {
+		if res.Header.Get("X-Hit-Mod") != "true" {
+			return errors.New("not allowed")
+		}
+		res.Header.Set("X-Modified", "yes")
+		return nil
+	}
+	frontend := httptest.NewServer(rpxy)
+	defer frontend.Close()
+
+	tests := []struct {
+		path     string
+		status   int
+		modified string
+	}{
+		{"/mod", http.StatusOK, "yes"},
+		{"/schedule", http.StatusBadGateway, ""},
+	}
+	for _, tt := range tests {
+		res, err := http.Get(frontend.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != tt.status {
+			t.Errorf("%s: status = %d; want %d", tt.path, res.StatusCode, tt.status)
+		}
+		if got := res.Header.Get("X-Modified"); got != tt.modified {
+			t.Errorf("%s: X-Modified = %q; want %q", tt.path, got, tt.modified)
+		}
+	}
+}
+
+func TestReverseProxyErrorHandler(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	backendURL, _ := url.Parse(backend.URL)
+	backend.Close() // nothing listens there any more
+
+	rpxy := NewSingleHostReverseProxy(backendURL)
+	rpxy.ErrorLog = log.New(ioutil.Discard, "", 0)
+	frontend := httptest.NewServer(rpxy)
+	defer frontend.Close()
+
+	res, err := http.Get(frontend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadGateway {
+		t.Errorf("default error status = %d; want %d", res.StatusCode, http.StatusBadGateway)
+	}
+
+	var handlerErr error
+	rpxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		handlerErr = err
+		w.WriteHeader(http.StatusTeapot)
+		io.WriteString(w, "custom")
+	}
+	res, err = http.Get(frontend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusTeapot || string(body) != "custom" {
+		t.Errorf("got %d %q; want %d %q", res.StatusCode, body, http.StatusTeapot, "custom")
+	}
+	if handlerErr == nil {
+		t.Error("ErrorHandler called with nil error")
+	}
+}
+
+// upgradeEchoHandler switches to the "echo" protocol and then
+// writes back each line it reads, upper-cased.
+var upgradeEchoHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if upgradeType(r.Header) != "echo" {
+		http.Error(w, "want an echo upgrade", http.StatusBadRequest)
+		return
+	}
+	conn, brw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	io.WriteString(brw, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+	brw.Flush()
+	for {
+		line, err := brw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		io.WriteString(brw, strings.ToUpper(line))
+		brw.Flush()
+	}
+})
+
+func TestReverseProxyWebSocket(t *testing.T) {
+	backend := httptest.NewServer(upgradeEchoHandler)
+	defer backend.Close()
+	backendURL, _ := url.Parse(backend.URL)
+	rpxy := NewSingleHostReverseProxy(backendURL)
+	rpxy.ErrorLog = log.New(ioutil.Discard, "", 0)
+	frontend := httptest.NewServer(rpxy)
+	defer frontend.Close()
+
+	checkEchoUpgrade(t, frontend.Listener.Addr().String())
+}
+
+func TestReverseProxyUpgradeContext(t *testing.T) {
+	backend := httptest.NewServer(upgradeEchoHandler)
+	defer backend.Close()
+	backendURL, _ := url.Parse(backend.URL)
+	rpxy := NewSingleHostReverseProxy(backendURL)
+	rpxy.ErrorLog = log.New(ioutil.Discard, "", 0)
+	frontend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 200*time.Millisecond)
+		defer cancel()
+		rpxy.ServeHTTP(w, r.WithContext(ctx))
+	}))
+	defer frontend.Close()
+
+	c, err := net.Dial("tcp", frontend.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(10 * time.Second))
+	io.WriteString(c, "GET / HTTP/1.1\r\nHost: example.com\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+	br := bufio.NewReader(c)
+	res, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d; want 101", res.StatusCode)
+	}
+	io.WriteString(c, "hello\n")
+	if got, err := br.ReadString('\n'); got != "HELLO\n" || err != nil {
+		t.Fatalf("echo = %q, %v; want %q", got, err, "HELLO\n")
+	}
+
+	// The tunnel is closed once the request's context is done.
+	if _, err := br.ReadByte(); err != io.EOF {
+		t.Errorf("read after context deadline = %v; want EOF", err)
+	}
+}
+
+// checkEchoUpgrade switches a connection to addr to the protocol of
+// upgradeEchoHandler and checks that lines are echoed.
+func checkEchoUpgrade(t *testing.T, addr string) {
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(10 * time.Second))
+	io.WriteString(c, "GET / HTTP/1.1\r\nHost: example.com\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+	br := bufio.NewReader(c)
+	res, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d; want 101", res.StatusCode)
+	}
+	if got := upgradeType(res.Header); got != "echo" {
+		t.Fatalf("upgrade type = %q; want echo", got)
+	}
+	for _, msg := range []string{"hello\n", "world\n"} {
+		io.WriteString(c, msg)
+		got, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := strings.ToUpper(msg); got != want {
+			t.Errorf("echo = %q; want %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package hpack implements HPACK, the header compression format used
+// by HTTP/2, as defined in RFC 7541.
+package hpack
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A HeaderField is a name-value pair. Both the name and value are
+// treated as opaque sequences of octets.
+type HeaderField struct {
+	Name, Value string
+
+	// Sensitive means that this header field should never be
+	// indexed.
+	Sensitive bool
+}
+
+// Size returns the size of an entry per RFC 7541 section 4.1.
+func (hf HeaderField) Size() uint32 {
+	return uint32(len(hf.Name) + len(hf.Value) + 32)
+}
+
+func (hf HeaderField) String() string {
+	var suffix string
+	if hf.Sensitive {
+		suffix = " (sensitive)"
+	}
+	return fmt.Sprintf("header field %q = %q%s", hf.Name, hf.Value, suffix)
+}
+
+// A DecodingError is something the spec defines as a decoding error.
+type DecodingError struct {
+	Err error
+}
+
+func (de DecodingError) Error() string {
+	return fmt.Sprintf("decoding error: %v", de.Err)
+}
+
+// An InvalidIndexError is returned when an encoder references a table
+// entry before the static table or after the end of the dynamic table.
+type InvalidIndexError int
+
+func (e InvalidIndexError) Error() string {
+	return fmt.Sprintf("invalid indexed representation index %d", int(e))
+}
+
+var (
+	errNeedMore            = errors.New("need more data")
+	errVarintOverflow      = DecodingError{errors.New("varint integer overflow")}
+	errStringLength        = DecodingError{errors.New("string length exceeds limit")}
+	errTableSizeUpdate     = DecodingError{errors.New("dynamic table size update too large")}
+	errTableSizeNotAtStart = DecodingError{errors.New("dynamic table size update after header field")}
+)
+
+// dynamicTable is the dynamic table of RFC 7541 section 2.3.2.
+// New entries are appended to ents, so the most recently added
+// entry (HPACK index 1 within the dynamic table) is last.
+type dynamicTable struct {
+	ents    []HeaderField
+	size    uint32
+	maxSize uint32 // current maximum, as set by the peer
+}
+
+func (dt *dynamicTable) setMaxSize(v uint32) {
+	dt.maxSize = v
+	dt.evict()
+}
+
+func (dt *dynamicTable) add(f HeaderField) {
+	dt.ents = append(dt.ents, f)
+	dt.size += f.Size()
+	dt.evict()
+}
+
+// evict removes entries from the table until its size is within
+// maxSize.
+func (dt *dynamicTable) evict() {
+	n := 0
+	for dt.size > dt.maxSize && n < len(dt.ents) {
+		dt.size -= dt.ents[n].Size()
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	copy(dt.ents, dt.ents[n:])
+	for k := len(dt.ents) - n; k < len(dt.ents); k++ {
+		dt.ents[k] = HeaderField{} // so strings can be garbage collected
+	}
+	dt.ents = dt.ents[:len(dt.ents)-n]
+}
+
+// search looks for f in the dynamic table. It returns the HPACK
+// index (relative to the start of the dynamic table, 1-based) of an
+// exact match, or failing that of an entry with the same name, or 0.
+func (dt *dynamicTable) search(f HeaderField) (i uint64, nameValueMatch bool) {
+	for k := len(dt.ents) - 1; k >= 0; k-- {
+		e := dt.ents[k]
+		if e.Name != f.Name {
+			continue
+		}
+		idx := uint64(len(dt.ents) - k)
+		if e.Value == f.Value && !f.Sensitive {
+			return idx, true
+		}
+		if i == 0 {
+			i = idx
+		}
+	}
+	return i, false
+}
+
+// searchStatic is like dynamicTable.search, but for the static table.
+func searchStatic(f HeaderField) (i uint64, nameValueMatch bool) {
+	for k, e := range staticTable {
+		if e.Name != f.Name {
+			continue
+		}
+		if e.Value == f.Value && !f.Sensitive {
+			return uint64(k + 1), true
+		}
+		if i == 0 {
+			i = uint64(k + 1)
+		}
+	}
+	return i, false
+}
+
+// A Decoder decodes HPACK header blocks. A Decoder holds the state
+// of the dynamic table for one direction of an HTTP/2 connection, so
+// all header blocks received on that connection must be passed to the
+// same Decoder, in order.
+type Decoder struct {
+	dynTab dynamicTable
+
+	// maxAllowedSize is the upper bound for dynamic table size
+	// updates, as advertised to the peer with SETTINGS_HEADER_TABLE_SIZE.
+	maxAllowedSize uint32
+
+	// maxStrLen is the maximum length of any decoded string, or
+	// 0 for no limit.
+	maxStrLen int
+}
+
+// NewDecoder returns a new decoder with the provided maximum dynamic
+// table size.
+func NewDecoder(maxDynamicTableSize uint32) *Decoder {
+	d := &Decoder{maxAllowedSize: maxDynamicTableSize}
+	d.dynTab.maxSize = maxDynamicTableSize
+	return d
+}
+
+// SetMaxStringLength sets the maximum size of a HeaderField name or
+// value string. If a string exceeds this length, DecodeFull returns
+// an error. A value of 0 means unlimited.
+func (d *Decoder) SetMaxStringLength(n int) {
+	d.maxStrLen = n
+}
+
+// SetAllowedMaxDynamicTableSize sets the upper bound that the encoded
+// stream (via dynamic table size updates) may set the maximum size to.
+func (d *Decoder) SetAllowedMaxDynamicTableSize(v uint32) {
+	d.maxAllowedSize = v
+}
+
+func (d *Decoder) at(i uint64) (hf HeaderField, ok bool) {
+	if i == 0 {
+		return
+	}
+	if i <= uint64(len(staticTable)) {
+		return staticTable[i-1], true
+	}
+	dents := d.dynTab.ents
+	di := i - uint64(len(staticTable))
+	if di > uint64(len(dents)) {
+		return
+	}
+	return dents[len(dents)-int(di)], true
+}
+
+// DecodeFull decodes an entire header block, which must be complete,
+// and returns its header fields in order.
+func (d *Decoder) DecodeFull(p []byte) ([]HeaderField, error) {
+	var hf []HeaderField
+	sawField := false
+	for len(p) > 0 {
+		b := p[0]
+		var (
+			f   HeaderField
+			err error
+		)
+		switch {
+		case b&128 != 0:
+			// Indexed representation. RFC 7541 section 6.1.
+			var idx uint64
+			idx, p, err = readVarInt(7, p)
+			if err != nil {
+				return nil, d.fixErr(err)
+			}
+			var ok bool
+			f, ok = d.at(idx)
+			if !ok {
+				return nil, DecodingError{InvalidIndexError(idx)}
+			}
+		case b&192 == 64:
+			// Literal with incremental indexing. Section 6.2.1.
+			f, p, err = d.parseLiteral(6, p)
+			if err != nil {
+				return nil, err
+			}
+			d.dynTab.add(f)
+		case b&240 == 0, b&240 == 16:
+			// Literal without indexing (section 6.2.2) or never
+			// indexed (section 6.2.3).
+			f, p, err = d.parseLiteral(4, p)
+			if err != nil {
+				return nil, err
+			}
+			f.Sensitive = b&240 == 16
+		case b&224 == 32:
+			// Dynamic table size update. Section 6.3.
+			if sawField {
+				return nil, errTableSizeNotAtStart
+			}
+			var size uint64
+			size, p, err = readVarInt(5, p)
+			if err != nil {
+				return nil, d.fixErr(err)
+			}
+			if size > uint64(d.maxAllowedSize) {
+				return nil, errTableSizeUpdate
+			}
+			d.dynTab.setMaxSize(uint32(size))
+			continue
+		default:
+			return nil, DecodingError{errors.New("invalid encoding")}
+		}
+		sawField = true
+		hf = append(hf, f)
+	}
+	return hf, nil
+}
+
+// fixErr converts an errNeedMore, which in a complete header block
+// means the block was truncated, into a DecodingError.
+func (d *Decoder) fixErr(err error) error {
+	if err == errNeedMore {
+		return DecodingError{errors.New("truncated headers")}
+	}
+	return err
+}
+
+// parseLiteral parses a literal header field representation whose
+// index is encoded with an n-bit prefix.
+func (d *Decoder) parseLiteral(n byte, p []byte) (f HeaderField, rest []byte, err error) {
+	nameIdx, p, err := readVarInt(n, p)
+	if err != nil {
+		return f, nil, d.fixErr(err)
+	}
+	if nameIdx > 0 {
+		ihf, ok := d.at(nameIdx)
+		if !ok {
+			return f, nil, DecodingError{InvalidIndexError(nameIdx)}
+		}
+		f.Name = ihf.Name
+	} else {
+		f.Name, p, err = d.readString(p)
+		if err != nil {
+			return f, nil, err
+		}
+	}
+	f.Value, p, err = d.readString(p)
+	if err != nil {
+		return f, nil, err
+	}
+	return f, p, nil
+}
+
+// readString decodes an HPACK string literal (RFC 7541 section 5.2)
+// from the beginning of p.
+func (d *Decoder) readString(p []byte) (s string, rest []byte, err error) {
+	if len(p) == 0 {
+		return "", p, d.fixErr(errNeedMore)
+	}
+	isHuff := p[0]&128 != 0
+	strLen, p, err := readVarInt(7, p)
+	if err != nil {
+		return "", p, d.fixErr(err)
+	}
+	if d.maxStrLen != 0 && strLen > uint64(d.maxStrLen) {
+		return "", nil, errStringLength
+	}
+	if uint64(len(p)) < strLen {
+		return "", p, d.fixErr(errNeedMore)
+	}
+	if !isHuff {
+		return string(p[:strLen]), p[strLen:], nil
+	}
+	s, err = huffmanDecodeString(p[:strLen], d.maxStrLen)
+	if err != nil {
+		return "", nil, err
+	}
+	return s, p[strLen:], nil
+}
+
+// readVarInt reads an unsigned variable length integer off the
+// beginning of p. n is the parameter as described in RFC 7541
+// section 5.1.
+//
+// n must always be between 1 and 8.
+//
+// The returned remain buffer is either a smaller suffix of p, or err
+// != nil. The error is errNeedMore if p doesn't contain a complete
+// integer.
+func readVarInt(n byte, p []byte) (i uint64, remain []byte, err error) {
+	if n < 1 || n > 8 {
+		panic("bad n")
+	}
+	if len(p) == 0 {
+		return 0, p, errNeedMore
+	}
+	i = uint64(p[0])
+	if n < 8 {
+		i &= (1 << uint64(n)) - 1
+	}
+	if i < (1<<uint64(n))-1 {
+		return i, p[1:], nil
+	}
+
+	origP := p
+	p = p[1:]
+	var m uint64
+	for len(p) > 0 {
+		b := p[0]
+		p = p[1:]
+		i += uint64(b&127) << m
+		if b&128 == 0 {
+			return i, p, nil
+		}
+		m += 7
+		if m >= 63 {
+			return 0, origP, errVarintOverflow
+		}
+	}
+	return 0, origP, errNeedMore
+}
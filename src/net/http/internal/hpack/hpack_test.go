@@ -0,0 +1,250 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hpack
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func dehex(s string) []byte {
+	s = strings.Replace(s, " ", "", -1)
+	s = strings.Replace(s, "\n", "", -1)
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+type encAndWant struct {
+	enc  []byte
+	want []HeaderField
+}
+
+// Requests from RFC 7541 Appendix C.3 (without Huffman coding) and
+// C.4 (with Huffman coding). Each sequence shares one dynamic table.
+var rfcRequestTests = []struct {
+	name string
+	reqs []encAndWant
+}{
+	{
+		"C.3",
+		[]encAndWant{
+			{
+				dehex("8286 8441 0f77 7777 2e65 7861 6d70 6c65 2e63 6f6d"),
+				[]HeaderField{
+					{Name: ":method", Value: "GET"},
+					{Name: ":scheme", Value: "http"},
+					{Name: ":path", Value: "/"},
+					{Name: ":authority", Value: "www.example.com"},
+				},
+			},
+			{
+				dehex("8286 84be 5808 6e6f 2d63 6163 6865"),
+				[]HeaderField{
+					{Name: ":method", Value: "GET"},
+					{Name: ":scheme", Value: "http"},
+					{Name: ":path", Value: "/"},
+					{Name: ":authority", Value: "www.example.com"},
+					{Name: "cache-control", Value: "no-cache"},
+				},
+			},
+			{
+				dehex("8287 85bf 400a 6375 7374 6f6d 2d6b 6579 0c63 7573 746f 6d2d 7661 6c75 65"),
+				[]HeaderField{
+					{Name: ":method", Value: "GET"},
+					{Name: ":scheme", Value: "https"},
+					{Name: ":path", Value: "/index.html"},
+					{Name: ":authority", Value: "www.example.com"},
+					{Name: "custom-key", Value: "custom-value"},
+				},
+			},
+		},
+	},
+	{
+		"C.4",
+		[]encAndWant{
+			{
+				dehex("8286 8441 8cf1 e3c2 e5f2 3a6b a0ab 90f4 ff"),
+				[]HeaderField{
+					{Name: ":method", Value: "GET"},
+					{Name: ":scheme", Value: "http"},
+					{Name: ":path", Value: "/"},
+					{Name: ":authority", Value: "www.example.com"},
+				},
+			},
+			{
+				dehex("8286 84be 5886 a8eb 1064 9cbf"),
+				[]HeaderField{
+					{Name: ":method", Value: "GET"},
+					{Name: ":scheme", Value: "http"},
+					{Name: ":path", Value: "/"},
+					{Name: ":authority", Value: "www.example.com"},
+					{Name: "cache-control", Value: "no-cache"},
+				},
+			},
+			{
+				dehex("8287 85bf 4088 25a8 49e9 5ba9 7d7f 8925 a849 e95b b8e8 b4bf"),
+				[]HeaderField{
+					{Name: ":method", Value: "GET"},
+					{Name: ":scheme", Value: "https"},
+					{Name: ":path", Value: "/index.html"},
+					{Name: ":authority", Value: "www.example.com"},
+					{Name: "custom-key", Value: "custom-value"},
+				},
+			},
+		},
+	},
+}
+
+func TestDecoderRFCExamples(t *testing.T) {
+	for _, tt := range rfcRequestTests {
+		d := NewDecoder(4096)
+		for i, req := range tt.reqs {
+			got, err := d.DecodeFull(req.enc)
+			if err != nil {
+				t.Errorf("%s request %d: %v", tt.name, i+1, err)
+				break
+			}
+			if !reflect.DeepEqual(got, req.want) {
+				t.Errorf("%s request %d:\n got %v\nwant %v", tt.name, i+1, got, req.want)
+			}
+		}
+	}
+}
+
+func TestEncoderRFCExamples(t *testing.T) {
+	// Our encoder uses Huffman coding whenever it's shorter, so
+	// it should reproduce the C.4 examples byte for byte.
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	for i, req := range rfcRequestTests[1].reqs {
+		buf.Reset()
+		for _, f := range req.want {
+			if err := e.WriteField(f); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !bytes.Equal(buf.Bytes(), req.enc) {
+			t.Errorf("request %d: encoded %x; want %x", i+1, buf.Bytes(), req.enc)
+		}
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	fields := []HeaderField{
+		{Name: ":status", Value: "302"},
+		{Name: "cache-control", Value: "private"},
+		{Name: "date", Value: "Mon, 21 Oct 2013 20:13:21 GMT"},
+		{Name: "location", Value: "https://www.example.com"},
+		{Name: "set-cookie", Value: "foo=ASDJKHQKBZXOQWEOPIUAXQWEOIU; max-age=3600; version=1", Sensitive: true},
+		{Name: "x-empty", Value: ""},
+		{Name: "x-binary", Value: "\x00\xff\x7f\x80"},
+	}
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	d := NewDecoder(4096)
+	for round := 0; round < 3; round++ {
+		buf.Reset()
+		if round == 1 {
+			e.SetMaxDynamicTableSize(64)
+		}
+		for _, f := range fields {
+			if err := e.WriteField(f); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := d.DecodeFull(buf.Bytes())
+		if err != nil {
+			t.Fatalf("round %d: %v", round, err)
+		}
+		if !reflect.DeepEqual(got, fields) {
+			t.Errorf("round %d:\n got %v\nwant %v", round, got, fields)
+		}
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		enc  []byte
+	}{
+		{"index 0", []byte{0x80}},
+		{"index past end", []byte{0xbe}},
+		{"truncated string", dehex("400a 6375 7374")},
+		{"truncated varint", []byte{0xff, 0x80}},
+		{"table size too large", dehex("3fe2 1f")},
+		{"bad huffman padding", dehex("4081 00 00")},
+	}
+	for _, tt := range tests {
+		d := NewDecoder(4096)
+		if _, err := d.DecodeFull(tt.enc); err == nil {
+			t.Errorf("%s: DecodeFull(%x) succeeded; want error", tt.name, tt.enc)
+		}
+	}
+}
+
+func TestDecoderMaxStringLength(t *testing.T) {
+	d := NewDecoder(4096)
+	d.SetMaxStringLength(3)
+	if _, err := d.DecodeFull(dehex("4003 666f 6f03 6261 72")); err != nil {
+		t.Errorf("3-byte strings: %v", err)
+	}
+	if _, err := d.DecodeFull(dehex("4004 666f 6f6f 0362 6172")); err != errStringLength {
+		t.Errorf("4-byte name: err = %v; want %v", err, errStringLength)
+	}
+}
+
+func TestHuffmanRoundTrip(t *testing.T) {
+	tests := []string{
+		"",
+		"a",
+		"www.example.com",
+		"no-cache",
+		"Mon, 21 Oct 2013 20:13:21 GMT",
+		"\x00\x01\xfe\xff",
+	}
+	for _, s := range tests {
+		enc := AppendHuffmanString(nil, s)
+		if uint64(len(enc)) != HuffmanEncodeLength(s) {
+			t.Errorf("%q: len(enc) = %d; HuffmanEncodeLength = %d", s, len(enc), HuffmanEncodeLength(s))
+		}
+		dec, err := HuffmanDecodeToString(enc)
+		if err != nil {
+			t.Errorf("%q: decode error: %v", s, err)
+			continue
+		}
+		if dec != s {
+			t.Errorf("decode(encode(%q)) = %q", s, dec)
+		}
+	}
+}
+
+func TestAppendVarInt(t *testing.T) {
+	tests := []struct {
+		n    byte
+		i    uint64
+		want []byte
+	}{
+		// RFC 7541 Appendix C.1.
+		{5, 10, []byte{10}},
+		{5, 1337, []byte{31, 154, 10}},
+		{8, 42, []byte{42}},
+	}
+	for _, tt := range tests {
+		got := appendVarInt(nil, tt.n, tt.i)
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("appendVarInt(%d, %d) = %v; want %v", tt.n, tt.i, got, tt.want)
+		}
+		i, rest, err := readVarInt(tt.n, got)
+		if err != nil || i != tt.i || len(rest) != 0 {
+			t.Errorf("readVarInt(%d, %v) = %d, %v, %v", tt.n, got, i, rest, err)
+		}
+	}
+}
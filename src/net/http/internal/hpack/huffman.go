@@ -0,0 +1,163 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hpack
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrInvalidHuffman is returned for errors found decoding
+// Huffman-encoded strings.
+var ErrInvalidHuffman = errors.New("hpack: invalid Huffman-encoded data")
+
+// HuffmanDecodeToString decodes the Huffman-encoded string in v.
+func HuffmanDecodeToString(v []byte) (string, error) {
+	return huffmanDecodeString(v, 0)
+}
+
+// huffmanDecodeString decodes v. If maxLen is greater than 0,
+// decoding more than maxLen bytes returns an error.
+func huffmanDecodeString(v []byte, maxLen int) (string, error) {
+	root := getRootHuffmanNode()
+	buf := make([]byte, 0, len(v)*8/5)
+	n := root
+	// cur is the bit buffer that has not been fed into n.
+	// cbits is the number of low order bits in cur that are valid.
+	// sbits is the number of bits of the symbol prefix being decoded.
+	cur, cbits, sbits := uint(0), uint8(0), uint8(0)
+	for _, b := range v {
+		cur = cur<<8 | uint(b)
+		cbits += 8
+		sbits += 8
+		for cbits >= 8 {
+			idx := byte(cur >> (cbits - 8))
+			n = n.children[idx]
+			if n == nil {
+				return "", ErrInvalidHuffman
+			}
+			if n.children == nil {
+				if maxLen != 0 && len(buf) == maxLen {
+					return "", errStringLength
+				}
+				buf = append(buf, n.sym)
+				cbits -= n.codeLen
+				n = root
+				sbits = cbits
+			} else {
+				cbits -= 8
+			}
+		}
+	}
+	for cbits > 0 {
+		n = n.children[byte(cur<<(8-cbits))]
+		if n == nil {
+			return "", ErrInvalidHuffman
+		}
+		if n.children != nil || n.codeLen > cbits {
+			break
+		}
+		if maxLen != 0 && len(buf) == maxLen {
+			return "", errStringLength
+		}
+		buf = append(buf, n.sym)
+		cbits -= n.codeLen
+		n = root
+		sbits = cbits
+	}
+	if sbits > 7 {
+		// Either there was an incomplete symbol, or overlong
+		// padding. Both are decoding errors per RFC 7541
+		// section 5.2.
+		return "", ErrInvalidHuffman
+	}
+	if mask := uint(1<<cbits - 1); cur&mask != mask {
+		// Trailing bits must be a prefix of EOS per RFC 7541
+		// section 5.2.
+		return "", ErrInvalidHuffman
+	}
+	return string(buf), nil
+}
+
+// A huffmanNode is either an internal node with 256 children
+// (indexed by the next eight bits of input) or a leaf.
+type huffmanNode struct {
+	// children is non-nil for internal nodes.
+	children *[256]*huffmanNode
+
+	// The following are only valid if children is nil:
+	codeLen uint8 // number of bits that led to the output of sym
+	sym     byte  // output symbol
+}
+
+func newInternalNode() *huffmanNode {
+	return &huffmanNode{children: new([256]*huffmanNode)}
+}
+
+var (
+	buildRootOnce       sync.Once
+	lazyRootHuffmanNode *huffmanNode
+)
+
+func getRootHuffmanNode() *huffmanNode {
+	buildRootOnce.Do(buildRootHuffmanNode)
+	return lazyRootHuffmanNode
+}
+
+func buildRootHuffmanNode() {
+	lazyRootHuffmanNode = newInternalNode()
+	for i, code := range huffmanCodes {
+		addDecoderNode(byte(i), code, huffmanCodeLen[i])
+	}
+}
+
+func addDecoderNode(sym byte, code uint32, codeLen uint8) {
+	cur := lazyRootHuffmanNode
+	for codeLen > 8 {
+		codeLen -= 8
+		i := uint8(code >> codeLen)
+		if cur.children[i] == nil {
+			cur.children[i] = newInternalNode()
+		}
+		cur = cur.children[i]
+	}
+	shift := 8 - codeLen
+	start, end := int(uint8(code<<shift)), int(1<<shift)
+	for i := start; i < start+end; i++ {
+		cur.children[i] = &huffmanNode{sym: sym, codeLen: codeLen}
+	}
+}
+
+// AppendHuffmanString appends s, as encoded in Huffman codes, to dst
+// and returns the extended buffer.
+func AppendHuffmanString(dst []byte, s string) []byte {
+	var x uint64 // pending code bits, in the low n bits
+	var n uint   // number of pending bits in x
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		x = x<<huffmanCodeLen[c] | uint64(huffmanCodes[c])
+		n += uint(huffmanCodeLen[c])
+		for n >= 8 {
+			n -= 8
+			dst = append(dst, byte(x>>n))
+		}
+	}
+	if n > 0 {
+		// Pad with the most significant bits of EOS, which
+		// are all ones.
+		dst = append(dst, byte(x<<(8-n))|0xff>>n)
+	}
+	return dst
+}
+
+// HuffmanEncodeLength returns the number of bytes required to encode
+// s in Huffman codes. The result is rounded up to a byte boundary.
+func HuffmanEncodeLength(s string) uint64 {
+	n := uint64(0)
+	for i := 0; i < len(s); i++ {
+		n += uint64(huffmanCodeLen[s[i]])
+	}
+	return (n + 7) / 8
+}
@@ -0,0 +1,811 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http2
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const frameHeaderLen = 9
+
+// A FrameType is a registered frame type as defined in
+// http://http2.github.io/http2-spec/#rfc.section.11.2
+type FrameType uint8
+
+const (
+	FrameData         FrameType = 0x0
+	FrameHeaders      FrameType = 0x1
+	FramePriority     FrameType = 0x2
+	FrameRSTStream    FrameType = 0x3
+	FrameSettings     FrameType = 0x4
+	FramePushPromise  FrameType = 0x5
+	FramePing         FrameType = 0x6
+	FrameGoAway       FrameType = 0x7
+	FrameWindowUpdate FrameType = 0x8
+	FrameContinuation FrameType = 0x9
+)
+
+var frameName = map[FrameType]string{
+	FrameData:         "DATA",
+	FrameHeaders:      "HEADERS",
+	FramePriority:     "PRIORITY",
+	FrameRSTStream:    "RST_STREAM",
+	FrameSettings:     "SETTINGS",
+	FramePushPromise:  "PUSH_PROMISE",
+	FramePing:         "PING",
+	FrameGoAway:       "GOAWAY",
+	FrameWindowUpdate: "WINDOW_UPDATE",
+	FrameContinuation: "CONTINUATION",
+}
+
+func (t FrameType) String() string {
+	if s, ok := frameName[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("UNKNOWN_FRAME_TYPE_%d", uint8(t))
+}
+
+// Flags is a bitmask of HTTP/2 flags.
+// The meaning of flags varies depending on the frame type.
+type Flags uint8
+
+// Has reports whether f contains all (0 or more) flags in v.
+func (f Flags) Has(v Flags) bool {
+	return (f & v) == v
+}
+
+// Frame-specific FrameHeader flag bits.
+const (
+	// Data Frame
+	FlagDataEndStream Flags = 0x1
+	FlagDataPadded    Flags = 0x8
+
+	// Headers Frame
+	FlagHeadersEndStream  Flags = 0x1
+	FlagHeadersEndHeaders Flags = 0x4
+	FlagHeadersPadded     Flags = 0x8
+	FlagHeadersPriority   Flags = 0x20
+
+	// Settings Frame
+	FlagSettingsAck Flags = 0x1
+
+	// Ping Frame
+	FlagPingAck Flags = 0x1
+
+	// Continuation Frame
+	FlagContinuationEndHeaders Flags = 0x4
+
+	FlagPushPromiseEndHeaders Flags = 0x4
+	FlagPushPromisePadded     Flags = 0x8
+)
+
+// A FrameHeader is the 9 byte header of all HTTP/2 frames.
+//
+// See http://http2.github.io/http2-spec/#FrameHeader
+type FrameHeader struct {
+	// Type is the 1 byte frame type. There are ten standard frame
+	// types, but extension frame types may be written by WriteRawFrame
+	// and will be returned by ReadFrame (as UnknownFrame).
+	Type FrameType
+
+	// Flags are the 1 byte of 8 potential bit flags per frame.
+	// They are specific to the frame type.
+	Flags Flags
+
+	// Length is the length of the frame, not including the 9 byte header.
+	// The maximum size is one byte less than 16MB (uint24), but only
+	// frames up to 16KB are allowed without peer agreement.
+	Length uint32
+
+	// StreamID is which stream this frame is for. Certain frames
+	// are not stream-specific, in which case this field is 0.
+	StreamID uint32
+}
+
+// Header returns h. It exists so FrameHeaders can be embedded in other
+// specific frame types and implement the Frame interface.
+func (h FrameHeader) Header() FrameHeader { return h }
+
+func (h FrameHeader) String() string {
+	return fmt.Sprintf("[FrameHeader %v flags=0x%x stream=%d len=%d]", h.Type, uint8(h.Flags), h.StreamID, h.Length)
+}
+
+// A Frame is the base interface implemented by all frame types.
+// Callers will generally type-assert the specific frame type:
+// *HeadersFrame, *SettingsFrame, *WindowUpdateFrame, etc.
+//
+// Frames are only valid until the next call to Framer.ReadFrame.
+type Frame interface {
+	Header() FrameHeader
+}
+
+// A DataFrame conveys arbitrary, variable-length sequences of octets
+// associated with a stream.
+// See http://http2.github.io/http2-spec/#rfc.section.6.1
+type DataFrame struct {
+	FrameHeader
+	data []byte
+}
+
+// StreamEnded reports whether the END_STREAM flag is set.
+func (f *DataFrame) StreamEnded() bool {
+	return f.Flags.Has(FlagDataEndStream)
+}
+
+// Data returns the frame's data octets, not including any padding
+// size byte or padding suffix bytes.
+// The caller must not retain the returned memory past the next
+// call to ReadFrame.
+func (f *DataFrame) Data() []byte {
+	return f.data
+}
+
+// A HeadersFrame is used to open a stream and additionally carries a
+// header block fragment.
+type HeadersFrame struct {
+	FrameHeader
+	headerFragBuf []byte // not owned
+}
+
+func (f *HeadersFrame) HeaderBlockFragment() []byte { return f.headerFragBuf }
+
+func (f *HeadersFrame) HeadersEnded() bool { return f.Flags.Has(FlagHeadersEndHeaders) }
+
+func (f *HeadersFrame) StreamEnded() bool { return f.Flags.Has(FlagHeadersEndStream) }
+
+// A PriorityFrame specifies the sender-advised priority of a stream.
+// This implementation does not act on priorities; the frame is only
+// validated.
+// See http://http2.github.io/http2-spec/#rfc.section.6.3
+type PriorityFrame struct {
+	FrameHeader
+}
+
+// A RSTStreamFrame allows for abnormal termination of a stream.
+// See http://http2.github.io/http2-spec/#rfc.section.6.4
+type RSTStreamFrame struct {
+	FrameHeader
+	ErrCode ErrCode
+}
+
+// A SettingsFrame conveys configuration parameters that affect how
+// endpoints communicate, such as preferences and constraints on peer
+// behavior.
+//
+// See http://http2.github.io/http2-spec/#SETTINGS
+type SettingsFrame struct {
+	FrameHeader
+	p []byte
+}
+
+func (f *SettingsFrame) IsAck() bool {
+	return f.FrameHeader.Flags.Has(FlagSettingsAck)
+}
+
+// NumSettings returns the number of settings in the frame.
+func (f *SettingsFrame) NumSettings() int { return len(f.p) / 6 }
+
+// Setting returns the setting from the frame at the given 0-based index.
+// The index must be >= 0 and less than f.NumSettings().
+func (f *SettingsFrame) Setting(i int) Setting {
+	buf := f.p
+	return Setting{
+		ID:  SettingID(binary.BigEndian.Uint16(buf[i*6 : i*6+2])),
+		Val: binary.BigEndian.Uint32(buf[i*6+2 : i*6+6]),
+	}
+}
+
+// ForeachSetting runs fn for each setting. It stops and returns the
+// first error.
+func (f *SettingsFrame) ForeachSetting(fn func(Setting) error) error {
+	for i := 0; i < f.NumSettings(); i++ {
+		if err := fn(f.Setting(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A PushPromiseFrame is used to initiate a server stream.
+// See http://http2.github.io/http2-spec/#rfc.section.6.6
+type PushPromiseFrame struct {
+	FrameHeader
+	PromiseID     uint32
+	headerFragBuf []byte // not owned
+}
+
+func (f *PushPromiseFrame) HeaderBlockFragment() []byte { return f.headerFragBuf }
+
+func (f *PushPromiseFrame) HeadersEnded() bool { return f.Flags.Has(FlagPushPromiseEndHeaders) }
+
+// A PingFrame is a mechanism for measuring a minimal round trip time
+// from the sender, as well as determining whether an idle connection
+// is still functional.
+// See http://http2.github.io/http2-spec/#rfc.section.6.7
+type PingFrame struct {
+	FrameHeader
+	Data [8]byte
+}
+
+func (f *PingFrame) IsAck() bool { return f.Flags.Has(FlagPingAck) }
+
+// A GoAwayFrame informs the remote peer to stop creating streams on
+// this connection.
+// See http://http2.github.io/http2-spec/#rfc.section.6.8
+type GoAwayFrame struct {
+	FrameHeader
+	LastStreamID uint32
+	ErrCode      ErrCode
+	debugData    []byte
+}
+
+// DebugData returns any debug data in the GOAWAY frame. Its contents
+// are not defined.
+// The caller must not retain the returned memory past the next
+// call to ReadFrame.
+func (f *GoAwayFrame) DebugData() []byte {
+	return f.debugData
+}
+
+// A WindowUpdateFrame is used to implement flow control.
+// See http://http2.github.io/http2-spec/#rfc.section.6.9
+type WindowUpdateFrame struct {
+	FrameHeader
+	Increment uint32 // never read with high bit set
+}
+
+// A ContinuationFrame is used to continue a sequence of header block
+// fragments.
+// See http://http2.github.io/http2-spec/#rfc.section.6.10
+type ContinuationFrame struct {
+	FrameHeader
+	headerFragBuf []byte
+}
+
+func (f *ContinuationFrame) HeaderBlockFragment() []byte { return f.headerFragBuf }
+
+func (f *ContinuationFrame) HeadersEnded() bool {
+	return f.FrameHeader.Flags.Has(FlagContinuationEndHeaders)
+}
+
+// An UnknownFrame is the frame type returned when the frame type is
+// unknown or no specific frame type parser exists. Implementations
+// must ignore frames of unknown types.
+type UnknownFrame struct {
+	FrameHeader
+}
+
+// A Framer reads and writes Frames.
+//
+// A Framer is not safe for concurrent use: reads may happen
+// concurrently with writes, but there may be only one reader and one
+// writer at a time.
+type Framer struct {
+	r io.Reader
+
+	maxReadSize uint32
+	headerBuf   [frameHeaderLen]byte
+	readBuf     []byte
+
+	// lastHeaderStream is non-zero if the last frame read was an
+	// unfinished HEADERS, PUSH_PROMISE or CONTINUATION.
+	lastHeaderStream uint32
+
+	w    io.Writer
+	wbuf []byte
+}
+
+// NewFramer returns a Framer that writes frames to w and reads them
+// from r.
+func NewFramer(w io.Writer, r io.Reader) *Framer {
+	return &Framer{
+		w:           w,
+		r:           r,
+		maxReadSize: DefaultMaxFrameSize,
+	}
+}
+
+// SetMaxReadFrameSize sets the maximum size of a frame that will be
+// read by a subsequent call to ReadFrame. It is the caller's
+// responsibility to advertise this limit with a SETTINGS frame.
+func (fr *Framer) SetMaxReadFrameSize(v uint32) {
+	if v > MaxFrameSize {
+		v = MaxFrameSize
+	}
+	fr.maxReadSize = v
+}
+
+// ErrFrameTooLarge is returned from Framer.ReadFrame when the peer
+// sends a frame that is larger than declared with SetMaxReadFrameSize.
+var ErrFrameTooLarge = errors.New("http2: frame too large")
+
+// ReadFrameHeader reads a frame header from r.
+func ReadFrameHeader(r io.Reader) (FrameHeader, error) {
+	var buf [frameHeaderLen]byte
+	return readFrameHeader(buf[:], r)
+}
+
+func readFrameHeader(buf []byte, r io.Reader) (FrameHeader, error) {
+	_, err := io.ReadFull(r, buf[:frameHeaderLen])
+	if err != nil {
+		return FrameHeader{}, err
+	}
+	return FrameHeader{
+		Length:   uint32(buf[0])<<16 | uint32(buf[1])<<8 | uint32(buf[2]),
+		Type:     FrameType(buf[3]),
+		Flags:    Flags(buf[4]),
+		StreamID: binary.BigEndian.Uint32(buf[5:]) & (1<<31 - 1),
+	}, nil
+}
+
+// ReadFrame reads a single frame. The returned Frame is only valid
+// until the next call to ReadFrame.
+//
+// If the frame is larger than previously set with SetMaxReadFrameSize,
+// the returned error is ErrFrameTooLarge. Other errors may be of type
+// ConnectionError, StreamError, or anything else from the underlying
+// reader.
+func (fr *Framer) ReadFrame() (Frame, error) {
+	fh, err := readFrameHeader(fr.headerBuf[:], fr.r)
+	if err != nil {
+		return nil, err
+	}
+	if fh.Length > fr.maxReadSize {
+		return nil, ErrFrameTooLarge
+	}
+	if uint32(cap(fr.readBuf)) < fh.Length {
+		fr.readBuf = make([]byte, fh.Length)
+	}
+	payload := fr.readBuf[:fh.Length]
+	if _, err := io.ReadFull(fr.r, payload); err != nil {
+		return nil, err
+	}
+	f, err := parseFrame(fh, payload)
+	if err != nil {
+		return nil, err
+	}
+	if err := fr.checkFrameOrder(f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// checkFrameOrder enforces that a header block is a contiguous
+// sequence of HEADERS (or PUSH_PROMISE) and CONTINUATION frames on
+// one stream, per section 6.10.
+func (fr *Framer) checkFrameOrder(f Frame) error {
+	fh := f.Header()
+	if fr.lastHeaderStream != 0 {
+		if fh.Type != FrameContinuation || fh.StreamID != fr.lastHeaderStream {
+			return ConnectionError(ErrCodeProtocol)
+		}
+	} else if fh.Type == FrameContinuation {
+		return ConnectionError(ErrCodeProtocol)
+	}
+	switch f := f.(type) {
+	case *HeadersFrame:
+		fr.lastHeaderStream = headerStream(f.HeadersEnded(), fh.StreamID)
+	case *PushPromiseFrame:
+		fr.lastHeaderStream = headerStream(f.HeadersEnded(), fh.StreamID)
+	case *ContinuationFrame:
+		fr.lastHeaderStream = headerStream(f.HeadersEnded(), fh.StreamID)
+	}
+	return nil
+}
+
+func headerStream(ended bool, streamID uint32) uint32 {
+	if ended {
+		return 0
+	}
+	return streamID
+}
+
+func parseFrame(fh FrameHeader, p []byte) (Frame, error) {
+	switch fh.Type {
+	case FrameData:
+		return parseDataFrame(fh, p)
+	case FrameHeaders:
+		return parseHeadersFrame(fh, p)
+	case FramePriority:
+		return parsePriorityFrame(fh, p)
+	case FrameRSTStream:
+		return parseRSTStreamFrame(fh, p)
+	case FrameSettings:
+		return parseSettingsFrame(fh, p)
+	case FramePushPromise:
+		return parsePushPromiseFrame(fh, p)
+	case FramePing:
+		return parsePingFrame(fh, p)
+	case FrameGoAway:
+		return parseGoAwayFrame(fh, p)
+	case FrameWindowUpdate:
+		return parseWindowUpdateFrame(fh, p)
+	case FrameContinuation:
+		if fh.StreamID == 0 {
+			return nil, ConnectionError(ErrCodeProtocol)
+		}
+		return &ContinuationFrame{fh, p}, nil
+	}
+	return &UnknownFrame{fh}, nil
+}
+
+// readPadding strips the padding of a frame whose PADDED flag is set,
+// returning the remaining payload.
+func readPadding(p []byte) ([]byte, error) {
+	if len(p) == 0 {
+		return nil, ConnectionError(ErrCodeFrameSize)
+	}
+	padLen := int(p[0])
+	p = p[1:]
+	if padLen > len(p) {
+		// The padding length exceeds the frame payload.
+		return nil, ConnectionError(ErrCodeProtocol)
+	}
+	return p[:len(p)-padLen], nil
+}
+
+func parseDataFrame(fh FrameHeader, p []byte) (Frame, error) {
+	if fh.StreamID == 0 {
+		// DATA frames MUST be associated with a stream.
+		return nil, ConnectionError(ErrCodeProtocol)
+	}
+	var err error
+	if fh.Flags.Has(FlagDataPadded) {
+		if p, err = readPadding(p); err != nil {
+			return nil, err
+		}
+	}
+	return &DataFrame{fh, p}, nil
+}
+
+func parseHeadersFrame(fh FrameHeader, p []byte) (Frame, error) {
+	if fh.StreamID == 0 {
+		return nil, ConnectionError(ErrCodeProtocol)
+	}
+	var err error
+	if fh.Flags.Has(FlagHeadersPadded) {
+		if p, err = readPadding(p); err != nil {
+			return nil, err
+		}
+	}
+	if fh.Flags.Has(FlagHeadersPriority) {
+		if len(p) < 5 {
+			return nil, ConnectionError(ErrCodeFrameSize)
+		}
+		if dep := binary.BigEndian.Uint32(p) & (1<<31 - 1); dep == fh.StreamID {
+			// A stream cannot depend on itself. Section 5.3.1.
+			return nil, StreamError{fh.StreamID, ErrCodeProtocol}
+		}
+		p = p[5:]
+	}
+	return &HeadersFrame{fh, p}, nil
+}
+
+func parsePriorityFrame(fh FrameHeader, p []byte) (Frame, error) {
+	if fh.StreamID == 0 {
+		return nil, ConnectionError(ErrCodeProtocol)
+	}
+	if len(p) != 5 {
+		return nil, StreamError{fh.StreamID, ErrCodeFrameSize}
+	}
+	if dep := binary.BigEndian.Uint32(p) & (1<<31 - 1); dep == fh.StreamID {
+		return nil, StreamError{fh.StreamID, ErrCodeProtocol}
+	}
+	return &PriorityFrame{fh}, nil
+}
+
+func parseRSTStreamFrame(fh FrameHeader, p []byte) (Frame, error) {
+	if len(p) != 4 {
+		return nil, ConnectionError(ErrCodeFrameSize)
+	}
+	if fh.StreamID == 0 {
+		return nil, ConnectionError(ErrCodeProtocol)
+	}
+	return &RSTStreamFrame{fh, ErrCode(binary.BigEndian.Uint32(p))}, nil
+}
+
+func parseSettingsFrame(fh FrameHeader, p []byte) (Frame, error) {
+	if fh.StreamID != 0 {
+		// SETTINGS frames always apply to a connection,
+		// never a single stream.
+		return nil, ConnectionError(ErrCodeProtocol)
+	}
+	if fh.Flags.Has(FlagSettingsAck) && fh.Length > 0 {
+		// When a SETTINGS frame with the ACK flag set is
+		// received, the payload MUST be empty.
+		return nil, ConnectionError(ErrCodeFrameSize)
+	}
+	if len(p)%6 != 0 {
+		return nil, ConnectionError(ErrCodeFrameSize)
+	}
+	f := &SettingsFrame{FrameHeader: fh, p: p}
+	if err := f.ForeachSetting(func(s Setting) error { return s.Valid() }); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func parsePushPromiseFrame(fh FrameHeader, p []byte) (Frame, error) {
+	if fh.StreamID == 0 {
+		return nil, ConnectionError(ErrCodeProtocol)
+	}
+	var err error
+	if fh.Flags.Has(FlagPushPromisePadded) {
+		if p, err = readPadding(p); err != nil {
+			return nil, err
+		}
+	}
+	if len(p) < 4 {
+		return nil, ConnectionError(ErrCodeFrameSize)
+	}
+	promiseID := binary.BigEndian.Uint32(p) & (1<<31 - 1)
+	if promiseID == 0 {
+		return nil, ConnectionError(ErrCodeProtocol)
+	}
+	return &PushPromiseFrame{fh, promiseID, p[4:]}, nil
+}
+
+func parsePingFrame(fh FrameHeader, p []byte) (Frame, error) {
+	if len(p) != 8 {
+		return nil, ConnectionError(ErrCodeFrameSize)
+	}
+	if fh.StreamID != 0 {
+		return nil, ConnectionError(ErrCodeProtocol)
+	}
+	f := &PingFrame{FrameHeader: fh}
+	copy(f.Data[:], p)
+	return f, nil
+}
+
+func parseGoAwayFrame(fh FrameHeader, p []byte) (Frame, error) {
+	if fh.StreamID != 0 {
+		return nil, ConnectionError(ErrCodeProtocol)
+	}
+	if len(p) < 8 {
+		return nil, ConnectionError(ErrCodeFrameSize)
+	}
+	return &GoAwayFrame{
+		FrameHeader:  fh,
+		LastStreamID: binary.BigEndian.Uint32(p[:4]) & (1<<31 - 1),
+		ErrCode:      ErrCode(binary.BigEndian.Uint32(p[4:8])),
+		debugData:    p[8:],
+	}, nil
+}
+
+func parseWindowUpdateFrame(fh FrameHeader, p []byte) (Frame, error) {
+	if len(p) != 4 {
+		return nil, ConnectionError(ErrCodeFrameSize)
+	}
+	inc := binary.BigEndian.Uint32(p[:4]) & 0x7fffffff // mask off high reserved bit
+	if inc == 0 {
+		// A receiver MUST treat the receipt of a
+		// WINDOW_UPDATE frame with an flow control window
+		// increment of 0 as a stream error (Section 5.4.2) of
+		// type PROTOCOL_ERROR; errors on the connection flow
+		// control window MUST be treated as a connection
+		// error (Section 5.4.1).
+		if fh.StreamID == 0 {
+			return nil, ConnectionError(ErrCodeProtocol)
+		}
+		return nil, StreamError{fh.StreamID, ErrCodeProtocol}
+	}
+	return &WindowUpdateFrame{fh, inc}, nil
+}
+
+func (fr *Framer) startWrite(ftype FrameType, flags Flags, streamID uint32) {
+	// Write the FrameHeader; its length is filled in by endWrite.
+	fr.wbuf = append(fr.wbuf[:0],
+		0, // 3 bytes of length, filled in by endWrite
+		0,
+		0,
+		byte(ftype),
+		byte(flags),
+		byte(streamID>>24),
+		byte(streamID>>16),
+		byte(streamID>>8),
+		byte(streamID))
+}
+
+var errFrameTooLarge = errors.New("http2: attempt to write frame larger than maximum frame size")
+
+func (fr *Framer) endWrite() error {
+	// Now that we know the final size, fill in the FrameHeader in
+	// the space previously reserved for it.
+	length := len(fr.wbuf) - frameHeaderLen
+	if length >= (1 << 24) {
+		return errFrameTooLarge
+	}
+	fr.wbuf[0] = byte(length >> 16)
+	fr.wbuf[1] = byte(length >> 8)
+	fr.wbuf[2] = byte(length)
+	n, err := fr.w.Write(fr.wbuf)
+	if err == nil && n != len(fr.wbuf) {
+		err = io.ErrShortWrite
+	}
+	return err
+}
+
+func (fr *Framer) writeUint32(v uint32) {
+	fr.wbuf = append(fr.wbuf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+var errStreamID = errors.New("http2: invalid stream ID")
+
+func validStreamID(streamID uint32) bool {
+	return streamID != 0 && streamID&(1<<31) == 0
+}
+
+// WriteData writes a DATA frame.
+//
+// It is the caller's responsibility not to violate the maximum frame
+// size and to not call other Write methods concurrently.
+func (fr *Framer) WriteData(streamID uint32, endStream bool, data []byte) error {
+	if !validStreamID(streamID) {
+		return errStreamID
+	}
+	var flags Flags
+	if endStream {
+		flags |= FlagDataEndStream
+	}
+	fr.startWrite(FrameData, flags, streamID)
+	fr.wbuf = append(fr.wbuf, data...)
+	return fr.endWrite()
+}
+
+// HeadersFrameParam are the parameters for writing a HEADERS frame.
+type HeadersFrameParam struct {
+	// StreamID is the required Stream ID to initiate.
+	StreamID uint32
+	// BlockFragment is part (or all) of a Header Block.
+	BlockFragment []byte
+
+	// EndStream indicates that the header block is the last that
+	// the endpoint will send for the identified stream. Setting
+	// this flag causes the stream to enter one of "half closed"
+	// states.
+	EndStream bool
+
+	// EndHeaders indicates that this frame contains an entire
+	// header block and is not followed by any
+	// CONTINUATION frames.
+	EndHeaders bool
+}
+
+// WriteHeaders writes a single HEADERS frame.
+//
+// This is a low-level header writing method. Encoding headers and
+// splitting them into any necessary CONTINUATION frames is handled
+// elsewhere.
+func (fr *Framer) WriteHeaders(p HeadersFrameParam) error {
+	if !validStreamID(p.StreamID) {
+		return errStreamID
+	}
+	var flags Flags
+	if p.EndStream {
+		flags |= FlagHeadersEndStream
+	}
+	if p.EndHeaders {
+		flags |= FlagHeadersEndHeaders
+	}
+	fr.startWrite(FrameHeaders, flags, p.StreamID)
+	fr.wbuf = append(fr.wbuf, p.BlockFragment...)
+	return fr.endWrite()
+}
+
+// WriteContinuation writes a CONTINUATION frame.
+func (fr *Framer) WriteContinuation(streamID uint32, endHeaders bool, headerBlockFragment []byte) error {
+	if !validStreamID(streamID) {
+		return errStreamID
+	}
+	var flags Flags
+	if endHeaders {
+		flags |= FlagContinuationEndHeaders
+	}
+	fr.startWrite(FrameContinuation, flags, streamID)
+	fr.wbuf = append(fr.wbuf, headerBlockFragment...)
+	return fr.endWrite()
+}
+
+// PushPromiseParam are the parameters for writing a PUSH_PROMISE frame.
+type PushPromiseParam struct {
+	// StreamID is the required Stream ID to initiate.
+	StreamID uint32
+
+	// PromiseID is the required Stream ID which this
+	// Push Promises
+	PromiseID uint32
+
+	// BlockFragment is part (or all) of a Header Block.
+	BlockFragment []byte
+
+	// EndHeaders indicates that this frame contains an entire
+	// header block and is not followed by any
+	// CONTINUATION frames.
+	EndHeaders bool
+}
+
+// WritePushPromise writes a single PushPromise Frame.
+func (fr *Framer) WritePushPromise(p PushPromiseParam) error {
+	if !validStreamID(p.StreamID) || !validStreamID(p.PromiseID) {
+		return errStreamID
+	}
+	var flags Flags
+	if p.EndHeaders {
+		flags |= FlagPushPromiseEndHeaders
+	}
+	fr.startWrite(FramePushPromise, flags, p.StreamID)
+	fr.writeUint32(p.PromiseID)
+	fr.wbuf = append(fr.wbuf, p.BlockFragment...)
+	return fr.endWrite()
+}
+
+// WriteRSTStream writes a RST_STREAM frame.
+func (fr *Framer) WriteRSTStream(streamID uint32, code ErrCode) error {
+	if !validStreamID(streamID) {
+		return errStreamID
+	}
+	fr.startWrite(FrameRSTStream, 0, streamID)
+	fr.writeUint32(uint32(code))
+	return fr.endWrite()
+}
+
+// WriteSettings writes a SETTINGS frame with zero or more settings
+// specified and the ACK bit not set.
+func (fr *Framer) WriteSettings(settings ...Setting) error {
+	fr.startWrite(FrameSettings, 0, 0)
+	for _, s := range settings {
+		fr.wbuf = append(fr.wbuf, byte(s.ID>>8), byte(s.ID))
+		fr.writeUint32(s.Val)
+	}
+	return fr.endWrite()
+}
+
+// WriteSettingsAck writes an empty SETTINGS frame with the ACK bit set.
+func (fr *Framer) WriteSettingsAck() error {
+	fr.startWrite(FrameSettings, FlagSettingsAck, 0)
+	return fr.endWrite()
+}
+
+// WritePing writes a PING frame.
+func (fr *Framer) WritePing(ack bool, data [8]byte) error {
+	var flags Flags
+	if ack {
+		flags = FlagPingAck
+	}
+	fr.startWrite(FramePing, flags, 0)
+	fr.wbuf = append(fr.wbuf, data[:]...)
+	return fr.endWrite()
+}
+
+// WriteGoAway writes a GOAWAY frame.
+func (fr *Framer) WriteGoAway(maxStreamID uint32, code ErrCode, debugData []byte) error {
+	fr.startWrite(FrameGoAway, 0, 0)
+	fr.writeUint32(maxStreamID & (1<<31 - 1))
+	fr.writeUint32(uint32(code))
+	fr.wbuf = append(fr.wbuf, debugData...)
+	return fr.endWrite()
+}
+
+// WriteWindowUpdate writes a WINDOW_UPDATE frame.
+// The increment value must be between 1 and 2,147,483,647, inclusive.
+func (fr *Framer) WriteWindowUpdate(streamID, incr uint32) error {
+	if incr < 1 || incr > MaxWindowSize {
+		return errors.New("http2: illegal window increment value")
+	}
+	fr.startWrite(FrameWindowUpdate, 0, streamID)
+	fr.writeUint32(incr)
+	return fr.endWrite()
+}
+
+// WriteRawFrame writes a raw frame. This can be used to write
+// extension frames unknown to this package.
+func (fr *Framer) WriteRawFrame(t FrameType, flags Flags, streamID uint32, payload []byte) error {
+	fr.startWrite(t, flags, streamID)
+	fr.wbuf = append(fr.wbuf, payload...)
+	return fr.endWrite()
+}
@@ -0,0 +1,301 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http2
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func testFramer() (*Framer, *bytes.Buffer) {
+	buf := new(bytes.Buffer)
+	return NewFramer(buf, buf), buf
+}
+
+func TestWriteRST(t *testing.T) {
+	fr, buf := testFramer()
+	var streamID uint32 = 1<<24 + 2<<16 + 3<<8 + 4
+	var errCode uint32 = 7<<24 + 6<<16 + 5<<8 + 4
+	fr.WriteRSTStream(streamID, ErrCode(errCode))
+	const wantEnc = "\x00\x00\x04\x03\x00\x01\x02\x03\x04\x07\x06\x05\x04"
+	if buf.String() != wantEnc {
+		t.Errorf("encoded as %q; want %q", buf.Bytes(), wantEnc)
+	}
+	f, err := fr.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &RSTStreamFrame{
+		FrameHeader: FrameHeader{
+			Type:     0x3,
+			Flags:    0x0,
+			Length:   0x4,
+			StreamID: 0x1020304,
+		},
+		ErrCode: 0x7060504,
+	}
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("parsed back %#v; want %#v", f, want)
+	}
+}
+
+func TestWriteData(t *testing.T) {
+	fr, buf := testFramer()
+	var streamID uint32 = 1<<24 + 2<<16 + 3<<8 + 4
+	data := []byte("ABC")
+	fr.WriteData(streamID, true, data)
+	const wantEnc = "\x00\x00\x03\x00\x01\x01\x02\x03\x04ABC"
+	if buf.String() != wantEnc {
+		t.Errorf("encoded as %q; want %q", buf.Bytes(), wantEnc)
+	}
+	f, err := fr.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	df, ok := f.(*DataFrame)
+	if !ok {
+		t.Fatalf("got %T; want *DataFrame", f)
+	}
+	if !bytes.Equal(df.Data(), data) {
+		t.Errorf("got %q; want %q", df.Data(), data)
+	}
+	if f.Header().Flags&1 == 0 {
+		t.Errorf("didn't see END_STREAM flag")
+	}
+}
+
+func TestReadPaddedData(t *testing.T) {
+	fr, buf := testFramer()
+	// A padded DATA frame: pad length 2, data "hi", 2 bytes padding.
+	fr.WriteRawFrame(FrameData, FlagDataPadded, 1, []byte("\x02hi\x00\x00"))
+	f, err := fr.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(f.(*DataFrame).Data()); got != "hi" {
+		t.Errorf("data = %q; want %q", got, "hi")
+	}
+
+	buf.Reset()
+	fr.WriteRawFrame(FrameData, FlagDataPadded, 1, []byte("\x05hi"))
+	if _, err := fr.ReadFrame(); err != ConnectionError(ErrCodeProtocol) {
+		t.Errorf("overlong padding: err = %v; want PROTOCOL_ERROR", err)
+	}
+}
+
+func TestWriteHeaders(t *testing.T) {
+	fr, buf := testFramer()
+	fr.WriteHeaders(HeadersFrameParam{
+		StreamID:      42,
+		BlockFragment: []byte("abc"),
+		EndStream:     true,
+		EndHeaders:    true,
+	})
+	const wantEnc = "\x00\x00\x03\x01\x05\x00\x00\x00*abc"
+	if buf.String() != wantEnc {
+		t.Errorf("encoded as %q; want %q", buf.Bytes(), wantEnc)
+	}
+	f, err := fr.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hf := f.(*HeadersFrame)
+	if !hf.StreamEnded() || !hf.HeadersEnded() || string(hf.HeaderBlockFragment()) != "abc" {
+		t.Errorf("got %v, fragment %q", hf.FrameHeader, hf.HeaderBlockFragment())
+	}
+}
+
+func TestContinuationOrder(t *testing.T) {
+	fr, _ := testFramer()
+	fr.WriteHeaders(HeadersFrameParam{StreamID: 1, BlockFragment: []byte("a")})
+	fr.WriteContinuation(1, false, []byte("b"))
+	fr.WriteContinuation(1, true, []byte("c"))
+	for i := 0; i < 3; i++ {
+		if _, err := fr.ReadFrame(); err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+	}
+
+	// A header block interrupted by another frame is a
+	// connection error.
+	fr, _ = testFramer()
+	fr.WriteHeaders(HeadersFrameParam{StreamID: 1, BlockFragment: []byte("a")})
+	fr.WriteData(1, false, []byte("x"))
+	fr.ReadFrame()
+	if _, err := fr.ReadFrame(); err != ConnectionError(ErrCodeProtocol) {
+		t.Errorf("DATA inside header block: err = %v; want PROTOCOL_ERROR", err)
+	}
+
+	// So is a CONTINUATION that doesn't follow a header block.
+	fr, _ = testFramer()
+	fr.WriteContinuation(1, true, []byte("c"))
+	if _, err := fr.ReadFrame(); err != ConnectionError(ErrCodeProtocol) {
+		t.Errorf("stray CONTINUATION: err = %v; want PROTOCOL_ERROR", err)
+	}
+}
+
+func TestWriteSettings(t *testing.T) {
+	fr, buf := testFramer()
+	settings := []Setting{{1, 2}, {3, 4}}
+	fr.WriteSettings(settings...)
+	const wantEnc = "\x00\x00\f\x04\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x02\x00\x03\x00\x00\x00\x04"
+	if buf.String() != wantEnc {
+		t.Errorf("encoded as %q; want %q", buf.Bytes(), wantEnc)
+	}
+	f, err := fr.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sf, ok := f.(*SettingsFrame)
+	if !ok {
+		t.Fatalf("Got a %T; want a SettingsFrame", f)
+	}
+	var got []Setting
+	sf.ForeachSetting(func(s Setting) error {
+		got = append(got, s)
+		return nil
+	})
+	if !reflect.DeepEqual(settings, got) {
+		t.Errorf("Read settings %+v != written settings %+v", got, settings)
+	}
+}
+
+func TestReadBadSettings(t *testing.T) {
+	tests := []struct {
+		s    Setting
+		want error
+	}{
+		{Setting{SettingEnablePush, 2}, ConnectionError(ErrCodeProtocol)},
+		{Setting{SettingInitialWindowSize, 1 << 31}, ConnectionError(ErrCodeFlowControl)},
+		{Setting{SettingMaxFrameSize, 100}, ConnectionError(ErrCodeProtocol)},
+	}
+	for _, tt := range tests {
+		fr, _ := testFramer()
+		fr.WriteSettings(tt.s)
+		if _, err := fr.ReadFrame(); err != tt.want {
+			t.Errorf("%v: err = %v; want %v", tt.s, err, tt.want)
+		}
+	}
+}
+
+func TestWriteSettingsAck(t *testing.T) {
+	fr, buf := testFramer()
+	fr.WriteSettingsAck()
+	const wantEnc = "\x00\x00\x00\x04\x01\x00\x00\x00\x00"
+	if buf.String() != wantEnc {
+		t.Errorf("encoded as %q; want %q", buf.Bytes(), wantEnc)
+	}
+}
+
+func TestWriteWindowUpdate(t *testing.T) {
+	fr, buf := testFramer()
+	const streamID = 1<<24 + 2<<16 + 3<<8 + 4
+	const incr = 7<<24 + 6<<16 + 5<<8 + 4
+	if err := fr.WriteWindowUpdate(streamID, incr); err != nil {
+		t.Fatal(err)
+	}
+	const wantEnc = "\x00\x00\x04\x08\x00\x01\x02\x03\x04\x07\x06\x05\x04"
+	if buf.String() != wantEnc {
+		t.Errorf("encoded as %q; want %q", buf.Bytes(), wantEnc)
+	}
+	f, err := fr.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &WindowUpdateFrame{
+		FrameHeader: FrameHeader{
+			Type:     0x8,
+			Flags:    0x0,
+			Length:   0x4,
+			StreamID: 0x1020304,
+		},
+		Increment: 0x7060504,
+	}
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("parsed back %#v; want %#v", f, want)
+	}
+	if err := fr.WriteWindowUpdate(1, 0); err == nil {
+		t.Error("WriteWindowUpdate with zero increment succeeded")
+	}
+}
+
+func TestWritePing(t *testing.T) {
+	fr, buf := testFramer()
+	if err := fr.WritePing(true, [8]byte{1, 2, 3, 4, 5, 6, 7, 8}); err != nil {
+		t.Fatal(err)
+	}
+	const wantEnc = "\x00\x00\x08\x06\x01\x00\x00\x00\x00\x01\x02\x03\x04\x05\x06\x07\x08"
+	if buf.String() != wantEnc {
+		t.Errorf("encoded as %q; want %q", buf.Bytes(), wantEnc)
+	}
+	f, err := fr.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf := f.(*PingFrame)
+	if !pf.IsAck() || pf.Data != [8]byte{1, 2, 3, 4, 5, 6, 7, 8} {
+		t.Errorf("parsed back %#v", pf)
+	}
+}
+
+func TestWriteGoAway(t *testing.T) {
+	fr, buf := testFramer()
+	fr.WriteGoAway(0x01020304, 0x05060708, []byte("debug"))
+	const wantEnc = "\x00\x00\r\a\x00\x00\x00\x00\x00\x01\x02\x03\x04\x05\x06\x07\x08debug"
+	if buf.String() != wantEnc {
+		t.Errorf("encoded as %q; want %q", buf.Bytes(), wantEnc)
+	}
+	f, err := fr.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gf := f.(*GoAwayFrame)
+	if gf.LastStreamID != 0x01020304 || gf.ErrCode != 0x05060708 || string(gf.DebugData()) != "debug" {
+		t.Errorf("parsed back %#v", gf)
+	}
+}
+
+func TestWritePushPromise(t *testing.T) {
+	fr, buf := testFramer()
+	fr.WritePushPromise(PushPromiseParam{
+		StreamID:      42,
+		PromiseID:     42,
+		BlockFragment: []byte("abc"),
+		EndHeaders:    true,
+	})
+	const wantEnc = "\x00\x00\x07\x05\x04\x00\x00\x00*\x00\x00\x00*abc"
+	if buf.String() != wantEnc {
+		t.Errorf("encoded as %q; want %q", buf.Bytes(), wantEnc)
+	}
+	f, err := fr.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pp := f.(*PushPromiseFrame)
+	if pp.PromiseID != 42 || !pp.HeadersEnded() || string(pp.HeaderBlockFragment()) != "abc" {
+		t.Errorf("parsed back %#v", pp)
+	}
+}
+
+func TestReadFrameTooLarge(t *testing.T) {
+	fr, _ := testFramer()
+	fr.WriteData(1, false, make([]byte, DefaultMaxFrameSize+1))
+	if _, err := fr.ReadFrame(); err != ErrFrameTooLarge {
+		t.Errorf("err = %v; want ErrFrameTooLarge", err)
+	}
+}
+
+func TestReadUnknownFrame(t *testing.T) {
+	fr, _ := testFramer()
+	fr.WriteRawFrame(0xfe, 0, 3, []byte("ext"))
+	f, err := fr.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.(*UnknownFrame); !ok {
+		t.Errorf("got %T; want *UnknownFrame", f)
+	}
+}
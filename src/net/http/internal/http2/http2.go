@@ -0,0 +1,101 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package http2 implements the framing layer of HTTP/2, as defined
+// in RFC 7540. The request and response semantics are implemented on
+// top of it by package net/http.
+package http2
+
+import "fmt"
+
+// ClientPreface is the string that must be sent by new connections
+// from clients.
+const ClientPreface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+// NextProtoTLS is the NPN/ALPN protocol negotiated during HTTP/2's
+// TLS setup.
+const NextProtoTLS = "h2"
+
+const (
+	// InitialWindowSize is the initial flow-control window size
+	// of new streams and connections, per section 6.9.2.
+	InitialWindowSize = 65535
+
+	// MaxWindowSize is the largest legal flow-control window.
+	MaxWindowSize = 1<<31 - 1
+
+	// DefaultMaxFrameSize is the initial value of
+	// SETTINGS_MAX_FRAME_SIZE, and the smallest legal value.
+	DefaultMaxFrameSize = 1 << 14
+
+	// MaxFrameSize is the largest legal value of
+	// SETTINGS_MAX_FRAME_SIZE.
+	MaxFrameSize = 1<<24 - 1
+
+	// InitialHeaderTableSize is the initial value of
+	// SETTINGS_HEADER_TABLE_SIZE.
+	InitialHeaderTableSize = 4096
+)
+
+// A SettingID is an HTTP/2 setting as defined in section 6.5.2.
+type SettingID uint16
+
+const (
+	SettingHeaderTableSize      SettingID = 0x1
+	SettingEnablePush           SettingID = 0x2
+	SettingMaxConcurrentStreams SettingID = 0x3
+	SettingInitialWindowSize    SettingID = 0x4
+	SettingMaxFrameSize         SettingID = 0x5
+	SettingMaxHeaderListSize    SettingID = 0x6
+)
+
+var settingName = map[SettingID]string{
+	SettingHeaderTableSize:      "HEADER_TABLE_SIZE",
+	SettingEnablePush:           "ENABLE_PUSH",
+	SettingMaxConcurrentStreams: "MAX_CONCURRENT_STREAMS",
+	SettingInitialWindowSize:    "INITIAL_WINDOW_SIZE",
+	SettingMaxFrameSize:         "MAX_FRAME_SIZE",
+	SettingMaxHeaderListSize:    "MAX_HEADER_LIST_SIZE",
+}
+
+func (s SettingID) String() string {
+	if v, ok := settingName[s]; ok {
+		return v
+	}
+	return fmt.Sprintf("UNKNOWN_SETTING_%d", uint16(s))
+}
+
+// Setting is a setting parameter: which setting it is, and its value.
+type Setting struct {
+	// ID is which setting is being set.
+	// See http://http2.github.io/http2-spec/#SettingValues
+	ID SettingID
+
+	// Val is the value.
+	Val uint32
+}
+
+func (s Setting) String() string {
+	return fmt.Sprintf("[%v = %d]", s.ID, s.Val)
+}
+
+// Valid reports whether the setting is valid.
+func (s Setting) Valid() error {
+	// Limits and error codes from section 6.5.2 Defined SETTINGS Parameters
+	switch s.ID {
+	case SettingEnablePush:
+		if s.Val != 1 && s.Val != 0 {
+			return ConnectionError(ErrCodeProtocol)
+		}
+	case SettingInitialWindowSize:
+		if s.Val > MaxWindowSize {
+			return ConnectionError(ErrCodeFlowControl)
+		}
+	case SettingMaxFrameSize:
+		if s.Val < DefaultMaxFrameSize || s.Val > MaxFrameSize {
+			return ConnectionError(ErrCodeProtocol)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Patterns for ServeMux routing.
+
+package http
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// A pattern is a parsed ServeMux pattern of the form
+//
+//	[METHOD ][HOST]/[PATH]
+//
+// Each path segment is a literal, a wildcard "{name}" matching one
+// non-empty segment, or, as the last segment only, a wildcard
+// "{name...}" matching the rest of the path. A trailing slash is an
+// anonymous "{...}" wildcard, and a trailing "{$}" matches only the
+// trailing slash itself.
+type pattern struct {
+	str      string // as registered
+	method   string // "" matches every method
+	host     string // "" matches every host
+	segments []segment
+}
+
+// A segment is one element of a pattern's path.
+type segment struct {
+	s     string // literal text, or wildcard name; "" for a literal means the trailing slash of "{$}"
+	wild  bool   // whether the segment is a wildcard
+	multi bool   // whether the wildcard matches the rest of the path
+}
+
+// parsePattern parses s as a ServeMux pattern.
+func parsePattern(s string) (*pattern, error) {
+	if s == "" {
+		return nil, errors.New("empty pattern")
+	}
+	p := &pattern{str: s}
+	rest := s
+	if i := strings.IndexAny(rest, " \t"); i >= 0 {
+		p.method = rest[:i]
+		rest = strings.TrimLeft(rest[i+1:], " \t")
+		if p.method == "" || strings.IndexFunc(p.method, isNotToken) >= 0 {
+			return nil, fmt.Errorf("invalid method %q", p.method)
+		}
+	}
+	i := strings.Index(rest, "/")
+	if i < 0 {
+		return nil, errors.New("host/path missing /")
+	}
+	p.host = rest[:i]
+	if strings.Contains(p.host, "{") {
+		return nil, errors.New("host contains '{' (missing initial '/'?)")
+	}
+	if strings.ContainsAny(p.host, " \t") {
+		return nil, fmt.Errorf("invalid host %q", p.host)
+	}
+	names := make(map[string]bool)
+	elems := strings.Split(rest[i+1:], "/")
+	for j, e := range elems {
+		last := j == len(elems)-1
+		if last && e == "" {
+			// Trailing slash: match the rest of the path.
+			p.segments = append(p.segments, segment{wild: true, multi: true})
+			break
+		}
+		if !strings.Contains(e, "{") && !strings.Contains(e, "}") {
+			p.segments = append(p.segments, segment{s: e})
+			continue
+		}
+		if len(e) < 2 || e[0] != '{' || e[len(e)-1] != '}' {
+			return nil, fmt.Errorf("bad wildcard segment %q (must be entire segment)", e)
+		}
+		name := e[1 : len(e)-1]
+		if name == "$" {
+			if !last {
+				return nil, errors.New("{$} not at end")
+			}
+			p.segments = append(p.segments, segment{s: ""})
+			break
+		}
+		seg := segment{wild: true}
+		if strings.HasSuffix(name, "...") {
+			if !last {
+				return nil, errors.New("{...} wildcard not at end")
+			}
+			name = name[:len(name)-len("...")]
+			seg.multi = true
+		}
+		if !isValidWildcardName(name) {
+			return nil, fmt.Errorf("bad wildcard name %q", name)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("duplicate wildcard name %q", name)
+		}
+		names[name] = true
+		seg.s = name
+		p.segments = append(p.segments, seg)
+	}
+	return p, nil
+}
+
+// isValidWildcardName reports whether s is a Go identifier.
+func isValidWildcardName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		if !unicode.IsLetter(c) && c != '_' && (i == 0 || !unicode.IsDigit(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// lastSegment returns the last segment of p.
+func (p *pattern) lastSegment() segment {
+	return p.segments[len(p.segments)-1]
+}
+
+// wildcardIndex returns the index in the values returned by matchPath
+// of the wildcard with the given name, or -1 if p has no such wildcard.
+func (p *pattern) wildcardIndex(name string) int {
+	i := 0
+	for _, seg := range p.segments {
+		if !seg.wild || seg.s == "" {
+			continue
+		}
+		if seg.s == name {
+			return i
+		}
+		i++
+	}
+	return -1
+}
+
+// matchMethod reports whether p matches requests with the given
+// method. A GET pattern also matches HEAD requests.
+func (p *pattern) matchMethod(method string) bool {
+	return p.method == "" || p.method == method || p.method == "GET" && method == "HEAD"
+}
+
+// matchPath reports whether p matches path. If so, it returns the
+// values of p's named wildcards in order, and whether the match is
+// exact: that is, whether any trailing multi-segment wildcard matched
+// nothing but the path's trailing slash.
+func (p *pattern) matchPath(path string) (values []string, exact, ok bool) {
+	if path == "" || path[0] != '/' {
+		return nil, false, false
+	}
+	elems := strings.Split(path[1:], "/")
+	exact = true
+	for i, seg := range p.segments {
+		if i >= len(elems) {
+			return nil, false, false
+		}
+		if seg.multi {
+			if seg.s != "" {
+				values = append(values, strings.Join(elems[i:], "/"))
+			}
+			exact = i == len(elems)-1 && elems[i] == ""
+			return values, exact, true
+		}
+		if !seg.wild {
+			if elems[i] != seg.s {
+				return nil, false, false
+			}
+			continue
+		}
+		if elems[i] == "" {
+			return nil, false, false
+		}
+		values = append(values, elems[i])
+	}
+	if len(elems) != len(p.segments) {
+		return nil, false, false
+	}
+	return values, exact, true
+}
+
+// A relationship describes how the sets of requests matched by two
+// patterns relate.
+type relationship string
+
+const (
+	equivalent   relationship = "equivalent"   // both match the same requests
+	moreGeneral  relationship = "moreGeneral"  // p1 matches a strict superset of p2's requests
+	moreSpecific relationship = "moreSpecific" // p1 matches a strict subset of p2's requests
+	disjoint     relationship = "disjoint"     // no request matches both
+	overlaps     relationship = "overlaps"     // none of the above
+)
+
+// compare returns the relationship of p1 to p2, ignoring their hosts.
+func (p1 *pattern) compare(p2 *pattern) relationship {
+	rel := p1.compareMethods(p2)
+	if rel == disjoint {
+		return disjoint
+	}
+	return combineRelationships(rel, p1.comparePaths(p2))
+}
+
+func (p1 *pattern) compareMethods(p2 *pattern) relationship {
+	switch {
+	case p1.method == p2.method:
+		return equivalent
+	case p1.method == "":
+		return moreGeneral
+	case p2.method == "":
+		return moreSpecific
+	case p1.method == "GET" && p2.method == "HEAD":
+		return moreGeneral
+	case p1.method == "HEAD" && p2.method == "GET":
+		return moreSpecific
+	}
+	return disjoint
+}
+
+func (p1 *pattern) comparePaths(p2 *pattern) relationship {
+	segs1, segs2 := p1.segments, p2.segments
+	rel := equivalent
+	for len(segs1) > 0 && len(segs2) > 0 {
+		rel = combineRelationships(rel, compareSegments(segs1[0], segs2[0]))
+		if rel == disjoint {
+			return disjoint
+		}
+		segs1, segs2 = segs1[1:], segs2[1:]
+	}
+	switch {
+	case len(segs1) == 0 && len(segs2) == 0:
+		return rel
+	case len(segs1) == 0 && p1.lastSegment().multi:
+		// p1's trailing wildcard covers the rest of p2.
+		return combineRelationships(rel, moreGeneral)
+	case len(segs2) == 0 && p2.lastSegment().multi:
+		return combineRelationships(rel, moreSpecific)
+	}
+	return disjoint
+}
+
+func compareSegments(s1, s2 segment) relationship {
+	switch {
+	case s1.multi && s2.multi:
+		return equivalent
+	case s1.multi:
+		return moreGeneral
+	case s2.multi:
+		return moreSpecific
+	case s1.wild && s2.wild:
+		return equivalent
+	case s1.wild:
+		if s2.s == "" {
+			// A wildcard never matches an empty segment.
+			return disjoint
+		}
+		return moreGeneral
+	case s2.wild:
+		if s1.s == "" {
+			return disjoint
+		}
+		return moreSpecific
+	case s1.s == s2.s:
+		return equivalent
+	}
+	return disjoint
+}
+
+// combineRelationships returns the relationship of two patterns
+// given the relationships r1 and r2 of two independent parts of them,
+// such as their methods and paths.
+func combineRelationships(r1, r2 relationship) relationship {
+	switch r1 {
+	case equivalent:
+		return r2
+	case disjoint:
+		return disjoint
+	case overlaps:
+		if r2 == disjoint {
+			return disjoint
+		}
+		return overlaps
+	}
+	// r1 is moreGeneral or moreSpecific.
+	switch r2 {
+	case equivalent:
+		return r1
+	case disjoint, overlaps:
+		return r2
+	case r1:
+		return r1
+	}
+	return overlaps
+}
@@ -0,0 +1,148 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var parsePatternTests = []struct {
+	in   string
+	want pattern
+}{
+	{"/", pattern{segments: []segment{{wild: true, multi: true}}}},
+	{"/a", pattern{segments: []segment{{s: "a"}}}},
+	{"/a/", pattern{segments: []segment{{s: "a"}, {wild: true, multi: true}}}},
+	{"/a/{$}", pattern{segments: []segment{{s: "a"}, {s: ""}}}},
+	{"GET /a/{x}/b", pattern{method: "GET", segments: []segment{{s: "a"}, {s: "x", wild: true}, {s: "b"}}}},
+	{"POST \t example.com/{rest...}", pattern{method: "POST", host: "example.com", segments: []segment{{s: "rest", wild: true, multi: true}}}},
+	{"example.com/", pattern{host: "example.com", segments: []segment{{wild: true, multi: true}}}},
+	{"/{x_1}", pattern{segments: []segment{{s: "x_1", wild: true}}}},
+}
+
+func TestParsePattern(t *testing.T) {
+	for _, tt := range parsePatternTests {
+		got, err := parsePattern(tt.in)
+		if err != nil {
+			t.Errorf("parsePattern(%q): %v", tt.in, err)
+			continue
+		}
+		tt.want.str = tt.in
+		if !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("parsePattern(%q) = %+v; want %+v", tt.in, *got, tt.want)
+		}
+	}
+}
+
+func TestParsePatternError(t *testing.T) {
+	for _, tt := range []struct {
+		in, contains string
+	}{
+		{"", "empty pattern"},
+		{"a", "missing /"},
+		{"GE(T /", "invalid method"},
+		{"/a{x}", "must be entire segment"},
+		{"/{x...}/a", "not at end"},
+		{"/{$}/a", "not at end"},
+		{"/{}", "bad wildcard name"},
+		{"/{1x}", "bad wildcard name"},
+		{"/{x}/{x}", "duplicate wildcard name"},
+		{"{x}.com/", "host contains"},
+		{"BAD METHOD /", "invalid host"},
+	} {
+		_, err := parsePattern(tt.in)
+		if err == nil || !strings.Contains(err.Error(), tt.contains) {
+			t.Errorf("parsePattern(%q) error = %v; want one containing %q", tt.in, err, tt.contains)
+		}
+	}
+}
+
+func TestPatternMatchPath(t *testing.T) {
+	for _, tt := range []struct {
+		pat, path string
+		values    []string
+		exact     bool
+		ok        bool
+	}{
+		{"/", "/", nil, true, true},
+		{"/", "/a/b", nil, false, true},
+		{"/a", "/a", nil, true, true},
+		{"/a", "/a/", nil, false, false},
+		{"/a/", "/a", nil, false, false},
+		{"/a/", "/a/", nil, true, true},
+		{"/a/{$}", "/a/", nil, true, true},
+		{"/a/{$}", "/a/b", nil, false, false},
+		{"/a/{x}", "/a/b", []string{"b"}, true, true},
+		{"/a/{x}", "/a/", nil, false, false},
+		{"/a/{x}/c", "/a/b/c", []string{"b"}, true, true},
+		{"/{x}/{rest...}", "/a/b/c", []string{"a", "b/c"}, false, true},
+		{"/{x}/{rest...}", "/a/", []string{"a", ""}, true, true},
+		{"/{x}/{rest...}", "/a", nil, false, false},
+		{"/a", "", nil, false, false},
+	} {
+		p, err := parsePattern(tt.pat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values, exact, ok := p.matchPath(tt.path)
+		if ok != tt.ok || ok && (exact != tt.exact || !reflect.DeepEqual(values, tt.values)) {
+			t.Errorf("%q.matchPath(%q) = %q, %t, %t; want %q, %t, %t",
+				tt.pat, tt.path, values, exact, ok, tt.values, tt.exact, tt.ok)
+		}
+	}
+}
+
+func TestPatternCompare(t *testing.T) {
+	for _, tt := range []struct {
+		p1, p2 string
+		want   relationship
+	}{
+		{"/a", "/a", equivalent},
+		{"/a", "/b", disjoint},
+		{"/{x}", "/{y}", equivalent},
+		{"/a/", "/a/{x...}", equivalent},
+		{"/", "/a", moreGeneral},
+		{"/images/", "/images/thumbnails/", moreGeneral},
+		{"/a/{x}", "/a/b", moreGeneral},
+		{"/a/{x}", "/a/{$}", disjoint},
+		{"/a/{x}", "/a/", moreSpecific},
+		{"/a/{x}/c", "/a/b/{y}", overlaps},
+		{"/a/{x...}", "/a", disjoint},
+		{"/a/{x}", "/a/{x}/{y}", disjoint},
+		{"GET /a", "/a", moreSpecific},
+		{"GET /a", "HEAD /a", moreGeneral},
+		{"GET /a", "POST /a", disjoint},
+		{"GET /a", "POST /", disjoint},
+		{"GET /", "/index.html", overlaps},
+		{"GET /posts/{id}", "/posts/latest", overlaps},
+		{"GET /posts/{id}", "/posts/{id}", moreSpecific},
+		{"GET /posts/{id}", "GET /posts/", moreSpecific},
+	} {
+		p1, err := parsePattern(tt.p1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p2, err := parsePattern(tt.p2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p1.compare(p2); got != tt.want {
+			t.Errorf("%q.compare(%q) = %s; want %s", tt.p1, tt.p2, got, tt.want)
+		}
+		// The relationship is symmetric, up to inversion.
+		want := tt.want
+		switch want {
+		case moreGeneral:
+			want = moreSpecific
+		case moreSpecific:
+			want = moreGeneral
+		}
+		if got := p2.compare(p1); got != want {
+			t.Errorf("%q.compare(%q) = %s; want %s", tt.p2, tt.p1, got, want)
+		}
+	}
+}
@@ -9,6 +9,7 @@ package http
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/base64"
 	"errors"
@@ -17,6 +18,7 @@ import (
 	"io/ioutil"
 	"mime"
 	"mime/multipart"
+	"net/http/httptrace"
 	"net/textproto"
 	"net/url"
 	"strconv"
@@ -223,6 +225,79 @@ type Request struct {
 	// otherwise it leaves the field nil.
 	// This field is ignored by the HTTP client.
 	TLS *tls.ConnectionState
+
+	// ctx is either the client or server context. It should only
+	// be modified via copying the whole Request using WithContext.
+	// It is unexported to prevent people from using Context wrong
+	// and mutating the contexts held by callers of the same request.
+	ctx context.Context
+
+	pat         *pattern          // the ServeMux pattern that matched the request
+	pathValues  []string          // values of pat's wildcards
+	otherValues map[string]string // path values set for names not in pat
+}
+
+// Context returns the request's context. To change the context, use
+// WithContext.
+//
+// The returned context is always non-nil; it defaults to the
+// background context.
+//
+// For outgoing client requests, the context controls cancelation:
+// the Transport abandons dialing, waiting for the response, and
+// reading the response body once the context is done.
+//
+// For incoming server requests, the context is canceled when the
+// client's connection closes, when the request is reset (with
+// HTTP/2), or when the ServeHTTP method returns. A request asking
+// for a protocol upgrade (with the Connection and Upgrade headers)
+// is not canceled when the client's connection closes, so that its
+// handler can still use Hijacker.
+func (r *Request) Context() context.Context {
+	if r.ctx != nil {
+		return r.ctx
+	}
+	return context.Background()
+}
+
+// WithContext returns a shallow copy of r with its context changed
+// to ctx. The provided ctx must be non-nil.
+func (r *Request) WithContext(ctx context.Context) *Request {
+	if ctx == nil {
+		panic("nil context")
+	}
+	r2 := new(Request)
+	*r2 = *r
+	r2.ctx = ctx
+	return r2
+}
+
+// PathValue returns the value for the named path wildcard in the
+// ServeMux pattern that matched the request, or the value set for
+// name by SetPathValue. It returns the empty string if the request
+// was not matched against a pattern or there is no such wildcard.
+func (r *Request) PathValue(name string) string {
+	if r.pat != nil {
+		if i := r.pat.wildcardIndex(name); i >= 0 {
+			return r.pathValues[i]
+		}
+	}
+	return r.otherValues[name]
+}
+
+// SetPathValue sets name to value, so that subsequent calls to
+// r.PathValue(name) return value.
+func (r *Request) SetPathValue(name, value string) {
+	if r.pat != nil {
+		if i := r.pat.wildcardIndex(name); i >= 0 {
+			r.pathValues[i] = value
+			return
+		}
+	}
+	if r.otherValues == nil {
+		r.otherValues = make(map[string]string)
+	}
+	r.otherValues[name] = value
 }
 
 // ProtoAtLeast reports whether the HTTP protocol used
@@ -346,7 +421,7 @@ const defaultUserAgent = "Go 1.1 package http"
 // hasn't been set to "identity", Write adds "Transfer-Encoding:
 // chunked" to the header. Body is closed after it is sent.
 func (r *Request) Write(w io.Writer) error {
-	return r.write(w, false, nil)
+	return r.write(w, false, nil, nil)
 }
 
 // WriteProxy is like Write but writes the request in the form
@@ -356,11 +431,12 @@ func (r *Request) Write(w io.Writer) error {
 // In either case, WriteProxy also writes a Host header, using
 // either r.Host or r.URL.Host.
 func (r *Request) WriteProxy(w io.Writer) error {
-	return r.write(w, true, nil)
+	return r.write(w, true, nil, nil)
 }
 
 // extraHeaders may be nil
-func (req *Request) write(w io.Writer, usingProxy bool, extraHeaders Header) error {
+// waitForContinue may be nil
+func (req *Request) write(w io.Writer, usingProxy bool, extraHeaders Header, waitForContinue func() bool) error {
 	// According to RFC 6874, an HTTP client, proxy, or other
 	// intermediary must remove any IPv6 zone identifier attached
 	// to an outgoing URI.
@@ -444,6 +520,24 @@ func (req *Request) write(w io.Writer, usingProxy bool, extraHeaders Header) err
 		return err
 	}
 
+	if trace := httptrace.ContextClientTrace(req.Context()); trace != nil && trace.WroteHeaders != nil {
+		trace.WroteHeaders()
+	}
+
+	// Flush and wait for 100-continue if expected.
+	if waitForContinue != nil {
+		if bw, ok := w.(*bufio.Writer); ok {
+			err = bw.Flush()
+			if err != nil {
+				return err
+			}
+		}
+		if !waitForContinue() {
+			req.closeBody()
+			return nil
+		}
+	}
+
 	// Write body and trailer
 	err = tw.WriteBody(w)
 	if err != nil {
@@ -932,6 +1026,10 @@ func (r *Request) wantsClose() bool {
 	return hasToken(r.Header.get("Connection"), "close")
 }
 
+func (r *Request) wantsUpgrade() bool {
+	return hasToken(r.Header.get("Connection"), "upgrade") && r.Header.get("Upgrade") != ""
+}
+
 func (r *Request) closeBody() {
 	if r.Body != nil {
 		r.Body.Close()
@@ -55,6 +55,11 @@ type Response struct {
 	//
 	// The Body is automatically dechunked if the server replied
 	// with a "chunked" Transfer-Encoding.
+	//
+	// If the Transport receives a 101 Switching Protocols response
+	// to a request with an "Upgrade" header, the Body is an
+	// io.ReadWriteCloser over the underlying connection, which the
+	// caller then owns.
 	Body io.ReadCloser
 
 	// ContentLength records the length of the associated content.  The
@@ -292,3 +297,11 @@ func (r *Response) Write(w io.Writer) error {
 	// Success
 	return nil
 }
+
+// isProtocolSwitch reports whether r is a 101 Switching Protocols
+// response accepting an upgrade.
+func (r *Response) isProtocolSwitch() bool {
+	return r.StatusCode == StatusSwitchingProtocols &&
+		r.Header.get("Upgrade") != "" &&
+		hasToken(r.Header.get("Connection"), "upgrade")
+}
@@ -9,6 +9,7 @@ package http_test
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -416,6 +417,147 @@ func TestServeMuxHandlerRedirects(t *testing.T) {
 	}
 }
 
+func TestServeMuxPatterns(t *testing.T) {
+	mux := NewServeMux()
+	for _, pat := range []string{
+		"GET /users/{id}",
+		"GET /users/{id}/posts/{post}",
+		"DELETE /users/{id}",
+		"GET /users/admin",
+		"POST /users/{$}",
+		"/files/{path...}",
+		"GET /files/readme",
+		"/",
+		"GET /{$}",
+		"example.com/users/{id}",
+	} {
+		pat := pat
+		mux.HandleFunc(pat, func(w ResponseWriter, r *Request) {
+			io.WriteString(w, pat)
+			for _, name := range []string{"id", "post", "path"} {
+				if v := r.PathValue(name); v != "" {
+					fmt.Fprintf(w, " %s=%s", name, v)
+				}
+			}
+		})
+	}
+
+	tests := []struct {
+		method, host, path string
+		code               int
+		body               string
+	}{
+		{"GET", "", "/users/42", 200, "GET /users/{id} id=42"},
+		{"HEAD", "", "/users/42", 200, "GET /users/{id} id=42"},
+		{"DELETE", "", "/users/42", 200, "DELETE /users/{id} id=42"},
+		{"GET", "", "/users/42/posts/7", 200, "GET /users/{id}/posts/{post} id=42 post=7"},
+		{"GET", "", "/users/admin", 200, "GET /users/admin"},
+		{"PUT", "", "/users/admin", 200, "/"},
+		{"POST", "", "/users/", 200, "POST /users/{$}"},
+		{"GET", "", "/users/", 200, "/"},
+		{"GET", "", "/files/a/b/c.txt", 200, "/files/{path...} path=a/b/c.txt"},
+		{"GET", "", "/files/readme", 200, "GET /files/readme"},
+		{"POST", "", "/files/readme", 200, "/files/{path...} path=readme"},
+		{"GET", "", "/", 200, "GET /{$}"},
+		{"GET", "", "/other", 200, "/"},
+		{"GET", "example.com", "/users/42", 200, "example.com/users/{id} id=42"},
+		{"GET", "", "/files", 301, ""},
+	}
+	for _, tt := range tests {
+		r := &Request{Method: tt.method, Host: tt.host, URL: &url.URL{Path: tt.path}}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, r)
+		if rr.Code != tt.code {
+			t.Errorf("%s %s%s: code = %d; want %d", tt.method, tt.host, tt.path, rr.Code, tt.code)
+			continue
+		}
+		if tt.code == 200 && rr.Body.String() != tt.body {
+			t.Errorf("%s %s%s: body = %q; want %q", tt.method, tt.host, tt.path, rr.Body.String(), tt.body)
+		}
+	}
+
+	// Without the catch-all "/", other methods get a 405.
+	mux = NewServeMux()
+	mux.Handle("GET /users/{id}", NotFoundHandler())
+	mux.Handle("DELETE /users/{id}", NotFoundHandler())
+	r := &Request{Method: "PUT", URL: &url.URL{Path: "/users/42"}}
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, r)
+	if rr.Code != StatusMethodNotAllowed {
+		t.Fatalf("PUT /users/42: code = %d; want 405", rr.Code)
+	}
+	if got, want := rr.HeaderMap.Get("Allow"), "DELETE, GET, HEAD"; got != want {
+		t.Errorf("PUT /users/42: Allow = %q; want %q", got, want)
+	}
+}
+
+func TestServeMuxRegisterPanics(t *testing.T) {
+	for _, tt := range []struct {
+		existing, pattern string
+	}{
+		{"/a", "/a"},
+		{"GET /a/{x}", "GET /a/{y}"},
+		{"GET /posts/{id}", "/posts/latest"},
+		{"GET /", "/index.html"},
+		{"/a/{x}/c", "/a/b/{y}"},
+		{"", "/{x}/{x}"},
+		{"", "BAD METHOD /"},
+	} {
+		mux := NewServeMux()
+		if tt.existing != "" {
+			mux.Handle(tt.existing, NotFoundHandler())
+		}
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("registering %q after %q did not panic", tt.pattern, tt.existing)
+				}
+			}()
+			mux.Handle(tt.pattern, NotFoundHandler())
+		}()
+	}
+
+	// Disjoint patterns, or ones where one is more specific, are fine,
+	// as are the same patterns for different hosts.
+	mux := NewServeMux()
+	for _, pat := range []string{
+		"GET /posts/{id}",
+		"POST /posts/{id}",
+		"GET /posts/latest",
+		"/posts/",
+		"example.com/posts/latest",
+	} {
+		mux.Handle(pat, NotFoundHandler())
+	}
+}
+
+func TestRequestPathValue(t *testing.T) {
+	defer afterTest(t)
+	mux := NewServeMux()
+	mux.HandleFunc("/{kind}/{name...}", func(w ResponseWriter, r *Request) {
+		r.SetPathValue("name", strings.ToUpper(r.PathValue("name")))
+		r.SetPathValue("extra", "x")
+		fmt.Fprintf(w, "%s %s %s %q", r.PathValue("kind"), r.PathValue("name"), r.PathValue("extra"), r.PathValue("missing"))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	res, err := Get(ts.URL + "/pkg/net/http%2Fpattern")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if got, want := string(body), `pkg NET/HTTP/PATTERN x ""`; got != want {
+		t.Errorf("body = %q; want %q", got, want)
+	}
+
+	var r Request
+	if v := r.PathValue("x"); v != "" {
+		t.Errorf("PathValue of unmatched request = %q; want empty", v)
+	}
+}
+
 // Tests for http://golang.org/issue/900
 func TestMuxRedirectLeadingSlashes(t *testing.T) {
 	paths := []string{"//foo.txt", "///foo.txt", "/../../foo.txt"}
@@ -1762,6 +1904,64 @@ For:
 	ts.Close()
 }
 
+// Tests that a request's context is canceled when the client goes
+// away, and that it works alongside CloseNotify.
+func TestServerContextClientGone(t *testing.T) {
+	defer afterTest(t)
+	gotReq := make(chan bool, 1)
+	sawDone := make(chan error, 1)
+	ts := httptest.NewServer(HandlerFunc(func(rw ResponseWriter, req *Request) {
+		cn := rw.(CloseNotifier).CloseNotify()
+		ctx := req.Context()
+		gotReq <- true
+		<-ctx.Done()
+		<-cn
+		sawDone <- ctx.Err()
+	}))
+	defer ts.Close()
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("error dialing: %v", err)
+	}
+	if _, err := fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: foo\r\n\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	<-gotReq
+	conn.Close()
+	select {
+	case err := <-sawDone:
+		if err != context.Canceled {
+			t.Errorf("ctx.Err() = %v; want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the request context to be canceled")
+	}
+}
+
+// Tests that a request's context is canceled once its handler returns.
+func TestServerContextCanceledAfterHandler(t *testing.T) {
+	defer afterTest(t)
+	ctxc := make(chan context.Context, 1)
+	ts := httptest.NewServer(HandlerFunc(func(rw ResponseWriter, req *Request) {
+		if err := req.Context().Err(); err != nil {
+			t.Errorf("context done while handler running: %v", err)
+		}
+		ctxc <- req.Context()
+	}))
+	defer ts.Close()
+	res, err := Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	ctx := <-ctxc
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("context not canceled after handler returned")
+	}
+}
+
 func TestCloseNotifierChanLeak(t *testing.T) {
 	defer afterTest(t)
 	req := reqBytes("GET / HTTP/1.0\nHost: golang.org")
@@ -2588,6 +2788,178 @@ func TestServerKeepAlivesEnabled(t *testing.T) {
 	}
 }
 
+func TestServerShutdown(t *testing.T) {
+	defer afterTest(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inHandler := make(chan bool)
+	unblock := make(chan bool)
+	srv := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		inHandler <- true
+		<-unblock
+		io.WriteString(w, "done")
+	})}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.Serve(ln) }()
+
+	gotRes := make(chan *Response, 1)
+	go func() {
+		res, err := Get("http://" + ln.Addr().String())
+		if err != nil {
+			t.Error(err)
+			gotRes <- nil
+			return
+		}
+		gotRes <- res
+	}()
+	<-inHandler
+
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- srv.Shutdown(0) }()
+
+	if err := <-serveErr; err != ErrServerClosed {
+		t.Errorf("Serve = %v; want ErrServerClosed", err)
+	}
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("Shutdown returned %v with a request in flight", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(unblock)
+	res := <-gotRes
+	if res == nil {
+		return
+	}
+	slurp, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(slurp) != "done" {
+		t.Errorf("body = %q; want %q", slurp, "done")
+	}
+	if !res.Close {
+		t.Errorf("response to in-flight request during Shutdown didn't close the connection")
+	}
+	select {
+	case err := <-shutdownErr:
+		if err != nil {
+			t.Errorf("Shutdown = %v; want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown didn't return after the handler finished")
+	}
+	if err := srv.Serve(ln); err != ErrServerClosed {
+		t.Errorf("Serve after Shutdown = %v; want ErrServerClosed", err)
+	}
+}
+
+func TestServerShutdownTimeout(t *testing.T) {
+	defer afterTest(t)
+	unblock := make(chan bool)
+	inHandler := make(chan bool)
+	ts := httptest.NewServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		inHandler <- true
+		<-unblock
+	}))
+	defer ts.Close()
+	defer close(unblock)
+	go func() {
+		res, err := Get(ts.URL)
+		if err == nil {
+			res.Body.Close()
+		}
+	}()
+	<-inHandler
+	if err := ts.Config.Shutdown(50 * time.Millisecond); err != ErrShutdownTimeout {
+		t.Errorf("Shutdown = %v; want ErrShutdownTimeout", err)
+	}
+}
+
+func TestServerShutdownClosesIdleConns(t *testing.T) {
+	defer afterTest(t)
+	var mu sync.Mutex
+	var states []ConnState
+	ts := httptest.NewUnstartedServer(HandlerFunc(func(w ResponseWriter, r *Request) {}))
+	ts.Config.ConnState = func(c net.Conn, state ConnState) {
+		mu.Lock()
+		defer mu.Unlock()
+		states = append(states, state)
+	}
+	ts.Start()
+	defer ts.Close()
+
+	c, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := io.WriteString(c, "GET / HTTP/1.1\r\nHost: foo\r\n\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(c)
+	res, err := ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if err := ts.Config.Shutdown(5 * time.Second); err != nil {
+		t.Fatalf("Shutdown = %v", err)
+	}
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := br.ReadByte(); err != io.EOF {
+		t.Errorf("read from idle conn after Shutdown = %v; want EOF", err)
+	}
+
+	want := []ConnState{StateNew, StateActive, StateIdle, StateClosed}
+	for i := 0; i < 5; i++ {
+		time.Sleep(time.Duration(i) * 50 * time.Millisecond)
+		mu.Lock()
+		match := reflect.DeepEqual(states, want)
+		mu.Unlock()
+		if match {
+			return
+		}
+	}
+	mu.Lock()
+	t.Errorf("conn states = %v; want %v", states, want)
+	mu.Unlock()
+}
+
+func TestServerClose(t *testing.T) {
+	defer afterTest(t)
+	inHandler := make(chan bool)
+	unblock := make(chan bool)
+	ts := httptest.NewServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		inHandler <- true
+		<-unblock
+	}))
+	defer ts.Close()
+	defer close(unblock)
+
+	c, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := io.WriteString(c, "GET / HTTP/1.1\r\nHost: foo\r\n\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	<-inHandler
+	ts.Config.Close()
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("read from active conn after Close = %v; want EOF", err)
+	}
+	if _, err := Get(ts.URL); err == nil {
+		t.Error("Get after Close succeeded; want error")
+	}
+}
+
 // golang.org/issue/7856
 func TestServerEmptyBodyRace(t *testing.T) {
 	defer afterTest(t)
@@ -8,6 +8,7 @@ package http
 
 import (
 	"bufio"
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -20,11 +21,14 @@ import (
 	"os"
 	"path"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"net/http/internal/http2"
 )
 
 // Errors introduced by the HTTP server.
@@ -33,6 +37,14 @@ var (
 	ErrBodyNotAllowed  = errors.New("http: request method or response status code does not allow body")
 	ErrHijacked        = errors.New("Conn has been hijacked")
 	ErrContentLength   = errors.New("Conn.Write wrote more than the declared Content-Length")
+
+	// ErrServerClosed is returned by the Server's Serve and
+	// ListenAndServe methods after a call to Shutdown or Close.
+	ErrServerClosed = errors.New("http: Server closed")
+
+	// ErrShutdownTimeout is returned by Server.Shutdown when its
+	// timeout expires before all connections have become idle.
+	ErrShutdownTimeout = errors.New("http: Server shutdown timed out")
 )
 
 // Objects implementing the Handler interface can be
@@ -124,10 +136,13 @@ type conn struct {
 	buf        *bufio.ReadWriter    // buffered(lr,rwc), reading from bufio->limitReader->sr->rwc
 	tlsState   *tls.ConnectionState // or nil when not using TLS
 
-	mu           sync.Mutex // guards the following
-	clientGone   bool       // if client has disconnected mid-request
-	closeNotifyc chan bool  // made lazily
-	hijackedv    bool       // connection has been hijacked by handler
+	curState uint64 // packed (unixtime<<8|uint8(ConnState)); accessed atomically
+
+	mu           sync.Mutex    // guards the following
+	clientGone   bool          // if client has disconnected mid-request
+	closeNotifyc chan bool     // made lazily
+	gonec        chan struct{} // closed when clientGone is set; made with closeNotifyc
+	hijackedv    bool          // connection has been hijacked by handler
 }
 
 func (c *conn) hijacked() bool {
@@ -157,12 +172,31 @@ func (c *conn) hijack() (rwc net.Conn, buf *bufio.ReadWriter, err error) {
 func (c *conn) closeNotify() <-chan bool {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.watchClientLocked()
+	return c.closeNotifyc
+}
+
+// clientGoneChan returns a channel that is closed when the client's
+// connection goes away. Like closeNotify, it starts the background
+// read watching for that.
+func (c *conn) clientGoneChan() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.watchClientLocked()
+	return c.gonec
+}
+
+// watchClientLocked starts, if it isn't already running, the
+// background read that notices the client going away. c.mu must be
+// held.
+func (c *conn) watchClientLocked() {
 	if c.closeNotifyc == nil {
 		c.closeNotifyc = make(chan bool, 1)
+		c.gonec = make(chan struct{})
 		if c.hijackedv {
 			// to obey the function signature, even though
 			// it'll never receive a value.
-			return c.closeNotifyc
+			return
 		}
 		pr, pw := io.Pipe()
 
@@ -179,7 +213,6 @@ func (c *conn) closeNotify() <-chan bool {
 			c.noteClientGone()
 		}()
 	}
-	return c.closeNotifyc
 }
 
 func (c *conn) noteClientGone() {
@@ -187,10 +220,49 @@ func (c *conn) noteClientGone() {
 	defer c.mu.Unlock()
 	if c.closeNotifyc != nil && !c.clientGone {
 		c.closeNotifyc <- true
+		close(c.gonec)
 	}
 	c.clientGone = true
 }
 
+// A connRequestContext is the context of a request read from an
+// HTTP/1 connection. It is canceled when the handler returns or the
+// client goes away. Noticing the latter needs a background read of
+// the connection, which is incompatible with Hijack, so it is only
+// started once somebody asks for the Done channel, and never for a
+// request asking for a protocol upgrade, whose handler is likely to
+// hijack the connection.
+type connRequestContext struct {
+	context.Context // from context.WithCancel
+	cancel          context.CancelFunc
+	c               *conn
+	watchClient     bool
+	once            sync.Once
+}
+
+func newConnRequestContext(c *conn, watchClient bool) *connRequestContext {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &connRequestContext{Context: ctx, cancel: cancel, c: c, watchClient: watchClient}
+}
+
+func (ctx *connRequestContext) Done() <-chan struct{} {
+	done := ctx.Context.Done()
+	if !ctx.watchClient {
+		return done
+	}
+	ctx.once.Do(func() {
+		gone := ctx.c.clientGoneChan()
+		go func() {
+			select {
+			case <-gone:
+				ctx.cancel()
+			case <-done:
+			}
+		}()
+	})
+	return done
+}
+
 // A switchWriter can have its Writer changed at runtime.
 // It's not safe for concurrent Writes and switches.
 type switchWriter struct {
@@ -1166,11 +1238,27 @@ func validNPN(proto string) bool {
 }
 
 func (c *conn) setState(nc net.Conn, state ConnState) {
-	if hook := c.server.ConnState; hook != nil {
+	srv := c.server
+	switch state {
+	case StateNew:
+		srv.trackConn(c, nc, true)
+	case StateHijacked, StateClosed:
+		srv.trackConn(c, nc, false)
+	}
+	packed := uint64(time.Now().Unix()<<8) | uint64(state)
+	atomic.StoreUint64(&c.curState, packed)
+	if hook := srv.ConnState; hook != nil {
 		hook(nc, state)
 	}
 }
 
+// getState returns the connection's current state and the time
+// it entered that state.
+func (c *conn) getState() (state ConnState, unixSec int64) {
+	packed := atomic.LoadUint64(&c.curState)
+	return ConnState(packed & 0xff), int64(packed >> 8)
+}
+
 // Serve a new connection.
 func (c *conn) serve() {
 	origConn := c.rwc // copy it before it's set nil on Close or Hijack
@@ -1202,7 +1290,7 @@ func (c *conn) serve() {
 		*c.tlsState = tlsConn.ConnectionState()
 		if proto := c.tlsState.NegotiatedProtocol; validNPN(proto) {
 			if fn := c.server.TLSNextProto[proto]; fn != nil {
-				h := initNPNRequest{tlsConn, serverHandler{c.server}}
+				h := initNPNRequest{tlsConn, serverHandler{c.server}, c}
 				fn(c.server, tlsConn, h)
 			}
 			return
@@ -1252,7 +1340,10 @@ func (c *conn) serve() {
 		// so we might as well run the handler in this goroutine.
 		// [*] Not strictly true: HTTP pipelining.  We could let them all process
 		// in parallel even if their responses need to be serialized.
+		ctx := newConnRequestContext(c, !req.wantsUpgrade())
+		req.ctx = ctx
 		serverHandler{c.server}.ServeHTTP(w, w.req)
+		ctx.cancel()
 		if c.hijacked() {
 			return
 		}
@@ -1263,6 +1354,12 @@ func (c *conn) serve() {
 			}
 			break
 		}
+		if c.server.shuttingDown() {
+			// The response may have been sent with keep-alives
+			// still enabled, but the server is going away;
+			// don't wait around for another request.
+			break
+		}
 		c.setState(c.rwc, StateIdle)
 	}
 }
@@ -1447,59 +1544,73 @@ func RedirectHandler(url string, code int) Handler {
 // patterns and calls the handler for the pattern that
 // most closely matches the URL.
 //
-// Patterns name fixed, rooted paths, like "/favicon.ico",
-// or rooted subtrees, like "/images/" (note the trailing slash).
-// Longer patterns take precedence over shorter ones, so that
-// if there are handlers registered for both "/images/"
-// and "/images/thumbnails/", the latter handler will be
-// called for paths beginning "/images/thumbnails/" and the
-// former will receive requests for any other paths in the
-// "/images/" subtree.
+// Patterns have the form
+//
+//	[METHOD ][HOST]/[PATH]
+//
+// All three parts are optional except the slash, so "/" is a valid
+// pattern. A pattern with a method matches only requests with that
+// method, except that "GET" also matches "HEAD". A pattern with a host
+// matches only requests for that host.
+//
+// A path is a sequence of slash-separated segments. Each segment may be
+// literal text, matching only itself, or a wildcard "{NAME}", matching
+// any single non-empty segment. The last segment may instead be a
+// wildcard "{NAME...}", matching the rest of the path, including any
+// slashes. A pattern ending in a slash, like "/images/", names a rooted
+// subtree: it matches all paths beginning "/images/", as if it ended
+// in an anonymous "{...}" wildcard. To match only the path with the
+// trailing slash, end the pattern with "{$}", as in "/images/{$}".
+// The segments matched by wildcards are available from the request's
+// PathValue method. Matching is done on the request's decoded and
+// cleaned URL path.
 //
 // Note that since a pattern ending in a slash names a rooted subtree,
 // the pattern "/" matches all paths not matched by other registered
 // patterns, not just the URL with Path == "/".
 //
-// Patterns may optionally begin with a host name, restricting matches to
-// URLs on that host only.  Host-specific patterns take precedence over
-// general patterns, so that a handler might register for the two patterns
-// "/codesearch" and "codesearch.google.com/" without also taking over
-// requests for "http://www.google.com/".
+// If two or more patterns match a request, the most specific one takes
+// precedence: pattern P1 is more specific than P2 if P1 matches a strict
+// subset of P2's requests. So "/images/thumbnails/" is more specific
+// than "/images/", and "GET /posts/{id}" is more specific than both
+// "/posts/{id}" and "GET /posts/". Two patterns conflict if some
+// request matches both and neither is more specific, like
+// "GET /posts/{id}" and "/posts/latest"; registering a pattern that
+// conflicts with one already registered, or that matches exactly the
+// same requests, panics.
+//
+// Host-specific patterns take precedence over general patterns, so that
+// a handler might register for the two patterns "/codesearch" and
+// "codesearch.google.com/" without also taking over requests for
+// "http://www.google.com/".
+//
+// If no pattern matches a request but some would with a different
+// method, ServeMux replies "405 Method Not Allowed" with an Allow header
+// listing those methods.
 //
 // ServeMux also takes care of sanitizing the URL request path,
 // redirecting any request containing . or .. elements to an
-// equivalent .- and ..-free URL.
+// equivalent .- and ..-free URL. A request for a path without a
+// trailing slash is redirected to the path with the slash when only
+// that path is exactly matched, so that registering "/tree/" also
+// redirects requests for "/tree".
 type ServeMux struct {
-	mu    sync.RWMutex
-	m     map[string]muxEntry
-	hosts bool // whether any patterns contain hostnames
+	mu      sync.RWMutex
+	entries []*muxEntry
+	hosts   bool // whether any patterns contain hostnames
 }
 
 type muxEntry struct {
-	explicit bool
-	h        Handler
-	pattern  string
+	h   Handler
+	pat *pattern
 }
 
 // NewServeMux allocates and returns a new ServeMux.
-func NewServeMux() *ServeMux { return &ServeMux{m: make(map[string]muxEntry)} }
+func NewServeMux() *ServeMux { return new(ServeMux) }
 
 // DefaultServeMux is the default ServeMux used by Serve.
 var DefaultServeMux = NewServeMux()
 
-// Does path match pattern?
-func pathMatch(pattern, path string) bool {
-	if len(pattern) == 0 {
-		// should not happen
-		return false
-	}
-	n := len(pattern)
-	if pattern[n-1] != '/' {
-		return pattern == path
-	}
-	return len(path) >= n && path[0:n] == pattern
-}
-
 // Return the canonical path for p, eliminating . and .. elements.
 func cleanPath(p string) string {
 	if p == "" {
@@ -1517,18 +1628,23 @@ func cleanPath(p string) string {
 	return np
 }
 
-// Find a handler on a handler map given a path string
-// Most-specific (longest) pattern wins
-func (mux *ServeMux) match(path string) (h Handler, pattern string) {
-	var n = 0
-	for k, v := range mux.m {
-		if !pathMatch(k, path) {
+// matchLocked finds the most specific entry for the given host, method
+// and path among the patterns with that host. It returns the values of
+// the entry's wildcards and whether the match was exact, as described
+// by pattern.matchPath.
+func (mux *ServeMux) matchLocked(host, method, path string) (e *muxEntry, values []string, exact bool) {
+	for _, me := range mux.entries {
+		if me.pat.host != host || !me.pat.matchMethod(method) {
 			continue
 		}
-		if h == nil || len(k) > n {
-			n = len(k)
-			h = v.h
-			pattern = v.pattern
+		v, ex, ok := me.pat.matchPath(path)
+		if !ok {
+			continue
+		}
+		// Registration guarantees that of any two patterns
+		// matching the same request, one is more specific.
+		if e == nil || me.pat.compare(e.pat) == moreSpecific {
+			e, values, exact = me, v, ex
 		}
 	}
 	return
@@ -1547,35 +1663,93 @@ func (mux *ServeMux) match(path string) (h Handler, pattern string) {
 // If there is no registered handler that applies to the request,
 // Handler returns a ``page not found'' handler and an empty pattern.
 func (mux *ServeMux) Handler(r *Request) (h Handler, pattern string) {
+	h, pat, _ := mux.findHandler(r)
+	if pat != nil {
+		pattern = pat.str
+	}
+	return
+}
+
+// findHandler is the main implementation of Handler. It also
+// returns the values of the matched pattern's wildcards.
+func (mux *ServeMux) findHandler(r *Request) (h Handler, pat *pattern, values []string) {
+	path := r.URL.Path
 	if r.Method != "CONNECT" {
-		if p := cleanPath(r.URL.Path); p != r.URL.Path {
-			_, pattern = mux.handler(r.Host, p)
+		if p := cleanPath(path); p != path {
+			_, pat, _ = mux.handler(r.Host, r.Method, p)
 			url := *r.URL
 			url.Path = p
-			return RedirectHandler(url.String(), StatusMovedPermanently), pattern
+			return RedirectHandler(url.String(), StatusMovedPermanently), pat, nil
 		}
 	}
 
-	return mux.handler(r.Host, r.URL.Path)
+	h, pat, values = mux.handler(r.Host, r.Method, path)
+	if path != "" && !strings.HasSuffix(path, "/") && (pat == nil || pat.lastSegment().multi) {
+		// The match, if any, is not exact. Redirect /tree to
+		// /tree/ if that is matched exactly.
+		if _, pat2, _ := mux.handler(r.Host, r.Method, path+"/"); pat2 != nil && pat2.lastSegment().multi {
+			if _, exact, _ := pat2.matchPath(path + "/"); exact {
+				u := &url.URL{Path: path + "/", RawQuery: r.URL.RawQuery}
+				return RedirectHandler(u.String(), StatusMovedPermanently), pat2, nil
+			}
+		}
+	}
+	return
 }
 
-// handler is the main implementation of Handler.
-// The path is known to be in canonical form, except for CONNECT methods.
-func (mux *ServeMux) handler(host, path string) (h Handler, pattern string) {
+// handler returns the handler for the given host, method and path,
+// which is known to be in canonical form, except for CONNECT methods.
+func (mux *ServeMux) handler(host, method, path string) (h Handler, pat *pattern, values []string) {
 	mux.mu.RLock()
 	defer mux.mu.RUnlock()
 
 	// Host-specific pattern takes precedence over generic ones
+	var e *muxEntry
 	if mux.hosts {
-		h, pattern = mux.match(host + path)
+		e, values, _ = mux.matchLocked(host, method, path)
 	}
-	if h == nil {
-		h, pattern = mux.match(path)
+	if e == nil {
+		e, values, _ = mux.matchLocked("", method, path)
 	}
-	if h == nil {
-		h, pattern = NotFoundHandler(), ""
+	if e != nil {
+		return e.h, e.pat, values
 	}
-	return
+	if allow := mux.allowedMethodsLocked(host, path); len(allow) > 0 {
+		return methodNotAllowedHandler(allow), nil, nil
+	}
+	return NotFoundHandler(), nil, nil
+}
+
+// allowedMethodsLocked returns the sorted methods of the patterns
+// matching host and path.
+func (mux *ServeMux) allowedMethodsLocked(host, path string) []string {
+	set := make(map[string]bool)
+	for _, e := range mux.entries {
+		if e.pat.host != "" && e.pat.host != host || e.pat.method == "" {
+			continue
+		}
+		if _, _, ok := e.pat.matchPath(path); ok {
+			set[e.pat.method] = true
+			if e.pat.method == "GET" {
+				set["HEAD"] = true
+			}
+		}
+	}
+	var allow []string
+	for m := range set {
+		allow = append(allow, m)
+	}
+	sort.Strings(allow)
+	return allow
+}
+
+// methodNotAllowedHandler returns a handler that replies to each
+// request with a 405 error listing the allowed methods.
+func methodNotAllowedHandler(allow []string) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+		Error(w, StatusText(StatusMethodNotAllowed), StatusMethodNotAllowed)
+	})
 }
 
 // ServeHTTP dispatches the request to the handler whose
@@ -1588,47 +1762,41 @@ func (mux *ServeMux) ServeHTTP(w ResponseWriter, r *Request) {
 		w.WriteHeader(StatusBadRequest)
 		return
 	}
-	h, _ := mux.Handler(r)
+	h, pat, values := mux.findHandler(r)
+	r.pat, r.pathValues = pat, values
 	h.ServeHTTP(w, r)
 }
 
 // Handle registers the handler for the given pattern.
-// If a handler already exists for pattern, Handle panics.
+// If the pattern is invalid, or conflicts with or duplicates a
+// registered pattern, Handle panics.
 func (mux *ServeMux) Handle(pattern string, handler Handler) {
 	mux.mu.Lock()
 	defer mux.mu.Unlock()
 
-	if pattern == "" {
-		panic("http: invalid pattern " + pattern)
+	pat, err := parsePattern(pattern)
+	if err != nil {
+		panic(fmt.Sprintf("http: invalid pattern %q: %v", pattern, err))
 	}
 	if handler == nil {
 		panic("http: nil handler")
 	}
-	if mux.m[pattern].explicit {
-		panic("http: multiple registrations for " + pattern)
+	for _, e := range mux.entries {
+		if e.pat.host != pat.host {
+			continue
+		}
+		switch pat.compare(e.pat) {
+		case equivalent:
+			panic("http: multiple registrations for " + pattern)
+		case overlaps:
+			panic(fmt.Sprintf("http: pattern %q conflicts with registered pattern %q", pattern, e.pat.str))
+		}
 	}
 
-	mux.m[pattern] = muxEntry{explicit: true, h: handler, pattern: pattern}
-
-	if pattern[0] != '/' {
+	mux.entries = append(mux.entries, &muxEntry{h: handler, pat: pat})
+	if pat.host != "" {
 		mux.hosts = true
 	}
-
-	// Helpful behavior:
-	// If pattern is /tree/, insert an implicit permanent redirect for /tree.
-	// It can be overridden by an explicit registration.
-	n := len(pattern)
-	if n > 0 && pattern[n-1] == '/' && !mux.m[pattern[0:n-1]].explicit {
-		// If pattern contains a host name, strip it and use remaining
-		// path for redirect.
-		path := pattern
-		if pattern[0] != '/' {
-			// In pattern, at least the last character is a '/', so
-			// strings.Index can't be -1.
-			path = pattern[strings.Index(pattern, "/"):]
-		}
-		mux.m[pattern[0:n-1]] = muxEntry{h: RedirectHandler(path, StatusMovedPermanently), pattern: pattern}
-	}
 }
 
 // HandleFunc registers the handler function for the given pattern.
@@ -1674,6 +1842,10 @@ type Server struct {
 	// handle HTTP requests and will initialize the Request's TLS
 	// and RemoteAddr if not already set.  The connection is
 	// automatically closed when the function returns.
+	//
+	// If TLSNextProto is nil, it is set when the Server starts
+	// serving to a map enabling HTTP/2 ("h2"). To disable HTTP/2,
+	// set it to a non-nil, empty map.
 	TLSNextProto map[string]func(*Server, *tls.Conn, Handler)
 
 	// ConnState specifies an optional callback function that is
@@ -1688,6 +1860,14 @@ type Server struct {
 	ErrorLog *log.Logger
 
 	disableKeepAlives int32 // accessed atomically.
+	inShutdown        int32 // accessed atomically (non-zero means we're in Shutdown)
+
+	nextProtoOnce sync.Once // guards setupHTTP2
+
+	mu         sync.Mutex
+	listeners  map[net.Listener]struct{}
+	activeConn map[*conn]net.Conn // conn -> the net.Conn it was accepted as
+	onShutdown []func()           // run by Shutdown, e.g. to send HTTP/2 GOAWAY
 }
 
 // A ConnState represents the state of a client connection to a server.
@@ -1758,6 +1938,9 @@ func (sh serverHandler) ServeHTTP(rw ResponseWriter, req *Request) {
 // calls Serve to handle requests on incoming connections.  If
 // srv.Addr is blank, ":http" is used.
 func (srv *Server) ListenAndServe() error {
+	if srv.shuttingDown() {
+		return ErrServerClosed
+	}
 	addr := srv.Addr
 	if addr == "" {
 		addr = ":http"
@@ -1772,12 +1955,22 @@ func (srv *Server) ListenAndServe() error {
 // Serve accepts incoming connections on the Listener l, creating a
 // new service goroutine for each.  The service goroutines read requests and
 // then call srv.Handler to reply to them.
+//
+// After Shutdown or Close, Serve returns ErrServerClosed.
 func (srv *Server) Serve(l net.Listener) error {
 	defer l.Close()
+	if !srv.trackListener(l, true) {
+		return ErrServerClosed
+	}
+	defer srv.trackListener(l, false)
+	srv.setupHTTP2()
 	var tempDelay time.Duration // how long to sleep on accept failure
 	for {
 		rw, e := l.Accept()
 		if e != nil {
+			if srv.shuttingDown() {
+				return ErrServerClosed
+			}
 			if ne, ok := e.(net.Error); ok && ne.Temporary() {
 				if tempDelay == 0 {
 					tempDelay = 5 * time.Millisecond
@@ -1804,7 +1997,156 @@ func (srv *Server) Serve(l net.Listener) error {
 }
 
 func (s *Server) doKeepAlives() bool {
-	return atomic.LoadInt32(&s.disableKeepAlives) == 0
+	return atomic.LoadInt32(&s.disableKeepAlives) == 0 && !s.shuttingDown()
+}
+
+func (s *Server) shuttingDown() bool {
+	return atomic.LoadInt32(&s.inShutdown) != 0
+}
+
+// trackListener adds or removes l from the set of listeners that
+// Shutdown and Close will close. It reports false if the listener
+// could not be added because the server is already shutting down.
+func (s *Server) trackListener(l net.Listener, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if add {
+		if s.shuttingDown() {
+			return false
+		}
+		if s.listeners == nil {
+			s.listeners = make(map[net.Listener]struct{})
+		}
+		s.listeners[l] = struct{}{}
+	} else {
+		delete(s.listeners, l)
+	}
+	return true
+}
+
+func (s *Server) trackConn(c *conn, nc net.Conn, add bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if add {
+		if s.activeConn == nil {
+			s.activeConn = make(map[*conn]net.Conn)
+		}
+		s.activeConn[c] = nc
+	} else {
+		delete(s.activeConn, c)
+	}
+}
+
+// closeListenersLocked closes all tracked listeners and returns the
+// first error encountered. s.mu must be held.
+func (s *Server) closeListenersLocked() error {
+	var err error
+	for l := range s.listeners {
+		if cerr := l.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(s.listeners, l)
+	}
+	return err
+}
+
+// shutdownPollInterval is how often Shutdown checks whether all
+// connections have become idle.
+const shutdownPollInterval = 500 * time.Millisecond
+
+// newConnIdleAge is how long a connection may sit in StateNew without
+// sending a request before Shutdown treats it as idle.
+const newConnIdleAge = 5 * time.Second
+
+// Shutdown gracefully shuts down the server without interrupting any
+// active connections. Shutdown works by first closing all open
+// listeners, then closing all idle connections, and then waiting
+// for the remaining connections to finish their current request and
+// become idle, at which point they too are closed. Keep-alives are
+// disabled for any response written after Shutdown begins.
+//
+// If timeout is positive and connections are still active once it
+// has elapsed, Shutdown returns ErrShutdownTimeout, leaving those
+// connections open; Close may be used to terminate them. A zero
+// timeout waits indefinitely. Otherwise Shutdown returns nil, or the
+// first error from closing the Server's listeners.
+//
+// Shutdown does not attempt to close nor wait for hijacked
+// connections. Once Shutdown has been called, Serve and
+// ListenAndServe return ErrServerClosed.
+func (s *Server) Shutdown(timeout time.Duration) error {
+	atomic.StoreInt32(&s.inShutdown, 1)
+
+	s.mu.Lock()
+	lnerr := s.closeListenersLocked()
+	for _, f := range s.onShutdown {
+		go f()
+	}
+	s.mu.Unlock()
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		deadline = t.C
+	}
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		if s.closeIdleConns() {
+			return lnerr
+		}
+		select {
+		case <-deadline:
+			return ErrShutdownTimeout
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close immediately closes all active listeners and all connections
+// in state StateNew, StateActive, or StateIdle, without waiting for
+// in-flight requests to finish. For a graceful shutdown, use Shutdown.
+//
+// Close does not attempt to close (and does not even know about)
+// any hijacked connections.
+//
+// Close returns any error returned from closing the Server's
+// underlying listeners.
+func (s *Server) Close() error {
+	atomic.StoreInt32(&s.inShutdown, 1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.closeListenersLocked()
+	for c, nc := range s.activeConn {
+		nc.Close()
+		delete(s.activeConn, c)
+	}
+	return err
+}
+
+// closeIdleConns closes all idle connections and reports whether the
+// server is quiescent.
+func (s *Server) closeIdleConns() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	quiescent := true
+	for c, nc := range s.activeConn {
+		st, unixSec := c.getState()
+		// A connection that was accepted but has not yet sent a
+		// request is treated as idle once it has been around
+		// long enough that it is unlikely to be about to.
+		if st == StateNew && unixSec < time.Now().Unix()-int64(newConnIdleAge/time.Second) {
+			st = StateIdle
+		}
+		if st != StateIdle {
+			quiescent = false
+			continue
+		}
+		nc.Close()
+		delete(s.activeConn, c)
+	}
+	return quiescent
 }
 
 // SetKeepAlivesEnabled controls whether HTTP keep-alives are enabled.
@@ -1901,7 +2243,14 @@ func ListenAndServeTLS(addr string, certFile string, keyFile string, handler Han
 // of the server's certificate followed by the CA's certificate.
 //
 // If srv.Addr is blank, ":https" is used.
+//
+// Unless srv.TLSConfig already lists its NextProtos, HTTP/2 is
+// offered to clients alongside HTTP/1.1 when it is enabled; see
+// TLSNextProto.
 func (srv *Server) ListenAndServeTLS(certFile, keyFile string) error {
+	if srv.shuttingDown() {
+		return ErrServerClosed
+	}
 	addr := srv.Addr
 	if addr == "" {
 		addr = ":https"
@@ -1910,8 +2259,12 @@ func (srv *Server) ListenAndServeTLS(certFile, keyFile string) error {
 	if srv.TLSConfig != nil {
 		*config = *srv.TLSConfig
 	}
+	srv.setupHTTP2()
 	if config.NextProtos == nil {
 		config.NextProtos = []string{"http/1.1"}
+		if _, ok := srv.TLSNextProto[http2.NextProtoTLS]; ok {
+			config.NextProtos = []string{http2.NextProtoTLS, "http/1.1"}
+		}
 	}
 
 	var err error
@@ -2071,8 +2424,9 @@ var _ io.WriterTo = eofReader
 // uninitialized fields in its *Request. Such partially-initialized
 // Requests come from NPN protocol handlers.
 type initNPNRequest struct {
-	c *tls.Conn
-	h serverHandler
+	c  *tls.Conn
+	h  serverHandler
+	hc *conn // for ConnState tracking by the protocol handler
 }
 
 func (h initNPNRequest) ServeHTTP(rw ResponseWriter, req *Request) {
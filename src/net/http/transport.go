@@ -12,12 +12,15 @@ package http
 import (
 	"bufio"
 	"compress/gzip"
+	"container/list"
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
+	"net/http/httptrace"
 	"net/url"
 	"os"
 	"strings"
@@ -32,10 +35,10 @@ import (
 // $no_proxy) environment variables.
 var DefaultTransport RoundTripper = &Transport{
 	Proxy: ProxyFromEnvironment,
-	Dial: (&net.Dialer{
+	DialContext: (&net.Dialer{
 		Timeout:   30 * time.Second,
 		KeepAlive: 30 * time.Second,
-	}).Dial,
+	}).DialContext,
 	TLSHandshakeTimeout: 10 * time.Second,
 }
 
@@ -51,6 +54,11 @@ type Transport struct {
 	wantIdle   bool // user has requested to close all idle conns
 	idleConn   map[connectMethodKey][]*persistConn
 	idleConnCh map[connectMethodKey]chan *persistConn
+	idleLRU    connLRU
+
+	connsPerHostMu   sync.Mutex
+	connsPerHost     map[connectMethodKey]int           // HTTP/1 conns dialing, in use or idle
+	connsPerHostWait map[connectMethodKey][]*connWaiter // requests waiting for MaxConnsPerHost
 
 	reqMu       sync.Mutex
 	reqCanceler map[*Request]func()
@@ -58,21 +66,37 @@ type Transport struct {
 	altMu    sync.RWMutex
 	altProto map[string]RoundTripper // nil or map of URI scheme => RoundTripper
 
+	nextProtoOnce sync.Once // guards initialization of TLSNextProto (onceSetNextProtoDefaults)
+
+	h2mu    sync.Mutex
+	h2conns map[connectMethodKey][]*h2ClientConn // shared HTTP/2 connections
+
 	// Proxy specifies a function to return a proxy for a given
 	// Request. If the function returns a non-nil error, the
 	// request is aborted with the provided error.
 	// If Proxy is nil or returns a nil *URL, no proxy is used.
 	Proxy func(*Request) (*url.URL, error)
 
+	// DialContext specifies the dial function for creating
+	// unencrypted TCP connections. The context is that of the
+	// request being sent.
+	// If DialContext is nil (and the deprecated Dial below is also
+	// nil), the Transport dials using package net.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+
 	// Dial specifies the dial function for creating unencrypted
 	// TCP connections.
-	// If Dial is nil, net.Dial is used.
+	//
+	// Deprecated: Use DialContext instead, which allows the
+	// transport to cancel dials as soon as they are no longer
+	// needed. If both are set, DialContext takes priority.
 	Dial func(network, addr string) (net.Conn, error)
 
 	// DialTLS specifies an optional dial function for creating
 	// TLS connections for non-proxied HTTPS requests.
 	//
-	// If DialTLS is nil, Dial and TLSClientConfig are used.
+	// If DialTLS is nil, DialContext (or Dial) and TLSClientConfig
+	// are used.
 	//
 	// If DialTLS is set, the Dial hook is not used for HTTPS
 	// requests and the TLSClientConfig and TLSHandshakeTimeout
@@ -102,19 +126,66 @@ type Transport struct {
 	// uncompressed.
 	DisableCompression bool
 
+	// MaxIdleConns controls the maximum number of idle (keep-alive)
+	// connections across all hosts. When it is exceeded, the
+	// connection that has been idle longest is closed.
+	// Zero means no limit.
+	MaxIdleConns int
+
 	// MaxIdleConnsPerHost, if non-zero, controls the maximum idle
 	// (keep-alive) to keep per-host.  If zero,
 	// DefaultMaxIdleConnsPerHost is used.
 	MaxIdleConnsPerHost int
 
+	// MaxConnsPerHost optionally limits the total number of
+	// connections per host, including connections in the dialing,
+	// active, and idle states. Requests that would exceed the limit
+	// wait, in the order they arrived, until a connection becomes
+	// idle or is closed, or until they are canceled. HTTP/2
+	// connections, which are shared by many requests, are not
+	// counted.
+	//
+	// Zero means no limit.
+	MaxConnsPerHost int
+
+	// IdleConnTimeout is the maximum amount of time an idle
+	// (keep-alive) connection will remain idle before closing
+	// itself.
+	// Zero means no limit.
+	IdleConnTimeout time.Duration
+
 	// ResponseHeaderTimeout, if non-zero, specifies the amount of
 	// time to wait for a server's response headers after fully
 	// writing the request (including its body, if any). This
 	// time does not include the time to read the response body.
 	ResponseHeaderTimeout time.Duration
 
-	// TODO: tunable on global max cached connections
-	// TODO: tunable on timeout on cached connections
+	// ExpectContinueTimeout, if non-zero, specifies the amount of
+	// time to wait for a server's first response headers after fully
+	// writing the request headers if the request has an
+	// "Expect: 100-continue" header. If the server replies with a
+	// final status instead of "100 Continue", the body is not sent.
+	// Zero means no timeout and causes the body to be sent
+	// immediately, without waiting for the server to approve.
+	// This time does not include the time to send the request header.
+	ExpectContinueTimeout time.Duration
+
+	// TLSNextProto specifies how the Transport switches to an
+	// alternate protocol (such as HTTP/2) after a TLS NPN/ALPN
+	// protocol negotiation. If Transport dials a TLS connection
+	// with a non-empty protocol name and TLSNextProto contains a
+	// map entry for that key (such as "h2"), then the func is
+	// called with the request's authority (such as "example.com"
+	// or "example.com:1234") and the TLS connection. The function
+	// must return a RoundTripper that then handles the request.
+	// Unless the connection is HTTP/2, it is used only for that
+	// request.
+	//
+	// If TLSNextProto is nil, HTTP/2 support is enabled
+	// automatically, and the Transport offers "h2" during the TLS
+	// handshake unless TLSClientConfig lists its own NextProtos.
+	// To disable HTTP/2, set TLSNextProto to a non-nil, empty map.
+	TLSNextProto map[string]func(authority string, c *tls.Conn) RoundTripper
 }
 
 // ProxyFromEnvironment returns the URL of the proxy to use for a
@@ -214,25 +285,55 @@ func (t *Transport) RoundTrip(req *Request) (resp *Response, err error) {
 		req.closeBody()
 		return nil, errors.New("http: no Host in request URL")
 	}
+	t.nextProtoOnce.Do(t.onceSetNextProtoDefaults)
 	treq := &transportRequest{Request: req}
 	cm, err := t.connectMethodForRequest(treq)
 	if err != nil {
 		req.closeBody()
 		return nil, err
 	}
+	trace := httptrace.ContextClientTrace(req.Context())
 
-	// Get the cached or newly-created connection to either the
-	// host (for http or https), the http proxy, or the http proxy
-	// pre-CONNECTed to https server.  In any case, we'll be ready
-	// to send it requests.
-	pconn, err := t.getConn(req, cm)
-	if err != nil {
-		t.setReqCanceler(req, nil)
-		req.closeBody()
-		return nil, err
-	}
+	for {
+		if trace != nil && trace.GetConn != nil {
+			trace.GetConn(cm.addr())
+		}
 
-	return pconn.roundTrip(treq)
+		// Requests to an https server share any HTTP/2
+		// connection already established to it.
+		if cm.targetScheme == "https" {
+			if cc := t.getH2Conn(cm.key()); cc != nil {
+				resp, err := cc.RoundTrip(req)
+				if err == errH2ClientConnUnusable {
+					continue
+				}
+				return resp, err
+			}
+		}
+
+		// Get the cached or newly-created connection to either the
+		// host (for http or https), the http proxy, or the http proxy
+		// pre-CONNECTed to https server.  In any case, we'll be ready
+		// to send it requests.
+		pconn, err := t.getConn(req, cm)
+		if err != nil {
+			t.setReqCanceler(req, nil)
+			req.closeBody()
+			return nil, err
+		}
+
+		if pconn.alt != nil {
+			// The server negotiated an alternate protocol.
+			t.setReqCanceler(req, nil)
+			t.putIdleConn(pconn)
+			resp, err := pconn.alt.RoundTrip(req)
+			if err == errH2ClientConnUnusable {
+				continue
+			}
+			return resp, err
+		}
+		return pconn.roundTrip(treq)
+	}
 }
 
 // RegisterProtocol registers a new protocol with scheme.
@@ -265,6 +366,7 @@ func (t *Transport) CloseIdleConnections() {
 	m := t.idleConn
 	t.idleConn = nil
 	t.idleConnCh = nil
+	t.idleLRU = connLRU{}
 	t.wantIdle = true
 	t.idleMu.Unlock()
 	for _, conns := range m {
@@ -272,6 +374,46 @@ func (t *Transport) CloseIdleConnections() {
 			pconn.close()
 		}
 	}
+	t.closeIdleH2Conns()
+}
+
+// PoolStats describes the connections of a Transport's pool.
+type PoolStats struct {
+	Conns   int // HTTP/1 connections dialing, in use or idle
+	Idle    int // idle (keep-alive) connections
+	Waiting int // requests waiting for a connection because of MaxConnsPerHost
+}
+
+// PoolStats returns the state of t's connection pool, both in total
+// and per host. The per-host map is keyed by the "host:port" of the
+// target server or, for plain HTTP requests sent through a proxy, by
+// the proxy URL. HTTP/2 connections are not included.
+func (t *Transport) PoolStats() (total PoolStats, perHost map[string]PoolStats) {
+	perHost = make(map[string]PoolStats)
+	t.idleMu.Lock()
+	for key, conns := range t.idleConn {
+		ps := perHost[key.statsName()]
+		ps.Idle += len(conns)
+		perHost[key.statsName()] = ps
+		total.Idle += len(conns)
+	}
+	t.idleMu.Unlock()
+
+	t.connsPerHostMu.Lock()
+	for key, n := range t.connsPerHost {
+		ps := perHost[key.statsName()]
+		ps.Conns += n
+		perHost[key.statsName()] = ps
+		total.Conns += n
+	}
+	for key, ws := range t.connsPerHostWait {
+		ps := perHost[key.statsName()]
+		ps.Waiting += len(ws)
+		perHost[key.statsName()] = ps
+		total.Waiting += len(ws)
+	}
+	t.connsPerHostMu.Unlock()
+	return
 }
 
 // CancelRequest cancels an in-flight request by closing its
@@ -353,17 +495,40 @@ func (cm *connectMethod) proxyAuth() string {
 	return ""
 }
 
+var (
+	errKeepAlivesDisabled = errors.New("http: putIdleConn: keep alives disabled")
+	errConnBroken         = errors.New("http: putIdleConn: connection is in bad state")
+	errWantIdle           = errors.New("http: putIdleConn: CloseIdleConnections was called")
+	errTooManyIdle        = errors.New("http: putIdleConn: too many idle connections")
+	errAltNotReusable     = errors.New("http: putIdleConn: alternate protocol connection is not reusable")
+)
+
 // putIdleConn adds pconn to the list of idle persistent connections awaiting
 // a new request.
 // If pconn is no longer needed or not in a good state, putIdleConn
 // returns false.
 func (t *Transport) putIdleConn(pconn *persistConn) bool {
+	return t.tryPutIdleConn(pconn) == nil
+}
+
+// tryPutIdleConn is like putIdleConn but reports why pconn could
+// not be kept.
+func (t *Transport) tryPutIdleConn(pconn *persistConn) error {
+	if pconn.alt != nil {
+		// HTTP/2 connections are shared rather than idle;
+		// other alternate protocols serve a single request.
+		if cc, ok := pconn.alt.(*h2ClientConn); ok {
+			t.putH2Conn(pconn.cacheKey, cc)
+			return nil
+		}
+		return errAltNotReusable
+	}
 	if t.DisableKeepAlives || t.MaxIdleConnsPerHost < 0 {
 		pconn.close()
-		return false
+		return errKeepAlivesDisabled
 	}
 	if pconn.isBroken() {
-		return false
+		return errConnBroken
 	}
 	key := pconn.cacheKey
 	max := t.MaxIdleConnsPerHost
@@ -381,7 +546,7 @@ func (t *Transport) putIdleConn(pconn *persistConn) bool {
 		// first). Chrome calls this socket late binding.  See
 		// https://insouciant.org/tech/connection-management-in-chromium/
 		t.idleMu.Unlock()
-		return true
+		return nil
 	default:
 		if waitingDialer != nil {
 			// They had populated this, but their dial won
@@ -392,7 +557,11 @@ func (t *Transport) putIdleConn(pconn *persistConn) bool {
 	if t.wantIdle {
 		t.idleMu.Unlock()
 		pconn.close()
-		return false
+		return errWantIdle
+	}
+	if t.handOffToHostWaiter(pconn) {
+		t.idleMu.Unlock()
+		return nil
 	}
 	if t.idleConn == nil {
 		t.idleConn = make(map[connectMethodKey][]*persistConn)
@@ -400,7 +569,7 @@ func (t *Transport) putIdleConn(pconn *persistConn) bool {
 	if len(t.idleConn[key]) >= max {
 		t.idleMu.Unlock()
 		pconn.close()
-		return false
+		return errTooManyIdle
 	}
 	for _, exist := range t.idleConn[key] {
 		if exist == pconn {
@@ -408,8 +577,22 @@ func (t *Transport) putIdleConn(pconn *persistConn) bool {
 		}
 	}
 	t.idleConn[key] = append(t.idleConn[key], pconn)
+	t.idleLRU.add(pconn)
+	if t.MaxIdleConns != 0 && t.idleLRU.len() > t.MaxIdleConns {
+		oldest := t.idleLRU.removeOldest()
+		oldest.close()
+		t.removeIdleConnLocked(oldest)
+	}
+	if t.IdleConnTimeout > 0 {
+		if pconn.idleTimer != nil {
+			pconn.idleTimer.Reset(t.IdleConnTimeout)
+		} else {
+			pconn.idleTimer = time.AfterFunc(t.IdleConnTimeout, pconn.closeConnIfStillIdle)
+		}
+	}
+	pconn.idleAt = time.Now()
 	t.idleMu.Unlock()
-	return true
+	return nil
 }
 
 // getIdleConnCh returns a channel to receive and return idle
@@ -434,17 +617,19 @@ func (t *Transport) getIdleConnCh(cm connectMethod) chan *persistConn {
 	return ch
 }
 
-func (t *Transport) getIdleConn(cm connectMethod) (pconn *persistConn) {
+// getIdleConn returns an idle connection for cm, if any, along with
+// the time it became idle.
+func (t *Transport) getIdleConn(cm connectMethod) (pconn *persistConn, idleSince time.Time) {
 	key := cm.key()
 	t.idleMu.Lock()
 	defer t.idleMu.Unlock()
 	if t.idleConn == nil {
-		return nil
+		return nil, time.Time{}
 	}
 	for {
 		pconns, ok := t.idleConn[key]
 		if !ok {
-			return nil
+			return nil, time.Time{}
 		}
 		if len(pconns) == 1 {
 			pconn = pconns[0]
@@ -455,10 +640,147 @@ func (t *Transport) getIdleConn(cm connectMethod) (pconn *persistConn) {
 			pconn = pconns[len(pconns)-1]
 			t.idleConn[key] = pconns[:len(pconns)-1]
 		}
+		t.idleLRU.remove(pconn)
+		if pconn.idleTimer != nil && !pconn.idleTimer.Stop() {
+			// We picked this conn at the ~same time it was
+			// expiring, and closeConnIfStillIdle will find it
+			// no longer idle. Close it ourselves.
+			pconn.close()
+			continue
+		}
 		if !pconn.isBroken() {
-			return
+			return pconn, pconn.idleAt
+		}
+	}
+}
+
+// removeIdleConn removes pconn from the idle pool, if it is there.
+func (t *Transport) removeIdleConn(pconn *persistConn) {
+	t.idleMu.Lock()
+	defer t.idleMu.Unlock()
+	t.removeIdleConnLocked(pconn)
+}
+
+// t.idleMu must be held.
+func (t *Transport) removeIdleConnLocked(pconn *persistConn) bool {
+	t.idleLRU.remove(pconn)
+	key := pconn.cacheKey
+	pconns := t.idleConn[key]
+	for i, v := range pconns {
+		if v != pconn {
+			continue
+		}
+		copy(pconns[i:], pconns[i+1:])
+		pconns[len(pconns)-1] = nil
+		if len(pconns) == 1 {
+			delete(t.idleConn, key)
+		} else {
+			t.idleConn[key] = pconns[:len(pconns)-1]
+		}
+		return true
+	}
+	return false
+}
+
+// A connWaiter is a request waiting for a connection because of
+// MaxConnsPerHost.
+type connWaiter struct {
+	ready chan struct{} // closed once granted a new conn's slot or pc
+	pc    *persistConn  // idle conn handed to the waiter, if any
+}
+
+// reserveHostConn counts a new connection for key. If that would
+// exceed MaxConnsPerHost, it instead queues and returns a waiter,
+// which is granted the slot of a closed connection or handed the
+// next connection to become idle.
+func (t *Transport) reserveHostConn(key connectMethodKey) *connWaiter {
+	t.connsPerHostMu.Lock()
+	defer t.connsPerHostMu.Unlock()
+	if t.MaxConnsPerHost <= 0 || t.connsPerHost[key] < t.MaxConnsPerHost {
+		if t.connsPerHost == nil {
+			t.connsPerHost = make(map[connectMethodKey]int)
+		}
+		t.connsPerHost[key]++
+		return nil
+	}
+	w := &connWaiter{ready: make(chan struct{})}
+	if t.connsPerHostWait == nil {
+		t.connsPerHostWait = make(map[connectMethodKey][]*connWaiter)
+	}
+	t.connsPerHostWait[key] = append(t.connsPerHostWait[key], w)
+	return w
+}
+
+// releaseHostConn uncounts a connection for key, passing its slot to
+// the longest waiting request, if any.
+func (t *Transport) releaseHostConn(key connectMethodKey) {
+	t.connsPerHostMu.Lock()
+	defer t.connsPerHostMu.Unlock()
+	if w := t.popHostWaiterLocked(key); w != nil {
+		close(w.ready)
+		return
+	}
+	if t.connsPerHost[key] > 1 {
+		t.connsPerHost[key]--
+	} else {
+		delete(t.connsPerHost, key)
+	}
+}
+
+// handOffToHostWaiter gives pconn, which would otherwise become idle,
+// to the longest waiting request for its key. It reports whether
+// there was one.
+func (t *Transport) handOffToHostWaiter(pconn *persistConn) bool {
+	t.connsPerHostMu.Lock()
+	defer t.connsPerHostMu.Unlock()
+	w := t.popHostWaiterLocked(pconn.cacheKey)
+	if w == nil {
+		return false
+	}
+	w.pc = pconn
+	close(w.ready)
+	return true
+}
+
+// t.connsPerHostMu must be held.
+func (t *Transport) popHostWaiterLocked(key connectMethodKey) *connWaiter {
+	ws := t.connsPerHostWait[key]
+	if len(ws) == 0 {
+		return nil
+	}
+	w := ws[0]
+	if len(ws) == 1 {
+		delete(t.connsPerHostWait, key)
+	} else {
+		t.connsPerHostWait[key] = ws[1:]
+	}
+	return w
+}
+
+// cancelHostWait stops w from waiting. If w was granted a slot in the
+// meantime, the slot is released; if it was handed a connection, that
+// connection is returned.
+func (t *Transport) cancelHostWait(key connectMethodKey, w *connWaiter) *persistConn {
+	t.connsPerHostMu.Lock()
+	ws := t.connsPerHostWait[key]
+	for i, v := range ws {
+		if v != w {
+			continue
 		}
+		ws = append(ws[:i:i], ws[i+1:]...)
+		if len(ws) == 0 {
+			delete(t.connsPerHostWait, key)
+		} else {
+			t.connsPerHostWait[key] = ws
+		}
+		t.connsPerHostMu.Unlock()
+		return nil
+	}
+	t.connsPerHostMu.Unlock()
+	if w.pc == nil {
+		t.releaseHostConn(key)
 	}
+	return w.pc
 }
 
 func (t *Transport) setReqCanceler(r *Request, fn func()) {
@@ -474,11 +796,16 @@ func (t *Transport) setReqCanceler(r *Request, fn func()) {
 	}
 }
 
-func (t *Transport) dial(network, addr string) (c net.Conn, err error) {
+var zeroDialer net.Dialer
+
+func (t *Transport) dial(ctx context.Context, network, addr string) (c net.Conn, err error) {
+	if t.DialContext != nil {
+		return t.DialContext(ctx, network, addr)
+	}
 	if t.Dial != nil {
 		return t.Dial(network, addr)
 	}
-	return net.Dial(network, addr)
+	return zeroDialer.DialContext(ctx, network, addr)
 }
 
 // Testing hooks:
@@ -489,10 +816,51 @@ var prePendingDial, postPendingDial func()
 // and/or setting up TLS.  If this doesn't return an error, the persistConn
 // is ready to write requests to.
 func (t *Transport) getConn(req *Request, cm connectMethod) (*persistConn, error) {
-	if pc := t.getIdleConn(cm); pc != nil {
+	ctx := req.Context()
+	trace := httptrace.ContextClientTrace(ctx)
+	if pc, idleSince := t.getIdleConn(cm); pc != nil {
+		if trace != nil && trace.GotConn != nil {
+			trace.GotConn(pc.gotIdleConnTrace(idleSince))
+		}
 		return pc, nil
 	}
 
+	cancelc := make(chan struct{})
+	t.setReqCanceler(req, func() { close(cancelc) })
+
+	key := cm.key()
+	if w := t.reserveHostConn(key); w != nil {
+		// Wait for MaxConnsPerHost to allow a new connection. A
+		// connection may have become idle before w was queued.
+		pc, idleSince := t.getIdleConn(cm)
+		var err error
+		if pc == nil {
+			select {
+			case <-w.ready:
+				pc, w = w.pc, nil
+			case <-cancelc:
+				err = errRequestCanceledConn
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		}
+		if w != nil {
+			if extra := t.cancelHostWait(key, w); extra != nil {
+				t.putIdleConn(extra)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if pc != nil {
+			if trace != nil && trace.GotConn != nil {
+				trace.GotConn(pc.gotIdleConnTrace(idleSince))
+			}
+			return pc, nil
+		}
+		// Granted the slot of a closed connection; dial.
+	}
+
 	type dialRes struct {
 		pc  *persistConn
 		err error
@@ -513,18 +881,23 @@ func (t *Transport) getConn(req *Request, cm connectMethod) (*persistConn, error
 		}()
 	}
 
-	cancelc := make(chan struct{})
-	t.setReqCanceler(req, func() { close(cancelc) })
-
 	go func() {
-		pc, err := t.dialConn(cm)
+		pc, err := t.dialConn(ctx, cm)
+		if err != nil || pc.alt != nil {
+			// Not an HTTP/1 connection to count.
+			t.releaseHostConn(key)
+		}
 		dialc <- dialRes{pc, err}
 	}()
 
 	idleConnCh := t.getIdleConnCh(cm)
 	select {
 	case v := <-dialc:
-		// Our dial finished.
+		// Our dial finished. HTTP/2 connections report
+		// GotConn themselves when the request is sent.
+		if v.pc != nil && v.pc.alt == nil && trace != nil && trace.GotConn != nil {
+			trace.GotConn(httptrace.GotConnInfo{Conn: v.pc.conn})
+		}
 		return v.pc, v.err
 	case pc := <-idleConnCh:
 		// Another request finished first and its net.Conn
@@ -533,14 +906,20 @@ func (t *Transport) getConn(req *Request, cm connectMethod) (*persistConn, error
 		// But our dial is still going, so give it away
 		// when it finishes:
 		handlePendingDial()
+		if pc.alt == nil && trace != nil && trace.GotConn != nil {
+			trace.GotConn(httptrace.GotConnInfo{Conn: pc.conn, Reused: pc.isReused()})
+		}
 		return pc, nil
 	case <-cancelc:
 		handlePendingDial()
-		return nil, errors.New("net/http: request canceled while waiting for connection")
+		return nil, errRequestCanceledConn
+	case <-ctx.Done():
+		handlePendingDial()
+		return nil, ctx.Err()
 	}
 }
 
-func (t *Transport) dialConn(cm connectMethod) (*persistConn, error) {
+func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (*persistConn, error) {
 	pconn := &persistConn{
 		t:          t,
 		cacheKey:   cm.key(),
@@ -549,6 +928,7 @@ func (t *Transport) dialConn(cm connectMethod) (*persistConn, error) {
 		closech:    make(chan struct{}),
 		writeErrCh: make(chan error, 1),
 	}
+	trace := httptrace.ContextClientTrace(ctx)
 	tlsDial := t.DialTLS != nil && cm.targetScheme == "https" && cm.proxyURL == nil
 	if tlsDial {
 		var err error
@@ -557,11 +937,26 @@ func (t *Transport) dialConn(cm connectMethod) (*persistConn, error) {
 			return nil, err
 		}
 		if tc, ok := pconn.conn.(*tls.Conn); ok {
+			// Handshake here, in case DialTLS didn't. TLSNextProto
+			// below depends on it for knowing the connection state.
+			if trace != nil && trace.TLSHandshakeStart != nil {
+				trace.TLSHandshakeStart()
+			}
+			if err := tc.Handshake(); err != nil {
+				pconn.conn.Close()
+				if trace != nil && trace.TLSHandshakeDone != nil {
+					trace.TLSHandshakeDone(tls.ConnectionState{}, err)
+				}
+				return nil, err
+			}
 			cs := tc.ConnectionState()
+			if trace != nil && trace.TLSHandshakeDone != nil {
+				trace.TLSHandshakeDone(cs, nil)
+			}
 			pconn.tlsState = &cs
 		}
 	} else {
-		conn, err := t.dial("tcp", cm.addr())
+		conn, err := t.dial(ctx, "tcp", cm.addr())
 		if err != nil {
 			if cm.proxyURL != nil {
 				err = fmt.Errorf("http: error connecting to proxy %s: %v", cm.proxyURL, err)
@@ -624,8 +1019,20 @@ func (t *Transport) dialConn(cm connectMethod) (*persistConn, error) {
 				cfg = &clone
 			}
 		}
+		if cfg.NextProtos == nil {
+			if protos := t.nextProtos(); protos != nil {
+				if cfg == t.TLSClientConfig {
+					clone := *cfg // shallow clone
+					cfg = &clone
+				}
+				cfg.NextProtos = protos
+			}
+		}
 		plainConn := pconn.conn
 		tlsConn := tls.Client(plainConn, cfg)
+		if trace != nil && trace.TLSHandshakeStart != nil {
+			trace.TLSHandshakeStart()
+		}
 		errc := make(chan error, 2)
 		var timer *time.Timer // for canceling TLS handshake
 		if d := t.TLSHandshakeTimeout; d != 0 {
@@ -642,19 +1049,35 @@ func (t *Transport) dialConn(cm connectMethod) (*persistConn, error) {
 		}()
 		if err := <-errc; err != nil {
 			plainConn.Close()
+			if trace != nil && trace.TLSHandshakeDone != nil {
+				trace.TLSHandshakeDone(tls.ConnectionState{}, err)
+			}
 			return nil, err
 		}
 		if !cfg.InsecureSkipVerify {
 			if err := tlsConn.VerifyHostname(cfg.ServerName); err != nil {
 				plainConn.Close()
+				if trace != nil && trace.TLSHandshakeDone != nil {
+					trace.TLSHandshakeDone(tls.ConnectionState{}, err)
+				}
 				return nil, err
 			}
 		}
 		cs := tlsConn.ConnectionState()
+		if trace != nil && trace.TLSHandshakeDone != nil {
+			trace.TLSHandshakeDone(cs, nil)
+		}
 		pconn.tlsState = &cs
 		pconn.conn = tlsConn
 	}
 
+	if s := pconn.tlsState; s != nil && s.NegotiatedProtocolIsMutual && s.NegotiatedProtocol != "" {
+		if next, ok := t.TLSNextProto[s.NegotiatedProtocol]; ok {
+			alt := next(cm.targetAddr, pconn.conn.(*tls.Conn))
+			return &persistConn{t: t, cacheKey: pconn.cacheKey, alt: alt}, nil
+		}
+	}
+
 	pconn.br = bufio.NewReader(noteEOFReader{pconn.conn, &pconn.sawEOF})
 	pconn.bw = bufio.NewWriter(pconn.conn)
 	go pconn.readLoop()
@@ -662,6 +1085,8 @@ func (t *Transport) dialConn(cm connectMethod) (*persistConn, error) {
 	return pconn, nil
 }
 
+var errRequestCanceledConn = errors.New("net/http: request canceled while waiting for connection")
+
 // useProxy reports whether requests to addr should use a proxy,
 // according to the NO_PROXY or no_proxy environment variable.
 // addr is always a canonicalAddr with a host and port.
@@ -776,6 +1201,14 @@ type connectMethodKey struct {
 	proxy, scheme, addr string
 }
 
+// statsName returns the name of k's host in PoolStats.
+func (k connectMethodKey) statsName() string {
+	if k.addr == "" {
+		return k.proxy
+	}
+	return k.addr
+}
+
 func (k connectMethodKey) String() string {
 	// Only used by tests.
 	return fmt.Sprintf("%s|%s|%s", k.proxy, k.scheme, k.addr)
@@ -784,6 +1217,11 @@ func (k connectMethodKey) String() string {
 // persistConn wraps a connection, usually a persistent one
 // (but may be used for non-keep-alive requests as well)
 type persistConn struct {
+	// alt optionally specifies the TLS NextProto RoundTripper.
+	// This is used for HTTP/2 today and future protocols later.
+	// If it's non-nil, the rest of the fields are unused.
+	alt RoundTripper
+
 	t        *Transport
 	cacheKey connectMethodKey
 	conn     net.Conn
@@ -801,16 +1239,41 @@ type persistConn struct {
 	// whether or not a connection can be reused. Issue 7569.
 	writeErrCh chan error
 
+	idleAt    time.Time   // when the conn last became idle; guarded by t.idleMu
+	idleTimer *time.Timer // closes the conn after IdleConnTimeout; guarded by t.idleMu
+
 	lk                   sync.Mutex // guards following fields
 	numExpectedResponses int
 	closed               bool // whether conn has been closed
 	broken               bool // an error has happened on this connection; marked broken so it's not reused.
+	reused               bool // whether conn has had successful request/response and is being reused.
 	// mutateHeaderFunc is an optional func to modify extra
 	// headers on each outbound request before it's written. (the
 	// original Request given to RoundTrip is not modified)
 	mutateHeaderFunc func(Header)
 }
 
+// isReused reports whether this connection has already served a
+// request.
+func (pc *persistConn) isReused() bool {
+	pc.lk.Lock()
+	r := pc.reused
+	pc.lk.Unlock()
+	return r
+}
+
+// gotIdleConnTrace returns the GotConn trace information for a
+// connection taken from the idle pool.
+func (pc *persistConn) gotIdleConnTrace(idleSince time.Time) (t httptrace.GotConnInfo) {
+	t.Conn = pc.conn
+	t.Reused = pc.isReused()
+	t.WasIdle = true
+	if !idleSince.IsZero() {
+		t.IdleTime = time.Since(idleSince)
+	}
+	return
+}
+
 // isBroken reports whether this connection is in a known broken state.
 func (pc *persistConn) isBroken() bool {
 	pc.lk.Lock()
@@ -823,6 +1286,19 @@ func (pc *persistConn) cancelRequest() {
 	pc.conn.Close()
 }
 
+// closeConnIfStillIdle closes the connection if it's still sitting
+// idle. It's called when its IdleConnTimeout expires.
+func (pc *persistConn) closeConnIfStillIdle() {
+	t := pc.t
+	t.idleMu.Lock()
+	defer t.idleMu.Unlock()
+	if !t.removeIdleConnLocked(pc) {
+		// Not idle.
+		return
+	}
+	pc.close()
+}
+
 var remoteSideClosedFunc func(error) bool // or nil to use default
 
 func remoteSideClosed(err error) bool {
@@ -836,8 +1312,17 @@ func remoteSideClosed(err error) bool {
 }
 
 func (pc *persistConn) readLoop() {
+	defer pc.t.removeIdleConn(pc)
 	alive := true
 
+	tryPutIdleConn := func(trace *httptrace.ClientTrace) bool {
+		err := pc.t.tryPutIdleConn(pc)
+		if trace != nil && trace.PutIdleConn != nil && err != errKeepAlivesDisabled {
+			trace.PutIdleConn(err)
+		}
+		return err == nil
+	}
+
 	for alive {
 		pb, err := pc.br.Peek(1)
 
@@ -856,29 +1341,55 @@ func (pc *persistConn) readLoop() {
 		pc.lk.Unlock()
 
 		rc := <-pc.reqch
+		trace := httptrace.ContextClientTrace(rc.req.Context())
 
 		var resp *Response
 		if err == nil {
+			if trace != nil && trace.GotFirstResponseByte != nil {
+				trace.GotFirstResponseByte()
+			}
 			resp, err = ReadResponse(pc.br, rc.req)
 			if err == nil && resp.StatusCode == 100 {
-				// Skip any 100-continue for now.
-				// TODO(bradfitz): if rc.req had "Expect: 100-continue",
-				// actually block the request body write and signal the
-				// writeLoop now to begin sending it. (Issue 2184) For now we
-				// eat it, since we're never expecting one.
+				if trace != nil && trace.Got100Continue != nil {
+					trace.Got100Continue()
+				}
+				if rc.continueCh != nil {
+					// Let the writeLoop send the body.
+					rc.continueCh <- struct{}{}
+					rc.continueCh = nil
+				}
 				resp, err = ReadResponse(pc.br, rc.req)
 			}
 		}
+		if rc.continueCh != nil {
+			// The server didn't approve the body, so it
+			// won't be sent, if it hasn't been already.
+			close(rc.continueCh)
+		}
 
 		if resp != nil {
 			resp.TLS = pc.tlsState
 		}
 
+		if err == nil && rc.req.Header.get("Upgrade") != "" && resp.isProtocolSwitch() {
+			// The caller takes over the connection. Send the
+			// response before letting go of the conn so that
+			// roundTrip sees it rather than the close.
+			resp.Body = &readWriteCloserBody{br: pc.br, ReadWriteCloser: pc.conn}
+			pc.t.setReqCanceler(rc.req, nil)
+			rc.ch <- responseAndError{resp, nil}
+			pc.handOff()
+			return
+		}
+
 		hasBody := resp != nil && rc.req.Method != "HEAD" && resp.ContentLength != 0
 
 		if err != nil {
 			pc.close()
 		} else {
+			pc.lk.Lock()
+			pc.reused = true
+			pc.lk.Unlock()
 			if rc.addedGzip && hasBody && resp.Header.Get("Content-Encoding") == "gzip" {
 				resp.Header.Del("Content-Encoding")
 				resp.Header.Del("Content-Length")
@@ -910,7 +1421,7 @@ func (pc *persistConn) readLoop() {
 					err == nil &&
 					!pc.sawEOF &&
 					pc.wroteRequest() &&
-					pc.t.putIdleConn(pc)
+					tryPutIdleConn(trace)
 			}
 		}
 
@@ -927,7 +1438,7 @@ func (pc *persistConn) readLoop() {
 		if alive && !hasBody {
 			alive = !pc.sawEOF &&
 				pc.wroteRequest() &&
-				pc.t.putIdleConn(pc)
+				tryPutIdleConn(trace)
 		}
 
 		// Wait for the just-returned response body to be fully consumed
@@ -935,6 +1446,9 @@ func (pc *persistConn) readLoop() {
 		if waitForBodyRead != nil {
 			select {
 			case alive = <-waitForBodyRead:
+			case <-rc.req.Context().Done():
+				alive = false
+				pc.cancelRequest()
 			case <-pc.closech:
 				alive = false
 			}
@@ -956,7 +1470,7 @@ func (pc *persistConn) writeLoop() {
 				wr.ch <- errors.New("http: can't write HTTP request on broken connection")
 				continue
 			}
-			err := wr.req.Request.write(pc.bw, pc.isProxy, wr.req.extra)
+			err := wr.req.Request.write(pc.bw, pc.isProxy, wr.req.extra, pc.waitForContinue(wr.continueCh))
 			if err == nil {
 				err = pc.bw.Flush()
 			}
@@ -964,6 +1478,9 @@ func (pc *persistConn) writeLoop() {
 				pc.markBroken()
 				wr.req.Request.closeBody()
 			}
+			if trace := httptrace.ContextClientTrace(wr.req.Context()); trace != nil && trace.WroteRequest != nil {
+				trace.WroteRequest(httptrace.WroteRequestInfo{Err: err})
+			}
 			pc.writeErrCh <- err // to the body reader, which might recycle us
 			wr.ch <- err         // to the roundTrip function
 		case <-pc.closech:
@@ -972,6 +1489,34 @@ func (pc *persistConn) writeLoop() {
 	}
 }
 
+// waitForContinue returns the function to block until any response,
+// timeout or connection close. After any of them, the function returns
+// whether to send the request body. It returns nil if continueCh is
+// nil.
+func (pc *persistConn) waitForContinue(continueCh <-chan struct{}) func() bool {
+	if continueCh == nil {
+		return nil
+	}
+	return func() bool {
+		timer := time.NewTimer(pc.t.ExpectContinueTimeout)
+		defer timer.Stop()
+
+		select {
+		case _, ok := <-continueCh:
+			if !ok {
+				// The server may still be waiting for the
+				// body, so the conn can't be reused.
+				pc.markBroken()
+			}
+			return ok
+		case <-timer.C:
+			return true
+		case <-pc.closech:
+			return false
+		}
+	}
+}
+
 // wroteRequest is a check before recycling a connection that the previous write
 // (from writeLoop above) happened and was successful.
 func (pc *persistConn) wroteRequest() bool {
@@ -1013,6 +1558,11 @@ type requestAndChan struct {
 	// Accept-Encoding gzip header? only if it we set it do
 	// we transparently decode the gzip.
 	addedGzip bool
+
+	// Optional channel for the readLoop to tell the writeLoop
+	// whether to send the body of a request with
+	// "Expect: 100-continue": a value means yes, a close no.
+	continueCh chan<- struct{}
 }
 
 // A writeRequest is sent by the readLoop's goroutine to the
@@ -1022,6 +1572,10 @@ type requestAndChan struct {
 type writeRequest struct {
 	req *transportRequest
 	ch  chan<- error
+
+	// Optional channel for 100-continue approval, as in
+	// requestAndChan.
+	continueCh <-chan struct{}
 }
 
 type httpError struct {
@@ -1078,17 +1632,23 @@ func (pc *persistConn) roundTrip(req *transportRequest) (resp *Response, err err
 		req.extraHeaders().Set("Connection", "close")
 	}
 
+	var continueCh chan struct{}
+	if req.ProtoAtLeast(1, 1) && req.Body != nil && req.expectsContinue() && pc.t.ExpectContinueTimeout > 0 {
+		continueCh = make(chan struct{}, 1)
+	}
+
 	// Write the request concurrently with waiting for a response,
 	// in case the server decides to reply before reading our full
 	// request body.
 	writeErrCh := make(chan error, 1)
-	pc.writech <- writeRequest{req, writeErrCh}
+	pc.writech <- writeRequest{req, writeErrCh, continueCh}
 
 	resc := make(chan responseAndError, 1)
-	pc.reqch <- requestAndChan{req.Request, resc, requestedGzip}
+	pc.reqch <- requestAndChan{req.Request, resc, requestedGzip, continueCh}
 
 	var re responseAndError
 	var respHeaderTimer <-chan time.Time
+	ctx := req.Context()
 WaitResponse:
 	for {
 		select {
@@ -1127,6 +1687,10 @@ WaitResponse:
 			pc.close()
 			re = responseAndError{err: errTimeout}
 			break WaitResponse
+		case <-ctx.Done():
+			pc.close()
+			re = responseAndError{err: ctx.Err()}
+			break WaitResponse
 		case re = <-resc:
 			break WaitResponse
 		}
@@ -1157,16 +1721,52 @@ func (pc *persistConn) close() {
 	pc.closeLocked()
 }
 
+// handOff retires pc without closing its conn, which now belongs
+// to the caller of a protocol switch.
+func (pc *persistConn) handOff() {
+	pc.lk.Lock()
+	defer pc.lk.Unlock()
+	pc.broken = true
+	if !pc.closed {
+		pc.closed = true
+		close(pc.closech)
+		pc.t.releaseHostConn(pc.cacheKey)
+	}
+	pc.mutateHeaderFunc = nil
+}
+
 func (pc *persistConn) closeLocked() {
 	pc.broken = true
 	if !pc.closed {
 		pc.conn.Close()
 		pc.closed = true
 		close(pc.closech)
+		pc.t.releaseHostConn(pc.cacheKey)
 	}
 	pc.mutateHeaderFunc = nil
 }
 
+// readWriteCloserBody is the Response.Body of a 101 Switching
+// Protocols response. It reads any data already buffered from the
+// connection before reading the connection itself.
+type readWriteCloserBody struct {
+	br *bufio.Reader // until drained
+	io.ReadWriteCloser
+}
+
+func (b *readWriteCloserBody) Read(p []byte) (int, error) {
+	if b.br != nil {
+		if n := b.br.Buffered(); n > 0 {
+			if len(p) > n {
+				p = p[:n]
+			}
+			return b.br.Read(p)
+		}
+		b.br = nil
+	}
+	return b.ReadWriteCloser.Read(p)
+}
+
 var portMap = map[string]string{
 	"http":  "80",
 	"https": "443",
@@ -1289,3 +1889,44 @@ func (nr noteEOFReader) Read(p []byte) (n int, err error) {
 	}
 	return
 }
+
+// connLRU orders idle connections by when they became idle.
+type connLRU struct {
+	ll *list.List // list.Element.Value type is of *persistConn
+	m  map[*persistConn]*list.Element
+}
+
+// add adds pc to the head of the linked list.
+func (cl *connLRU) add(pc *persistConn) {
+	if cl.ll == nil {
+		cl.ll = list.New()
+		cl.m = make(map[*persistConn]*list.Element)
+	}
+	ele := cl.ll.PushFront(pc)
+	if _, ok := cl.m[pc]; ok {
+		panic("persistConn was already in LRU")
+	}
+	cl.m[pc] = ele
+}
+
+// removeOldest removes and returns the connection idle longest.
+func (cl *connLRU) removeOldest() *persistConn {
+	ele := cl.ll.Back()
+	pc := ele.Value.(*persistConn)
+	cl.ll.Remove(ele)
+	delete(cl.m, pc)
+	return pc
+}
+
+// remove removes pc from cl.
+func (cl *connLRU) remove(pc *persistConn) {
+	if ele, ok := cl.m[pc]; ok {
+		cl.ll.Remove(ele)
+		delete(cl.m, pc)
+	}
+}
+
+// len returns the number of items in the cache.
+func (cl *connLRU) len() int {
+	return len(cl.m)
+}
@@ -10,6 +10,7 @@ import (
 	"bufio"
 	"bytes"
 	"compress/gzip"
+	"context"
 	"crypto/rand"
 	"crypto/tls"
 	"errors"
@@ -21,6 +22,7 @@ import (
 	"net/http"
 	. "net/http"
 	"net/http/httptest"
+	"net/http/httptrace"
 	"net/url"
 	"os"
 	"runtime"
@@ -1340,6 +1342,200 @@ func TestTransportResponseHeaderTimeout(t *testing.T) {
 	}
 }
 
+func TestTransportContextCancel(t *testing.T) {
+	defer afterTest(t)
+	unblockc := make(chan bool)
+	ts := httptest.NewServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.URL.Path == "/body" {
+			fmt.Fprintf(w, "Hello")
+			w.(Flusher).Flush() // send headers and some body
+		}
+		<-unblockc
+	}))
+	defer ts.Close()
+	defer close(unblockc)
+
+	tr := &Transport{}
+	defer tr.CloseIdleConnections()
+
+	// Canceled while waiting for the response headers.
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := NewRequest("GET", ts.URL, nil)
+	req = req.WithContext(ctx)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	res, err := tr.RoundTrip(req)
+	if err != context.Canceled {
+		if err == nil {
+			res.Body.Close()
+		}
+		t.Fatalf("RoundTrip = %v; want %v", err, context.Canceled)
+	}
+
+	// Canceled while reading the body.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	req, _ = NewRequest("GET", ts.URL+"/body", nil)
+	res, err = tr.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err == nil {
+		t.Error("expected an error reading the body")
+	}
+	if string(body) != "Hello" {
+		t.Errorf("Body = %q; want Hello", body)
+	}
+	if n := tr.NumPendingRequestsForTesting(); n != 0 {
+		t.Errorf("pending requests = %d; want 0", n)
+	}
+
+	// A context that is already done fails before dialing.
+	req, _ = NewRequest("GET", ts.URL, nil)
+	if _, err := tr.RoundTrip(req.WithContext(ctx)); err != context.Canceled {
+		t.Errorf("RoundTrip with canceled context = %v; want %v", err, context.Canceled)
+	}
+}
+
+func TestTransportProtocolSwitch(t *testing.T) {
+	defer afterTest(t)
+	ts := httptest.NewServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		conn, brw, err := w.(Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		io.WriteString(brw, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\nhi\n")
+		brw.Flush()
+		line, err := brw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		io.WriteString(brw, strings.ToUpper(line))
+		brw.Flush()
+	}))
+	defer ts.Close()
+
+	tr := &Transport{}
+	defer tr.CloseIdleConnections()
+	req, _ := NewRequest("GET", ts.URL, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "echo")
+	res, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != StatusSwitchingProtocols {
+		t.Fatalf("status = %d; want 101", res.StatusCode)
+	}
+	rwc, ok := res.Body.(io.ReadWriteCloser)
+	if !ok {
+		t.Fatalf("Body type %T is not an io.ReadWriteCloser", res.Body)
+	}
+	br := bufio.NewReader(rwc)
+	if line, err := br.ReadString('\n'); err != nil || line != "hi\n" {
+		t.Fatalf("read %q, %v; want data sent along with the 101", line, err)
+	}
+	io.WriteString(rwc, "hello\n")
+	if line, err := br.ReadString('\n'); err != nil || line != "HELLO\n" {
+		t.Errorf("read %q, %v; want HELLO", line, err)
+	}
+}
+
+func TestTransportEventTrace(t *testing.T) {
+	defer afterTest(t)
+	ts := httptest.NewServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.Copy(ioutil.Discard, r.Body)
+		io.WriteString(w, "ok")
+	}))
+	defer ts.Close()
+
+	tr := &Transport{}
+	defer tr.CloseIdleConnections()
+
+	var (
+		mu    sync.Mutex
+		buf   bytes.Buffer
+		infos []httptrace.GotConnInfo
+	)
+	logf := func(format string, args ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(&buf, format, args...)
+		buf.WriteByte('\n')
+	}
+	trace := &httptrace.ClientTrace{
+		GetConn: func(hostPort string) { logf("GetConn") },
+		GotConn: func(ci httptrace.GotConnInfo) {
+			mu.Lock()
+			infos = append(infos, ci)
+			mu.Unlock()
+			logf("GotConn")
+		},
+		PutIdleConn:          func(err error) { logf("PutIdleConn = %v", err) },
+		GotFirstResponseByte: func() { logf("GotFirstResponseByte") },
+		DNSStart:             func(httptrace.DNSStartInfo) { logf("DNSStart") },
+		ConnectStart:         func(network, addr string) { logf("ConnectStart") },
+		ConnectDone:          func(network, addr string, err error) { logf("ConnectDone = %v", err) },
+		WroteHeaders:         func() { logf("WroteHeaders") },
+		WroteRequest:         func(wr httptrace.WroteRequestInfo) { logf("WroteRequest = %v", wr.Err) },
+	}
+	ctx := httptrace.WithClientTrace(context.Background(), trace)
+
+	for i := 0; i < 2; i++ {
+		req, _ := NewRequest("POST", ts.URL, strings.NewReader("body"))
+		res, err := tr.RoundTrip(req.WithContext(ctx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ioutil.ReadAll(res.Body); err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+
+	mu.Lock()
+	got := buf.String()
+	mu.Unlock()
+	const want = `GetConn
+ConnectStart
+ConnectDone = <nil>
+GotConn
+WroteHeaders
+WroteRequest = <nil>
+GotFirstResponseByte
+PutIdleConn = <nil>
+GetConn
+GotConn
+WroteHeaders
+WroteRequest = <nil>
+GotFirstResponseByte
+PutIdleConn = <nil>
+`
+	if got != want {
+		t.Errorf("trace events:\n%s\nwant:\n%s", got, want)
+	}
+	if len(infos) != 2 {
+		t.Fatalf("got %d GotConn calls; want 2", len(infos))
+	}
+	if ci := infos[0]; ci.Reused || ci.WasIdle || ci.Conn == nil {
+		t.Errorf("first GotConn = %+v; want a new conn", ci)
+	}
+	if ci := infos[1]; !ci.Reused || !ci.WasIdle || ci.Conn != infos[0].Conn {
+		t.Errorf("second GotConn = %+v; want the first conn, reused from the idle pool", ci)
+	}
+}
+
 func TestTransportCancelRequest(t *testing.T) {
 	defer afterTest(t)
 	if testing.Short() {
@@ -1787,6 +1983,236 @@ var proxyFromEnvTests = []proxyFromEnvTest{
 	{noenv: ".foo.com", req: "http://example.com/", env: "proxy", want: "http://proxy"},
 }
 
+// waitPoolStats waits for tr's pool to reach a total state for which
+// ok returns true, failing the test after a few seconds.
+func waitPoolStats(t *testing.T, tr *Transport, ok func(PoolStats) bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		total, _ := tr.PoolStats()
+		if ok(total) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for pool stats; have %+v", total)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestTransportMaxConnsPerHost(t *testing.T) {
+	defer afterTest(t)
+	release := make(chan bool)
+	var mu sync.Mutex
+	remoteAddrs := make(map[string]bool)
+	ts := httptest.NewServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		mu.Lock()
+		remoteAddrs[r.RemoteAddr] = true
+		mu.Unlock()
+		<-release
+		io.WriteString(w, "ok")
+	}))
+	defer ts.Close()
+
+	tr := &Transport{MaxConnsPerHost: 1}
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+
+	const n = 3
+	errc := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			res, err := c.Get(ts.URL)
+			if err == nil {
+				_, err = ioutil.ReadAll(res.Body)
+				res.Body.Close()
+			}
+			errc <- err
+		}()
+	}
+
+	// One request is in flight and the others wait for its conn.
+	waitPoolStats(t, tr, func(ps PoolStats) bool { return ps.Conns == 1 && ps.Waiting == n-1 })
+	_, perHost := tr.PoolStats()
+	if ps := perHost[ts.Listener.Addr().String()]; ps.Conns != 1 || ps.Waiting != n-1 {
+		t.Errorf("per-host stats = %+v (all: %v)", ps, perHost)
+	}
+
+	close(release)
+	for i := 0; i < n; i++ {
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(remoteAddrs) != 1 {
+		t.Errorf("server saw %d connections; want 1", len(remoteAddrs))
+	}
+	waitPoolStats(t, tr, func(ps PoolStats) bool { return ps == PoolStats{Conns: 1, Idle: 1} })
+}
+
+func TestTransportMaxConnsPerHostCancel(t *testing.T) {
+	defer afterTest(t)
+	release := make(chan bool)
+	ts := httptest.NewServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		<-release
+	}))
+	defer ts.Close()
+
+	tr := &Transport{MaxConnsPerHost: 1}
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+
+	errc := make(chan error, 1)
+	go func() {
+		res, err := c.Get(ts.URL)
+		if err == nil {
+			res.Body.Close()
+		}
+		errc <- err
+	}()
+	waitPoolStats(t, tr, func(ps PoolStats) bool { return ps.Conns == 1 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := NewRequest("GET", ts.URL, nil)
+	if _, err := c.Do(req.WithContext(ctx)); err == nil || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+		t.Errorf("queued request error = %v; want %v", err, context.DeadlineExceeded)
+	}
+	if total, _ := tr.PoolStats(); total.Waiting != 0 {
+		t.Errorf("after cancel, %d requests waiting", total.Waiting)
+	}
+
+	close(release)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTransportIdleConnTimeout(t *testing.T) {
+	defer afterTest(t)
+	ts := httptest.NewServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, r.RemoteAddr)
+	}))
+	defer ts.Close()
+
+	tr := &Transport{IdleConnTimeout: 50 * time.Millisecond}
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+
+	get := func() string {
+		res, err := c.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		slurp, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(slurp)
+	}
+	addr1 := get()
+	if total, _ := tr.PoolStats(); total.Idle != 1 {
+		t.Fatalf("after first request, %d idle conns; want 1", total.Idle)
+	}
+	waitPoolStats(t, tr, func(ps PoolStats) bool { return ps == PoolStats{} })
+	if addr2 := get(); addr1 == addr2 {
+		t.Errorf("second request reused connection %s closed by IdleConnTimeout", addr1)
+	}
+}
+
+func TestTransportMaxIdleConns(t *testing.T) {
+	defer afterTest(t)
+	var servers []*httptest.Server
+	for i := 0; i < 3; i++ {
+		ts := httptest.NewServer(HandlerFunc(func(w ResponseWriter, r *Request) {}))
+		defer ts.Close()
+		servers = append(servers, ts)
+	}
+
+	tr := &Transport{MaxIdleConns: 2}
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+	for _, ts := range servers {
+		res, err := c.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+
+	waitPoolStats(t, tr, func(ps PoolStats) bool { return ps == PoolStats{Conns: 2, Idle: 2} })
+	_, perHost := tr.PoolStats()
+	if _, ok := perHost[servers[0].Listener.Addr().String()]; ok {
+		t.Errorf("connection idle longest was not closed; per-host stats = %v", perHost)
+	}
+}
+
+// readTracker is an io.Reader that records whether it was read.
+type readTracker struct {
+	mu   sync.Mutex
+	read bool
+	r    io.Reader
+}
+
+func (rt *readTracker) Read(p []byte) (int, error) {
+	rt.mu.Lock()
+	rt.read = true
+	rt.mu.Unlock()
+	return rt.r.Read(p)
+}
+
+func (rt *readTracker) wasRead() bool {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.read
+}
+
+func TestTransportExpectContinueTimeout(t *testing.T) {
+	defer afterTest(t)
+	ts := httptest.NewServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.URL.Path == "/reject" {
+			w.WriteHeader(StatusUnauthorized)
+			return
+		}
+		io.Copy(w, r.Body)
+	}))
+	defer ts.Close()
+
+	tr := &Transport{ExpectContinueTimeout: time.Minute}
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+
+	const body = "request body"
+	for _, path := range []string{"/reject", "/accept"} {
+		rt := &readTracker{r: strings.NewReader(body)}
+		req, _ := NewRequest("POST", ts.URL+path, rt)
+		req.ContentLength = int64(len(body))
+		req.Header.Set("Expect", "100-continue")
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		slurp, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		switch path {
+		case "/reject":
+			if res.StatusCode != StatusUnauthorized {
+				t.Errorf("%s: status = %d; want 401", path, res.StatusCode)
+			}
+			if rt.wasRead() {
+				t.Errorf("%s: body was sent without 100 Continue", path)
+			}
+		case "/accept":
+			if string(slurp) != body {
+				t.Errorf("%s: body = %q; want %q", path, slurp, body)
+			}
+		}
+	}
+}
+
 func TestProxyFromEnvironment(t *testing.T) {
 	ResetProxyEnv()
 	for _, tt := range proxyFromEnvTests {
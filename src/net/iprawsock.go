@@ -46,7 +46,7 @@ func ResolveIPAddr(net, addr string) (*IPAddr, error) {
 	default:
 		return nil, UnknownNetworkError(net)
 	}
-	addrs, err := internetAddrList(afnet, addr, noDeadline)
+	addrs, err := internetAddrList(afnet, addr, noDeadline, nil)
 	if err != nil {
 		return nil, err
 	}
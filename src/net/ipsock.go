@@ -8,6 +8,7 @@ package net
 
 import (
 	"errors"
+	"internal/nettrace"
 	"time"
 )
 
@@ -200,8 +201,8 @@ func JoinHostPort(host, port string) string {
 // internetAddrList resolves addr, which may be a literal IP
 // address or a DNS name, and returns a list of internet protocol
 // family addresses. The result contains at least one address when
-// error is nil.
-func internetAddrList(net, addr string, deadline time.Time) (addrList, error) {
+// error is nil. DNS lookups are reported to trace, if non-nil.
+func internetAddrList(net, addr string, deadline time.Time, trace *nettrace.Trace) (addrList, error) {
 	var (
 		err        error
 		host, port string
@@ -249,7 +250,17 @@ func internetAddrList(net, addr string, deadline time.Time) (addrList, error) {
 		return addrList{inetaddr(IPAddr{IP: ip, Zone: zone})}, nil
 	}
 	// Try as a DNS name.
+	if trace != nil && trace.DNSStart != nil {
+		trace.DNSStart(host)
+	}
 	ips, err := lookupIPDeadline(host, deadline)
+	if trace != nil && trace.DNSDone != nil {
+		addrs := make([]interface{}, len(ips))
+		for i := range ips {
+			addrs[i] = ips[i]
+		}
+		trace.DNSDone(addrs, err)
+	}
 	if err != nil {
 		return nil, err
 	}
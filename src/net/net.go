@@ -312,6 +312,7 @@ type Listener interface {
 var (
 	// For connection setup and write operations.
 	errMissingAddress = errors.New("missing address")
+	errCanceled       = errors.New("operation was canceled")
 
 	// For both read and write operations.
 	errTimeout          error = &timeoutError{}
@@ -46,7 +46,7 @@ func ResolveUDPAddr(net, addr string) (*UDPAddr, error) {
 	default:
 		return nil, UnknownNetworkError(net)
 	}
-	addrs, err := internetAddrList(net, addr, noDeadline)
+	addrs, err := internetAddrList(net, addr, noDeadline, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -9,6 +9,7 @@ package exec
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
 	"os"
@@ -95,13 +96,16 @@ type Cmd struct {
 	// available after a call to Wait or Run.
 	ProcessState *os.ProcessState
 
-	lookPathErr     error // LookPath error, if any.
-	finished        bool  // when Wait was called
+	ctx             context.Context // nil means none
+	lookPathErr     error           // LookPath error, if any.
+	finished        bool            // when Wait was called
 	childFiles      []*os.File
 	closeAfterStart []io.Closer
 	closeAfterWait  []io.Closer
 	goroutine       []func() error
 	errch           chan error // one send per goroutine
+	waitDone        chan struct{}
+	ctxErr          chan error // ctx.Err() if ctx killed the process, else nil
 }
 
 // Command returns the Cmd struct to execute the named program with
@@ -131,6 +135,20 @@ func Command(name string, arg ...string) *Cmd {
 	return cmd
 }
 
+// CommandContext is like Command but includes a context.
+//
+// The provided context is used to kill the process (by calling
+// os.Process.Kill) if the context becomes done before the command
+// completes on its own.
+func CommandContext(ctx context.Context, name string, arg ...string) *Cmd {
+	if ctx == nil {
+		panic("nil Context")
+	}
+	cmd := Command(name, arg...)
+	cmd.ctx = ctx
+	return cmd
+}
+
 // interfaceEqual protects against panics from doing equality tests on
 // two interfaces with non-comparable underlying types.
 func interfaceEqual(a, b interface{}) bool {
@@ -294,6 +312,15 @@ func (c *Cmd) Start() error {
 	if c.Process != nil {
 		return errors.New("exec: already started")
 	}
+	if c.ctx != nil {
+		select {
+		case <-c.ctx.Done():
+			c.closeDescriptors(c.closeAfterStart)
+			c.closeDescriptors(c.closeAfterWait)
+			return c.ctx.Err()
+		default:
+		}
+	}
 
 	type F func(*Cmd) (*os.File, error)
 	for _, setupFd := range []F{(*Cmd).stdin, (*Cmd).stdout, (*Cmd).stderr} {
@@ -329,6 +356,23 @@ func (c *Cmd) Start() error {
 		}(fn)
 	}
 
+	if c.ctx != nil {
+		c.waitDone = make(chan struct{})
+		c.ctxErr = make(chan error, 1)
+		go func() {
+			select {
+			case <-c.ctx.Done():
+				var err error
+				if c.Process.Kill() == nil {
+					err = c.ctx.Err()
+				}
+				c.ctxErr <- err
+			case <-c.waitDone:
+				c.ctxErr <- nil
+			}
+		}()
+	}
+
 	return nil
 }
 
@@ -350,7 +394,9 @@ func (e *ExitError) Error() string {
 //
 // If the command fails to run or doesn't complete successfully, the
 // error is of type *ExitError. Other error types may be
-// returned for I/O problems.
+// returned for I/O problems. If the command was started with
+// CommandContext and was killed because the context became done, the
+// error is the context's Err.
 //
 // Wait releases any resources associated with the Cmd.
 func (c *Cmd) Wait() error {
@@ -362,6 +408,11 @@ func (c *Cmd) Wait() error {
 	}
 	c.finished = true
 	state, err := c.Process.Wait()
+	var ctxErr error
+	if c.waitDone != nil {
+		close(c.waitDone)
+		ctxErr = <-c.ctxErr
+	}
 	c.ProcessState = state
 
 	var copyError error
@@ -376,6 +427,9 @@ func (c *Cmd) Wait() error {
 	if err != nil {
 		return err
 	} else if !state.Success() {
+		if ctxErr != nil {
+			return ctxErr
+		}
 		return &ExitError{state}
 	}
 
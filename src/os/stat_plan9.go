@@ -88,9 +88,8 @@ func dirstat(arg interface{}) (*syscall.Dir, error) {
 	return nil, &PathError{"stat", name, err}
 }
 
-// Stat returns a FileInfo describing the named file.
-// If there is an error, it will be of type *PathError.
-func Stat(name string) (fi FileInfo, err error) {
+// statNolog is the Plan 9 implementation of Stat.
+func statNolog(name string) (fi FileInfo, err error) {
 	d, err := dirstat(name)
 	if err != nil {
 		return nil, err
@@ -98,12 +97,9 @@ func Stat(name string) (fi FileInfo, err error) {
 	return fileInfoFromStat(d), nil
 }
 
-// Lstat returns a FileInfo describing the named file.
-// If the file is a symbolic link, the returned FileInfo
-// describes the symbolic link.  Lstat makes no attempt to follow the link.
-// If there is an error, it will be of type *PathError.
-func Lstat(name string) (fi FileInfo, err error) {
-	return Stat(name)
+// lstatNolog is the Plan 9 implementation of Lstat.
+func lstatNolog(name string) (fi FileInfo, err error) {
+	return statNolog(name)
 }
 
 // For testing.
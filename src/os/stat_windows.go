@@ -20,7 +20,7 @@ func (file *File) Stat() (fi FileInfo, err error) {
 	}
 	if file.isdir() {
 		// I don't know any better way to do that for directory
-		return Stat(file.name)
+		return statNolog(file.name)
 	}
 	if file.name == DevNull {
 		return &devNullStat, nil
@@ -46,11 +46,10 @@ func (file *File) Stat() (fi FileInfo, err error) {
 	}, nil
 }
 
-// Stat returns a FileInfo structure describing the named file.
-// If there is an error, it will be of type *PathError.
-func Stat(name string) (fi FileInfo, err error) {
+// statNolog is the Windows implementation of Stat.
+func statNolog(name string) (fi FileInfo, err error) {
 	for {
-		fi, err = Lstat(name)
+		fi, err = lstatNolog(name)
 		if err != nil {
 			return
 		}
@@ -65,11 +64,8 @@ func Stat(name string) (fi FileInfo, err error) {
 	return fi, err
 }
 
-// Lstat returns the FileInfo structure describing the named file.
-// If the file is a symbolic link, the returned FileInfo
-// describes the symbolic link.  Lstat makes no attempt to follow the link.
-// If there is an error, it will be of type *PathError.
-func Lstat(name string) (fi FileInfo, err error) {
+// lstatNolog is the Windows implementation of Lstat.
+func lstatNolog(name string) (fi FileInfo, err error) {
 	if len(name) == 0 {
 		return nil, &PathError{"Lstat", name, syscall.Errno(syscall.ERROR_PATH_NOT_FOUND)}
 	}
@@ -10,6 +10,7 @@ import (
 	"io"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -30,10 +31,14 @@ func RunExamples(matchString func(pat, str string) (bool, error), examples []Int
 			fmt.Fprintf(os.Stderr, "testing: invalid regexp for -test.run: %s\n", err)
 			os.Exit(1)
 		}
-		if !matched {
+		if !matched || !inShard() {
 			continue
 		}
+		if shouldFailFast() {
+			break
+		}
 		if !runExample(eg) {
+			atomic.AddUint32(&numFailed, 1)
 			ok = false
 		}
 	}
@@ -43,7 +48,7 @@ func RunExamples(matchString func(pat, str string) (bool, error), examples []Int
 
 func runExample(eg InternalExample) (ok bool) {
 	if *chatty {
-		fmt.Printf("=== RUN: %s\n", eg.Name)
+		fmt.Printf("=== RUN %s\n", eg.Name)
 	}
 
 	// Capture stdout.
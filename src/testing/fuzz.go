@@ -0,0 +1,993 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Support for fuzz targets.
+
+package testing
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	matchFuzz        = flag.String("test.fuzz", "", "run the fuzzing engine on the fuzz target matching `regexp`")
+	fuzzDuration     = flag.Duration("test.fuzztime", 0, "time to spend fuzzing; 0 means until a failing input is found")
+	fuzzMinimizeTime = flag.Duration("test.fuzzminimizetime", 60*time.Second, "time to spend minimizing a failing input")
+)
+
+// corpusDir is the directory holding the corpus files of each fuzz target,
+// in a subdirectory named after the target.
+var corpusDir = filepath.Join("testdata", "fuzz")
+
+// An internal type but exported because it is cross-package; part of the implementation
+// of the "go test" command.
+type InternalFuzzTarget struct {
+	Name string
+	Fn   func(f *F)
+}
+
+// F is a type passed to fuzz targets to manage the seed corpus and run
+// the fuzz function.
+//
+// A fuzz target is run as a test: its fuzz function is called once for
+// each entry of the corpus, as a subtest named after the entry. When the
+// target is selected with -test.fuzz, the fuzzing engine calls the fuzz
+// function with inputs generated from the corpus instead.
+type F struct {
+	common
+	context    *testContext // For running corpus entries as subtests.
+	fuzzing    bool         // Whether to run the fuzzing engine rather than the corpus.
+	corpus     []corpusEntry
+	fuzzCalled bool
+}
+
+var _ TB = (*F)(nil)
+
+// A corpusEntry is a set of values to pass to a fuzz function.
+type corpusEntry struct {
+	name   string // Name of the subtest running the entry.
+	values []interface{}
+}
+
+// Add adds the arguments to the seed corpus of the fuzz target. The
+// arguments must match, in number, order, and type, the arguments of the
+// fuzz function following its *T.
+func (f *F) Add(args ...interface{}) {
+	if f.fuzzCalled {
+		panic("testing: F.Add called after F.Fuzz")
+	}
+	for _, arg := range args {
+		if t := reflect.TypeOf(arg); t == nil || !isFuzzType(t) {
+			panic(fmt.Sprintf("testing: unsupported type %v passed to F.Add", t))
+		}
+	}
+	f.corpus = append(f.corpus, corpusEntry{
+		name:   fmt.Sprintf("seed#%d", len(f.corpus)),
+		values: args,
+	})
+}
+
+// Fuzz runs the fuzz function ff on the corpus of the fuzz target. The
+// first argument of ff must be a *T, and the others must be of type
+// []byte, string, bool, or of a numeric type other than complex. ff must
+// not return any value.
+//
+// The corpus consists of the values added with Add and of the files in the
+// directory testdata/fuzz/<Name> of the package, where <Name> is the name
+// of the fuzz target. The fuzzing engine writes any failing input it finds
+// to that directory, so that it becomes a regression test.
+//
+// Fuzz may be called only once, and only from the goroutine running the
+// fuzz target.
+func (f *F) Fuzz(ff interface{}) {
+	if f.fuzzCalled {
+		panic("testing: F.Fuzz called more than once")
+	}
+	f.fuzzCalled = true
+
+	fn := reflect.ValueOf(ff)
+	if fn.Kind() != reflect.Func {
+		panic("testing: F.Fuzz must receive a function")
+	}
+	fnType := fn.Type()
+	if fnType.NumIn() < 2 || fnType.In(0) != reflect.TypeOf((*T)(nil)) {
+		panic("testing: fuzz function must receive at least two arguments, the first of which is a *T")
+	}
+	if fnType.NumOut() != 0 {
+		panic("testing: fuzz function must not return a value")
+	}
+	var types []reflect.Type
+	for i := 1; i < fnType.NumIn(); i++ {
+		t := fnType.In(i)
+		if !isFuzzType(t) {
+			panic(fmt.Sprintf("testing: unsupported type %v for fuzzing", t))
+		}
+		types = append(types, t)
+	}
+
+	for _, e := range f.corpus {
+		if err := checkCorpusEntry(e.values, types); err != nil {
+			f.Fatalf("%s: %v", e.name, err)
+		}
+	}
+	files, err := readCorpus(filepath.Join(corpusDir, f.name), types)
+	if err != nil {
+		f.Fatal(err)
+	}
+	corpus := append(f.corpus, files...)
+
+	if f.fuzzing {
+		f.fuzz(fn, types, corpus)
+		return
+	}
+	for _, e := range corpus {
+		e := e
+		f.context.run(&f.common, e.name, func(t *T) {
+			callFuzzFunc(fn, t, e.values)
+		})
+	}
+}
+
+func callFuzzFunc(fn reflect.Value, t *T, values []interface{}) {
+	args := []reflect.Value{reflect.ValueOf(t)}
+	// Pass copies, so that fn cannot modify the corpus.
+	for _, v := range copyValues(values) {
+		args = append(args, reflect.ValueOf(v))
+	}
+	fn.Call(args)
+}
+
+// runFuzzTarget runs target as a subtest of t. If fuzzing is set, the
+// fuzz function is run by the fuzzing engine rather than on the corpus.
+func (t *T) runFuzzTarget(target InternalFuzzTarget, fuzzing bool) bool {
+	name, ok := t.context.match.fullName(&t.common, target.Name)
+	if !ok || shouldFailFast() {
+		return true
+	}
+	f := &F{
+		common: common{
+			barrier: make(chan bool),
+			signal:  make(chan bool),
+			name:    name,
+			parent:  &t.common,
+			level:   t.level + 1,
+			chatty:  t.chatty,
+		},
+		context: t.context,
+		fuzzing: fuzzing,
+	}
+	f.w = indenter{&f.common}
+
+	if f.chatty {
+		f.printRoot("=== RUN %s\n", f.name)
+	}
+	go fRunner(f, target.Fn)
+	<-f.signal
+	return !f.Failed()
+}
+
+// fRunner is the counterpart of tRunner for fuzz targets. A fuzz target
+// is always run sequentially, but the corpus entries it runs as subtests
+// may be parallel.
+func fRunner(f *F, fn func(f *F)) {
+	defer func() {
+		f.duration += time.Now().Sub(f.start)
+		err := recover()
+		if !f.finished && err == nil {
+			err = fmt.Errorf("fuzz target executed panic(nil) or runtime.Goexit")
+		}
+		if err != nil {
+			f.Fail()
+			f.flushFailures()
+			panic(err)
+		}
+
+		if len(f.sub) > 0 {
+			f.context.release()
+			close(f.barrier)
+			for _, sub := range f.sub {
+				<-sub.signal
+			}
+			f.context.waitParallel()
+		}
+		f.report()
+		if f.Failed() {
+			atomic.AddUint32(&numFailed, 1)
+		}
+
+		f.mu.Lock()
+		f.done = true
+		f.mu.Unlock()
+		f.signal <- true
+	}()
+
+	f.start = time.Now()
+	fn(f)
+	f.finished = true
+}
+
+// runFuzzing runs the fuzzing engine on the fuzz target matching
+// -test.fuzz. It reports whether no failing input was found.
+func runFuzzing(matchString func(pat, str string) (bool, error), fuzzTargets []InternalFuzzTarget) (ok bool) {
+	ctx := newTestContext(1, newMatcher(matchString, *matchFuzz, "-test.fuzz"))
+	var target *InternalFuzzTarget
+	for i := range fuzzTargets {
+		if _, matched := ctx.match.fullName(nil, fuzzTargets[i].Name); !matched {
+			continue
+		}
+		if target != nil {
+			fmt.Fprintf(os.Stderr, "testing: will not fuzz, -test.fuzz matches more than one fuzz target: %s, %s\n", target.Name, fuzzTargets[i].Name)
+			return false
+		}
+		target = &fuzzTargets[i]
+	}
+	if target == nil {
+		fmt.Fprintln(os.Stderr, "testing: warning: no fuzz targets to fuzz")
+		return true
+	}
+
+	t := &T{
+		common: common{
+			signal:  make(chan bool),
+			barrier: make(chan bool),
+			w:       os.Stdout,
+			chatty:  *chatty,
+		},
+		context: ctx,
+	}
+	tRunner(t, func(t *T) {
+		t.runFuzzTarget(*target, true)
+		go func() { <-t.signal }()
+	})
+	return !t.Failed()
+}
+
+// fuzz runs the fuzzing engine on fn, starting from corpus, until it finds
+// a failing input or -test.fuzztime has elapsed. Inputs that reach new
+// coverage are added to the corpus; a failing input is minimized and
+// written to the corpus directory of the target.
+func (f *F) fuzz(fn reflect.Value, types []reflect.Type, corpus []corpusEntry) {
+	cov := newCoverage()
+	if cov == nil {
+		fmt.Fprintf(os.Stderr, "testing: warning: %s is not instrumented for coverage; fuzzing without guidance\n", f.name)
+	}
+	if len(corpus) == 0 {
+		corpus = append(corpus, corpusEntry{name: "zero", values: zeroValues(types)})
+	}
+	// Run the corpus first, so that only inputs reaching further
+	// than it count as interesting.
+	for _, e := range corpus {
+		cov.reset()
+		if failed, output := f.runInput(fn, e.values); failed {
+			f.fail(output, fmt.Sprintf("corpus entry %s fails", e.name))
+			return
+		}
+		cov.update()
+	}
+
+	m := newMutator()
+	start := time.Now()
+	last := start
+	var execs, interesting int64
+	report := func(now time.Time) {
+		elapsed := now.Sub(start)
+		f.printRoot("fuzz: elapsed: %ds, execs: %d (%.0f/sec), interesting: %d, corpus: %d\n",
+			int64(elapsed.Seconds()), execs, float64(execs)/elapsed.Seconds(), interesting, len(corpus))
+	}
+	for *fuzzDuration <= 0 || time.Since(start) < *fuzzDuration {
+		values := m.mutate(corpus[m.r.intn(len(corpus))].values, corpus)
+		cov.reset()
+		failed, output := f.runInput(fn, values)
+		execs++
+		if failed {
+			report(time.Now())
+			f.crashed(fn, values, output)
+			return
+		}
+		if cov.update() {
+			corpus = append(corpus, corpusEntry{values: values})
+			interesting++
+		}
+		if now := time.Now(); now.Sub(last) >= 3*time.Second {
+			report(now)
+			last = now
+		}
+	}
+	report(time.Now())
+}
+
+// runInput calls fn with values in isolation: the test running it has no
+// parent, so that its failure and output are reported only through the
+// results. A panic in fn is recovered and reported as a failure.
+func (f *F) runInput(fn reflect.Value, values []interface{}) (failed bool, output []byte) {
+	t := &T{
+		common: common{
+			signal:  make(chan bool),
+			barrier: make(chan bool),
+			name:    f.name,
+			level:   f.level,
+		},
+		context: newTestContext(1, &matcher{
+			matchFunc: f.context.match.matchFunc,
+			subNames:  map[string]int64{},
+		}),
+	}
+	t.w = indenter{&t.common}
+	go tRunner(t, func(t *T) {
+		defer func() {
+			if err := recover(); err != nil {
+				buf := make([]byte, 8192)
+				buf = buf[:runtime.Stack(buf, false)]
+				stack := strings.Replace(strings.TrimSpace(string(buf)), "\n", "\n\t\t", -1)
+				t.mu.Lock()
+				t.output = append(t.output, fmt.Sprintf("\tpanic: %v\n\t\t%s\n", err, stack)...)
+				t.failed = true
+				t.mu.Unlock()
+			}
+		}()
+		callFuzzFunc(fn, t, values)
+	})
+	<-t.signal
+	return t.Failed(), t.output
+}
+
+// crashed minimizes the failing input values, writes it to the corpus
+// directory and fails f with the output of the minimized input.
+func (f *F) crashed(fn reflect.Value, values []interface{}, output []byte) {
+	values, output = f.minimize(fn, values, output)
+	path, err := writeCorpusFile(filepath.Join(corpusDir, f.name), values)
+	if err != nil {
+		f.fail(output, fmt.Sprintf("failed to write failing input: %v", err))
+		return
+	}
+	f.fail(output, fmt.Sprintf("Failing input written to %s\nTo re-run:\ngo test -run=%s/%s", path, f.name, filepath.Base(path)))
+}
+
+// fail appends output and the lines of msg to the output of f and marks
+// f as failed.
+func (f *F) fail(output []byte, msg string) {
+	f.mu.Lock()
+	f.output = append(f.output, output...)
+	for _, line := range strings.Split(msg, "\n") {
+		f.output = append(f.output, "\t"+line+"\n"...)
+	}
+	f.mu.Unlock()
+	f.Fail()
+}
+
+// minimize looks for a simpler input than values that still makes fn
+// fail, for at most -test.fuzzminimizetime. It returns the simplest
+// failing input found and its output.
+func (f *F) minimize(fn reflect.Value, values []interface{}, output []byte) ([]interface{}, []byte) {
+	deadline := time.Now().Add(*fuzzMinimizeTime)
+	for i := range values {
+		// try reports whether fn fails when argument i is v, in which
+		// case v replaces it.
+		try := func(v interface{}) bool {
+			if time.Now().After(deadline) {
+				return false
+			}
+			candidate := copyValues(values)
+			candidate[i] = v
+			failed, out := f.runInput(fn, candidate)
+			if failed {
+				values, output = candidate, out
+			}
+			return failed
+		}
+		v := reflect.ValueOf(values[i])
+		switch v.Kind() {
+		case reflect.Slice:
+			minimizeBytes(v.Bytes(), func(b []byte) bool { return try(b) })
+		case reflect.String:
+			minimizeBytes([]byte(v.String()), func(b []byte) bool { return try(string(b)) })
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			// Try zero, then halve the value until it stops failing.
+			if n := v.Int(); n != 0 && !try(reflect.Zero(v.Type()).Interface()) {
+				for n /= 2; n != 0; n /= 2 {
+					nv := reflect.New(v.Type()).Elem()
+					nv.SetInt(n)
+					if !try(nv.Interface()) {
+						break
+					}
+				}
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if n := v.Uint(); n != 0 && !try(reflect.Zero(v.Type()).Interface()) {
+				for n /= 2; n != 0; n /= 2 {
+					nv := reflect.New(v.Type()).Elem()
+					nv.SetUint(n)
+					if !try(nv.Interface()) {
+						break
+					}
+				}
+			}
+		case reflect.Float32, reflect.Float64:
+			if x := v.Float(); x != 0 && !try(reflect.Zero(v.Type()).Interface()) && x != math.Trunc(x) {
+				nv := reflect.New(v.Type()).Elem()
+				nv.SetFloat(math.Trunc(x))
+				try(nv.Interface())
+			}
+		case reflect.Bool:
+			if v.Bool() {
+				try(false)
+			}
+		}
+	}
+	return values, output
+}
+
+// minimizeBytes removes ever smaller chunks of b, keeping each removal
+// for which try reports that the input still fails.
+func minimizeBytes(b []byte, try func([]byte) bool) {
+	for chunk := len(b); chunk > 0; chunk /= 2 {
+		for i := 0; i+chunk <= len(b); {
+			candidate := make([]byte, 0, len(b)-chunk)
+			candidate = append(candidate, b[:i]...)
+			candidate = append(candidate, b[i+chunk:]...)
+			if try(candidate) {
+				b = candidate
+			} else {
+				i += chunk
+			}
+		}
+	}
+}
+
+// coverage tracks the coverage counters of the instrumented packages across
+// the inputs run by the fuzzing engine. A nil *coverage tracks nothing.
+type coverage struct {
+	counters [][]uint32 // The counters of cover.Counters, in a fixed order.
+	seen     [][]uint8  // The hit count classes seen so far for each counter.
+}
+
+func newCoverage() *coverage {
+	if len(cover.Counters) == 0 {
+		return nil
+	}
+	var names []string
+	for name := range cover.Counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	c := new(coverage)
+	for _, name := range names {
+		counters := cover.Counters[name]
+		c.counters = append(c.counters, counters)
+		c.seen = append(c.seen, make([]uint8, len(counters)))
+	}
+	return c
+}
+
+// reset zeroes the counters before running an input.
+func (c *coverage) reset() {
+	if c == nil {
+		return
+	}
+	for _, counters := range c.counters {
+		for i := range counters {
+			atomic.StoreUint32(&counters[i], 0)
+		}
+	}
+}
+
+// update records the counters of the input just run. It reports whether
+// the input reached a block, or a hit count class of a block, for the
+// first time.
+func (c *coverage) update() bool {
+	if c == nil {
+		return false
+	}
+	found := false
+	for i, counters := range c.counters {
+		seen := c.seen[i]
+		for j := range counters {
+			n := atomic.LoadUint32(&counters[j])
+			if n == 0 {
+				continue
+			}
+			if class := hitClass(n); seen[j]&class == 0 {
+				seen[j] |= class
+				found = true
+			}
+		}
+	}
+	return found
+}
+
+// hitClass returns the bit identifying the class of the hit count n among
+// 1, 2, 3, 4-7, 8-15, 16-31, 32-127 and 128 or more, so that an input
+// running a block notably more often than the others counts as new behavior.
+func hitClass(n uint32) uint8 {
+	switch {
+	case n <= 3:
+		return 1 << (n - 1)
+	case n <= 7:
+		return 1 << 3
+	case n <= 15:
+		return 1 << 4
+	case n <= 31:
+		return 1 << 5
+	case n <= 127:
+		return 1 << 6
+	}
+	return 1 << 7
+}
+
+// maxBytesLen bounds the length of the byte slices and strings
+// generated by the mutator.
+const maxBytesLen = 1 << 16
+
+var interestingBytes = []byte{0, 1, '\n', ' ', '0', '9', 'A', 'Z', 'a', 'z', 0x7f, 0x80, 0xff}
+
+// A mutator generates new inputs from the corpus.
+type mutator struct {
+	r *fuzzRand
+}
+
+func newMutator() *mutator {
+	return &mutator{r: newFuzzRand(time.Now().UnixNano())}
+}
+
+// A fuzzRand is the mutator's source of pseudo-random numbers, an
+// xorshift64* generator. The testing package cannot use math/rand,
+// whose own tests import testing.
+type fuzzRand struct {
+	x uint64
+}
+
+func newFuzzRand(seed int64) *fuzzRand {
+	r := &fuzzRand{x: uint64(seed)}
+	if r.x == 0 {
+		r.x = 1 // the generator is stuck at zero
+	}
+	return r
+}
+
+func (r *fuzzRand) uint64() uint64 {
+	r.x ^= r.x >> 12
+	r.x ^= r.x << 25
+	r.x ^= r.x >> 27
+	return r.x * 2685821657736338717
+}
+
+// int63 returns a non-negative pseudo-random 63-bit integer.
+func (r *fuzzRand) int63() int64 {
+	return int64(r.uint64() >> 1)
+}
+
+// intn returns a pseudo-random number in [0,n). It panics if n <= 0.
+func (r *fuzzRand) intn(n int) int {
+	if n <= 0 {
+		panic("testing: invalid argument to intn")
+	}
+	return int(r.uint64() % uint64(n))
+}
+
+// normFloat64 returns a normally distributed float64 with
+// mean 0 and standard deviation 1.
+func (r *fuzzRand) normFloat64() float64 {
+	u1 := float64(r.uint64()>>11+1) / (1 << 53) // in (0,1], so that the Log is finite
+	u2 := float64(r.uint64()>>11) / (1 << 53)
+	return math.Sqrt(-2*math.Log(u1)) * math.Cos(2*math.Pi*u2)
+}
+
+// mutate returns a copy of values with one of them changed at random.
+// Byte slices and strings may be spliced with the values of the same
+// argument in other entries of corpus.
+func (m *mutator) mutate(values []interface{}, corpus []corpusEntry) []interface{} {
+	values = copyValues(values)
+	i := m.r.intn(len(values))
+	v := reflect.ValueOf(values[i])
+	nv := reflect.New(v.Type()).Elem()
+	switch v.Kind() {
+	case reflect.Slice:
+		nv.SetBytes(m.mutateBytes(v.Bytes(), m.spliceSource(corpus, i)))
+	case reflect.String:
+		nv.SetString(string(m.mutateBytes([]byte(v.String()), m.spliceSource(corpus, i))))
+	case reflect.Bool:
+		nv.SetBool(!v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		nv.SetInt(m.mutateInt(v.Int(), uint(v.Type().Bits())))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		nv.SetUint(m.mutateUint(v.Uint(), uint(v.Type().Bits())))
+	case reflect.Float32, reflect.Float64:
+		nv.SetFloat(m.mutateFloat(v.Float()))
+	}
+	values[i] = nv.Interface()
+	return values
+}
+
+// spliceSource returns the value of argument i of a random corpus entry,
+// if it is a byte slice or a string.
+func (m *mutator) spliceSource(corpus []corpusEntry, i int) []byte {
+	switch v := corpus[m.r.intn(len(corpus))].values[i].(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	}
+	return nil
+}
+
+func (m *mutator) mutateInt(v int64, bits uint) int64 {
+	switch m.r.intn(4) {
+	case 0:
+		v += int64(m.r.intn(33) - 16)
+	case 1:
+		v ^= 1 << uint(m.r.intn(int(bits)))
+	case 2:
+		interesting := []int64{0, 1, -1, 1<<(bits-1) - 1, -1 << (bits - 1)}
+		v = interesting[m.r.intn(len(interesting))]
+	default:
+		v = m.r.int63() - m.r.int63()
+	}
+	// Wrap around to the range of the type.
+	shift := 64 - bits
+	return v << shift >> shift
+}
+
+func (m *mutator) mutateUint(v uint64, bits uint) uint64 {
+	switch m.r.intn(4) {
+	case 0:
+		v += uint64(m.r.intn(33) - 16)
+	case 1:
+		v ^= 1 << uint(m.r.intn(int(bits)))
+	case 2:
+		interesting := []uint64{0, 1, 1<<(bits-1) - 1, 1 << (bits - 1), 1<<bits - 1}
+		v = interesting[m.r.intn(len(interesting))]
+	default:
+		v = uint64(m.r.int63())<<1 ^ uint64(m.r.int63())
+	}
+	shift := 64 - bits
+	return v << shift >> shift
+}
+
+func (m *mutator) mutateFloat(v float64) float64 {
+	switch m.r.intn(4) {
+	case 0:
+		v += float64(m.r.intn(33) - 16)
+	case 1:
+		v *= m.r.normFloat64() * 4
+	case 2:
+		interesting := []float64{0, 1, -1, 0.5, math.MaxFloat32, math.SmallestNonzeroFloat64, math.Inf(1), math.Inf(-1), math.NaN()}
+		v = interesting[m.r.intn(len(interesting))]
+	default:
+		v = math.Float64frombits(uint64(m.r.int63())<<1 ^ uint64(m.r.int63()))
+	}
+	return v
+}
+
+// mutateBytes applies a few random changes to b, which it may modify.
+func (m *mutator) mutateBytes(b, splice []byte) []byte {
+	for n := 1 + m.r.intn(4); n > 0; n-- {
+		b = m.mutateBytesOnce(b, splice)
+	}
+	if len(b) > maxBytesLen {
+		b = b[:maxBytesLen]
+	}
+	return b
+}
+
+func (m *mutator) mutateBytesOnce(b, splice []byte) []byte {
+	if len(b) == 0 {
+		return m.insertBytes(b, splice)
+	}
+	switch m.r.intn(8) {
+	case 0: // Flip a bit.
+		b[m.r.intn(len(b))] ^= 1 << uint(m.r.intn(8))
+	case 1: // Set a byte to a random value.
+		b[m.r.intn(len(b))] = byte(m.r.intn(256))
+	case 2: // Set a byte to an interesting value.
+		b[m.r.intn(len(b))] = interestingBytes[m.r.intn(len(interestingBytes))]
+	case 3: // Add a small amount to a byte.
+		b[m.r.intn(len(b))] += byte(m.r.intn(33) - 16)
+	case 4: // Remove a chunk.
+		i, j := m.chunk(len(b))
+		b = append(b[:i], b[j:]...)
+	case 5: // Duplicate a chunk.
+		i, j := m.chunk(len(b))
+		b = insertBytes(b, m.r.intn(len(b)+1), b[i:j])
+	case 6: // Swap two bytes.
+		i, j := m.r.intn(len(b)), m.r.intn(len(b))
+		b[i], b[j] = b[j], b[i]
+	default:
+		return m.insertBytes(b, splice)
+	}
+	return b
+}
+
+// insertBytes inserts random bytes, or a chunk of splice, into b.
+func (m *mutator) insertBytes(b, splice []byte) []byte {
+	var chunk []byte
+	if len(splice) > 0 && m.r.intn(2) == 0 {
+		i, j := m.chunk(len(splice))
+		chunk = splice[i:j]
+	} else {
+		chunk = make([]byte, 1+m.r.intn(8))
+		for i := range chunk {
+			chunk[i] = byte(m.r.intn(256))
+		}
+	}
+	return insertBytes(b, m.r.intn(len(b)+1), chunk)
+}
+
+// chunk returns the bounds of a random, usually short, non-empty chunk of
+// a slice of length n > 0.
+func (m *mutator) chunk(n int) (i, j int) {
+	size := 1 + m.r.intn(n)
+	if n > 8 && m.r.intn(2) == 0 {
+		size = 1 + m.r.intn(8)
+	}
+	i = m.r.intn(n - size + 1)
+	return i, i + size
+}
+
+// insertBytes returns a new slice holding b with chunk inserted at index i.
+func insertBytes(b []byte, i int, chunk []byte) []byte {
+	nb := make([]byte, 0, len(b)+len(chunk))
+	nb = append(nb, b[:i]...)
+	nb = append(nb, chunk...)
+	return append(nb, b[i:]...)
+}
+
+// copyValues returns a copy of values that shares no byte slices with it.
+func copyValues(values []interface{}) []interface{} {
+	c := make([]interface{}, len(values))
+	for i, v := range values {
+		if b, ok := v.([]byte); ok {
+			v = append([]byte(nil), b...)
+		}
+		c[i] = v
+	}
+	return c
+}
+
+func zeroValues(types []reflect.Type) []interface{} {
+	var values []interface{}
+	for _, t := range types {
+		values = append(values, reflect.Zero(t).Interface())
+	}
+	return values
+}
+
+// corpusTypes maps the type names used in corpus files to the types
+// that fuzz functions may take.
+var corpusTypes = map[string]reflect.Type{
+	"[]byte":  reflect.TypeOf([]byte(nil)),
+	"string":  reflect.TypeOf(""),
+	"bool":    reflect.TypeOf(false),
+	"byte":    reflect.TypeOf(byte(0)),
+	"rune":    reflect.TypeOf(rune(0)),
+	"int":     reflect.TypeOf(int(0)),
+	"int8":    reflect.TypeOf(int8(0)),
+	"int16":   reflect.TypeOf(int16(0)),
+	"int32":   reflect.TypeOf(int32(0)),
+	"int64":   reflect.TypeOf(int64(0)),
+	"uint":    reflect.TypeOf(uint(0)),
+	"uint8":   reflect.TypeOf(uint8(0)),
+	"uint16":  reflect.TypeOf(uint16(0)),
+	"uint32":  reflect.TypeOf(uint32(0)),
+	"uint64":  reflect.TypeOf(uint64(0)),
+	"float32": reflect.TypeOf(float32(0)),
+	"float64": reflect.TypeOf(float64(0)),
+}
+
+func isFuzzType(t reflect.Type) bool {
+	return t == corpusTypes["[]byte"] || corpusTypes[t.String()] == t
+}
+
+func checkCorpusEntry(values []interface{}, types []reflect.Type) error {
+	if len(values) != len(types) {
+		return fmt.Errorf("wrong number of values: got %d, want %d", len(values), len(types))
+	}
+	for i, v := range values {
+		if t := reflect.TypeOf(v); t != types[i] {
+			return fmt.Errorf("value %d has type %v, want %v", i, t, types[i])
+		}
+	}
+	return nil
+}
+
+// corpusHeader is the first line of a corpus file. Each following line
+// holds a value, written as a conversion of a Go literal to its type,
+// such as []byte("\x00") or int64(-3).
+const corpusHeader = "go test fuzz v1"
+
+func marshalCorpusFile(values []interface{}) []byte {
+	var b bytes.Buffer
+	b.WriteString(corpusHeader + "\n")
+	for _, v := range values {
+		switch v := v.(type) {
+		case []byte:
+			fmt.Fprintf(&b, "[]byte(%s)\n", strconv.Quote(string(v)))
+		case string:
+			fmt.Fprintf(&b, "string(%s)\n", strconv.Quote(v))
+		default:
+			fmt.Fprintf(&b, "%T(%v)\n", v, v)
+		}
+	}
+	return b.Bytes()
+}
+
+func unmarshalCorpusFile(data []byte) ([]interface{}, error) {
+	lines := strings.Split(string(data), "\n")
+	if strings.TrimSpace(lines[0]) != corpusHeader {
+		return nil, errors.New("not a corpus file: missing header")
+	}
+	var values []interface{}
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		v, err := parseCorpusValue(line)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	if len(values) == 0 {
+		return nil, errors.New("no values in corpus file")
+	}
+	return values, nil
+}
+
+func parseCorpusValue(line string) (interface{}, error) {
+	i := strings.Index(line, "(")
+	if i < 0 || !strings.HasSuffix(line, ")") {
+		return nil, fmt.Errorf("malformed line %q", line)
+	}
+	name, lit := line[:i], line[i+1:len(line)-1]
+	t, ok := corpusTypes[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported type %s in line %q", name, line)
+	}
+	v := reflect.New(t).Elem()
+	var err error
+	switch t.Kind() {
+	case reflect.Slice, reflect.String:
+		var s string
+		if s, err = strconv.Unquote(lit); err == nil {
+			if t.Kind() == reflect.Slice {
+				v.SetBytes([]byte(s))
+			} else {
+				v.SetString(s)
+			}
+		}
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(lit)
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		n, err = strconv.ParseInt(lit, 0, t.Bits())
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		n, err = strconv.ParseUint(lit, 0, t.Bits())
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		var x float64
+		x, err = strconv.ParseFloat(lit, t.Bits())
+		v.SetFloat(x)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("malformed line %q: %v", line, err)
+	}
+	return v.Interface(), nil
+}
+
+// readCorpus reads the corpus files in dir, which need not exist, and
+// checks that their values match types.
+func readCorpus(dir string, types []reflect.Type) ([]corpusEntry, error) {
+	names, err := readDirNames(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var corpus []corpusEntry
+	for _, name := range names {
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			continue
+		}
+		data, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		values, err := unmarshalCorpusFile(data)
+		if err == nil {
+			err = checkCorpusEntry(values, types)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		corpus = append(corpus, corpusEntry{name: name, values: values})
+	}
+	return corpus, nil
+}
+
+// writeCorpusFile writes values to a file in dir named after the hash of
+// its contents, and returns the path of the file.
+func writeCorpusFile(dir string, values []interface{}) (string, error) {
+	data := marshalCorpusFile(values)
+	// The FNV-1a hash, as computed by hash/fnv.
+	h := uint64(14695981039346656037)
+	for _, c := range data {
+		h ^= uint64(c)
+		h *= 1099511628211
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%016x", h))
+	if err := writeFile(path, data); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// The functions below do the work of their counterparts in io/ioutil,
+// which the testing package cannot import because its tests import testing.
+
+// readDirNames returns the sorted names of the entries in dir.
+func readDirNames(dir string) ([]string, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func readFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var buf bytes.Buffer
+	_, err = buf.ReadFrom(f)
+	return buf.Bytes(), err
+}
+
+func writeFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
@@ -0,0 +1,249 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testing
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// runFuzzRoot runs fn as the fuzz target FuzzTest of a top-level test,
+// with its corpus files in a temporary directory populated by files.
+func runFuzzRoot(t *T, fuzzing bool, files map[string]string, fn func(f *F)) (*T, string, string) {
+	dir, err := ioutil.TempDir("", "testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(old string) { corpusDir = old }(corpusDir)
+	corpusDir = dir
+	for name, data := range files {
+		path := filepath.Join(dir, "FuzzTest", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	root := &T{
+		common: common{
+			signal:  make(chan bool),
+			barrier: make(chan bool),
+			w:       buf,
+			chatty:  true,
+		},
+		context: newTestContext(1, newMatcher(regexp.MatchString, "", "")),
+	}
+	tRunner(root, func(t *T) {
+		t.runFuzzTarget(InternalFuzzTarget{"FuzzTest", fn}, fuzzing)
+		go func() { <-t.signal }()
+	})
+	return root, buf.String(), dir
+}
+
+func TestFuzzCorpus(t *T) {
+	files := map[string]string{
+		"0123456789abcdef": "go test fuzz v1\nstring(\"file\")\nint(3)\n",
+	}
+	var got []string
+	root, out, dir := runFuzzRoot(t, false, files, func(f *F) {
+		f.Add("seed", 1)
+		f.Add("fail", 2)
+		f.Fuzz(func(t *T, s string, n int) {
+			got = append(got, s+strings.Repeat("!", n))
+			if s == "fail" {
+				t.Error("failed")
+			}
+		})
+	})
+	defer os.RemoveAll(dir)
+
+	if !root.Failed() {
+		t.Error("root did not fail")
+	}
+	if want := []string{"seed!", "fail!!", "file!!!"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("fuzz function called with %q; want %q", got, want)
+	}
+	for _, s := range []string{
+		"=== RUN FuzzTest/seed#0\n",
+		"--- PASS: FuzzTest/seed#0 ",
+		"--- FAIL: FuzzTest/seed#1 ",
+		"--- PASS: FuzzTest/0123456789abcdef ",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("output does not contain %q:\n%s", s, out)
+		}
+	}
+}
+
+func TestFuzzBadCorpus(t *T) {
+	for _, tc := range []struct {
+		desc  string
+		files map[string]string
+		add   []interface{}
+		err   string
+	}{{
+		desc: "wrong type in Add",
+		add:  []interface{}{int64(1)},
+		err:  "seed#0: value 0 has type int64, want int",
+	}, {
+		desc: "wrong number of values in Add",
+		add:  []interface{}{1, 2},
+		err:  "seed#0: wrong number of values: got 2, want 1",
+	}, {
+		desc:  "wrong type in file",
+		files: map[string]string{"a": "go test fuzz v1\nuint(1)\n"},
+		err:   "value 0 has type uint, want int",
+	}, {
+		desc:  "malformed file",
+		files: map[string]string{"a": "int(1)\n"},
+		err:   "missing header",
+	}} {
+		called := false
+		root, out, dir := runFuzzRoot(t, false, tc.files, func(f *F) {
+			if tc.add != nil {
+				f.Add(tc.add...)
+			}
+			f.Fuzz(func(t *T, n int) { called = true })
+		})
+		os.RemoveAll(dir)
+		if !root.Failed() {
+			t.Errorf("%s: did not fail", tc.desc)
+		}
+		if called {
+			t.Errorf("%s: fuzz function was called", tc.desc)
+		}
+		if !strings.Contains(out, tc.err) {
+			t.Errorf("%s: output does not contain %q:\n%s", tc.desc, tc.err, out)
+		}
+	}
+}
+
+func TestFuzzEngine(t *T) {
+	defer func(old Cover, d, m time.Duration) {
+		cover, *fuzzDuration, *fuzzMinimizeTime = old, d, m
+	}(cover, *fuzzDuration, *fuzzMinimizeTime)
+	counters := make([]uint32, 4)
+	cover = Cover{Mode: "count", Counters: map[string][]uint32{"fuzz.go": counters}}
+	*fuzzDuration = time.Minute
+	*fuzzMinimizeTime = 10 * time.Second
+
+	// Each byte of the prefix "FUZZ" reaches a new block, which lets the
+	// engine find the failing input one byte at a time.
+	root, out, dir := runFuzzRoot(t, true, nil, func(f *F) {
+		f.Add([]byte("...."))
+		f.Fuzz(func(t *T, b []byte) {
+			for i, c := range []byte("FUZZ") {
+				if len(b) <= i || b[i] != c {
+					return
+				}
+				counters[i]++
+			}
+			t.Errorf("found %q", b)
+		})
+	})
+	defer os.RemoveAll(dir)
+
+	if !root.Failed() {
+		t.Fatalf("no failing input found:\n%s", out)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "FuzzTest", "*"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("got corpus files %q, %v; want a single file\n%s", files, err, out)
+	}
+	for _, s := range []string{
+		"--- FAIL: FuzzTest ",
+		`found "FUZZ"`,
+		"Failing input written to " + files[0],
+		"go test -run=FuzzTest/" + filepath.Base(files[0]),
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("output does not contain %q:\n%s", s, out)
+		}
+	}
+	data, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), "go test fuzz v1\n[]byte(\"FUZZ\")\n"; got != want {
+		t.Errorf("corpus file contains %q; want %q", got, want)
+	}
+}
+
+func TestCorpusFile(t *T) {
+	values := []interface{}{
+		[]byte("\x00\xff\n"), "line\nbreak", true, false,
+		int(-1), int8(math.MinInt8), int16(math.MaxInt16), int32('x'), int64(math.MinInt64),
+		uint(1), uint8(math.MaxUint8), uint16(2), uint32(3), uint64(math.MaxUint64),
+		float32(0.1), float64(-1e300), math.Inf(1),
+	}
+	data := marshalCorpusFile(values)
+	got, err := unmarshalCorpusFile(data)
+	if err != nil {
+		t.Fatalf("unmarshal %q: %v", data, err)
+	}
+	if !reflect.DeepEqual(got, values) {
+		t.Errorf("round trip of %q:\ngot  %#v\nwant %#v", data, got, values)
+	}
+
+	for _, data := range []string{
+		"",
+		"go test fuzz v1\n",
+		"go test fuzz v2\nint(1)\n",
+		"go test fuzz v1\nint(1\n",
+		"go test fuzz v1\nint 1\n",
+		"go test fuzz v1\ncomplex128(1)\n",
+		"go test fuzz v1\nint8(128)\n",
+		"go test fuzz v1\nstring(\"unterminated)\n",
+	} {
+		if values, err := unmarshalCorpusFile([]byte(data)); err == nil {
+			t.Errorf("unmarshal %q = %#v; want error", data, values)
+		}
+	}
+}
+
+func TestMutate(t *T) {
+	corpus := []corpusEntry{
+		{values: []interface{}{[]byte("abc"), "", int8(0), uint16(0), float32(0), true}},
+		{values: []interface{}{[]byte(nil), "xyz", int8(1), uint16(1), float32(1), false}},
+	}
+	m := newMutator()
+	for i := 0; i < 1000; i++ {
+		in := corpus[i%len(corpus)].values
+		saved := copyValues(in)
+		out := m.mutate(in, corpus)
+		if !reflect.DeepEqual(in, saved) {
+			t.Fatalf("mutate modified its input: got %#v, want %#v", in, saved)
+		}
+		if len(out) != len(in) {
+			t.Fatalf("mutate(%#v) = %#v", in, out)
+		}
+		for j := range out {
+			if reflect.TypeOf(out[j]) != reflect.TypeOf(in[j]) {
+				t.Fatalf("mutate(%#v) = %#v; changed type of value %d", in, out, j)
+			}
+		}
+	}
+}
+
+func TestHitClass(t *T) {
+	var last uint8
+	for n := uint32(1); n < 300; n++ {
+		c := hitClass(n)
+		if c < last || c&(c-1) != 0 {
+			t.Fatalf("hitClass(%d) = %#x after %#x", n, c, last)
+		}
+		last = c
+	}
+}
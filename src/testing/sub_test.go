@@ -0,0 +1,391 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testing
+
+import (
+	"bytes"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+func TestTestContext(t *T) {
+	const (
+		add1 = 0
+		done = 1
+	)
+	// After each of the calls are applied to the context, the
+	// running and waiting counts and whether a test was started
+	// must match the call's expectations.
+	type call struct {
+		typ int // run or done
+		// result from applying the call
+		running int
+		waiting int
+		started bool
+	}
+	testCases := []struct {
+		max int
+		run []call
+	}{{
+		max: 1,
+		run: []call{
+			{typ: add1, running: 1, waiting: 0, started: true},
+			{typ: done, running: 0, waiting: 0, started: false},
+		},
+	}, {
+		max: 1,
+		run: []call{
+			{typ: add1, running: 1, waiting: 0, started: true},
+			{typ: add1, running: 1, waiting: 1, started: false},
+			{typ: done, running: 1, waiting: 0, started: true},
+			{typ: done, running: 0, waiting: 0, started: false},
+			{typ: add1, running: 1, waiting: 0, started: true},
+		},
+	}, {
+		max: 3,
+		run: []call{
+			{typ: add1, running: 1, waiting: 0, started: true},
+			{typ: add1, running: 2, waiting: 0, started: true},
+			{typ: add1, running: 3, waiting: 0, started: true},
+			{typ: add1, running: 3, waiting: 1, started: false},
+			{typ: add1, running: 3, waiting: 2, started: false},
+			{typ: add1, running: 3, waiting: 3, started: false},
+			{typ: done, running: 3, waiting: 2, started: true},
+			{typ: add1, running: 3, waiting: 3, started: false},
+			{typ: done, running: 3, waiting: 2, started: true},
+			{typ: done, running: 3, waiting: 1, started: true},
+			{typ: done, running: 3, waiting: 0, started: true},
+			{typ: done, running: 2, waiting: 0, started: false},
+			{typ: done, running: 1, waiting: 0, started: false},
+			{typ: done, running: 0, waiting: 0, started: false},
+		},
+	}}
+	for i, tc := range testCases {
+		ctx := &testContext{
+			startParallel: make(chan bool),
+			maxParallel:   tc.max,
+		}
+		for j, call := range tc.run {
+			doCall := func(f func()) chan bool {
+				done := make(chan bool)
+				go func() {
+					f()
+					done <- true
+				}()
+				return done
+			}
+			started := false
+			switch call.typ {
+			case add1:
+				signal := doCall(ctx.waitParallel)
+				select {
+				case <-signal:
+					started = true
+				case ctx.startParallel <- true:
+					<-signal
+				}
+			case done:
+				signal := doCall(ctx.release)
+				select {
+				case <-signal:
+				case <-ctx.startParallel:
+					started = true
+					<-signal
+				}
+			}
+			if started != call.started {
+				t.Errorf("%d:%d:started: got %v; want %v", i, j, started, call.started)
+			}
+			if ctx.running != call.running {
+				t.Errorf("%d:%d:running: got %v; want %v", i, j, ctx.running, call.running)
+			}
+			if ctx.numWaiting != call.waiting {
+				t.Errorf("%d:%d:waiting: got %v; want %v", i, j, ctx.numWaiting, call.waiting)
+			}
+		}
+	}
+}
+
+// runRoot runs f as the body of a top-level test, as RunTests does,
+// and returns the root test and its output.
+func runRoot(chatty bool, maxPar int, f func(t *T)) (*T, string) {
+	buf := &bytes.Buffer{}
+	root := &T{
+		common: common{
+			signal:  make(chan bool),
+			barrier: make(chan bool),
+			w:       buf,
+			chatty:  chatty,
+		},
+		context: newTestContext(maxPar, newMatcher(regexp.MatchString, "", "")),
+	}
+	tRunner(root, func(t *T) {
+		t.Run("Test", f)
+		go func() { <-t.signal }()
+	})
+	return root, buf.String()
+}
+
+func TestTRun(t *T) {
+	realTest := t
+	testCases := []struct {
+		desc   string
+		ok     bool
+		maxPar int
+		chatty bool
+		output string
+		f      func(*T)
+	}{{
+		desc:   "failnow skips future sequential and parallel tests at same level",
+		ok:     false,
+		maxPar: 1,
+		output: `
+--- FAIL: Test (N.NNs)
+    --- FAIL: Test/#00 (N.NNs)
+    	sub_test.go:NNN: fail
+		`,
+		f: func(t *T) {
+			ranSeq := false
+			ranPar := false
+			t.Run("", func(t *T) {
+				t.Run("par", func(t *T) {
+					t.Parallel()
+					ranPar = true
+				})
+				t.Run("seq", func(t *T) {
+					ranSeq = true
+				})
+				t.Fatal("fail")
+				t.Run("seq", func(t *T) {
+					realTest.Error("test must be skipped")
+				})
+				t.Run("par", func(t *T) {
+					t.Parallel()
+					realTest.Error("test must be skipped.")
+				})
+			})
+			if !ranPar {
+				realTest.Error("parallel test was not run")
+			}
+			if !ranSeq {
+				realTest.Error("sequential test was not run")
+			}
+		},
+	}, {
+		desc:   "failure in parallel test propagates upwards",
+		ok:     false,
+		maxPar: 1,
+		output: `
+--- FAIL: Test (N.NNs)
+    --- FAIL: Test/par (N.NNs)
+        --- FAIL: Test/par/#00 (N.NNs)
+        	sub_test.go:NNN: fail
+		`,
+		f: func(t *T) {
+			t.Run("par", func(t *T) {
+				t.Parallel()
+				t.Run("", func(t *T) {
+					t.Fatal("fail")
+				})
+			})
+		},
+	}, {
+		desc:   "chatty reports every level",
+		ok:     true,
+		chatty: true,
+		maxPar: 1,
+		output: `
+=== RUN Test
+=== RUN Test/a
+=== RUN Test/a/b
+=== RUN Test/a/c
+--- PASS: Test (N.NNs)
+    --- PASS: Test/a (N.NNs)
+        --- PASS: Test/a/b (N.NNs)
+        --- SKIP: Test/a/c (N.NNs)
+        	sub_test.go:NNN: skipped
+`,
+		f: func(t *T) {
+			t.Run("a", func(t *T) {
+				t.Run("b", func(t *T) {})
+				t.Run("c", func(t *T) { t.Skip("skipped") })
+			})
+		},
+	}, {
+		desc:   "parallel subtests finish before Run returns",
+		ok:     true,
+		maxPar: 4,
+		f: func(t *T) {
+			var count int32
+			t.Run("group", func(t *T) {
+				for i := 0; i < 10; i++ {
+					t.Run("", func(t *T) {
+						t.Parallel()
+						time.Sleep(time.Millisecond)
+						atomic.AddInt32(&count, 1)
+					})
+				}
+				if n := atomic.LoadInt32(&count); n != 0 {
+					realTest.Errorf("%d parallel subtests ran before the parent returned", n)
+				}
+			})
+			if n := atomic.LoadInt32(&count); n != 10 {
+				realTest.Errorf("Run returned after %d of 10 parallel subtests", n)
+			}
+		},
+	}, {
+		desc:   "maxPar limits parallel subtests",
+		ok:     true,
+		maxPar: 2,
+		f: func(t *T) {
+			var running, max int32
+			t.Run("group", func(t *T) {
+				for i := 0; i < 10; i++ {
+					t.Run("", func(t *T) {
+						t.Parallel()
+						n := atomic.AddInt32(&running, 1)
+						for {
+							m := atomic.LoadInt32(&max)
+							if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+								break
+							}
+						}
+						time.Sleep(time.Millisecond)
+						atomic.AddInt32(&running, -1)
+					})
+				}
+			})
+			if max > 2 {
+				realTest.Errorf("%d tests ran in parallel; want at most 2", max)
+			}
+		},
+	}}
+	for _, tc := range testCases {
+		root, out := runRoot(tc.chatty, tc.maxPar, tc.f)
+		if ok := !root.Failed(); ok != tc.ok {
+			t.Errorf("%s: got ok %v; want %v", tc.desc, ok, tc.ok)
+		}
+		if tc.output == "" {
+			continue
+		}
+		want := strings.TrimSpace(tc.output)
+		re := makeRegexp(want)
+		if ok, err := regexp.MatchString(re, strings.TrimSpace(out)); !ok || err != nil {
+			t.Errorf("%s: output:\ngot:\n%s\nwant:\n%s", tc.desc, out, want)
+		}
+	}
+}
+
+func TestBRun(t *T) {
+	defer func(d time.Duration) { *benchTime = d }(*benchTime)
+	*benchTime = time.Millisecond
+
+	var names []string
+	var sum int
+	res := Benchmark(func(b *B) {
+		for _, n := range []string{"a", "b b"} {
+			b.Run(n, func(b *B) {
+				names = append(names, b.Name())
+				for i := 0; i < b.N; i++ {
+					sum++
+				}
+			})
+		}
+	})
+	// Without a context, sub-benchmarks inherit their parent's name.
+	if len(names) == 0 || names[0] != "" {
+		t.Errorf("sub-benchmark names = %q", names)
+	}
+	if res.N != 1 {
+		t.Errorf("N = %d for a benchmark with sub-benchmarks; want 1", res.N)
+	}
+
+	buf := &bytes.Buffer{}
+	root := &B{
+		common: common{
+			signal: make(chan bool),
+			name:   "root",
+			w:      buf,
+		},
+		benchFunc: func(b *B) {
+			b.Run("Bench", func(b *B) {
+				b.Run("x y", func(b *B) { b.Error("fail") })
+			})
+		},
+		benchTime: time.Millisecond,
+		context: &benchContext{
+			match: newMatcher(regexp.MatchString, "", ""),
+		},
+	}
+	root.runN(1)
+	if !root.Failed() {
+		t.Error("failing sub-benchmark did not fail its parents")
+	}
+	if out := buf.String(); !strings.Contains(out, "--- FAIL: Bench/x_y\n") {
+		t.Errorf("output %q does not report Bench/x_y", out)
+	}
+}
+
+func TestFailFast(t *T) {
+	defer func(b bool, n uint32) {
+		*failFast = b
+		atomic.StoreUint32(&numFailed, n)
+	}(*failFast, atomic.LoadUint32(&numFailed))
+	*failFast = true
+	atomic.StoreUint32(&numFailed, 0)
+
+	var ran []string
+	root, _ := runRoot(false, 1, func(t *T) {
+		t.Run("a", func(t *T) { ran = append(ran, t.Name()) })
+		t.Run("b", func(t *T) {
+			ran = append(ran, t.Name())
+			t.Fail()
+		})
+		t.Run("c", func(t *T) { ran = append(ran, t.Name()) })
+	})
+	if !root.Failed() {
+		t.Error("failing test did not fail its parents")
+	}
+	if got, want := strings.Join(ran, ","), "Test/a,Test/b"; got != want {
+		t.Errorf("ran %s; want %s", got, want)
+	}
+}
+
+func TestShardTests(t *T) {
+	defer func(n, k, m int) {
+		*numShards, *shardIndex, numMatched = n, k, m
+	}(*numShards, *shardIndex, numMatched)
+
+	tests := []InternalTest{{"TestA", nil}, {"TestB", nil}, {"TestC", nil}, {"TestD", nil}, {"TestE", nil}}
+	targets := []InternalFuzzTarget{{"FuzzA", nil}, {"FuzzB", nil}}
+	m := newMatcher(regexp.MatchString, "A|C|D|E", "")
+	*numShards = 2
+	var got []string
+	for k := 0; k < *numShards; k++ {
+		*shardIndex, numMatched = k, 0
+		ts, fs := shardTests(m, tests, targets)
+		var names []string
+		for _, test := range ts {
+			names = append(names, test.Name)
+		}
+		for _, target := range fs {
+			names = append(names, target.Name)
+		}
+		got = append(got, strings.Join(names, ","))
+	}
+	if want := []string{"TestA,TestD,FuzzA", "TestC,TestE"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("shards: got %q; want %q", got, want)
+	}
+}
+
+func makeRegexp(s string) string {
+	s = regexp.QuoteMeta(s)
+	s = strings.Replace(s, ":NNN:", `:\d\d\d:`, -1)
+	s = strings.Replace(s, "N\\.NNs", `\d*\.\d\ds`, -1)
+	return s
+}
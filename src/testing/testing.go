@@ -118,6 +118,94 @@
 // example function, at least one other function, type, variable, or constant
 // declaration, and no test or benchmark functions.
 //
+// Subtests and Sub-benchmarks
+//
+// The Run methods of T and B allow defining subtests and sub-benchmarks,
+// without having to define separate functions for each. This enables uses
+// like table-driven benchmarks and creating hierarchical tests.
+// It also provides a way to share common setup and tear-down code:
+//
+//     func TestFoo(t *testing.T) {
+//         // <setup code>
+//         t.Run("A=1", func(t *testing.T) { ... })
+//         t.Run("A=2", func(t *testing.T) { ... })
+//         t.Run("B=1", func(t *testing.T) { ... })
+//         // <tear-down code>
+//     }
+//
+// Each subtest and sub-benchmark has a unique name: the combination of the name
+// of the top-level test and the sequence of names passed to Run, separated by
+// slashes, with an optional trailing sequence number for disambiguation.
+// Spaces in the names passed to Run are replaced by underscores.
+//
+// The argument to the -run and -bench command-line flags is a slash-separated
+// list of regular expressions that match each name element in turn.
+// For example:
+//
+//     go test -run Foo     # Run top-level tests matching "Foo".
+//     go test -run Foo/A=  # Run subtests of Foo matching "A=".
+//     go test -run /A=1    # Run all subtests of a top-level test matching "A=1".
+//
+// Subtests can also be used to control parallelism. A parent test will only
+// complete once all of its subtests complete. In this example, all tests are
+// run in parallel with each other, and only with each other, regardless of
+// other top-level tests that may be defined:
+//
+//     func TestGroupedParallel(t *testing.T) {
+//         for _, tc := range tests {
+//             tc := tc // capture range variable
+//             t.Run(tc.Name, func(t *testing.T) {
+//                 t.Parallel()
+//                 ...
+//             })
+//         }
+//     }
+//
+// Run does not return until parallel subtests have completed, providing a way
+// to clean up after a group of parallel tests:
+//
+//     func TestTeardownParallel(t *testing.T) {
+//         // This Run will not return until the parallel tests finish.
+//         t.Run("group", func(t *testing.T) {
+//             t.Run("Test1", parallelTest1)
+//             t.Run("Test2", parallelTest2)
+//             t.Run("Test3", parallelTest3)
+//         })
+//         // <tear-down code>
+//     }
+//
+// With -test.v, the result of each test and subtest is reported, with the
+// results of subtests indented below their parent.
+//
+// Fuzzing
+//
+// Functions of the form
+//     func FuzzXxx(*testing.F)
+// are considered fuzz targets. A fuzz target adds seed inputs to its corpus
+// with F.Add and passes a fuzz function to F.Fuzz, which takes a *T
+// followed by the values of an input:
+//
+//     func FuzzParseQuery(f *testing.F) {
+//         f.Add("x=1&y=2")
+//         f.Fuzz(func(t *testing.T, s string) {
+//             if _, err := url.ParseQuery(s); err != nil {
+//                 t.Skip()
+//             }
+//             ...
+//         })
+//     }
+//
+// By default, a fuzz target runs like a test, calling the fuzz function on
+// each seed input and on each input stored in the testdata/fuzz/FuzzXxx
+// directory of the package, as subtests.
+//
+// With the -fuzz flag, "go test" builds the package with coverage
+// instrumentation and the fuzzing engine keeps calling the fuzz function of
+// the matching target with inputs mutated from the corpus, adding to the
+// corpus any input that reaches new code. When an input makes the fuzz
+// function fail or panic, it is minimized and written to the testdata/fuzz
+// directory, so that later runs of "go test" check it as a regression test.
+//
 // Main
 //
 // It is sometimes necessary for a test program to do extra setup or teardown
@@ -147,12 +235,14 @@ import (
 	"bytes"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"runtime"
 	"runtime/pprof"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -173,7 +263,7 @@ var (
 	// Report as tests are run; default is silent for success.
 	chatty           = flag.Bool("test.v", false, "verbose: print additional output")
 	coverProfile     = flag.String("test.coverprofile", "", "write a coverage profile to the named file after execution")
-	match            = flag.String("test.run", "", "regular expression to select tests and examples to run")
+	match            = flag.String("test.run", "", "regular expression selecting tests and examples to run; a slash-separated list of regular expressions selects subtests")
 	memProfile       = flag.String("test.memprofile", "", "write a memory profile to the named file after execution")
 	memProfileRate   = flag.Int("test.memprofilerate", 0, "if >=0, sets runtime.MemProfileRate")
 	cpuProfile       = flag.String("test.cpuprofile", "", "write a cpu profile to the named file during execution")
@@ -183,25 +273,46 @@ var (
 	timeout          = flag.Duration("test.timeout", 0, "if positive, sets an aggregate time limit for all tests")
 	cpuListStr       = flag.String("test.cpu", "", "comma-separated list of number of CPUs to use for each test")
 	parallel         = flag.Int("test.parallel", runtime.GOMAXPROCS(0), "maximum test parallelism")
+	count            = flag.Uint("test.count", 1, "run tests and benchmarks `n` times")
+	failFast         = flag.Bool("test.failfast", false, "do not start new tests after the first test failure")
+	testlogFile      = flag.String("test.testlogfile", "", "write test action log to `file` (for use only by cmd/go)")
+
+	// The shard flags split the matched tests of a package between
+	// several runs of the test binary, for instance on different machines.
+	// The matched tests are dealt out to the shards in turn, in the order
+	// in which they appear in the package, so the same binary always puts
+	// a test in the same shard.
+	numShards  = flag.Int("test.shards", 1, "split the matched tests into `n` shards")
+	shardIndex = flag.Int("test.shard", 0, "run only the tests of shard `k`, counting from 0")
 
 	haveExamples bool // are there examples?
 
 	cpuList []int
+
+	numFailed  uint32 // number of test failures, for -test.failfast
+	numMatched int    // number of matched tests dealt out to the shards
 )
 
 // common holds the elements common between T and B and
 // captures common methods such as Errorf.
 type common struct {
-	mu       sync.RWMutex // guards output and failed
+	mu       sync.RWMutex // guards output, failed, and done.
 	output   []byte       // Output generated by test or benchmark.
+	w        io.Writer    // For flushToParent.
+	chatty   bool         // A copy of the chatty flag.
 	failed   bool         // Test or benchmark has failed.
 	skipped  bool         // Test of benchmark has been skipped.
 	finished bool
+	done     bool // Test is finished and all subtests have completed.
 
+	parent   *common
+	level    int       // Nesting depth of test or benchmark.
+	name     string    // Name of test or benchmark.
 	start    time.Time // Time test or benchmark started
 	duration time.Duration
-	self     interface{}      // To be sent on signal channel when done.
-	signal   chan interface{} // Output for serial tests.
+	barrier  chan bool // To signal parallel subtests they may start.
+	signal   chan bool // To signal a test is done.
+	sub      []*T      // Queue of subtests to be run in parallel.
 }
 
 // Short reports whether the -test.short flag is set.
@@ -248,6 +359,58 @@ func decorate(s string) string {
 	return buf.String()
 }
 
+// flushToParent writes c.output to the parent after first writing the header
+// with the given format and arguments.
+func (c *common) flushToParent(format string, args ...interface{}) {
+	p := c.parent
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	fmt.Fprintf(p.w, format, args...)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	io.Copy(p.w, bytes.NewReader(c.output))
+	c.output = c.output[:0]
+}
+
+// printRoot writes a status line directly to the root test's io.Writer,
+// so that it appears without waiting for c and its parents to report.
+func (c *common) printRoot(format string, args ...interface{}) {
+	root := c
+	for ; root.parent != nil; root = root.parent {
+	}
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	fmt.Fprintf(root.w, format, args...)
+}
+
+// An indenter is the io.Writer of a subtest. It indents the output
+// flushed into it by the subtest's own subtests and appends it to the
+// subtest's output, so that nested results line up below their parent.
+type indenter struct {
+	c *common
+}
+
+func (w indenter) Write(b []byte) (n int, err error) {
+	n = len(b)
+	for len(b) > 0 {
+		end := bytes.IndexByte(b, '\n')
+		if end == -1 {
+			end = len(b)
+		} else {
+			end++
+		}
+		// An indent of 4 spaces will neatly align the dashes with the status
+		// indicator of the parent.
+		const indent = "    "
+		w.c.output = append(w.c.output, indent...)
+		w.c.output = append(w.c.output, b[:end]...)
+		b = b[end:]
+	}
+	return
+}
+
 // fmtDuration returns a string representing d in the form "87.00s".
 func fmtDuration(d time.Duration) string {
 	return fmt.Sprintf("%.2fs", d.Seconds())
@@ -264,6 +427,7 @@ type TB interface {
 	Fatalf(format string, args ...interface{})
 	Log(args ...interface{})
 	Logf(format string, args ...interface{})
+	Name() string
 	Skip(args ...interface{})
 	SkipNow()
 	Skipf(format string, args ...interface{})
@@ -282,16 +446,29 @@ var _ TB = (*B)(nil)
 // Logs are accumulated during execution and dumped to standard error when done.
 type T struct {
 	common
-	name          string    // Name of test.
-	startParallel chan bool // Parallel tests will wait on this.
+	isParallel bool
+	context    *testContext // For running tests and subtests.
 }
 
 func (c *common) private() {}
 
+// Name returns the name of the running test or benchmark.
+func (c *common) Name() string {
+	return c.name
+}
+
 // Fail marks the function as having failed but continues execution.
+// A failing subtest also marks its parents as having failed.
 func (c *common) Fail() {
+	if c.parent != nil {
+		c.parent.Fail()
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	// c.done needs to be locked to synchronize checks to c.done in parent tests.
+	if c.done {
+		panic("Fail in goroutine after " + c.name + " has completed")
+	}
 	c.failed = true
 }
 
@@ -319,7 +496,7 @@ func (c *common) FailNow() {
 	// A previous version of this code said:
 	//
 	//	c.duration = ...
-	//	c.signal <- c.self
+	//	c.signal <- true
 	//	runtime.Goexit()
 	//
 	// This previous version duplicated code (those lines are in
@@ -414,10 +591,33 @@ func (c *common) Skipped() bool {
 // Parallel signals that this test is to be run in parallel with (and only with)
 // other parallel tests.
 func (t *T) Parallel() {
-	t.signal <- (*T)(nil) // Release main testing loop
-	<-t.startParallel     // Wait for serial tests to finish
-	// Assuming Parallel is the first thing a test does, which is reasonable,
-	// reinitialize the test's start time because it's actually starting now.
+	if t.isParallel {
+		panic("testing: t.Parallel called multiple times")
+	}
+	t.isParallel = true
+	if t.parent == nil {
+		// The function of a fuzz target is run in isolation while fuzzing;
+		// there is nothing for it to run in parallel with.
+		return
+	}
+
+	// We don't want to include the time we spend waiting for serial tests
+	// in the test duration. Record the elapsed time thus far and reset the
+	// timer afterwards.
+	t.duration += time.Since(t.start)
+
+	// Add to the list of tests to be released by the parent.
+	t.parent.sub = append(t.parent.sub, t)
+
+	if t.chatty {
+		t.printRoot("=== PAUSE %s\n", t.name)
+	}
+	t.signal <- true   // Release calling test.
+	<-t.parent.barrier // Wait for the parent test to complete.
+	t.context.waitParallel()
+	if t.chatty {
+		t.printRoot("=== CONT %s\n", t.name)
+	}
 	t.start = time.Now()
 }
 
@@ -428,13 +628,13 @@ type InternalTest struct {
 	F    func(*T)
 }
 
-func tRunner(t *T, test *InternalTest) {
-	// When this goroutine is done, either because test.F(t)
+func tRunner(t *T, fn func(t *T)) {
+	// When this goroutine is done, either because fn(t)
 	// returned normally or because a test failure triggered
 	// a call to runtime.Goexit, record the duration and send
 	// a signal saying that the test is done.
 	defer func() {
-		t.duration = time.Now().Sub(t.start)
+		t.duration += time.Now().Sub(t.start)
 		// If the test panicked, print any test output before dying.
 		err := recover()
 		if !t.finished && err == nil {
@@ -442,21 +642,154 @@ func tRunner(t *T, test *InternalTest) {
 		}
 		if err != nil {
 			t.Fail()
-			t.report()
+			t.flushFailures()
 			panic(err)
 		}
-		t.signal <- t
+
+		if len(t.sub) > 0 {
+			// Run parallel subtests.
+			// Decrease the running count for this test.
+			t.context.release()
+			// Release the parallel subtests.
+			close(t.barrier)
+			// Wait for subtests to complete.
+			for _, sub := range t.sub {
+				<-sub.signal
+			}
+			if !t.isParallel {
+				// Reacquire the count for sequential tests. See comment in Run.
+				t.context.waitParallel()
+			}
+		} else if t.isParallel {
+			// Only release the count for this test if it was run as a parallel
+			// test. See comment in Run method.
+			t.context.release()
+		}
+		t.report() // Report after all subtests have finished.
+		if t.Failed() {
+			atomic.AddUint32(&numFailed, 1)
+		}
+
+		t.mu.Lock()
+		t.done = true
+		t.mu.Unlock()
+		t.signal <- true
 	}()
 
 	t.start = time.Now()
-	test.F(t)
+	fn(t)
 	t.finished = true
 }
 
+// flushFailures flushes the output of c and its parents, reporting each
+// of them as failed, so that it is printed before the panic that is about
+// to terminate the test binary.
+func (c *common) flushFailures() {
+	for p := c; p.parent != nil; p = p.parent {
+		if p != c {
+			p.duration += time.Now().Sub(p.start)
+		}
+		p.flushToParent("--- FAIL: %s (%s)\n", p.name, fmtDuration(p.duration))
+	}
+}
+
+// Run runs f as a subtest of t called name. It reports whether f succeeded.
+// Run will block until all its parallel subtests have completed.
+func (t *T) Run(name string, f func(t *T)) bool {
+	return t.context.run(&t.common, name, f)
+}
+
+// run runs f as a subtest of parent called name. It is the implementation
+// of T.Run, and is also used to run the corpus entries of a fuzz target.
+func (ctx *testContext) run(parent *common, name string, f func(t *T)) bool {
+	testName, ok := ctx.match.fullName(parent, name)
+	if !ok || shouldFailFast() {
+		return true
+	}
+	t := &T{
+		common: common{
+			barrier: make(chan bool),
+			signal:  make(chan bool),
+			name:    testName,
+			parent:  parent,
+			level:   parent.level + 1,
+			chatty:  parent.chatty,
+		},
+		context: ctx,
+	}
+	t.w = indenter{&t.common}
+
+	if t.chatty {
+		t.printRoot("=== RUN %s\n", t.name)
+	}
+	// Instead of reducing the running count of this test before calling the
+	// tRunner and increasing it afterwards, we rely on tRunner keeping the
+	// count correct. This ensures that a sequence of sequential tests runs
+	// without being preempted, even when their parent is a parallel test. This
+	// may especially reduce surprises if *parallel == 1.
+	go tRunner(t, f)
+	<-t.signal
+	return !t.Failed()
+}
+
+// testContext holds all fields that are common to all tests. This includes
+// synchronization primitives to run at most *parallel tests.
+type testContext struct {
+	match *matcher
+
+	mu sync.Mutex
+
+	// Channel used to signal tests that are ready to be run in parallel.
+	startParallel chan bool
+
+	// running is the number of tests currently running in parallel.
+	// This does not include tests that are waiting for subtests to complete.
+	running int
+
+	// numWaiting is the number tests waiting to be run in parallel.
+	numWaiting int
+
+	// maxParallel is a copy of the parallel flag.
+	maxParallel int
+}
+
+func newTestContext(maxParallel int, m *matcher) *testContext {
+	return &testContext{
+		match:         m,
+		startParallel: make(chan bool),
+		maxParallel:   maxParallel,
+		running:       1, // Set the count to 1 for the main (sequential) test.
+	}
+}
+
+func (c *testContext) waitParallel() {
+	c.mu.Lock()
+	if c.running < c.maxParallel {
+		c.running++
+		c.mu.Unlock()
+		return
+	}
+	c.numWaiting++
+	c.mu.Unlock()
+	<-c.startParallel
+}
+
+func (c *testContext) release() {
+	c.mu.Lock()
+	if c.numWaiting == 0 {
+		c.running--
+		c.mu.Unlock()
+		return
+	}
+	c.numWaiting--
+	c.mu.Unlock()
+	c.startParallel <- true // Pick a waiting test to be run.
+}
+
 // An internal function but exported because it is cross-package; part of the implementation
 // of the "go test" command.
 func Main(matchString func(pat, str string) (bool, error), tests []InternalTest, benchmarks []InternalBenchmark, examples []InternalExample) {
-	os.Exit(MainStart(matchString, tests, benchmarks, examples).Run())
+	os.Exit(MainStart(matchString, tests, benchmarks, nil, examples).Run())
 }
 
 // M is a type passed to a TestMain function to run the actual tests.
@@ -464,17 +797,19 @@ type M struct {
 	matchString func(pat, str string) (bool, error)
 	tests       []InternalTest
 	benchmarks  []InternalBenchmark
+	fuzzTargets []InternalFuzzTarget
 	examples    []InternalExample
 }
 
 // MainStart is meant for use by tests generated by 'go test'.
 // It is not meant to be called directly and is not subject to the Go 1 compatibility document.
 // It may change signature from release to release.
-func MainStart(matchString func(pat, str string) (bool, error), tests []InternalTest, benchmarks []InternalBenchmark, examples []InternalExample) *M {
+func MainStart(matchString func(pat, str string) (bool, error), tests []InternalTest, benchmarks []InternalBenchmark, fuzzTargets []InternalFuzzTarget, examples []InternalExample) *M {
 	return &M{
 		matchString: matchString,
 		tests:       tests,
 		benchmarks:  benchmarks,
+		fuzzTargets: fuzzTargets,
 		examples:    examples,
 	}
 }
@@ -483,11 +818,15 @@ func MainStart(matchString func(pat, str string) (bool, error), tests []Internal
 func (m *M) Run() int {
 	flag.Parse()
 	parseCpuList()
+	if *numShards < 1 || *shardIndex < 0 || *shardIndex >= *numShards {
+		fmt.Fprintf(os.Stderr, "testing: invalid -test.shard=%d for -test.shards=%d\n", *shardIndex, *numShards)
+		os.Exit(1)
+	}
 
 	before()
 	startAlarm()
 	haveExamples = len(m.examples) > 0
-	testOk := RunTests(m.matchString, m.tests)
+	testOk := runTests(m.matchString, m.tests, m.fuzzTargets)
 	exampleOk := RunExamples(m.matchString, m.examples)
 	stopAlarm()
 	if !testOk || !exampleOk {
@@ -495,100 +834,126 @@ func (m *M) Run() int {
 		after()
 		return 1
 	}
+	if *matchFuzz != "" && !runFuzzing(m.matchString, m.fuzzTargets) {
+		fmt.Println("FAIL")
+		after()
+		return 1
+	}
 	fmt.Println("PASS")
 	RunBenchmarks(m.matchString, m.benchmarks)
 	after()
 	return 0
 }
 
-func (t *T) report() {
-	dstr := fmtDuration(t.duration)
-	format := "--- %s: %s (%s)\n%s"
-	if t.Failed() {
-		fmt.Printf(format, "FAIL", t.name, dstr, t.output)
-	} else if *chatty {
-		if t.Skipped() {
-			fmt.Printf(format, "SKIP", t.name, dstr, t.output)
+func (c *common) report() {
+	if c.parent == nil {
+		return
+	}
+	dstr := fmtDuration(c.duration)
+	format := "--- %s: %s (%s)\n"
+	if c.Failed() {
+		c.flushToParent(format, "FAIL", c.name, dstr)
+	} else if c.chatty {
+		if c.Skipped() {
+			c.flushToParent(format, "SKIP", c.name, dstr)
 		} else {
-			fmt.Printf(format, "PASS", t.name, dstr, t.output)
+			c.flushToParent(format, "PASS", c.name, dstr)
 		}
 	}
 }
 
+// An internal function but exported because it is cross-package; part of the implementation
+// of the "go test" command.
 func RunTests(matchString func(pat, str string) (bool, error), tests []InternalTest) (ok bool) {
+	return runTests(matchString, tests, nil)
+}
+
+func runTests(matchString func(pat, str string) (bool, error), tests []InternalTest, fuzzTargets []InternalFuzzTarget) (ok bool) {
 	ok = true
-	if len(tests) == 0 && !haveExamples {
+	if len(tests) == 0 && len(fuzzTargets) == 0 && !haveExamples {
 		fmt.Fprintln(os.Stderr, "testing: warning: no tests to run")
 		return
 	}
+	tests, fuzzTargets = shardTests(newMatcher(matchString, *match, "-test.run"), tests, fuzzTargets)
 	for _, procs := range cpuList {
 		runtime.GOMAXPROCS(procs)
-		// We build a new channel tree for each run of the loop.
-		// collector merges in one channel all the upstream signals from parallel tests.
-		// If all tests pump to the same channel, a bug can occur where a test
-		// kicks off a goroutine that Fails, yet the test still delivers a completion signal,
-		// which skews the counting.
-		var collector = make(chan interface{})
-
-		numParallel := 0
-		startParallel := make(chan bool)
-
-		for i := 0; i < len(tests); i++ {
-			matched, err := matchString(*match, tests[i].Name)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "testing: invalid regexp for -test.run: %s\n", err)
-				os.Exit(1)
-			}
-			if !matched {
-				continue
-			}
-			testName := tests[i].Name
-			if procs != 1 {
-				testName = fmt.Sprintf("%s-%d", tests[i].Name, procs)
+		for i := uint(0); i < *count; i++ {
+			if shouldFailFast() {
+				break
 			}
+			ctx := newTestContext(*parallel, newMatcher(matchString, *match, "-test.run"))
 			t := &T{
 				common: common{
-					signal: make(chan interface{}),
+					signal:  make(chan bool),
+					barrier: make(chan bool),
+					w:       os.Stdout,
+					chatty:  *chatty,
 				},
-				name:          testName,
-				startParallel: startParallel,
-			}
-			t.self = t
-			if *chatty {
-				fmt.Printf("=== RUN %s\n", t.name)
-			}
-			go tRunner(t, &tests[i])
-			out := (<-t.signal).(*T)
-			if out == nil { // Parallel run.
-				go func() {
-					collector <- <-t.signal
-				}()
-				numParallel++
-				continue
+				context: ctx,
 			}
-			t.report()
-			ok = ok && !out.Failed()
-		}
-
-		running := 0
-		for numParallel+running > 0 {
-			if running < *parallel && numParallel > 0 {
-				startParallel <- true
-				running++
-				numParallel--
-				continue
-			}
-			t := (<-collector).(*T)
-			t.report()
+			tRunner(t, func(t *T) {
+				for _, test := range tests {
+					t.Run(test.Name, test.F)
+				}
+				for _, target := range fuzzTargets {
+					t.runFuzzTarget(target, false)
+				}
+				// Run catching the signal rather than the tRunner as a separate
+				// goroutine to avoid adding a goroutine during the sequential
+				// phase as this pollutes the stacktrace output when aborting.
+				go func() { <-t.signal }()
+			})
 			ok = ok && !t.Failed()
-			running--
 		}
 	}
 	return
 }
 
+// shardTests returns the tests and fuzz targets matched by m that
+// belong to the shard selected by -test.shard.
+func shardTests(m *matcher, tests []InternalTest, fuzzTargets []InternalFuzzTarget) ([]InternalTest, []InternalFuzzTarget) {
+	if *numShards <= 1 {
+		return tests, fuzzTargets
+	}
+	var shardTests []InternalTest
+	for _, test := range tests {
+		if _, matched := m.fullName(nil, test.Name); matched && inShard() {
+			shardTests = append(shardTests, test)
+		}
+	}
+	var shardTargets []InternalFuzzTarget
+	for _, target := range fuzzTargets {
+		if _, matched := m.fullName(nil, target.Name); matched && inShard() {
+			shardTargets = append(shardTargets, target)
+		}
+	}
+	return shardTests, shardTargets
+}
+
+// inShard deals the next matched test out to a shard and reports
+// whether that is the shard selected by -test.shard.
+func inShard() bool {
+	n := numMatched
+	numMatched++
+	return n%*numShards == *shardIndex
+}
+
+// shouldFailFast reports whether -test.failfast is set and a test has failed,
+// so that no new tests should be started.
+func shouldFailFast() bool {
+	return *failFast && atomic.LoadUint32(&numFailed) > 0
+}
+
 // before runs before all testing.
 func before() {
+	if *testlogFile != "" {
+		f, err := os.Create(*testlogFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "testing: %s\n", err)
+			os.Exit(2)
+		}
+		startTestLog(f)
+	}
 	if *memProfileRate > 0 {
 		runtime.MemProfileRate = *memProfileRate
 	}
@@ -629,6 +994,9 @@ func before() {
 
 // after runs after all testing.
 func after() {
+	if testlogger != nil {
+		stopTestLog()
+	}
 	if *cpuProfile != "" {
 		pprof.StopCPUProfile() // flushes profile to disk
 	}
@@ -660,7 +1028,9 @@ func after() {
 		}
 		f.Close()
 	}
-	if cover.Mode != "" {
+	if cover.Mode != "" && *matchFuzz == "" {
+		// While fuzzing, the counters measure the last input run,
+		// not the coverage of the tests.
 		coverReport()
 	}
 }
@@ -0,0 +1,76 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testing
+
+import (
+	"bufio"
+	"fmt"
+	"internal/testlog"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// testLog records the environment variables and files the test reads,
+// for the go command to key cached test results on. It is written to
+// the file named by -test.testlogfile, one operation per line after a
+// "# test log" header:
+//
+//	getenv "HOME"
+//	open "testdata/input.txt"
+//	stat "/etc/hosts"
+//	chdir "/tmp"
+//
+// Each distinct operation is logged once. Relative names are relative
+// to the directory of the most recent chdir before them, or to the
+// directory the test started in.
+type testLog struct {
+	mu   sync.Mutex
+	f    *os.File
+	w    *bufio.Writer
+	seen map[string]bool
+}
+
+var testlogger *testLog
+
+func (l *testLog) Getenv(key string) { l.add("getenv", key) }
+func (l *testLog) Open(name string)  { l.add("open", name) }
+func (l *testLog) Stat(name string)  { l.add("stat", name) }
+func (l *testLog) Chdir(dir string)  { l.add("chdir", dir) }
+
+func (l *testLog) add(op, name string) {
+	line := op + " " + strconv.Quote(name) + "\n"
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.w == nil || l.seen[line] && op != "chdir" {
+		return
+	}
+	l.seen[line] = true
+	l.w.WriteString(line)
+}
+
+// startTestLog starts logging the test's operations to f.
+func startTestLog(f *os.File) {
+	testlogger = &testLog{f: f, w: bufio.NewWriter(f), seen: make(map[string]bool)}
+	testlogger.w.WriteString("# test log\n")
+	testlog.SetLogger(testlogger)
+}
+
+// stopTestLog stops logging and writes out the log,
+// so that the operations of after are not recorded.
+func stopTestLog() {
+	l := testlogger
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	err := l.w.Flush()
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	l.w = nil
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "testing: can't write %s: %s\n", *testlogFile, err)
+		os.Exit(2)
+	}
+}